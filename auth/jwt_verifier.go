@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"sync"
+
+	lConfig "github.com/nalawade41/secret-server/config"
+	"github.com/nalawade41/secret-server/internal/common/logger"
+	"github.com/nalawade41/secret-server/internal/common/security"
+	"github.com/pkg/errors"
+)
+
+var (
+	verifier     *security.JWTVerifier
+	verifierOnce = new(sync.Once)
+)
+
+// InitJWTVerifier initializes the verifier of the bearer tokens of the identity provider,
+// it returns nil when no JWT_ISSUER is configured and bearer tokens are not accepted
+func InitJWTVerifier(cfg *lConfig.Config) (*security.JWTVerifier, error) {
+	var initErr error
+
+	verifierOnce.Do(func() {
+		if !cfg.Auth.JWTEnabled() {
+			return
+		}
+
+		verifier, initErr = newJWTVerifier(cfg.Auth)
+		if initErr == nil {
+			logger.Infof("Accepting bearer tokens issued by %s", cfg.Auth.JWTIssuer)
+		}
+	})
+
+	return verifier, initErr
+}
+
+// newJWTVerifier creates the verifier with the key set from the JWKS URL or file
+func newJWTVerifier(cfg *lConfig.AuthConfig) (*security.JWTVerifier, error) {
+	if cfg.JWTAudience == "" {
+		return nil, errors.New("JWT_AUDIENCE must be set when JWT_ISSUER is set")
+	}
+
+	for _, algorithm := range cfg.JWTAlgorithms {
+		if !supportedAlgorithm(algorithm) {
+			return nil, errors.Errorf("unsupported JWT algorithm %q, JWT_ALGORITHMS may contain %v", algorithm, security.JWTAlgorithms)
+		}
+	}
+
+	var keys security.KeySet
+	switch {
+	case cfg.JWKSURL != "" && cfg.JWKSFile != "":
+		return nil, errors.New("only one of JWT_JWKS_URL and JWT_JWKS_FILE can be set")
+	case cfg.JWKSFile != "":
+		// A broken key file fails at startup instead of with the first token
+		staticKeys, err := security.LoadJWKSFile(cfg.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		keys = staticKeys
+	case cfg.JWKSURL != "":
+		// The identity provider may not be reachable yet, the keys are fetched with the first token
+		keys = &security.RemoteKeySet{URL: cfg.JWKSURL}
+	default:
+		return nil, errors.New("JWT_JWKS_URL or JWT_JWKS_FILE must be set when JWT_ISSUER is set")
+	}
+
+	return &security.JWTVerifier{
+		Issuer:     cfg.JWTIssuer,
+		Audience:   cfg.JWTAudience,
+		Algorithms: cfg.JWTAlgorithms,
+		ClockSkew:  cfg.JWTClockSkew,
+		Keys:       keys,
+	}, nil
+}
+
+func supportedAlgorithm(algorithm string) bool {
+	for _, supported := range security.JWTAlgorithms {
+		if supported == algorithm {
+			return true
+		}
+	}
+	return false
+}
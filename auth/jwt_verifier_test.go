@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	lConfig "github.com/nalawade41/secret-server/config"
+	"github.com/nalawade41/secret-server/internal/common/security"
+	"github.com/stretchr/testify/assert"
+)
+
+// testJWKS holds a single Ed25519 key
+const testJWKS = `{"keys":[{"kty":"OKP","kid":"ed","crv":"Ed25519","x":"11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"}]}`
+
+func validConfig() lConfig.AuthConfig {
+	return lConfig.AuthConfig{
+		JWTIssuer:     "https://sso.example.com",
+		JWTAudience:   "secret-server",
+		JWKSURL:       "https://sso.example.com/.well-known/jwks.json",
+		JWTClockSkew:  time.Minute,
+		JWTAlgorithms: security.JWTAlgorithms,
+	}
+}
+
+func TestNewJWTVerifier(t *testing.T) {
+	verifier, err := newJWTVerifier(&lConfig.AuthConfig{})
+	assert.Error(t, err, "The audience is required")
+	assert.Nil(t, verifier)
+
+	cfg := validConfig()
+	verifier, err = newJWTVerifier(&cfg)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "secret-server", verifier.Audience)
+		assert.IsType(t, &security.RemoteKeySet{}, verifier.Keys)
+	}
+
+	keyFile := filepath.Join(t.TempDir(), "jwks.json")
+	assert.NoError(t, os.WriteFile(keyFile, []byte(testJWKS), 0600))
+
+	// Both key sources can not be configured at once
+	cfg.JWKSFile = keyFile
+	_, err = newJWTVerifier(&cfg)
+	assert.Error(t, err)
+
+	cfg.JWKSURL = ""
+	verifier, err = newJWTVerifier(&cfg)
+	if assert.NoError(t, err) {
+		assert.IsType(t, security.StaticKeySet{}, verifier.Keys)
+	}
+
+	cfg.JWKSFile = ""
+	_, err = newJWTVerifier(&cfg)
+	assert.Error(t, err, "A key source is required")
+
+	cfg = validConfig()
+	cfg.JWTAlgorithms = []string{"HS256"}
+	_, err = newJWTVerifier(&cfg)
+	assert.Error(t, err, "Only asymmetric algorithms are supported")
+}
@@ -0,0 +1,263 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/nalawade41/secret-server/internal/common/responses"
+	"github.com/nalawade41/secret-server/internal/common/security"
+	"github.com/nalawade41/secret-server/internal/secret/requests"
+	"github.com/nalawade41/secret-server/internal/secret/response"
+	"github.com/pkg/errors"
+)
+
+const (
+	// keySize is the number of random bytes in a client side key
+	keySize = 32
+
+	// apiKeyHeader carries the API key, it is only sent to BaseURL and never to the server of a link
+	apiKeyHeader = "X-API-Key"
+)
+
+// Client creates and reads client encrypted secrets. The secret is encrypted before it is sent
+// and the key only ever lives in the fragment of the link, which browsers and HTTP clients never send.
+type Client struct {
+	// BaseURL is the address of the secret server, e.g. http://localhost:8080
+	BaseURL    string
+	HTTPClient *http.Client
+	// APIKey is sent when creating and listing secrets, servers that require API keys reject creating secrets without one
+	APIKey string
+	// Tenant creates and lists the secrets on the routes of the tenant, empty for the default routes.
+	// Links keep the routes they were created on, so reading a secret never needs it.
+	Tenant string
+}
+
+// CreateOptions controls the expiration of a new secret
+type CreateOptions struct {
+	// ExpireAfter is the lifetime of the secret in minutes, 0 never expires
+	ExpireAfter int
+	// Views is the number of times the secret can be read
+	Views int
+}
+
+// CreatedSecret is the link of a new secret and the token that revokes it
+type CreatedSecret struct {
+	// Link carries the key in its fragment, whoever holds it can read the secret
+	Link string
+	// RevocationToken is only known to the creator, DeleteSecret needs it
+	RevocationToken string
+}
+
+// New creates a client for the secret server at baseURL
+func New(baseURL string) *Client {
+	return &Client{BaseURL: strings.TrimRight(baseURL, "/"), HTTPClient: http.DefaultClient}
+}
+
+// CreateSecret encrypts the secret locally, stores the ciphertext and returns the link with the key in its fragment
+func (c *Client) CreateSecret(ctx context.Context, secret string, opts CreateOptions) (CreatedSecret, error) {
+	key := make([]byte, keySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return CreatedSecret{}, errors.Wrap(err, "failed to generate key")
+	}
+
+	env, err := security.SealEnvelope(key, "", []byte(secret))
+	if err != nil {
+		return CreatedSecret{}, errors.Wrap(err, "failed to encrypt secret")
+	}
+
+	body, err := json.Marshal(requests.CreateSecretRequest{
+		SecretText:      env.Encode(),
+		ExpiresAfter:    opts.ExpireAfter,
+		RemainingViews:  opts.Views,
+		ClientEncrypted: true,
+		Algorithm:       security.AlgorithmNameAES256GCM,
+	})
+	if err != nil {
+		return CreatedSecret{}, errors.Wrap(err, "failed to encode request")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.apiURL("/secret"), bytes.NewReader(body))
+	if err != nil {
+		return CreatedSecret{}, errors.Wrap(err, "failed to create request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.APIKey != "" {
+		req.Header.Set(apiKeyHeader, c.APIKey)
+	}
+
+	created, err := c.do(req)
+	if err != nil {
+		return CreatedSecret{}, err
+	}
+
+	return CreatedSecret{
+		Link:            created.URL + "#" + hex.EncodeToString(key),
+		RevocationToken: created.RevocationToken,
+	}, nil
+}
+
+// DeleteSecret revokes the secret behind the link with the token returned by CreateSecret
+func (c *Client) DeleteSecret(ctx context.Context, link string, revocationToken string) error {
+	u, err := url.Parse(link)
+	if err != nil {
+		return errors.Wrap(err, "invalid secret link")
+	}
+	u.Fragment = ""
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, u.String(), nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to create request")
+	}
+	req.Header.Set("X-Revocation-Token", revocationToken)
+
+	res, err := c.send(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+
+	return nil
+}
+
+// ListSecrets returns a page of the secrets created with the API key, newest first. The cursor is empty for the first
+// page and the NextCursor of the previous page after that, a limit of 0 leaves the size of the page to the server.
+func (c *Client) ListSecrets(ctx context.Context, cursor string, limit int) (response.SecretListResponse, error) {
+	query := url.Values{}
+	if cursor != "" {
+		query.Set("cursor", cursor)
+	}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+
+	u := c.apiURL("/secrets")
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return response.SecretListResponse{}, errors.Wrap(err, "failed to create request")
+	}
+	if c.APIKey != "" {
+		req.Header.Set(apiKeyHeader, c.APIKey)
+	}
+
+	res, err := c.send(req)
+	if err != nil {
+		return response.SecretListResponse{}, err
+	}
+	defer res.Body.Close()
+
+	var list response.SecretListResponse
+	if err := json.NewDecoder(res.Body).Decode(&list); err != nil {
+		return response.SecretListResponse{}, errors.Wrap(err, "failed to decode response")
+	}
+
+	return list, nil
+}
+
+// apiURL returns the address of the route on the routes of the tenant of the client
+func (c *Client) apiURL(route string) string {
+	if c.Tenant == "" {
+		return c.BaseURL + "/api/v1" + route
+	}
+	return c.BaseURL + "/api/v1/tenants/" + url.PathEscape(c.Tenant) + route
+}
+
+// GetSecret reads the secret behind the link and decrypts it with the key from the fragment, it consumes one view
+func (c *Client) GetSecret(ctx context.Context, link string) (string, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return "", errors.Wrap(err, "invalid secret link")
+	}
+
+	key, err := hex.DecodeString(u.Fragment)
+	if err != nil || len(key) != keySize {
+		return "", errors.New("secret link has no valid key in its fragment")
+	}
+
+	// The fragment is kept away from the server
+	u.Fragment = ""
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create request")
+	}
+
+	secret, err := c.do(req)
+	if err != nil {
+		return "", err
+	}
+
+	if !secret.ClientEncrypted {
+		return "", errors.New("secret is not client encrypted")
+	}
+
+	env, err := security.DecodeEnvelope(secret.SecretText)
+	if err != nil {
+		return "", errors.Wrap(err, "invalid encrypted secret")
+	}
+
+	plaintext, err := env.Open(key)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to decrypt secret")
+	}
+
+	return string(plaintext), nil
+}
+
+// do sends the request and decodes the secret
+func (c *Client) do(req *http.Request) (response.SecretResponse, error) {
+	res, err := c.send(req)
+	if err != nil {
+		return response.SecretResponse{}, err
+	}
+	defer res.Body.Close()
+
+	var secret response.SecretResponse
+	if err := json.NewDecoder(res.Body).Decode(&secret); err != nil {
+		return response.SecretResponse{}, errors.Wrap(err, "failed to decode response")
+	}
+
+	return secret, nil
+}
+
+// send sends the request, error responses are turned into errors
+func (c *Client) send(req *http.Request) (*http.Response, error) {
+	req.Header.Set("Accept", "application/json")
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "request to secret server failed")
+	}
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		defer res.Body.Close()
+
+		var problem responses.Problem
+		if err := json.NewDecoder(res.Body).Decode(&problem); err != nil || problem.ErrorCode == "" {
+			return nil, fmt.Errorf("secret server returned %s", res.Status)
+		}
+		if problem.Detail == "" {
+			return nil, fmt.Errorf("secret server returned %d %s: %s", res.StatusCode, problem.ErrorCode, problem.Title)
+		}
+		return nil, fmt.Errorf("secret server returned %d %s: %s, %s", res.StatusCode, problem.ErrorCode, problem.Title, problem.Detail)
+	}
+
+	return res, nil
+}
@@ -0,0 +1,224 @@
+package client
+
+import (
+	"context"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	apikeyhandler "github.com/nalawade41/secret-server/internal/apikey/handler"
+	apikeymemory "github.com/nalawade41/secret-server/internal/apikey/repository/memory"
+	apikeyusecase "github.com/nalawade41/secret-server/internal/apikey/usecase"
+	"github.com/nalawade41/secret-server/internal/common/security"
+	"github.com/nalawade41/secret-server/internal/domain"
+	"github.com/nalawade41/secret-server/internal/secret/handler"
+	"github.com/nalawade41/secret-server/internal/secret/repository/memory"
+	"github.com/nalawade41/secret-server/internal/secret/usecase"
+	"github.com/nalawade41/secret-server/router"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestServer starts the secret routes on an in memory repository, creating secrets requires the returned API key.
+// The key belongs to the tenant, team-a is the only tenant of the server.
+func newTestServer(t *testing.T, tenant string) (*httptest.Server, *memory.SecretManagerRepository, string) {
+	keyProvider, err := security.NewLocalKeyProvider("test", make([]byte, 32))
+	assert.NoError(t, err)
+
+	repo := memory.NewSecretManagerRepository()
+	secretHandler := handler.SecretManagerHandler{SecretManager: usecase.SecretManagerUseCase{
+		SecretRepo:  repo,
+		Encryptor:   security.RealEncryptor{KeyProvider: keyProvider},
+		IDGenerator: security.IDGenerator{Length: 22, Encoding: security.IDEncodingBase62},
+	}, MaxSecretSize: 64 * 1024}
+	tenants := map[string]domain.TenantPolicy{"team-a": {MaxSecretSize: 64 * 1024}}
+
+	apiKeys := apikeyusecase.APIKeyUseCase{
+		APIKeyRepo:  apikeymemory.NewAPIKeyRepository(),
+		Encryptor:   security.RealEncryptor{KeyProvider: keyProvider},
+		IDGenerator: security.IDGenerator{Length: 22, Encoding: security.IDEncodingBase62},
+	}
+	key, err := apiKeys.IssueKey(context.Background(), "client test", tenant, []string{domain.ScopeSecretsCreate})
+	assert.NoError(t, err)
+	apiKeyHandler := apikeyhandler.APIKeyHandler{APIKeys: apiKeys}
+
+	e := echo.New()
+	e.HTTPErrorHandler = router.ErrorHandler
+	api := e.Group("/api/v1", apiKeyHandler.Authenticate(), router.TenantScope(tenants))
+	secretHandler.InitRoutes(api, apiKeyHandler.RequireScope(domain.ScopeSecretsCreate))
+	secretHandler.InitRoutes(api.Group("/tenants/:tenant"), apiKeyHandler.RequireScope(domain.ScopeSecretsCreate))
+
+	srv := httptest.NewServer(e)
+	t.Cleanup(srv.Close)
+	return srv, repo, key.Key
+}
+
+func TestClient_RoundTrip(t *testing.T) {
+	srv, repo, apiKey := newTestServer(t, domain.DefaultTenant)
+	secrets := New(srv.URL)
+	secrets.APIKey = apiKey
+
+	created, err := secrets.CreateSecret(context.Background(), "This is a test secret", CreateOptions{Views: 1})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, created.RevocationToken)
+	link := created.Link
+
+	u, err := url.Parse(link)
+	assert.NoError(t, err)
+	assert.Empty(t, u.RawQuery, "The key must not be sent to the server")
+	assert.Len(t, u.Fragment, 64, "The key should be part of the fragment")
+
+	// The server only stores the envelope
+	hash := strings.TrimPrefix(u.Path, "/api/v1/secret/")
+	stored, err := repo.GetByHash(context.Background(), hash)
+	assert.NoError(t, err)
+	assert.True(t, stored.ClientEncrypted)
+	assert.Equal(t, security.AlgorithmNameAES256GCM, stored.Algorithm)
+	assert.NotContains(t, stored.SecretText, "This is a test secret")
+	assert.Empty(t, stored.WrappedKey)
+
+	secret, err := secrets.GetSecret(context.Background(), link)
+	assert.NoError(t, err)
+	assert.Equal(t, "This is a test secret", secret)
+
+	// The only view is consumed
+	_, err = secrets.GetSecret(context.Background(), link)
+	assert.Error(t, err)
+}
+
+func TestClient_APIKey(t *testing.T) {
+	srv, _, apiKey := newTestServer(t, domain.DefaultTenant)
+	secrets := New(srv.URL)
+
+	_, err := secrets.CreateSecret(context.Background(), "This is a test secret", CreateOptions{Views: 1})
+	assert.ErrorContains(t, err, "401 api_key_required")
+
+	secrets.APIKey = apiKey[:len(apiKey)-1]
+	_, err = secrets.CreateSecret(context.Background(), "This is a test secret", CreateOptions{Views: 1})
+	assert.ErrorContains(t, err, "401 invalid_api_key")
+
+	secrets.APIKey = apiKey
+	_, err = secrets.CreateSecret(context.Background(), "This is a test secret", CreateOptions{Views: 1})
+	assert.NoError(t, err)
+}
+
+func TestClient_ListSecrets(t *testing.T) {
+	srv, _, apiKey := newTestServer(t, domain.DefaultTenant)
+	secrets := New(srv.URL)
+
+	_, err := secrets.ListSecrets(context.Background(), "", 0)
+	assert.ErrorContains(t, err, "401 api_key_required")
+
+	secrets.APIKey = apiKey
+	var links []string
+	for i := 0; i < 3; i++ {
+		created, err := secrets.CreateSecret(context.Background(), "This is a test secret", CreateOptions{Views: 1})
+		assert.NoError(t, err)
+		links = append(links, created.Link)
+	}
+
+	// Every secret of the key is listed once over the pages
+	seen := make(map[string]bool)
+	cursor := ""
+	for page := 0; page < 3; page++ {
+		list, err := secrets.ListSecrets(context.Background(), cursor, 2)
+		assert.NoError(t, err)
+		for _, secret := range list.Secrets {
+			seen[secret.Hash] = true
+		}
+		if cursor = list.NextCursor; cursor == "" {
+			break
+		}
+	}
+	assert.Empty(t, cursor)
+	assert.Len(t, seen, 3)
+	for _, link := range links {
+		u, err := url.Parse(link)
+		assert.NoError(t, err)
+		assert.True(t, seen[path.Base(u.Path)], "secret %s is listed", link)
+	}
+
+	_, err = secrets.ListSecrets(context.Background(), "not a cursor", 0)
+	assert.ErrorContains(t, err, "400 invalid_cursor")
+}
+
+func TestClient_WrongKey(t *testing.T) {
+	srv, _, apiKey := newTestServer(t, domain.DefaultTenant)
+	secrets := New(srv.URL)
+	secrets.APIKey = apiKey
+
+	created, err := secrets.CreateSecret(context.Background(), "This is a test secret", CreateOptions{Views: 2})
+	assert.NoError(t, err)
+	link := created.Link
+
+	_, err = secrets.GetSecret(context.Background(), link[:strings.Index(link, "#")])
+	assert.Error(t, err, "A link without key can not be decrypted")
+
+	_, err = secrets.GetSecret(context.Background(), link[:strings.Index(link, "#")+1]+strings.Repeat("00", keySize))
+	assert.Error(t, err, "A wrong key can not be decrypted")
+}
+
+func TestClient_DeleteSecret(t *testing.T) {
+	srv, _, apiKey := newTestServer(t, domain.DefaultTenant)
+	secrets := New(srv.URL)
+	secrets.APIKey = apiKey
+
+	created, err := secrets.CreateSecret(context.Background(), "This is a test secret", CreateOptions{Views: 2})
+	assert.NoError(t, err)
+
+	err = secrets.DeleteSecret(context.Background(), created.Link, "wrong token")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "403 invalid_revocation_token: invalid revocation token")
+
+	err = secrets.DeleteSecret(context.Background(), created.Link, created.RevocationToken)
+	assert.NoError(t, err)
+
+	// The secret is gone although it had views left
+	_, err = secrets.GetSecret(context.Background(), created.Link)
+	assert.Error(t, err)
+}
+
+func TestClient_Tenant(t *testing.T) {
+	srv, repo, apiKey := newTestServer(t, "team-a")
+	secrets := New(srv.URL)
+	secrets.APIKey = apiKey
+	secrets.Tenant = "team-a"
+
+	created, err := secrets.CreateSecret(context.Background(), "This is a test secret", CreateOptions{Views: 2})
+	assert.NoError(t, err)
+	assert.Contains(t, created.Link, "/api/v1/tenants/team-a/secret/")
+
+	list, err := secrets.ListSecrets(context.Background(), "", 0)
+	assert.NoError(t, err)
+	assert.Len(t, list.Secrets, 1)
+
+	// The secret is not found on the default routes, nor in the default tenant of the repository
+	u, err := url.Parse(created.Link)
+	assert.NoError(t, err)
+	hash := path.Base(u.Path)
+	_, err = repo.GetByHash(context.Background(), hash)
+	assert.ErrorIs(t, err, domain.ErrSecretNotFound)
+
+	_, err = New(srv.URL).GetSecret(context.Background(), srv.URL+"/api/v1/secret/"+hash+"#"+u.Fragment)
+	assert.ErrorContains(t, err, "404 secret_not_found")
+
+	secret, err := New(srv.URL).GetSecret(context.Background(), created.Link)
+	assert.NoError(t, err)
+	assert.Equal(t, "This is a test secret", secret)
+
+	// A key of the tenant can not be used on the default routes
+	secrets.Tenant = ""
+	_, err = secrets.CreateSecret(context.Background(), "This is a test secret", CreateOptions{Views: 2})
+	assert.ErrorContains(t, err, "403 tenant_mismatch")
+}
+
+func TestClient_ServerError(t *testing.T) {
+	srv, _, _ := newTestServer(t, domain.DefaultTenant)
+	secrets := New(srv.URL)
+
+	_, err := secrets.GetSecret(context.Background(), srv.URL+"/api/v1/secret/missing#"+strings.Repeat("00", keySize))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "404 secret_not_found: secret not found")
+}
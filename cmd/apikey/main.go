@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/nalawade41/secret-server/config"
+	"github.com/nalawade41/secret-server/db"
+	"github.com/nalawade41/secret-server/internal/apikey/requests"
+	"github.com/nalawade41/secret-server/internal/common/logger"
+	"github.com/nalawade41/secret-server/internal/common/security"
+	"github.com/nalawade41/secret-server/internal/domain"
+	"github.com/nalawade41/secret-server/internal/wire"
+	"github.com/nalawade41/secret-server/keys"
+)
+
+const usage = `Usage:
+  apikey issue -name NAME [-scopes secrets:create,admin] [-tenant TENANT]
+  apikey list
+  apikey revoke ID
+
+issue prints the new key, it is only shown once. Use it to create the first key with the admin scope,
+further keys can be managed through the admin API. A key of a tenant only uses the routes of the tenant.
+list prints every key without the key itself, revoked keys included.
+revoke rejects requests with the key from then on.
+`
+
+// The apikey command manages API keys directly in the storage of the server, it needs the same configuration
+func main() {
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flag.Parse()
+
+	// Initialize the configuration and get the configuration object
+	cfg, err := config.Init()
+	if err != nil {
+		logger.Error(err)
+		os.Exit(1)
+	}
+
+	// Keys in memory are gone once this command exits
+	if cfg.Storage.Backend == config.StorageMemory {
+		logger.Error("the memory storage backend can not keep API keys, use API_KEYS_REQUIRED=false instead")
+		os.Exit(1)
+	}
+
+	// Initialize the storage backend of the API keys
+	var storage *db.Storage
+	if storage, err = db.InitStorage(cfg); err != nil {
+		logger.Error(err)
+		os.Exit(1)
+	}
+
+	// Initialize the provider of the master key
+	var keyProvider security.KeyProvider
+	if keyProvider, err = keys.InitKeyProvider(cfg); err != nil {
+		logger.Error(err)
+		os.Exit(1)
+	}
+
+	apiKeys := wire.InitializeAPIKeys(storage, cfg.Database.APIKeyTableName, cfg.Secret, keyProvider)
+	ctx := context.Background()
+
+	switch flag.Arg(0) {
+	case "issue":
+		err = issue(ctx, apiKeys, cfg.Secret.Tenants, flag.Args()[1:])
+	case "list":
+		err = list(ctx, apiKeys)
+	case "revoke":
+		if flag.NArg() != 2 {
+			flag.Usage()
+			os.Exit(2)
+		}
+		err = apiKeys.RevokeKey(ctx, flag.Arg(1))
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		logger.Error(err)
+		os.Exit(1)
+	}
+}
+
+// issue issues a key and prints it
+func issue(ctx context.Context, apiKeys domain.APIKeyUseCase, tenants map[string]domain.TenantPolicy, args []string) error {
+	flags := flag.NewFlagSet("issue", flag.ExitOnError)
+	name := flags.String("name", "", "name that tells the key apart")
+	scopes := flags.String("scopes", domain.ScopeAdmin, "comma separated scopes of the key")
+	tenant := flags.String("tenant", domain.DefaultTenant, "tenant of the key, empty for the default routes")
+	_ = flags.Parse(args)
+
+	// The same rules apply as for keys issued through the admin API
+	request := requests.IssueKeyRequest{Name: *name, Scopes: strings.Split(*scopes, ","), Tenant: *tenant}
+	if err := request.Validate(tenants); err != nil {
+		return err
+	}
+
+	key, err := apiKeys.IssueKey(ctx, request.Name, request.Tenant, request.Scopes)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Issued key %s, it is only shown once:\n", key.ID)
+	fmt.Println(key.Key)
+
+	return nil
+}
+
+// list prints every key as a table
+func list(ctx context.Context, apiKeys domain.APIKeyUseCase) error {
+	keys, err := apiKeys.ListKeys(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tSCOPES\tTENANT\tCREATED\tREVOKED")
+	for _, key := range keys {
+		revoked := "-"
+		if key.RevokedAt != nil {
+			revoked = key.RevokedAt.Format(time.RFC3339)
+		}
+		tenant := "-"
+		if key.Tenant != domain.DefaultTenant {
+			tenant = key.Tenant
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", key.ID, key.Name, strings.Join(key.Scopes, ","), tenant, key.CreatedAt.Format(time.RFC3339), revoked)
+	}
+
+	return w.Flush()
+}
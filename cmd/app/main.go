@@ -3,14 +3,18 @@ package main
 import (
 	"context"
 	"fmt"
+	"log"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 	echoadapter "github.com/awslabs/aws-lambda-go-api-proxy/echo"
+	"github.com/nalawade41/secret-server/auth"
 	"github.com/nalawade41/secret-server/config"
 	"github.com/nalawade41/secret-server/db"
 	_ "github.com/nalawade41/secret-server/docs"
 	"github.com/nalawade41/secret-server/internal/common/logger"
+	"github.com/nalawade41/secret-server/internal/common/security"
+	"github.com/nalawade41/secret-server/keys"
 	"github.com/nalawade41/secret-server/router"
 	"github.com/nalawade41/secret-server/trace"
 	"go.opentelemetry.io/contrib/instrumentation/github.com/aws/aws-lambda-go/otellambda"
@@ -20,23 +24,37 @@ import (
 var echoLambda *echoadapter.EchoLambda
 
 func init() {
+	// A failed initialization stops the function, serving requests without a handler would only panic
 	logger.Info("Initializing the Lambda function")
 
 	cfg, err := config.Init()
 	if err != nil {
-		logger.Error(err)
-		return
+		log.Fatal(err)
+	}
+	if err = cfg.RequirePublicBaseURL(); err != nil {
+		log.Fatal(err)
 	}
 
-	// Initialize the dynamo client
-	var dbConnect db.DynamoDBAPI
-	if dbConnect, err = db.InitDynamoDB(cfg); err != nil {
-		logger.Error(err)
-		return
+	// Initialize the storage backend of the secrets
+	var storage *db.Storage
+	if storage, err = db.InitStorage(cfg); err != nil {
+		log.Fatal(err)
+	}
+
+	// Initialize the provider of the master key
+	var keyProvider security.KeyProvider
+	if keyProvider, err = keys.InitKeyProvider(cfg); err != nil {
+		log.Fatal(err)
+	}
+
+	// Initialize the verifier of the bearer tokens of the identity provider
+	var verifier *security.JWTVerifier
+	if verifier, err = auth.InitJWTVerifier(cfg); err != nil {
+		log.Fatal(err)
 	}
 
 	// Initialize the server with the configuration object and the router handler
-	echoLambda = echoadapter.New(router.NewHandler(cfg, dbConnect).Init())
+	echoLambda = echoadapter.New(router.NewHandler(cfg, storage, keyProvider, verifier).Init())
 }
 
 // @title My API
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/nalawade41/secret-server/client"
+)
+
+const usage = `Usage:
+  cli [-server URL] [-api-key KEY] [-tenant TENANT] create [-views N] [-expire MINUTES] < secret.txt
+  cli [-server URL] get LINK
+  cli [-server URL] delete LINK TOKEN
+  cli [-server URL] [-api-key KEY] [-tenant TENANT] list [-limit N] [-cursor CURSOR]
+
+create encrypts the secret read from stdin locally and prints the link, the key is only part of its fragment.
+The revocation token of the new secret is printed to stderr.
+Servers that require API keys need a key with the secrets:create scope to create secrets,
+it is read from SECRET_SERVER_API_KEY unless -api-key is set.
+A key of a tenant creates and lists secrets on the routes of its tenant, set with -tenant or SECRET_SERVER_TENANT.
+get reads the secret behind a link and prints it, which consumes one view.
+delete revokes the secret behind a link with its revocation token.
+list prints the secrets created with the API key, newest first, without the secrets themselves.
+The cursor of the next page is printed to stderr.
+`
+
+// The cli command creates and reads client encrypted secrets, the server never sees the plaintext or the key
+func main() {
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	server := flag.String("server", "http://localhost:8080", "address of the secret server")
+	apiKey := flag.String("api-key", os.Getenv("SECRET_SERVER_API_KEY"), "API key sent when creating and listing secrets")
+	tenant := flag.String("tenant", os.Getenv("SECRET_SERVER_TENANT"), "tenant whose routes secrets are created and listed on")
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	secrets := client.New(*server)
+	secrets.APIKey = *apiKey
+	secrets.Tenant = *tenant
+
+	var err error
+	switch flag.Arg(0) {
+	case "create":
+		err = create(ctx, secrets, flag.Args()[1:])
+	case "get":
+		err = get(ctx, secrets, flag.Args()[1:])
+	case "delete":
+		err = revoke(ctx, secrets, flag.Args()[1:])
+	case "list":
+		err = list(ctx, secrets, flag.Args()[1:])
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// create encrypts stdin and prints the link of the new secret
+func create(ctx context.Context, secrets *client.Client, args []string) error {
+	flags := flag.NewFlagSet("create", flag.ExitOnError)
+	views := flags.Int("views", 1, "number of times the secret can be read")
+	expire := flags.Int("expire", 0, "lifetime of the secret in minutes, 0 never expires")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	secret, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return err
+	}
+
+	// A trailing newline from echo or an editor is not part of the secret
+	text := strings.TrimRight(string(secret), "\r\n")
+	if text == "" {
+		return fmt.Errorf("secret is empty")
+	}
+
+	created, err := secrets.CreateSecret(ctx, text, client.CreateOptions{ExpireAfter: *expire, Views: *views})
+	if err != nil {
+		return err
+	}
+
+	// Only the link goes to stdout, so it can be piped on without the token
+	fmt.Println(created.Link)
+	fmt.Fprintf(os.Stderr, "revocation token: %s\n", created.RevocationToken)
+	return nil
+}
+
+// get prints the secret behind the link
+func get(ctx context.Context, secrets *client.Client, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("get expects exactly one link")
+	}
+
+	secret, err := secrets.GetSecret(ctx, args[0])
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(secret)
+	return nil
+}
+
+// revoke deletes the secret behind the link
+func revoke(ctx context.Context, secrets *client.Client, args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("delete expects a link and a revocation token")
+	}
+
+	return secrets.DeleteSecret(ctx, args[0], args[1])
+}
+
+// list prints a page of the secrets of the API key, one secret per line
+func list(ctx context.Context, secrets *client.Client, args []string) error {
+	flags := flag.NewFlagSet("list", flag.ExitOnError)
+	limit := flags.Int("limit", 0, "number of secrets on the page, the server default unless set")
+	cursor := flags.String("cursor", "", "cursor of the page, printed with the previous page")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	page, err := secrets.ListSecrets(ctx, *cursor, *limit)
+	if err != nil {
+		return err
+	}
+
+	for _, secret := range page.Secrets {
+		state := "active"
+		switch {
+		case secret.Consumed:
+			state = "consumed"
+		case secret.Expired:
+			state = "expired"
+		}
+		fmt.Printf("%s\t%s\t%s\t%d views left\t%s\n", secret.Hash, secret.CreatedAt.Format(time.RFC3339), secret.ExpiresAt.Format(time.RFC3339), secret.RemainingViews, state)
+	}
+
+	if page.NextCursor != "" {
+		fmt.Fprintf(os.Stderr, "next page: -cursor %s\n", page.NextCursor)
+	}
+	return nil
+}
@@ -10,9 +10,12 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/nalawade41/secret-server/auth"
 	"github.com/nalawade41/secret-server/config"
 	_ "github.com/nalawade41/secret-server/docs"
 	"github.com/nalawade41/secret-server/internal/common/logger"
+	"github.com/nalawade41/secret-server/internal/common/security"
+	"github.com/nalawade41/secret-server/keys"
 	"github.com/nalawade41/secret-server/router"
 	"github.com/nalawade41/secret-server/server"
 )
@@ -32,6 +35,16 @@ import (
 // @host		localhost:8080
 // @BasePath	/
 // @schemes	http
+
+// @securityDefinitions.apikey	APIKey
+// @in							header
+// @name						X-API-Key
+// @description				API key issued through the admin API or the apikey command
+
+// @securityDefinitions.apikey	BearerAuth
+// @in							header
+// @name						Authorization
+// @description				JWT of the identity provider as "Bearer <token>"
 func main() {
 	var err error
 
@@ -41,16 +54,34 @@ func main() {
 		logger.Error(err)
 		return
 	}
+	if err = cfg.RequirePublicBaseURL(); err != nil {
+		logger.Error(err)
+		return
+	}
+
+	// Initialize the storage backend of the secrets
+	var storage *db.Storage
+	if storage, err = db.InitStorage(cfg); err != nil {
+		logger.Error(err)
+		return
+	}
+
+	// Initialize the provider of the master key
+	var keyProvider security.KeyProvider
+	if keyProvider, err = keys.InitKeyProvider(cfg); err != nil {
+		logger.Error(err)
+		return
+	}
 
-	// Initialize the dynamo client
-	var dbConnect db.DynamoDBAPI
-	if dbConnect, err = db.InitDynamoDB(cfg); err != nil {
+	// Initialize the verifier of the bearer tokens of the identity provider
+	var verifier *security.JWTVerifier
+	if verifier, err = auth.InitJWTVerifier(cfg); err != nil {
 		logger.Error(err)
 		return
 	}
 
 	// Initialize the server with the configuration object and the router handler
-	srv := server.NewServer(cfg, router.NewHandler(cfg, dbConnect).Init())
+	srv := server.NewServer(cfg, router.NewHandler(cfg, storage, keyProvider, verifier).Init())
 
 	// Start the server in a goroutine
 	go func() {
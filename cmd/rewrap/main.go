@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/nalawade41/secret-server/config"
+	"github.com/nalawade41/secret-server/db"
+	"github.com/nalawade41/secret-server/internal/common/logger"
+	"github.com/nalawade41/secret-server/internal/common/security"
+	"github.com/nalawade41/secret-server/internal/domain"
+	"github.com/nalawade41/secret-server/internal/wire"
+	"github.com/nalawade41/secret-server/keys"
+)
+
+// The rewrap command re-wraps the data keys of all stored secrets under the active master key.
+// Run it after rotating the master key, once it reports no failures the old master key can be retired.
+// Progress is written to the checkpoint file after every page, an interrupted run resumes from it.
+func main() {
+	checkpointFile := flag.String("checkpoint", "rewrap.checkpoint.json", "file that stores the progress of the re-wrap")
+	batchSize := flag.Int("batch", 100, "number of secrets read per page")
+	flag.Parse()
+
+	if *batchSize <= 0 {
+		logger.Error("batch size must be positive")
+		os.Exit(1)
+	}
+
+	// Initialize the configuration and get the configuration object
+	cfg, err := config.Init()
+	if err != nil {
+		logger.Error(err)
+		os.Exit(1)
+	}
+
+	// Initialize the storage backend of the secrets
+	var storage *db.Storage
+	if storage, err = db.InitStorage(cfg); err != nil {
+		logger.Error(err)
+		os.Exit(1)
+	}
+
+	// Initialize the provider of the master key
+	var keyProvider security.KeyProvider
+	if keyProvider, err = keys.InitKeyProvider(cfg); err != nil {
+		logger.Error(err)
+		os.Exit(1)
+	}
+
+	progress, err := loadCheckpoint(*checkpointFile)
+	if err != nil {
+		logger.Error(err)
+		os.Exit(1)
+	}
+	if progress.Cursor != "" {
+		logger.Infof("Resuming re-wrap after secret %s", progress.Cursor)
+	}
+
+	// Stop after the current page on SIGINT or SIGTERM, the checkpoint keeps the progress
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	rotation := wire.InitializeKeyRotation(storage, cfg.Database.TableName, keyProvider)
+	progress, err = rotation.RewrapSecrets(ctx, progress, *batchSize, func(progress domain.RewrapProgress) error {
+		logger.Infof("Scanned %d secrets: %d re-wrapped, %d skipped, %d failed, %d legacy", progress.Scanned, progress.Rewrapped, progress.Skipped, progress.Failed, progress.Legacy)
+		return saveCheckpoint(*checkpointFile, progress)
+	})
+	if err != nil {
+		logger.Errorf("re-wrap stopped, run again to resume from %s: %v", *checkpointFile, err)
+		os.Exit(1)
+	}
+
+	if err := os.Remove(*checkpointFile); err != nil && !errors.Is(err, os.ErrNotExist) {
+		logger.Errorf("failed to remove checkpoint: %v", err)
+	}
+
+	logger.Infof("Re-wrap finished: %d secrets scanned, %d re-wrapped, %d skipped, %d failed", progress.Scanned, progress.Rewrapped, progress.Skipped, progress.Failed)
+
+	// Legacy secrets do not hold up the retirement of a master key, they are not wrapped by any
+	if progress.Legacy > 0 {
+		logger.Warnf("%d secrets were written before data keys, they are migrated when read and removed when they expire", progress.Legacy)
+	}
+
+	// Failed secrets are still wrapped by an old master key, it must not be retired yet
+	if progress.Failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// loadCheckpoint reads the progress of an earlier run, a missing file starts from the beginning
+func loadCheckpoint(file string) (domain.RewrapProgress, error) {
+	var progress domain.RewrapProgress
+
+	content, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return progress, nil
+	}
+	if err != nil {
+		return progress, err
+	}
+
+	err = json.Unmarshal(content, &progress)
+	return progress, err
+}
+
+// saveCheckpoint replaces the checkpoint file, the rename keeps a crash from leaving a partial file behind
+func saveCheckpoint(file string, progress domain.RewrapProgress) error {
+	content, err := json.Marshal(progress)
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(file+".tmp", content, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(file+".tmp", file)
+}
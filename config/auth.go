@@ -0,0 +1,111 @@
+package config
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nalawade41/secret-server/internal/common/logger"
+)
+
+const (
+	// defaultJWTClockSkew is the time allowed between the clocks of the identity provider and the server
+	defaultJWTClockSkew = time.Minute
+
+	// defaultJWTGroupsClaim is the claim most identity providers put the groups of the user in
+	defaultJWTGroupsClaim = "groups"
+
+	// defaultJWTTenantClaim is the claim the tenant of the user is read from
+	defaultJWTTenantClaim = "tenant"
+)
+
+// defaultJWTAlgorithms are the signature algorithms accepted unless JWT_ALGORITHMS restricts them
+var defaultJWTAlgorithms = []string{"RS256", "ES256", "EdDSA"}
+
+// AuthConfig holds how clients of the API are authenticated
+type AuthConfig struct {
+	// APIKeysRequired restricts creating secrets to clients with an API key, reading a secret only needs its link
+	APIKeysRequired bool
+
+	// JWTIssuer enables bearer tokens of the identity provider, tokens must be issued by it for JWTAudience
+	JWTIssuer   string
+	JWTAudience string
+	// JWKSURL and JWKSFile are where the public keys of the identity provider are read from, only one is set
+	JWKSURL  string
+	JWKSFile string
+	// JWTClockSkew is allowed when checking the expiration, not before and issued at times
+	JWTClockSkew  time.Duration
+	JWTAlgorithms []string
+	// JWTGroupsClaim is the claim holding the groups of the user
+	JWTGroupsClaim string
+	// JWTAdminGroups are the groups whose members may manage API keys
+	JWTAdminGroups []string
+	// JWTTenantClaim is the claim holding the tenant of the user, tokens without it use the default routes
+	JWTTenantClaim string
+}
+
+// JWTEnabled reports whether bearer tokens are accepted
+func (c AuthConfig) JWTEnabled() bool {
+	return c.JWTIssuer != ""
+}
+
+// LoadAuthConfig loads the AuthConfig struct
+func LoadAuthConfig() *AuthConfig {
+	auth := AuthConfig{
+		APIKeysRequired: true,
+
+		JWTIssuer:      os.Getenv("JWT_ISSUER"),
+		JWTAudience:    os.Getenv("JWT_AUDIENCE"),
+		JWKSURL:        os.Getenv("JWT_JWKS_URL"),
+		JWKSFile:       os.Getenv("JWT_JWKS_FILE"),
+		JWTClockSkew:   defaultJWTClockSkew,
+		JWTAlgorithms:  defaultJWTAlgorithms,
+		JWTGroupsClaim: defaultJWTGroupsClaim,
+		JWTAdminGroups: splitList(os.Getenv("JWT_ADMIN_GROUPS")),
+		JWTTenantClaim: defaultJWTTenantClaim,
+	}
+
+	if value := os.Getenv("API_KEYS_REQUIRED"); value != "" {
+		required, err := strconv.ParseBool(value)
+		if err != nil {
+			logger.Warnf("Invalid API_KEYS_REQUIRED %q, it must be true or false. Using default value", value)
+		} else {
+			auth.APIKeysRequired = required
+		}
+	}
+
+	if value := os.Getenv("JWT_CLOCK_SKEW"); value != "" {
+		skew, err := time.ParseDuration(value)
+		if err != nil || skew < 0 {
+			logger.Warnf("Invalid JWT_CLOCK_SKEW %q, it must be a duration such as 30s. Using default value", value)
+		} else {
+			auth.JWTClockSkew = skew
+		}
+	}
+
+	if algorithms := splitList(os.Getenv("JWT_ALGORITHMS")); len(algorithms) > 0 {
+		auth.JWTAlgorithms = algorithms
+	}
+
+	if value := os.Getenv("JWT_GROUPS_CLAIM"); value != "" {
+		auth.JWTGroupsClaim = value
+	}
+
+	if value := os.Getenv("JWT_TENANT_CLAIM"); value != "" {
+		auth.JWTTenantClaim = value
+	}
+
+	return &auth
+}
+
+// splitList splits a comma separated list, blank entries are dropped
+func splitList(value string) []string {
+	var list []string
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			list = append(list, entry)
+		}
+	}
+	return list
+}
@@ -0,0 +1,65 @@
+package config
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadAuthConfig(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected bool
+	}{
+		{value: "", expected: true},
+		{value: "false", expected: false},
+		{value: "true", expected: true},
+		{value: "sometimes", expected: true},
+	}
+
+	defer os.Unsetenv("API_KEYS_REQUIRED")
+
+	for _, tt := range tests {
+		os.Setenv("API_KEYS_REQUIRED", tt.value)
+
+		authConfig := LoadAuthConfig()
+
+		assert.Equal(t, tt.expected, authConfig.APIKeysRequired, "API_KEYS_REQUIRED=%q", tt.value)
+	}
+}
+
+func TestLoadAuthConfig_JWT(t *testing.T) {
+	defer func() {
+		for _, name := range []string{"JWT_ISSUER", "JWT_AUDIENCE", "JWT_CLOCK_SKEW", "JWT_ALGORITHMS", "JWT_ADMIN_GROUPS", "JWT_TENANT_CLAIM"} {
+			os.Unsetenv(name)
+		}
+	}()
+
+	authConfig := LoadAuthConfig()
+	assert.False(t, authConfig.JWTEnabled())
+	assert.Equal(t, defaultJWTClockSkew, authConfig.JWTClockSkew)
+	assert.Equal(t, defaultJWTAlgorithms, authConfig.JWTAlgorithms)
+	assert.Equal(t, defaultJWTGroupsClaim, authConfig.JWTGroupsClaim)
+	assert.Equal(t, defaultJWTTenantClaim, authConfig.JWTTenantClaim)
+
+	os.Setenv("JWT_ISSUER", "https://sso.example.com")
+	os.Setenv("JWT_AUDIENCE", "secret-server")
+	os.Setenv("JWT_CLOCK_SKEW", "30s")
+	os.Setenv("JWT_ALGORITHMS", "ES256, EdDSA")
+	os.Setenv("JWT_ADMIN_GROUPS", "secret-admins,,platform ")
+	os.Setenv("JWT_TENANT_CLAIM", "org")
+
+	authConfig = LoadAuthConfig()
+	assert.True(t, authConfig.JWTEnabled())
+	assert.Equal(t, "secret-server", authConfig.JWTAudience)
+	assert.Equal(t, 30*time.Second, authConfig.JWTClockSkew)
+	assert.Equal(t, []string{"ES256", "EdDSA"}, authConfig.JWTAlgorithms)
+	assert.Equal(t, []string{"secret-admins", "platform"}, authConfig.JWTAdminGroups)
+	assert.Equal(t, "org", authConfig.JWTTenantClaim)
+
+	// An invalid skew keeps the default
+	os.Setenv("JWT_CLOCK_SKEW", "-5s")
+	assert.Equal(t, defaultJWTClockSkew, LoadAuthConfig().JWTClockSkew)
+}
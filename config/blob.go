@@ -0,0 +1,63 @@
+package config
+
+import (
+	"os"
+
+	"github.com/nalawade41/secret-server/internal/common/constants"
+	"github.com/nalawade41/secret-server/internal/common/logger"
+)
+
+const (
+	// BlobFilesystem keeps the content of file secrets in a local directory
+	BlobFilesystem = "filesystem"
+	// BlobS3 keeps the content of file secrets in an S3 bucket or a bucket of an S3 compatible store
+	BlobS3 = "s3"
+	// BlobMemory keeps the content of file secrets in process memory, it is lost on restart
+	BlobMemory = "memory"
+
+	defaultBlobDir = "blobs"
+)
+
+// BlobConfig holds the store the content of file secrets is kept in, the secrets only point to their blob
+type BlobConfig struct {
+	Backend string
+	Dir     string
+	Bucket  string
+	// S3Endpoint points to an S3 compatible store like MinIO, it is addressed with path style URLs
+	S3Endpoint string
+}
+
+// LoadBlobConfig loads the BlobConfig struct
+func LoadBlobConfig() *BlobConfig {
+	blob := BlobConfig{
+		Backend:    defaultBlobBackend(),
+		Dir:        defaultBlobDir,
+		Bucket:     os.Getenv("BLOB_BUCKET"),
+		S3Endpoint: os.Getenv("S3_ENDPOINT"),
+	}
+
+	switch backend := os.Getenv("BLOB_BACKEND"); backend {
+	case "":
+	case BlobFilesystem, BlobS3, BlobMemory:
+		blob.Backend = backend
+	default:
+		logger.Warnf("Invalid BLOB_BACKEND %q, it must be %s, %s or %s. Using default value",
+			backend, BlobFilesystem, BlobS3, BlobMemory)
+	}
+
+	if value := os.Getenv("BLOB_DIR"); value != "" {
+		blob.Dir = value
+	}
+
+	return &blob
+}
+
+// defaultBlobBackend returns the s3 backend on Lambda, the code directory of a function is read-only
+// and its other directories do not outlive the instance
+func defaultBlobBackend() string {
+	if os.Getenv(constants.LambdaTaskRoot) != "" {
+		return BlobS3
+	}
+
+	return BlobFilesystem
+}
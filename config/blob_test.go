@@ -0,0 +1,83 @@
+package config
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadBlobConfig_ValidEnvVariables(t *testing.T) {
+	// Set environment variables
+	os.Setenv("BLOB_BACKEND", "s3")
+	os.Setenv("BLOB_DIR", "/var/lib/secret-server/blobs")
+	os.Setenv("BLOB_BUCKET", "secret-files")
+	os.Setenv("S3_ENDPOINT", "http://localhost:9000")
+
+	defer func() {
+		// Unset environment variables after the test
+		os.Unsetenv("BLOB_BACKEND")
+		os.Unsetenv("BLOB_DIR")
+		os.Unsetenv("BLOB_BUCKET")
+		os.Unsetenv("S3_ENDPOINT")
+	}()
+
+	// Load blob config
+	blobConfig := LoadBlobConfig()
+
+	// Assertions
+	assert.Equal(t, BlobS3, blobConfig.Backend)
+	assert.Equal(t, "/var/lib/secret-server/blobs", blobConfig.Dir)
+	assert.Equal(t, "secret-files", blobConfig.Bucket)
+	assert.Equal(t, "http://localhost:9000", blobConfig.S3Endpoint)
+}
+
+func TestLoadBlobConfig_MissingEnvVariables(t *testing.T) {
+	// Ensure environment variables are not set
+	os.Unsetenv("BLOB_BACKEND")
+	os.Unsetenv("BLOB_DIR")
+	os.Unsetenv("BLOB_BUCKET")
+	os.Unsetenv("S3_ENDPOINT")
+
+	// Load blob config
+	blobConfig := LoadBlobConfig()
+
+	// Assertions
+	assert.Equal(t, BlobFilesystem, blobConfig.Backend)
+	assert.Equal(t, defaultBlobDir, blobConfig.Dir)
+	assert.Empty(t, blobConfig.Bucket)
+	assert.Empty(t, blobConfig.S3Endpoint)
+}
+
+func TestLoadBlobConfig_InvalidBackend(t *testing.T) {
+	// Set an unknown backend
+	os.Setenv("BLOB_BACKEND", "gcs")
+
+	defer os.Unsetenv("BLOB_BACKEND")
+
+	// Load blob config
+	blobConfig := LoadBlobConfig()
+
+	// The default backend is used
+	assert.Equal(t, BlobFilesystem, blobConfig.Backend)
+}
+
+func TestLoadBlobConfig_Lambda(t *testing.T) {
+	// Running on Lambda
+	os.Setenv("LAMBDA_TASK_ROOT", "/var/task")
+
+	defer os.Unsetenv("LAMBDA_TASK_ROOT")
+
+	// Load blob config
+	blobConfig := LoadBlobConfig()
+
+	// The files are kept in S3 by default
+	assert.Equal(t, BlobS3, blobConfig.Backend)
+
+	// Test case: The backend is still chosen by BLOB_BACKEND
+	os.Setenv("BLOB_BACKEND", "memory")
+
+	defer os.Unsetenv("BLOB_BACKEND")
+
+	assert.Equal(t, BlobMemory, LoadBlobConfig().Backend)
+}
@@ -28,7 +28,13 @@ type (
 		Environment string
 		HTTP        *HttpConfig
 		Database    *DynamoConfig
+		Storage     *StorageConfig
+		Blob        *BlobConfig
 		AWS         *AWSConfig
+		Secret      *SecretConfig
+		Key         *KeyConfig
+		Auth        *AuthConfig
+		Quota       *QuotaConfig
 	}
 )
 
@@ -51,13 +57,35 @@ func Init() (*Config, error) {
 
 	http := LoadHttpConfig()
 	db := LoadDynamoConfig()
+	storage := LoadStorageConfig()
+	blob := LoadBlobConfig()
 	aws := LoadAWSConfig()
+	secret := LoadSecretConfig()
+	key := LoadKeyConfig()
+	auth := LoadAuthConfig()
+	quota := LoadQuotaConfig()
+
+	tenants, err := LoadTenants(secret)
+	if err != nil {
+		return nil, err
+	}
+	secret.Tenants = tenants
+
+	if secret.PublicBaseURL, err = LoadPublicBaseURL(); err != nil {
+		return nil, err
+	}
 
 	config := &Config{
 		Environment: env,
 		HTTP:        http,
 		Database:    db,
+		Storage:     storage,
+		Blob:        blob,
 		AWS:         aws,
+		Secret:      secret,
+		Key:         key,
+		Auth:        auth,
+		Quota:       quota,
 	}
 	return config, nil
 }
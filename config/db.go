@@ -4,19 +4,30 @@ import (
 	"os"
 )
 
+// defaultAPIKeyTableName is used for the table of the API keys when no name is configured
+const defaultAPIKeyTableName = "api_keys"
+
 // DynamoConfig holds config details for the dynamo db server
 type DynamoConfig struct {
 	Host      string
 	Port      string
 	TableName string
+	// APIKeyTableName is the table of the API keys, in DynamoDB as well as in the SQL backends
+	APIKeyTableName string
 }
 
 // LoadDynamoConfig loads the DynamoConfig struct
 func LoadDynamoConfig() *DynamoConfig {
 	dynamoDb := DynamoConfig{
-		Host:      os.Getenv("DB_HOST"),
-		Port:      os.Getenv("DB_PORT"),
-		TableName: os.Getenv("DB_TABLE_NAME"),
+		Host:            os.Getenv("DB_HOST"),
+		Port:            os.Getenv("DB_PORT"),
+		TableName:       os.Getenv("DB_TABLE_NAME"),
+		APIKeyTableName: defaultAPIKeyTableName,
+	}
+
+	if value := os.Getenv("DB_API_KEY_TABLE_NAME"); value != "" {
+		dynamoDb.APIKeyTableName = value
 	}
+
 	return &dynamoDb
 }
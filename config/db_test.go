@@ -12,12 +12,14 @@ func TestLoadDynamoConfig_ValidEnvVariables(t *testing.T) {
 	os.Setenv("DB_HOST", "localhost")
 	os.Setenv("DB_PORT", "8000")
 	os.Setenv("DB_TABLE_NAME", "Secrets")
+	os.Setenv("DB_API_KEY_TABLE_NAME", "Keys")
 
 	defer func() {
 		// Unset environment variables after the test
 		os.Unsetenv("DB_HOST")
 		os.Unsetenv("DB_PORT")
 		os.Unsetenv("DB_TABLE_NAME")
+		os.Unsetenv("DB_API_KEY_TABLE_NAME")
 	}()
 
 	// Load DynamoDB config
@@ -27,6 +29,7 @@ func TestLoadDynamoConfig_ValidEnvVariables(t *testing.T) {
 	assert.Equal(t, "localhost", dynamoConfig.Host)
 	assert.Equal(t, "8000", dynamoConfig.Port)
 	assert.Equal(t, "Secrets", dynamoConfig.TableName)
+	assert.Equal(t, "Keys", dynamoConfig.APIKeyTableName)
 }
 
 func TestLoadDynamoConfig_MissingEnvVariables(t *testing.T) {
@@ -34,6 +37,7 @@ func TestLoadDynamoConfig_MissingEnvVariables(t *testing.T) {
 	os.Unsetenv("DB_HOST")
 	os.Unsetenv("DB_PORT")
 	os.Unsetenv("DB_TABLE_NAME")
+	os.Unsetenv("DB_API_KEY_TABLE_NAME")
 
 	// Load DynamoDB config
 	dynamoConfig := LoadDynamoConfig()
@@ -42,4 +46,5 @@ func TestLoadDynamoConfig_MissingEnvVariables(t *testing.T) {
 	assert.Empty(t, dynamoConfig.Host)
 	assert.Empty(t, dynamoConfig.Port)
 	assert.Empty(t, dynamoConfig.TableName)
+	assert.Equal(t, defaultAPIKeyTableName, dynamoConfig.APIKeyTableName)
 }
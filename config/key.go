@@ -0,0 +1,58 @@
+package config
+
+import (
+	"os"
+
+	"github.com/nalawade41/secret-server/internal/common/logger"
+)
+
+const (
+	// KeyProviderLocal wraps data keys with a master key from the environment or a file
+	KeyProviderLocal = "local"
+	// KeyProviderKMS wraps data keys with an AWS KMS key
+	KeyProviderKMS = "kms"
+
+	defaultMasterKeyID = "local"
+)
+
+// KeyConfig holds the settings of the master key that wraps the data keys of the secrets
+type KeyConfig struct {
+	Provider      string
+	MasterKey     string
+	MasterKeyFile string
+	MasterKeyID   string
+	// DecryptMasterKeys are retired id:key pairs, they only unwrap data keys until those are re-wrapped
+	DecryptMasterKeys     string
+	DecryptMasterKeysFile string
+	KMSKeyID              string
+	KMSEndpoint           string
+}
+
+// LoadKeyConfig loads the KeyConfig struct
+func LoadKeyConfig() *KeyConfig {
+	key := KeyConfig{
+		Provider:      KeyProviderLocal,
+		MasterKey:     os.Getenv("MASTER_KEY"),
+		MasterKeyFile: os.Getenv("MASTER_KEY_FILE"),
+		MasterKeyID:   defaultMasterKeyID,
+
+		DecryptMasterKeys:     os.Getenv("DECRYPT_MASTER_KEYS"),
+		DecryptMasterKeysFile: os.Getenv("DECRYPT_MASTER_KEYS_FILE"),
+		KMSKeyID:              os.Getenv("KMS_KEY_ID"),
+		KMSEndpoint:           os.Getenv("KMS_ENDPOINT"),
+	}
+
+	switch provider := os.Getenv("KEY_PROVIDER"); provider {
+	case "":
+	case KeyProviderLocal, KeyProviderKMS:
+		key.Provider = provider
+	default:
+		logger.Warnf("Invalid KEY_PROVIDER %q, it must be %s or %s. Using default value", provider, KeyProviderLocal, KeyProviderKMS)
+	}
+
+	if value := os.Getenv("MASTER_KEY_ID"); value != "" {
+		key.MasterKeyID = value
+	}
+
+	return &key
+}
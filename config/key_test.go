@@ -0,0 +1,62 @@
+package config
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadKeyConfig_ValidEnvVariables(t *testing.T) {
+	// Set environment variables
+	os.Setenv("KEY_PROVIDER", "kms")
+	os.Setenv("MASTER_KEY_ID", "master-2024")
+	os.Setenv("KMS_KEY_ID", "alias/secret-server")
+	os.Setenv("KMS_ENDPOINT", "http://localhost:4566")
+	os.Setenv("DECRYPT_MASTER_KEYS", "master-2023:key")
+
+	defer func() {
+		// Unset environment variables after the test
+		os.Unsetenv("KEY_PROVIDER")
+		os.Unsetenv("MASTER_KEY_ID")
+		os.Unsetenv("KMS_KEY_ID")
+		os.Unsetenv("KMS_ENDPOINT")
+		os.Unsetenv("DECRYPT_MASTER_KEYS")
+	}()
+
+	// Load key config
+	keyConfig := LoadKeyConfig()
+
+	// Assertions
+	assert.Equal(t, KeyProviderKMS, keyConfig.Provider)
+	assert.Equal(t, "master-2024", keyConfig.MasterKeyID)
+	assert.Equal(t, "alias/secret-server", keyConfig.KMSKeyID)
+	assert.Equal(t, "http://localhost:4566", keyConfig.KMSEndpoint)
+	assert.Equal(t, "master-2023:key", keyConfig.DecryptMasterKeys)
+}
+
+func TestLoadKeyConfig_MissingEnvVariables(t *testing.T) {
+	// Ensure environment variables are not set
+	os.Unsetenv("KEY_PROVIDER")
+	os.Unsetenv("MASTER_KEY")
+	os.Unsetenv("MASTER_KEY_ID")
+
+	// Load key config
+	keyConfig := LoadKeyConfig()
+
+	// Assertions
+	assert.Equal(t, KeyProviderLocal, keyConfig.Provider)
+	assert.Equal(t, defaultMasterKeyID, keyConfig.MasterKeyID)
+	assert.Empty(t, keyConfig.MasterKey)
+}
+
+func TestLoadKeyConfig_InvalidProvider(t *testing.T) {
+	os.Setenv("KEY_PROVIDER", "vault")
+	defer os.Unsetenv("KEY_PROVIDER")
+
+	// Load key config
+	keyConfig := LoadKeyConfig()
+
+	// An unknown provider falls back to the local one
+	assert.Equal(t, KeyProviderLocal, keyConfig.Provider)
+}
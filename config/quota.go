@@ -0,0 +1,60 @@
+package config
+
+import (
+	"os"
+	"strconv"
+
+	"github.com/nalawade41/secret-server/internal/common/logger"
+	"github.com/nalawade41/secret-server/internal/domain"
+)
+
+// defaultQuotaTableName is used for the table of the quota usage when no name is configured
+const defaultQuotaTableName = "quotas"
+
+// QuotaConfig holds the quota of every caller and where the usage of the quotas is stored
+type QuotaConfig struct {
+	// TableName is the table of the quota usage, in DynamoDB as well as in the SQL backends
+	TableName string
+	// PerCaller is the quota of every authenticated caller, anonymous callers only have the quota of their tenant
+	PerCaller domain.QuotaPolicy
+}
+
+// LoadQuotaConfig loads the QuotaConfig struct, the quotas are unlimited unless they are set
+func LoadQuotaConfig() *QuotaConfig {
+	quota := QuotaConfig{
+		TableName: defaultQuotaTableName,
+	}
+
+	if value := os.Getenv("DB_QUOTA_TABLE_NAME"); value != "" {
+		quota.TableName = value
+	}
+
+	if value := os.Getenv("QUOTA_SECRETS_PER_DAY"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 0 {
+			logger.Warnf("Invalid QUOTA_SECRETS_PER_DAY %q, it must be a number, 0 is unlimited. Using default value", value)
+		} else {
+			quota.PerCaller.SecretsPerDay = limit
+		}
+	}
+
+	if value := os.Getenv("QUOTA_MAX_BYTES"); value != "" {
+		limit, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || limit < 0 {
+			logger.Warnf("Invalid QUOTA_MAX_BYTES %q, it must be a number of bytes, 0 is unlimited. Using default value", value)
+		} else {
+			quota.PerCaller.MaxBytes = limit
+		}
+	}
+
+	if value := os.Getenv("QUOTA_MAX_LIVE_SECRETS"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 0 {
+			logger.Warnf("Invalid QUOTA_MAX_LIVE_SECRETS %q, it must be a number, 0 is unlimited. Using default value", value)
+		} else {
+			quota.PerCaller.MaxLiveSecrets = limit
+		}
+	}
+
+	return &quota
+}
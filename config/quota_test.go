@@ -0,0 +1,67 @@
+package config
+
+import (
+	"os"
+	"testing"
+
+	"github.com/nalawade41/secret-server/internal/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadQuotaConfig_ValidEnvVariables(t *testing.T) {
+	// Set environment variables
+	os.Setenv("DB_QUOTA_TABLE_NAME", "Quotas")
+	os.Setenv("QUOTA_SECRETS_PER_DAY", "100")
+	os.Setenv("QUOTA_MAX_BYTES", "1048576")
+	os.Setenv("QUOTA_MAX_LIVE_SECRETS", "20")
+
+	defer func() {
+		// Unset environment variables after the test
+		os.Unsetenv("DB_QUOTA_TABLE_NAME")
+		os.Unsetenv("QUOTA_SECRETS_PER_DAY")
+		os.Unsetenv("QUOTA_MAX_BYTES")
+		os.Unsetenv("QUOTA_MAX_LIVE_SECRETS")
+	}()
+
+	// Load quota config
+	quotaConfig := LoadQuotaConfig()
+
+	// Assertions
+	assert.Equal(t, "Quotas", quotaConfig.TableName)
+	assert.Equal(t, domain.QuotaPolicy{SecretsPerDay: 100, MaxBytes: 1048576, MaxLiveSecrets: 20}, quotaConfig.PerCaller)
+}
+
+func TestLoadQuotaConfig_MissingEnvVariables(t *testing.T) {
+	// Ensure environment variables are not set
+	os.Unsetenv("DB_QUOTA_TABLE_NAME")
+	os.Unsetenv("QUOTA_SECRETS_PER_DAY")
+	os.Unsetenv("QUOTA_MAX_BYTES")
+	os.Unsetenv("QUOTA_MAX_LIVE_SECRETS")
+
+	// Load quota config
+	quotaConfig := LoadQuotaConfig()
+
+	// Assertions
+	assert.Equal(t, defaultQuotaTableName, quotaConfig.TableName)
+	assert.False(t, quotaConfig.PerCaller.Enabled())
+}
+
+func TestLoadQuotaConfig_InvalidEnvVariables(t *testing.T) {
+	// Set invalid environment variables
+	os.Setenv("QUOTA_SECRETS_PER_DAY", "many")
+	os.Setenv("QUOTA_MAX_BYTES", "-1")
+	os.Setenv("QUOTA_MAX_LIVE_SECRETS", "1.5")
+
+	defer func() {
+		// Unset environment variables after the test
+		os.Unsetenv("QUOTA_SECRETS_PER_DAY")
+		os.Unsetenv("QUOTA_MAX_BYTES")
+		os.Unsetenv("QUOTA_MAX_LIVE_SECRETS")
+	}()
+
+	// Load quota config
+	quotaConfig := LoadQuotaConfig()
+
+	// Invalid limits fall back to unlimited
+	assert.False(t, quotaConfig.PerCaller.Enabled())
+}
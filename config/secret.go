@@ -0,0 +1,126 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/nalawade41/secret-server/internal/common/logger"
+	"github.com/nalawade41/secret-server/internal/common/security"
+	"github.com/nalawade41/secret-server/internal/domain"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultIDLength = 22 // ~131 bits of entropy in base62
+	minIDLength     = 16
+
+	defaultMaxPassphraseAttempts = 5
+
+	defaultMaxSecretSize = 64 * 1024
+	defaultMaxFileSize   = 10 * 1024 * 1024
+)
+
+// SecretConfig holds the settings for creating secrets
+type SecretConfig struct {
+	IDLength   int
+	IDEncoding string
+	// MaxPassphraseAttempts is the number of wrong passphrases after which a secret is burned
+	MaxPassphraseAttempts int
+	// MaxSecretSize is the largest secret in bytes that is accepted, it is checked before the secret is encrypted
+	MaxSecretSize int
+	// MaxFileSize is the largest file in bytes that is accepted as a file secret
+	MaxFileSize int
+	// Tenants are the configured tenants by name with their policies, secrets of the default tenant only
+	// have the limits above
+	Tenants map[string]domain.TenantPolicy
+	// PublicBaseURL is where clients reach the server, the links of the secrets are built on it.
+	// Without it the links are built from the Host header of the request, which is only done in local mode.
+	PublicBaseURL string
+}
+
+// LoadSecretConfig loads the SecretConfig struct
+func LoadSecretConfig() *SecretConfig {
+	secret := SecretConfig{
+		IDLength:   defaultIDLength,
+		IDEncoding: security.IDEncodingBase62,
+
+		MaxPassphraseAttempts: defaultMaxPassphraseAttempts,
+		MaxSecretSize:         defaultMaxSecretSize,
+		MaxFileSize:           defaultMaxFileSize,
+	}
+
+	if value := os.Getenv("SECRET_ID_LENGTH"); value != "" {
+		idLength, err := strconv.Atoi(value)
+		if err != nil || idLength < minIDLength {
+			logger.Warnf("Invalid SECRET_ID_LENGTH %q, it must be a number of at least %d. Using default value", value, minIDLength)
+		} else {
+			secret.IDLength = idLength
+		}
+	}
+
+	switch encoding := os.Getenv("SECRET_ID_ENCODING"); encoding {
+	case "":
+	case security.IDEncodingBase62, security.IDEncodingBase32:
+		secret.IDEncoding = encoding
+	default:
+		logger.Warnf("Invalid SECRET_ID_ENCODING %q, it must be %s or %s. Using default value", encoding, security.IDEncodingBase62, security.IDEncodingBase32)
+	}
+
+	if value := os.Getenv("SECRET_MAX_PASSPHRASE_ATTEMPTS"); value != "" {
+		attempts, err := strconv.Atoi(value)
+		if err != nil || attempts < 1 {
+			logger.Warnf("Invalid SECRET_MAX_PASSPHRASE_ATTEMPTS %q, it must be a positive number. Using default value", value)
+		} else {
+			secret.MaxPassphraseAttempts = attempts
+		}
+	}
+
+	if value := os.Getenv("SECRET_MAX_SIZE"); value != "" {
+		size, err := strconv.Atoi(value)
+		if err != nil || size < 1 {
+			logger.Warnf("Invalid SECRET_MAX_SIZE %q, it must be a positive number of bytes. Using default value", value)
+		} else {
+			secret.MaxSecretSize = size
+		}
+	}
+
+	if value := os.Getenv("SECRET_MAX_FILE_SIZE"); value != "" {
+		size, err := strconv.Atoi(value)
+		if err != nil || size < 1 {
+			logger.Warnf("Invalid SECRET_MAX_FILE_SIZE %q, it must be a positive number of bytes. Using default value", value)
+		} else {
+			secret.MaxFileSize = size
+		}
+	}
+
+	return &secret
+}
+
+// LoadPublicBaseURL loads the base URL of the secret links from PUBLIC_BASE_URL. Like the tenants an invalid
+// value is an error, links to another host would hand the keys of the secrets to it.
+func LoadPublicBaseURL() (string, error) {
+	value := os.Getenv("PUBLIC_BASE_URL")
+	if value == "" {
+		return "", nil
+	}
+
+	base, err := url.Parse(value)
+	if err != nil || (base.Scheme != "http" && base.Scheme != "https") || base.Host == "" ||
+		base.User != nil || base.RawQuery != "" || base.Fragment != "" {
+		return "", errors.Errorf("invalid PUBLIC_BASE_URL %q, it must be an http or https URL without query", value)
+	}
+
+	return strings.TrimSuffix(base.String(), "/"), nil
+}
+
+// RequirePublicBaseURL returns an error when the server would build the links of the secrets from the
+// Host header of the requests, a client could then point them at any host. Only local servers may do so.
+func (c *Config) RequirePublicBaseURL() error {
+	if c.Secret.PublicBaseURL == "" && c.Environment != EnvLocal {
+		return errors.New(fmt.Sprintf("PUBLIC_BASE_URL must be set unless APP_ENV is %s", EnvLocal))
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package config
+
+import (
+	"os"
+	"testing"
+
+	"github.com/nalawade41/secret-server/internal/common/security"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadSecretConfig_ValidEnvVariables(t *testing.T) {
+	// Set environment variables
+	os.Setenv("SECRET_ID_LENGTH", "32")
+	os.Setenv("SECRET_ID_ENCODING", "base32")
+	os.Setenv("SECRET_MAX_PASSPHRASE_ATTEMPTS", "3")
+	os.Setenv("SECRET_MAX_SIZE", "1024")
+	os.Setenv("SECRET_MAX_FILE_SIZE", "4096")
+
+	defer func() {
+		// Unset environment variables after the test
+		os.Unsetenv("SECRET_ID_LENGTH")
+		os.Unsetenv("SECRET_ID_ENCODING")
+		os.Unsetenv("SECRET_MAX_PASSPHRASE_ATTEMPTS")
+		os.Unsetenv("SECRET_MAX_SIZE")
+		os.Unsetenv("SECRET_MAX_FILE_SIZE")
+	}()
+
+	// Load secret config
+	secretConfig := LoadSecretConfig()
+
+	// Assertions
+	assert.Equal(t, 32, secretConfig.IDLength)
+	assert.Equal(t, security.IDEncodingBase32, secretConfig.IDEncoding)
+	assert.Equal(t, 3, secretConfig.MaxPassphraseAttempts)
+	assert.Equal(t, 1024, secretConfig.MaxSecretSize)
+	assert.Equal(t, 4096, secretConfig.MaxFileSize)
+}
+
+func TestLoadSecretConfig_MissingEnvVariables(t *testing.T) {
+	// Ensure environment variables are not set
+	os.Unsetenv("SECRET_ID_LENGTH")
+	os.Unsetenv("SECRET_ID_ENCODING")
+	os.Unsetenv("SECRET_MAX_PASSPHRASE_ATTEMPTS")
+	os.Unsetenv("SECRET_MAX_SIZE")
+	os.Unsetenv("SECRET_MAX_FILE_SIZE")
+
+	// Load secret config
+	secretConfig := LoadSecretConfig()
+
+	// Assertions
+	assert.Equal(t, defaultIDLength, secretConfig.IDLength)
+	assert.Equal(t, security.IDEncodingBase62, secretConfig.IDEncoding)
+	assert.Equal(t, defaultMaxPassphraseAttempts, secretConfig.MaxPassphraseAttempts)
+	assert.Equal(t, defaultMaxSecretSize, secretConfig.MaxSecretSize)
+	assert.Equal(t, defaultMaxFileSize, secretConfig.MaxFileSize)
+}
+
+func TestLoadSecretConfig_InvalidEnvVariables(t *testing.T) {
+	// Set invalid environment variables
+	os.Setenv("SECRET_ID_LENGTH", "8")
+	os.Setenv("SECRET_ID_ENCODING", "hex")
+	os.Setenv("SECRET_MAX_PASSPHRASE_ATTEMPTS", "0")
+	os.Setenv("SECRET_MAX_SIZE", "-1")
+	os.Setenv("SECRET_MAX_FILE_SIZE", "ten")
+
+	defer func() {
+		// Unset environment variables after the test
+		os.Unsetenv("SECRET_ID_LENGTH")
+		os.Unsetenv("SECRET_ID_ENCODING")
+		os.Unsetenv("SECRET_MAX_PASSPHRASE_ATTEMPTS")
+		os.Unsetenv("SECRET_MAX_SIZE")
+		os.Unsetenv("SECRET_MAX_FILE_SIZE")
+	}()
+
+	// Load secret config
+	secretConfig := LoadSecretConfig()
+
+	// Short ids and unknown encodings fall back to the defaults
+	assert.Equal(t, defaultIDLength, secretConfig.IDLength)
+	assert.Equal(t, security.IDEncodingBase62, secretConfig.IDEncoding)
+	assert.Equal(t, defaultMaxPassphraseAttempts, secretConfig.MaxPassphraseAttempts)
+	assert.Equal(t, defaultMaxSecretSize, secretConfig.MaxSecretSize)
+	assert.Equal(t, defaultMaxFileSize, secretConfig.MaxFileSize)
+}
+
+func TestLoadPublicBaseURL(t *testing.T) {
+	defer os.Unsetenv("PUBLIC_BASE_URL")
+
+	// Test case: Not set
+	os.Unsetenv("PUBLIC_BASE_URL")
+	base, err := LoadPublicBaseURL()
+	assert.NoError(t, err)
+	assert.Empty(t, base)
+
+	// Test case: The trailing slash is dropped, the path is kept
+	os.Setenv("PUBLIC_BASE_URL", "https://secrets.example.com/prod/")
+	base, err = LoadPublicBaseURL()
+	assert.NoError(t, err)
+	assert.Equal(t, "https://secrets.example.com/prod", base)
+
+	// Test case: Invalid URLs are refused
+	for _, value := range []string{"secrets.example.com", "ftp://secrets.example.com", "https://secrets.example.com/?a=b", "https://"} {
+		os.Setenv("PUBLIC_BASE_URL", value)
+		_, err = LoadPublicBaseURL()
+		assert.Error(t, err, value)
+	}
+}
+
+func TestRequirePublicBaseURL(t *testing.T) {
+	// Only local servers build links from the Host header
+	assert.NoError(t, (&Config{Environment: EnvLocal, Secret: &SecretConfig{}}).RequirePublicBaseURL())
+	assert.Error(t, (&Config{Environment: Prod, Secret: &SecretConfig{}}).RequirePublicBaseURL())
+	assert.Error(t, (&Config{Secret: &SecretConfig{}}).RequirePublicBaseURL())
+	assert.NoError(t, (&Config{Environment: Prod, Secret: &SecretConfig{PublicBaseURL: "https://secrets.example.com"}}).RequirePublicBaseURL())
+}
@@ -0,0 +1,51 @@
+package config
+
+import (
+	"os"
+
+	"github.com/nalawade41/secret-server/internal/common/logger"
+)
+
+const (
+	// StorageDynamoDB stores secrets in the DynamoDB table of the Database config
+	StorageDynamoDB = "dynamodb"
+	// StorageMemory keeps secrets in process memory, they are lost on restart
+	StorageMemory = "memory"
+	// StorageSQLite stores secrets in an embedded SQLite database file
+	StorageSQLite = "sqlite"
+	// StoragePostgres stores secrets in a PostgreSQL database
+	StoragePostgres = "postgres"
+
+	defaultSQLitePath = "secrets.db"
+)
+
+// StorageConfig holds the backend the secrets are stored in and its connection settings
+type StorageConfig struct {
+	Backend     string
+	SQLitePath  string
+	PostgresURL string
+}
+
+// LoadStorageConfig loads the StorageConfig struct
+func LoadStorageConfig() *StorageConfig {
+	storage := StorageConfig{
+		Backend:     StorageDynamoDB,
+		SQLitePath:  defaultSQLitePath,
+		PostgresURL: os.Getenv("POSTGRES_URL"),
+	}
+
+	switch backend := os.Getenv("STORAGE_BACKEND"); backend {
+	case "":
+	case StorageDynamoDB, StorageMemory, StorageSQLite, StoragePostgres:
+		storage.Backend = backend
+	default:
+		logger.Warnf("Invalid STORAGE_BACKEND %q, it must be %s, %s, %s or %s. Using default value",
+			backend, StorageDynamoDB, StorageMemory, StorageSQLite, StoragePostgres)
+	}
+
+	if value := os.Getenv("SQLITE_PATH"); value != "" {
+		storage.SQLitePath = value
+	}
+
+	return &storage
+}
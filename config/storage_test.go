@@ -0,0 +1,58 @@
+package config
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadStorageConfig_ValidEnvVariables(t *testing.T) {
+	// Set environment variables
+	os.Setenv("STORAGE_BACKEND", "postgres")
+	os.Setenv("SQLITE_PATH", "/tmp/secrets.db")
+	os.Setenv("POSTGRES_URL", "postgres://localhost:5432/secrets")
+
+	defer func() {
+		// Unset environment variables after the test
+		os.Unsetenv("STORAGE_BACKEND")
+		os.Unsetenv("SQLITE_PATH")
+		os.Unsetenv("POSTGRES_URL")
+	}()
+
+	// Load storage config
+	storageConfig := LoadStorageConfig()
+
+	// Assertions
+	assert.Equal(t, StoragePostgres, storageConfig.Backend)
+	assert.Equal(t, "/tmp/secrets.db", storageConfig.SQLitePath)
+	assert.Equal(t, "postgres://localhost:5432/secrets", storageConfig.PostgresURL)
+}
+
+func TestLoadStorageConfig_MissingEnvVariables(t *testing.T) {
+	// Ensure environment variables are not set
+	os.Unsetenv("STORAGE_BACKEND")
+	os.Unsetenv("SQLITE_PATH")
+	os.Unsetenv("POSTGRES_URL")
+
+	// Load storage config
+	storageConfig := LoadStorageConfig()
+
+	// Assertions
+	assert.Equal(t, StorageDynamoDB, storageConfig.Backend)
+	assert.Equal(t, defaultSQLitePath, storageConfig.SQLitePath)
+	assert.Empty(t, storageConfig.PostgresURL)
+}
+
+func TestLoadStorageConfig_InvalidBackend(t *testing.T) {
+	// Set an unknown backend
+	os.Setenv("STORAGE_BACKEND", "mysql")
+
+	defer os.Unsetenv("STORAGE_BACKEND")
+
+	// Load storage config
+	storageConfig := LoadStorageConfig()
+
+	// The default backend is used
+	assert.Equal(t, StorageDynamoDB, storageConfig.Backend)
+}
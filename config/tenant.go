@@ -0,0 +1,121 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/nalawade41/secret-server/internal/domain"
+	"github.com/pkg/errors"
+)
+
+// tenantPolicy is a tenant as it is configured in TENANTS or TENANTS_FILE, the TTL is a duration such as 24h
+type tenantPolicy struct {
+	MaxTTL        string       `json:"maxTTL"`
+	MaxViews      int          `json:"maxViews"`
+	MaxSecretSize int          `json:"maxSecretSize"`
+	MaxFileSize   int          `json:"maxFileSize"`
+	Quota         *tenantQuota `json:"quota"`
+}
+
+// tenantQuota is the quota shared by all callers of a tenant, limits that are not set are unlimited
+type tenantQuota struct {
+	SecretsPerDay  int   `json:"secretsPerDay"`
+	MaxBytes       int64 `json:"maxBytes"`
+	MaxLiveSecrets int   `json:"maxLiveSecrets"`
+}
+
+// LoadTenants loads the tenants from the JSON object in TENANTS or in the file TENANTS_FILE, keyed by the name
+// of the tenant. Unlike other settings an invalid tenant is an error, a tenant without its policy must not start.
+// Sizes that are not set are the sizes of the deployment in secret.
+func LoadTenants(secret *SecretConfig) (map[string]domain.TenantPolicy, error) {
+	value := os.Getenv("TENANTS")
+	if file := os.Getenv("TENANTS_FILE"); file != "" {
+		if value != "" {
+			return nil, errors.New("only one of TENANTS and TENANTS_FILE can be set")
+		}
+
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("failed to read tenants file %s", file))
+		}
+		value = string(content)
+	}
+
+	if value == "" {
+		return nil, nil
+	}
+
+	var configured map[string]tenantPolicy
+	decoder := json.NewDecoder(bytes.NewBufferString(value))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&configured); err != nil {
+		return nil, errors.Wrap(err, "invalid tenants, they must be a JSON object of tenant policies")
+	}
+
+	tenants := make(map[string]domain.TenantPolicy, len(configured))
+	for name, configuredPolicy := range configured {
+		if !domain.ValidTenantName(name) {
+			return nil, errors.Errorf("invalid tenant %q, the name must be lower case letters, digits and dashes", name)
+		}
+
+		policy, err := configuredPolicy.toDomain(secret)
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("invalid tenant %q", name))
+		}
+
+		tenants[name] = policy
+	}
+
+	return tenants, nil
+}
+
+// toDomain converts the policy, the sizes can only be lowered for a tenant
+func (p tenantPolicy) toDomain(secret *SecretConfig) (domain.TenantPolicy, error) {
+	policy := domain.TenantPolicy{
+		MaxViews:      p.MaxViews,
+		MaxSecretSize: secret.MaxSecretSize,
+		MaxFileSize:   secret.MaxFileSize,
+	}
+
+	if p.MaxTTL != "" {
+		ttl, err := time.ParseDuration(p.MaxTTL)
+		if err != nil || ttl < time.Minute {
+			return domain.TenantPolicy{}, errors.Errorf("maxTTL %q must be a duration of at least 1m", p.MaxTTL)
+		}
+		policy.MaxTTL = ttl
+	}
+
+	if p.MaxViews < 0 {
+		return domain.TenantPolicy{}, errors.New("maxViews must not be negative")
+	}
+
+	if p.MaxSecretSize < 0 || p.MaxSecretSize > secret.MaxSecretSize {
+		return domain.TenantPolicy{}, errors.Errorf("maxSecretSize must not be negative or larger than %d", secret.MaxSecretSize)
+	}
+	if p.MaxSecretSize > 0 {
+		policy.MaxSecretSize = p.MaxSecretSize
+	}
+
+	if p.MaxFileSize < 0 || p.MaxFileSize > secret.MaxFileSize {
+		return domain.TenantPolicy{}, errors.Errorf("maxFileSize must not be negative or larger than %d", secret.MaxFileSize)
+	}
+	if p.MaxFileSize > 0 {
+		policy.MaxFileSize = p.MaxFileSize
+	}
+
+	if p.Quota != nil {
+		if p.Quota.SecretsPerDay < 0 || p.Quota.MaxBytes < 0 || p.Quota.MaxLiveSecrets < 0 {
+			return domain.TenantPolicy{}, errors.New("the limits of the quota must not be negative")
+		}
+		policy.Quota = domain.QuotaPolicy{
+			SecretsPerDay:  p.Quota.SecretsPerDay,
+			MaxBytes:       p.Quota.MaxBytes,
+			MaxLiveSecrets: p.Quota.MaxLiveSecrets,
+		}
+	}
+
+	return policy, nil
+}
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nalawade41/secret-server/internal/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadTenants(t *testing.T) {
+	secret := &SecretConfig{MaxSecretSize: 1024, MaxFileSize: 4096}
+
+	defer os.Unsetenv("TENANTS")
+
+	// Without tenants only the default routes are served
+	os.Unsetenv("TENANTS")
+	tenants, err := LoadTenants(secret)
+	assert.NoError(t, err)
+	assert.Empty(t, tenants)
+
+	os.Setenv("TENANTS", `{"team-a":{"maxTTL":"24h","maxViews":3,"maxSecretSize":512,"quota":{"secretsPerDay":100,"maxLiveSecrets":10}},"team-b":{}}`)
+	tenants, err = LoadTenants(secret)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]domain.TenantPolicy{
+		"team-a": {MaxTTL: 24 * time.Hour, MaxViews: 3, MaxSecretSize: 512, MaxFileSize: 4096, Quota: domain.QuotaPolicy{SecretsPerDay: 100, MaxLiveSecrets: 10}},
+		"team-b": {MaxSecretSize: 1024, MaxFileSize: 4096},
+	}, tenants)
+}
+
+func TestLoadTenants_File(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "tenants.json")
+	assert.NoError(t, os.WriteFile(file, []byte(`{"team-a":{"maxViews":1}}`), 0o600))
+
+	os.Setenv("TENANTS_FILE", file)
+	defer os.Unsetenv("TENANTS_FILE")
+
+	tenants, err := LoadTenants(&SecretConfig{MaxSecretSize: 1024, MaxFileSize: 4096})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, tenants["team-a"].MaxViews)
+
+	// Both sources at once are ambiguous
+	os.Setenv("TENANTS", `{"team-b":{}}`)
+	defer os.Unsetenv("TENANTS")
+
+	_, err = LoadTenants(&SecretConfig{MaxSecretSize: 1024, MaxFileSize: 4096})
+	assert.Error(t, err)
+}
+
+func TestLoadTenants_Invalid(t *testing.T) {
+	tests := map[string]string{
+		"not an object":      `["team-a"]`,
+		"unknown field":      `{"team-a":{"maxSize":1}}`,
+		"invalid name":       `{"Team#A":{}}`,
+		"invalid ttl":        `{"team-a":{"maxTTL":"a day"}}`,
+		"negative views":     `{"team-a":{"maxViews":-1}}`,
+		"larger secret size": `{"team-a":{"maxSecretSize":2048}}`,
+		"larger file size":   `{"team-a":{"maxFileSize":8192}}`,
+		"negative quota":     `{"team-a":{"quota":{"maxBytes":-1}}}`,
+		"unknown quota":      `{"team-a":{"quota":{"requestsPerMinute":10}}}`,
+	}
+
+	defer os.Unsetenv("TENANTS")
+
+	for name, value := range tests {
+		t.Run(name, func(t *testing.T) {
+			os.Setenv("TENANTS", value)
+
+			_, err := LoadTenants(&SecretConfig{MaxSecretSize: 1024, MaxFileSize: 4096})
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	lConfig "github.com/nalawade41/secret-server/config"
+	"github.com/nalawade41/secret-server/internal/common/logger"
+	"github.com/nalawade41/secret-server/internal/domain"
+	"github.com/nalawade41/secret-server/internal/secret/blobstore"
+	"github.com/pkg/errors"
+)
+
+var (
+	blobStore     domain.BlobStore
+	blobStoreOnce = new(sync.Once)
+)
+
+// InitBlobStore initializes the store of the file secret contents selected in the blob config
+func InitBlobStore(cfg *lConfig.Config) (domain.BlobStore, error) {
+	var initErr error
+
+	blobStoreOnce.Do(func() {
+		switch cfg.Blob.Backend {
+		case lConfig.BlobMemory:
+			blobStore = blobstore.NewMemoryStore()
+		case lConfig.BlobS3:
+			blobStore, initErr = newS3BlobStore(cfg)
+		default:
+			blobStore, initErr = blobstore.NewFileStore(cfg.Blob.Dir)
+		}
+
+		if initErr == nil {
+			logger.Infof("Using %s blob store", cfg.Blob.Backend)
+		}
+	})
+
+	return blobStore, initErr
+}
+
+// newS3BlobStore creates the blob store for the configured bucket. The credentials come from the default
+// chain, for MinIO that is AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY set to its access and secret key.
+func newS3BlobStore(cfg *lConfig.Config) (*blobstore.S3Store, error) {
+	if cfg.Blob.Bucket == "" {
+		return nil, errors.New("BLOB_BUCKET must be set for the s3 blob store")
+	}
+
+	awsConfig, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion(cfg.AWS.Region))
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("failed to load AWS config: %v", err))
+	}
+
+	client := s3.NewFromConfig(awsConfig, func(o *s3.Options) {
+		// S3 compatible stores are usually not set up for virtual hosted buckets
+		if cfg.Blob.S3Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.Blob.S3Endpoint)
+			o.UsePathStyle = true
+		}
+	})
+
+	return &blobstore.S3Store{Client: client, Bucket: cfg.Blob.Bucket}, nil
+}
@@ -16,6 +16,19 @@ import (
 	"github.com/pkg/errors"
 )
 
+const (
+	// ttlAttributeName is the attribute holding the expiry of a secret in epoch seconds
+	ttlAttributeName = "ttl"
+
+	// OwnerIndexName is the global secondary index of the secrets by owner and creation time. Anonymous secrets
+	// have no owner attribute and are left out of it.
+	OwnerIndexName = "owner-createdAt"
+)
+
+// ownerIndexAttributes are the attributes of the secrets copied into the owner index besides the keys,
+// the status of a secret is read from them. The ciphertext and the keys are never copied.
+var ownerIndexAttributes = []string{"expiresAt", "remainingViews", "readAt"}
+
 var (
 	dynamoDBClient DynamoDBAPI
 	dynamoDBOnce   = new(sync.Once)
@@ -60,30 +73,69 @@ func InitDynamoDB(cfg *lConfig.Config) (DynamoDBAPI, error) {
 		// Create DynamoDB client
 		dynamoDBClient = dynamodb.NewFromConfig(awsConfig)
 
-		// Check if the table exists
-		exists, err := doesTableExist(context.TODO(), dynamoDBClient, cfg.Database.TableName)
-		if err != nil {
-			initErr = errors.Wrap(err, fmt.Sprintf("failed to check table existence: %v", err))
+		// Create the tables of the secrets, the API keys and the quota usage if they don't exist
+		if err := EnsureSecretTable(context.TODO(), dynamoDBClient, cfg.Database.TableName); err != nil {
+			initErr = err
 			return
 		}
-
-		// Create the table if it doesn't exist
-		if !exists {
-			err = createTable(context.TODO(), dynamoDBClient, cfg.Database.TableName)
-			if err != nil {
-				initErr = errors.Wrap(err, fmt.Sprintf("failed to create table: %v", err))
-				return
-			} else {
-				logger.Infof("Table %s created successfully\n", cfg.Database.TableName)
-			}
-		} else {
-			logger.Infof("Table %s already exists", cfg.Database.TableName)
+		if err := EnsureAPIKeyTable(context.TODO(), dynamoDBClient, cfg.Database.APIKeyTableName); err != nil {
+			initErr = err
+			return
+		}
+		if err := EnsureQuotaTable(context.TODO(), dynamoDBClient, cfg.Quota.TableName); err != nil {
+			initErr = err
+			return
 		}
 	})
 
 	return dynamoDBClient, initErr
 }
 
+// EnsureSecretTable creates the table of the secrets or brings a table of an older release up to date,
+// with the owner index and time to live
+func EnsureSecretTable(ctx context.Context, svc DynamoDBAPI, tableName string) error {
+	if err := ensureTable(ctx, svc, tableName, createTable); err != nil {
+		return err
+	}
+	if err := ensureOwnerIndex(ctx, svc, tableName); err != nil {
+		return err
+	}
+	return ensureTimeToLive(ctx, svc, tableName)
+}
+
+// EnsureAPIKeyTable creates the table of the API keys unless it exists already
+func EnsureAPIKeyTable(ctx context.Context, svc DynamoDBAPI, tableName string) error {
+	return ensureTable(ctx, svc, tableName, createAPIKeyTable)
+}
+
+// EnsureQuotaTable creates the table of the quota usage or enables time to live on a table of an older release
+func EnsureQuotaTable(ctx context.Context, svc DynamoDBAPI, tableName string) error {
+	if err := ensureTable(ctx, svc, tableName, createQuotaTable); err != nil {
+		return err
+	}
+	return ensureTimeToLive(ctx, svc, tableName)
+}
+
+// ensureTable creates the table with create unless it exists already
+func ensureTable(ctx context.Context, svc DynamoDBAPI, tableName string, create func(context.Context, DynamoDBAPI, string) error) error {
+	exists, err := doesTableExist(ctx, svc, tableName)
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("failed to check table existence: %v", err))
+	}
+
+	if exists {
+		logger.Infof("Table %s already exists", tableName)
+		return nil
+	}
+
+	if err := create(ctx, svc, tableName); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("failed to create table: %v", err))
+	}
+
+	logger.Infof("Table %s created successfully\n", tableName)
+	return nil
+}
+
 // doesTableExist checks if a DynamoDB table exists
 func doesTableExist(ctx context.Context, svc DynamoDBAPI, tableName string) (bool, error) {
 	// Use DescribeTable to check if the table exists
@@ -97,41 +149,243 @@ func doesTableExist(ctx context.Context, svc DynamoDBAPI, tableName string) (boo
 		if ok := errors.As(err, &notFoundErr); ok {
 			return false, nil // Table does not exist
 		}
-		return false, errors.Wrap(err, fmt.Sprintf("failed to describe table: %w", err))
+		return false, errors.Wrap(err, fmt.Sprintf("failed to describe table: %v", err))
 	}
 
 	return true, nil // Table exists
 }
 
-// createTable creates a new DynamoDB table
+// createTable creates a new DynamoDB table with the owner index
 func createTable(ctx context.Context, svc DynamoDBAPI, tableName string) error {
 	_, err := svc.CreateTable(ctx, &dynamodb.CreateTableInput{
 		TableName: aws.String(tableName),
-		AttributeDefinitions: []types.AttributeDefinition{
+		AttributeDefinitions: append([]types.AttributeDefinition{
 			{
 				AttributeName: aws.String("hash"),
 				AttributeType: types.ScalarAttributeTypeS,
 			},
-		},
+		}, ownerIndexAttributeDefinitions()...),
 		KeySchema: []types.KeySchemaElement{
 			{
 				AttributeName: aws.String("hash"),
 				KeyType:       types.KeyTypeHash,
 			},
 		},
-		ProvisionedThroughput: &types.ProvisionedThroughput{
-			ReadCapacityUnits:  aws.Int64(5),
-			WriteCapacityUnits: aws.Int64(5),
+		GlobalSecondaryIndexes: []types.GlobalSecondaryIndex{
+			ownerIndex(provisionedThroughput()),
 		},
+		ProvisionedThroughput: provisionedThroughput(),
 	})
 	if err != nil {
 		return errors.Wrap(err, fmt.Sprintf("failed to create table %s: %v", tableName, err))
 	}
 
 	// Wait for the table to become active
+	if err := waitForTableToBeActive(ctx, svc, tableName); err != nil {
+		return err
+	}
+
+	return enableTimeToLive(ctx, svc, tableName)
+}
+
+// provisionedThroughput is the capacity of the tables and indexes created by the server
+func provisionedThroughput() *types.ProvisionedThroughput {
+	return &types.ProvisionedThroughput{
+		ReadCapacityUnits:  aws.Int64(5),
+		WriteCapacityUnits: aws.Int64(5),
+	}
+}
+
+// ownerIndexAttributeDefinitions defines the key attributes of the owner index, createdAt is stored as RFC 3339 text
+func ownerIndexAttributeDefinitions() []types.AttributeDefinition {
+	return []types.AttributeDefinition{
+		{
+			AttributeName: aws.String("owner"),
+			AttributeType: types.ScalarAttributeTypeS,
+		},
+		{
+			AttributeName: aws.String("createdAt"),
+			AttributeType: types.ScalarAttributeTypeS,
+		},
+	}
+}
+
+// ownerIndex returns the owner index, throughput is nil for tables billed per request
+func ownerIndex(throughput *types.ProvisionedThroughput) types.GlobalSecondaryIndex {
+	return types.GlobalSecondaryIndex{
+		IndexName: aws.String(OwnerIndexName),
+		KeySchema: []types.KeySchemaElement{
+			{
+				AttributeName: aws.String("owner"),
+				KeyType:       types.KeyTypeHash,
+			},
+			{
+				AttributeName: aws.String("createdAt"),
+				KeyType:       types.KeyTypeRange,
+			},
+		},
+		Projection: &types.Projection{
+			ProjectionType:   types.ProjectionTypeInclude,
+			NonKeyAttributes: ownerIndexAttributes,
+		},
+		ProvisionedThroughput: throughput,
+	}
+}
+
+// ensureOwnerIndex adds the owner index to a table created by an older release. DynamoDB fills the index in the
+// background, until then listing the secrets of an owner fails.
+func ensureOwnerIndex(ctx context.Context, svc DynamoDBAPI, tableName string) error {
+	desc, err := svc.DescribeTable(ctx, &dynamodb.DescribeTableInput{
+		TableName: aws.String(tableName),
+	})
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("failed to describe table: %v", err))
+	}
+
+	for _, index := range desc.Table.GlobalSecondaryIndexes {
+		if aws.ToString(index.IndexName) == OwnerIndexName {
+			return nil
+		}
+	}
+
+	// An index of a table billed per request must not have a throughput of its own
+	throughput := provisionedThroughput()
+	if desc.Table.BillingModeSummary != nil && desc.Table.BillingModeSummary.BillingMode == types.BillingModePayPerRequest {
+		throughput = nil
+	}
+
+	index := ownerIndex(throughput)
+	_, err = svc.UpdateTable(ctx, &dynamodb.UpdateTableInput{
+		TableName:            aws.String(tableName),
+		AttributeDefinitions: ownerIndexAttributeDefinitions(),
+		GlobalSecondaryIndexUpdates: []types.GlobalSecondaryIndexUpdate{
+			{
+				Create: &types.CreateGlobalSecondaryIndexAction{
+					IndexName:             index.IndexName,
+					KeySchema:             index.KeySchema,
+					Projection:            index.Projection,
+					ProvisionedThroughput: index.ProvisionedThroughput,
+				},
+			},
+		},
+	})
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("failed to add index %s to table %s: %v", OwnerIndexName, tableName, err))
+	}
+
+	logger.Infof("Adding index %s to table %s, it is filled in the background", OwnerIndexName, tableName)
+
+	return nil
+}
+
+// createAPIKeyTable creates the table of the API keys, keys are looked up by their id
+func createAPIKeyTable(ctx context.Context, svc DynamoDBAPI, tableName string) error {
+	_, err := svc.CreateTable(ctx, &dynamodb.CreateTableInput{
+		TableName: aws.String(tableName),
+		AttributeDefinitions: []types.AttributeDefinition{
+			{
+				AttributeName: aws.String("id"),
+				AttributeType: types.ScalarAttributeTypeS,
+			},
+		},
+		KeySchema: []types.KeySchemaElement{
+			{
+				AttributeName: aws.String("id"),
+				KeyType:       types.KeyTypeHash,
+			},
+		},
+		ProvisionedThroughput: provisionedThroughput(),
+	})
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("failed to create table %s: %v", tableName, err))
+	}
+
 	return waitForTableToBeActive(ctx, svc, tableName)
 }
 
+// createQuotaTable creates the table of the quota usage, the usage is looked up by its subject. DynamoDB deletes
+// the usage of a subject once its day and all of its buckets are over.
+func createQuotaTable(ctx context.Context, svc DynamoDBAPI, tableName string) error {
+	_, err := svc.CreateTable(ctx, &dynamodb.CreateTableInput{
+		TableName: aws.String(tableName),
+		AttributeDefinitions: []types.AttributeDefinition{
+			{
+				AttributeName: aws.String("subject"),
+				AttributeType: types.ScalarAttributeTypeS,
+			},
+		},
+		KeySchema: []types.KeySchemaElement{
+			{
+				AttributeName: aws.String("subject"),
+				KeyType:       types.KeyTypeHash,
+			},
+		},
+		ProvisionedThroughput: provisionedThroughput(),
+	})
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("failed to create table %s: %v", tableName, err))
+	}
+
+	if err := waitForTableToBeActive(ctx, svc, tableName); err != nil {
+		return err
+	}
+
+	return enableTimeToLive(ctx, svc, tableName)
+}
+
+// enableTimeToLive lets DynamoDB delete expired items on its own, the table has to be active
+func enableTimeToLive(ctx context.Context, svc DynamoDBAPI, tableName string) error {
+	_, err := svc.UpdateTimeToLive(ctx, &dynamodb.UpdateTimeToLiveInput{
+		TableName: aws.String(tableName),
+		TimeToLiveSpecification: &types.TimeToLiveSpecification{
+			AttributeName: aws.String(ttlAttributeName),
+			Enabled:       aws.Bool(true),
+		},
+	})
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("failed to enable time to live on table %s: %v", tableName, err))
+	}
+
+	return nil
+}
+
+// ensureTimeToLive enables time to live on a table created by an older release. A table that has it on another
+// attribute or is still disabling it is left as it is, DynamoDB refuses to change it then.
+func ensureTimeToLive(ctx context.Context, svc DynamoDBAPI, tableName string) error {
+	desc, err := svc.DescribeTimeToLive(ctx, &dynamodb.DescribeTimeToLiveInput{
+		TableName: aws.String(tableName),
+	})
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("failed to describe time to live of table %s: %v", tableName, err))
+	}
+
+	ttl := desc.TimeToLiveDescription
+	status := types.TimeToLiveStatusDisabled
+	if ttl != nil {
+		status = ttl.TimeToLiveStatus
+	}
+
+	switch status {
+	case types.TimeToLiveStatusEnabled, types.TimeToLiveStatusEnabling:
+		if attribute := aws.ToString(ttl.AttributeName); attribute != ttlAttributeName {
+			logger.Warnf("Table %s expires items by attribute %s instead of %s, expired items are not deleted",
+				tableName, attribute, ttlAttributeName)
+		}
+		return nil
+	case types.TimeToLiveStatusDisabling:
+		logger.Warnf("Table %s is disabling time to live, it is enabled on the next start", tableName)
+		return nil
+	}
+
+	if err := enableTimeToLive(ctx, svc, tableName); err != nil {
+		return err
+	}
+
+	logger.Infof("Enabled time to live on table %s", tableName)
+
+	return nil
+}
+
 // waitForTableToBeActive waits for a DynamoDB table to become active
 func waitForTableToBeActive(ctx context.Context, svc DynamoDBAPI, tableName string) error {
 	waitTime := 5 * time.Second
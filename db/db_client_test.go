@@ -79,9 +79,33 @@ func TestCreateTable(t *testing.T) {
 			},
 		}, nil).AnyTimes()
 
+	// Expired secrets are deleted by DynamoDB through the ttl attribute
+	mockDynamoClient.EXPECT().
+		UpdateTimeToLive(gomock.Any(), &dynamodb.UpdateTimeToLiveInput{
+			TableName: aws.String(tableName),
+			TimeToLiveSpecification: &types.TimeToLiveSpecification{
+				AttributeName: aws.String("ttl"),
+				Enabled:       aws.Bool(true),
+			},
+		}).
+		Return(&dynamodb.UpdateTimeToLiveOutput{}, nil)
+
 	err := createTable(context.TODO(), mockDynamoClient, tableName)
 	assert.NoError(t, err)
 
+	// Test case: UpdateTimeToLive error
+	mockDynamoClient.EXPECT().
+		CreateTable(gomock.Any(), gomock.Any()).
+		Return(&dynamodb.CreateTableOutput{}, nil)
+
+	mockDynamoClient.EXPECT().
+		UpdateTimeToLive(gomock.Any(), gomock.Any()).
+		Return(nil, errors.New("update time to live error"))
+
+	err = createTable(context.TODO(), mockDynamoClient, tableName)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to enable time to live")
+
 	// Test case: CreateTable error
 	mockDynamoClient.EXPECT().
 		CreateTable(gomock.Any(), gomock.Any()).
@@ -91,3 +115,242 @@ func TestCreateTable(t *testing.T) {
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "failed to create table")
 }
+
+func TestEnsureTable(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDynamoClient := mocks.NewMockDynamoDBAPI(ctrl)
+
+	tableName := "api_keys"
+
+	// Test case: The table exists, nothing is created
+	mockDynamoClient.EXPECT().
+		DescribeTable(gomock.Any(), &dynamodb.DescribeTableInput{TableName: aws.String(tableName)}).
+		Return(&dynamodb.DescribeTableOutput{Table: &types.TableDescription{TableStatus: types.TableStatusActive}}, nil)
+
+	err := ensureTable(context.TODO(), mockDynamoClient, tableName, createAPIKeyTable)
+	assert.NoError(t, err)
+
+	// Test case: The table is missing and created with the id as its key
+	mockDynamoClient.EXPECT().
+		DescribeTable(gomock.Any(), &dynamodb.DescribeTableInput{TableName: aws.String(tableName)}).
+		Return(nil, &types.ResourceNotFoundException{})
+
+	mockDynamoClient.EXPECT().
+		CreateTable(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, input *dynamodb.CreateTableInput, _ ...func(*dynamodb.Options)) (*dynamodb.CreateTableOutput, error) {
+			assert.Equal(t, tableName, aws.ToString(input.TableName))
+			assert.Equal(t, "id", aws.ToString(input.KeySchema[0].AttributeName))
+			return &dynamodb.CreateTableOutput{}, nil
+		})
+
+	mockDynamoClient.EXPECT().
+		DescribeTable(gomock.Any(), &dynamodb.DescribeTableInput{TableName: aws.String(tableName)}).
+		Return(&dynamodb.DescribeTableOutput{Table: &types.TableDescription{TableStatus: types.TableStatusActive}}, nil)
+
+	err = ensureTable(context.TODO(), mockDynamoClient, tableName, createAPIKeyTable)
+	assert.NoError(t, err)
+
+	// Test case: CreateTable error
+	mockDynamoClient.EXPECT().
+		DescribeTable(gomock.Any(), gomock.Any()).
+		Return(nil, &types.ResourceNotFoundException{})
+
+	mockDynamoClient.EXPECT().
+		CreateTable(gomock.Any(), gomock.Any()).
+		Return(nil, errors.New("create table error"))
+
+	err = ensureTable(context.TODO(), mockDynamoClient, tableName, createAPIKeyTable)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to create table")
+}
+
+func TestCreateQuotaTable(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDynamoClient := mocks.NewMockDynamoDBAPI(ctrl)
+
+	tableName := "quotas"
+
+	// The usage is keyed by its subject and dropped by DynamoDB through the ttl attribute
+	mockDynamoClient.EXPECT().
+		CreateTable(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, input *dynamodb.CreateTableInput, _ ...func(*dynamodb.Options)) (*dynamodb.CreateTableOutput, error) {
+			assert.Equal(t, tableName, aws.ToString(input.TableName))
+			assert.Equal(t, "subject", aws.ToString(input.KeySchema[0].AttributeName))
+			return &dynamodb.CreateTableOutput{}, nil
+		})
+
+	mockDynamoClient.EXPECT().
+		DescribeTable(gomock.Any(), &dynamodb.DescribeTableInput{TableName: aws.String(tableName)}).
+		Return(&dynamodb.DescribeTableOutput{Table: &types.TableDescription{TableStatus: types.TableStatusActive}}, nil)
+
+	mockDynamoClient.EXPECT().
+		UpdateTimeToLive(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, input *dynamodb.UpdateTimeToLiveInput, _ ...func(*dynamodb.Options)) (*dynamodb.UpdateTimeToLiveOutput, error) {
+			assert.Equal(t, "ttl", aws.ToString(input.TimeToLiveSpecification.AttributeName))
+			return &dynamodb.UpdateTimeToLiveOutput{}, nil
+		})
+
+	err := createQuotaTable(context.TODO(), mockDynamoClient, tableName)
+	assert.NoError(t, err)
+
+	// Test case: CreateTable error
+	mockDynamoClient.EXPECT().
+		CreateTable(gomock.Any(), gomock.Any()).
+		Return(nil, errors.New("create table error"))
+
+	err = createQuotaTable(context.TODO(), mockDynamoClient, tableName)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to create table")
+}
+
+func TestEnsureOwnerIndex(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDynamoClient := mocks.NewMockDynamoDBAPI(ctrl)
+
+	tableName := "secrets"
+
+	// Test case: The index exists, the table is left as it is
+	mockDynamoClient.EXPECT().
+		DescribeTable(gomock.Any(), &dynamodb.DescribeTableInput{TableName: aws.String(tableName)}).
+		Return(&dynamodb.DescribeTableOutput{Table: &types.TableDescription{
+			GlobalSecondaryIndexes: []types.GlobalSecondaryIndexDescription{{IndexName: aws.String(OwnerIndexName)}},
+		}}, nil)
+
+	err := ensureOwnerIndex(context.TODO(), mockDynamoClient, tableName)
+	assert.NoError(t, err)
+
+	// Test case: A table of an older release billed per request gets the index without a throughput
+	mockDynamoClient.EXPECT().
+		DescribeTable(gomock.Any(), &dynamodb.DescribeTableInput{TableName: aws.String(tableName)}).
+		Return(&dynamodb.DescribeTableOutput{Table: &types.TableDescription{
+			BillingModeSummary: &types.BillingModeSummary{BillingMode: types.BillingModePayPerRequest},
+		}}, nil)
+
+	mockDynamoClient.EXPECT().
+		UpdateTable(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, input *dynamodb.UpdateTableInput, _ ...func(*dynamodb.Options)) (*dynamodb.UpdateTableOutput, error) {
+			assert.Equal(t, tableName, aws.ToString(input.TableName))
+			if assert.Len(t, input.GlobalSecondaryIndexUpdates, 1) {
+				create := input.GlobalSecondaryIndexUpdates[0].Create
+				assert.Equal(t, OwnerIndexName, aws.ToString(create.IndexName))
+				assert.Equal(t, "owner", aws.ToString(create.KeySchema[0].AttributeName))
+				assert.Equal(t, "createdAt", aws.ToString(create.KeySchema[1].AttributeName))
+				assert.Nil(t, create.ProvisionedThroughput)
+			}
+			return &dynamodb.UpdateTableOutput{}, nil
+		})
+
+	err = ensureOwnerIndex(context.TODO(), mockDynamoClient, tableName)
+	assert.NoError(t, err)
+
+	// Test case: UpdateTable error
+	mockDynamoClient.EXPECT().
+		DescribeTable(gomock.Any(), gomock.Any()).
+		Return(&dynamodb.DescribeTableOutput{Table: &types.TableDescription{}}, nil)
+
+	mockDynamoClient.EXPECT().
+		UpdateTable(gomock.Any(), gomock.Any()).
+		Return(nil, errors.New("update table error"))
+
+	err = ensureOwnerIndex(context.TODO(), mockDynamoClient, tableName)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to add index")
+}
+
+func TestEnsureTimeToLive(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDynamoClient := mocks.NewMockDynamoDBAPI(ctrl)
+
+	tableName := "secrets"
+
+	// Test case: Time to live is enabled, the table is left as it is
+	mockDynamoClient.EXPECT().
+		DescribeTimeToLive(gomock.Any(), &dynamodb.DescribeTimeToLiveInput{TableName: aws.String(tableName)}).
+		Return(&dynamodb.DescribeTimeToLiveOutput{TimeToLiveDescription: &types.TimeToLiveDescription{
+			AttributeName:    aws.String(ttlAttributeName),
+			TimeToLiveStatus: types.TimeToLiveStatusEnabled,
+		}}, nil)
+
+	err := ensureTimeToLive(context.TODO(), mockDynamoClient, tableName)
+	assert.NoError(t, err)
+
+	// Test case: A table still disabling time to live can not be updated
+	mockDynamoClient.EXPECT().
+		DescribeTimeToLive(gomock.Any(), gomock.Any()).
+		Return(&dynamodb.DescribeTimeToLiveOutput{TimeToLiveDescription: &types.TimeToLiveDescription{
+			TimeToLiveStatus: types.TimeToLiveStatusDisabling,
+		}}, nil)
+
+	err = ensureTimeToLive(context.TODO(), mockDynamoClient, tableName)
+	assert.NoError(t, err)
+
+	// Test case: A table of an older release gets time to live on the ttl attribute
+	mockDynamoClient.EXPECT().
+		DescribeTimeToLive(gomock.Any(), gomock.Any()).
+		Return(&dynamodb.DescribeTimeToLiveOutput{TimeToLiveDescription: &types.TimeToLiveDescription{
+			TimeToLiveStatus: types.TimeToLiveStatusDisabled,
+		}}, nil)
+
+	mockDynamoClient.EXPECT().
+		UpdateTimeToLive(gomock.Any(), &dynamodb.UpdateTimeToLiveInput{
+			TableName: aws.String(tableName),
+			TimeToLiveSpecification: &types.TimeToLiveSpecification{
+				AttributeName: aws.String(ttlAttributeName),
+				Enabled:       aws.Bool(true),
+			},
+		}).
+		Return(&dynamodb.UpdateTimeToLiveOutput{}, nil)
+
+	err = ensureTimeToLive(context.TODO(), mockDynamoClient, tableName)
+	assert.NoError(t, err)
+
+	// Test case: UpdateTimeToLive error
+	mockDynamoClient.EXPECT().
+		DescribeTimeToLive(gomock.Any(), gomock.Any()).
+		Return(&dynamodb.DescribeTimeToLiveOutput{}, nil)
+
+	mockDynamoClient.EXPECT().
+		UpdateTimeToLive(gomock.Any(), gomock.Any()).
+		Return(nil, errors.New("update time to live error"))
+
+	err = ensureTimeToLive(context.TODO(), mockDynamoClient, tableName)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to enable time to live")
+
+	// Test case: DescribeTimeToLive error
+	mockDynamoClient.EXPECT().
+		DescribeTimeToLive(gomock.Any(), gomock.Any()).
+		Return(nil, errors.New("describe time to live error"))
+
+	err = ensureTimeToLive(context.TODO(), mockDynamoClient, tableName)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to describe time to live")
+}
+
+func TestEnsureSecretTable(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDynamoClient := mocks.NewMockDynamoDBAPI(ctrl)
+
+	tableName := "secrets"
+
+	// An existing table of an older release gets the owner index and time to live
+	gomock.InOrder(
+		mockDynamoClient.EXPECT().DescribeTable(gomock.Any(), gomock.Any()).Return(&dynamodb.DescribeTableOutput{Table: &types.TableDescription{}}, nil),
+		mockDynamoClient.EXPECT().DescribeTable(gomock.Any(), gomock.Any()).Return(&dynamodb.DescribeTableOutput{Table: &types.TableDescription{}}, nil),
+		mockDynamoClient.EXPECT().UpdateTable(gomock.Any(), gomock.Any()).Return(&dynamodb.UpdateTableOutput{}, nil),
+		mockDynamoClient.EXPECT().DescribeTimeToLive(gomock.Any(), gomock.Any()).Return(&dynamodb.DescribeTimeToLiveOutput{}, nil),
+		mockDynamoClient.EXPECT().UpdateTimeToLive(gomock.Any(), gomock.Any()).Return(&dynamodb.UpdateTimeToLiveOutput{}, nil),
+	)
+
+	assert.NoError(t, EnsureSecretTable(context.TODO(), mockDynamoClient, tableName))
+}
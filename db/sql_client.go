@@ -0,0 +1,257 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"sync"
+
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+	lConfig "github.com/nalawade41/secret-server/config"
+	"github.com/nalawade41/secret-server/internal/common/logger"
+	"github.com/pkg/errors"
+)
+
+// defaultSQLTableName is used when no table name is configured, DynamoDB has no such default
+const defaultSQLTableName = "secrets"
+
+var (
+	sqlClient *sql.DB
+	sqlOnce   = new(sync.Once)
+
+	// tableNamePattern keeps the table name, which is not a query parameter, a plain identifier
+	tableNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// InitSQL opens the SQLite or PostgreSQL database of the storage config and creates the tables of the secrets,
+// the API keys and the quota usage
+func InitSQL(cfg *lConfig.Config) (*sql.DB, error) {
+	var initErr error
+
+	sqlOnce.Do(func() {
+		db, err := OpenSQL(cfg.Storage)
+		if err != nil {
+			initErr = err
+			return
+		}
+
+		if err := CreateSQLTable(context.TODO(), db, cfg.Storage.Backend, SQLTableName(cfg.Database.TableName)); err != nil {
+			db.Close()
+			initErr = err
+			return
+		}
+
+		if err := CreateSQLAPIKeyTable(context.TODO(), db, cfg.Storage.Backend, cfg.Database.APIKeyTableName); err != nil {
+			db.Close()
+			initErr = err
+			return
+		}
+
+		if err := CreateSQLQuotaTable(context.TODO(), db, cfg.Quota.TableName); err != nil {
+			db.Close()
+			initErr = err
+			return
+		}
+
+		sqlClient = db
+	})
+
+	return sqlClient, initErr
+}
+
+// OpenSQL opens the database of the SQL storage backend and checks the connection
+func OpenSQL(cfg *lConfig.StorageConfig) (*sql.DB, error) {
+	var (
+		db  *sql.DB
+		err error
+	)
+
+	switch cfg.Backend {
+	case lConfig.StorageSQLite:
+		// A locked database is waited for instead of failing the write right away
+		db, err = sql.Open("sqlite3", fmt.Sprintf("file:%s?_busy_timeout=5000&_journal_mode=WAL", cfg.SQLitePath))
+		if err == nil {
+			// SQLite allows a single writer, one connection serializes the transactions instead of failing them.
+			// It also keeps an in memory database from being opened once per connection.
+			db.SetMaxOpenConns(1)
+		}
+	case lConfig.StoragePostgres:
+		if cfg.PostgresURL == "" {
+			return nil, errors.New("POSTGRES_URL must be set for the postgres storage backend")
+		}
+		db, err = sql.Open("postgres", cfg.PostgresURL)
+	default:
+		return nil, errors.Errorf("storage backend %s is not an SQL database", cfg.Backend)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("failed to open %s database: %v", cfg.Backend, err))
+	}
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, errors.Wrap(err, fmt.Sprintf("failed to connect to %s database: %v", cfg.Backend, err))
+	}
+
+	return db, nil
+}
+
+// SQLTableName returns the configured table name or the default one
+func SQLTableName(tableName string) string {
+	if tableName == "" {
+		return defaultSQLTableName
+	}
+	return tableName
+}
+
+// CreateSQLTable creates the secrets table if it does not exist yet. The columns mirror the attributes of the
+// DynamoDB items, read times are stored as a JSON array and the ttl is kept in epoch seconds, 0 never expires.
+func CreateSQLTable(ctx context.Context, db *sql.DB, backend string, tableName string) error {
+	if !tableNamePattern.MatchString(tableName) {
+		return errors.Errorf("invalid table name %q", tableName)
+	}
+
+	timestamp := "TIMESTAMP"
+	if backend == lConfig.StoragePostgres {
+		timestamp = "TIMESTAMPTZ"
+	}
+
+	_, err := db.ExecContext(ctx, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	hash                  TEXT PRIMARY KEY,
+	revocation_token_hash TEXT NOT NULL DEFAULT '',
+	secret_text           TEXT NOT NULL DEFAULT '',
+	wrapped_key           TEXT NOT NULL DEFAULT '',
+	key_id                TEXT NOT NULL DEFAULT '',
+	passphrase_kdf        TEXT NOT NULL DEFAULT '',
+	failed_attempts       INTEGER NOT NULL DEFAULT 0,
+	client_encrypted      BOOLEAN NOT NULL DEFAULT FALSE,
+	algorithm             TEXT NOT NULL DEFAULT '',
+	created_at            %[2]s NOT NULL,
+	expires_at            %[2]s NOT NULL,
+	ttl                   BIGINT NOT NULL DEFAULT 0,
+	remaining_views       INTEGER NOT NULL,
+	read_at               TEXT NOT NULL DEFAULT '[]',
+	blob_key              TEXT NOT NULL DEFAULT '',
+	file_name             TEXT NOT NULL DEFAULT '',
+	file_content_type     TEXT NOT NULL DEFAULT '',
+	owner                 TEXT NOT NULL DEFAULT '',
+	quota_bytes           BIGINT NOT NULL DEFAULT 0
+)`, tableName, timestamp))
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("failed to create table %s: %v", tableName, err))
+	}
+
+	if err := addSQLColumns(ctx, db, tableName, addedSQLColumns); err != nil {
+		return err
+	}
+
+	// The secrets of an owner are listed newest first
+	_, err = db.ExecContext(ctx, fmt.Sprintf("CREATE INDEX IF NOT EXISTS %[1]s_owner_created_at ON %[1]s (owner, created_at)", tableName))
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("failed to create owner index of table %s: %v", tableName, err))
+	}
+
+	logger.Infof("Table %s is ready", tableName)
+
+	return nil
+}
+
+// CreateSQLAPIKeyTable creates the API keys table if it does not exist yet, scopes are stored as a JSON array
+// and revoked_at is NULL until the key is revoked
+func CreateSQLAPIKeyTable(ctx context.Context, db *sql.DB, backend string, tableName string) error {
+	if !tableNamePattern.MatchString(tableName) {
+		return errors.Errorf("invalid table name %q", tableName)
+	}
+
+	timestamp := "TIMESTAMP"
+	if backend == lConfig.StoragePostgres {
+		timestamp = "TIMESTAMPTZ"
+	}
+
+	_, err := db.ExecContext(ctx, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	id         TEXT PRIMARY KEY,
+	name       TEXT NOT NULL,
+	key_hash   TEXT NOT NULL,
+	scopes     TEXT NOT NULL DEFAULT '[]',
+	created_at %[2]s NOT NULL,
+	revoked_at %[2]s,
+	tenant     TEXT NOT NULL DEFAULT ''
+)`, tableName, timestamp))
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("failed to create table %s: %v", tableName, err))
+	}
+
+	if err := addSQLColumns(ctx, db, tableName, addedSQLAPIKeyColumns); err != nil {
+		return err
+	}
+
+	logger.Infof("Table %s is ready", tableName)
+
+	return nil
+}
+
+// CreateSQLQuotaTable creates the quota usage table if it does not exist yet, the buckets of the live secrets
+// are stored as a JSON array
+func CreateSQLQuotaTable(ctx context.Context, db *sql.DB, tableName string) error {
+	if !tableNamePattern.MatchString(tableName) {
+		return errors.Errorf("invalid table name %q", tableName)
+	}
+
+	_, err := db.ExecContext(ctx, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	subject TEXT PRIMARY KEY,
+	day     TEXT NOT NULL DEFAULT '',
+	created INTEGER NOT NULL DEFAULT 0,
+	buckets TEXT NOT NULL DEFAULT '[]',
+	version BIGINT NOT NULL
+)`, tableName))
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("failed to create table %s: %v", tableName, err))
+	}
+
+	logger.Infof("Table %s is ready", tableName)
+
+	return nil
+}
+
+// sqlColumn is a column added after its table was first released, with its definition
+type sqlColumn struct {
+	name       string
+	definition string
+}
+
+var (
+	// addedSQLColumns are the columns added to the secrets table
+	addedSQLColumns = []sqlColumn{
+		{"blob_key", "TEXT NOT NULL DEFAULT ''"},
+		{"file_name", "TEXT NOT NULL DEFAULT ''"},
+		{"file_content_type", "TEXT NOT NULL DEFAULT ''"},
+		{"owner", "TEXT NOT NULL DEFAULT ''"},
+		{"quota_bytes", "BIGINT NOT NULL DEFAULT 0"},
+	}
+
+	// addedSQLAPIKeyColumns are the columns added to the API keys table, keys without a tenant are of the default tenant
+	addedSQLAPIKeyColumns = []sqlColumn{
+		{"tenant", "TEXT NOT NULL DEFAULT ''"},
+	}
+)
+
+// addSQLColumns adds the columns a table created by an older release is missing. Selecting a column
+// works the same in SQLite and PostgreSQL, unlike ADD COLUMN IF NOT EXISTS.
+func addSQLColumns(ctx context.Context, db *sql.DB, tableName string, columns []sqlColumn) error {
+	for _, column := range columns {
+		rows, err := db.QueryContext(ctx, fmt.Sprintf("SELECT %s FROM %s LIMIT 0", column.name, tableName))
+		if err == nil {
+			rows.Close()
+			continue
+		}
+
+		if _, err := db.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", tableName, column.name, column.definition)); err != nil {
+			return errors.Wrap(err, fmt.Sprintf("failed to add column %s to table %s: %v", column.name, tableName, err))
+		}
+
+		logger.Infof("Added column %s to table %s", column.name, tableName)
+	}
+
+	return nil
+}
@@ -0,0 +1,139 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	lConfig "github.com/nalawade41/secret-server/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenSQL(t *testing.T) {
+	// Test case: SQLite
+	conn, err := OpenSQL(&lConfig.StorageConfig{Backend: lConfig.StorageSQLite, SQLitePath: ":memory:"})
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	// Test case: PostgreSQL without connection url
+	_, err = OpenSQL(&lConfig.StorageConfig{Backend: lConfig.StoragePostgres})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "POSTGRES_URL must be set")
+
+	// Test case: Not an SQL backend
+	_, err = OpenSQL(&lConfig.StorageConfig{Backend: lConfig.StorageDynamoDB})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "is not an SQL database")
+}
+
+func TestCreateSQLTable(t *testing.T) {
+	conn, err := OpenSQL(&lConfig.StorageConfig{Backend: lConfig.StorageSQLite, SQLitePath: ":memory:"})
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	err = CreateSQLTable(context.TODO(), conn, lConfig.StorageSQLite, "secrets")
+	assert.NoError(t, err)
+
+	// Test case: The table already exists
+	err = CreateSQLTable(context.TODO(), conn, lConfig.StorageSQLite, "secrets")
+	assert.NoError(t, err)
+
+	// Test case: The table name is not a plain identifier
+	err = CreateSQLTable(context.TODO(), conn, lConfig.StorageSQLite, "secrets; DROP TABLE secrets")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid table name")
+}
+
+func TestCreateSQLTable_AddsColumns(t *testing.T) {
+	conn, err := OpenSQL(&lConfig.StorageConfig{Backend: lConfig.StorageSQLite, SQLitePath: ":memory:"})
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	// A table created before file secrets has none of their columns, nor the owner of the secrets
+	_, err = conn.Exec(`CREATE TABLE secrets (hash TEXT PRIMARY KEY, secret_text TEXT NOT NULL DEFAULT '', created_at TIMESTAMP NOT NULL)`)
+	assert.NoError(t, err)
+
+	err = CreateSQLTable(context.TODO(), conn, lConfig.StorageSQLite, "secrets")
+	assert.NoError(t, err)
+
+	_, err = conn.Exec(`SELECT blob_key, file_name, file_content_type, owner, quota_bytes FROM secrets`)
+	assert.NoError(t, err)
+}
+
+func TestCreateSQLAPIKeyTable(t *testing.T) {
+	conn, err := OpenSQL(&lConfig.StorageConfig{Backend: lConfig.StorageSQLite, SQLitePath: ":memory:"})
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	err = CreateSQLAPIKeyTable(context.TODO(), conn, lConfig.StorageSQLite, "api_keys")
+	assert.NoError(t, err)
+
+	// Test case: The table already exists
+	err = CreateSQLAPIKeyTable(context.TODO(), conn, lConfig.StorageSQLite, "api_keys")
+	assert.NoError(t, err)
+
+	_, err = conn.Exec(`SELECT id, name, key_hash, scopes, created_at, revoked_at, tenant FROM api_keys`)
+	assert.NoError(t, err)
+
+	// Test case: The table name is not a plain identifier
+	err = CreateSQLAPIKeyTable(context.TODO(), conn, lConfig.StorageSQLite, "api-keys")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid table name")
+}
+
+func TestCreateSQLAPIKeyTable_AddsColumns(t *testing.T) {
+	conn, err := OpenSQL(&lConfig.StorageConfig{Backend: lConfig.StorageSQLite, SQLitePath: ":memory:"})
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	// A table created before tenants has no tenant of the keys
+	_, err = conn.Exec(`CREATE TABLE api_keys (id TEXT PRIMARY KEY, name TEXT NOT NULL, key_hash TEXT NOT NULL, scopes TEXT NOT NULL DEFAULT '[]', created_at TIMESTAMP NOT NULL, revoked_at TIMESTAMP)`)
+	assert.NoError(t, err)
+
+	err = CreateSQLAPIKeyTable(context.TODO(), conn, lConfig.StorageSQLite, "api_keys")
+	assert.NoError(t, err)
+
+	_, err = conn.Exec(`SELECT tenant FROM api_keys`)
+	assert.NoError(t, err)
+}
+
+func TestCreateSQLQuotaTable(t *testing.T) {
+	conn, err := OpenSQL(&lConfig.StorageConfig{Backend: lConfig.StorageSQLite, SQLitePath: ":memory:"})
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	err = CreateSQLQuotaTable(context.TODO(), conn, "quotas")
+	assert.NoError(t, err)
+
+	// Test case: The table already exists
+	err = CreateSQLQuotaTable(context.TODO(), conn, "quotas")
+	assert.NoError(t, err)
+
+	_, err = conn.Exec(`SELECT subject, day, created, buckets, version FROM quotas`)
+	assert.NoError(t, err)
+
+	// Test case: The table name is not a plain identifier
+	err = CreateSQLQuotaTable(context.TODO(), conn, "quota usage")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid table name")
+}
+
+func TestSQLTableName(t *testing.T) {
+	assert.Equal(t, "secrets", SQLTableName(""))
+	assert.Equal(t, "Secrets", SQLTableName("Secrets"))
+}
+
+func TestInitStorage_Memory(t *testing.T) {
+	cfg := &lConfig.Config{
+		Storage: &lConfig.StorageConfig{Backend: lConfig.StorageMemory},
+		Blob:    &lConfig.BlobConfig{Backend: lConfig.BlobMemory},
+	}
+
+	storage, err := InitStorage(cfg)
+
+	// The memory backend needs no connection
+	assert.NoError(t, err)
+	assert.Equal(t, lConfig.StorageMemory, storage.Backend)
+	assert.Nil(t, storage.DynamoDB)
+	assert.Nil(t, storage.SQL)
+	assert.NotNil(t, storage.Blobs)
+}
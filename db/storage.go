@@ -0,0 +1,43 @@
+package db
+
+import (
+	"database/sql"
+
+	lConfig "github.com/nalawade41/secret-server/config"
+	"github.com/nalawade41/secret-server/internal/common/logger"
+	"github.com/nalawade41/secret-server/internal/domain"
+)
+
+// Storage is the connection to the backend the secrets are stored in, only the one of Backend is set.
+// Blobs holds the content of file secrets whatever the backend is.
+type Storage struct {
+	Backend  string
+	DynamoDB DynamoDBAPI
+	SQL      *sql.DB
+	Blobs    domain.BlobStore
+}
+
+// InitStorage connects to the storage backend selected in the config
+func InitStorage(cfg *lConfig.Config) (*Storage, error) {
+	storage := &Storage{Backend: cfg.Storage.Backend}
+
+	var err error
+	switch cfg.Storage.Backend {
+	case lConfig.StorageMemory:
+	case lConfig.StorageSQLite, lConfig.StoragePostgres:
+		storage.SQL, err = InitSQL(cfg)
+	default:
+		storage.DynamoDB, err = InitDynamoDB(cfg)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if storage.Blobs, err = InitBlobStore(cfg); err != nil {
+		return nil, err
+	}
+
+	logger.Infof("Using %s storage backend", cfg.Storage.Backend)
+
+	return storage, nil
+}
@@ -48,16 +48,227 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/secret": {
+        "/api/v1/admin/keys": {
+            "get": {
+                "security": [
+                    {
+                        "APIKey": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Lists every key by its creation time, revoked keys included. The keys themselves are never listed.",
+                "produces": [
+                    "application/json",
+                    " application/xml",
+                    " application/yaml"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "List API keys",
+                "operationId": "listKeys",
+                "responses": {
+                    "200": {
+                        "description": "successful operation",
+                        "schema": {
+                            "$ref": "#/definitions/response.APIKeyListResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Caller missing or invalid, api_key_required, invalid_api_key or invalid_token",
+                        "schema": {
+                            "$ref": "#/definitions/responses.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Caller lacks the admin scope, insufficient_scope",
+                        "schema": {
+                            "$ref": "#/definitions/responses.Problem"
+                        }
+                    },
+                    "406": {
+                        "description": "None of the accepted media types is supported, not_acceptable",
+                        "schema": {
+                            "$ref": "#/definitions/responses.Problem"
+                        }
+                    },
+                    "503": {
+                        "description": "Storage unavailable, service_unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/responses.Problem"
+                        }
+                    }
+                }
+            },
             "post": {
-                "description": "Add a new secret with expiration controls",
+                "security": [
+                    {
+                        "APIKey": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Issues a key with the scopes, the key is only returned in this response.\nA key of a tenant can only use the routes of its tenant, it can not have the admin scope.",
                 "consumes": [
-                    "application/x-www-form-urlencoded"
+                    "application/json"
                 ],
+                "produces": [
+                    "application/json",
+                    " application/xml",
+                    " application/yaml"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Issue an API key",
+                "operationId": "issueKey",
+                "parameters": [
+                    {
+                        "description": "Name, scopes and tenant of the key",
+                        "name": "key",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/requests.IssueKeyRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "key issued",
+                        "schema": {
+                            "$ref": "#/definitions/response.APIKeyResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request, malformed_request or invalid_input",
+                        "schema": {
+                            "$ref": "#/definitions/responses.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Caller missing or invalid, api_key_required, invalid_api_key or invalid_token",
+                        "schema": {
+                            "$ref": "#/definitions/responses.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Caller lacks the admin scope, insufficient_scope",
+                        "schema": {
+                            "$ref": "#/definitions/responses.Problem"
+                        }
+                    },
+                    "406": {
+                        "description": "None of the accepted media types is supported, not_acceptable",
+                        "schema": {
+                            "$ref": "#/definitions/responses.Problem"
+                        }
+                    },
+                    "415": {
+                        "description": "Content type not supported, unsupported_media_type",
+                        "schema": {
+                            "$ref": "#/definitions/responses.Problem"
+                        }
+                    },
+                    "503": {
+                        "description": "Storage unavailable, service_unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/responses.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/keys/{id}": {
+            "delete": {
+                "security": [
+                    {
+                        "APIKey": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Revokes a key, requests with it are rejected from then on. Revoking a revoked key succeeds.",
                 "produces": [
                     "application/json",
                     " application/xml"
                 ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Revoke an API key",
+                "operationId": "revokeKey",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Id of the key",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "key revoked"
+                    },
+                    "400": {
+                        "description": "Bad request, id_required",
+                        "schema": {
+                            "$ref": "#/definitions/responses.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Caller missing or invalid, api_key_required, invalid_api_key or invalid_token",
+                        "schema": {
+                            "$ref": "#/definitions/responses.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Caller lacks the admin scope, insufficient_scope",
+                        "schema": {
+                            "$ref": "#/definitions/responses.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Key not found, api_key_not_found",
+                        "schema": {
+                            "$ref": "#/definitions/responses.Problem"
+                        }
+                    },
+                    "503": {
+                        "description": "Storage unavailable, service_unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/responses.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/secret": {
+            "post": {
+                "security": [
+                    {
+                        "APIKey": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Add a new secret with expiration controls.\nWith clientEncrypted the secret is an envelope encrypted by the client, the server stores it without decrypting it.\nA multipart/form-data body can send a file in the file field instead of the secret, it is encrypted like the secret.",
+                "consumes": [
+                    "application/json",
+                    " application/x-www-form-urlencoded",
+                    " multipart/form-data"
+                ],
+                "produces": [
+                    "application/json",
+                    " application/xml",
+                    " application/yaml"
+                ],
                 "tags": [
                     "secret"
                 ],
@@ -76,21 +287,95 @@ const docTemplate = `{
                 ],
                 "responses": {
                     "200": {
-                        "description": "successful operation",
+                        "description": "successful operation, the key is only returned once for secrets encrypted by the server",
                         "schema": {
                             "$ref": "#/definitions/response.SecretResponse"
+                        },
+                        "headers": {
+                            "RateLimit-Limit": {
+                                "type": "integer",
+                                "description": "Limit of the most restrictive quota, only sent when a quota applies"
+                            },
+                            "RateLimit-Remaining": {
+                                "type": "integer",
+                                "description": "Secrets or bytes left in that quota"
+                            },
+                            "RateLimit-Reset": {
+                                "type": "integer",
+                                "description": "Seconds until that quota resets or frees up"
+                            }
                         }
                     },
                     "400": {
-                        "description": "Bad request",
+                        "description": "Bad request, malformed_request or invalid_input, also for unknown fields and query parameters",
                         "schema": {
-                            "$ref": "#/definitions/responses.Error"
+                            "$ref": "#/definitions/responses.Problem"
                         }
                     },
-                    "405": {
-                        "description": "Invalid input",
+                    "401": {
+                        "description": "Caller missing or invalid when API keys are required, api_key_required, invalid_api_key or invalid_token",
                         "schema": {
-                            "$ref": "#/definitions/responses.Error"
+                            "$ref": "#/definitions/responses.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Caller lacks the secrets:create scope or belongs to another tenant, insufficient_scope or tenant_mismatch",
+                        "schema": {
+                            "$ref": "#/definitions/responses.Problem"
+                        }
+                    },
+                    "406": {
+                        "description": "None of the accepted media types is supported, not_acceptable",
+                        "schema": {
+                            "$ref": "#/definitions/responses.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "No free id for the secret, secret_already_exists",
+                        "schema": {
+                            "$ref": "#/definitions/responses.Problem"
+                        }
+                    },
+                    "413": {
+                        "description": "Request body or file too large, request_entity_too_large",
+                        "schema": {
+                            "$ref": "#/definitions/responses.Problem"
+                        }
+                    },
+                    "415": {
+                        "description": "Content type not supported, unsupported_media_type",
+                        "schema": {
+                            "$ref": "#/definitions/responses.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "Quota of the caller or the tenant exceeded, quota_exceeded",
+                        "schema": {
+                            "$ref": "#/definitions/responses.Problem"
+                        },
+                        "headers": {
+                            "RateLimit-Limit": {
+                                "type": "integer",
+                                "description": "Limit of the most restrictive quota, only sent when a quota applies"
+                            },
+                            "RateLimit-Remaining": {
+                                "type": "integer",
+                                "description": "Secrets or bytes left in that quota"
+                            },
+                            "RateLimit-Reset": {
+                                "type": "integer",
+                                "description": "Seconds until that quota resets or frees up"
+                            },
+                            "Retry-After": {
+                                "type": "integer",
+                                "description": "Seconds until the exceeded quota resets or frees up"
+                            }
+                        }
+                    },
+                    "503": {
+                        "description": "Storage unavailable, service_unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/responses.Problem"
                         }
                     }
                 }
@@ -98,10 +383,12 @@ const docTemplate = `{
         },
         "/api/v1/secret/{hash}": {
             "get": {
-                "description": "Returns a single secret",
+                "description": "Returns a single secret, client encrypted secrets are returned as the stored envelope.\nA file is returned as an attachment with the type it was uploaded with, whatever the Accept header asks for.",
                 "produces": [
                     "application/json",
-                    " application/xml"
+                    " application/xml",
+                    " application/yaml",
+                    " text/plain"
                 ],
                 "tags": [
                     "Secret"
@@ -115,6 +402,18 @@ const docTemplate = `{
                         "name": "hash",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Decryption key from the secret link, required unless the secret is client encrypted",
+                        "name": "key",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Passphrase of a passphrase protected secret",
+                        "name": "X-Secret-Passphrase",
+                        "in": "header"
                     }
                 ],
                 "responses": {
@@ -122,18 +421,271 @@ const docTemplate = `{
                         "description": "successful operation",
                         "schema": {
                             "$ref": "#/definitions/response.SecretResponse"
+                        },
+                        "headers": {
+                            "X-Remaining-Views": {
+                                "type": "integer",
+                                "description": "Views left after this one, only sent with files"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request, hash_required or key_required",
+                        "schema": {
+                            "$ref": "#/definitions/responses.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Passphrase missing or wrong or bearer token invalid, passphrase_required, wrong_passphrase or invalid_token",
+                        "schema": {
+                            "$ref": "#/definitions/responses.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Secret not found, secret_not_found or invalid_key",
+                        "schema": {
+                            "$ref": "#/definitions/responses.Problem"
+                        }
+                    },
+                    "406": {
+                        "description": "None of the accepted media types is supported, not_acceptable",
+                        "schema": {
+                            "$ref": "#/definitions/responses.Problem"
+                        }
+                    },
+                    "410": {
+                        "description": "Secret gone, secret_expired, views_exhausted or secret_burned",
+                        "schema": {
+                            "$ref": "#/definitions/responses.Problem"
+                        }
+                    },
+                    "503": {
+                        "description": "Storage unavailable, service_unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/responses.Problem"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "APIKey": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Deletes a secret immediately, only the creator holding the revocation token can delete it.\nAn authenticated owner of the secret can delete it without the token.",
+                "produces": [
+                    "application/json",
+                    " application/xml"
+                ],
+                "tags": [
+                    "Secret"
+                ],
+                "summary": "Delete a secret",
+                "operationId": "deleteSecret",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Unique hash to identify the secret",
+                        "name": "hash",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Revocation token returned when the secret was created, required unless the caller owns the secret",
+                        "name": "X-Revocation-Token",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "secret deleted"
+                    },
+                    "400": {
+                        "description": "Bad request, hash_required or revocation_token_required",
+                        "schema": {
+                            "$ref": "#/definitions/responses.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "API key or bearer token invalid, invalid_api_key or invalid_token",
+                        "schema": {
+                            "$ref": "#/definitions/responses.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Invalid revocation token or not the owner, invalid_revocation_token",
+                        "schema": {
+                            "$ref": "#/definitions/responses.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Secret not found, secret_not_found",
+                        "schema": {
+                            "$ref": "#/definitions/responses.Problem"
+                        }
+                    },
+                    "503": {
+                        "description": "Storage unavailable, service_unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/responses.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/secret/{hash}/status": {
+            "get": {
+                "security": [
+                    {
+                        "APIKey": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Returns the metadata of a secret to its creator without consuming a view, the secret itself is never returned.\nAn authenticated owner of the secret can read it without the token.",
+                "produces": [
+                    "application/json",
+                    " application/xml",
+                    " application/yaml"
+                ],
+                "tags": [
+                    "Secret"
+                ],
+                "summary": "Get the status of a secret",
+                "operationId": "getSecretStatus",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Unique hash to identify the secret",
+                        "name": "hash",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Revocation token returned when the secret was created, required unless the caller owns the secret",
+                        "name": "X-Revocation-Token",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "successful operation",
+                        "schema": {
+                            "$ref": "#/definitions/response.SecretStatusResponse"
                         }
                     },
                     "400": {
-                        "description": "Bad request, hash missing",
+                        "description": "Bad request, hash_required or revocation_token_required",
                         "schema": {
-                            "$ref": "#/definitions/responses.Error"
+                            "$ref": "#/definitions/responses.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "API key or bearer token invalid, invalid_api_key or invalid_token",
+                        "schema": {
+                            "$ref": "#/definitions/responses.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Invalid revocation token or not the owner, invalid_revocation_token",
+                        "schema": {
+                            "$ref": "#/definitions/responses.Problem"
                         }
                     },
                     "404": {
-                        "description": "Secret not found",
+                        "description": "Secret not found, secret_not_found",
+                        "schema": {
+                            "$ref": "#/definitions/responses.Problem"
+                        }
+                    },
+                    "406": {
+                        "description": "None of the accepted media types is supported, not_acceptable",
+                        "schema": {
+                            "$ref": "#/definitions/responses.Problem"
+                        }
+                    },
+                    "503": {
+                        "description": "Storage unavailable, service_unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/responses.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/secrets": {
+            "get": {
+                "security": [
+                    {
+                        "APIKey": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Lists the metadata of the secrets created by the caller, newest first. The secrets themselves are never listed.\nPass the nextCursor of a page as cursor to get the next page, the last page has no nextCursor.",
+                "produces": [
+                    "application/json",
+                    " application/xml",
+                    " application/yaml"
+                ],
+                "tags": [
+                    "Secret"
+                ],
+                "summary": "List own secrets",
+                "operationId": "listSecrets",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Cursor of the page, the nextCursor of the previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "maximum": 100,
+                        "minimum": 1,
+                        "type": "integer",
+                        "description": "Number of secrets on the page, 20 by default",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "successful operation",
+                        "schema": {
+                            "$ref": "#/definitions/response.SecretListResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request, invalid_input or invalid_cursor",
+                        "schema": {
+                            "$ref": "#/definitions/responses.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Caller missing or invalid, api_key_required, invalid_api_key or invalid_token",
+                        "schema": {
+                            "$ref": "#/definitions/responses.Problem"
+                        }
+                    },
+                    "406": {
+                        "description": "None of the accepted media types is supported, not_acceptable",
+                        "schema": {
+                            "$ref": "#/definitions/responses.Problem"
+                        }
+                    },
+                    "503": {
+                        "description": "Storage unavailable, service_unavailable",
                         "schema": {
-                            "$ref": "#/definitions/responses.Error"
+                            "$ref": "#/definitions/responses.Problem"
                         }
                     }
                 }
@@ -141,23 +693,126 @@ const docTemplate = `{
         }
     },
     "definitions": {
+        "domain.FieldError": {
+            "type": "object",
+            "properties": {
+                "field": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
         "requests.CreateSecretRequest": {
             "type": "object",
             "properties": {
+                "algorithm": {
+                    "type": "string"
+                },
+                "clientEncrypted": {
+                    "description": "ClientEncrypted marks the secret as an envelope encrypted by the client with Algorithm,\nthe server stores it as it is and never sees the key",
+                    "type": "boolean"
+                },
                 "expireAfter": {
                     "type": "integer"
                 },
                 "expireAfterViews": {
                     "type": "integer"
                 },
+                "passphrase": {
+                    "description": "Passphrase is optional, when set it is required again to read the secret",
+                    "type": "string"
+                },
                 "secret": {
                     "type": "string"
                 }
             }
         },
+        "requests.IssueKeyRequest": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "description": "Name tells the keys apart, for example the client or pipeline using it",
+                    "type": "string"
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "tenant": {
+                    "description": "Tenant restricts the key to the routes of the tenant, empty for the default routes",
+                    "type": "string"
+                }
+            }
+        },
+        "response.APIKeyListResponse": {
+            "type": "object",
+            "properties": {
+                "keys": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/response.APIKeyResponse"
+                    }
+                }
+            }
+        },
+        "response.APIKeyResponse": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "key": {
+                    "description": "Key is only returned when the key is issued, it can not be retrieved again",
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "revokedAt": {
+                    "type": "string"
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "tenant": {
+                    "type": "string"
+                }
+            }
+        },
+        "response.SecretListResponse": {
+            "type": "object",
+            "properties": {
+                "nextCursor": {
+                    "type": "string"
+                },
+                "secrets": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/response.SecretStatusResponse"
+                    }
+                }
+            }
+        },
         "response.SecretResponse": {
             "type": "object",
             "properties": {
+                "algorithm": {
+                    "type": "string"
+                },
+                "clientEncrypted": {
+                    "description": "ClientEncrypted tells the reader that SecretText is an envelope to decrypt with the key from the link",
+                    "type": "boolean"
+                },
                 "createdAt": {
                     "type": "string"
                 },
@@ -167,25 +822,99 @@ const docTemplate = `{
                 "hash": {
                     "type": "string"
                 },
+                "key": {
+                    "type": "string"
+                },
                 "remainingViews": {
                     "type": "integer"
                 },
+                "revocationToken": {
+                    "description": "RevocationToken is only returned to the creator, it is needed to delete the secret",
+                    "type": "string"
+                },
                 "secretText": {
                     "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "response.SecretStatusResponse": {
+            "type": "object",
+            "properties": {
+                "consumed": {
+                    "type": "boolean"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "expired": {
+                    "type": "boolean"
+                },
+                "expiresAt": {
+                    "type": "string"
+                },
+                "hash": {
+                    "type": "string"
+                },
+                "readAt": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "remainingViews": {
+                    "type": "integer"
                 }
             }
         },
-        "responses.Error": {
+        "responses.Problem": {
             "type": "object",
             "properties": {
-                "code": {
+                "detail": {
+                    "type": "string"
+                },
+                "errorCode": {
+                    "description": "ErrorCode is a stable machine readable code, clients branch on it instead of the title",
+                    "type": "string"
+                },
+                "errors": {
+                    "description": "Errors lists every invalid field of a rejected request",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/domain.FieldError"
+                    }
+                },
+                "instance": {
+                    "type": "string"
+                },
+                "status": {
                     "type": "integer"
                 },
-                "message": {
+                "title": {
+                    "type": "string"
+                },
+                "type": {
+                    "description": "Type identifies the kind of problem, the errorCode is the same identifier without the URN prefix",
                     "type": "string"
                 }
             }
         }
+    },
+    "securityDefinitions": {
+        "APIKey": {
+            "description": "API key issued through the admin API or the apikey command",
+            "type": "apiKey",
+            "name": "X-API-Key",
+            "in": "header"
+        },
+        "BearerAuth": {
+            "description": "JWT of the identity provider as \"Bearer \u003ctoken\u003e\"",
+            "type": "apiKey",
+            "name": "Authorization",
+            "in": "header"
+        }
     }
 }`
 
@@ -19,6 +19,8 @@ import {
 import * as path from "node:path";
 import {Stack} from "aws-cdk-lib";
 import {Effect, Policy, PolicyStatement} from "aws-cdk-lib/aws-iam";
+import {Key} from "aws-cdk-lib/aws-kms";
+import {BlockPublicAccess, Bucket, BucketEncryption} from "aws-cdk-lib/aws-s3";
 
 
 export class DeployApi extends cdk.Stack {
@@ -33,6 +35,21 @@ export class DeployApi extends cdk.Stack {
       logGroupName: `/aws/apigateway/${ENV}-api-logs`,
     });
 
+    // Create the KMS key that wraps the data keys of the secrets, the secrets can not be read without it
+    const masterKey = new Key(this, `${ENV}-secrets-master-key`, {
+      alias: `alias/${ENV}-secrets-master-key`,
+      description: `${ENV} master key of the secrets`,
+      enableKeyRotation: true,
+      removalPolicy: cdk.RemovalPolicy.RETAIN,
+    });
+
+    // Create the bucket of the encrypted file secrets, the Lambda function has no writable directory to keep them in
+    const blobBucket = new Bucket(this, `${ENV}-secret-files`, {
+      encryption: BucketEncryption.S3_MANAGED,
+      blockPublicAccess: BlockPublicAccess.BLOCK_ALL,
+      enforceSSL: true,
+      removalPolicy: cdk.RemovalPolicy.RETAIN,
+    });
 
     // Create the Lambda function
     const apiHandler = new Function(this, "api-sls", {
@@ -44,6 +61,10 @@ export class DeployApi extends cdk.Stack {
         WRITE_TIMEOUT: "5s",
         MAX_HEADER_BYTES: "1048576",
         DB_TABLE_NAME: "secrets",
+        KEY_PROVIDER: "kms",
+        KMS_KEY_ID: masterKey.keyArn,
+        BLOB_BACKEND: "s3",
+        BLOB_BUCKET: blobBucket.bucketName,
       },
       tracing: Tracing.ACTIVE,
       memorySize: 512,
@@ -78,6 +99,13 @@ export class DeployApi extends cdk.Stack {
 
     apiHandler.role?.attachInlinePolicy(policy);
 
+    // Allow the Lambda function to wrap and unwrap the data keys with the master key
+    masterKey.grantEncryptDecrypt(apiHandler);
+    masterKey.grant(apiHandler, "kms:DescribeKey");
+
+    // Allow the Lambda function to store, read and delete the encrypted files
+    blobBucket.grantReadWrite(apiHandler);
+
     // Configure API Gateway properties
     const apiGatewayProps: RestApiProps = {
       description: `${ENV} API Gateway`,
@@ -113,6 +141,13 @@ export class DeployApi extends cdk.Stack {
     // Create a new instance of RestApi
     const apiGateway = new RestApi(this, apiGatewayName, apiGatewayProps);
 
+    // Build the links of the secrets on the URL of the stage, never on the Host header of a request.
+    // The stage name is spelled out, referencing the stage would make the function depend on its own deployment.
+    apiHandler.addEnvironment(
+        "PUBLIC_BASE_URL",
+        `https://${apiGateway.restApiId}.execute-api.${Stack.of(this).region}.${Stack.of(this).urlSuffix}/${ENV}`
+    );
+
     apiGateway.root.addProxy({
       defaultIntegration: new LambdaIntegration(apiHandler, {
         proxy: true,
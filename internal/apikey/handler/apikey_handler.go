@@ -0,0 +1,200 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nalawade41/secret-server/internal/apikey/requests"
+	"github.com/nalawade41/secret-server/internal/apikey/response"
+	"github.com/nalawade41/secret-server/internal/common/responses"
+	"github.com/nalawade41/secret-server/internal/domain"
+)
+
+const (
+	// APIKeyHeader carries the API key of the client
+	APIKeyHeader = "X-API-Key"
+
+	// apiKeyContextKey stores the authenticated key in the echo context
+	apiKeyContextKey = "apiKey"
+)
+
+var errIDRequired = domain.NewError(domain.ErrValidation, "id_required", "id is required")
+
+type APIKeyHandler struct {
+	APIKeys domain.APIKeyUseCase
+	// Tenants are the configured tenants, keys can only be issued for them
+	Tenants map[string]domain.TenantPolicy
+}
+
+// InitRoutes adds the admin API for keys to the group, every route requires a key with the admin scope
+func (h *APIKeyHandler) InitRoutes(e *echo.Group) {
+	admin := e.Group("/admin", h.RequireScope(domain.ScopeAdmin))
+	admin.POST("/keys", h.IssueKey)
+	admin.GET("/keys", h.ListKeys)
+	admin.DELETE("/keys/:id", h.RevokeKey)
+}
+
+// Authenticate returns a middleware that puts the principal of the API key in the X-API-Key header into the
+// request context. Requests without a key, or already authenticated with a bearer token, pass through as they are,
+// an invalid key is rejected.
+func (h *APIKeyHandler) Authenticate() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if c.Request().Header.Get(APIKeyHeader) == "" {
+				return next(c)
+			}
+
+			if _, err := h.authenticate(c); err != nil {
+				return err
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// RequireScope returns a middleware that only lets callers with the scope through. A caller already
+// authenticated with a bearer token is checked as it is, anyone else needs a valid API key in the X-API-Key
+// header. The principal of the key is put into the request context like the one of a token.
+func (h *APIKeyHandler) RequireScope(scope string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			principal, err := h.authenticate(c)
+			if err != nil {
+				return err
+			}
+
+			if !principal.HasScope(scope) {
+				return domain.ErrInsufficientScope
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// authenticate returns the principal of the request context, without one it authenticates the API key
+// of the request and puts its principal into the context
+func (h *APIKeyHandler) authenticate(c echo.Context) (domain.Principal, error) {
+	ctx := c.Request().Context()
+
+	if principal, ok := domain.PrincipalFromContext(ctx); ok {
+		return principal, nil
+	}
+
+	token := c.Request().Header.Get(APIKeyHeader)
+	if token == "" {
+		return domain.Principal{}, domain.ErrAPIKeyRequired
+	}
+
+	key, err := h.APIKeys.Authenticate(ctx, token)
+	if err != nil {
+		return domain.Principal{}, err
+	}
+
+	principal := domain.Principal{Subject: domain.SubjectPrefixAPIKey + key.ID, Scopes: key.Scopes, Tenant: key.Tenant}
+	c.Set(apiKeyContextKey, key)
+	c.SetRequest(c.Request().WithContext(domain.ContextWithPrincipal(ctx, principal)))
+
+	return principal, nil
+}
+
+// KeyFromContext returns the key a request was authenticated with by RequireScope
+func KeyFromContext(c echo.Context) (domain.APIKey, bool) {
+	key, ok := c.Get(apiKeyContextKey).(domain.APIKey)
+	return key, ok
+}
+
+// IssueKey godoc
+//	@Summary		Issue an API key
+//	@Description	Issues a key with the scopes, the key is only returned in this response.
+//	@Description	A key of a tenant can only use the routes of its tenant, it can not have the admin scope.
+//	@Tags			admin
+//	@ID				issueKey
+//	@Accept			application/json
+//	@Produce		application/json, application/xml, application/yaml
+//	@Security		APIKey
+//	@Security		BearerAuth
+//	@Param			key	body		requests.IssueKeyRequest	true	"Name, scopes and tenant of the key"
+//	@Success		201	{object}	response.APIKeyResponse		"key issued"
+//	@Failure		400	{object}	responses.Problem			"Bad request, malformed_request or invalid_input"
+//	@Failure		401	{object}	responses.Problem			"Caller missing or invalid, api_key_required, invalid_api_key or invalid_token"
+//	@Failure		403	{object}	responses.Problem			"Caller lacks the admin scope, insufficient_scope"
+//	@Failure		406	{object}	responses.Problem			"None of the accepted media types is supported, not_acceptable"
+//	@Failure		415	{object}	responses.Problem			"Content type not supported, unsupported_media_type"
+//	@Failure		503	{object}	responses.Problem			"Storage unavailable, service_unavailable"
+//	@Router			/api/v1/admin/keys [post]
+func (h *APIKeyHandler) IssueKey(c echo.Context) error {
+	request := new(requests.IssueKeyRequest)
+	if err := request.Bind(c.Request()); err != nil {
+		return err
+	}
+
+	if err := request.Validate(h.Tenants); err != nil {
+		return err
+	}
+
+	// The key is only handed out once, a client that can not read the response must not issue it
+	if _, err := responses.Negotiate(c, false); err != nil {
+		return err
+	}
+
+	key, err := h.APIKeys.IssueKey(c.Request().Context(), request.Name, request.Tenant, request.Scopes)
+	if err != nil {
+		return err
+	}
+
+	return responses.Response(c, http.StatusCreated, response.NewAPIKeyResponse(key))
+}
+
+// ListKeys godoc
+//	@Summary		List API keys
+//	@Description	Lists every key by its creation time, revoked keys included. The keys themselves are never listed.
+//	@Tags			admin
+//	@ID				listKeys
+//	@Produce		application/json, application/xml, application/yaml
+//	@Security		APIKey
+//	@Security		BearerAuth
+//	@Success		200	{object}	response.APIKeyListResponse	"successful operation"
+//	@Failure		401	{object}	responses.Problem				"Caller missing or invalid, api_key_required, invalid_api_key or invalid_token"
+//	@Failure		403	{object}	responses.Problem				"Caller lacks the admin scope, insufficient_scope"
+//	@Failure		406	{object}	responses.Problem				"None of the accepted media types is supported, not_acceptable"
+//	@Failure		503	{object}	responses.Problem				"Storage unavailable, service_unavailable"
+//	@Router			/api/v1/admin/keys [get]
+func (h *APIKeyHandler) ListKeys(c echo.Context) error {
+	keys, err := h.APIKeys.ListKeys(c.Request().Context())
+	if err != nil {
+		return err
+	}
+
+	return responses.Response(c, http.StatusOK, response.NewAPIKeyListResponse(keys))
+}
+
+// RevokeKey godoc
+//	@Summary		Revoke an API key
+//	@Description	Revokes a key, requests with it are rejected from then on. Revoking a revoked key succeeds.
+//	@Tags			admin
+//	@ID				revokeKey
+//	@Produce		application/json, application/xml
+//	@Security		APIKey
+//	@Security		BearerAuth
+//	@Param			id	path	string	true	"Id of the key"
+//	@Success		204	"key revoked"
+//	@Failure		400	{object}	responses.Problem	"Bad request, id_required"
+//	@Failure		401	{object}	responses.Problem	"Caller missing or invalid, api_key_required, invalid_api_key or invalid_token"
+//	@Failure		403	{object}	responses.Problem	"Caller lacks the admin scope, insufficient_scope"
+//	@Failure		404	{object}	responses.Problem	"Key not found, api_key_not_found"
+//	@Failure		503	{object}	responses.Problem	"Storage unavailable, service_unavailable"
+//	@Router			/api/v1/admin/keys/{id} [delete]
+func (h *APIKeyHandler) RevokeKey(c echo.Context) error {
+	id := c.Param("id")
+	if id == "" {
+		return errIDRequired
+	}
+
+	if err := h.APIKeys.RevokeKey(c.Request().Context(), id); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
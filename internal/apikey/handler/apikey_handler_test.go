@@ -0,0 +1,256 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	"github.com/nalawade41/secret-server/internal/apikey/response"
+	"github.com/nalawade41/secret-server/internal/domain"
+	"github.com/nalawade41/secret-server/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequireScope(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		key     domain.APIKey
+		authErr error
+		// principal was put into the context from a bearer token before
+		principal *domain.Principal
+		wantErr   error
+	}{
+		{name: "key with scope", header: "ssk_abc_secret", key: domain.APIKey{ID: "abc", Scopes: []string{domain.ScopeSecretsCreate}}},
+		{name: "missing key", wantErr: domain.ErrAPIKeyRequired},
+		{name: "invalid key", header: "ssk_abc_wrong", authErr: domain.ErrInvalidAPIKey, wantErr: domain.ErrInvalidAPIKey},
+		{name: "key without scope", header: "ssk_abc_secret", key: domain.APIKey{ID: "abc", Scopes: []string{domain.ScopeAdmin}}, wantErr: domain.ErrInsufficientScope},
+		{name: "token with scope", principal: &domain.Principal{Subject: "alice", Scopes: []string{domain.ScopeSecretsCreate}}},
+		{name: "token without scope", principal: &domain.Principal{Subject: "alice"}, wantErr: domain.ErrInsufficientScope},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockUseCase := mocks.NewMockAPIKeyUseCase(ctrl)
+			if tt.header != "" {
+				mockUseCase.EXPECT().Authenticate(gomock.Any(), tt.header).Return(tt.key, tt.authErr)
+			}
+
+			handler := APIKeyHandler{APIKeys: mockUseCase}
+
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/secret", nil)
+			if tt.header != "" {
+				req.Header.Set(APIKeyHeader, tt.header)
+			}
+			if tt.principal != nil {
+				req = req.WithContext(domain.ContextWithPrincipal(req.Context(), *tt.principal))
+			}
+			c := e.NewContext(req, httptest.NewRecorder())
+
+			called := false
+			err := handler.RequireScope(domain.ScopeSecretsCreate)(func(c echo.Context) error {
+				called = true
+				principal, ok := domain.PrincipalFromContext(c.Request().Context())
+				assert.True(t, ok)
+				if tt.principal != nil {
+					assert.Equal(t, tt.principal.Subject, principal.Subject)
+					return nil
+				}
+
+				// Clients of API keys are known by the id of the key
+				assert.Equal(t, "apikey:"+tt.key.ID, principal.Subject)
+				key, ok := KeyFromContext(c)
+				assert.True(t, ok)
+				assert.Equal(t, tt.key.ID, key.ID)
+				return nil
+			})(c)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.False(t, called, "The request must not reach the handler")
+				return
+			}
+			assert.NoError(t, err)
+			assert.True(t, called)
+		})
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUseCase := mocks.NewMockAPIKeyUseCase(ctrl)
+	handler := APIKeyHandler{APIKeys: mockUseCase}
+
+	mockUseCase.EXPECT().Authenticate(gomock.Any(), "ssk_abc_secret").Return(domain.APIKey{ID: "abc", Scopes: []string{domain.ScopeAdmin}, Tenant: "team-a"}, nil)
+	mockUseCase.EXPECT().Authenticate(gomock.Any(), "ssk_abc_wrong").Return(domain.APIKey{}, domain.ErrInvalidAPIKey)
+
+	serve := func(header string) (domain.Principal, bool, error) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/secrets", nil)
+		if header != "" {
+			req.Header.Set(APIKeyHeader, header)
+		}
+		c := echo.New().NewContext(req, httptest.NewRecorder())
+
+		var (
+			principal domain.Principal
+			ok        bool
+		)
+		err := handler.Authenticate()(func(c echo.Context) error {
+			principal, ok = domain.PrincipalFromContext(c.Request().Context())
+			return nil
+		})(c)
+		return principal, ok, err
+	}
+
+	// Requests without a key stay anonymous
+	_, ok, err := serve("")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	// The scopes of the key are not checked, only that the key is valid
+	principal, ok, err := serve("ssk_abc_secret")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "apikey:abc", principal.Subject)
+	assert.Equal(t, "team-a", principal.Tenant, "The caller is restricted to the tenant of its key")
+
+	_, _, err = serve("ssk_abc_wrong")
+	assert.ErrorIs(t, err, domain.ErrInvalidAPIKey)
+}
+
+func TestIssueKey_Success(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUseCase := mocks.NewMockAPIKeyUseCase(ctrl)
+	handler := APIKeyHandler{APIKeys: mockUseCase}
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/admin/keys", bytes.NewBufferString(`{"name":"ci","scopes":["secrets:create"]}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	issued := domain.APIKey{ID: "abc", Name: "ci", Key: "ssk_abc_secret", Scopes: []string{domain.ScopeSecretsCreate}, CreatedAt: time.Now().UTC()}
+	mockUseCase.EXPECT().IssueKey(gomock.Any(), "ci", domain.DefaultTenant, []string{domain.ScopeSecretsCreate}).Return(issued, nil)
+
+	if assert.NoError(t, handler.IssueKey(c)) {
+		assert.Equal(t, http.StatusCreated, rec.Code)
+
+		var keyResponse response.APIKeyResponse
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &keyResponse))
+		assert.Equal(t, "abc", keyResponse.ID)
+		assert.Equal(t, "ssk_abc_secret", keyResponse.Key, "The key is returned when it is issued")
+	}
+}
+
+func TestIssueKey_InvalidScope(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// The use case is never called for an invalid request
+	handler := APIKeyHandler{APIKeys: mocks.NewMockAPIKeyUseCase(ctrl)}
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/admin/keys", bytes.NewBufferString(`{"name":"ci","scopes":["root"]}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	c := e.NewContext(req, httptest.NewRecorder())
+
+	assert.ErrorIs(t, handler.IssueKey(c), domain.ErrValidation)
+}
+
+func TestIssueKey_Tenant(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUseCase := mocks.NewMockAPIKeyUseCase(ctrl)
+	handler := APIKeyHandler{APIKeys: mockUseCase, Tenants: map[string]domain.TenantPolicy{"team-a": {}}}
+
+	issue := func(body string) (*httptest.ResponseRecorder, error) {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/admin/keys", bytes.NewBufferString(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		return rec, handler.IssueKey(echo.New().NewContext(req, rec))
+	}
+
+	issued := domain.APIKey{ID: "abc", Name: "ci", Key: "ssk_abc_secret", Scopes: []string{domain.ScopeSecretsCreate}, Tenant: "team-a", CreatedAt: time.Now().UTC()}
+	mockUseCase.EXPECT().IssueKey(gomock.Any(), "ci", "team-a", []string{domain.ScopeSecretsCreate}).Return(issued, nil)
+
+	rec, err := issue(`{"name":"ci","scopes":["secrets:create"],"tenant":"team-a"}`)
+	if assert.NoError(t, err) {
+		var keyResponse response.APIKeyResponse
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &keyResponse))
+		assert.Equal(t, "team-a", keyResponse.Tenant)
+	}
+
+	// Tenants that are not configured are rejected before the use case is called
+	_, err = issue(`{"name":"ci","scopes":["secrets:create"],"tenant":"team-b"}`)
+	assert.ErrorIs(t, err, domain.ErrValidation)
+}
+
+func TestListKeys(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUseCase := mocks.NewMockAPIKeyUseCase(ctrl)
+	handler := APIKeyHandler{APIKeys: mockUseCase}
+
+	revokedAt := time.Now().UTC()
+	mockUseCase.EXPECT().ListKeys(gomock.Any()).Return([]domain.APIKey{
+		{ID: "abc", Name: "ci", Scopes: []string{domain.ScopeSecretsCreate}},
+		{ID: "def", Name: "old", Scopes: []string{domain.ScopeAdmin}, RevokedAt: &revokedAt},
+	}, nil)
+
+	e := echo.New()
+	rec := httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest(http.MethodGet, "/api/v1/admin/keys", nil), rec)
+
+	if assert.NoError(t, handler.ListKeys(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var list response.APIKeyListResponse
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+		if assert.Len(t, list.Keys, 2) {
+			assert.Nil(t, list.Keys[0].RevokedAt)
+			assert.NotNil(t, list.Keys[1].RevokedAt)
+		}
+	}
+}
+
+func TestRevokeKey(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUseCase := mocks.NewMockAPIKeyUseCase(ctrl)
+	handler := APIKeyHandler{APIKeys: mockUseCase}
+
+	mockUseCase.EXPECT().RevokeKey(gomock.Any(), "abc").Return(nil)
+	mockUseCase.EXPECT().RevokeKey(gomock.Any(), "missing").Return(domain.ErrAPIKeyNotFound)
+
+	e := echo.New()
+	rec := httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest(http.MethodDelete, "/api/v1/admin/keys/abc", nil), rec)
+	c.SetParamNames("id")
+	c.SetParamValues("abc")
+
+	if assert.NoError(t, handler.RevokeKey(c)) {
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+	}
+
+	c = e.NewContext(httptest.NewRequest(http.MethodDelete, "/api/v1/admin/keys/missing", nil), httptest.NewRecorder())
+	c.SetParamNames("id")
+	c.SetParamValues("missing")
+
+	assert.ErrorIs(t, handler.RevokeKey(c), domain.ErrAPIKeyNotFound)
+}
@@ -0,0 +1,91 @@
+package apikey
+
+import (
+	"sync"
+
+	"github.com/google/wire"
+	"github.com/nalawade41/secret-server/config"
+	"github.com/nalawade41/secret-server/db"
+	"github.com/nalawade41/secret-server/internal/apikey/handler"
+	"github.com/nalawade41/secret-server/internal/apikey/repository/dynamo"
+	"github.com/nalawade41/secret-server/internal/apikey/repository/memory"
+	"github.com/nalawade41/secret-server/internal/apikey/repository/sqldb"
+	"github.com/nalawade41/secret-server/internal/apikey/usecase"
+	"github.com/nalawade41/secret-server/internal/common/repository"
+	"github.com/nalawade41/secret-server/internal/common/security"
+	"github.com/nalawade41/secret-server/internal/domain"
+	"github.com/nalawade41/secret-server/internal/secret"
+	secretsql "github.com/nalawade41/secret-server/internal/secret/repository/sqldb"
+)
+
+var (
+	apiKeyHandler *handler.APIKeyHandler
+	hdlOnce       sync.Once
+
+	apiKeyUseCase *usecase.APIKeyUseCase
+	ucOnce        sync.Once
+
+	repo     domain.APIKeyRepository
+	repoOnce sync.Once
+
+	ProviderSet wire.ProviderSet = wire.NewSet(
+		NewAPIKeyHandler,
+		NewAPIKeyUseCase,
+		NewAPIKeyRepository,
+		secret.NewEncryptor,
+		secret.NewIDGenerator,
+
+		wire.Bind(new(domain.APIKeyUseCase), new(*usecase.APIKeyUseCase)),
+		wire.Bind(new(domain.Encryptor), new(*security.RealEncryptor)),
+		wire.Bind(new(domain.IDGenerator), new(*security.IDGenerator)),
+	)
+)
+
+func NewAPIKeyUseCase(repo domain.APIKeyRepository, encryptor domain.Encryptor, idGenerator domain.IDGenerator) *usecase.APIKeyUseCase {
+	ucOnce.Do(func() {
+		apiKeyUseCase = &usecase.APIKeyUseCase{
+			APIKeyRepo:  repo,
+			Encryptor:   encryptor,
+			IDGenerator: idGenerator,
+		}
+	})
+	return apiKeyUseCase
+}
+
+func NewAPIKeyHandler(uc domain.APIKeyUseCase, cfg *config.SecretConfig) *handler.APIKeyHandler {
+	hdlOnce.Do(func() {
+		apiKeyHandler = &handler.APIKeyHandler{
+			APIKeys: uc,
+			Tenants: cfg.Tenants,
+		}
+	})
+	return apiKeyHandler
+}
+
+// NewAPIKeyRepository creates the API key repository of the storage backend selected at startup
+func NewAPIKeyRepository(storage *db.Storage, tableName string) domain.APIKeyRepository {
+	repoOnce.Do(func() {
+		switch storage.Backend {
+		case config.StorageMemory:
+			repo = memory.NewAPIKeyRepository()
+		case config.StorageSQLite, config.StoragePostgres:
+			dialect := secretsql.SQLite
+			if storage.Backend == config.StoragePostgres {
+				dialect = secretsql.Postgres
+			}
+			repo = &sqldb.APIKeyRepository{
+				DB:        storage.SQL,
+				Dialect:   dialect,
+				TableName: tableName,
+			}
+		default:
+			repo = &dynamo.APIKeyRepository{
+				BaseRepository: repository.BaseRepository{
+					DBConnection: storage.DynamoDB,
+				},
+				TableName: tableName,
+			}
+		}
+	})
+	return repo
+}
@@ -0,0 +1,150 @@
+package dynamo
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/nalawade41/secret-server/internal/common/repository"
+	"github.com/nalawade41/secret-server/internal/domain"
+	"github.com/pkg/errors"
+)
+
+type APIKeyRepository struct {
+	repository.BaseRepository
+	TableName string
+}
+
+// idAttributeName maps #id to the key attribute, so expressions never clash with a reserved word
+func idAttributeName() map[string]string {
+	return map[string]string{"#id": "id"}
+}
+
+// idKey returns the key of the item of the API key
+func idKey(id string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"id": &types.AttributeValueMemberS{Value: id},
+	}
+}
+
+func (r APIKeyRepository) Save(ctx context.Context, key domain.APIKey) error {
+	item, err := attributevalue.MarshalMap(key)
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("failed to marshal api key: %v", err))
+	}
+
+	// Put the item into the DynamoDB table, unless the id is already taken
+	_, err = r.DBConnection.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:                aws.String(r.TableName),
+		Item:                     item,
+		ConditionExpression:      aws.String("attribute_not_exists(#id)"),
+		ExpressionAttributeNames: idAttributeName(),
+	})
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return errors.Wrap(domain.ErrAPIKeyAlreadyExists, fmt.Sprintf("failed to put api key for id: %s", key.ID))
+		}
+		return errors.Wrap(domain.Unavailable(err), fmt.Sprintf("failed to put api key: %v", err))
+	}
+
+	return nil
+}
+
+func (r APIKeyRepository) GetByID(ctx context.Context, id string) (domain.APIKey, error) {
+	result, err := r.DBConnection.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(r.TableName),
+		Key:       idKey(id),
+	})
+	if err != nil {
+		return domain.APIKey{}, errors.Wrap(domain.Unavailable(err), fmt.Sprintf("failed to retrieve api key for id: %s", id))
+	}
+
+	if result.Item == nil {
+		return domain.APIKey{}, domain.ErrAPIKeyNotFound
+	}
+
+	var key domain.APIKey
+	if err := attributevalue.UnmarshalMap(result.Item, &key); err != nil {
+		return domain.APIKey{}, errors.Wrap(err, fmt.Sprintf("failed to unmarshal api key: %v", err))
+	}
+
+	return key, nil
+}
+
+// Revoke sets the revocation time in a conditional update, a key that is revoked already keeps its time
+func (r APIKeyRepository) Revoke(ctx context.Context, id string, at time.Time) error {
+	revokedAt, err := attributevalue.Marshal(at.UTC())
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("failed to marshal revocation time: %v", err))
+	}
+
+	_, err = r.DBConnection.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:        aws.String(r.TableName),
+		Key:              idKey(id),
+		UpdateExpression: aws.String("SET revokedAt = :revokedAt"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":revokedAt": revokedAt,
+		},
+		ConditionExpression:                 aws.String("attribute_exists(#id) AND attribute_not_exists(revokedAt)"),
+		ExpressionAttributeNames:            idAttributeName(),
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	})
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			// Without an old item the key never existed, otherwise it is revoked already
+			if len(conditionErr.Item) == 0 {
+				return domain.ErrAPIKeyNotFound
+			}
+			return nil
+		}
+		return errors.Wrap(domain.Unavailable(err), fmt.Sprintf("failed to revoke api key for id: %s", id))
+	}
+
+	return nil
+}
+
+// ListKeys scans the whole table, there are only ever a few keys
+func (r APIKeyRepository) ListKeys(ctx context.Context) ([]domain.APIKey, error) {
+	keys := []domain.APIKey{}
+
+	var startKey map[string]types.AttributeValue
+	for {
+		result, err := r.DBConnection.Scan(ctx, &dynamodb.ScanInput{
+			TableName:         aws.String(r.TableName),
+			ExclusiveStartKey: startKey,
+		})
+		if err != nil {
+			return nil, errors.Wrap(domain.Unavailable(err), fmt.Sprintf("failed to scan api keys: %v", err))
+		}
+
+		var page []domain.APIKey
+		if err := attributevalue.UnmarshalListOfMaps(result.Items, &page); err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("failed to unmarshal api keys: %v", err))
+		}
+		keys = append(keys, page...)
+
+		// DynamoDB only returns a last evaluated key when there are more items to scan
+		if len(result.LastEvaluatedKey) == 0 {
+			break
+		}
+		startKey = result.LastEvaluatedKey
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].CreatedAt.Equal(keys[j].CreatedAt) {
+			return keys[i].ID < keys[j].ID
+		}
+		return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+	})
+
+	return keys, nil
+}
+
+var _ domain.APIKeyRepository = (*APIKeyRepository)(nil)
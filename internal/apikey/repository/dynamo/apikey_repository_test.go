@@ -0,0 +1,142 @@
+package dynamo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/golang/mock/gomock"
+	"github.com/nalawade41/secret-server/internal/common/repository"
+	"github.com/nalawade41/secret-server/internal/domain"
+	"github.com/nalawade41/secret-server/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestRepository(ctrl *gomock.Controller) (APIKeyRepository, *mocks.MockDynamoDBAPI) {
+	mockDB := mocks.NewMockDynamoDBAPI(ctrl)
+	return APIKeyRepository{BaseRepository: repository.BaseRepository{DBConnection: mockDB}, TableName: "api_keys"}, mockDB
+}
+
+func TestSave(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo, mockDB := newTestRepository(ctrl)
+	key := domain.APIKey{ID: "keyid", Name: "ci", Key: "plainkey", KeyHash: "keyhash", Scopes: []string{domain.ScopeSecretsCreate}, CreatedAt: time.Now().UTC()}
+
+	// The plain key is never part of the item
+	mockDB.EXPECT().PutItem(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, input *dynamodb.PutItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+		assert.Equal(t, "attribute_not_exists(#id)", *input.ConditionExpression)
+		assert.NotContains(t, input.Item, "key")
+		assert.Equal(t, &types.AttributeValueMemberS{Value: "keyhash"}, input.Item["keyHash"])
+		assert.NotContains(t, input.Item, "revokedAt")
+		return &dynamodb.PutItemOutput{}, nil
+	})
+	assert.NoError(t, repo.Save(context.Background(), key))
+
+	// Test case: The id is taken
+	mockDB.EXPECT().PutItem(gomock.Any(), gomock.Any()).Return(nil, &types.ConditionalCheckFailedException{})
+	assert.ErrorIs(t, repo.Save(context.Background(), key), domain.ErrAPIKeyAlreadyExists)
+
+	// Test case: DynamoDB is unavailable
+	mockDB.EXPECT().PutItem(gomock.Any(), gomock.Any()).Return(nil, errors.New("throttled"))
+	assert.ErrorIs(t, repo.Save(context.Background(), key), domain.ErrUnavailable)
+}
+
+func TestGetByID(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo, mockDB := newTestRepository(ctrl)
+
+	revokedAt := time.Now().UTC().Truncate(time.Second)
+	item, err := attributevalue.MarshalMap(domain.APIKey{ID: "keyid", KeyHash: "keyhash", Scopes: []string{domain.ScopeAdmin}, RevokedAt: &revokedAt})
+	assert.NoError(t, err)
+
+	mockDB.EXPECT().GetItem(gomock.Any(), gomock.Any()).Return(&dynamodb.GetItemOutput{Item: item}, nil)
+	key, err := repo.GetByID(context.Background(), "keyid")
+	assert.NoError(t, err)
+	assert.Equal(t, "keyhash", key.KeyHash)
+	assert.True(t, key.HasScope(domain.ScopeAdmin))
+	assert.True(t, key.Revoked())
+
+	// Test case: There is no key for the id
+	mockDB.EXPECT().GetItem(gomock.Any(), gomock.Any()).Return(&dynamodb.GetItemOutput{}, nil)
+	_, err = repo.GetByID(context.Background(), "keyid")
+	assert.ErrorIs(t, err, domain.ErrAPIKeyNotFound)
+}
+
+func TestRevoke(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected error
+	}{
+		{name: "revoked"},
+		{name: "revoked already", err: &types.ConditionalCheckFailedException{Item: map[string]types.AttributeValue{"id": &types.AttributeValueMemberS{Value: "keyid"}}}},
+		{name: "not found", err: &types.ConditionalCheckFailedException{}, expected: domain.ErrAPIKeyNotFound},
+		{name: "unavailable", err: errors.New("throttled"), expected: domain.ErrUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo, mockDB := newTestRepository(ctrl)
+			mockDB.EXPECT().UpdateItem(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, input *dynamodb.UpdateItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+				assert.Equal(t, "attribute_exists(#id) AND attribute_not_exists(revokedAt)", *input.ConditionExpression)
+				if tt.err != nil {
+					return nil, tt.err
+				}
+				return &dynamodb.UpdateItemOutput{}, nil
+			})
+
+			err := repo.Revoke(context.Background(), "keyid", time.Now())
+			if tt.expected == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.expected)
+			}
+		})
+	}
+}
+
+func TestListKeys(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo, mockDB := newTestRepository(ctrl)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	older, err := attributevalue.MarshalMap(domain.APIKey{ID: "older", CreatedAt: now.Add(-time.Hour)})
+	assert.NoError(t, err)
+	newer, err := attributevalue.MarshalMap(domain.APIKey{ID: "newer", CreatedAt: now})
+	assert.NoError(t, err)
+
+	// Every page is read and the keys are sorted by their creation time
+	lastKey := map[string]types.AttributeValue{"id": &types.AttributeValueMemberS{Value: "newer"}}
+	gomock.InOrder(
+		mockDB.EXPECT().Scan(gomock.Any(), gomock.Any()).Return(&dynamodb.ScanOutput{Items: []map[string]types.AttributeValue{newer}, LastEvaluatedKey: lastKey}, nil),
+		mockDB.EXPECT().Scan(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, input *dynamodb.ScanInput, _ ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+			assert.Equal(t, lastKey, input.ExclusiveStartKey)
+			return &dynamodb.ScanOutput{Items: []map[string]types.AttributeValue{older}}, nil
+		}),
+	)
+
+	keys, err := repo.ListKeys(context.Background())
+	assert.NoError(t, err)
+	if assert.Len(t, keys, 2) {
+		assert.Equal(t, "older", keys[0].ID)
+		assert.Equal(t, "newer", keys[1].ID)
+	}
+
+	// Test case: DynamoDB is unavailable
+	mockDB.EXPECT().Scan(gomock.Any(), gomock.Any()).Return(nil, errors.New("throttled"))
+	_, err = repo.ListKeys(context.Background())
+	assert.ErrorIs(t, err, domain.ErrUnavailable)
+}
@@ -0,0 +1,14 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/nalawade41/secret-server/internal/apikey/repository/repositorytest"
+	"github.com/nalawade41/secret-server/internal/domain"
+)
+
+func TestConformance(t *testing.T) {
+	repositorytest.Run(t, func(t *testing.T) domain.APIKeyRepository {
+		return NewAPIKeyRepository()
+	})
+}
@@ -0,0 +1,91 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/nalawade41/secret-server/internal/domain"
+)
+
+// APIKeyRepository keeps API keys in process memory, keys are lost on restart
+type APIKeyRepository struct {
+	mu   sync.Mutex
+	keys map[string]domain.APIKey
+}
+
+// NewAPIKeyRepository creates an empty in memory API key repository
+func NewAPIKeyRepository() *APIKeyRepository {
+	return &APIKeyRepository{
+		keys: make(map[string]domain.APIKey),
+	}
+}
+
+func (r *APIKeyRepository) Save(_ context.Context, key domain.APIKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.keys[key.ID]; ok {
+		return domain.ErrAPIKeyAlreadyExists
+	}
+
+	// The plain key is never persisted, same as for the other repositories
+	key.Key = ""
+	key.Scopes = append([]string(nil), key.Scopes...)
+	r.keys[key.ID] = key
+
+	return nil
+}
+
+func (r *APIKeyRepository) GetByID(_ context.Context, id string) (domain.APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key, ok := r.keys[id]
+	if !ok {
+		return domain.APIKey{}, domain.ErrAPIKeyNotFound
+	}
+
+	return key, nil
+}
+
+// Revoke sets the revocation time under the lock, unless the key is revoked already
+func (r *APIKeyRepository) Revoke(_ context.Context, id string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key, ok := r.keys[id]
+	if !ok {
+		return domain.ErrAPIKeyNotFound
+	}
+
+	if key.RevokedAt == nil {
+		at = at.UTC()
+		key.RevokedAt = &at
+		r.keys[id] = key
+	}
+
+	return nil
+}
+
+func (r *APIKeyRepository) ListKeys(_ context.Context) ([]domain.APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	keys := make([]domain.APIKey, 0, len(r.keys))
+	for _, key := range r.keys {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].CreatedAt.Equal(keys[j].CreatedAt) {
+			return keys[i].ID < keys[j].ID
+		}
+		return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+	})
+
+	return keys, nil
+}
+
+var _ domain.APIKeyRepository = (*APIKeyRepository)(nil)
@@ -0,0 +1,178 @@
+// Package repositorytest holds the conformance suite every domain.APIKeyRepository has to pass.
+// A new storage backend runs the suite from its tests:
+//
+//	func TestConformance(t *testing.T) {
+//		repositorytest.Run(t, func(t *testing.T) domain.APIKeyRepository { return newRepository(t) })
+//	}
+package repositorytest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/nalawade41/secret-server/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Factory returns the repository under test. It may return the same repository for every test,
+// the tests only work on keys with their own random ids.
+type Factory func(t *testing.T) domain.APIKeyRepository
+
+// Run runs the conformance suite against the repositories created by newRepository
+func Run(t *testing.T, newRepository Factory) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, repo domain.APIKeyRepository)
+	}{
+		{"SaveAndGetByID", testSaveAndGetByID},
+		{"SaveAlreadyExists", testSaveAlreadyExists},
+		{"NotFound", testNotFound},
+		{"Revoke", testRevoke},
+		{"ListKeys", testListKeys},
+		{"Tenant", testTenant},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newRepository(t))
+		})
+	}
+}
+
+// newKey returns a key with a random id and every persisted field set
+func newKey(t *testing.T) domain.APIKey {
+	b := make([]byte, 12)
+	_, err := rand.Read(b)
+	require.NoError(t, err)
+
+	// Backends store times with different precision, whole seconds survive all of them
+	return domain.APIKey{
+		ID:        hex.EncodeToString(b),
+		Name:      "ci pipeline",
+		Key:       "plainkey",
+		KeyHash:   "keyhash",
+		Scopes:    []string{domain.ScopeSecretsCreate, domain.ScopeAdmin},
+		CreatedAt: time.Now().UTC().Truncate(time.Second),
+	}
+}
+
+func testSaveAndGetByID(t *testing.T, repo domain.APIKeyRepository) {
+	ctx := context.Background()
+	key := newKey(t)
+	require.NoError(t, repo.Save(ctx, key))
+
+	stored, err := repo.GetByID(ctx, key.ID)
+	require.NoError(t, err)
+	assert.Equal(t, key.ID, stored.ID)
+	assert.Equal(t, key.Name, stored.Name)
+	assert.Equal(t, key.KeyHash, stored.KeyHash)
+	assert.Equal(t, key.Scopes, stored.Scopes)
+	assert.True(t, key.CreatedAt.Equal(stored.CreatedAt), "created at %v, stored %v", key.CreatedAt, stored.CreatedAt)
+	assert.Nil(t, stored.RevokedAt)
+
+	// The plain key is only handed out once
+	assert.Empty(t, stored.Key)
+}
+
+func testSaveAlreadyExists(t *testing.T, repo domain.APIKeyRepository) {
+	ctx := context.Background()
+	key := newKey(t)
+	require.NoError(t, repo.Save(ctx, key))
+
+	other := key
+	other.KeyHash = "otherhash"
+	assert.ErrorIs(t, repo.Save(ctx, other), domain.ErrAPIKeyAlreadyExists)
+
+	stored, err := repo.GetByID(ctx, key.ID)
+	require.NoError(t, err)
+	assert.Equal(t, key.KeyHash, stored.KeyHash, "A taken id must not be overwritten")
+}
+
+func testNotFound(t *testing.T, repo domain.APIKeyRepository) {
+	ctx := context.Background()
+	id := newKey(t).ID
+
+	_, err := repo.GetByID(ctx, id)
+	assert.ErrorIs(t, err, domain.ErrAPIKeyNotFound)
+
+	assert.ErrorIs(t, repo.Revoke(ctx, id, time.Now()), domain.ErrAPIKeyNotFound)
+}
+
+func testRevoke(t *testing.T, repo domain.APIKeyRepository) {
+	ctx := context.Background()
+	key := newKey(t)
+	require.NoError(t, repo.Save(ctx, key))
+
+	revokedAt := time.Now().UTC().Truncate(time.Second)
+	require.NoError(t, repo.Revoke(ctx, key.ID, revokedAt))
+
+	stored, err := repo.GetByID(ctx, key.ID)
+	require.NoError(t, err)
+	if assert.NotNil(t, stored.RevokedAt) {
+		assert.True(t, revokedAt.Equal(*stored.RevokedAt))
+	}
+
+	// Revoking again keeps the first revocation time
+	require.NoError(t, repo.Revoke(ctx, key.ID, revokedAt.Add(time.Hour)))
+
+	stored, err = repo.GetByID(ctx, key.ID)
+	require.NoError(t, err)
+	if assert.NotNil(t, stored.RevokedAt) {
+		assert.True(t, revokedAt.Equal(*stored.RevokedAt))
+	}
+}
+
+func testListKeys(t *testing.T, repo domain.APIKeyRepository) {
+	ctx := context.Background()
+
+	first := newKey(t)
+	first.CreatedAt = first.CreatedAt.Add(-time.Minute)
+	second := newKey(t)
+	require.NoError(t, repo.Save(ctx, second))
+	require.NoError(t, repo.Save(ctx, first))
+	require.NoError(t, repo.Revoke(ctx, second.ID, time.Now()))
+
+	keys, err := repo.ListKeys(ctx)
+	require.NoError(t, err)
+
+	// The repository may be shared with other tests, only the keys of this test are checked
+	var listed []domain.APIKey
+	for _, key := range keys {
+		if key.ID == first.ID || key.ID == second.ID {
+			listed = append(listed, key)
+		}
+	}
+
+	if assert.Len(t, listed, 2) {
+		assert.Equal(t, first.ID, listed[0].ID, "Keys are listed by their creation time")
+		assert.Nil(t, listed[0].RevokedAt)
+		assert.Equal(t, second.ID, listed[1].ID)
+		assert.NotNil(t, listed[1].RevokedAt, "Revoked keys are listed as well")
+		assert.Empty(t, listed[1].Key)
+	}
+}
+
+func testTenant(t *testing.T, repo domain.APIKeyRepository) {
+	ctx := context.Background()
+
+	// Keys issued before tenants are keys of the default tenant
+	key := newKey(t)
+	require.NoError(t, repo.Save(ctx, key))
+
+	stored, err := repo.GetByID(ctx, key.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.DefaultTenant, stored.Tenant)
+
+	key = newKey(t)
+	key.Scopes = []string{domain.ScopeSecretsCreate}
+	key.Tenant = "team-a"
+	require.NoError(t, repo.Save(ctx, key))
+
+	stored, err = repo.GetByID(ctx, key.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "team-a", stored.Tenant)
+}
@@ -0,0 +1,138 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/nalawade41/secret-server/internal/domain"
+	secretsql "github.com/nalawade41/secret-server/internal/secret/repository/sqldb"
+	"github.com/pkg/errors"
+)
+
+// apiKeyColumns are the columns of an API key in the order scanAPIKey reads them
+const apiKeyColumns = "id, name, key_hash, scopes, created_at, revoked_at, tenant"
+
+// APIKeyRepository stores API keys in a table of an SQLite or PostgreSQL database
+type APIKeyRepository struct {
+	DB        *sql.DB
+	Dialect   secretsql.Dialect
+	TableName string
+}
+
+// scanner is implemented by *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// query fills the table name into the query and adapts its placeholders to the dialect
+func (r APIKeyRepository) query(format string) string {
+	return r.Dialect.Rebind(fmt.Sprintf(format, r.TableName))
+}
+
+func (r APIKeyRepository) Save(ctx context.Context, key domain.APIKey) error {
+	scopes, err := json.Marshal(key.Scopes)
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("failed to marshal scopes: %v", err))
+	}
+
+	// The conflict clause turns a taken id into zero inserted rows, the plain key has no column
+	result, err := r.DB.ExecContext(ctx, r.query("INSERT INTO %s (id, name, key_hash, scopes, created_at, tenant) VALUES (?, ?, ?, ?, ?, ?) ON CONFLICT (id) DO NOTHING"),
+		key.ID, key.Name, key.KeyHash, string(scopes), key.CreatedAt.UTC(), key.Tenant)
+	if err != nil {
+		return errors.Wrap(domain.Unavailable(err), fmt.Sprintf("failed to insert api key: %v", err))
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return errors.Wrap(domain.Unavailable(err), fmt.Sprintf("failed to insert api key: %v", err))
+	}
+	if rows == 0 {
+		return errors.Wrap(domain.ErrAPIKeyAlreadyExists, fmt.Sprintf("failed to insert api key for id: %s", key.ID))
+	}
+
+	return nil
+}
+
+func (r APIKeyRepository) GetByID(ctx context.Context, id string) (domain.APIKey, error) {
+	key, err := scanAPIKey(r.DB.QueryRowContext(ctx, r.query("SELECT "+apiKeyColumns+" FROM %s WHERE id = ?"), id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.APIKey{}, domain.ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return domain.APIKey{}, errors.Wrap(domain.Unavailable(err), fmt.Sprintf("failed to retrieve api key for id: %s", id))
+	}
+
+	return key, nil
+}
+
+// Revoke only sets the revocation time of a key that is not revoked yet, no updated row means the key
+// is revoked already or does not exist
+func (r APIKeyRepository) Revoke(ctx context.Context, id string, at time.Time) error {
+	result, err := r.DB.ExecContext(ctx, r.query("UPDATE %s SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL"), at.UTC(), id)
+	if err != nil {
+		return errors.Wrap(domain.Unavailable(err), fmt.Sprintf("failed to revoke api key for id: %s", id))
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return errors.Wrap(domain.Unavailable(err), fmt.Sprintf("failed to revoke api key for id: %s", id))
+	}
+	if rows > 0 {
+		return nil
+	}
+
+	_, err = r.GetByID(ctx, id)
+	return err
+}
+
+func (r APIKeyRepository) ListKeys(ctx context.Context) ([]domain.APIKey, error) {
+	rows, err := r.DB.QueryContext(ctx, r.query("SELECT "+apiKeyColumns+" FROM %s ORDER BY created_at, id"))
+	if err != nil {
+		return nil, errors.Wrap(domain.Unavailable(err), fmt.Sprintf("failed to list api keys: %v", err))
+	}
+	defer rows.Close()
+
+	keys := []domain.APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, errors.Wrap(domain.Unavailable(err), fmt.Sprintf("failed to read api key: %v", err))
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(domain.Unavailable(err), fmt.Sprintf("failed to list api keys: %v", err))
+	}
+
+	return keys, nil
+}
+
+// scanAPIKey reads the columns of apiKeyColumns
+func scanAPIKey(row scanner) (domain.APIKey, error) {
+	var (
+		key       domain.APIKey
+		scopes    string
+		revokedAt sql.NullTime
+	)
+
+	if err := row.Scan(&key.ID, &key.Name, &key.KeyHash, &scopes, &key.CreatedAt, &revokedAt, &key.Tenant); err != nil {
+		return domain.APIKey{}, err
+	}
+
+	if err := json.Unmarshal([]byte(scopes), &key.Scopes); err != nil {
+		return domain.APIKey{}, errors.Wrap(err, fmt.Sprintf("failed to unmarshal scopes: %v", err))
+	}
+
+	key.CreatedAt = key.CreatedAt.UTC()
+	if revokedAt.Valid {
+		at := revokedAt.Time.UTC()
+		key.RevokedAt = &at
+	}
+
+	return key, nil
+}
+
+var _ domain.APIKeyRepository = (*APIKeyRepository)(nil)
@@ -0,0 +1,110 @@
+package requests
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nalawade41/secret-server/internal/domain"
+)
+
+const (
+	// maxNameLength keeps names short enough to show in a list
+	maxNameLength = 100
+
+	// maxBodySize is far more than a name and every scope need
+	maxBodySize = 4 * 1024
+)
+
+type IssueKeyRequest struct {
+	// Name tells the keys apart, for example the client or pipeline using it
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+	// Tenant restricts the key to the routes of the tenant, empty for the default routes
+	Tenant string `json:"tenant,omitempty"`
+}
+
+// Bind reads the request from a JSON body, unknown fields and bodies larger than maxBodySize are rejected
+func (r *IssueKeyRequest) Bind(req *http.Request) error {
+	contentType, _, err := mime.ParseMediaType(req.Header.Get(echo.HeaderContentType))
+	if err != nil || contentType != echo.MIMEApplicationJSON {
+		return echo.NewHTTPError(http.StatusUnsupportedMediaType, "the content type must be application/json")
+	}
+
+	decoder := json.NewDecoder(http.MaxBytesReader(nil, req.Body, maxBodySize))
+	decoder.DisallowUnknownFields()
+
+	err = decoder.Decode(r)
+	if err == nil {
+		if _, tokenErr := decoder.Token(); !errors.Is(tokenErr, io.EOF) {
+			err = errors.New("unexpected data after the JSON object")
+		}
+	}
+
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return echo.NewHTTPError(http.StatusRequestEntityTooLarge, fmt.Sprintf("the request body is larger than %d bytes", maxBodySize))
+	}
+	if err != nil {
+		return &domain.Error{Kind: domain.ErrValidation, Code: "malformed_request", Message: "error parsing data", Err: err}
+	}
+
+	return nil
+}
+
+// Validate method to validate the request, every invalid field is reported and not only the first one.
+// tenants are the configured tenants, a key can only be issued for one of them.
+func (r IssueKeyRequest) Validate(tenants map[string]domain.TenantPolicy) error {
+	var fields []domain.FieldError
+	invalid := func(field string, message string) {
+		fields = append(fields, domain.FieldError{Field: field, Message: message})
+	}
+
+	if r.Name == "" {
+		invalid("name", "name is required")
+	} else if len(r.Name) > maxNameLength {
+		invalid("name", fmt.Sprintf("name is longer than %d characters", maxNameLength))
+	}
+
+	if len(r.Scopes) == 0 {
+		invalid("scopes", "at least one scope is required")
+	}
+	for _, scope := range r.Scopes {
+		if !knownScope(scope) {
+			invalid("scopes", fmt.Sprintf("unknown scope %q", scope))
+		}
+	}
+
+	if r.Tenant != domain.DefaultTenant {
+		if _, ok := tenants[r.Tenant]; !ok {
+			invalid("tenant", fmt.Sprintf("unknown tenant %q", r.Tenant))
+		}
+
+		// Admins manage the keys of every tenant, a tenant can not have one of its own
+		for _, scope := range r.Scopes {
+			if scope == domain.ScopeAdmin {
+				invalid("scopes", "the admin scope can not be issued for a tenant")
+			}
+		}
+	}
+
+	if len(fields) > 0 {
+		return domain.NewValidationError(fields)
+	}
+
+	return nil
+}
+
+// knownScope reports whether a key can be issued with the scope
+func knownScope(scope string) bool {
+	for _, s := range domain.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
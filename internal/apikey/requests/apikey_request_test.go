@@ -0,0 +1,84 @@
+package requests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nalawade41/secret-server/internal/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBind(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantStatus  int
+		wantErr     error
+	}{
+		{name: "valid body", contentType: echo.MIMEApplicationJSON, body: `{"name":"ci","scopes":["secrets:create"]}`},
+		{name: "unknown field", contentType: echo.MIMEApplicationJSON, body: `{"name":"ci","admin":true}`, wantErr: domain.ErrValidation},
+		{name: "trailing data", contentType: echo.MIMEApplicationJSON, body: `{"name":"ci"}{}`, wantErr: domain.ErrValidation},
+		{name: "form body", contentType: echo.MIMEApplicationForm, body: `name=ci`, wantStatus: http.StatusUnsupportedMediaType},
+		{name: "body too large", contentType: echo.MIMEApplicationJSON, body: `{"name":"` + strings.Repeat("a", maxBodySize) + `"}`, wantStatus: http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/admin/keys", strings.NewReader(tt.body))
+			req.Header.Set(echo.HeaderContentType, tt.contentType)
+
+			var request IssueKeyRequest
+			err := request.Bind(req)
+
+			switch {
+			case tt.wantStatus != 0:
+				var httpErr *echo.HTTPError
+				if assert.ErrorAs(t, err, &httpErr) {
+					assert.Equal(t, tt.wantStatus, httpErr.Code)
+				}
+			case tt.wantErr != nil:
+				assert.ErrorIs(t, err, tt.wantErr)
+			default:
+				assert.NoError(t, err)
+				assert.Equal(t, IssueKeyRequest{Name: "ci", Scopes: []string{domain.ScopeSecretsCreate}}, request)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, IssueKeyRequest{Name: "ci", Scopes: []string{domain.ScopeSecretsCreate, domain.ScopeAdmin}}.Validate(nil))
+
+	err := IssueKeyRequest{Name: strings.Repeat("a", maxNameLength+1), Scopes: []string{"secrets:read"}}.Validate(nil)
+
+	var validationErr *domain.Error
+	if assert.ErrorAs(t, err, &validationErr) {
+		assert.Len(t, validationErr.Fields, 2, "Every invalid field is reported")
+	}
+
+	err = IssueKeyRequest{}.Validate(nil)
+	if assert.ErrorAs(t, err, &validationErr) {
+		assert.Len(t, validationErr.Fields, 2)
+	}
+}
+
+func TestValidate_Tenant(t *testing.T) {
+	tenants := map[string]domain.TenantPolicy{"team-a": {}}
+
+	assert.NoError(t, IssueKeyRequest{Name: "ci", Scopes: []string{domain.ScopeSecretsCreate}, Tenant: "team-a"}.Validate(tenants))
+
+	var validationErr *domain.Error
+	err := IssueKeyRequest{Name: "ci", Scopes: []string{domain.ScopeSecretsCreate}, Tenant: "team-b"}.Validate(tenants)
+	if assert.ErrorAs(t, err, &validationErr) {
+		assert.Equal(t, "tenant", validationErr.Fields[0].Field)
+	}
+
+	err = IssueKeyRequest{Name: "ci", Scopes: []string{domain.ScopeAdmin}, Tenant: "team-a"}.Validate(tenants)
+	if assert.ErrorAs(t, err, &validationErr) {
+		assert.Equal(t, "scopes", validationErr.Fields[0].Field, "A tenant can not have an admin key")
+	}
+}
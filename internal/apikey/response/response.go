@@ -0,0 +1,53 @@
+package response
+
+import (
+	"encoding/xml"
+	"time"
+
+	"github.com/nalawade41/secret-server/internal/domain"
+)
+
+type APIKeyResponse struct {
+	ID   string `xml:"id" json:"id" yaml:"id"`
+	Name string `xml:"name" json:"name" yaml:"name"`
+	// Key is only returned when the key is issued, it can not be retrieved again
+	Key       string     `xml:"key,omitempty" json:"key,omitempty" yaml:"key,omitempty"`
+	Scopes    []string   `xml:"scopes>scope" json:"scopes" yaml:"scopes"`
+	Tenant    string     `xml:"tenant,omitempty" json:"tenant,omitempty" yaml:"tenant,omitempty"`
+	CreatedAt time.Time  `xml:"createdAt" json:"createdAt" yaml:"createdAt"`
+	RevokedAt *time.Time `xml:"revokedAt,omitempty" json:"revokedAt,omitempty" yaml:"revokedAt,omitempty"`
+}
+
+// NewAPIKeyResponse converts the key to APIKeyResponse
+func NewAPIKeyResponse(key domain.APIKey) APIKeyResponse {
+	scopes := key.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+
+	return APIKeyResponse{
+		ID:        key.ID,
+		Name:      key.Name,
+		Key:       key.Key,
+		Scopes:    scopes,
+		Tenant:    key.Tenant,
+		CreatedAt: key.CreatedAt,
+		RevokedAt: key.RevokedAt,
+	}
+}
+
+// APIKeyListResponse lists keys, the secret part of a key is never listed
+type APIKeyListResponse struct {
+	XMLName xml.Name         `xml:"apiKeys" json:"-" yaml:"-"`
+	Keys    []APIKeyResponse `xml:"apiKey" json:"keys" yaml:"keys"`
+}
+
+// NewAPIKeyListResponse converts the keys to APIKeyListResponse
+func NewAPIKeyListResponse(keys []domain.APIKey) APIKeyListResponse {
+	list := APIKeyListResponse{Keys: make([]APIKeyResponse, 0, len(keys))}
+	for _, key := range keys {
+		key.Key = ""
+		list.Keys = append(list.Keys, NewAPIKeyResponse(key))
+	}
+	return list
+}
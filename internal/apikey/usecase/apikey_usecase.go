@@ -0,0 +1,122 @@
+package usecase
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nalawade41/secret-server/internal/common/logger"
+	"github.com/nalawade41/secret-server/internal/domain"
+	"github.com/pkg/errors"
+)
+
+const (
+	// KeyPrefix starts every API key, so secret scanners and people can tell it apart from other tokens
+	KeyPrefix = "ssk_"
+
+	// keySeparator separates the id of the key from its secret part, neither id alphabet contains it
+	keySeparator = "_"
+
+	// maxIDAttempts is how often a new id is generated when the previous one was already taken
+	maxIDAttempts = 3
+)
+
+type APIKeyUseCase struct {
+	APIKeyRepo  domain.APIKeyRepository
+	Encryptor   domain.Encryptor
+	IDGenerator domain.IDGenerator
+}
+
+// IssueKey creates a key with the scopes for the tenant, DefaultTenant for a key of the default routes.
+// The full key is only returned here, the repository only ever sees the hash of its secret part.
+func (u APIKeyUseCase) IssueKey(ctx context.Context, name string, tenant string, scopes []string) (domain.APIKey, error) {
+	secretPart, err := u.Encryptor.GenerateKey()
+	if err != nil {
+		return domain.APIKey{}, errors.Wrap(err, fmt.Sprintf("failed to generate api key: %v", err))
+	}
+
+	key := domain.APIKey{
+		Name:      name,
+		KeyHash:   u.Encryptor.GenerateSHA256Hash(secretPart),
+		Scopes:    scopes,
+		CreatedAt: time.Now().UTC(),
+		Tenant:    tenant,
+	}
+
+	for attempt := 1; ; attempt++ {
+		key.ID, err = u.IDGenerator.GenerateID()
+		if err != nil {
+			return domain.APIKey{}, errors.Wrap(err, fmt.Sprintf("failed to generate api key id: %v", err))
+		}
+
+		err = u.APIKeyRepo.Save(ctx, key)
+		if err == nil {
+			break
+		}
+
+		if !errors.Is(err, domain.ErrAPIKeyAlreadyExists) || attempt == maxIDAttempts {
+			return domain.APIKey{}, errors.Wrap(err, fmt.Sprintf("failed to store api key: %v", err))
+		}
+
+		logger.Warnf("api key id collision on attempt %d, generating a new id", attempt)
+	}
+
+	if key.Tenant == domain.DefaultTenant {
+		logger.Infof("issued api key %s %q with scopes %s", key.ID, key.Name, strings.Join(key.Scopes, ","))
+	} else {
+		logger.Infof("issued api key %s %q of tenant %s with scopes %s", key.ID, key.Name, key.Tenant, strings.Join(key.Scopes, ","))
+	}
+
+	key.Key = KeyPrefix + key.ID + keySeparator + secretPart
+
+	return key, nil
+}
+
+// RevokeKey revokes the key, requests with it are rejected from then on
+func (u APIKeyUseCase) RevokeKey(ctx context.Context, id string) error {
+	if err := u.APIKeyRepo.Revoke(ctx, id, time.Now().UTC()); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("failed to revoke api key: %v", err))
+	}
+
+	logger.Infof("revoked api key %s", id)
+
+	return nil
+}
+
+// ListKeys returns every key without its secret part
+func (u APIKeyUseCase) ListKeys(ctx context.Context) ([]domain.APIKey, error) {
+	keys, err := u.APIKeyRepo.ListKeys(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("failed to list api keys: %v", err))
+	}
+
+	return keys, nil
+}
+
+// Authenticate looks the key up by its id and compares the hash of its secret part in constant time.
+// Malformed, unknown and revoked keys all fail the same way, so a caller can not probe for ids.
+func (u APIKeyUseCase) Authenticate(ctx context.Context, token string) (domain.APIKey, error) {
+	id, secretPart, ok := strings.Cut(strings.TrimPrefix(token, KeyPrefix), keySeparator)
+	if !strings.HasPrefix(token, KeyPrefix) || !ok || id == "" || secretPart == "" {
+		return domain.APIKey{}, domain.ErrInvalidAPIKey
+	}
+
+	key, err := u.APIKeyRepo.GetByID(ctx, id)
+	if errors.Is(err, domain.ErrAPIKeyNotFound) {
+		return domain.APIKey{}, domain.ErrInvalidAPIKey
+	}
+	if err != nil {
+		return domain.APIKey{}, errors.Wrap(err, fmt.Sprintf("failed to retrieve api key: %v", err))
+	}
+
+	hash := u.Encryptor.GenerateSHA256Hash(secretPart)
+	if subtle.ConstantTimeCompare([]byte(hash), []byte(key.KeyHash)) != 1 || key.Revoked() {
+		return domain.APIKey{}, domain.ErrInvalidAPIKey
+	}
+
+	return key, nil
+}
+
+var _ domain.APIKeyUseCase = (*APIKeyUseCase)(nil)
@@ -0,0 +1,139 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/nalawade41/secret-server/internal/common/security"
+	"github.com/nalawade41/secret-server/internal/domain"
+	"github.com/nalawade41/secret-server/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIssueKey_Success(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockAPIKeyRepository(ctrl)
+	mockEncryptor := mocks.NewMockEncryptor(ctrl)
+	mockIDGenerator := mocks.NewMockIDGenerator(ctrl)
+
+	useCase := APIKeyUseCase{APIKeyRepo: mockRepo, Encryptor: mockEncryptor, IDGenerator: mockIDGenerator}
+
+	mockEncryptor.EXPECT().GenerateKey().Return("secretpart", nil)
+	mockEncryptor.EXPECT().GenerateSHA256Hash("secretpart").Return("hashed")
+
+	// A taken id is replaced by a new one
+	mockIDGenerator.EXPECT().GenerateID().Return("taken", nil)
+	mockRepo.EXPECT().Save(gomock.Any(), gomock.Any()).Return(domain.ErrAPIKeyAlreadyExists)
+	mockIDGenerator.EXPECT().GenerateID().Return("abc123", nil)
+	mockRepo.EXPECT().Save(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, key domain.APIKey) error {
+		assert.Equal(t, "abc123", key.ID)
+		assert.Equal(t, "hashed", key.KeyHash)
+		assert.Equal(t, "team-a", key.Tenant)
+		assert.Empty(t, key.Key, "The plain key must never reach the repository")
+		return nil
+	})
+
+	key, err := useCase.IssueKey(context.Background(), "ci", "team-a", []string{domain.ScopeSecretsCreate})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "ssk_abc123_secretpart", key.Key)
+	assert.Equal(t, "ci", key.Name)
+	assert.Equal(t, []string{domain.ScopeSecretsCreate}, key.Scopes)
+	assert.Equal(t, "team-a", key.Tenant)
+	assert.False(t, key.CreatedAt.IsZero())
+}
+
+func TestIssueKey_RepoError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockAPIKeyRepository(ctrl)
+	mockEncryptor := mocks.NewMockEncryptor(ctrl)
+	mockIDGenerator := mocks.NewMockIDGenerator(ctrl)
+
+	useCase := APIKeyUseCase{APIKeyRepo: mockRepo, Encryptor: mockEncryptor, IDGenerator: mockIDGenerator}
+
+	mockEncryptor.EXPECT().GenerateKey().Return("secretpart", nil)
+	mockEncryptor.EXPECT().GenerateSHA256Hash("secretpart").Return("hashed")
+	mockIDGenerator.EXPECT().GenerateID().Return("taken", nil).Times(maxIDAttempts)
+	mockRepo.EXPECT().Save(gomock.Any(), gomock.Any()).Return(domain.ErrAPIKeyAlreadyExists).Times(maxIDAttempts)
+
+	_, err := useCase.IssueKey(context.Background(), "ci", domain.DefaultTenant, []string{domain.ScopeAdmin})
+
+	assert.ErrorIs(t, err, domain.ErrAPIKeyAlreadyExists)
+}
+
+func TestRevokeKey(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockAPIKeyRepository(ctrl)
+	useCase := APIKeyUseCase{APIKeyRepo: mockRepo}
+
+	mockRepo.EXPECT().Revoke(gomock.Any(), "abc123", gomock.Any()).Return(nil)
+	mockRepo.EXPECT().Revoke(gomock.Any(), "missing", gomock.Any()).Return(domain.ErrAPIKeyNotFound)
+
+	assert.NoError(t, useCase.RevokeKey(context.Background(), "abc123"))
+	assert.ErrorIs(t, useCase.RevokeKey(context.Background(), "missing"), domain.ErrAPIKeyNotFound)
+}
+
+func TestAuthenticate(t *testing.T) {
+	encryptor := security.RealEncryptor{}
+	revokedAt := time.Now()
+
+	stored := map[string]domain.APIKey{
+		"abc123":  {ID: "abc123", KeyHash: encryptor.GenerateSHA256Hash("secretpart"), Scopes: []string{domain.ScopeSecretsCreate}},
+		"revoked": {ID: "revoked", KeyHash: encryptor.GenerateSHA256Hash("secretpart"), RevokedAt: &revokedAt},
+	}
+	unavailable := errors.New("connection refused")
+
+	tests := []struct {
+		name    string
+		key     string
+		wantID  string
+		wantErr error
+	}{
+		{name: "valid key", key: "ssk_abc123_secretpart", wantID: "abc123"},
+		{name: "wrong secret part", key: "ssk_abc123_otherpart", wantErr: domain.ErrInvalidAPIKey},
+		{name: "revoked key", key: "ssk_revoked_secretpart", wantErr: domain.ErrInvalidAPIKey},
+		{name: "unknown id", key: "ssk_missing_secretpart", wantErr: domain.ErrInvalidAPIKey},
+		{name: "missing prefix", key: "abc123_secretpart", wantErr: domain.ErrInvalidAPIKey},
+		{name: "missing secret part", key: "ssk_abc123", wantErr: domain.ErrInvalidAPIKey},
+		{name: "empty id", key: "ssk__secretpart", wantErr: domain.ErrInvalidAPIKey},
+		{name: "repository unavailable", key: "ssk_down_secretpart", wantErr: unavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockRepo := mocks.NewMockAPIKeyRepository(ctrl)
+			mockRepo.EXPECT().GetByID(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, id string) (domain.APIKey, error) {
+				if id == "down" {
+					return domain.APIKey{}, unavailable
+				}
+				key, ok := stored[id]
+				if !ok {
+					return domain.APIKey{}, domain.ErrAPIKeyNotFound
+				}
+				return key, nil
+			}).AnyTimes()
+
+			useCase := APIKeyUseCase{APIKeyRepo: mockRepo, Encryptor: encryptor}
+			key, err := useCase.Authenticate(context.Background(), tt.key)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantID, key.ID)
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package responses
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// MIMETextXML is the older media type of XML some clients still ask for
+	MIMETextXML = "text/xml"
+
+	// MIMEApplicationYAML is the media type of YAML, see RFC 9512
+	MIMEApplicationYAML = "application/yaml"
+
+	// MIMEApplicationXYAML and MIMETextYAML are unregistered media types of YAML that are in common use
+	MIMEApplicationXYAML = "application/x-yaml"
+	MIMETextYAML         = "text/yaml"
+)
+
+// offers are the media types of responses in the order the server prefers them, the first acceptable one is used
+// when the client accepts several with the same quality. Plain text is only offered for data that has a text form.
+var offers = []string{
+	echo.MIMEApplicationJSON,
+	echo.MIMEApplicationXML,
+	MIMEApplicationYAML,
+	echo.MIMETextPlain,
+	MIMETextXML,
+	MIMEApplicationXYAML,
+	MIMETextYAML,
+}
+
+// problemOffers are the media types of problem details, the XML ones are answered with application/problem+xml
+var problemOffers = []string{
+	MIMEApplicationProblemJSON,
+	echo.MIMEApplicationJSON,
+	MIMEApplicationProblemXML,
+	echo.MIMEApplicationXML,
+	MIMETextXML,
+}
+
+// mediaRange is a media range of the Accept header with its quality
+type mediaRange struct {
+	mediaType string
+	subtype   string
+	quality   float64
+}
+
+// Negotiate picks the media type of the response from the Accept header of the request, see RFC 7231 section 5.3.2.
+// text tells whether the response has a plain text form. An error with the status 406 is returned when the
+// client accepts none of the media types, handlers with side effects negotiate before doing anything.
+func Negotiate(c echo.Context, text bool) (string, error) {
+	available := offers
+	if !text {
+		available = make([]string, 0, len(offers)-1)
+		for _, offer := range offers {
+			if offer != echo.MIMETextPlain {
+				available = append(available, offer)
+			}
+		}
+	}
+
+	mediaType := negotiate(c.Request().Header.Get(echo.HeaderAccept), available)
+	if mediaType == "" {
+		return "", echo.NewHTTPError(http.StatusNotAcceptable, "supported media types are "+strings.Join(available, ", "))
+	}
+
+	return mediaType, nil
+}
+
+// negotiate returns the offer with the highest quality in the Accept header, an empty string if none is acceptable.
+// A missing or unparsable header accepts everything.
+func negotiate(header string, offers []string) string {
+	ranges := parseAccept(header)
+	if len(ranges) == 0 {
+		return offers[0]
+	}
+
+	best, bestQuality := "", 0.0
+	for _, offer := range offers {
+		if q := quality(ranges, offer); q > bestQuality {
+			best, bestQuality = offer, q
+		}
+	}
+
+	return best
+}
+
+// parseAccept parses the media ranges of an Accept header, malformed ranges are skipped.
+// Parameters other than the quality are ignored.
+func parseAccept(header string) []mediaRange {
+	var ranges []mediaRange
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+
+		mediaType, subtype, ok := strings.Cut(strings.ToLower(strings.TrimSpace(params[0])), "/")
+		if !ok || mediaType == "" || subtype == "" || (mediaType == "*" && subtype != "*") {
+			continue
+		}
+
+		r := mediaRange{mediaType: mediaType, subtype: subtype, quality: 1}
+		for _, param := range params[1:] {
+			name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if !strings.EqualFold(strings.TrimSpace(name), "q") {
+				continue
+			}
+
+			q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil || q < 0 || q > 1 {
+				ok = false
+				break
+			}
+			r.quality = q
+		}
+		if ok {
+			ranges = append(ranges, r)
+		}
+	}
+
+	return ranges
+}
+
+// quality returns the quality of the media type, taken from the most specific media range that matches it
+func quality(ranges []mediaRange, offer string) float64 {
+	mediaType, subtype, _ := strings.Cut(offer, "/")
+
+	q, specificity := 0.0, -1
+	for _, r := range ranges {
+		var s int
+		switch {
+		case r.mediaType == mediaType && r.subtype == subtype:
+			s = 2
+		case r.mediaType == mediaType && r.subtype == "*":
+			s = 1
+		case r.mediaType == "*":
+			s = 0
+		default:
+			continue
+		}
+
+		if s > specificity {
+			q, specificity = r.quality, s
+		}
+	}
+
+	return q
+}
@@ -0,0 +1,72 @@
+package responses
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestNegotiate tests the media type picked for various Accept headers
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		name     string
+		accept   string
+		text     bool
+		expected string
+	}{
+		{name: "no accept header", accept: "", expected: echo.MIMEApplicationJSON},
+		{name: "anything", accept: "*/*", expected: echo.MIMEApplicationJSON},
+		{name: "json", accept: "application/json", expected: echo.MIMEApplicationJSON},
+		{name: "xml", accept: "application/xml", expected: echo.MIMEApplicationXML},
+		{name: "text xml", accept: "text/xml", expected: MIMETextXML},
+		{name: "xml with charset", accept: "application/xml; charset=utf-8", expected: echo.MIMEApplicationXML},
+		{name: "case insensitive", accept: "Application/XML", expected: echo.MIMEApplicationXML},
+		{name: "xml preferred over anything", accept: "application/xml;q=0.9, */*;q=0.8", expected: echo.MIMEApplicationXML},
+		{name: "anything preferred over xml", accept: "application/xml;q=0.9, */*", expected: echo.MIMEApplicationJSON},
+		{name: "highest quality wins", accept: "application/json;q=0.5, application/yaml;q=0.8, application/xml;q=0.7", expected: MIMEApplicationYAML},
+		{name: "most specific range wins", accept: "application/*;q=0.1, application/xml;q=1, */*;q=0.5", expected: echo.MIMEApplicationXML},
+		{name: "excluded by quality zero", accept: "application/json;q=0, */*", expected: echo.MIMEApplicationXML},
+		{name: "yaml alias", accept: "application/x-yaml", expected: MIMEApplicationXYAML},
+		{name: "text yaml", accept: "text/yaml", expected: MIMETextYAML},
+		{name: "plain text", accept: "text/plain", text: true, expected: echo.MIMETextPlain},
+		{name: "any text prefers plain", accept: "text/*", text: true, expected: echo.MIMETextPlain},
+		{name: "any text without text form", accept: "text/*", expected: MIMETextXML},
+		{name: "browser", accept: "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", expected: echo.MIMEApplicationXML},
+		{name: "malformed range skipped", accept: "json, application/yaml", expected: MIMEApplicationYAML},
+		{name: "invalid quality skipped", accept: "application/xml;q=2, application/yaml", expected: MIMEApplicationYAML},
+		{name: "only malformed ranges", accept: "garbage", expected: echo.MIMEApplicationJSON},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set(echo.HeaderAccept, tt.accept)
+			c := e.NewContext(req, httptest.NewRecorder())
+
+			mediaType, err := Negotiate(c, tt.text)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, mediaType)
+		})
+	}
+}
+
+// TestNegotiate_NotAcceptable tests that a 406 is returned when no media type is acceptable
+func TestNegotiate_NotAcceptable(t *testing.T) {
+	for _, accept := range []string{"text/html", "text/plain", "application/json;q=0"} {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(echo.HeaderAccept, accept)
+		c := e.NewContext(req, httptest.NewRecorder())
+
+		_, err := Negotiate(c, false)
+
+		var httpErr *echo.HTTPError
+		if assert.ErrorAs(t, err, &httpErr, accept) {
+			assert.Equal(t, http.StatusNotAcceptable, httpErr.Code)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package responses
+
+import (
+	"encoding/xml"
+
+	"github.com/nalawade41/secret-server/internal/domain"
+)
+
+const (
+	// MIMEApplicationProblemJSON is the content type of problem details in JSON, see RFC 7807
+	MIMEApplicationProblemJSON = "application/problem+json"
+
+	// MIMEApplicationProblemXML is the content type of problem details in XML, see RFC 7807
+	MIMEApplicationProblemXML = "application/problem+xml"
+
+	// ProblemTypeBlank is the type of problems that have no meaning beyond their HTTP status
+	ProblemTypeBlank = "about:blank"
+
+	// problemTypePrefix is followed by the error code to build the type URI of a problem
+	problemTypePrefix = "urn:secret-server:problem:"
+)
+
+// Problem represents the error for UI as problem details, see RFC 7807
+type Problem struct {
+	XMLName xml.Name `json:"-" xml:"urn:ietf:rfc:7807 problem"`
+	// Type identifies the kind of problem, the errorCode is the same identifier without the URN prefix
+	Type     string `json:"type" xml:"type"`
+	Title    string `json:"title" xml:"title"`
+	Status   int    `json:"status" xml:"status"`
+	Detail   string `json:"detail,omitempty" xml:"detail,omitempty"`
+	Instance string `json:"instance,omitempty" xml:"instance,omitempty"`
+	// ErrorCode is a stable machine readable code, clients branch on it instead of the title
+	ErrorCode string `json:"errorCode" xml:"errorCode"`
+	// Errors lists every invalid field of a rejected request
+	Errors []domain.FieldError `json:"errors,omitempty" xml:"errors>error,omitempty"`
+}
+
+// ProblemType returns the type URI of the problems with the error code
+func ProblemType(errorCode string) string {
+	return problemTypePrefix + errorCode
+}
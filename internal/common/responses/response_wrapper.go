@@ -1,37 +1,114 @@
 package responses
 
 import (
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"mime"
+	"net/http"
+
 	"github.com/labstack/echo/v4"
+	"github.com/nalawade41/secret-server/internal/common/logger"
+	"gopkg.in/yaml.v3"
 )
 
-// Error represents the error for UI
-type Error struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
-}
+// charsetUTF8 is added to the Content-Type of text formats, the same way echo does it
+const charsetUTF8 = "charset=UTF-8"
 
-// Response transforms data for the UI with data
+// Response transforms data for the UI with data, in the format negotiated from the Accept header
 func Response(c echo.Context, statusCode int, data interface{}) error {
-	// If needed, we can set the headers here
-	acceptHeader := c.Request().Header.Get(echo.HeaderAccept)
+	mediaType, err := Negotiate(c, false)
+	if err != nil {
+		return err
+	}
+
+	return render(c, statusCode, mediaType, data)
+}
+
+// TextResponse transforms data for the UI like Response, clients accepting plain text get the text instead of the data
+func TextResponse(c echo.Context, statusCode int, data interface{}, text string) error {
+	mediaType, err := Negotiate(c, true)
+	if err != nil {
+		return err
+	}
+
+	if mediaType == echo.MIMETextPlain {
+		c.Response().Header().Add(echo.HeaderVary, echo.HeaderAccept)
+		return c.String(statusCode, text)
+	}
+
+	return render(c, statusCode, mediaType, data)
+}
+
+// FileResponse streams the content as a download with the file name. The content type comes from whoever
+// uploaded the file, browsers are told not to sniff it and to run anything they render in a sandbox.
+// Content that fails once the response started aborts the connection, so a client never takes the part
+// it received for the whole file.
+func FileResponse(c echo.Context, statusCode int, name string, contentType string, content io.Reader) error {
+	disposition := mime.FormatMediaType("attachment", map[string]string{"filename": name})
+	if disposition == "" {
+		disposition = "attachment"
+	}
 
-	switch {
-	case acceptHeader == "application/xml":
-		// // c.XML automatically sets the Content-Type to application/json
+	header := c.Response().Header()
+	header.Set(echo.HeaderContentDisposition, disposition)
+	header.Set(echo.HeaderXContentTypeOptions, "nosniff")
+	header.Set(echo.HeaderContentSecurityPolicy, "sandbox")
+
+	if err := c.Stream(statusCode, contentType, content); err != nil {
+		logger.Errorf("failed to send file: %v", err)
+		panic(http.ErrAbortHandler)
+	}
+
+	return nil
+}
+
+// render writes data in the negotiated media type, every response tells caches that it varies with the Accept header
+func render(c echo.Context, statusCode int, mediaType string, data interface{}) error {
+	c.Response().Header().Add(echo.HeaderVary, echo.HeaderAccept)
+
+	switch mediaType {
+	case echo.MIMEApplicationXML:
+		// c.XML automatically sets the Content-Type to application/xml
 		return c.XML(statusCode, data)
 
-	case acceptHeader == "application/json", acceptHeader == "":
-		fallthrough
+	case MIMETextXML:
+		body, err := xml.Marshal(data)
+		if err != nil {
+			return err
+		}
+		return c.Blob(statusCode, MIMETextXML+"; "+charsetUTF8, append([]byte(xml.Header), body...))
+
+	case MIMEApplicationYAML, MIMEApplicationXYAML, MIMETextYAML:
+		body, err := yaml.Marshal(data)
+		if err != nil {
+			return err
+		}
+		return c.Blob(statusCode, mediaType+"; "+charsetUTF8, body)
+
 	default:
 		// c.JSON automatically sets the Content-Type to application/json
 		return c.JSON(statusCode, data)
 	}
 }
 
-// ErrorResponseWithMessage transforms data for the UI with error message
-func ErrorResponseWithMessage(c echo.Context, statusCode int, message string) error {
-	return Response(c, statusCode, Error{
-		Code:    statusCode,
-		Message: message,
-	})
+// ProblemResponse writes the problem details as application/problem+json, or as application/problem+xml
+// when the client prefers XML. A client that accepts neither still gets JSON, the error is more useful than a 406.
+func ProblemResponse(c echo.Context, problem Problem) error {
+	c.Response().Header().Add(echo.HeaderVary, echo.HeaderAccept)
+
+	switch negotiate(c.Request().Header.Get(echo.HeaderAccept), problemOffers) {
+	case MIMEApplicationProblemXML, echo.MIMEApplicationXML, MIMETextXML:
+		body, err := xml.Marshal(problem)
+		if err != nil {
+			return err
+		}
+		return c.Blob(problem.Status, MIMEApplicationProblemXML, append([]byte(xml.Header), body...))
+	}
+
+	body, err := json.Marshal(problem)
+	if err != nil {
+		return err
+	}
+	return c.Blob(problem.Status, MIMEApplicationProblemJSON, body)
 }
@@ -3,11 +3,16 @@ package responses
 import (
 	"encoding/json"
 	"encoding/xml"
+	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"testing/iotest"
 
 	"github.com/labstack/echo/v4"
+	"github.com/nalawade41/secret-server/internal/domain"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -61,24 +66,193 @@ func TestResponseXML(t *testing.T) {
 	assert.Equal(t, data.Key, responseData.Key)
 }
 
-// TestErrorResponseWithMessage tests the ErrorResponseWithMessage function
-func TestErrorResponseWithMessage(t *testing.T) {
+// TestResponseYAML tests the Response function for YAML responses
+func TestResponseYAML(t *testing.T) {
 	e := echo.New()
 	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(echo.HeaderAccept, "application/json;q=0.5, application/yaml")
 	rec := httptest.NewRecorder()
 	c := e.NewContext(req, rec)
 
-	statusCode := http.StatusBadRequest
-	message := "An error occurred"
+	data := struct {
+		Key string `yaml:"key"`
+	}{
+		Key: "value",
+	}
 
-	err := ErrorResponseWithMessage(c, statusCode, message)
+	err := Response(c, http.StatusOK, data)
 	assert.NoError(t, err)
-	assert.Equal(t, statusCode, rec.Code)
-	assert.Equal(t, echo.MIMEApplicationJSON, rec.Header().Get(echo.HeaderContentType))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/yaml; charset=UTF-8", rec.Header().Get(echo.HeaderContentType))
+	assert.Equal(t, echo.HeaderAccept, rec.Header().Get(echo.HeaderVary))
+	assert.Equal(t, "key: value\n", rec.Body.String())
+}
 
-	var responseData Error
-	err = json.Unmarshal(rec.Body.Bytes(), &responseData)
+// TestResponseTextXML tests the Response function for clients asking for text/xml
+func TestResponseTextXML(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(echo.HeaderAccept, "text/xml")
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	data := struct {
+		XMLName xml.Name `xml:"response"`
+		Key     string   `xml:"key"`
+	}{
+		Key: "value",
+	}
+
+	err := Response(c, http.StatusOK, data)
 	assert.NoError(t, err)
-	assert.Equal(t, statusCode, responseData.Code)
-	assert.Equal(t, message, responseData.Message)
+	assert.Equal(t, "text/xml; charset=UTF-8", rec.Header().Get(echo.HeaderContentType))
+	assert.Equal(t, xml.Header+"<response><key>value</key></response>", rec.Body.String())
+}
+
+// TestResponseNotAcceptable tests that nothing is written when no media type is acceptable
+func TestResponseNotAcceptable(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(echo.HeaderAccept, "text/plain")
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	err := Response(c, http.StatusOK, map[string]string{"key": "value"})
+
+	var httpErr *echo.HTTPError
+	if assert.ErrorAs(t, err, &httpErr) {
+		assert.Equal(t, http.StatusNotAcceptable, httpErr.Code)
+	}
+	assert.False(t, c.Response().Committed)
+}
+
+// TestTextResponse tests that TextResponse only answers plain text with the text
+func TestTextResponse(t *testing.T) {
+	tests := []struct {
+		accept      string
+		contentType string
+		body        string
+	}{
+		{accept: "text/plain", contentType: echo.MIMETextPlainCharsetUTF8, body: "the secret"},
+		{accept: "text/plain;q=0.5, application/json", contentType: echo.MIMEApplicationJSON, body: "{\"key\":\"value\"}\n"},
+	}
+
+	for _, tt := range tests {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(echo.HeaderAccept, tt.accept)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		err := TextResponse(c, http.StatusOK, map[string]string{"key": "value"}, "the secret")
+		assert.NoError(t, err)
+		assert.Equal(t, tt.contentType, rec.Header().Get(echo.HeaderContentType))
+		assert.Equal(t, echo.HeaderAccept, rec.Header().Get(echo.HeaderVary))
+		assert.Equal(t, tt.body, rec.Body.String())
+	}
+}
+
+// TestFileResponse tests that FileResponse serves the content as a sandboxed attachment
+func TestFileResponse(t *testing.T) {
+	tests := []struct {
+		name        string
+		disposition string
+	}{
+		{name: "report.pdf", disposition: "attachment; filename=report.pdf"},
+		{name: "my report.pdf", disposition: "attachment; filename=\"my report.pdf\""},
+		{name: "résumé.pdf", disposition: "attachment; filename*=utf-8''r%C3%A9sum%C3%A9.pdf"},
+	}
+
+	for _, tt := range tests {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(echo.HeaderAccept, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		err := FileResponse(c, http.StatusOK, tt.name, "application/pdf", strings.NewReader("%PDF"))
+		assert.NoError(t, err)
+		assert.Equal(t, "application/pdf", rec.Header().Get(echo.HeaderContentType))
+		assert.Equal(t, tt.disposition, rec.Header().Get(echo.HeaderContentDisposition))
+		assert.Equal(t, "nosniff", rec.Header().Get(echo.HeaderXContentTypeOptions))
+		assert.Equal(t, "sandbox", rec.Header().Get(echo.HeaderContentSecurityPolicy))
+		assert.Equal(t, "%PDF", rec.Body.String())
+	}
+}
+
+// TestFileResponse_Aborted tests that a file failing midway aborts the response instead of ending it
+func TestFileResponse_Aborted(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	content := io.MultiReader(strings.NewReader("%PDF"), iotest.ErrReader(errors.New("authentication failed")))
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		_ = FileResponse(c, http.StatusOK, "report.pdf", "application/pdf", content)
+	})
+}
+
+// TestProblemResponseJSON tests the ProblemResponse function for problem+json responses
+func TestProblemResponseJSON(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/secret", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	problem := Problem{
+		Type:      ProblemType("invalid_input"),
+		Title:     "invalid input",
+		Status:    http.StatusBadRequest,
+		Detail:    "secret text is required",
+		Instance:  "/api/v1/secret",
+		ErrorCode: "invalid_input",
+		Errors:    []domain.FieldError{{Field: "secret", Message: "secret text is required"}},
+	}
+
+	err := ProblemResponse(c, problem)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, MIMEApplicationProblemJSON, rec.Header().Get(echo.HeaderContentType))
+	assert.JSONEq(t, `{
+		"type": "urn:secret-server:problem:invalid_input",
+		"title": "invalid input",
+		"status": 400,
+		"detail": "secret text is required",
+		"instance": "/api/v1/secret",
+		"errorCode": "invalid_input",
+		"errors": [{"field": "secret", "message": "secret text is required"}]
+	}`, rec.Body.String())
+}
+
+// TestProblemResponseXML tests the ProblemResponse function for problem+xml responses
+func TestProblemResponseXML(t *testing.T) {
+	for _, accept := range []string{echo.MIMEApplicationXML, MIMEApplicationProblemXML, "application/json;q=0.5, text/xml"} {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/secret/testhash", nil)
+		req.Header.Set(echo.HeaderAccept, accept)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		problem := Problem{
+			Type:      ProblemType("invalid_input"),
+			Title:     "invalid input",
+			Status:    http.StatusBadRequest,
+			ErrorCode: "invalid_input",
+			Errors:    []domain.FieldError{{Field: "secret", Message: "secret text is required"}},
+		}
+
+		err := ProblemResponse(c, problem)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, MIMEApplicationProblemXML, rec.Header().Get(echo.HeaderContentType))
+		assert.Contains(t, rec.Body.String(), `<problem xmlns="urn:ietf:rfc:7807">`)
+
+		var responseData Problem
+		err = xml.Unmarshal(rec.Body.Bytes(), &responseData)
+		assert.NoError(t, err)
+		assert.Equal(t, problem.Type, responseData.Type)
+		assert.Equal(t, http.StatusBadRequest, responseData.Status)
+		assert.Equal(t, problem.Errors, responseData.Errors)
+	}
 }
@@ -1,75 +1,217 @@
 package security
 
 import (
-	"bytes"
-	"crypto/aes"
-	"crypto/cipher"
+	"context"
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
-	"fmt"
 	"io"
 
+	"github.com/nalawade41/secret-server/internal/domain"
 	"github.com/pkg/errors"
 )
 
-type RealEncryptor struct{}
+// keySize is the number of random bytes in a generated encryption key
+const keySize = 32
 
-// deriveKeyFromHash derives a secret key from the hash
-func deriveKeyFromHash(hash string) ([]byte, error) {
-	if len(hash) < 32 {
-		return nil, errors.New("hash must be at least 32 characters long")
+// messageKeyLabel separates the message key from any other key derived from the same link key
+var messageKeyLabel = []byte("secret-server message key")
+
+// messageKeyID is stored in the envelope of a message and names the derivation of its message key. A new
+// derivation gets a new id, envelopes are always opened with the derivation they name.
+const messageKeyID = "m1"
+
+type RealEncryptor struct {
+	// KeyProvider wraps the per secret data keys with the master key
+	KeyProvider KeyProvider
+}
+
+// GenerateKey generates a random hex encoded encryption key
+func (e RealEncryptor) GenerateKey() (string, error) {
+	return randomHex(keySize)
+}
+
+// randomHex reads n bytes from the CSPRNG and returns them hex encoded
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := io.ReadFull(rand.Reader, b); err != nil {
+		return "", errors.Wrap(err, "failed to read random bytes")
 	}
+	return hex.EncodeToString(b), nil
+}
 
-	// Use the first 32 characters of the hash as the key
-	key, err := hex.DecodeString(hash[:32])
+// deriveMessageKey derives the AES-256 key for a message from the hex encoded link key.
+// Keys handed out before the switch to AES-256 only have 16 bytes, deriving the key
+// keeps them usable when their secrets are re-encrypted.
+func deriveMessageKey(key string) ([]byte, error) {
+	raw, err := hex.DecodeString(key)
 	if err != nil {
-		return nil, errors.Wrap(err, "invalid hex in key derivation")
+		return nil, errors.Wrap(err, "invalid hex in key")
+	}
+
+	if len(raw) < 16 {
+		return nil, errors.New("key must be at least 32 characters long")
 	}
-	return key, nil
+
+	mac := hmac.New(sha256.New, raw)
+	mac.Write(messageKeyLabel)
+	return mac.Sum(nil), nil
 }
 
-// EncryptMessage encrypts the plaintext message using AES encryption
-func (e RealEncryptor) EncryptMessage(plaintext string, hash string) (string, error) {
-	key, err := deriveKeyFromHash(hash)
+// GenerateDataKey generates a random data key for a secret and wraps it with the master key.
+// The content key combines the data key with the link key, so neither the database
+// with the master key nor the link alone is enough to decrypt the secret.
+func (e RealEncryptor) GenerateDataKey(ctx context.Context, linkKey string) (domain.DataKey, error) {
+	if e.KeyProvider == nil {
+		return domain.DataKey{}, errors.New("no key provider configured")
+	}
+
+	dataKey := make([]byte, keySize)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return domain.DataKey{}, errors.Wrap(err, "failed to generate data key")
+	}
+
+	contentKey, err := combineKeys(dataKey, linkKey)
+	if err != nil {
+		return domain.DataKey{}, err
+	}
+
+	wrappedKey, keyID, err := e.KeyProvider.WrapKey(ctx, dataKey)
+	if err != nil {
+		return domain.DataKey{}, err
+	}
+
+	return domain.DataKey{ContentKey: contentKey, WrappedKey: wrappedKey, KeyID: keyID}, nil
+}
+
+// UnwrapDataKey unwraps the data key of a secret and returns its content key
+func (e RealEncryptor) UnwrapDataKey(ctx context.Context, linkKey string, wrappedKey string, keyID string) (string, error) {
+	if e.KeyProvider == nil {
+		return "", errors.New("no key provider configured")
+	}
+
+	dataKey, err := e.KeyProvider.UnwrapKey(ctx, wrappedKey, keyID)
 	if err != nil {
 		return "", err
 	}
 
-	// Create the AES cipher
-	block, err := aes.NewCipher(key)
+	return combineKeys(dataKey, linkKey)
+}
+
+// RewrapDataKey wraps the data key of a secret again with the active master key.
+// The content key does not change, so the ciphertext and the link stay valid.
+func (e RealEncryptor) RewrapDataKey(ctx context.Context, wrappedKey string, keyID string) (string, string, error) {
+	if e.KeyProvider == nil {
+		return "", "", errors.New("no key provider configured")
+	}
+
+	dataKey, err := e.KeyProvider.UnwrapKey(ctx, wrappedKey, keyID)
 	if err != nil {
-		return "", errors.Wrap(err, "failed to create AES cipher")
+		return "", "", err
 	}
-	paddedText, _ := pkcs7Pad([]byte(plaintext), block.BlockSize())
 
-	// The IV needs to be unique, but not secure.
-	// Therefore, it's common to include it at the beginning of the ciphertext.
-	ciphertext := make([]byte, aes.BlockSize+len(paddedText))
-	iv := ciphertext[:aes.BlockSize]
-	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
-		return "", errors.Wrap(err, "failed to generate IV")
+	return e.KeyProvider.WrapKey(ctx, dataKey)
+}
+
+// ActiveKeyID returns the id of the master key new data keys are wrapped with
+func (e RealEncryptor) ActiveKeyID(ctx context.Context) (string, error) {
+	if e.KeyProvider == nil {
+		return "", errors.New("no key provider configured")
 	}
 
-	bm := cipher.NewCBCEncrypter(block, iv)
-	bm.CryptBlocks(ciphertext[aes.BlockSize:], paddedText)
+	return e.KeyProvider.ActiveKeyID(ctx)
+}
+
+// combineKeys derives the hex encoded content key of a secret from its data key and link key
+func combineKeys(dataKey []byte, linkKey string) (string, error) {
+	messageKey, err := deriveMessageKey(linkKey)
+	if err != nil {
+		return "", err
+	}
 
-	return fmt.Sprintf("%x", ciphertext), nil
+	mac := hmac.New(sha256.New, dataKey)
+	mac.Write(messageKey)
+	return hex.EncodeToString(mac.Sum(nil)), nil
 }
 
-func pkcs7Pad(b []byte, blockSize int) ([]byte, error) {
-	if blockSize <= 0 {
-		return nil, errors.New("invalid blocksize")
+// EncryptMessage encrypts the plaintext message with AES-256-GCM and returns the encoded envelope
+func (e RealEncryptor) EncryptMessage(plaintext string, key string) (string, error) {
+	messageKey, err := deriveMessageKey(key)
+	if err != nil {
+		return "", err
+	}
+
+	env, err := SealEnvelope(messageKey, messageKeyID, []byte(plaintext))
+	if err != nil {
+		return "", errors.Wrap(err, "failed to seal envelope")
+	}
+
+	return env.Encode(), nil
+}
+
+// DecryptMessage decrypts a ciphertext produced by EncryptMessage, legacy AES-CBC ciphertexts are still accepted
+func (e RealEncryptor) DecryptMessage(ciphertext string, key string) (string, error) {
+	if e.IsLegacy(ciphertext) {
+		return decryptLegacyMessage(ciphertext, key)
+	}
+
+	env, err := DecodeEnvelope(ciphertext)
+	if err != nil {
+		return "", err
+	}
+
+	messageKey, err := envelopeMessageKey(env.KeyID, key)
+	if err != nil {
+		return "", err
 	}
 
-	if b == nil || len(b) == 0 {
-		return nil, errors.New("invalid PKCS7 data (empty or not padded)")
+	plaintext, err := env.Open(messageKey)
+	if err != nil {
+		return "", err
 	}
 
-	n := blockSize - (len(b) % blockSize)
-	pb := make([]byte, len(b)+n)
+	return string(plaintext), nil
+}
+
+// envelopeMessageKey derives the message key with the derivation named by the key id of the envelope.
+// Envelopes written before the key id was stored have none, their key was derived the same way as m1.
+func envelopeMessageKey(keyID string, key string) ([]byte, error) {
+	switch keyID {
+	case messageKeyID, "":
+		return deriveMessageKey(key)
+	default:
+		return nil, errors.Errorf("unknown message key id %q", keyID)
+	}
+}
+
+// EncryptStream returns a writer that encrypts everything written to it into dst in chunks, for payloads
+// too large to hold in memory. Close must be called to finish the stream.
+func (e RealEncryptor) EncryptStream(dst io.Writer, key string) (io.WriteCloser, error) {
+	messageKey, err := deriveMessageKey(key)
+	if err != nil {
+		return nil, err
+	}
 
-	copy(pb, b)
-	copy(pb[len(b):], bytes.Repeat([]byte{byte(n)}, n))
+	return NewEncryptWriter(dst, messageKey)
+}
+
+// DecryptStream returns a reader that decrypts a stream produced by EncryptStream from src
+func (e RealEncryptor) DecryptStream(src io.Reader, key string) (io.Reader, error) {
+	messageKey, err := deriveMessageKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewDecryptReader(src, messageKey)
+}
+
+// StreamSize returns the length of the stream EncryptStream writes for a plaintext of the size
+func (e RealEncryptor) StreamSize(size int64) int64 {
+	return EncryptedStreamSize(size)
+}
 
-	return pb, nil
+// IsLegacy reports whether the ciphertext uses the unauthenticated AES-CBC format and should be re-encrypted
+func (e RealEncryptor) IsLegacy(ciphertext string) bool {
+	return isLegacyCiphertext(ciphertext)
 }
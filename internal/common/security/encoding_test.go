@@ -1,10 +1,14 @@
 package security
 
 import (
+	"bytes"
+	"context"
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/rand"
 	"encoding/hex"
 	"errors"
+	"io"
 	"testing"
 
 	"github.com/golang/mock/gomock"
@@ -102,72 +106,351 @@ func TestEncryptMessage_Success_Actual(t *testing.T) {
 	encryptor := RealEncryptor{}
 
 	plaintext := "Hello, World!"
-	hash := "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef" // 64 hex characters = 32 bytes
+	key := "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef" // 64 hex characters = 32 bytes
 
 	// Encrypt the plaintext
-	ciphertext, err := encryptor.EncryptMessage(plaintext, hash)
+	ciphertext, err := encryptor.EncryptMessage(plaintext, key)
 	assert.NoError(t, err, "EncryptMessage should not return an error")
+	assert.False(t, encryptor.IsLegacy(ciphertext), "New ciphertexts should not use the legacy format")
 
-	// Decode ciphertext from hex
-	ciphertextBytes, err := hex.DecodeString(ciphertext)
-	assert.NoError(t, err, "Decoding ciphertext should not return an error")
+	// Decode the envelope
+	env, err := DecodeEnvelope(ciphertext)
+	assert.NoError(t, err, "Decoding the envelope should not return an error")
+	assert.Equal(t, EnvelopeVersion1, env.Version)
+	assert.Equal(t, AlgorithmAES256GCM, env.Algorithm)
+	assert.Equal(t, messageKeyID, env.KeyID, "The envelope names the derivation of its message key")
 
 	// Derive key and check the decryption
-	key, err := deriveKeyFromHash(hash)
-	assert.NoError(t, err, "deriveKeyFromHash should not return an error for a valid hash")
+	messageKey, err := deriveMessageKey(key)
+	assert.NoError(t, err, "deriveMessageKey should not return an error for a valid key")
+	assert.Len(t, messageKey, 32, "The message key should be 32 bytes long for AES-256")
+
+	decryptedMessage, err := env.Open(messageKey)
+	assert.NoError(t, err, "Opening the envelope should not return an error")
+	assert.Equal(t, plaintext, string(decryptedMessage), "Decrypted message should match the original plaintext")
+}
 
-	block, err := aes.NewCipher(key)
-	assert.NoError(t, err, "Creating cipher block should not return an error")
+func TestDecryptMessage_MessageKeyID(t *testing.T) {
+	encryptor := RealEncryptor{}
+	key := "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
 
-	iv := ciphertextBytes[:aes.BlockSize]
-	encryptedMessage := ciphertextBytes[aes.BlockSize:]
-	decryptedMessage := make([]byte, len(encryptedMessage))
+	messageKey, err := deriveMessageKey(key)
+	assert.NoError(t, err)
 
-	stream := cipher.NewCFBDecrypter(block, iv)
-	stream.XORKeyStream(decryptedMessage, encryptedMessage)
+	// Test case: Envelopes written before the key id was stored are still opened
+	env, err := SealEnvelope(messageKey, "", []byte("written without key id"))
+	assert.NoError(t, err)
 
-	assert.Equal(t, plaintext, string(decryptedMessage), "Decrypted message should match the original plaintext")
+	plaintext, err := encryptor.DecryptMessage(env.Encode(), key)
+	assert.NoError(t, err)
+	assert.Equal(t, "written without key id", plaintext)
+
+	// Test case: An envelope of an unknown derivation is refused
+	env, err = SealEnvelope(messageKey, "m2", []byte("unknown derivation"))
+	assert.NoError(t, err)
+
+	_, err = encryptor.DecryptMessage(env.Encode(), key)
+	assert.ErrorContains(t, err, "unknown message key id")
 }
 
 func TestEncryptMessage_InvalidKeyLength_Actual(t *testing.T) {
 	encryptor := RealEncryptor{}
 
 	plaintext := "Hello, World!"
-	hash := "short"
+	key := "abcdef"
 
-	_, err := encryptor.EncryptMessage(plaintext, hash)
+	_, err := encryptor.EncryptMessage(plaintext, key)
 
 	assert.Error(t, err, "EncryptMessage should return an error for invalid key length")
-	assert.Contains(t, err.Error(), "hash must be at least 32 characters long", "The error message should indicate the hash length requirement")
+	assert.Contains(t, err.Error(), "key must be at least 32 characters long", "The error message should indicate the key length requirement")
 }
 
 func TestEncryptMessage_EmptyPlaintext_Actual(t *testing.T) {
 	encryptor := RealEncryptor{}
 
 	plaintext := ""
-	hash := "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef" // 64 hex characters = 32 bytes
+	key := "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef" // 64 hex characters = 32 bytes
 
-	ciphertext, err := encryptor.EncryptMessage(plaintext, hash)
+	ciphertext, err := encryptor.EncryptMessage(plaintext, key)
 	assert.NoError(t, err, "EncryptMessage should not return an error for empty plaintext")
 	assert.NotEmpty(t, ciphertext, "Ciphertext should not be empty even if plaintext is empty")
 
-	// Decode ciphertext from hex
-	ciphertextBytes, err := hex.DecodeString(ciphertext)
-	assert.NoError(t, err, "Decoding ciphertext should not return an error")
+	decryptedMessage, err := encryptor.DecryptMessage(ciphertext, key)
+	assert.NoError(t, err, "DecryptMessage should not return an error for empty plaintext")
+	assert.Equal(t, plaintext, decryptedMessage, "Decrypted message should match the original plaintext")
+}
 
-	// Derive key and check the decryption
-	key, err := deriveKeyFromHash(hash)
-	assert.NoError(t, err, "deriveKeyFromHash should not return an error for a valid hash")
+func TestDecryptMessage_RoundTrip(t *testing.T) {
+	encryptor := RealEncryptor{}
 
-	block, err := aes.NewCipher(key)
-	assert.NoError(t, err, "Creating cipher block should not return an error")
+	plaintext := "Hello, World!"
+	hash := "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
 
-	iv := ciphertextBytes[:aes.BlockSize]
-	encryptedMessage := ciphertextBytes[aes.BlockSize:]
-	decryptedMessage := make([]byte, len(encryptedMessage))
+	ciphertext, err := encryptor.EncryptMessage(plaintext, hash)
+	assert.NoError(t, err, "EncryptMessage should not return an error")
 
-	stream := cipher.NewCFBDecrypter(block, iv)
-	stream.XORKeyStream(decryptedMessage, encryptedMessage)
+	decrypted, err := encryptor.DecryptMessage(ciphertext, hash)
+	assert.NoError(t, err, "DecryptMessage should not return an error")
+	assert.Equal(t, plaintext, decrypted, "Decrypted message should match the original plaintext")
+}
 
-	assert.Equal(t, plaintext, string(decryptedMessage), "Decrypted message should match the original plaintext")
+func TestDecryptMessage_BlockAlignedPlaintext(t *testing.T) {
+	encryptor := RealEncryptor{}
+
+	plaintext := "0123456789abcdef" // exactly one AES block
+	hash := "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+	ciphertext, err := encryptor.EncryptMessage(plaintext, hash)
+	assert.NoError(t, err, "EncryptMessage should not return an error")
+
+	decrypted, err := encryptor.DecryptMessage(ciphertext, hash)
+	assert.NoError(t, err, "DecryptMessage should not return an error")
+	assert.Equal(t, plaintext, decrypted, "Decrypted message should match the original plaintext")
+}
+
+func TestDecryptMessage_WrongKey(t *testing.T) {
+	encryptor := RealEncryptor{}
+
+	key := "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	otherKey := "fedcba9876543210fedcba9876543210fedcba9876543210fedcba9876543210"
+
+	ciphertext, err := encryptor.EncryptMessage("Hello, World!", key)
+	assert.NoError(t, err, "EncryptMessage should not return an error")
+
+	_, err = encryptor.DecryptMessage(ciphertext, otherKey)
+	assert.Error(t, err, "DecryptMessage should fail to authenticate with the wrong key")
+	assert.Contains(t, err.Error(), "failed to authenticate envelope")
+}
+
+func TestEncryptStream_RoundTrip(t *testing.T) {
+	encryptor := RealEncryptor{}
+
+	key := "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	otherKey := "fedcba9876543210fedcba9876543210fedcba9876543210fedcba9876543210"
+
+	var ciphertext bytes.Buffer
+	w, err := encryptor.EncryptStream(&ciphertext, key)
+	assert.NoError(t, err, "EncryptStream should not return an error")
+	_, err = w.Write([]byte("Hello, World!"))
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+	assert.Equal(t, int64(ciphertext.Len()), encryptor.StreamSize(int64(len("Hello, World!"))))
+
+	r, err := encryptor.DecryptStream(bytes.NewReader(ciphertext.Bytes()), key)
+	assert.NoError(t, err, "DecryptStream should not return an error")
+	plaintext, err := io.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "Hello, World!", string(plaintext))
+
+	r, err = encryptor.DecryptStream(bytes.NewReader(ciphertext.Bytes()), otherKey)
+	assert.NoError(t, err)
+	_, err = io.ReadAll(r)
+	assert.Error(t, err, "DecryptStream should fail to authenticate with the wrong key")
+
+	_, err = encryptor.EncryptStream(io.Discard, "not hex")
+	assert.Error(t, err, "EncryptStream should reject invalid keys")
+}
+
+func TestDecryptMessage_Tampered(t *testing.T) {
+	encryptor := RealEncryptor{}
+
+	key := "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+	ciphertext, err := encryptor.EncryptMessage("Hello, World!", key)
+	assert.NoError(t, err, "EncryptMessage should not return an error")
+
+	env, err := DecodeEnvelope(ciphertext)
+	assert.NoError(t, err)
+
+	// Flip a bit of the ciphertext
+	env.Ciphertext[0] ^= 0x01
+	_, err = encryptor.DecryptMessage(env.Encode(), key)
+	assert.Error(t, err, "DecryptMessage should detect a modified ciphertext")
+
+	// Swap the key id in the authenticated header
+	env.Ciphertext[0] ^= 0x01
+	env.KeyID = "other"
+	_, err = encryptor.DecryptMessage(env.Encode(), key)
+	assert.Error(t, err, "DecryptMessage should detect a modified header")
+}
+
+func TestDecryptMessage_InvalidCiphertext(t *testing.T) {
+	encryptor := RealEncryptor{}
+
+	key := "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+	_, err := encryptor.DecryptMessage("not base64!", key)
+	assert.Error(t, err, "DecryptMessage should fail for invalid ciphertext")
+	assert.Contains(t, err.Error(), "invalid base64 in envelope")
+
+	_, err = encryptor.DecryptMessage("AQE", key)
+	assert.Error(t, err, "DecryptMessage should fail for a truncated envelope")
+	assert.Contains(t, err.Error(), "envelope is too short")
+
+	_, err = encryptor.DecryptMessage("00112233", key)
+	assert.Error(t, err, "DecryptMessage should fail for truncated legacy ciphertext")
+	assert.Contains(t, err.Error(), "ciphertext has invalid length")
+}
+
+func TestDecryptMessage_Legacy(t *testing.T) {
+	encryptor := RealEncryptor{}
+
+	plaintext := "Hello, World!"
+	key := "0123456789abcdef0123456789abcdef" // keys handed out for AES-128-CBC
+
+	ciphertext := encryptLegacyMessage(t, plaintext, key)
+	assert.True(t, encryptor.IsLegacy(ciphertext), "CBC ciphertexts should be detected as legacy")
+
+	decrypted, err := encryptor.DecryptMessage(ciphertext, key)
+	assert.NoError(t, err, "DecryptMessage should still read legacy ciphertexts")
+	assert.Equal(t, plaintext, decrypted)
+
+	// Legacy keys can be used to re-encrypt the secret
+	upgraded, err := encryptor.EncryptMessage(decrypted, key)
+	assert.NoError(t, err, "EncryptMessage should accept a legacy key")
+	assert.False(t, encryptor.IsLegacy(upgraded))
+
+	decrypted, err = encryptor.DecryptMessage(upgraded, key)
+	assert.NoError(t, err)
+	assert.Equal(t, plaintext, decrypted)
+}
+
+func TestPkcs7Unpad(t *testing.T) {
+	padded, err := pkcs7Pad([]byte("abc"), 8)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{'a', 'b', 'c', 5, 5, 5, 5, 5}, padded)
+
+	unpadded, err := pkcs7Unpad(padded, 8)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("abc"), unpadded)
+
+	// Padding byte larger than the block size
+	_, err = pkcs7Unpad([]byte{'a', 'b', 'c', 'd', 'e', 'f', 'g', 9}, 8)
+	assert.Error(t, err)
+
+	// Zero padding byte
+	_, err = pkcs7Unpad([]byte{'a', 'b', 'c', 'd', 'e', 'f', 'g', 0}, 8)
+	assert.Error(t, err)
+
+	// Inconsistent padding bytes
+	_, err = pkcs7Unpad([]byte{'a', 'b', 'c', 'd', 'e', 3, 2, 3}, 8)
+	assert.Error(t, err)
+
+	// Data not aligned to the block size
+	_, err = pkcs7Unpad([]byte{'a', 'b', 1}, 8)
+	assert.Error(t, err)
+
+	// Empty data
+	_, err = pkcs7Unpad(nil, 8)
+	assert.Error(t, err)
+}
+
+func TestGenerateKey(t *testing.T) {
+	encryptor := RealEncryptor{}
+
+	key, err := encryptor.GenerateKey()
+	assert.NoError(t, err, "GenerateKey should not return an error")
+	assert.Len(t, key, 2*keySize, "The key should be hex encoded")
+
+	other, err := encryptor.GenerateKey()
+	assert.NoError(t, err, "GenerateKey should not return an error")
+	assert.NotEqual(t, key, other, "Generated keys should be random")
+
+	// The generated key must be usable for encryption
+	ciphertext, err := encryptor.EncryptMessage("Hello, World!", key)
+	assert.NoError(t, err, "EncryptMessage should accept a generated key")
+
+	decrypted, err := encryptor.DecryptMessage(ciphertext, key)
+	assert.NoError(t, err, "DecryptMessage should accept a generated key")
+	assert.Equal(t, "Hello, World!", decrypted)
+}
+
+// encryptLegacyMessage produces a ciphertext in the format written before authenticated encryption
+func encryptLegacyMessage(t *testing.T, plaintext string, key string) string {
+	aesKey, err := deriveKeyFromHash(key)
+	assert.NoError(t, err)
+
+	block, err := aes.NewCipher(aesKey)
+	assert.NoError(t, err)
+
+	paddedText, err := pkcs7Pad([]byte(plaintext), block.BlockSize())
+	assert.NoError(t, err)
+
+	ciphertext := make([]byte, aes.BlockSize+len(paddedText))
+	iv := ciphertext[:aes.BlockSize]
+	_, err = rand.Read(iv)
+	assert.NoError(t, err)
+
+	bm := cipher.NewCBCEncrypter(block, iv)
+	bm.CryptBlocks(ciphertext[aes.BlockSize:], paddedText)
+
+	return hex.EncodeToString(ciphertext)
+}
+
+func pkcs7Pad(b []byte, blockSize int) ([]byte, error) {
+	if blockSize <= 0 {
+		return nil, errors.New("invalid blocksize")
+	}
+
+	n := blockSize - (len(b) % blockSize)
+	pb := make([]byte, len(b)+n)
+
+	copy(pb, b)
+	copy(pb[len(b):], bytes.Repeat([]byte{byte(n)}, n))
+
+	return pb, nil
+}
+
+func TestGenerateDataKey_RoundTrip(t *testing.T) {
+	provider, err := NewLocalKeyProvider("master", make([]byte, 32))
+	assert.NoError(t, err)
+	encryptor := RealEncryptor{KeyProvider: provider}
+
+	linkKey, err := encryptor.GenerateKey()
+	assert.NoError(t, err)
+
+	dataKey, err := encryptor.GenerateDataKey(context.Background(), linkKey)
+	assert.NoError(t, err, "GenerateDataKey should not return an error")
+	assert.Equal(t, "master", dataKey.KeyID, "The data key should be wrapped by the master key")
+	assert.NotEqual(t, linkKey, dataKey.ContentKey, "The content key must differ from the link key")
+
+	// Unwrapping the data key with the link key yields the same content key
+	contentKey, err := encryptor.UnwrapDataKey(context.Background(), linkKey, dataKey.WrappedKey, dataKey.KeyID)
+	assert.NoError(t, err, "UnwrapDataKey should not return an error")
+	assert.Equal(t, dataKey.ContentKey, contentKey)
+
+	// The wrapped data key alone is not enough, the link key is part of the content key
+	otherLinkKey, err := encryptor.GenerateKey()
+	assert.NoError(t, err)
+	contentKey, err = encryptor.UnwrapDataKey(context.Background(), otherLinkKey, dataKey.WrappedKey, dataKey.KeyID)
+	assert.NoError(t, err)
+	assert.NotEqual(t, dataKey.ContentKey, contentKey)
+}
+
+func TestGenerateDataKey_UniquePerSecret(t *testing.T) {
+	provider, err := NewLocalKeyProvider("master", make([]byte, 32))
+	assert.NoError(t, err)
+	encryptor := RealEncryptor{KeyProvider: provider}
+
+	linkKey := "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+	first, err := encryptor.GenerateDataKey(context.Background(), linkKey)
+	assert.NoError(t, err)
+	second, err := encryptor.GenerateDataKey(context.Background(), linkKey)
+	assert.NoError(t, err)
+
+	assert.NotEqual(t, first.WrappedKey, second.WrappedKey, "Every secret should get its own data key")
+	assert.NotEqual(t, first.ContentKey, second.ContentKey, "Every secret should get its own content key")
+}
+
+func TestGenerateDataKey_NoKeyProvider(t *testing.T) {
+	encryptor := RealEncryptor{}
+
+	_, err := encryptor.GenerateDataKey(context.Background(), "0123456789abcdef0123456789abcdef")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "no key provider configured")
+
+	_, err = encryptor.UnwrapDataKey(context.Background(), "0123456789abcdef0123456789abcdef", "wrapped", "master")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "no key provider configured")
 }
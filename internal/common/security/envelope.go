@@ -0,0 +1,196 @@
+package security
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// EnvelopeVersion1 is the current layout of an encrypted envelope
+	EnvelopeVersion1 byte = 1
+
+	// AlgorithmAES256GCM identifies AES-256 in Galois/Counter Mode
+	AlgorithmAES256GCM byte = 1
+
+	// AlgorithmNameAES256GCM is the name clients use for AlgorithmAES256GCM
+	AlgorithmNameAES256GCM = "AES-256-GCM"
+
+	// maxKeyIDLength is the longest key id that fits the single length byte
+	maxKeyIDLength = 255
+)
+
+// Envelope is the self describing container for authenticated ciphertext.
+//
+// The binary layout is:
+//
+//	version (1 byte) | algorithm (1 byte) | key id length (1 byte) | key id | nonce | ciphertext and tag
+//
+// Everything in front of the nonce is authenticated as additional data, so the
+// header can not be swapped without the open failing.
+type Envelope struct {
+	Version    byte
+	Algorithm  byte
+	KeyID      string
+	Nonce      []byte
+	Ciphertext []byte
+}
+
+// SealEnvelope encrypts the plaintext with AES-256-GCM under the given 32 byte key
+func SealEnvelope(key []byte, keyID string, plaintext []byte) (Envelope, error) {
+	if len(keyID) > maxKeyIDLength {
+		return Envelope{}, errors.New("key id is too long")
+	}
+
+	aead, err := newAEAD(AlgorithmAES256GCM, key)
+	if err != nil {
+		return Envelope{}, err
+	}
+
+	env := Envelope{
+		Version:   EnvelopeVersion1,
+		Algorithm: AlgorithmAES256GCM,
+		KeyID:     keyID,
+		Nonce:     make([]byte, aead.NonceSize()),
+	}
+
+	// The nonce must never repeat for a key, a random 96 bit nonce is safe for the amount of data per key
+	if _, err := io.ReadFull(rand.Reader, env.Nonce); err != nil {
+		return Envelope{}, errors.Wrap(err, "failed to generate nonce")
+	}
+
+	env.Ciphertext = aead.Seal(nil, env.Nonce, plaintext, env.header())
+	return env, nil
+}
+
+// Open authenticates and decrypts the envelope with the given key
+func (e Envelope) Open(key []byte) ([]byte, error) {
+	if e.Version != EnvelopeVersion1 {
+		return nil, errors.Errorf("unsupported envelope version %d", e.Version)
+	}
+
+	aead, err := newAEAD(e.Algorithm, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(e.Nonce) != aead.NonceSize() {
+		return nil, errors.New("envelope has invalid nonce length")
+	}
+
+	plaintext, err := aead.Open(nil, e.Nonce, e.Ciphertext, e.header())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to authenticate envelope")
+	}
+
+	return plaintext, nil
+}
+
+// Bytes returns the binary form of the envelope
+func (e Envelope) Bytes() []byte {
+	b := make([]byte, 0, len(e.header())+len(e.Nonce)+len(e.Ciphertext))
+	b = append(b, e.header()...)
+	b = append(b, e.Nonce...)
+	b = append(b, e.Ciphertext...)
+	return b
+}
+
+// Encode returns the envelope as an unpadded URL safe base64 string.
+// The version and algorithm bytes make every encoded envelope start with
+// an upper case character, which keeps it apart from the legacy lower case hex format.
+func (e Envelope) Encode() string {
+	return base64.RawURLEncoding.EncodeToString(e.Bytes())
+}
+
+// header returns the authenticated part of the envelope
+func (e Envelope) header() []byte {
+	h := make([]byte, 0, 3+len(e.KeyID))
+	h = append(h, e.Version, e.Algorithm, byte(len(e.KeyID)))
+	return append(h, e.KeyID...)
+}
+
+// ParseEnvelope parses the binary form of an envelope
+func ParseEnvelope(data []byte) (Envelope, error) {
+	if len(data) < 3 {
+		return Envelope{}, errors.New("envelope is too short")
+	}
+
+	env := Envelope{Version: data[0], Algorithm: data[1]}
+	if env.Version != EnvelopeVersion1 {
+		return Envelope{}, errors.Errorf("unsupported envelope version %d", env.Version)
+	}
+
+	nonceSize, overhead, err := algorithmSizes(env.Algorithm)
+	if err != nil {
+		return Envelope{}, err
+	}
+
+	keyIDLength := int(data[2])
+	rest := data[3:]
+	if len(rest) < keyIDLength+nonceSize+overhead {
+		return Envelope{}, errors.New("envelope is too short")
+	}
+
+	env.KeyID = string(rest[:keyIDLength])
+	rest = rest[keyIDLength:]
+	env.Nonce = rest[:nonceSize]
+	env.Ciphertext = rest[nonceSize:]
+
+	return env, nil
+}
+
+// DecodeEnvelope parses an envelope produced by Envelope.Encode
+func DecodeEnvelope(s string) (Envelope, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Envelope{}, errors.Wrap(err, "invalid base64 in envelope")
+	}
+	return ParseEnvelope(data)
+}
+
+// ParseAlgorithmName returns the envelope algorithm for its name
+func ParseAlgorithmName(name string) (byte, error) {
+	switch name {
+	case AlgorithmNameAES256GCM:
+		return AlgorithmAES256GCM, nil
+	default:
+		return 0, errors.Errorf("unsupported algorithm %q", name)
+	}
+}
+
+// algorithmSizes returns the nonce size and the authentication overhead of the algorithm
+func algorithmSizes(algorithm byte) (int, int, error) {
+	switch algorithm {
+	case AlgorithmAES256GCM:
+		return 12, 16, nil
+	default:
+		return 0, 0, errors.Errorf("unsupported envelope algorithm %d", algorithm)
+	}
+}
+
+// newAEAD creates the AEAD cipher for the algorithm
+func newAEAD(algorithm byte, key []byte) (cipher.AEAD, error) {
+	switch algorithm {
+	case AlgorithmAES256GCM:
+		if len(key) != 32 {
+			return nil, errors.New("AES-256-GCM requires a 32 byte key")
+		}
+
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create AES cipher")
+		}
+
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create GCM")
+		}
+		return aead, nil
+	default:
+		return nil, errors.Errorf("unsupported envelope algorithm %d", algorithm)
+	}
+}
@@ -0,0 +1,103 @@
+package security
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSealEnvelope_RoundTrip(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, 32)
+
+	env, err := SealEnvelope(key, "master-1", []byte("Hello, World!"))
+	assert.NoError(t, err, "SealEnvelope should not return an error")
+	assert.Equal(t, EnvelopeVersion1, env.Version)
+	assert.Equal(t, AlgorithmAES256GCM, env.Algorithm)
+	assert.Len(t, env.Nonce, 12)
+
+	// The encoded form must survive a round trip
+	parsed, err := DecodeEnvelope(env.Encode())
+	assert.NoError(t, err, "DecodeEnvelope should not return an error")
+	assert.Equal(t, "master-1", parsed.KeyID)
+	assert.Equal(t, env.Nonce, parsed.Nonce)
+	assert.Equal(t, env.Ciphertext, parsed.Ciphertext)
+
+	plaintext, err := parsed.Open(key)
+	assert.NoError(t, err, "Open should not return an error")
+	assert.Equal(t, "Hello, World!", string(plaintext))
+}
+
+func TestSealEnvelope_UniqueNonce(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, 32)
+
+	first, err := SealEnvelope(key, "", []byte("same"))
+	assert.NoError(t, err)
+
+	second, err := SealEnvelope(key, "", []byte("same"))
+	assert.NoError(t, err)
+
+	assert.NotEqual(t, first.Nonce, second.Nonce, "Every envelope should get a fresh nonce")
+	assert.NotEqual(t, first.Ciphertext, second.Ciphertext)
+}
+
+func TestSealEnvelope_InvalidInput(t *testing.T) {
+	_, err := SealEnvelope([]byte("short"), "", []byte("data"))
+	assert.Error(t, err, "SealEnvelope should reject keys that are not 32 bytes")
+
+	_, err = SealEnvelope(bytes.Repeat([]byte{0x42}, 32), string(bytes.Repeat([]byte("k"), 256)), []byte("data"))
+	assert.Error(t, err, "SealEnvelope should reject key ids that do not fit the header")
+}
+
+func TestParseEnvelope_Invalid(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, 32)
+
+	env, err := SealEnvelope(key, "", []byte("data"))
+	assert.NoError(t, err)
+	data := env.Bytes()
+
+	// Unknown version
+	unknownVersion := append([]byte{}, data...)
+	unknownVersion[0] = 9
+	_, err = ParseEnvelope(unknownVersion)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "unsupported envelope version")
+
+	// Unknown algorithm
+	unknownAlgorithm := append([]byte{}, data...)
+	unknownAlgorithm[1] = 9
+	_, err = ParseEnvelope(unknownAlgorithm)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "unsupported envelope algorithm")
+
+	// Key id length pointing past the data
+	longKeyID := append([]byte{}, data...)
+	longKeyID[2] = 200
+	_, err = ParseEnvelope(longKeyID)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "envelope is too short")
+
+	// Missing authentication tag
+	_, err = ParseEnvelope(data[:3+12+4])
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "envelope is too short")
+}
+
+func TestEnvelope_EncodeIsNotLegacy(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, 32)
+
+	for i := 0; i < 100; i++ {
+		env, err := SealEnvelope(key, "", []byte("data"))
+		assert.NoError(t, err)
+		assert.False(t, isLegacyCiphertext(env.Encode()), "Encoded envelopes must never look like legacy hex")
+	}
+}
+
+func TestParseAlgorithmName(t *testing.T) {
+	algorithm, err := ParseAlgorithmName(AlgorithmNameAES256GCM)
+	assert.NoError(t, err)
+	assert.Equal(t, AlgorithmAES256GCM, algorithm)
+
+	_, err = ParseAlgorithmName("AES-128-CBC")
+	assert.Error(t, err, "Unknown algorithms should be rejected")
+}
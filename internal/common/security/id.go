@@ -0,0 +1,71 @@
+package security
+
+import (
+	"crypto/rand"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// IDEncodingBase62 encodes ids with digits and upper and lower case letters
+	IDEncodingBase62 = "base62"
+	// IDEncodingBase32 encodes ids with lower case letters and the digits 2-7, which is safe for case insensitive channels
+	IDEncodingBase32 = "base32"
+
+	base62Alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	base32Alphabet = "abcdefghijklmnopqrstuvwxyz234567"
+)
+
+// IDGenerator generates unguessable identifiers for secrets from the CSPRNG
+type IDGenerator struct {
+	Length   int
+	Encoding string
+}
+
+// GenerateID returns a random identifier of the configured length and encoding
+func (g IDGenerator) GenerateID() (string, error) {
+	if g.Length <= 0 {
+		return "", errors.New("id length must be greater than 0")
+	}
+
+	var alphabet string
+	switch g.Encoding {
+	case IDEncodingBase62:
+		alphabet = base62Alphabet
+	case IDEncodingBase32:
+		alphabet = base32Alphabet
+	default:
+		return "", errors.Errorf("unsupported id encoding %q", g.Encoding)
+	}
+
+	return randomString(g.Length, alphabet)
+}
+
+// randomString picks n characters uniformly from the alphabet.
+// Random bytes beyond the largest multiple of the alphabet size are discarded,
+// so no character is more likely than another.
+func randomString(n int, alphabet string) (string, error) {
+	limit := 256 - 256%len(alphabet)
+
+	out := make([]byte, 0, n)
+	buf := make([]byte, n)
+	for len(out) < n {
+		if _, err := io.ReadFull(rand.Reader, buf); err != nil {
+			return "", errors.Wrap(err, "failed to read random bytes")
+		}
+
+		for _, b := range buf {
+			if int(b) >= limit {
+				continue
+			}
+
+			out = append(out, alphabet[int(b)%len(alphabet)])
+			if len(out) == n {
+				break
+			}
+		}
+	}
+
+	return string(out), nil
+}
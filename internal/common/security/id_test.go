@@ -0,0 +1,53 @@
+package security
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateID_Base62(t *testing.T) {
+	generator := IDGenerator{Length: 22, Encoding: IDEncodingBase62}
+
+	id, err := generator.GenerateID()
+	assert.NoError(t, err, "GenerateID should not return an error")
+	assert.Len(t, id, 22, "The id should have the configured length")
+
+	for _, c := range id {
+		assert.True(t, strings.ContainsRune(base62Alphabet, c), "The id should only contain base62 characters")
+	}
+}
+
+func TestGenerateID_Base32(t *testing.T) {
+	generator := IDGenerator{Length: 26, Encoding: IDEncodingBase32}
+
+	id, err := generator.GenerateID()
+	assert.NoError(t, err, "GenerateID should not return an error")
+	assert.Len(t, id, 26, "The id should have the configured length")
+
+	for _, c := range id {
+		assert.True(t, strings.ContainsRune(base32Alphabet, c), "The id should only contain base32 characters")
+	}
+}
+
+func TestGenerateID_Unique(t *testing.T) {
+	generator := IDGenerator{Length: 22, Encoding: IDEncodingBase62}
+
+	seen := make(map[string]bool)
+	for i := 0; i < 1000; i++ {
+		id, err := generator.GenerateID()
+		assert.NoError(t, err)
+		assert.False(t, seen[id], "Generated ids should not repeat")
+		seen[id] = true
+	}
+}
+
+func TestGenerateID_Invalid(t *testing.T) {
+	_, err := IDGenerator{Length: 0, Encoding: IDEncodingBase62}.GenerateID()
+	assert.Error(t, err, "GenerateID should reject a zero length")
+
+	_, err = IDGenerator{Length: 22, Encoding: "hex"}.GenerateID()
+	assert.Error(t, err, "GenerateID should reject unknown encodings")
+	assert.Contains(t, err.Error(), "unsupported id encoding")
+}
@@ -0,0 +1,289 @@
+package security
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// minRSAKeyBits rejects RSA keys too short to be trusted with signatures
+	minRSAKeyBits = 2048
+
+	// maxJWKSSize is far more than the key set of any identity provider
+	maxJWKSSize = 1 << 20
+
+	// defaultJWKSRefreshInterval is how long fetched keys are used before they are fetched again
+	defaultJWKSRefreshInterval = 15 * time.Minute
+
+	// defaultJWKSMinRefreshInterval keeps tokens with unknown key ids from fetching the key set on every request
+	defaultJWKSMinRefreshInterval = time.Minute
+)
+
+// ErrUnknownJWK is returned when no key of the set matches the key id of a token
+var ErrUnknownJWK = errors.New("unknown key id")
+
+// PublicKey is a verification key of a JSON Web Key Set
+type PublicKey struct {
+	ID string
+	// Algorithm restricts the key to one signature algorithm, empty allows every algorithm matching the key type
+	Algorithm string
+	Key       crypto.PublicKey
+}
+
+// KeySet looks up the key a token was signed with, an empty id matches a set with a single key
+type KeySet interface {
+	Key(ctx context.Context, id string) (PublicKey, error)
+}
+
+// jwk is a single key of a JSON Web Key Set as described in RFC 7517, only signature keys are read
+type jwk struct {
+	KeyType   string `json:"kty"`
+	ID        string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+	Curve     string `json:"crv"`
+	N         string `json:"n"`
+	E         string `json:"e"`
+	X         string `json:"x"`
+	Y         string `json:"y"`
+}
+
+// ParseJWKS reads the signature keys of a JSON Web Key Set. Keys of unsupported types and encryption keys
+// are skipped, a set without a single usable key is an error.
+func ParseJWKS(data []byte) ([]PublicKey, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, errors.Wrap(err, "invalid key set")
+	}
+
+	var keys []PublicKey
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		key, err := k.publicKey()
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("invalid key %q", k.ID))
+		}
+		if key == nil {
+			continue
+		}
+
+		keys = append(keys, PublicKey{ID: k.ID, Algorithm: k.Algorithm, Key: key})
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("key set has no signature keys")
+	}
+
+	return keys, nil
+}
+
+// publicKey decodes the key, nil means the key type is not supported
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.KeyType {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if n.BitLen() < minRSAKeyBits {
+			return nil, errors.Errorf("rsa key has %d bits, at least %d are required", n.BitLen(), minRSAKeyBits)
+		}
+		if !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+			return nil, errors.New("rsa key has an invalid exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		if k.Curve != "P-256" {
+			return nil, nil
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !elliptic.P256().IsOnCurve(x, y) {
+			return nil, errors.New("ec key is not on the curve")
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+	case "OKP":
+		if k.Curve != "Ed25519" {
+			return nil, nil
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("ed25519 key has an invalid length")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, nil
+	}
+}
+
+// decodeBigInt decodes a base64url encoded unsigned big endian integer
+func decodeBigInt(value string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(b) == 0 {
+		return nil, errors.New("invalid base64url integer")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// findKey returns the key with the id, a token without id only matches a set with a single key
+func findKey(keys []PublicKey, id string) (PublicKey, bool) {
+	if id == "" {
+		if len(keys) == 1 {
+			return keys[0], true
+		}
+		return PublicKey{}, false
+	}
+
+	for _, key := range keys {
+		if key.ID == id {
+			return key, true
+		}
+	}
+	return PublicKey{}, false
+}
+
+// StaticKeySet is a key set that never changes, e.g. read from a file
+type StaticKeySet []PublicKey
+
+// LoadJWKSFile reads the key set from a JSON Web Key Set file
+func LoadJWKSFile(path string) (StaticKeySet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("failed to read key set file %s", path))
+	}
+
+	keys, err := ParseJWKS(data)
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (s StaticKeySet) Key(_ context.Context, id string) (PublicKey, error) {
+	key, ok := findKey(s, id)
+	if !ok {
+		return PublicKey{}, ErrUnknownJWK
+	}
+	return key, nil
+}
+
+// RemoteKeySet fetches the key set from the JWKS URL of the identity provider. The keys are cached for
+// RefreshInterval, a token with an unknown key id fetches them again so rotated keys are picked up,
+// but not more often than MinRefreshInterval.
+type RemoteKeySet struct {
+	URL                string
+	HTTPClient         *http.Client
+	RefreshInterval    time.Duration
+	MinRefreshInterval time.Duration
+
+	mu          sync.Mutex
+	keys        []PublicKey
+	fetchedAt   time.Time
+	attemptedAt time.Time
+}
+
+func (s *RemoteKeySet) Key(ctx context.Context, id string) (PublicKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	refreshInterval := s.RefreshInterval
+	if refreshInterval <= 0 {
+		refreshInterval = defaultJWKSRefreshInterval
+	}
+	minRefreshInterval := s.MinRefreshInterval
+	if minRefreshInterval <= 0 {
+		minRefreshInterval = defaultJWKSMinRefreshInterval
+	}
+
+	key, ok := findKey(s.keys, id)
+	if ok && time.Since(s.fetchedAt) < refreshInterval {
+		return key, nil
+	}
+
+	// Fetching again right after the last attempt can not help, the identity provider does not rotate that fast
+	if time.Since(s.attemptedAt) < minRefreshInterval {
+		if ok {
+			return key, nil
+		}
+		return PublicKey{}, ErrUnknownJWK
+	}
+
+	s.attemptedAt = time.Now()
+	keys, err := s.fetch(ctx)
+	if err != nil {
+		// Keys that were fetched before stay usable while the identity provider can not be reached
+		if ok {
+			return key, nil
+		}
+		return PublicKey{}, err
+	}
+	s.keys = keys
+	s.fetchedAt = s.attemptedAt
+
+	key, ok = findKey(s.keys, id)
+	if !ok {
+		return PublicKey{}, ErrUnknownJWK
+	}
+	return key, nil
+}
+
+// fetch downloads and parses the key set
+func (s *RemoteKeySet) fetch(ctx context.Context) ([]PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create key set request")
+	}
+	req.Header.Set("Accept", "application/json")
+
+	httpClient := s.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("failed to fetch key set from %s", s.URL))
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("failed to fetch key set from %s: %s", s.URL, res.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(res.Body, maxJWKSSize))
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("failed to read key set from %s", s.URL))
+	}
+
+	return ParseJWKS(data)
+}
@@ -0,0 +1,288 @@
+package security
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// JWTAlgorithmRS256 is RSASSA-PKCS1-v1_5 with SHA-256
+	JWTAlgorithmRS256 = "RS256"
+	// JWTAlgorithmES256 is ECDSA on P-256 with SHA-256
+	JWTAlgorithmES256 = "ES256"
+	// JWTAlgorithmEdDSA is Ed25519
+	JWTAlgorithmEdDSA = "EdDSA"
+
+	// maxJWTSize keeps oversized headers from being decoded
+	maxJWTSize = 16 * 1024
+)
+
+// JWTAlgorithms lists every signature algorithm the verifier supports
+var JWTAlgorithms = []string{JWTAlgorithmRS256, JWTAlgorithmES256, JWTAlgorithmEdDSA}
+
+// JWTClaims are the verified claims of a token, Raw holds every claim for claims the verifier does not know
+type JWTClaims struct {
+	Issuer    string
+	Subject   string
+	Audience  []string
+	ExpiresAt time.Time
+	Raw       map[string]json.RawMessage
+}
+
+// StringList reads a claim that holds a single string or an array of strings, a missing claim is empty
+func (c JWTClaims) StringList(name string) ([]string, error) {
+	raw, ok := c.Raw[name]
+	if !ok {
+		return nil, nil
+	}
+	return decodeStringList(raw)
+}
+
+// String reads a claim that holds a single string, a missing claim is empty
+func (c JWTClaims) String(name string) (string, error) {
+	var value string
+	if err := decodeClaim(c.Raw, name, &value); err != nil {
+		return "", err
+	}
+	return value, nil
+}
+
+// JWTVerifier verifies JSON Web Tokens signed by an identity provider. Only the configured algorithms
+// are accepted and the key must belong to the algorithm, so a token can not pick a weaker check.
+type JWTVerifier struct {
+	Issuer     string
+	Audience   string
+	Algorithms []string
+	// ClockSkew is allowed between the clocks of the identity provider and the server
+	ClockSkew time.Duration
+	Keys      KeySet
+	// Now returns the current time, tests set it to check expired tokens
+	Now func() time.Time
+}
+
+// jwtHeader is the JOSE header of a signed token
+type jwtHeader struct {
+	Algorithm string   `json:"alg"`
+	KeyID     string   `json:"kid"`
+	Critical  []string `json:"crit"`
+}
+
+// Verify checks the signature of the compact serialized token, then its issuer, audience and validity period
+func (v *JWTVerifier) Verify(ctx context.Context, token string) (JWTClaims, error) {
+	if len(token) > maxJWTSize {
+		return JWTClaims{}, errors.New("token is too large")
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return JWTClaims{}, errors.New("token is not a signed JWT")
+	}
+
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return JWTClaims{}, errors.Wrap(err, "invalid token header")
+	}
+	if len(header.Critical) > 0 {
+		return JWTClaims{}, errors.New("token has unsupported critical header parameters")
+	}
+	if !v.allowed(header.Algorithm) {
+		return JWTClaims{}, errors.Errorf("token algorithm %q is not allowed", header.Algorithm)
+	}
+
+	key, err := v.Keys.Key(ctx, header.KeyID)
+	if err != nil {
+		return JWTClaims{}, errors.Wrap(err, "failed to find the key of the token")
+	}
+	if key.Algorithm != "" && key.Algorithm != header.Algorithm {
+		return JWTClaims{}, errors.Errorf("key %q is not for algorithm %s", key.ID, header.Algorithm)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return JWTClaims{}, errors.New("invalid token signature encoding")
+	}
+	if err := verifySignature(header.Algorithm, key.Key, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return JWTClaims{}, err
+	}
+
+	var raw map[string]json.RawMessage
+	if err := decodeSegment(parts[1], &raw); err != nil {
+		return JWTClaims{}, errors.Wrap(err, "invalid token claims")
+	}
+
+	return v.validate(raw)
+}
+
+// allowed reports whether the algorithm is configured and supported
+func (v *JWTVerifier) allowed(algorithm string) bool {
+	for _, a := range v.Algorithms {
+		if a == algorithm {
+			for _, supported := range JWTAlgorithms {
+				if supported == algorithm {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// validate checks the registered claims of RFC 7519, the expiration time is required
+func (v *JWTVerifier) validate(raw map[string]json.RawMessage) (JWTClaims, error) {
+	claims := JWTClaims{Raw: raw}
+
+	if err := decodeClaim(raw, "iss", &claims.Issuer); err != nil {
+		return JWTClaims{}, err
+	}
+	if claims.Issuer != v.Issuer {
+		return JWTClaims{}, errors.Errorf("token issuer %q is not trusted", claims.Issuer)
+	}
+
+	if err := decodeClaim(raw, "sub", &claims.Subject); err != nil {
+		return JWTClaims{}, err
+	}
+	if claims.Subject == "" {
+		return JWTClaims{}, errors.New("token has no subject")
+	}
+
+	audience, err := claims.StringList("aud")
+	if err != nil {
+		return JWTClaims{}, errors.Wrap(err, "invalid aud claim")
+	}
+	claims.Audience = audience
+	if !contains(audience, v.Audience) {
+		return JWTClaims{}, errors.New("token is not issued for this audience")
+	}
+
+	now := time.Now()
+	if v.Now != nil {
+		now = v.Now()
+	}
+
+	var exp, nbf, iat *float64
+	for name, target := range map[string]**float64{"exp": &exp, "nbf": &nbf, "iat": &iat} {
+		if err := decodeClaim(raw, name, target); err != nil {
+			return JWTClaims{}, err
+		}
+	}
+
+	if exp == nil {
+		return JWTClaims{}, errors.New("token has no expiration time")
+	}
+	claims.ExpiresAt = unixTime(*exp)
+	if !now.Before(claims.ExpiresAt.Add(v.ClockSkew)) {
+		return JWTClaims{}, errors.New("token is expired")
+	}
+	if nbf != nil && now.Add(v.ClockSkew).Before(unixTime(*nbf)) {
+		return JWTClaims{}, errors.New("token is not valid yet")
+	}
+	if iat != nil && now.Add(v.ClockSkew).Before(unixTime(*iat)) {
+		return JWTClaims{}, errors.New("token is issued in the future")
+	}
+
+	return claims, nil
+}
+
+// verifySignature checks the signature of the signing input with the key of the algorithm
+func verifySignature(algorithm string, key crypto.PublicKey, input []byte, signature []byte) error {
+	switch algorithm {
+	case JWTAlgorithmRS256:
+		rsaKey, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return errors.New("key is not an RSA key")
+		}
+		digest := sha256.Sum256(input)
+		if err := rsa.VerifyPKCS1v15(rsaKey, crypto.SHA256, digest[:], signature); err != nil {
+			return errors.New("invalid token signature")
+		}
+	case JWTAlgorithmES256:
+		ecKey, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return errors.New("key is not an EC key")
+		}
+		// JWS signatures are R and S as two 32 byte big endian integers, not ASN.1
+		if len(signature) != 64 {
+			return errors.New("invalid token signature")
+		}
+		digest := sha256.Sum256(input)
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		if !ecdsa.Verify(ecKey, digest[:], r, s) {
+			return errors.New("invalid token signature")
+		}
+	case JWTAlgorithmEdDSA:
+		edKey, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return errors.New("key is not an Ed25519 key")
+		}
+		if !ed25519.Verify(edKey, input, signature) {
+			return errors.New("invalid token signature")
+		}
+	default:
+		return errors.Errorf("unsupported algorithm %q", algorithm)
+	}
+
+	return nil
+}
+
+// decodeSegment decodes a base64url encoded JSON segment of the token
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// decodeClaim decodes the claim into target, a missing claim leaves it as it is
+func decodeClaim(raw map[string]json.RawMessage, name string, target interface{}) error {
+	value, ok := raw[name]
+	if !ok {
+		return nil
+	}
+	if err := json.Unmarshal(value, target); err != nil {
+		return errors.Errorf("invalid %s claim", name)
+	}
+	return nil
+}
+
+// decodeStringList decodes a single string or an array of strings
+func decodeStringList(raw json.RawMessage) ([]string, error) {
+	var single string
+	if err := json.Unmarshal(raw, &single); err == nil {
+		return []string{single}, nil
+	}
+
+	var list []string
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return nil, errors.New("must be a string or an array of strings")
+	}
+	return list, nil
+}
+
+// unixTime converts a NumericDate, seconds since the epoch that may have a fraction
+func unixTime(seconds float64) time.Time {
+	whole, fraction := math.Modf(seconds)
+	return time.Unix(int64(whole), int64(fraction*float64(time.Second)))
+}
+
+func contains(list []string, value string) bool {
+	for _, entry := range list {
+		if entry == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,368 @@
+package security
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testIssuer   = "https://sso.example.com"
+	testAudience = "secret-server"
+)
+
+// testSigner signs tokens with a locally generated key of one algorithm
+type testSigner struct {
+	id        string
+	algorithm string
+	key       crypto.Signer
+}
+
+// newTestSigners generates a key for every supported algorithm
+func newTestSigners(t *testing.T) []testSigner {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	return []testSigner{
+		{id: "rsa", algorithm: JWTAlgorithmRS256, key: rsaKey},
+		{id: "ec", algorithm: JWTAlgorithmES256, key: ecKey},
+		{id: "ed", algorithm: JWTAlgorithmEdDSA, key: edKey},
+	}
+}
+
+// jwk returns the public key of the signer as a JSON Web Key
+func (s testSigner) jwk() map[string]string {
+	encode := base64.RawURLEncoding.EncodeToString
+	switch key := s.key.Public().(type) {
+	case *rsa.PublicKey:
+		return map[string]string{"kty": "RSA", "kid": s.id, "alg": s.algorithm, "use": "sig",
+			"n": encode(key.N.Bytes()), "e": encode(big.NewInt(int64(key.E)).Bytes())}
+	case *ecdsa.PublicKey:
+		return map[string]string{"kty": "EC", "kid": s.id, "crv": "P-256",
+			"x": encode(key.X.FillBytes(make([]byte, 32))), "y": encode(key.Y.FillBytes(make([]byte, 32)))}
+	case ed25519.PublicKey:
+		return map[string]string{"kty": "OKP", "kid": s.id, "crv": "Ed25519", "x": encode(key)}
+	}
+	return nil
+}
+
+// sign returns the compact serialized token of the claims
+func (s testSigner) sign(t *testing.T, header map[string]interface{}, claims map[string]interface{}) string {
+	if header == nil {
+		header = map[string]interface{}{"alg": s.algorithm, "kid": s.id, "typ": "JWT"}
+	}
+
+	headerJSON, err := json.Marshal(header)
+	require.NoError(t, err)
+	claimsJSON, err := json.Marshal(claims)
+	require.NoError(t, err)
+
+	input := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(claimsJSON)
+
+	var signature []byte
+	switch key := s.key.(type) {
+	case *rsa.PrivateKey:
+		digest := sha256.Sum256([]byte(input))
+		signature, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	case *ecdsa.PrivateKey:
+		digest := sha256.Sum256([]byte(input))
+		var r, sig *big.Int
+		r, sig, err = ecdsa.Sign(rand.Reader, key, digest[:])
+		signature = append(r.FillBytes(make([]byte, 32)), sig.FillBytes(make([]byte, 32))...)
+	case ed25519.PrivateKey:
+		signature = ed25519.Sign(key, []byte(input))
+	}
+	require.NoError(t, err)
+
+	return input + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// testJWKS returns the JSON Web Key Set of the signers
+func testJWKS(t *testing.T, signers ...testSigner) []byte {
+	var keys []map[string]string
+	for _, s := range signers {
+		keys = append(keys, s.jwk())
+	}
+	data, err := json.Marshal(map[string]interface{}{"keys": keys})
+	require.NoError(t, err)
+	return data
+}
+
+// validClaims returns claims the verifier accepts, tests change them to break one check at a time
+func validClaims() map[string]interface{} {
+	now := time.Now()
+	return map[string]interface{}{
+		"iss":    testIssuer,
+		"sub":    "alice",
+		"aud":    testAudience,
+		"exp":    now.Add(time.Hour).Unix(),
+		"nbf":    now.Add(-time.Minute).Unix(),
+		"iat":    now.Add(-time.Minute).Unix(),
+		"groups": []string{"engineering", "secret-admins"},
+	}
+}
+
+func newTestVerifier(t *testing.T, signers ...testSigner) *JWTVerifier {
+	keys, err := ParseJWKS(testJWKS(t, signers...))
+	require.NoError(t, err)
+
+	return &JWTVerifier{
+		Issuer:     testIssuer,
+		Audience:   testAudience,
+		Algorithms: JWTAlgorithms,
+		ClockSkew:  time.Minute,
+		Keys:       StaticKeySet(keys),
+	}
+}
+
+func TestJWTVerifier_Algorithms(t *testing.T) {
+	signers := newTestSigners(t)
+	verifier := newTestVerifier(t, signers...)
+
+	for _, signer := range signers {
+		t.Run(signer.algorithm, func(t *testing.T) {
+			claims, err := verifier.Verify(context.Background(), signer.sign(t, nil, validClaims()))
+			require.NoError(t, err)
+
+			assert.Equal(t, "alice", claims.Subject)
+			assert.Equal(t, []string{testAudience}, claims.Audience)
+
+			groups, err := claims.StringList("groups")
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"engineering", "secret-admins"}, groups)
+		})
+	}
+}
+
+func TestJWTVerifier_Rejects(t *testing.T) {
+	signers := newTestSigners(t)
+	rsaSigner, ecSigner := signers[0], signers[1]
+	verifier := newTestVerifier(t, signers...)
+
+	other := newTestSigners(t)[0]
+
+	tests := []struct {
+		name   string
+		token  func(t *testing.T) string
+		verify func(v *JWTVerifier)
+	}{
+		{name: "malformed token", token: func(t *testing.T) string { return "not.a-token" }},
+		{name: "none algorithm", token: func(t *testing.T) string {
+			token := rsaSigner.sign(t, map[string]interface{}{"alg": "none", "kid": "rsa"}, validClaims())
+			return token[:strings.LastIndex(token, ".")+1]
+		}},
+		{name: "HS256 with the public key", token: func(t *testing.T) string {
+			return rsaSigner.sign(t, map[string]interface{}{"alg": "HS256", "kid": "rsa"}, validClaims())
+		}},
+		{name: "algorithm not configured", token: func(t *testing.T) string {
+			return ecSigner.sign(t, nil, validClaims())
+		}, verify: func(v *JWTVerifier) { v.Algorithms = []string{JWTAlgorithmRS256} }},
+		{name: "key of another algorithm", token: func(t *testing.T) string {
+			return ecSigner.sign(t, map[string]interface{}{"alg": JWTAlgorithmES256, "kid": "rsa"}, validClaims())
+		}},
+		{name: "unknown key id", token: func(t *testing.T) string {
+			return rsaSigner.sign(t, map[string]interface{}{"alg": JWTAlgorithmRS256, "kid": "other"}, validClaims())
+		}},
+		{name: "signed by another key", token: func(t *testing.T) string {
+			return other.sign(t, nil, validClaims())
+		}},
+		{name: "critical header", token: func(t *testing.T) string {
+			return rsaSigner.sign(t, map[string]interface{}{"alg": JWTAlgorithmRS256, "kid": "rsa", "crit": []string{"exp"}}, validClaims())
+		}},
+		{name: "wrong issuer", token: func(t *testing.T) string {
+			claims := validClaims()
+			claims["iss"] = "https://evil.example.com"
+			return rsaSigner.sign(t, nil, claims)
+		}},
+		{name: "wrong audience", token: func(t *testing.T) string {
+			claims := validClaims()
+			claims["aud"] = []string{"other-service"}
+			return rsaSigner.sign(t, nil, claims)
+		}},
+		{name: "missing subject", token: func(t *testing.T) string {
+			claims := validClaims()
+			delete(claims, "sub")
+			return rsaSigner.sign(t, nil, claims)
+		}},
+		{name: "missing expiration", token: func(t *testing.T) string {
+			claims := validClaims()
+			delete(claims, "exp")
+			return rsaSigner.sign(t, nil, claims)
+		}},
+		{name: "expired", token: func(t *testing.T) string {
+			claims := validClaims()
+			claims["exp"] = time.Now().Add(-2 * time.Minute).Unix()
+			return rsaSigner.sign(t, nil, claims)
+		}},
+		{name: "not valid yet", token: func(t *testing.T) string {
+			claims := validClaims()
+			claims["nbf"] = time.Now().Add(2 * time.Minute).Unix()
+			return rsaSigner.sign(t, nil, claims)
+		}},
+		{name: "issued in the future", token: func(t *testing.T) string {
+			claims := validClaims()
+			claims["iat"] = time.Now().Add(2 * time.Minute).Unix()
+			return rsaSigner.sign(t, nil, claims)
+		}},
+		{name: "expiration of the wrong type", token: func(t *testing.T) string {
+			claims := validClaims()
+			claims["exp"] = "tomorrow"
+			return rsaSigner.sign(t, nil, claims)
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := *verifier
+			if tt.verify != nil {
+				tt.verify(&v)
+			}
+
+			_, err := v.Verify(context.Background(), tt.token(t))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestJWTVerifier_ClockSkew(t *testing.T) {
+	signer := newTestSigners(t)[2]
+	verifier := newTestVerifier(t, signer)
+
+	// Expired half a minute ago is still within the skew of a minute
+	claims := validClaims()
+	claims["exp"] = time.Now().Add(-30 * time.Second).Unix()
+	token := signer.sign(t, nil, claims)
+
+	_, err := verifier.Verify(context.Background(), token)
+	assert.NoError(t, err)
+
+	verifier.Now = func() time.Time { return time.Now().Add(time.Minute) }
+	_, err = verifier.Verify(context.Background(), token)
+	assert.Error(t, err)
+}
+
+func TestParseJWKS(t *testing.T) {
+	signers := newTestSigners(t)
+
+	// Encryption keys and unsupported curves are skipped
+	data, err := json.Marshal(map[string]interface{}{"keys": []map[string]string{
+		signers[0].jwk(),
+		{"kty": "RSA", "kid": "enc", "use": "enc", "n": "AQAB", "e": "AQAB"},
+		{"kty": "EC", "kid": "p384", "crv": "P-384", "x": "AA", "y": "AA"},
+	}})
+	require.NoError(t, err)
+
+	keys, err := ParseJWKS(data)
+	require.NoError(t, err)
+	if assert.Len(t, keys, 1) {
+		assert.Equal(t, "rsa", keys[0].ID)
+		assert.Equal(t, JWTAlgorithmRS256, keys[0].Algorithm)
+	}
+
+	// Short RSA keys are rejected
+	_, err = ParseJWKS([]byte(`{"keys":[{"kty":"RSA","kid":"short","n":"AQAB","e":"AQAB"}]}`))
+	assert.Error(t, err)
+
+	// A point that is not on the curve is rejected
+	_, err = ParseJWKS([]byte(`{"keys":[{"kty":"EC","kid":"bad","crv":"P-256","x":"AQ","y":"AQ"}]}`))
+	assert.Error(t, err)
+
+	_, err = ParseJWKS([]byte(`{"keys":[]}`))
+	assert.Error(t, err)
+}
+
+func TestLoadJWKSFile(t *testing.T) {
+	signer := newTestSigners(t)[1]
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, testJWKS(t, signer), 0600))
+
+	keys, err := LoadJWKSFile(path)
+	require.NoError(t, err)
+
+	// A set with a single key also matches tokens without key id
+	key, err := keys.Key(context.Background(), "")
+	assert.NoError(t, err)
+	assert.Equal(t, "ec", key.ID)
+
+	_, err = keys.Key(context.Background(), "other")
+	assert.ErrorIs(t, err, ErrUnknownJWK)
+
+	_, err = LoadJWKSFile(filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err)
+}
+
+func TestRemoteKeySet(t *testing.T) {
+	signers := newTestSigners(t)
+
+	// The identity provider starts with the RSA key and rotates to the EC key
+	var rotated atomic.Bool
+	var fetches atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		if rotated.Load() {
+			_, _ = w.Write(testJWKS(t, signers[1]))
+			return
+		}
+		_, _ = w.Write(testJWKS(t, signers[0]))
+	}))
+	defer srv.Close()
+
+	keys := &RemoteKeySet{URL: srv.URL, MinRefreshInterval: time.Millisecond}
+
+	key, err := keys.Key(context.Background(), "rsa")
+	require.NoError(t, err)
+	assert.Equal(t, "rsa", key.ID)
+
+	// Known keys are served from the cache
+	_, err = keys.Key(context.Background(), "rsa")
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), fetches.Load())
+
+	// An unknown key id fetches the rotated set
+	rotated.Store(true)
+	time.Sleep(2 * time.Millisecond)
+	key, err = keys.Key(context.Background(), "ec")
+	require.NoError(t, err)
+	assert.Equal(t, "ec", key.ID)
+	assert.Equal(t, int32(2), fetches.Load())
+
+	// Unknown key ids do not fetch again within the minimum refresh interval
+	keys.MinRefreshInterval = time.Hour
+	_, err = keys.Key(context.Background(), "missing")
+	assert.ErrorIs(t, err, ErrUnknownJWK)
+	assert.Equal(t, int32(2), fetches.Load())
+}
+
+func TestRemoteKeySet_Unavailable(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	keys := &RemoteKeySet{URL: srv.URL}
+	_, err := keys.Key(context.Background(), "rsa")
+	assert.Error(t, err)
+}
@@ -0,0 +1,102 @@
+package security
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ErrUnknownKeyID is returned when a data key was wrapped by a master key the provider does not hold
+var ErrUnknownKeyID = errors.New("unknown master key id")
+
+// KeyProvider wraps and unwraps per secret data keys with a master key that never leaves the provider
+type KeyProvider interface {
+	// ActiveKeyID returns the id of the master key new data keys are wrapped with
+	ActiveKeyID(ctx context.Context) (string, error)
+	// WrapKey encrypts the data key and returns it together with the id of the master key used
+	WrapKey(ctx context.Context, dataKey []byte) (wrappedKey string, keyID string, err error)
+	// UnwrapKey decrypts a data key wrapped by the master key with the given id
+	UnwrapKey(ctx context.Context, wrappedKey string, keyID string) ([]byte, error)
+}
+
+// LocalKeyProvider wraps data keys with a master key held in process memory,
+// the master key is read from the environment or a file at startup
+type LocalKeyProvider struct {
+	keyID     string
+	masterKey []byte
+}
+
+// NewLocalKeyProvider creates a key provider for the 32 byte master key
+func NewLocalKeyProvider(keyID string, masterKey []byte) (*LocalKeyProvider, error) {
+	if keyID == "" {
+		return nil, errors.New("master key id is required")
+	}
+
+	if len(keyID) > maxKeyIDLength {
+		return nil, errors.New("master key id is too long")
+	}
+
+	if len(masterKey) != keySize {
+		return nil, errors.Errorf("master key must be %d bytes long", keySize)
+	}
+
+	return &LocalKeyProvider{keyID: keyID, masterKey: masterKey}, nil
+}
+
+// ParseMasterKey decodes a hex or standard base64 encoded master key
+func ParseMasterKey(value string) ([]byte, error) {
+	value = strings.TrimSpace(value)
+
+	if key, err := hex.DecodeString(value); err == nil && len(key) == keySize {
+		return key, nil
+	}
+
+	if key, err := base64.StdEncoding.DecodeString(value); err == nil && len(key) == keySize {
+		return key, nil
+	}
+
+	return nil, errors.Errorf("master key must be %d bytes encoded as hex or base64", keySize)
+}
+
+// ActiveKeyID returns the id of the master key
+func (p *LocalKeyProvider) ActiveKeyID(_ context.Context) (string, error) {
+	return p.keyID, nil
+}
+
+// WrapKey seals the data key in an envelope under the master key, the key id is authenticated with it
+func (p *LocalKeyProvider) WrapKey(_ context.Context, dataKey []byte) (string, string, error) {
+	env, err := SealEnvelope(p.masterKey, p.keyID, dataKey)
+	if err != nil {
+		return "", "", errors.Wrap(err, "failed to wrap data key")
+	}
+
+	return env.Encode(), p.keyID, nil
+}
+
+// UnwrapKey opens a data key wrapped by WrapKey
+func (p *LocalKeyProvider) UnwrapKey(_ context.Context, wrappedKey string, keyID string) ([]byte, error) {
+	if keyID != p.keyID {
+		return nil, errors.Wrap(ErrUnknownKeyID, keyID)
+	}
+
+	env, err := DecodeEnvelope(wrappedKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid wrapped data key")
+	}
+
+	if env.KeyID != keyID {
+		return nil, errors.New("wrapped data key belongs to a different master key")
+	}
+
+	dataKey, err := env.Open(p.masterKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to unwrap data key")
+	}
+
+	return dataKey, nil
+}
+
+var _ KeyProvider = (*LocalKeyProvider)(nil)
@@ -0,0 +1,84 @@
+package security
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalKeyProvider_RoundTrip(t *testing.T) {
+	provider, err := NewLocalKeyProvider("master", bytes.Repeat([]byte{7}, 32))
+	assert.NoError(t, err)
+
+	dataKey := bytes.Repeat([]byte{1}, 32)
+
+	wrappedKey, keyID, err := provider.WrapKey(context.Background(), dataKey)
+	assert.NoError(t, err, "WrapKey should not return an error")
+	assert.Equal(t, "master", keyID)
+	assert.NotContains(t, wrappedKey, hex.EncodeToString(dataKey), "The wrapped key must not contain the data key")
+
+	unwrapped, err := provider.UnwrapKey(context.Background(), wrappedKey, keyID)
+	assert.NoError(t, err, "UnwrapKey should not return an error")
+	assert.Equal(t, dataKey, unwrapped)
+}
+
+func TestLocalKeyProvider_UnknownKeyID(t *testing.T) {
+	provider, err := NewLocalKeyProvider("master", bytes.Repeat([]byte{7}, 32))
+	assert.NoError(t, err)
+
+	wrappedKey, _, err := provider.WrapKey(context.Background(), bytes.Repeat([]byte{1}, 32))
+	assert.NoError(t, err)
+
+	_, err = provider.UnwrapKey(context.Background(), wrappedKey, "other")
+	assert.ErrorIs(t, err, ErrUnknownKeyID)
+}
+
+func TestLocalKeyProvider_WrongMasterKey(t *testing.T) {
+	provider, err := NewLocalKeyProvider("master", bytes.Repeat([]byte{7}, 32))
+	assert.NoError(t, err)
+	other, err := NewLocalKeyProvider("master", bytes.Repeat([]byte{8}, 32))
+	assert.NoError(t, err)
+
+	wrappedKey, keyID, err := provider.WrapKey(context.Background(), bytes.Repeat([]byte{1}, 32))
+	assert.NoError(t, err)
+
+	// A master key with the same id but different bytes must not unwrap the data key
+	_, err = other.UnwrapKey(context.Background(), wrappedKey, keyID)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to unwrap data key")
+}
+
+func TestNewLocalKeyProvider_InvalidInput(t *testing.T) {
+	// Test case: Missing key id
+	_, err := NewLocalKeyProvider("", make([]byte, 32))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "master key id is required")
+
+	// Test case: Master key of the wrong length
+	_, err = NewLocalKeyProvider("master", make([]byte, 16))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "master key must be 32 bytes long")
+}
+
+func TestParseMasterKey(t *testing.T) {
+	raw := bytes.Repeat([]byte{0xab}, 32)
+
+	// Test case: Hex encoded key
+	key, err := ParseMasterKey(hex.EncodeToString(raw))
+	assert.NoError(t, err)
+	assert.Equal(t, raw, key)
+
+	// Test case: Base64 encoded key with surrounding whitespace, as read from a file
+	key, err = ParseMasterKey(" " + base64.StdEncoding.EncodeToString(raw) + "\n")
+	assert.NoError(t, err)
+	assert.Equal(t, raw, key)
+
+	// Test case: Key of the wrong length
+	_, err = ParseMasterKey(hex.EncodeToString(raw[:16]))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "master key must be 32 bytes")
+}
@@ -0,0 +1,85 @@
+package security
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Keyring holds the local master keys. Data keys are always wrapped with the active key,
+// the other keys are decrypt only and stay in the keyring until every data key they wrapped is re-wrapped
+type Keyring struct {
+	active string
+	keys   map[string]*LocalKeyProvider
+}
+
+// NewKeyring creates a keyring from the master keys by id, the active key must be one of them
+func NewKeyring(active string, masterKeys map[string][]byte) (*Keyring, error) {
+	if _, ok := masterKeys[active]; !ok {
+		return nil, errors.Errorf("active master key %q is not in the keyring", active)
+	}
+
+	keyring := Keyring{active: active, keys: make(map[string]*LocalKeyProvider, len(masterKeys))}
+	for keyID, masterKey := range masterKeys {
+		provider, err := NewLocalKeyProvider(keyID, masterKey)
+		if err != nil {
+			return nil, errors.Wrap(err, keyID)
+		}
+		keyring.keys[keyID] = provider
+	}
+
+	return &keyring, nil
+}
+
+// ParseMasterKeys parses a list of id:key pairs separated by commas or new lines
+func ParseMasterKeys(value string) (map[string][]byte, error) {
+	masterKeys := make(map[string][]byte)
+
+	entries := strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == '\n' })
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		keyID, encoded, ok := strings.Cut(entry, ":")
+		if !ok || keyID == "" {
+			return nil, errors.New("master keys must be given as id:key")
+		}
+
+		if _, exists := masterKeys[keyID]; exists {
+			return nil, errors.Errorf("master key %q is given twice", keyID)
+		}
+
+		masterKey, err := ParseMasterKey(encoded)
+		if err != nil {
+			return nil, errors.Wrap(err, keyID)
+		}
+		masterKeys[keyID] = masterKey
+	}
+
+	return masterKeys, nil
+}
+
+// ActiveKeyID returns the id of the key new data keys are wrapped with
+func (k *Keyring) ActiveKeyID(_ context.Context) (string, error) {
+	return k.active, nil
+}
+
+// WrapKey wraps the data key with the active master key
+func (k *Keyring) WrapKey(ctx context.Context, dataKey []byte) (string, string, error) {
+	return k.keys[k.active].WrapKey(ctx, dataKey)
+}
+
+// UnwrapKey unwraps the data key with the master key it was wrapped with, active or decrypt only
+func (k *Keyring) UnwrapKey(ctx context.Context, wrappedKey string, keyID string) ([]byte, error) {
+	provider, ok := k.keys[keyID]
+	if !ok {
+		return nil, errors.Wrap(ErrUnknownKeyID, keyID)
+	}
+
+	return provider.UnwrapKey(ctx, wrappedKey, keyID)
+}
+
+var _ KeyProvider = (*Keyring)(nil)
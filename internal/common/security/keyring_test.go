@@ -0,0 +1,80 @@
+package security
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyring_RotateMasterKey(t *testing.T) {
+	oldKey := bytes.Repeat([]byte{1}, 32)
+	newKey := bytes.Repeat([]byte{2}, 32)
+
+	// Data key wrapped while the old master key was active
+	before, err := NewKeyring("2023", map[string][]byte{"2023": oldKey})
+	assert.NoError(t, err)
+	dataKey := bytes.Repeat([]byte{9}, 32)
+	wrappedKey, keyID, err := before.WrapKey(context.Background(), dataKey)
+	assert.NoError(t, err)
+	assert.Equal(t, "2023", keyID)
+
+	// After the rotation the old master key is decrypt only
+	after, err := NewKeyring("2024", map[string][]byte{"2023": oldKey, "2024": newKey})
+	assert.NoError(t, err)
+
+	activeKeyID, err := after.ActiveKeyID(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "2024", activeKeyID)
+
+	unwrapped, err := after.UnwrapKey(context.Background(), wrappedKey, keyID)
+	assert.NoError(t, err, "Data keys of a decrypt only master key must still unwrap")
+	assert.Equal(t, dataKey, unwrapped)
+
+	// New data keys are wrapped with the active master key only
+	_, keyID, err = after.WrapKey(context.Background(), dataKey)
+	assert.NoError(t, err)
+	assert.Equal(t, "2024", keyID)
+}
+
+func TestKeyring_UnknownKeyID(t *testing.T) {
+	keyring, err := NewKeyring("2024", map[string][]byte{"2024": bytes.Repeat([]byte{2}, 32)})
+	assert.NoError(t, err)
+
+	_, err = keyring.UnwrapKey(context.Background(), "wrapped", "2023")
+	assert.ErrorIs(t, err, ErrUnknownKeyID)
+}
+
+func TestNewKeyring_ActiveKeyMissing(t *testing.T) {
+	_, err := NewKeyring("2024", map[string][]byte{"2023": bytes.Repeat([]byte{1}, 32)})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "active master key \"2024\" is not in the keyring")
+}
+
+func TestParseMasterKeys(t *testing.T) {
+	first := hex.EncodeToString(bytes.Repeat([]byte{1}, 32))
+	second := hex.EncodeToString(bytes.Repeat([]byte{2}, 32))
+
+	// Test case: Comma and new line separated entries
+	masterKeys, err := ParseMasterKeys("2022:" + first + ",\n2023:" + second + "\n")
+	assert.NoError(t, err)
+	assert.Len(t, masterKeys, 2)
+	assert.Equal(t, bytes.Repeat([]byte{2}, 32), masterKeys["2023"])
+
+	// Test case: Empty value
+	masterKeys, err = ParseMasterKeys("")
+	assert.NoError(t, err)
+	assert.Empty(t, masterKeys)
+
+	// Test case: Missing id
+	_, err = ParseMasterKeys(first)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "master keys must be given as id:key")
+
+	// Test case: Duplicate id
+	_, err = ParseMasterKeys("2023:" + first + ",2023:" + second)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "master key \"2023\" is given twice")
+}
@@ -0,0 +1,82 @@
+package security
+
+import (
+	"context"
+	"encoding/base64"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/pkg/errors"
+)
+
+// kmsEncryptionContext binds the wrapped data keys to this service, KMS refuses to decrypt them without it
+var kmsEncryptionContext = map[string]string{"purpose": "secret-server data key"}
+
+// KMSAPI represents the subset of KMS client methods the key provider uses
+type KMSAPI interface {
+	Encrypt(ctx context.Context, params *kms.EncryptInput, optFns ...func(*kms.Options)) (*kms.EncryptOutput, error)
+	Decrypt(ctx context.Context, params *kms.DecryptInput, optFns ...func(*kms.Options)) (*kms.DecryptOutput, error)
+	DescribeKey(ctx context.Context, params *kms.DescribeKeyInput, optFns ...func(*kms.Options)) (*kms.DescribeKeyOutput, error)
+}
+
+// KMSKeyProvider wraps data keys with a KMS key, the master key never leaves KMS
+type KMSKeyProvider struct {
+	Client KMSAPI
+	KeyID  string
+}
+
+// ActiveKeyID resolves the configured KMS key to its ARN, which is the id stored with the wrapped data keys.
+// Data keys wrapped by an older KMS key stay decryptable as long as that key is enabled in KMS.
+func (p KMSKeyProvider) ActiveKeyID(ctx context.Context) (string, error) {
+	out, err := p.Client.DescribeKey(ctx, &kms.DescribeKeyInput{KeyId: aws.String(p.KeyID)})
+	if err != nil {
+		return "", errors.Wrap(err, "failed to describe KMS key")
+	}
+
+	if out.KeyMetadata == nil || out.KeyMetadata.Arn == nil {
+		return "", errors.New("KMS did not return the key ARN")
+	}
+
+	return aws.ToString(out.KeyMetadata.Arn), nil
+}
+
+// WrapKey encrypts the data key with the configured KMS key
+func (p KMSKeyProvider) WrapKey(ctx context.Context, dataKey []byte) (string, string, error) {
+	out, err := p.Client.Encrypt(ctx, &kms.EncryptInput{
+		KeyId:             aws.String(p.KeyID),
+		Plaintext:         dataKey,
+		EncryptionContext: kmsEncryptionContext,
+	})
+	if err != nil {
+		return "", "", errors.Wrap(err, "failed to wrap data key with KMS")
+	}
+
+	// KMS reports the full ARN of the key, which also resolves when the configured id is an alias
+	keyID := aws.ToString(out.KeyId)
+	if keyID == "" {
+		keyID = p.KeyID
+	}
+
+	return base64.StdEncoding.EncodeToString(out.CiphertextBlob), keyID, nil
+}
+
+// UnwrapKey decrypts a data key wrapped by WrapKey
+func (p KMSKeyProvider) UnwrapKey(ctx context.Context, wrappedKey string, keyID string) ([]byte, error) {
+	blob, err := base64.StdEncoding.DecodeString(wrappedKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid base64 in wrapped data key")
+	}
+
+	out, err := p.Client.Decrypt(ctx, &kms.DecryptInput{
+		KeyId:             aws.String(keyID),
+		CiphertextBlob:    blob,
+		EncryptionContext: kmsEncryptionContext,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to unwrap data key with KMS")
+	}
+
+	return out.Plaintext, nil
+}
+
+var _ KeyProvider = KMSKeyProvider{}
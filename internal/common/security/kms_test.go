@@ -0,0 +1,170 @@
+package security
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/golang/mock/gomock"
+	"github.com/nalawade41/secret-server/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+// localKMS is a stand-in for KMS that keeps its keys in memory and checks the encryption context like KMS does
+type localKMS struct {
+	keys map[string]*LocalKeyProvider
+}
+
+func newLocalKMS(t *testing.T, keyIDs ...string) *localKMS {
+	l := &localKMS{keys: make(map[string]*LocalKeyProvider)}
+	for i, keyID := range keyIDs {
+		provider, err := NewLocalKeyProvider(keyID, bytes.Repeat([]byte{byte(i + 1)}, 32))
+		assert.NoError(t, err)
+		l.keys[keyID] = provider
+	}
+	return l
+}
+
+func (l *localKMS) Encrypt(ctx context.Context, params *kms.EncryptInput, _ ...func(*kms.Options)) (*kms.EncryptOutput, error) {
+	provider, ok := l.keys[aws.ToString(params.KeyId)]
+	if !ok {
+		return nil, errors.New("NotFoundException")
+	}
+
+	wrappedKey, keyID, err := provider.WrapKey(ctx, append([]byte(params.EncryptionContext["purpose"]+"|"), params.Plaintext...))
+	if err != nil {
+		return nil, err
+	}
+
+	return &kms.EncryptOutput{KeyId: aws.String("arn:aws:kms:local:" + keyID), CiphertextBlob: []byte(wrappedKey)}, nil
+}
+
+func (l *localKMS) Decrypt(ctx context.Context, params *kms.DecryptInput, _ ...func(*kms.Options)) (*kms.DecryptOutput, error) {
+	keyID := aws.ToString(params.KeyId)
+	provider, ok := l.keys[keyID[len("arn:aws:kms:local:"):]]
+	if !ok {
+		return nil, errors.New("NotFoundException")
+	}
+
+	plaintext, err := provider.UnwrapKey(ctx, string(params.CiphertextBlob), provider.keyID)
+	if err != nil {
+		return nil, errors.New("InvalidCiphertextException")
+	}
+
+	prefix := []byte(params.EncryptionContext["purpose"] + "|")
+	if !bytes.HasPrefix(plaintext, prefix) {
+		return nil, errors.New("InvalidCiphertextException")
+	}
+
+	return &kms.DecryptOutput{Plaintext: plaintext[len(prefix):]}, nil
+}
+
+func (l *localKMS) DescribeKey(_ context.Context, params *kms.DescribeKeyInput, _ ...func(*kms.Options)) (*kms.DescribeKeyOutput, error) {
+	keyID := aws.ToString(params.KeyId)
+	if _, ok := l.keys[keyID]; !ok {
+		return nil, errors.New("NotFoundException")
+	}
+
+	return &kms.DescribeKeyOutput{KeyMetadata: &types.KeyMetadata{Arn: aws.String("arn:aws:kms:local:" + keyID)}}, nil
+}
+
+func TestKMSKeyProvider_RoundTrip(t *testing.T) {
+	provider := KMSKeyProvider{Client: newLocalKMS(t, "secret-server"), KeyID: "secret-server"}
+
+	dataKey := bytes.Repeat([]byte{1}, 32)
+
+	wrappedKey, keyID, err := provider.WrapKey(context.Background(), dataKey)
+	assert.NoError(t, err, "WrapKey should not return an error")
+	assert.Equal(t, "arn:aws:kms:local:secret-server", keyID, "The key id reported by KMS should be stored")
+
+	unwrapped, err := provider.UnwrapKey(context.Background(), wrappedKey, keyID)
+	assert.NoError(t, err, "UnwrapKey should not return an error")
+	assert.Equal(t, dataKey, unwrapped)
+
+	// The active key id matches the id stored with the wrapped data keys
+	activeKeyID, err := provider.ActiveKeyID(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, keyID, activeKeyID)
+}
+
+func TestKMSKeyProvider_Rotation(t *testing.T) {
+	localKMS := newLocalKMS(t, "key-2023", "key-2024")
+	oldEncryptor := RealEncryptor{KeyProvider: KMSKeyProvider{Client: localKMS, KeyID: "key-2023"}}
+	newEncryptor := RealEncryptor{KeyProvider: KMSKeyProvider{Client: localKMS, KeyID: "key-2024"}}
+
+	linkKey, err := oldEncryptor.GenerateKey()
+	assert.NoError(t, err)
+	dataKey, err := oldEncryptor.GenerateDataKey(context.Background(), linkKey)
+	assert.NoError(t, err)
+
+	// Data keys of the old KMS key are re-wrapped under the new one without changing the content key
+	wrappedKey, keyID, err := newEncryptor.RewrapDataKey(context.Background(), dataKey.WrappedKey, dataKey.KeyID)
+	assert.NoError(t, err)
+	assert.Equal(t, "arn:aws:kms:local:key-2024", keyID)
+
+	contentKey, err := newEncryptor.UnwrapDataKey(context.Background(), linkKey, wrappedKey, keyID)
+	assert.NoError(t, err)
+	assert.Equal(t, dataKey.ContentKey, contentKey)
+}
+
+func TestKMSKeyProvider_WithEncryptor(t *testing.T) {
+	encryptor := RealEncryptor{KeyProvider: KMSKeyProvider{Client: newLocalKMS(t, "secret-server"), KeyID: "secret-server"}}
+
+	linkKey, err := encryptor.GenerateKey()
+	assert.NoError(t, err)
+
+	dataKey, err := encryptor.GenerateDataKey(context.Background(), linkKey)
+	assert.NoError(t, err)
+
+	ciphertext, err := encryptor.EncryptMessage("Hello, World!", dataKey.ContentKey)
+	assert.NoError(t, err)
+
+	contentKey, err := encryptor.UnwrapDataKey(context.Background(), linkKey, dataKey.WrappedKey, dataKey.KeyID)
+	assert.NoError(t, err)
+
+	plaintext, err := encryptor.DecryptMessage(ciphertext, contentKey)
+	assert.NoError(t, err)
+	assert.Equal(t, "Hello, World!", plaintext)
+}
+
+func TestKMSKeyProvider_Errors(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockKMS := mocks.NewMockKMSAPI(ctrl)
+	provider := KMSKeyProvider{Client: mockKMS, KeyID: "alias/secret-server"}
+
+	// Test case: Encrypt fails
+	mockKMS.EXPECT().Encrypt(gomock.Any(), &kms.EncryptInput{
+		KeyId:             aws.String("alias/secret-server"),
+		Plaintext:         []byte("datakey"),
+		EncryptionContext: kmsEncryptionContext,
+	}).Return(nil, errors.New("AccessDeniedException"))
+
+	_, _, err := provider.WrapKey(context.Background(), []byte("datakey"))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to wrap data key with KMS")
+
+	// Test case: Decrypt fails
+	mockKMS.EXPECT().Decrypt(gomock.Any(), gomock.Any()).Return(nil, errors.New("InvalidCiphertextException"))
+
+	_, err = provider.UnwrapKey(context.Background(), "d3JhcHBlZA==", "alias/secret-server")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to unwrap data key with KMS")
+
+	// Test case: DescribeKey fails
+	mockKMS.EXPECT().DescribeKey(gomock.Any(), gomock.Any()).Return(nil, errors.New("NotFoundException"))
+
+	_, err = provider.ActiveKeyID(context.Background())
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to describe KMS key")
+
+	// Test case: Wrapped key is not base64, KMS is not called
+	_, err = provider.UnwrapKey(context.Background(), "not base64!", "alias/secret-server")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid base64 in wrapped data key")
+}
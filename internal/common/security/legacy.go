@@ -0,0 +1,99 @@
+package security
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/hex"
+
+	"github.com/pkg/errors"
+)
+
+// The functions in this file read secrets written before the switch to authenticated
+// encryption. Those were AES-128-CBC without a MAC, stored as lower case hex of IV and ciphertext.
+
+// isLegacyCiphertext reports whether the ciphertext is in the hex encoded AES-CBC format
+func isLegacyCiphertext(ciphertext string) bool {
+	if ciphertext == "" || len(ciphertext)%2 != 0 {
+		return false
+	}
+
+	for _, c := range ciphertext {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// deriveKeyFromHash derives a secret key from the hex encoded key material
+func deriveKeyFromHash(hash string) ([]byte, error) {
+	if len(hash) < 32 {
+		return nil, errors.New("hash must be at least 32 characters long")
+	}
+
+	// Use the first 32 characters of the hash as the key
+	key, err := hex.DecodeString(hash[:32])
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid hex in key derivation")
+	}
+	return key, nil
+}
+
+// decryptLegacyMessage decrypts a hex encoded AES-CBC ciphertext
+func decryptLegacyMessage(ciphertext string, key string) (string, error) {
+	aesKey, err := deriveKeyFromHash(key)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := hex.DecodeString(ciphertext)
+	if err != nil {
+		return "", errors.Wrap(err, "invalid hex in ciphertext")
+	}
+
+	// The ciphertext must hold the IV and at least one padded block
+	if len(data) < 2*aes.BlockSize || len(data)%aes.BlockSize != 0 {
+		return "", errors.New("ciphertext has invalid length")
+	}
+
+	block, err := aes.NewCipher(aesKey)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create AES cipher")
+	}
+
+	iv := data[:aes.BlockSize]
+	plaintext := make([]byte, len(data)-aes.BlockSize)
+
+	bm := cipher.NewCBCDecrypter(block, iv)
+	bm.CryptBlocks(plaintext, data[aes.BlockSize:])
+
+	unpadded, err := pkcs7Unpad(plaintext, block.BlockSize())
+	if err != nil {
+		return "", errors.Wrap(err, "failed to unpad plaintext")
+	}
+
+	return string(unpadded), nil
+}
+
+func pkcs7Unpad(b []byte, blockSize int) ([]byte, error) {
+	if blockSize <= 0 {
+		return nil, errors.New("invalid blocksize")
+	}
+
+	if len(b) == 0 || len(b)%blockSize != 0 {
+		return nil, errors.New("invalid PKCS7 data (empty or not padded)")
+	}
+
+	n := int(b[len(b)-1])
+	if n == 0 || n > blockSize {
+		return nil, errors.New("invalid PKCS7 padding")
+	}
+
+	// Every padding byte must carry the padding length
+	if !bytes.Equal(b[len(b)-n:], bytes.Repeat([]byte{byte(n)}, n)) {
+		return nil, errors.New("invalid PKCS7 padding")
+	}
+
+	return b[:len(b)-n], nil
+}
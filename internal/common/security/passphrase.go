@@ -0,0 +1,103 @@
+package security
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	// Argon2id parameters for new passphrases, the second recommended option of RFC 9106
+	argon2Time    = 1
+	argon2Memory  = 64 * 1024
+	argon2Threads = 4
+	argon2SaltLen = 16
+
+	// maxArgon2Memory bounds the memory a stored parameter set can ask for
+	maxArgon2Memory = 1024 * 1024
+	maxArgon2Time   = 10
+)
+
+// passphraseKeyLabel separates the passphrase key from the other keys derived from the content key
+var passphraseKeyLabel = []byte("secret-server passphrase key")
+
+// passphraseKDF holds the Argon2id parameters and the salt of a passphrase
+type passphraseKDF struct {
+	time    uint32
+	memory  uint32
+	threads uint8
+	salt    []byte
+}
+
+// GeneratePassphraseKDF returns Argon2id parameters with a random salt for a new passphrase,
+// they are stored with the secret in the form argon2id$v=19$m=65536,t=1,p=4$salt
+func (e RealEncryptor) GeneratePassphraseKDF() (string, error) {
+	kdf := passphraseKDF{time: argon2Time, memory: argon2Memory, threads: argon2Threads, salt: make([]byte, argon2SaltLen)}
+	if _, err := io.ReadFull(rand.Reader, kdf.salt); err != nil {
+		return "", errors.Wrap(err, "failed to generate passphrase salt")
+	}
+
+	return kdf.String(), nil
+}
+
+// DerivePassphraseKey stretches the passphrase with the stored Argon2id parameters and mixes it into the key.
+// The result depends on the key and the passphrase, so the passphrase alone does not help an attacker.
+func (e RealEncryptor) DerivePassphraseKey(key string, passphrase string, kdf string) (string, error) {
+	params, err := parsePassphraseKDF(kdf)
+	if err != nil {
+		return "", err
+	}
+
+	keyBytes, err := hex.DecodeString(key)
+	if err != nil {
+		return "", errors.Wrap(err, "invalid hex in key")
+	}
+
+	stretched := argon2.IDKey([]byte(passphrase), params.salt, params.time, params.memory, params.threads, keySize)
+
+	mac := hmac.New(sha256.New, keyBytes)
+	mac.Write(passphraseKeyLabel)
+	mac.Write(stretched)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// String encodes the parameters and the salt
+func (k passphraseKDF) String() string {
+	return fmt.Sprintf("argon2id$v=%d$m=%d,t=%d,p=%d$%s", argon2.Version, k.memory, k.time, k.threads, base64.RawStdEncoding.EncodeToString(k.salt))
+}
+
+// parsePassphraseKDF parses parameters produced by GeneratePassphraseKDF
+func parsePassphraseKDF(value string) (passphraseKDF, error) {
+	var (
+		kdf     passphraseKDF
+		version int
+		salt    string
+	)
+
+	_, err := fmt.Sscanf(value, "argon2id$v=%d$m=%d,t=%d,p=%d$%s", &version, &kdf.memory, &kdf.time, &kdf.threads, &salt)
+	if err != nil {
+		return passphraseKDF{}, errors.Wrap(err, "invalid passphrase parameters")
+	}
+
+	if version != argon2.Version {
+		return passphraseKDF{}, errors.Errorf("unsupported argon2 version %d", version)
+	}
+
+	if kdf.time == 0 || kdf.time > maxArgon2Time || kdf.memory == 0 || kdf.memory > maxArgon2Memory || kdf.threads == 0 {
+		return passphraseKDF{}, errors.New("passphrase parameters out of range")
+	}
+
+	kdf.salt, err = base64.RawStdEncoding.DecodeString(salt)
+	if err != nil || len(kdf.salt) < argon2SaltLen {
+		return passphraseKDF{}, errors.New("invalid passphrase salt")
+	}
+
+	return kdf, nil
+}
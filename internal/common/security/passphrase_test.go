@@ -0,0 +1,79 @@
+package security
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDerivePassphraseKey_Deterministic(t *testing.T) {
+	encryptor := RealEncryptor{}
+	key := strings.Repeat("ab", keySize)
+
+	kdf, err := encryptor.GeneratePassphraseKDF()
+	assert.NoError(t, err, "GeneratePassphraseKDF should not return an error")
+	assert.True(t, strings.HasPrefix(kdf, "argon2id$v=19$m=65536,t=1,p=4$"), "The parameters should name the algorithm")
+
+	first, err := encryptor.DerivePassphraseKey(key, "correct horse", kdf)
+	assert.NoError(t, err)
+	second, err := encryptor.DerivePassphraseKey(key, "correct horse", kdf)
+	assert.NoError(t, err)
+	assert.Equal(t, first, second, "The same passphrase should derive the same key")
+	assert.Len(t, first, keySize*2, "The derived key should be a hex encoded AES-256 key")
+
+	// The derived key can be used to encrypt
+	ciphertext, err := encryptor.EncryptMessage("This is a test secret", first)
+	assert.NoError(t, err)
+	plaintext, err := encryptor.DecryptMessage(ciphertext, second)
+	assert.NoError(t, err)
+	assert.Equal(t, "This is a test secret", plaintext)
+}
+
+func TestDerivePassphraseKey_Inputs(t *testing.T) {
+	encryptor := RealEncryptor{}
+	key := strings.Repeat("ab", keySize)
+
+	kdf, err := encryptor.GeneratePassphraseKDF()
+	assert.NoError(t, err)
+	otherKDF, err := encryptor.GeneratePassphraseKDF()
+	assert.NoError(t, err)
+	assert.NotEqual(t, kdf, otherKDF, "Every passphrase should get its own salt")
+
+	derived, err := encryptor.DerivePassphraseKey(key, "correct horse", kdf)
+	assert.NoError(t, err)
+
+	wrongPassphrase, err := encryptor.DerivePassphraseKey(key, "wrong horse", kdf)
+	assert.NoError(t, err)
+	assert.NotEqual(t, derived, wrongPassphrase, "A different passphrase should derive a different key")
+
+	otherSalt, err := encryptor.DerivePassphraseKey(key, "correct horse", otherKDF)
+	assert.NoError(t, err)
+	assert.NotEqual(t, derived, otherSalt, "A different salt should derive a different key")
+
+	otherKey, err := encryptor.DerivePassphraseKey(strings.Repeat("cd", keySize), "correct horse", kdf)
+	assert.NoError(t, err)
+	assert.NotEqual(t, derived, otherKey, "The passphrase alone should not determine the key")
+}
+
+func TestDerivePassphraseKey_InvalidKDF(t *testing.T) {
+	encryptor := RealEncryptor{}
+	key := strings.Repeat("ab", keySize)
+
+	for _, kdf := range []string{
+		"",
+		"scrypt$N=32768,r=8,p=1$c2FsdHNhbHRzYWx0c2FsdA",
+		"argon2id$v=16$m=65536,t=1,p=4$c2FsdHNhbHRzYWx0c2FsdA",
+		"argon2id$v=19$m=4194304,t=1,p=4$c2FsdHNhbHRzYWx0c2FsdA",
+		"argon2id$v=19$m=65536,t=0,p=4$c2FsdHNhbHRzYWx0c2FsdA",
+		"argon2id$v=19$m=65536,t=1,p=4$c2hvcnQ",
+	} {
+		_, err := encryptor.DerivePassphraseKey(key, "correct horse", kdf)
+		assert.Error(t, err, "DerivePassphraseKey should reject %q", kdf)
+	}
+
+	kdf, err := encryptor.GeneratePassphraseKDF()
+	assert.NoError(t, err)
+	_, err = encryptor.DerivePassphraseKey("not hex", "correct horse", kdf)
+	assert.Error(t, err, "DerivePassphraseKey should reject a key that is not hex")
+}
@@ -0,0 +1,295 @@
+package security
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	// StreamVersion1 is the current layout of an encrypted stream
+	StreamVersion1 byte = 1
+
+	// StreamChunkSize is the amount of plaintext sealed in one chunk of a stream
+	StreamChunkSize = 64 * 1024
+
+	// streamSaltSize is the number of random bytes the stream key is derived with
+	streamSaltSize = 16
+
+	// streamHeaderSize is the length of version, algorithm and salt in front of the chunks
+	streamHeaderSize = 2 + streamSaltSize
+
+	// streamTagSize is the length of the GCM tag every chunk is sealed with
+	streamTagSize = 16
+)
+
+// streamKeyLabel separates the stream key from any other key derived from the same key
+var streamKeyLabel = []byte("secret-server stream key")
+
+// An encrypted stream is the STREAM construction with AES-256-GCM, as used by age:
+//
+//	version (1 byte) | algorithm (1 byte) | salt (16 bytes) | chunk | chunk | ... | final chunk
+//
+// Every stream is sealed under its own key derived with HKDF-SHA256 from the key and the random salt,
+// so the nonces can be a counter. The nonce of a chunk is its 11 byte big endian index followed by
+// a byte that is 1 for the final chunk and 0 otherwise. Every chunk but the final one holds exactly
+// StreamChunkSize bytes of plaintext, the final chunk is only empty for an empty plaintext.
+// Reordered, dropped or truncated chunks fail to authenticate, and the header is authenticated with
+// every chunk as additional data.
+
+// EncryptedStreamSize returns the length of the stream NewEncryptWriter writes for a plaintext of the size,
+// so the stream can be uploaded with a known length while it is still being written
+func EncryptedStreamSize(size int64) int64 {
+	chunks := (size + StreamChunkSize - 1) / StreamChunkSize
+	if chunks == 0 {
+		chunks = 1
+	}
+
+	return streamHeaderSize + size + chunks*streamTagSize
+}
+
+// streamCipher seals the chunks of one stream
+type streamCipher struct {
+	aead    cipher.AEAD
+	header  []byte
+	counter uint64
+	nonce   []byte
+}
+
+// newStreamCipher derives the key of the stream with the salt of its header
+func newStreamCipher(key []byte, header []byte) (*streamCipher, error) {
+	if len(key) != 32 {
+		return nil, errors.New("stream requires a 32 byte key")
+	}
+
+	streamKey := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, key, header[2:], streamKeyLabel), streamKey); err != nil {
+		return nil, errors.Wrap(err, "failed to derive stream key")
+	}
+
+	aead, err := newAEAD(header[1], streamKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &streamCipher{aead: aead, header: header, nonce: make([]byte, aead.NonceSize())}, nil
+}
+
+// next returns the nonce of the next chunk, it refuses to reuse a nonce once the counter wraps
+func (s *streamCipher) next(final bool) ([]byte, error) {
+	if s.counter == ^uint64(0) {
+		return nil, errors.New("stream has too many chunks")
+	}
+
+	// The upper bytes of the 11 byte counter stay zero
+	for i := range s.nonce {
+		s.nonce[i] = 0
+	}
+	binary.BigEndian.PutUint64(s.nonce[len(s.nonce)-9:], s.counter)
+	if final {
+		s.nonce[len(s.nonce)-1] = 1
+	}
+
+	s.counter++
+	return s.nonce, nil
+}
+
+// encryptWriter buffers one chunk of plaintext, a full chunk is only sealed once more plaintext
+// follows as it could still turn out to be the final one
+type encryptWriter struct {
+	dst    io.Writer
+	cipher *streamCipher
+	buf    []byte
+	out    []byte
+	err    error
+}
+
+// NewEncryptWriter returns a writer that encrypts everything written to it into dst with the
+// 32 byte key, holding no more than a chunk in memory. The header is written right away.
+// Close must be called to write the final chunk, it does not close dst.
+func NewEncryptWriter(dst io.Writer, key []byte) (io.WriteCloser, error) {
+	header := make([]byte, streamHeaderSize)
+	header[0], header[1] = StreamVersion1, AlgorithmAES256GCM
+	if _, err := io.ReadFull(rand.Reader, header[2:]); err != nil {
+		return nil, errors.Wrap(err, "failed to generate stream salt")
+	}
+
+	c, err := newStreamCipher(key, header)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := dst.Write(header); err != nil {
+		return nil, errors.Wrap(err, "failed to write stream header")
+	}
+
+	return &encryptWriter{
+		dst:    dst,
+		cipher: c,
+		buf:    make([]byte, 0, StreamChunkSize),
+		out:    make([]byte, 0, StreamChunkSize+c.aead.Overhead()),
+	}, nil
+}
+
+func (w *encryptWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	written := 0
+	for len(p) > 0 {
+		if len(w.buf) == StreamChunkSize {
+			if err := w.seal(false); err != nil {
+				return written, err
+			}
+		}
+
+		n := copy(w.buf[len(w.buf):StreamChunkSize], p)
+		w.buf = w.buf[:len(w.buf)+n]
+		p = p[n:]
+		written += n
+	}
+
+	return written, nil
+}
+
+// Close seals the buffered plaintext as the final chunk
+func (w *encryptWriter) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+
+	if err := w.seal(true); err != nil {
+		return err
+	}
+
+	w.err = errors.New("write to closed stream")
+	return nil
+}
+
+// seal encrypts the buffered plaintext as the next chunk and writes it
+func (w *encryptWriter) seal(final bool) error {
+	nonce, err := w.cipher.next(final)
+	if err != nil {
+		w.err = err
+		return err
+	}
+
+	w.out = w.cipher.aead.Seal(w.out[:0], nonce, w.buf, w.cipher.header)
+	w.buf = w.buf[:0]
+
+	if _, err := w.dst.Write(w.out); err != nil {
+		w.err = errors.Wrap(err, "failed to write stream chunk")
+		return w.err
+	}
+
+	return nil
+}
+
+// decryptReader reads one chunk and a byte more, a chunk is final when nothing follows it
+type decryptReader struct {
+	src       io.Reader
+	cipher    *streamCipher
+	buf       []byte
+	carried   bool
+	plaintext []byte
+	unread    []byte
+	final     bool
+	err       error
+}
+
+// NewDecryptReader returns a reader that decrypts a stream written by NewEncryptWriter from src
+// with the 32 byte key, holding no more than a chunk in memory. It reads the header right away.
+// Every chunk is authenticated before its plaintext is returned, a stream that is modified or
+// cut short fails with an error instead of io.EOF.
+func NewDecryptReader(src io.Reader, key []byte) (io.Reader, error) {
+	header := make([]byte, streamHeaderSize)
+	if _, err := io.ReadFull(src, header); err != nil {
+		return nil, errors.Wrap(err, "failed to read stream header")
+	}
+
+	if header[0] != StreamVersion1 {
+		return nil, errors.Errorf("unsupported stream version %d", header[0])
+	}
+
+	c, err := newStreamCipher(key, header)
+	if err != nil {
+		return nil, err
+	}
+
+	return &decryptReader{
+		src:       src,
+		cipher:    c,
+		buf:       make([]byte, StreamChunkSize+c.aead.Overhead()+1),
+		plaintext: make([]byte, 0, StreamChunkSize),
+	}, nil
+}
+
+func (r *decryptReader) Read(p []byte) (int, error) {
+	for len(r.unread) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		if r.final {
+			return 0, io.EOF
+		}
+		r.err = r.open()
+	}
+
+	n := copy(p, r.unread)
+	r.unread = r.unread[n:]
+	return n, nil
+}
+
+// open reads and authenticates the next chunk
+func (r *decryptReader) open() error {
+	start := 0
+	if r.carried {
+		start = 1
+	}
+
+	n, err := io.ReadFull(r.src, r.buf[start:])
+	size := start + n
+	chunkSize := len(r.buf) - 1
+
+	switch {
+	case err == nil:
+		size = chunkSize
+	case errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF):
+		r.final = true
+	default:
+		return errors.Wrap(err, "failed to read stream chunk")
+	}
+
+	overhead := r.cipher.aead.Overhead()
+	if size < overhead {
+		return errors.New("stream is truncated")
+	}
+	if r.final && size == overhead && r.cipher.counter > 0 {
+		return errors.New("stream has an empty final chunk")
+	}
+
+	nonce, err := r.cipher.next(r.final)
+	if err != nil {
+		return err
+	}
+
+	r.plaintext, err = r.cipher.aead.Open(r.plaintext[:0], nonce, r.buf[:size], r.cipher.header)
+	if err != nil {
+		return errors.Wrap(err, "failed to authenticate stream chunk")
+	}
+	r.unread = r.plaintext
+
+	// The byte read past the chunk starts the next one
+	if !r.final {
+		r.buf[0] = r.buf[chunkSize]
+		r.carried = true
+	}
+
+	return nil
+}
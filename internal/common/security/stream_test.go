@@ -0,0 +1,208 @@
+package security
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// encryptStream encrypts the plaintext with the stream writer in a single write
+func encryptStream(t *testing.T, key []byte, plaintext []byte) []byte {
+	var ciphertext bytes.Buffer
+	w, err := NewEncryptWriter(&ciphertext, key)
+	assert.NoError(t, err)
+	_, err = w.Write(plaintext)
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+	return ciphertext.Bytes()
+}
+
+// decryptStream decrypts the whole stream
+func decryptStream(key []byte, ciphertext []byte) ([]byte, error) {
+	r, err := NewDecryptReader(bytes.NewReader(ciphertext), key)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+func TestStream_RoundTrip(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, 32)
+
+	sizes := []int{0, 1, StreamChunkSize - 1, StreamChunkSize, StreamChunkSize + 1, 3 * StreamChunkSize}
+	for _, size := range sizes {
+		plaintext := make([]byte, size)
+		_, err := rand.Read(plaintext)
+		assert.NoError(t, err)
+
+		ciphertext := encryptStream(t, key, plaintext)
+
+		// Every chunk adds the tag, the final chunk is always written
+		chunks := size/StreamChunkSize + 1
+		if size > 0 && size%StreamChunkSize == 0 {
+			chunks--
+		}
+		assert.Len(t, ciphertext, streamHeaderSize+size+chunks*16, "size %d", size)
+		assert.Equal(t, int64(len(ciphertext)), EncryptedStreamSize(int64(size)), "size %d", size)
+
+		decrypted, err := decryptStream(key, ciphertext)
+		assert.NoError(t, err, "size %d", size)
+		assert.True(t, bytes.Equal(plaintext, decrypted), "size %d", size)
+	}
+}
+
+func TestStream_SmallWritesAndReads(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, 32)
+	plaintext := bytes.Repeat([]byte("0123456789"), StreamChunkSize/4)
+
+	// Writing in odd pieces must give a stream the reader can read in odd pieces
+	var ciphertext bytes.Buffer
+	w, err := NewEncryptWriter(&ciphertext, key)
+	assert.NoError(t, err)
+	for rest := plaintext; len(rest) > 0; {
+		n := 7777
+		if n > len(rest) {
+			n = len(rest)
+		}
+		_, err := w.Write(rest[:n])
+		assert.NoError(t, err)
+		rest = rest[n:]
+	}
+	assert.NoError(t, w.Close())
+
+	r, err := NewDecryptReader(iotest.HalfReader(bytes.NewReader(ciphertext.Bytes())), key)
+	assert.NoError(t, err)
+	assert.NoError(t, iotest.TestReader(r, plaintext))
+}
+
+func TestStream_UniqueSalt(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, 32)
+
+	first := encryptStream(t, key, []byte("same"))
+	second := encryptStream(t, key, []byte("same"))
+	assert.NotEqual(t, first, second, "Every stream should be sealed under its own key")
+}
+
+func TestStream_Invalid(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, 32)
+	plaintext := bytes.Repeat([]byte{0x07}, 2*StreamChunkSize+100)
+	ciphertext := encryptStream(t, key, plaintext)
+	chunkSize := StreamChunkSize + 16
+
+	modify := func(change func(c []byte) []byte) []byte {
+		return change(append([]byte{}, ciphertext...))
+	}
+
+	tests := []struct {
+		name       string
+		key        []byte
+		ciphertext []byte
+		err        string
+	}{
+		{
+			name:       "wrong key",
+			key:        bytes.Repeat([]byte{0x43}, 32),
+			ciphertext: ciphertext,
+			err:        "failed to authenticate stream chunk",
+		},
+		{
+			name:       "modified chunk",
+			key:        key,
+			ciphertext: modify(func(c []byte) []byte { c[streamHeaderSize+chunkSize+5] ^= 1; return c }),
+			err:        "failed to authenticate stream chunk",
+		},
+		{
+			name:       "modified salt",
+			key:        key,
+			ciphertext: modify(func(c []byte) []byte { c[2] ^= 1; return c }),
+			err:        "failed to authenticate stream chunk",
+		},
+		{
+			name:       "truncated at a chunk boundary",
+			key:        key,
+			ciphertext: ciphertext[:streamHeaderSize+2*chunkSize],
+			err:        "failed to authenticate stream chunk",
+		},
+		{
+			name:       "truncated in a chunk",
+			key:        key,
+			ciphertext: ciphertext[:len(ciphertext)-1],
+			err:        "failed to authenticate stream chunk",
+		},
+		{
+			name:       "without chunks",
+			key:        key,
+			ciphertext: ciphertext[:streamHeaderSize],
+			err:        "stream is truncated",
+		},
+		{
+			name:       "data after the final chunk",
+			key:        key,
+			ciphertext: append(append([]byte{}, ciphertext...), 0),
+			err:        "failed to authenticate stream chunk",
+		},
+		{
+			name: "swapped chunks",
+			key:  key,
+			ciphertext: modify(func(c []byte) []byte {
+				first := append([]byte{}, c[streamHeaderSize:streamHeaderSize+chunkSize]...)
+				copy(c[streamHeaderSize:], c[streamHeaderSize+chunkSize:streamHeaderSize+2*chunkSize])
+				copy(c[streamHeaderSize+chunkSize:], first)
+				return c
+			}),
+			err: "failed to authenticate stream chunk",
+		},
+		{
+			name:       "unknown version",
+			key:        key,
+			ciphertext: modify(func(c []byte) []byte { c[0] = 9; return c }),
+			err:        "unsupported stream version",
+		},
+		{
+			name:       "unknown algorithm",
+			key:        key,
+			ciphertext: modify(func(c []byte) []byte { c[1] = 9; return c }),
+			err:        "unsupported envelope algorithm",
+		},
+		{
+			name:       "short header",
+			key:        key,
+			ciphertext: ciphertext[:5],
+			err:        "failed to read stream header",
+		},
+		{
+			name:       "short key",
+			key:        []byte("short"),
+			ciphertext: ciphertext,
+			err:        "stream requires a 32 byte key",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := decryptStream(tt.key, tt.ciphertext)
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tt.err)
+			}
+		})
+	}
+}
+
+func TestEncryptWriter_Closed(t *testing.T) {
+	w, err := NewEncryptWriter(io.Discard, bytes.Repeat([]byte{0x42}, 32))
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+
+	_, err = w.Write([]byte("late"))
+	assert.Error(t, err, "Writing after Close should fail")
+	assert.Error(t, w.Close(), "Closing twice should fail")
+}
+
+func TestNewEncryptWriter_InvalidKey(t *testing.T) {
+	_, err := NewEncryptWriter(io.Discard, []byte("short"))
+	assert.Error(t, err, "NewEncryptWriter should reject keys that are not 32 bytes")
+}
@@ -0,0 +1,85 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	// ScopeSecretsCreate allows a key to create secrets
+	ScopeSecretsCreate = "secrets:create"
+
+	// ScopeAdmin allows a key to issue, list and revoke API keys
+	ScopeAdmin = "admin"
+)
+
+// Scopes lists every scope an API key can be issued with
+var Scopes = []string{ScopeSecretsCreate, ScopeAdmin}
+
+var (
+	// ErrAPIKeyRequired is returned when a request that needs an API key comes without one
+	ErrAPIKeyRequired = NewError(ErrUnauthorized, "api_key_required", "api key or bearer token required")
+
+	// ErrInvalidAPIKey is returned for keys that are malformed, unknown or revoked
+	ErrInvalidAPIKey = NewError(ErrUnauthorized, "invalid_api_key", "invalid api key")
+
+	// ErrInsufficientScope is returned when an authenticated caller lacks the scope of the request
+	ErrInsufficientScope = NewError(ErrForbidden, "insufficient_scope", "caller lacks the scope of the request")
+
+	// ErrAPIKeyNotFound is returned when there is no API key for the id
+	ErrAPIKeyNotFound = NewError(ErrNotFound, "api_key_not_found", "api key not found")
+
+	// ErrAPIKeyAlreadyExists is returned when an API key is saved under an id that is already taken
+	ErrAPIKeyAlreadyExists = NewError(ErrConflict, "api_key_already_exists", "api key already exists")
+)
+
+// APIKey authenticates a client of the API. The key handed out is the id and a random secret part,
+// only the SHA-256 hash of the secret part is stored.
+type APIKey struct {
+	ID   string `dynamodbav:"id"`
+	Name string `dynamodbav:"name"`
+	// Key is the full key handed out once when it is issued, it is never persisted
+	Key       string    `dynamodbav:"-"`
+	KeyHash   string    `dynamodbav:"keyHash"`
+	Scopes    []string  `dynamodbav:"scopes"`
+	CreatedAt time.Time `dynamodbav:"createdAt"`
+	// RevokedAt is set once the key is revoked, revoked keys are kept so they show up in the list
+	RevokedAt *time.Time `dynamodbav:"revokedAt,omitempty"`
+	// Tenant is the tenant the key creates and manages secrets in, DefaultTenant for keys of the deployment
+	Tenant string `dynamodbav:"tenant,omitempty"`
+}
+
+// HasScope reports whether the key was issued with the scope
+func (k APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Revoked reports whether the key was revoked
+func (k APIKey) Revoked() bool {
+	return k.RevokedAt != nil
+}
+
+// APIKeyRepository represents interface providers for the API key repository
+type APIKeyRepository interface {
+	Save(ctx context.Context, key APIKey) error
+	GetByID(ctx context.Context, id string) (APIKey, error)
+	// Revoke marks the key as revoked at the time, a key that is already revoked keeps its first revocation time
+	Revoke(ctx context.Context, id string, at time.Time) error
+	// ListKeys returns every key, revoked ones included, ordered by their creation time
+	ListKeys(ctx context.Context) ([]APIKey, error)
+}
+
+// APIKeyUseCase represents interface for managing and checking API keys
+type APIKeyUseCase interface {
+	// IssueKey issues a key for the tenant, DefaultTenant issues a key of the deployment
+	IssueKey(ctx context.Context, name string, tenant string, scopes []string) (APIKey, error)
+	RevokeKey(ctx context.Context, id string) error
+	ListKeys(ctx context.Context) ([]APIKey, error)
+	// Authenticate returns the key for the full key sent by a client, unknown and revoked keys are ErrInvalidAPIKey
+	Authenticate(ctx context.Context, key string) (APIKey, error)
+}
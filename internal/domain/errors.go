@@ -0,0 +1,91 @@
+package domain
+
+import (
+	"errors"
+)
+
+// The kinds of errors, every error of the domain is of one kind and the API maps each kind to a status
+var (
+	// ErrNotFound is the kind of errors for secrets that do not exist
+	ErrNotFound = errors.New("not found")
+
+	// ErrExpired is the kind of errors for secrets past their expiration
+	ErrExpired = errors.New("expired")
+
+	// ErrViewsExhausted is the kind of errors for secrets that can not be viewed anymore
+	ErrViewsExhausted = errors.New("views exhausted")
+
+	// ErrValidation is the kind of errors for invalid input
+	ErrValidation = errors.New("validation failed")
+
+	// ErrUnauthorized is the kind of errors for a missing or wrong passphrase
+	ErrUnauthorized = errors.New("unauthorized")
+
+	// ErrForbidden is the kind of errors for requests that are not allowed to manage a secret
+	ErrForbidden = errors.New("forbidden")
+
+	// ErrConflict is the kind of errors for writes that clash with the stored secret
+	ErrConflict = errors.New("conflict")
+
+	// ErrTooManyRequests is the kind of errors for requests beyond a quota of the caller, they succeed once the quota resets
+	ErrTooManyRequests = errors.New("too many requests")
+
+	// ErrUnavailable is the kind of errors for failures of the storage or another backing service, a retry may succeed
+	ErrUnavailable = errors.New("unavailable")
+)
+
+// Error is an error of a kind with a stable machine readable code. errors.Is matches it against
+// the error itself, its kind and its cause.
+type Error struct {
+	Kind    error
+	Code    string
+	Message string
+	// Fields lists the invalid fields of a request, it is only set for validation errors
+	Fields []FieldError
+	// Err is the cause of the error, it is optional
+	Err error
+}
+
+// FieldError tells why a single field of a request is invalid, Field is the name the client sent it as
+type FieldError struct {
+	Field   string `json:"field" xml:"field"`
+	Message string `json:"message" xml:"message"`
+}
+
+// NewError creates an error of the kind with the code and the message
+func NewError(kind error, code string, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+// NewValidationError creates a validation error listing every invalid field of a request
+func NewValidationError(fields []FieldError) *Error {
+	return &Error{Kind: ErrValidation, Code: "invalid_input", Message: "invalid input", Fields: fields}
+}
+
+// Unavailable marks err as a failure of a backing service such as the storage
+func Unavailable(err error) error {
+	return &Error{Kind: ErrUnavailable, Code: "service_unavailable", Message: "service unavailable", Err: err}
+}
+
+func (e *Error) Error() string {
+	message := e.Message
+	for i, field := range e.Fields {
+		sep := "; "
+		if i == 0 {
+			sep = ": "
+		}
+		message += sep + field.Field + ": " + field.Message
+	}
+
+	if e.Err == nil {
+		return message
+	}
+	return message + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() []error {
+	if e.Err == nil {
+		return []error{e.Kind}
+	}
+	return []error{e.Kind, e.Err}
+}
@@ -0,0 +1,50 @@
+package domain
+
+import "context"
+
+// ErrInvalidToken is returned for bearer tokens that are malformed, expired or not signed by the identity provider
+var ErrInvalidToken = NewError(ErrUnauthorized, "invalid_token", "invalid bearer token")
+
+const (
+	// SubjectPrefixAPIKey starts the subject of clients authenticated with an API key, followed by the id of the key
+	SubjectPrefixAPIKey = "apikey:"
+	// SubjectPrefixJWT starts the subject of users of the identity provider, followed by the sub claim of the token.
+	// A sub claim can then never pass for an API key.
+	SubjectPrefixJWT = "jwt:"
+)
+
+// Principal is the authenticated caller of a request, a user of the identity provider or the client of an API key
+type Principal struct {
+	// Subject identifies the caller, SubjectPrefixJWT and the sub claim of a token or SubjectPrefixAPIKey and the id of an API key
+	Subject string
+	// Groups are the groups of a user of the identity provider, clients of API keys have none
+	Groups []string
+	// Scopes are what the caller may do, see ScopeSecretsCreate and ScopeAdmin
+	Scopes []string
+	// Tenant is the tenant of the key or the tenant claim of the token, the caller may only use its routes
+	Tenant string
+}
+
+// HasScope reports whether the caller may do what the scope allows
+func (p Principal) HasScope(scope string) bool {
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// principalKey is the context key of the principal, unexported so only this package can set it
+type principalKey struct{}
+
+// ContextWithPrincipal returns a copy of ctx that carries the principal
+func ContextWithPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext returns the principal of the request, false when the caller is anonymous
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(Principal)
+	return principal, ok
+}
@@ -0,0 +1,86 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrQuotaExceeded is returned when a secret would take a caller or a tenant beyond its quota
+	ErrQuotaExceeded = NewError(ErrTooManyRequests, "quota_exceeded", "quota exceeded")
+
+	// ErrQuotaUsageChanged is returned by a repository when the usage was saved by another request in the meantime
+	ErrQuotaUsageChanged = NewError(ErrConflict, "quota_usage_changed", "quota usage changed concurrently")
+)
+
+// QuotaPolicy limits the secrets of a caller or of a tenant, a limit of 0 is unlimited. The secrets created
+// per day are counted by UTC day, the bytes and the live secrets are those of the secrets that did not expire
+// and were neither fully viewed nor revoked.
+type QuotaPolicy struct {
+	SecretsPerDay  int
+	MaxBytes       int64
+	MaxLiveSecrets int
+}
+
+// Enabled reports whether the policy has any limit
+func (p QuotaPolicy) Enabled() bool {
+	return p.SecretsPerDay > 0 || p.MaxBytes > 0 || p.MaxLiveSecrets > 0
+}
+
+// QuotaBucket is the charge of the live secrets that expire in the same hour, Until is the end of the hour
+// in epoch seconds. The bucket is dropped once the hour passed, the secrets in it have expired by then.
+type QuotaBucket struct {
+	Until   int64 `dynamodbav:"until" json:"until"`
+	Secrets int   `dynamodbav:"secrets" json:"secrets"`
+	Bytes   int64 `dynamodbav:"bytes" json:"bytes"`
+}
+
+// QuotaUsage is the usage of the quota of a subject, a caller or a tenant. Version is incremented on every
+// save, a save of a usage that was read at an older version fails.
+type QuotaUsage struct {
+	Subject string `dynamodbav:"subject"`
+	// Day is the UTC day Created counts the secrets of, as 2006-01-02
+	Day     string        `dynamodbav:"day"`
+	Created int           `dynamodbav:"created"`
+	Buckets []QuotaBucket `dynamodbav:"buckets"`
+	Version int64         `dynamodbav:"version"`
+}
+
+// QuotaStatus is the state of the most restrictive limit of a quota after a request, it is reported in the
+// RateLimit headers. Reset is the time until the limit resets or frees up, a zero status has no limit.
+type QuotaStatus struct {
+	Limit     int64
+	Remaining int64
+	Reset     time.Duration
+}
+
+// QuotaExceededError is the quota exceeded error with the status of the limit that was exceeded
+type QuotaExceededError struct {
+	Status QuotaStatus
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("%s: limit %d, resets in %s", ErrQuotaExceeded.Error(), e.Status.Limit, e.Status.Reset)
+}
+
+func (e *QuotaExceededError) Unwrap() error {
+	return ErrQuotaExceeded
+}
+
+// QuotaRepository stores the usage of the quotas
+type QuotaRepository interface {
+	// GetUsage returns the usage of the subject, a subject without usage has an empty usage of version 0
+	GetUsage(ctx context.Context, subject string) (QuotaUsage, error)
+	// SaveUsage stores the usage at the next version, unless the stored usage is not at usage.Version anymore
+	SaveUsage(ctx context.Context, usage QuotaUsage) error
+}
+
+// QuotaUseCase charges the secrets to the quotas of their creator and their tenant
+type QuotaUseCase interface {
+	// Reserve charges the secret to the quotas of the context, it fails with a QuotaExceededError when
+	// any of them would be exceeded and charges none of them then
+	Reserve(ctx context.Context, secret Secret) (QuotaStatus, error)
+	// Release returns the charge of a secret that was removed before it expired
+	Release(ctx context.Context, secret Secret) error
+}
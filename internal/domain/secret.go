@@ -2,33 +2,211 @@ package domain
 
 import (
 	"context"
+	"io"
 	"time"
 )
 
+var (
+	// ErrSecretAlreadyExists is returned when a secret is saved under an id that is already taken
+	ErrSecretAlreadyExists = NewError(ErrConflict, "secret_already_exists", "secret already exists")
+
+	// ErrSecretNotFound is returned when there is no secret for the id
+	ErrSecretNotFound = NewError(ErrNotFound, "secret_not_found", "secret not found")
+
+	// ErrSecretExpired is returned when a secret is read after its expiration
+	ErrSecretExpired = NewError(ErrExpired, "secret_expired", "secret expired")
+
+	// ErrNoRemainingViews is returned when a secret has no views left to consume
+	ErrNoRemainingViews = NewError(ErrViewsExhausted, "views_exhausted", "secret has no remaining views")
+
+	// ErrSecretChanged is returned when a conditional update finds the secret changed or deleted
+	ErrSecretChanged = NewError(ErrConflict, "secret_changed", "secret changed concurrently")
+
+	// ErrPassphraseRequired is returned when a passphrase protected secret is read without a passphrase
+	ErrPassphraseRequired = NewError(ErrUnauthorized, "passphrase_required", "passphrase required")
+
+	// ErrWrongPassphrase is returned when the passphrase does not decrypt the secret
+	ErrWrongPassphrase = NewError(ErrUnauthorized, "wrong_passphrase", "wrong passphrase")
+
+	// ErrKeyRequired is returned when a secret encrypted by the server is read without the key from the link
+	ErrKeyRequired = NewError(ErrValidation, "key_required", "key required")
+
+	// ErrInvalidKey is returned when the key from the link does not decrypt the secret, for the reader
+	// the link leads nowhere just like the link of a missing secret
+	ErrInvalidKey = NewError(ErrNotFound, "invalid_key", "key does not decrypt the secret")
+
+	// ErrInvalidRevocationToken is returned when the revocation token does not belong to the secret
+	ErrInvalidRevocationToken = NewError(ErrForbidden, "invalid_revocation_token", "invalid revocation token")
+
+	// ErrSecretBurned is returned when the last passphrase attempt failed and the secret was deleted
+	ErrSecretBurned = NewError(ErrViewsExhausted, "secret_burned", "secret burned after too many failed passphrase attempts")
+
+	// ErrBlobNotFound is returned when the content of a file secret is missing from the blob store
+	ErrBlobNotFound = NewError(ErrNotFound, "file_not_found", "file of the secret not found")
+
+	// ErrInvalidCursor is returned when the cursor of a listing was not handed out by the repository
+	ErrInvalidCursor = NewError(ErrValidation, "invalid_cursor", "invalid cursor")
+)
+
 type Secret struct {
-	Hash           string    `dynamodbav:"hash"`
-	SecretText     string    `dynamodbav:"secretText"`
-	CreatedAt      time.Time `dynamodbav:"createdAt"`
-	ExpiresAt      time.Time `dynamodbav:"expiresAt"`
-	RemainingViews int       `dynamodbav:"remainingViews"`
+	Hash string `dynamodbav:"hash"`
+	// Key is the decryption key handed out in the secret link, it is never persisted
+	Key string `dynamodbav:"-"`
+	// Passphrase optionally protects the secret in addition to the key, it is never persisted
+	Passphrase string `dynamodbav:"-"`
+	// RevocationToken lets the creator delete the secret, it is only handed out once and stored as RevocationTokenHash
+	RevocationToken     string `dynamodbav:"-"`
+	RevocationTokenHash string `dynamodbav:"revocationTokenHash,omitempty"`
+	SecretText          string `dynamodbav:"secretText"`
+	// WrappedKey is the data key of the secret encrypted by the master key with the id KeyID
+	WrappedKey string `dynamodbav:"wrappedKey,omitempty"`
+	KeyID      string `dynamodbav:"keyId,omitempty"`
+	// PassphraseKDF holds the key derivation parameters and salt of the passphrase, it is empty without passphrase
+	PassphraseKDF  string `dynamodbav:"passphraseKdf,omitempty"`
+	FailedAttempts int    `dynamodbav:"failedAttempts,omitempty"`
+	// ClientEncrypted marks a secret encrypted by the client with Algorithm, the server never holds its key
+	ClientEncrypted bool      `dynamodbav:"clientEncrypted,omitempty"`
+	Algorithm       string    `dynamodbav:"algorithm,omitempty"`
+	CreatedAt       time.Time `dynamodbav:"createdAt"`
+	ExpiresAt       time.Time `dynamodbav:"expiresAt"`
+	// TTL is ExpiresAt in epoch seconds, DynamoDB deletes the item some time after it passed
+	TTL            int64 `dynamodbav:"ttl,omitempty"`
+	RemainingViews int   `dynamodbav:"remainingViews"`
+	// ReadAt holds the time of every view taken
+	ReadAt []time.Time `dynamodbav:"readAt,omitempty"`
+	// BlobKey points to the encrypted content of a file secret in the blob store. FileName is encrypted like
+	// the secret, FileContentType is kept in the clear to serve the file.
+	BlobKey         string `dynamodbav:"blobKey,omitempty"`
+	FileName        string `dynamodbav:"fileName,omitempty"`
+	FileContentType string `dynamodbav:"fileContentType,omitempty"`
+	// Owner is the subject of the principal that created the secret, it is empty for anonymous secrets.
+	// Secrets without owner are left out of the owner index.
+	Owner string `dynamodbav:"owner,omitempty"`
+	// Tenant is the tenant the secret was created in, the repositories keep it in the keys of the secret
+	Tenant string `dynamodbav:"-"`
+	// QuotaBytes is the size charged to the quotas of the creator and the tenant, it is returned when the
+	// secret is removed early. The tombstone left by the last view has none, the charge was returned with
+	// the view. Secrets created before quotas have none either, a secret without it holds no charge.
+	QuotaBytes int64 `dynamodbav:"quotaBytes,omitempty"`
+	// Quota is the status of the quota after the secret was created, it is never persisted
+	Quota QuotaStatus `dynamodbav:"-"`
+	// File is the plain file of a file secret while it is created or read, it is never persisted
+	File *File `dynamodbav:"-"`
 }
 
-// SecretRepository represents interface providers for secret repository
+// File is a file shared as a secret
+type File struct {
+	Name        string
+	ContentType string
+	// Size is the length of the plain content in bytes
+	Size int64
+	// Content streams the plain content, so large files are never held in memory. Whoever holds the file closes it.
+	Content io.ReadCloser
+}
+
+// SecretStatus is the metadata of a secret shown to its creator, it never carries the secret itself
+type SecretStatus struct {
+	Hash           string
+	CreatedAt      time.Time
+	ExpiresAt      time.Time
+	RemainingViews int
+	// Consumed is set once the last view was taken
+	Consumed bool
+	Expired  bool
+	ReadAt   []time.Time
+}
+
+// SecretRepository represents interface providers for secret repository. Secrets are stored and looked up in the
+// tenant of the context, a secret of one tenant is never found through another.
 type SecretRepository interface {
 	Save(ctx context.Context, secret Secret) error
 	GetByHash(ctx context.Context, hash string) (Secret, error)
 	DeleteSecret(ctx context.Context, hash string) error
-	UpdateSecretViews(ctx context.Context, hash string, remainingViews int) error
+	// GetMetadata returns the secret without its ciphertext and keys, it does not take a view
+	GetMetadata(ctx context.Context, hash string) (Secret, error)
+	// ConsumeView atomically takes one view of the secret, records the time of the read and returns the secret
+	// with the remaining views. A secret whose last view was taken is kept as a tombstone without its ciphertext.
+	ConsumeView(ctx context.Context, hash string) (Secret, error)
+	// UpdateEncryption replaces the ciphertext and the wrapped data key of the secret
+	UpdateEncryption(ctx context.Context, secret Secret) error
+	// RecordFailedAttempt atomically counts a failed passphrase attempt and returns the number of failed attempts,
+	// the secret is deleted once maxAttempts is reached
+	RecordFailedAttempt(ctx context.Context, hash string, maxAttempts int) (int, error)
+	// UpdateWrappedKey replaces the wrapped data key, as long as the secret still has the old wrapped key
+	UpdateWrappedKey(ctx context.Context, hash string, oldWrappedKey string, wrappedKey string, keyID string) error
+	// ListSecrets returns up to limit secrets of every tenant after the cursor and the cursor of the next page,
+	// the next cursor is empty once all secrets are listed
+	ListSecrets(ctx context.Context, cursor string, limit int) ([]Secret, string, error)
+	// ListSecretsByOwner returns the metadata of up to limit secrets of the owner after the cursor, newest first,
+	// and the cursor of the next page. A cursor the repository did not hand out is rejected with ErrInvalidCursor.
+	ListSecretsByOwner(ctx context.Context, owner string, cursor string, limit int) ([]Secret, string, error)
+}
+
+// BlobStore stores the encrypted content of file secrets, the secret only holds the key of its blob
+type BlobStore interface {
+	// Put stores the size bytes of the content, the content may be a stream that can only be read once
+	Put(ctx context.Context, key string, content io.Reader, size int64) error
+	// Get returns ErrBlobNotFound when there is no blob for the key, the caller closes the content
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the blob, a missing blob is not an error
+	Delete(ctx context.Context, key string) error
 }
 
 // SecretUseCase represents interface for secret use cases
 type SecretUseCase interface {
 	CreateSecretMessage(ctx context.Context, message Secret) (Secret, error)
-	GetSecretMessage(ctx context.Context, hash string) (Secret, error)
+	GetSecretMessage(ctx context.Context, hash string, key string, passphrase string) (Secret, error)
+	// RevokeSecret deletes the secret on behalf of its creator, who holds the revocation token or owns the secret
+	RevokeSecret(ctx context.Context, hash string, token string) error
+	// GetSecretStatus returns the metadata of the secret to its creator without taking a view
+	GetSecretStatus(ctx context.Context, hash string, token string) (SecretStatus, error)
+	// ListOwnSecrets returns the status of the secrets owned by the principal of the context, newest first
+	ListOwnSecrets(ctx context.Context, cursor string, limit int) ([]SecretStatus, string, error)
+}
+
+// RewrapProgress reports how far a re-wrap of the data keys got, Cursor is where the next run resumes
+type RewrapProgress struct {
+	Cursor    string `json:"cursor"`
+	Scanned   int    `json:"scanned"`
+	Rewrapped int    `json:"rewrapped"`
+	Skipped   int    `json:"skipped"`
+	Failed    int    `json:"failed"`
+	// Legacy counts the secrets written before data keys, they are only migrated when they are read
+	Legacy int `json:"legacy"`
+}
+
+// KeyRotationUseCase represents interface for re-wrapping the data keys under the active master key
+type KeyRotationUseCase interface {
+	RewrapSecrets(ctx context.Context, progress RewrapProgress, batchSize int, checkpoint func(RewrapProgress) error) (RewrapProgress, error)
+}
+
+// DataKey is the per secret key material. ContentKey encrypts the secret and is only ever held in memory,
+// WrappedKey is the data key encrypted by the master key with the id KeyID and is stored with the secret
+type DataKey struct {
+	ContentKey string
+	WrappedKey string
+	KeyID      string
 }
 
 // Encryptor is an interface to abstract the encryption function
 type Encryptor interface {
-	EncryptMessage(plaintext string, hash string) (string, error)
+	GenerateKey() (string, error)
+	GenerateDataKey(ctx context.Context, linkKey string) (DataKey, error)
+	UnwrapDataKey(ctx context.Context, linkKey string, wrappedKey string, keyID string) (string, error)
+	RewrapDataKey(ctx context.Context, wrappedKey string, keyID string) (string, string, error)
+	ActiveKeyID(ctx context.Context) (string, error)
+	GeneratePassphraseKDF() (string, error)
+	DerivePassphraseKey(key string, passphrase string, kdf string) (string, error)
+	EncryptMessage(plaintext string, key string) (string, error)
+	DecryptMessage(ciphertext string, key string) (string, error)
+	EncryptStream(dst io.Writer, key string) (io.WriteCloser, error)
+	DecryptStream(src io.Reader, key string) (io.Reader, error)
+	StreamSize(size int64) int64
+	IsLegacy(ciphertext string) bool
 	GenerateSHA256Hash(inputs ...string) string
 }
+
+// IDGenerator is an interface to abstract the generation of secret ids
+type IDGenerator interface {
+	GenerateID() (string, error)
+}
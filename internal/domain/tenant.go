@@ -0,0 +1,51 @@
+package domain
+
+import (
+	"context"
+	"regexp"
+	"time"
+)
+
+// DefaultTenant is the tenant of the routes without a tenant, secrets and keys created before tenants belong to it
+const DefaultTenant = ""
+
+var (
+	// ErrTenantNotFound is returned for the routes of a tenant that is not configured
+	ErrTenantNotFound = NewError(ErrNotFound, "tenant_not_found", "tenant not found")
+
+	// ErrTenantMismatch is returned when an authenticated caller uses the routes of another tenant
+	ErrTenantMismatch = NewError(ErrForbidden, "tenant_mismatch", "caller belongs to another tenant")
+
+	// tenantNamePattern keeps tenant names usable in paths and keeps them from containing the separator of storage keys
+	tenantNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+)
+
+// TenantPolicy limits the secrets created in a tenant. MaxTTL and MaxViews of 0 are unlimited,
+// the sizes are always set and never larger than those of the deployment.
+type TenantPolicy struct {
+	MaxTTL        time.Duration
+	MaxViews      int
+	MaxSecretSize int
+	MaxFileSize   int
+	// Quota is shared by all callers of the tenant, on top of the quota of each caller
+	Quota QuotaPolicy
+}
+
+// ValidTenantName reports whether the name can be used for a tenant, lower case letters, digits and dashes
+func ValidTenantName(name string) bool {
+	return tenantNamePattern.MatchString(name)
+}
+
+// tenantKey is the context key of the tenant, unexported so only this package can set it
+type tenantKey struct{}
+
+// ContextWithTenant returns a copy of ctx that carries the tenant of the request
+func ContextWithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantFromContext returns the tenant of the request, DefaultTenant when none was set
+func TenantFromContext(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantKey{}).(string)
+	return tenant
+}
@@ -0,0 +1,71 @@
+package quota
+
+import (
+	"sync"
+
+	"github.com/google/wire"
+	"github.com/nalawade41/secret-server/config"
+	"github.com/nalawade41/secret-server/db"
+	"github.com/nalawade41/secret-server/internal/common/repository"
+	"github.com/nalawade41/secret-server/internal/domain"
+	"github.com/nalawade41/secret-server/internal/quota/repository/dynamo"
+	"github.com/nalawade41/secret-server/internal/quota/repository/memory"
+	"github.com/nalawade41/secret-server/internal/quota/repository/sqldb"
+	"github.com/nalawade41/secret-server/internal/quota/usecase"
+	secretsql "github.com/nalawade41/secret-server/internal/secret/repository/sqldb"
+)
+
+var (
+	quotaUseCase *usecase.QuotaUseCase
+	ucOnce       sync.Once
+
+	repo     domain.QuotaRepository
+	repoOnce sync.Once
+
+	ProviderSet wire.ProviderSet = wire.NewSet(
+		NewQuotaUseCase,
+		NewQuotaRepository,
+
+		wire.Bind(new(domain.QuotaUseCase), new(*usecase.QuotaUseCase)),
+	)
+)
+
+// NewQuotaUseCase creates the use case charging the secrets to the quota of every caller and of their tenant
+func NewQuotaUseCase(repo domain.QuotaRepository, cfg *config.QuotaConfig, secretConfig *config.SecretConfig) *usecase.QuotaUseCase {
+	ucOnce.Do(func() {
+		quotaUseCase = &usecase.QuotaUseCase{
+			QuotaRepo: repo,
+			PerCaller: cfg.PerCaller,
+			Tenants:   secretConfig.Tenants,
+		}
+	})
+	return quotaUseCase
+}
+
+// NewQuotaRepository creates the quota repository of the storage backend selected at startup
+func NewQuotaRepository(storage *db.Storage, cfg *config.QuotaConfig) domain.QuotaRepository {
+	repoOnce.Do(func() {
+		switch storage.Backend {
+		case config.StorageMemory:
+			repo = memory.NewQuotaRepository()
+		case config.StorageSQLite, config.StoragePostgres:
+			dialect := secretsql.SQLite
+			if storage.Backend == config.StoragePostgres {
+				dialect = secretsql.Postgres
+			}
+			repo = &sqldb.QuotaRepository{
+				DB:        storage.SQL,
+				Dialect:   dialect,
+				TableName: cfg.TableName,
+			}
+		default:
+			repo = &dynamo.QuotaRepository{
+				BaseRepository: repository.BaseRepository{
+					DBConnection: storage.DynamoDB,
+				},
+				TableName: cfg.TableName,
+			}
+		}
+	})
+	return repo
+}
@@ -0,0 +1,56 @@
+package dynamo
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/nalawade41/secret-server/db"
+	"github.com/nalawade41/secret-server/internal/common/repository"
+	"github.com/nalawade41/secret-server/internal/domain"
+	"github.com/nalawade41/secret-server/internal/quota/repository/repositorytest"
+	"github.com/stretchr/testify/require"
+)
+
+// TestConformance runs the conformance suite against dynamodb-local, e.g. the one of docker-compose.yml:
+//
+//	DYNAMODB_LOCAL_ENDPOINT=http://localhost:8000 go test ./internal/quota/repository/dynamo/
+func TestConformance(t *testing.T) {
+	endpoint := os.Getenv("DYNAMODB_LOCAL_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("DYNAMODB_LOCAL_ENDPOINT is not set, skipping the conformance suite against dynamodb-local")
+	}
+
+	ctx := context.Background()
+	awsConfig, err := config.LoadDefaultConfig(ctx,
+		config.WithRegion("us-west-2"),
+		config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider("a1b2c3", "a1b2c3", "")),
+	)
+	require.NoError(t, err)
+
+	client := dynamodb.NewFromConfig(awsConfig, func(o *dynamodb.Options) {
+		o.BaseEndpoint = aws.String(endpoint)
+	})
+
+	// Every run gets its own table, the tests within the run share it. It is created by the same code as
+	// the table of the server, so the suite runs against the production schema.
+	tableName := "conformance_quotas_" + time.Now().UTC().Format("20060102150405.000000")
+	t.Cleanup(func() {
+		_, _ = client.DeleteTable(context.Background(), &dynamodb.DeleteTableInput{TableName: aws.String(tableName)})
+	})
+	require.NoError(t, db.EnsureQuotaTable(ctx, client, tableName), "failed to create table in dynamodb-local at %s", endpoint)
+
+	repo := &QuotaRepository{
+		BaseRepository: repository.BaseRepository{DBConnection: client},
+		TableName:      tableName,
+	}
+
+	repositorytest.Run(t, func(t *testing.T) domain.QuotaRepository {
+		return repo
+	})
+}
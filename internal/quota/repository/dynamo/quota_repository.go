@@ -0,0 +1,108 @@
+package dynamo
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/nalawade41/secret-server/internal/common/repository"
+	"github.com/nalawade41/secret-server/internal/domain"
+	"github.com/pkg/errors"
+)
+
+// ttlAttributeName is the attribute DynamoDB deletes the usage by, the time to live is enabled on the table
+const ttlAttributeName = "ttl"
+
+type QuotaRepository struct {
+	repository.BaseRepository
+	TableName string
+}
+
+// GetUsage reads the usage consistently, a stale usage would only fail to save
+func (r QuotaRepository) GetUsage(ctx context.Context, subject string) (domain.QuotaUsage, error) {
+	result, err := r.DBConnection.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(r.TableName),
+		Key: map[string]types.AttributeValue{
+			"subject": &types.AttributeValueMemberS{Value: subject},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return domain.QuotaUsage{}, errors.Wrap(domain.Unavailable(err), fmt.Sprintf("failed to retrieve quota usage of %s", subject))
+	}
+
+	if result.Item == nil {
+		return domain.QuotaUsage{Subject: subject}, nil
+	}
+
+	var usage domain.QuotaUsage
+	if err := attributevalue.UnmarshalMap(result.Item, &usage); err != nil {
+		return domain.QuotaUsage{}, errors.Wrap(err, fmt.Sprintf("failed to unmarshal quota usage: %v", err))
+	}
+
+	return usage, nil
+}
+
+// SaveUsage puts the usage at the next version in a conditional write, the write only succeeds while the
+// stored usage is still at the version of the usage, or for version 0 while there is none
+func (r QuotaRepository) SaveUsage(ctx context.Context, usage domain.QuotaUsage) error {
+	version := usage.Version
+	usage.Version++
+
+	item, err := attributevalue.MarshalMap(usage)
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("failed to marshal quota usage: %v", err))
+	}
+
+	if ttl := expiresAt(usage); ttl > 0 {
+		item[ttlAttributeName] = &types.AttributeValueMemberN{Value: strconv.FormatInt(ttl, 10)}
+	}
+
+	input := &dynamodb.PutItemInput{
+		TableName:                aws.String(r.TableName),
+		Item:                     item,
+		ConditionExpression:      aws.String("attribute_not_exists(#subject)"),
+		ExpressionAttributeNames: map[string]string{"#subject": "subject"},
+	}
+	if version > 0 {
+		input.ConditionExpression = aws.String("#version = :version")
+		input.ExpressionAttributeNames = map[string]string{"#version": "version"}
+		input.ExpressionAttributeValues = map[string]types.AttributeValue{
+			":version": &types.AttributeValueMemberN{Value: strconv.FormatInt(version, 10)},
+		}
+	}
+
+	if _, err := r.DBConnection.PutItem(ctx, input); err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return domain.ErrQuotaUsageChanged
+		}
+		return errors.Wrap(domain.Unavailable(err), fmt.Sprintf("failed to put quota usage of %s", usage.Subject))
+	}
+
+	return nil
+}
+
+// expiresAt returns the time in epoch seconds after which the usage counts nothing anymore, the end of its day
+// or of its last bucket, whichever is later
+func expiresAt(usage domain.QuotaUsage) int64 {
+	var ttl int64
+	if day, err := time.Parse("2006-01-02", usage.Day); err == nil {
+		ttl = day.Add(24 * time.Hour).Unix()
+	}
+
+	for _, bucket := range usage.Buckets {
+		if bucket.Until > ttl {
+			ttl = bucket.Until
+		}
+	}
+
+	return ttl
+}
+
+var _ domain.QuotaRepository = (*QuotaRepository)(nil)
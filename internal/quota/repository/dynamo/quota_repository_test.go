@@ -0,0 +1,93 @@
+package dynamo
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/golang/mock/gomock"
+	"github.com/nalawade41/secret-server/internal/common/repository"
+	"github.com/nalawade41/secret-server/internal/domain"
+	"github.com/nalawade41/secret-server/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestRepository(ctrl *gomock.Controller) (QuotaRepository, *mocks.MockDynamoDBAPI) {
+	mockDB := mocks.NewMockDynamoDBAPI(ctrl)
+	return QuotaRepository{BaseRepository: repository.BaseRepository{DBConnection: mockDB}, TableName: "quotas"}, mockDB
+}
+
+func TestGetUsage(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo, mockDB := newTestRepository(ctrl)
+
+	item, err := attributevalue.MarshalMap(domain.QuotaUsage{Subject: "tenant:team-a", Day: "2024-06-01", Created: 2, Version: 3})
+	assert.NoError(t, err)
+
+	mockDB.EXPECT().GetItem(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, input *dynamodb.GetItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+		assert.True(t, *input.ConsistentRead)
+		return &dynamodb.GetItemOutput{Item: item}, nil
+	})
+	usage, err := repo.GetUsage(context.Background(), "tenant:team-a")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, usage.Created)
+	assert.Equal(t, int64(3), usage.Version)
+
+	// Test case: A subject without usage
+	mockDB.EXPECT().GetItem(gomock.Any(), gomock.Any()).Return(&dynamodb.GetItemOutput{}, nil)
+	usage, err = repo.GetUsage(context.Background(), "tenant:team-b")
+	assert.NoError(t, err)
+	assert.Equal(t, domain.QuotaUsage{Subject: "tenant:team-b"}, usage)
+
+	// Test case: DynamoDB is unavailable
+	mockDB.EXPECT().GetItem(gomock.Any(), gomock.Any()).Return(nil, errors.New("throttled"))
+	_, err = repo.GetUsage(context.Background(), "tenant:team-a")
+	assert.ErrorIs(t, err, domain.ErrUnavailable)
+}
+
+func TestSaveUsage(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo, mockDB := newTestRepository(ctrl)
+	usage := domain.QuotaUsage{
+		Subject: "tenant:team-a",
+		Day:     "2024-06-01",
+		Created: 1,
+		Buckets: []domain.QuotaBucket{{Until: 1717401600, Secrets: 1, Bytes: 10}},
+	}
+
+	// A new usage must not exist yet, it expires with its last bucket which ends after its day
+	mockDB.EXPECT().PutItem(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, input *dynamodb.PutItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+		assert.Equal(t, "attribute_not_exists(#subject)", *input.ConditionExpression)
+		assert.Equal(t, &types.AttributeValueMemberN{Value: "1"}, input.Item["version"])
+		assert.Equal(t, &types.AttributeValueMemberN{Value: "1717401600"}, input.Item["ttl"])
+		return &dynamodb.PutItemOutput{}, nil
+	})
+	assert.NoError(t, repo.SaveUsage(context.Background(), usage))
+
+	// Test case: A stored usage is only replaced at its version, it expires at the end of its day
+	usage.Version = 4
+	usage.Buckets = nil
+	mockDB.EXPECT().PutItem(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, input *dynamodb.PutItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+		assert.Equal(t, "#version = :version", *input.ConditionExpression)
+		assert.Equal(t, &types.AttributeValueMemberN{Value: "4"}, input.ExpressionAttributeValues[":version"])
+		assert.Equal(t, &types.AttributeValueMemberN{Value: "5"}, input.Item["version"])
+		assert.Equal(t, &types.AttributeValueMemberN{Value: "1717286400"}, input.Item["ttl"])
+		return &dynamodb.PutItemOutput{}, nil
+	})
+	assert.NoError(t, repo.SaveUsage(context.Background(), usage))
+
+	// Test case: The usage was saved by another request
+	mockDB.EXPECT().PutItem(gomock.Any(), gomock.Any()).Return(nil, &types.ConditionalCheckFailedException{})
+	assert.ErrorIs(t, repo.SaveUsage(context.Background(), usage), domain.ErrQuotaUsageChanged)
+
+	// Test case: DynamoDB is unavailable
+	mockDB.EXPECT().PutItem(gomock.Any(), gomock.Any()).Return(nil, errors.New("throttled"))
+	assert.ErrorIs(t, repo.SaveUsage(context.Background(), usage), domain.ErrUnavailable)
+}
@@ -0,0 +1,53 @@
+package memory
+
+import (
+	"context"
+	"sync"
+
+	"github.com/nalawade41/secret-server/internal/domain"
+)
+
+// QuotaRepository keeps the quota usage in process memory, the usage is lost on restart
+type QuotaRepository struct {
+	mu     sync.Mutex
+	usages map[string]domain.QuotaUsage
+}
+
+// NewQuotaRepository creates an empty in memory quota repository
+func NewQuotaRepository() *QuotaRepository {
+	return &QuotaRepository{
+		usages: make(map[string]domain.QuotaUsage),
+	}
+}
+
+func (r *QuotaRepository) GetUsage(_ context.Context, subject string) (domain.QuotaUsage, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	usage, ok := r.usages[subject]
+	if !ok {
+		return domain.QuotaUsage{Subject: subject}, nil
+	}
+
+	// The buckets are changed in place by the callers, they get a copy like from the other repositories
+	usage.Buckets = append([]domain.QuotaBucket(nil), usage.Buckets...)
+	return usage, nil
+}
+
+// SaveUsage compares the version under the lock, so only one of two concurrent saves of a usage succeeds
+func (r *QuotaRepository) SaveUsage(_ context.Context, usage domain.QuotaUsage) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.usages[usage.Subject].Version != usage.Version {
+		return domain.ErrQuotaUsageChanged
+	}
+
+	usage.Version++
+	usage.Buckets = append([]domain.QuotaBucket(nil), usage.Buckets...)
+	r.usages[usage.Subject] = usage
+
+	return nil
+}
+
+var _ domain.QuotaRepository = (*QuotaRepository)(nil)
@@ -0,0 +1,130 @@
+// Package repositorytest holds the conformance suite every domain.QuotaRepository has to pass.
+// A new storage backend runs the suite from its tests:
+//
+//	func TestConformance(t *testing.T) {
+//		repositorytest.Run(t, func(t *testing.T) domain.QuotaRepository { return newRepository(t) })
+//	}
+package repositorytest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"testing"
+
+	"github.com/nalawade41/secret-server/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Factory returns the repository under test. It may return the same repository for every test,
+// the tests only work on subjects of their own.
+type Factory func(t *testing.T) domain.QuotaRepository
+
+// Run runs the conformance suite against the repositories created by newRepository
+func Run(t *testing.T, newRepository Factory) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, repo domain.QuotaRepository)
+	}{
+		{"GetUsageEmpty", testGetUsageEmpty},
+		{"SaveAndGetUsage", testSaveAndGetUsage},
+		{"SaveUsageChanged", testSaveUsageChanged},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newRepository(t))
+		})
+	}
+}
+
+// newSubject returns a random subject
+func newSubject(t *testing.T) string {
+	b := make([]byte, 12)
+	_, err := rand.Read(b)
+	require.NoError(t, err)
+
+	return "caller:team-a:apikey:" + hex.EncodeToString(b)
+}
+
+func testGetUsageEmpty(t *testing.T, repo domain.QuotaRepository) {
+	subject := newSubject(t)
+
+	usage, err := repo.GetUsage(context.Background(), subject)
+	require.NoError(t, err)
+
+	assert.Equal(t, subject, usage.Subject)
+	assert.Zero(t, usage.Version)
+	assert.Zero(t, usage.Created)
+	assert.Empty(t, usage.Buckets)
+}
+
+func testSaveAndGetUsage(t *testing.T, repo domain.QuotaRepository) {
+	ctx := context.Background()
+
+	usage := domain.QuotaUsage{
+		Subject: newSubject(t),
+		Day:     "2024-06-01",
+		Created: 3,
+		Buckets: []domain.QuotaBucket{
+			{Until: 1717228800, Secrets: 2, Bytes: 2048},
+			{Until: 1717232400, Secrets: 1, Bytes: 12},
+		},
+	}
+	require.NoError(t, repo.SaveUsage(ctx, usage))
+
+	stored, err := repo.GetUsage(ctx, usage.Subject)
+	require.NoError(t, err)
+
+	// Every save moves the usage to the next version
+	assert.Equal(t, int64(1), stored.Version)
+	assert.Equal(t, usage.Day, stored.Day)
+	assert.Equal(t, usage.Created, stored.Created)
+	assert.Equal(t, usage.Buckets, stored.Buckets)
+
+	stored.Created = 4
+	stored.Buckets = stored.Buckets[1:]
+	require.NoError(t, repo.SaveUsage(ctx, stored))
+
+	updated, err := repo.GetUsage(ctx, usage.Subject)
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(2), updated.Version)
+	assert.Equal(t, 4, updated.Created)
+	assert.Equal(t, usage.Buckets[1:], updated.Buckets)
+
+	// Test case: The last bucket is dropped
+	updated.Buckets = nil
+	require.NoError(t, repo.SaveUsage(ctx, updated))
+
+	emptied, err := repo.GetUsage(ctx, usage.Subject)
+	require.NoError(t, err)
+	assert.Empty(t, emptied.Buckets)
+}
+
+func testSaveUsageChanged(t *testing.T, repo domain.QuotaRepository) {
+	ctx := context.Background()
+
+	usage := domain.QuotaUsage{Subject: newSubject(t), Day: "2024-06-01", Created: 1}
+	require.NoError(t, repo.SaveUsage(ctx, usage))
+
+	// Test case: A usage that was never read can not be created again
+	assert.ErrorIs(t, repo.SaveUsage(ctx, usage), domain.ErrQuotaUsageChanged)
+
+	first, err := repo.GetUsage(ctx, usage.Subject)
+	require.NoError(t, err)
+	second, err := repo.GetUsage(ctx, usage.Subject)
+	require.NoError(t, err)
+
+	// Test case: Only the first of two saves of the same version succeeds
+	first.Created++
+	require.NoError(t, repo.SaveUsage(ctx, first))
+
+	second.Created += 10
+	assert.ErrorIs(t, repo.SaveUsage(ctx, second), domain.ErrQuotaUsageChanged)
+
+	stored, err := repo.GetUsage(ctx, usage.Subject)
+	require.NoError(t, err)
+	assert.Equal(t, 2, stored.Created)
+}
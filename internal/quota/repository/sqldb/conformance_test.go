@@ -0,0 +1,25 @@
+package sqldb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nalawade41/secret-server/config"
+	"github.com/nalawade41/secret-server/db"
+	"github.com/nalawade41/secret-server/internal/domain"
+	"github.com/nalawade41/secret-server/internal/quota/repository/repositorytest"
+	secretsql "github.com/nalawade41/secret-server/internal/secret/repository/sqldb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConformance_SQLite(t *testing.T) {
+	repositorytest.Run(t, func(t *testing.T) domain.QuotaRepository {
+		conn, err := db.OpenSQL(&config.StorageConfig{Backend: config.StorageSQLite, SQLitePath: ":memory:"})
+		require.NoError(t, err)
+		t.Cleanup(func() { conn.Close() })
+
+		require.NoError(t, db.CreateSQLQuotaTable(context.Background(), conn, "quotas"))
+
+		return QuotaRepository{DB: conn, Dialect: secretsql.SQLite, TableName: "quotas"}
+	})
+}
@@ -0,0 +1,82 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/nalawade41/secret-server/internal/domain"
+	secretsql "github.com/nalawade41/secret-server/internal/secret/repository/sqldb"
+	"github.com/pkg/errors"
+)
+
+// QuotaRepository stores the quota usage in a table of an SQLite or PostgreSQL database
+type QuotaRepository struct {
+	DB        *sql.DB
+	Dialect   secretsql.Dialect
+	TableName string
+}
+
+// query fills the table name into the query and adapts its placeholders to the dialect
+func (r QuotaRepository) query(format string) string {
+	return r.Dialect.Rebind(fmt.Sprintf(format, r.TableName))
+}
+
+func (r QuotaRepository) GetUsage(ctx context.Context, subject string) (domain.QuotaUsage, error) {
+	usage := domain.QuotaUsage{Subject: subject}
+
+	var buckets string
+	err := r.DB.QueryRowContext(ctx, r.query("SELECT day, created, buckets, version FROM %s WHERE subject = ?"), subject).
+		Scan(&usage.Day, &usage.Created, &buckets, &usage.Version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return usage, nil
+	}
+	if err != nil {
+		return domain.QuotaUsage{}, errors.Wrap(domain.Unavailable(err), fmt.Sprintf("failed to retrieve quota usage of %s", subject))
+	}
+
+	if err := json.Unmarshal([]byte(buckets), &usage.Buckets); err != nil {
+		return domain.QuotaUsage{}, errors.Wrap(err, fmt.Sprintf("failed to unmarshal quota buckets: %v", err))
+	}
+
+	return usage, nil
+}
+
+// SaveUsage inserts the usage of version 0 and otherwise only updates the row still at the version of the
+// usage, no affected row means another request saved the usage in the meantime
+func (r QuotaRepository) SaveUsage(ctx context.Context, usage domain.QuotaUsage) error {
+	buckets := usage.Buckets
+	if buckets == nil {
+		buckets = []domain.QuotaBucket{}
+	}
+
+	encoded, err := json.Marshal(buckets)
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("failed to marshal quota buckets: %v", err))
+	}
+
+	var result sql.Result
+	if usage.Version == 0 {
+		result, err = r.DB.ExecContext(ctx, r.query("INSERT INTO %s (subject, day, created, buckets, version) VALUES (?, ?, ?, ?, 1) ON CONFLICT (subject) DO NOTHING"),
+			usage.Subject, usage.Day, usage.Created, string(encoded))
+	} else {
+		result, err = r.DB.ExecContext(ctx, r.query("UPDATE %s SET day = ?, created = ?, buckets = ?, version = version + 1 WHERE subject = ? AND version = ?"),
+			usage.Day, usage.Created, string(encoded), usage.Subject, usage.Version)
+	}
+	if err != nil {
+		return errors.Wrap(domain.Unavailable(err), fmt.Sprintf("failed to save quota usage of %s", usage.Subject))
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return errors.Wrap(domain.Unavailable(err), fmt.Sprintf("failed to save quota usage of %s", usage.Subject))
+	}
+	if rows == 0 {
+		return domain.ErrQuotaUsageChanged
+	}
+
+	return nil
+}
+
+var _ domain.QuotaRepository = (*QuotaRepository)(nil)
@@ -0,0 +1,295 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/nalawade41/secret-server/internal/common/logger"
+	"github.com/nalawade41/secret-server/internal/domain"
+	"github.com/pkg/errors"
+)
+
+const (
+	// maxSaveAttempts is how often the usage is read and saved again when another request saved it in the meantime
+	maxSaveAttempts = 5
+
+	// bucketSize is the span of the expiry times of the secrets sharing a bucket
+	bucketSize = time.Hour
+
+	// dayLayout is the layout of the day of a usage
+	dayLayout = "2006-01-02"
+)
+
+// QuotaUseCase charges the secrets to the quota of their creator and to the quota of their tenant. The usage
+// is kept in the repository, so every instance of the server enforces the same quotas.
+type QuotaUseCase struct {
+	QuotaRepo domain.QuotaRepository
+	// PerCaller is the quota of every authenticated caller, anonymous secrets are only charged to their tenant
+	PerCaller domain.QuotaPolicy
+	// Tenants are the configured tenants by name, the quota of a tenant is part of its policy
+	Tenants map[string]domain.TenantPolicy
+	// Now returns the current time, time.Now when nil
+	Now func() time.Time
+}
+
+// quota is a policy applied to the usage of a subject
+type quota struct {
+	subject string
+	policy  domain.QuotaPolicy
+}
+
+// Reserve charges the secret to every quota of the context. When one of them would be exceeded the quotas
+// charged before are released again and the status of the exceeded limit is returned in the error.
+func (u QuotaUseCase) Reserve(ctx context.Context, secret domain.Secret) (domain.QuotaStatus, error) {
+	now := u.now()
+
+	var (
+		status  domain.QuotaStatus
+		charged []quota
+	)
+	for _, q := range u.quotas(ctx, secret.Owner) {
+		usage, err := u.update(ctx, q.subject, now, func(usage *domain.QuotaUsage) error {
+			if exceeded, ok := exceededLimit(q.policy, *usage, secret.QuotaBytes, now); ok {
+				return &domain.QuotaExceededError{Status: exceeded}
+			}
+
+			usage.Created++
+			charge(usage, secret, 1)
+			return nil
+		})
+		if err != nil {
+			u.refund(ctx, charged, secret, now)
+			return domain.QuotaStatus{}, err
+		}
+
+		charged = append(charged, q)
+		status = mostRestrictive(status, currentStatus(q.policy, usage, now))
+	}
+
+	return status, nil
+}
+
+// Release returns the charge of a secret that was removed before it expired. The secrets created per day
+// are not given back, the other limits free up as if the secret had expired.
+func (u QuotaUseCase) Release(ctx context.Context, secret domain.Secret) error {
+	// Secrets created before quotas were never charged
+	if secret.QuotaBytes == 0 {
+		return nil
+	}
+
+	now := u.now()
+	for _, q := range u.quotas(ctx, secret.Owner) {
+		if _, err := u.update(ctx, q.subject, now, func(usage *domain.QuotaUsage) error {
+			charge(usage, secret, -1)
+			return nil
+		}); err != nil {
+			return errors.Wrap(err, fmt.Sprintf("failed to release quota of %s", q.subject))
+		}
+	}
+
+	return nil
+}
+
+// refund releases the quotas charged before a later quota was exceeded, failures are only logged as the
+// charge frees up once the secret would have expired
+func (u QuotaUseCase) refund(ctx context.Context, charged []quota, secret domain.Secret, now time.Time) {
+	for _, q := range charged {
+		if _, err := u.update(ctx, q.subject, now, func(usage *domain.QuotaUsage) error {
+			if usage.Created > 0 {
+				usage.Created--
+			}
+			charge(usage, secret, -1)
+			return nil
+		}); err != nil {
+			logger.Errorf("failed to refund quota of %s: %v", q.subject, err)
+		}
+	}
+}
+
+// quotas returns the quotas with limits of the owner and of the tenant of the context
+func (u QuotaUseCase) quotas(ctx context.Context, owner string) []quota {
+	tenant := domain.TenantFromContext(ctx)
+
+	var quotas []quota
+	if owner != "" && u.PerCaller.Enabled() {
+		// Tenant names never contain a colon, so the subjects of callers of different tenants never collide
+		quotas = append(quotas, quota{subject: "caller:" + tenant + ":" + owner, policy: u.PerCaller})
+	}
+	if policy := u.Tenants[tenant].Quota; policy.Enabled() {
+		quotas = append(quotas, quota{subject: "tenant:" + tenant, policy: policy})
+	}
+
+	return quotas
+}
+
+// update applies the change to the current usage of the subject and saves it. A usage saved by another
+// request in the meantime is read again and the change applied anew.
+func (u QuotaUseCase) update(ctx context.Context, subject string, now time.Time, change func(usage *domain.QuotaUsage) error) (domain.QuotaUsage, error) {
+	for attempt := 1; ; attempt++ {
+		usage, err := u.QuotaRepo.GetUsage(ctx, subject)
+		if err != nil {
+			return domain.QuotaUsage{}, errors.Wrap(err, fmt.Sprintf("failed to retrieve quota usage of %s", subject))
+		}
+
+		prune(&usage, now)
+		if err := change(&usage); err != nil {
+			return domain.QuotaUsage{}, err
+		}
+
+		err = u.QuotaRepo.SaveUsage(ctx, usage)
+		if err == nil {
+			return usage, nil
+		}
+
+		if !errors.Is(err, domain.ErrQuotaUsageChanged) || attempt == maxSaveAttempts {
+			return domain.QuotaUsage{}, errors.Wrap(err, fmt.Sprintf("failed to save quota usage of %s", subject))
+		}
+
+		logger.Warnf("quota usage of %s changed on attempt %d, reading it again", subject, attempt)
+	}
+}
+
+func (u QuotaUseCase) now() time.Time {
+	if u.Now != nil {
+		return u.Now().UTC()
+	}
+	return time.Now().UTC()
+}
+
+// prune starts the count of a new day and drops the buckets of the secrets that have expired
+func prune(usage *domain.QuotaUsage, now time.Time) {
+	if day := now.Format(dayLayout); usage.Day != day {
+		usage.Day = day
+		usage.Created = 0
+	}
+
+	buckets := usage.Buckets[:0]
+	for _, bucket := range usage.Buckets {
+		if bucket.Until > now.Unix() {
+			buckets = append(buckets, bucket)
+		}
+	}
+	usage.Buckets = buckets
+}
+
+// charge adds the secret to the bucket of its expiry time, or takes it out of it for a negative sign.
+// The counts never drop below zero, a bucket without secrets is dropped.
+func charge(usage *domain.QuotaUsage, secret domain.Secret, sign int) {
+	until := secret.ExpiresAt.UTC().Truncate(bucketSize).Add(bucketSize).Unix()
+	i := sort.Search(len(usage.Buckets), func(i int) bool { return usage.Buckets[i].Until >= until })
+	if i == len(usage.Buckets) || usage.Buckets[i].Until != until {
+		if sign < 0 {
+			return
+		}
+		usage.Buckets = append(usage.Buckets, domain.QuotaBucket{})
+		copy(usage.Buckets[i+1:], usage.Buckets[i:])
+		usage.Buckets[i] = domain.QuotaBucket{Until: until}
+	}
+
+	bucket := &usage.Buckets[i]
+	bucket.Secrets += sign
+	bucket.Bytes += int64(sign) * secret.QuotaBytes
+	if bucket.Bytes < 0 {
+		bucket.Bytes = 0
+	}
+	if bucket.Secrets <= 0 {
+		usage.Buckets = append(usage.Buckets[:i], usage.Buckets[i+1:]...)
+	}
+}
+
+// live returns the number and the bytes of the secrets in the buckets
+func live(usage domain.QuotaUsage) (int, int64) {
+	var (
+		secrets int
+		bytes   int64
+	)
+	for _, bucket := range usage.Buckets {
+		secrets += bucket.Secrets
+		bytes += bucket.Bytes
+	}
+	return secrets, bytes
+}
+
+// exceededLimit returns the status of the first limit of the policy a secret of the size would exceed
+func exceededLimit(policy domain.QuotaPolicy, usage domain.QuotaUsage, size int64, now time.Time) (domain.QuotaStatus, bool) {
+	secrets, bytes := live(usage)
+
+	switch {
+	case policy.SecretsPerDay > 0 && usage.Created+1 > policy.SecretsPerDay:
+		return dayStatus(policy, usage, now), true
+	case policy.MaxLiveSecrets > 0 && secrets+1 > policy.MaxLiveSecrets:
+		return liveStatus(policy, usage, now), true
+	case policy.MaxBytes > 0 && bytes+size > policy.MaxBytes:
+		return bytesStatus(policy, usage, now), true
+	}
+
+	return domain.QuotaStatus{}, false
+}
+
+// currentStatus returns the status of the most restrictive limit of the policy
+func currentStatus(policy domain.QuotaPolicy, usage domain.QuotaUsage, now time.Time) domain.QuotaStatus {
+	var status domain.QuotaStatus
+	if policy.SecretsPerDay > 0 {
+		status = mostRestrictive(status, dayStatus(policy, usage, now))
+	}
+	if policy.MaxLiveSecrets > 0 {
+		status = mostRestrictive(status, liveStatus(policy, usage, now))
+	}
+	if policy.MaxBytes > 0 {
+		status = mostRestrictive(status, bytesStatus(policy, usage, now))
+	}
+	return status
+}
+
+// dayStatus is the status of the secrets created per day, the count resets at the next UTC midnight
+func dayStatus(policy domain.QuotaPolicy, usage domain.QuotaUsage, now time.Time) domain.QuotaStatus {
+	midnight := now.Truncate(24 * time.Hour).Add(24 * time.Hour)
+	return newStatus(int64(policy.SecretsPerDay), int64(usage.Created), midnight.Sub(now))
+}
+
+// liveStatus is the status of the live secrets, they free up when the earliest bucket ends
+func liveStatus(policy domain.QuotaPolicy, usage domain.QuotaUsage, now time.Time) domain.QuotaStatus {
+	secrets, _ := live(usage)
+	return newStatus(int64(policy.MaxLiveSecrets), int64(secrets), untilFirstBucket(usage, now))
+}
+
+// bytesStatus is the status of the bytes of the live secrets, they free up when the earliest bucket ends
+func bytesStatus(policy domain.QuotaPolicy, usage domain.QuotaUsage, now time.Time) domain.QuotaStatus {
+	_, bytes := live(usage)
+	return newStatus(policy.MaxBytes, bytes, untilFirstBucket(usage, now))
+}
+
+// untilFirstBucket returns the time until the earliest bucket ends, 0 without buckets
+func untilFirstBucket(usage domain.QuotaUsage, now time.Time) time.Duration {
+	if len(usage.Buckets) == 0 {
+		return 0
+	}
+	return time.Unix(usage.Buckets[0].Until, 0).Sub(now)
+}
+
+func newStatus(limit int64, used int64, reset time.Duration) domain.QuotaStatus {
+	remaining := limit - used
+	if remaining < 0 {
+		remaining = 0
+	}
+	return domain.QuotaStatus{Limit: limit, Remaining: remaining, Reset: reset}
+}
+
+// mostRestrictive returns the status with the smaller share of its limit remaining, a zero status has no limit
+func mostRestrictive(a domain.QuotaStatus, b domain.QuotaStatus) domain.QuotaStatus {
+	if a.Limit == 0 {
+		return b
+	}
+	if b.Limit == 0 {
+		return a
+	}
+
+	if float64(b.Remaining)/float64(b.Limit) < float64(a.Remaining)/float64(a.Limit) {
+		return b
+	}
+	return a
+}
+
+var _ domain.QuotaUseCase = (*QuotaUseCase)(nil)
@@ -0,0 +1,211 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/nalawade41/secret-server/internal/domain"
+	"github.com/nalawade41/secret-server/internal/quota/repository/memory"
+	"github.com/nalawade41/secret-server/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testNow is the time of the tests, 18:30 UTC so the day resets in 5h30m
+var testNow = time.Date(2024, 6, 1, 18, 30, 0, 0, time.UTC)
+
+func newTestUseCase(perCaller domain.QuotaPolicy, tenants map[string]domain.TenantPolicy) (*QuotaUseCase, *time.Time) {
+	now := testNow
+	return &QuotaUseCase{
+		QuotaRepo: memory.NewQuotaRepository(),
+		PerCaller: perCaller,
+		Tenants:   tenants,
+		Now:       func() time.Time { return now },
+	}, &now
+}
+
+// newSecret returns a secret of the owner expiring after ttl
+func newSecret(owner string, size int64, ttl time.Duration) domain.Secret {
+	return domain.Secret{Owner: owner, QuotaBytes: size, ExpiresAt: testNow.Add(ttl)}
+}
+
+func TestReserve_SecretsPerDay(t *testing.T) {
+	useCase, now := newTestUseCase(domain.QuotaPolicy{SecretsPerDay: 2}, nil)
+	ctx := context.Background()
+
+	status, err := useCase.Reserve(ctx, newSecret("apikey:a", 10, time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, domain.QuotaStatus{Limit: 2, Remaining: 1, Reset: 5*time.Hour + 30*time.Minute}, status)
+
+	_, err = useCase.Reserve(ctx, newSecret("apikey:a", 10, time.Hour))
+	require.NoError(t, err)
+
+	// Test case: The third secret of the day is refused until midnight
+	_, err = useCase.Reserve(ctx, newSecret("apikey:a", 10, time.Hour))
+	var exceeded *domain.QuotaExceededError
+	require.ErrorAs(t, err, &exceeded)
+	assert.ErrorIs(t, err, domain.ErrQuotaExceeded)
+	assert.Equal(t, domain.QuotaStatus{Limit: 2, Remaining: 0, Reset: 5*time.Hour + 30*time.Minute}, exceeded.Status)
+
+	// Test case: Another caller has a quota of its own
+	_, err = useCase.Reserve(ctx, newSecret("apikey:b", 10, time.Hour))
+	assert.NoError(t, err)
+
+	// Test case: A secret removed early does not give back the secrets of the day
+	assert.NoError(t, useCase.Release(ctx, newSecret("apikey:a", 10, time.Hour)))
+	_, err = useCase.Reserve(ctx, newSecret("apikey:a", 10, time.Hour))
+	assert.ErrorIs(t, err, domain.ErrQuotaExceeded)
+
+	// Test case: The count starts over on the next day
+	*now = testNow.Add(6 * time.Hour)
+	_, err = useCase.Reserve(ctx, newSecret("apikey:a", 10, time.Hour))
+	assert.NoError(t, err)
+}
+
+func TestReserve_LiveSecrets(t *testing.T) {
+	useCase, now := newTestUseCase(domain.QuotaPolicy{MaxLiveSecrets: 2}, nil)
+	ctx := context.Background()
+
+	first := newSecret("apikey:a", 10, 10*time.Minute)
+	_, err := useCase.Reserve(ctx, first)
+	require.NoError(t, err)
+
+	status, err := useCase.Reserve(ctx, newSecret("apikey:a", 10, 3*time.Hour))
+	require.NoError(t, err)
+	// The live secrets free up at the end of the hour the first of them expires in
+	assert.Equal(t, domain.QuotaStatus{Limit: 2, Remaining: 0, Reset: 30 * time.Minute}, status)
+
+	// Test case: A third live secret is refused
+	_, err = useCase.Reserve(ctx, newSecret("apikey:a", 10, time.Hour))
+	var exceeded *domain.QuotaExceededError
+	require.ErrorAs(t, err, &exceeded)
+	assert.Equal(t, 30*time.Minute, exceeded.Status.Reset)
+
+	// Test case: A released secret frees its place
+	require.NoError(t, useCase.Release(ctx, first))
+	_, err = useCase.Reserve(ctx, newSecret("apikey:a", 10, time.Hour))
+	assert.NoError(t, err)
+
+	// Test case: Expired secrets are not live anymore
+	*now = testNow.Add(2 * time.Hour)
+	_, err = useCase.Reserve(ctx, newSecret("apikey:a", 10, time.Hour))
+	assert.NoError(t, err)
+}
+
+func TestReserve_Bytes(t *testing.T) {
+	useCase, _ := newTestUseCase(domain.QuotaPolicy{MaxBytes: 100}, nil)
+	ctx := context.Background()
+
+	status, err := useCase.Reserve(ctx, newSecret("apikey:a", 60, time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, int64(40), status.Remaining)
+
+	// Test case: A secret larger than the bytes left is refused
+	_, err = useCase.Reserve(ctx, newSecret("apikey:a", 41, time.Hour))
+	assert.ErrorIs(t, err, domain.ErrQuotaExceeded)
+
+	_, err = useCase.Reserve(ctx, newSecret("apikey:a", 40, time.Hour))
+	assert.NoError(t, err)
+}
+
+func TestReserve_Tenant(t *testing.T) {
+	tenants := map[string]domain.TenantPolicy{
+		"team-a": {Quota: domain.QuotaPolicy{SecretsPerDay: 2}},
+	}
+	useCase, _ := newTestUseCase(domain.QuotaPolicy{SecretsPerDay: 10}, tenants)
+	ctx := domain.ContextWithTenant(context.Background(), "team-a")
+
+	// The tenant quota is the most restrictive one
+	status, err := useCase.Reserve(ctx, newSecret("apikey:a", 10, time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), status.Limit)
+	assert.Equal(t, int64(1), status.Remaining)
+
+	// Anonymous secrets are only charged to the tenant
+	_, err = useCase.Reserve(ctx, newSecret("", 10, time.Hour))
+	require.NoError(t, err)
+
+	// Test case: The tenant quota is shared by all of its callers
+	_, err = useCase.Reserve(ctx, newSecret("apikey:b", 10, time.Hour))
+	assert.ErrorIs(t, err, domain.ErrQuotaExceeded)
+
+	// The caller quota charged before the tenant quota was exceeded is refunded
+	usage, err := useCase.QuotaRepo.GetUsage(ctx, "caller:team-a:apikey:b")
+	require.NoError(t, err)
+	assert.Zero(t, usage.Created)
+	assert.Empty(t, usage.Buckets)
+
+	// Test case: The default tenant has no tenant quota
+	status, err = useCase.Reserve(context.Background(), newSecret("apikey:a", 10, time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, domain.QuotaStatus{Limit: 10, Remaining: 9, Reset: 5*time.Hour + 30*time.Minute}, status)
+}
+
+func TestReserve_NoQuota(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Without limits the repository is never used
+	useCase := QuotaUseCase{QuotaRepo: mocks.NewMockQuotaRepository(ctrl)}
+
+	status, err := useCase.Reserve(context.Background(), newSecret("apikey:a", 10, time.Hour))
+	assert.NoError(t, err)
+	assert.Zero(t, status)
+	assert.NoError(t, useCase.Release(context.Background(), newSecret("apikey:a", 10, time.Hour)))
+}
+
+func TestReserve_UsageChanged(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockQuotaRepository(ctrl)
+	useCase := QuotaUseCase{QuotaRepo: mockRepo, PerCaller: domain.QuotaPolicy{SecretsPerDay: 5}, Now: func() time.Time { return testNow }}
+
+	// A usage saved by another request in the meantime is read again
+	mockRepo.EXPECT().GetUsage(gomock.Any(), "caller::apikey:a").Return(domain.QuotaUsage{Subject: "caller::apikey:a"}, nil)
+	mockRepo.EXPECT().SaveUsage(gomock.Any(), gomock.Any()).Return(domain.ErrQuotaUsageChanged)
+	mockRepo.EXPECT().GetUsage(gomock.Any(), "caller::apikey:a").Return(domain.QuotaUsage{Subject: "caller::apikey:a", Day: "2024-06-01", Created: 1, Version: 1}, nil)
+	mockRepo.EXPECT().SaveUsage(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, usage domain.QuotaUsage) error {
+		assert.Equal(t, 2, usage.Created)
+		assert.Equal(t, int64(1), usage.Version)
+		return nil
+	})
+
+	status, err := useCase.Reserve(context.Background(), newSecret("apikey:a", 10, time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), status.Remaining)
+
+	// Test case: The usage keeps changing
+	mockRepo.EXPECT().GetUsage(gomock.Any(), gomock.Any()).Return(domain.QuotaUsage{Subject: "caller::apikey:a"}, nil).Times(maxSaveAttempts)
+	mockRepo.EXPECT().SaveUsage(gomock.Any(), gomock.Any()).Return(domain.ErrQuotaUsageChanged).Times(maxSaveAttempts)
+
+	_, err = useCase.Reserve(context.Background(), newSecret("apikey:a", 10, time.Hour))
+	assert.ErrorIs(t, err, domain.ErrQuotaUsageChanged)
+
+	// Test case: The storage is unavailable
+	mockRepo.EXPECT().GetUsage(gomock.Any(), gomock.Any()).Return(domain.QuotaUsage{}, domain.Unavailable(errors.New("connection refused")))
+
+	_, err = useCase.Reserve(context.Background(), newSecret("apikey:a", 10, time.Hour))
+	assert.ErrorIs(t, err, domain.ErrUnavailable)
+}
+
+func TestRelease_Uncharged(t *testing.T) {
+	useCase, _ := newTestUseCase(domain.QuotaPolicy{MaxLiveSecrets: 1}, nil)
+	ctx := context.Background()
+
+	_, err := useCase.Reserve(ctx, newSecret("apikey:a", 10, time.Hour))
+	require.NoError(t, err)
+
+	// Test case: Secrets created before quotas were never charged and give nothing back
+	require.NoError(t, useCase.Release(ctx, newSecret("apikey:a", 0, time.Hour)))
+	_, err = useCase.Reserve(ctx, newSecret("apikey:a", 10, time.Hour))
+	assert.ErrorIs(t, err, domain.ErrQuotaExceeded)
+
+	// Test case: A secret of another bucket does not free the place of the charged one
+	require.NoError(t, useCase.Release(ctx, newSecret("apikey:a", 10, 5*time.Hour)))
+	_, err = useCase.Reserve(ctx, newSecret("apikey:a", 10, time.Hour))
+	assert.ErrorIs(t, err, domain.ErrQuotaExceeded)
+}
@@ -0,0 +1,90 @@
+package blobstore
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/nalawade41/secret-server/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stores returns every local blob store, they all have to behave the same
+func stores(t *testing.T) map[string]domain.BlobStore {
+	fileStore, err := NewFileStore(t.TempDir())
+	require.NoError(t, err)
+
+	return map[string]domain.BlobStore{
+		"file":   fileStore,
+		"memory": NewMemoryStore(),
+	}
+}
+
+func TestPutGetDelete(t *testing.T) {
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			require.NoError(t, store.Put(ctx, "testkey", strings.NewReader("encrypted content"), 17))
+
+			content, err := store.Get(ctx, "testkey")
+			require.NoError(t, err)
+			data, err := io.ReadAll(content)
+			assert.NoError(t, err)
+			assert.NoError(t, content.Close())
+			assert.Equal(t, "encrypted content", string(data))
+
+			// A blob is replaced as a whole
+			require.NoError(t, store.Put(ctx, "testkey", strings.NewReader("new"), 3))
+			content, err = store.Get(ctx, "testkey")
+			require.NoError(t, err)
+			data, err = io.ReadAll(content)
+			assert.NoError(t, err)
+			assert.NoError(t, content.Close())
+			assert.Equal(t, "new", string(data))
+
+			assert.NoError(t, store.Delete(ctx, "testkey"))
+			_, err = store.Get(ctx, "testkey")
+			assert.ErrorIs(t, err, domain.ErrBlobNotFound)
+
+			// Deleting twice is not an error, the blob of a secret may already be gone
+			assert.NoError(t, store.Delete(ctx, "testkey"))
+		})
+	}
+}
+
+func TestGet_NotFound(t *testing.T) {
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			_, err := store.Get(context.Background(), "missing")
+			assert.ErrorIs(t, err, domain.ErrBlobNotFound)
+		})
+	}
+}
+
+func TestPut_SizeMismatch(t *testing.T) {
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			// A content that is cut short is not stored
+			assert.Error(t, store.Put(ctx, "testkey", strings.NewReader("encrypted"), 17))
+			_, err := store.Get(ctx, "testkey")
+			assert.ErrorIs(t, err, domain.ErrBlobNotFound)
+		})
+	}
+}
+
+func TestFileStore_InvalidKey(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	require.NoError(t, err)
+
+	for _, key := range []string{"", "../outside", "dir/blob", ".hidden"} {
+		assert.Error(t, store.Put(context.Background(), key, strings.NewReader("content"), 7), key)
+		_, err := store.Get(context.Background(), key)
+		assert.Error(t, err, key)
+		assert.Error(t, store.Delete(context.Background(), key), key)
+	}
+}
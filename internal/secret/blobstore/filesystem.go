@@ -0,0 +1,101 @@
+package blobstore
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/nalawade41/secret-server/internal/domain"
+	"github.com/pkg/errors"
+)
+
+// FileStore keeps every blob as a file in a local directory
+type FileStore struct {
+	Dir string
+}
+
+// NewFileStore creates the store and its directory, only the user running the server can read the blobs
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("failed to create blob directory %s: %v", dir, err))
+	}
+
+	return &FileStore{Dir: dir}, nil
+}
+
+// Put writes the content to a temporary file first, a reader never sees a partly written blob
+func (s *FileStore) Put(_ context.Context, key string, content io.Reader, size int64) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp(s.Dir, ".upload-*")
+	if err != nil {
+		return errors.Wrap(domain.Unavailable(err), fmt.Sprintf("failed to create blob %s: %v", key, err))
+	}
+	defer os.Remove(file.Name())
+
+	written, err := io.Copy(file, content)
+	if err != nil {
+		file.Close()
+		return errors.Wrap(domain.Unavailable(err), fmt.Sprintf("failed to write blob %s: %v", key, err))
+	}
+	if written != size {
+		file.Close()
+		return errors.Errorf("blob %s has %d bytes instead of %d", key, written, size)
+	}
+
+	if err := file.Close(); err != nil {
+		return errors.Wrap(domain.Unavailable(err), fmt.Sprintf("failed to write blob %s: %v", key, err))
+	}
+
+	if err := os.Rename(file.Name(), path); err != nil {
+		return errors.Wrap(domain.Unavailable(err), fmt.Sprintf("failed to store blob %s: %v", key, err))
+	}
+
+	return nil
+}
+
+func (s *FileStore) Get(_ context.Context, key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, domain.ErrBlobNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrap(domain.Unavailable(err), fmt.Sprintf("failed to open blob %s: %v", key, err))
+	}
+
+	return file, nil
+}
+
+func (s *FileStore) Delete(_ context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return errors.Wrap(domain.Unavailable(err), fmt.Sprintf("failed to delete blob %s: %v", key, err))
+	}
+
+	return nil
+}
+
+// path returns the file of the blob, keys that could point outside of the directory are refused
+func (s *FileStore) path(key string) (string, error) {
+	if key == "" || key[0] == '.' || filepath.Base(key) != key {
+		return "", errors.Errorf("invalid blob key %q", key)
+	}
+
+	return filepath.Join(s.Dir, key), nil
+}
+
+var _ domain.BlobStore = (*FileStore)(nil)
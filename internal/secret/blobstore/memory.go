@@ -0,0 +1,65 @@
+package blobstore
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/nalawade41/secret-server/internal/domain"
+	"github.com/pkg/errors"
+)
+
+// MemoryStore keeps blobs in process memory, blobs are lost on restart
+type MemoryStore struct {
+	mu    sync.Mutex
+	blobs map[string][]byte
+}
+
+// NewMemoryStore creates an empty in memory blob store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		blobs: make(map[string][]byte),
+	}
+}
+
+func (s *MemoryStore) Put(_ context.Context, key string, content io.Reader, size int64) error {
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("failed to read blob %s: %v", key, err))
+	}
+	if int64(len(data)) != size {
+		return errors.Errorf("blob %s has %d bytes instead of %d", key, len(data), size)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.blobs[key] = data
+
+	return nil
+}
+
+func (s *MemoryStore) Get(_ context.Context, key string) (io.ReadCloser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, ok := s.blobs[key]
+	if !ok {
+		return nil, domain.ErrBlobNotFound
+	}
+
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (s *MemoryStore) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.blobs, key)
+
+	return nil
+}
+
+var _ domain.BlobStore = (*MemoryStore)(nil)
@@ -0,0 +1,78 @@
+package blobstore
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/nalawade41/secret-server/internal/domain"
+	"github.com/pkg/errors"
+)
+
+// S3API represents the subset of S3 client methods the blob store uses
+type S3API interface {
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+	DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
+}
+
+// S3Store keeps every blob as an object in an S3 bucket, or in a bucket of an S3 compatible store like MinIO
+type S3Store struct {
+	Client S3API
+	Bucket string
+}
+
+// Put uploads the content in a single request. The content is already encrypted, the server side encryption
+// of the bucket only adds a second layer. The content is streamed with its length and without a payload hash,
+// hashing it first would need a body that can be read twice, which a stream being encrypted is not.
+func (s S3Store) Put(ctx context.Context, key string, content io.Reader, size int64) error {
+	_, err := s.Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(key),
+		// The SDK sends a pipe in chunks whatever its length, S3 only accepts a body of known length
+		Body:          struct{ io.Reader }{content},
+		ContentLength: aws.Int64(size),
+		ContentType:   aws.String("application/octet-stream"),
+	}, s3.WithAPIOptions(v4.SwapComputePayloadSHA256ForUnsignedPayloadMiddleware))
+	if err != nil {
+		return errors.Wrap(domain.Unavailable(err), fmt.Sprintf("failed to put blob %s: %v", key, err))
+	}
+
+	return nil
+}
+
+func (s S3Store) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	out, err := s.Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(key),
+	})
+
+	var noSuchKey *types.NoSuchKey
+	if errors.As(err, &noSuchKey) {
+		return nil, domain.ErrBlobNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrap(domain.Unavailable(err), fmt.Sprintf("failed to get blob %s: %v", key, err))
+	}
+
+	return out.Body, nil
+}
+
+// Delete removes the object, S3 does not report an error for a missing object
+func (s S3Store) Delete(ctx context.Context, key string) error {
+	_, err := s.Client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return errors.Wrap(domain.Unavailable(err), fmt.Sprintf("failed to delete blob %s: %v", key, err))
+	}
+
+	return nil
+}
+
+var _ domain.BlobStore = (*S3Store)(nil)
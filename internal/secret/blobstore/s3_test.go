@@ -0,0 +1,123 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/golang/mock/gomock"
+	"github.com/nalawade41/secret-server/internal/domain"
+	"github.com/nalawade41/secret-server/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestS3Store_Put(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockClient := mocks.NewMockS3API(ctrl)
+	store := S3Store{Client: mockClient, Bucket: "secrets"}
+
+	mockClient.EXPECT().PutObject(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, params *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+			assert.Equal(t, "secrets", aws.ToString(params.Bucket))
+			assert.Equal(t, "testkey", aws.ToString(params.Key))
+			assert.Equal(t, int64(17), aws.ToInt64(params.ContentLength))
+			data, err := io.ReadAll(params.Body)
+			assert.NoError(t, err)
+			assert.Equal(t, "encrypted content", string(data))
+			return &s3.PutObjectOutput{}, nil
+		})
+
+	assert.NoError(t, store.Put(context.Background(), "testkey", strings.NewReader("encrypted content"), 17))
+}
+
+func TestS3Store_PutStream(t *testing.T) {
+	// A plain HTTP endpoint like a local MinIO, the SDK can not use a trailing checksum there
+	var received []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPut, r.Method)
+		assert.Equal(t, "/secrets/testkey", r.URL.Path)
+		assert.Equal(t, int64(17), r.ContentLength)
+		assert.Equal(t, "UNSIGNED-PAYLOAD", r.Header.Get("X-Amz-Content-Sha256"))
+
+		var err error
+		received, err = io.ReadAll(r.Body)
+		assert.NoError(t, err)
+	}))
+	defer server.Close()
+
+	client := s3.New(s3.Options{
+		Region:       "us-east-1",
+		Credentials:  credentials.NewStaticCredentialsProvider("access", "secret", ""),
+		BaseEndpoint: aws.String(server.URL),
+		UsePathStyle: true,
+	})
+	store := S3Store{Client: client, Bucket: "secrets"}
+
+	// The content is a stream that can only be read once, as it is while it is being encrypted
+	r, w := io.Pipe()
+	go func() {
+		_, err := io.WriteString(w, "encrypted content")
+		w.CloseWithError(err)
+	}()
+
+	assert.NoError(t, store.Put(context.Background(), "testkey", r, 17))
+	assert.Equal(t, "encrypted content", string(received))
+}
+
+func TestS3Store_Get(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockClient := mocks.NewMockS3API(ctrl)
+	store := S3Store{Client: mockClient, Bucket: "secrets"}
+
+	mockClient.EXPECT().GetObject(gomock.Any(), &s3.GetObjectInput{Bucket: aws.String("secrets"), Key: aws.String("testkey")}).
+		Return(&s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader("encrypted content"))}, nil)
+
+	content, err := store.Get(context.Background(), "testkey")
+	if assert.NoError(t, err) {
+		data, err := io.ReadAll(content)
+		assert.NoError(t, err)
+		assert.Equal(t, "encrypted content", string(data))
+	}
+}
+
+func TestS3Store_GetNotFound(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockClient := mocks.NewMockS3API(ctrl)
+	store := S3Store{Client: mockClient, Bucket: "secrets"}
+
+	mockClient.EXPECT().GetObject(gomock.Any(), gomock.Any()).Return(nil, &types.NoSuchKey{})
+
+	_, err := store.Get(context.Background(), "missing")
+	assert.ErrorIs(t, err, domain.ErrBlobNotFound)
+}
+
+func TestS3Store_Unavailable(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockClient := mocks.NewMockS3API(ctrl)
+	store := S3Store{Client: mockClient, Bucket: "secrets"}
+
+	mockClient.EXPECT().PutObject(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, errors.New("connection refused"))
+	mockClient.EXPECT().GetObject(gomock.Any(), gomock.Any()).Return(nil, errors.New("connection refused"))
+	mockClient.EXPECT().DeleteObject(gomock.Any(), gomock.Any()).Return(nil, errors.New("connection refused"))
+
+	assert.ErrorIs(t, store.Put(context.Background(), "testkey", strings.NewReader("content"), 7), domain.ErrUnavailable)
+	_, err := store.Get(context.Background(), "testkey")
+	assert.ErrorIs(t, err, domain.ErrUnavailable)
+	assert.ErrorIs(t, store.Delete(context.Background(), "testkey"), domain.ErrUnavailable)
+}
@@ -1,7 +1,13 @@
 package handler
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/nalawade41/secret-server/internal/common/responses"
@@ -10,58 +16,134 @@ import (
 	"github.com/nalawade41/secret-server/internal/secret/response"
 )
 
+const (
+	// PassphraseHeader carries the passphrase when reading a passphrase protected secret
+	PassphraseHeader = "X-Secret-Passphrase"
+
+	// RevocationTokenHeader carries the revocation token handed to the creator of a secret
+	RevocationTokenHeader = "X-Revocation-Token"
+
+	// RemainingViewsHeader tells the reader of a file how many views are left, files are sent without the metadata
+	RemainingViewsHeader = "X-Remaining-Views"
+
+	// RateLimitLimitHeader, RateLimitRemainingHeader and RateLimitResetHeader report the most restrictive quota
+	// of the creator of a secret, the reset is in seconds
+	RateLimitLimitHeader     = "RateLimit-Limit"
+	RateLimitRemainingHeader = "RateLimit-Remaining"
+	RateLimitResetHeader     = "RateLimit-Reset"
+)
+
+var (
+	errHashRequired            = domain.NewError(domain.ErrValidation, "hash_required", "hash is required")
+	errRevocationTokenRequired = domain.NewError(domain.ErrValidation, "revocation_token_required", "revocation token is required")
+)
+
 type SecretManagerHandler struct {
 	SecretManager domain.SecretUseCase
+	// MaxSecretSize is the largest secret in bytes that is accepted
+	MaxSecretSize int
+	// MaxFileSize is the largest file in bytes that is accepted
+	MaxFileSize int
+	// Tenants are the policies of the configured tenants, the default tenant only has the sizes above
+	Tenants map[string]domain.TenantPolicy
+	// PublicBaseURL is where clients reach the server, without it links point to the Host of the request
+	PublicBaseURL string
 }
 
-func (h *SecretManagerHandler) InitRoutes(e *echo.Group) {
-	e.POST("/secret", h.AddSecret)
+// InitRoutes adds the secret routes to the group, createMiddleware only runs for creating secrets,
+// reading a secret only ever needs its link
+func (h *SecretManagerHandler) InitRoutes(e *echo.Group, createMiddleware ...echo.MiddlewareFunc) {
+	e.POST("/secret", h.AddSecret, createMiddleware...)
 	e.GET("/secret/:hash", h.GetSecretByHash)
+	e.DELETE("/secret/:hash", h.DeleteSecret)
+	e.GET("/secret/:hash/status", h.GetSecretStatus)
+	e.GET("/secrets", h.ListSecrets)
 }
 
 // AddSecret godoc
 //	@Summary		Add a new secret
-//	@Description	Add a new secret with expiration controls
+//	@Description	Add a new secret with expiration controls.
+//	@Description	With clientEncrypted the secret is an envelope encrypted by the client, the server stores it without decrypting it.
+//	@Description	A multipart/form-data body can send a file in the file field instead of the secret, it is encrypted like the secret.
 //	@Tags			secret
 //	@ID				addSecret
-//	@Accept			application/x-www-form-urlencoded
-//	@Produce		application/json, application/xml
+//	@Accept			application/json, application/x-www-form-urlencoded, multipart/form-data
+//	@Produce		application/json, application/xml, application/yaml
+//	@Security		APIKey
+//	@Security		BearerAuth
 //	@Param			secret	body		requests.CreateSecretRequest	true	"Create Secret Message"
-//	@Success		200		{object}	response.SecretResponse			"successful operation"
-//	@Failure		400		{object}	responses.Error					"Bad request"
-//	@Failure		405		{object}	responses.Error					"Invalid input"
+//	@Success		200		{object}	response.SecretResponse			"successful operation, the key is only returned once for secrets encrypted by the server"
+//	@Failure		400		{object}	responses.Problem					"Bad request, malformed_request or invalid_input, also for unknown fields and query parameters"
+//	@Failure		401		{object}	responses.Problem					"Caller missing or invalid when API keys are required, api_key_required, invalid_api_key or invalid_token"
+//	@Failure		403		{object}	responses.Problem					"Caller lacks the secrets:create scope or belongs to another tenant, insufficient_scope or tenant_mismatch"
+//	@Failure		406		{object}	responses.Problem					"None of the accepted media types is supported, not_acceptable"
+//	@Failure		409		{object}	responses.Problem					"No free id for the secret, secret_already_exists"
+//	@Failure		413		{object}	responses.Problem					"Request body or file too large, request_entity_too_large"
+//	@Failure		415		{object}	responses.Problem					"Content type not supported, unsupported_media_type"
+//	@Failure		429		{object}	responses.Problem					"Quota of the caller or the tenant exceeded, quota_exceeded"
+//	@Failure		503		{object}	responses.Problem					"Storage unavailable, service_unavailable"
+//	@Header			200,429	{integer}	RateLimit-Limit					"Limit of the most restrictive quota, only sent when a quota applies"
+//	@Header			200,429	{integer}	RateLimit-Remaining				"Secrets or bytes left in that quota"
+//	@Header			200,429	{integer}	RateLimit-Reset					"Seconds until that quota resets or frees up"
+//	@Header			429		{integer}	Retry-After						"Seconds until the exceeded quota resets or frees up"
 //	@Router			/api/v1/secret [post]
 func (h *SecretManagerHandler) AddSecret(c echo.Context) error {
 	ctx := c.Request().Context()
 	var err error
 
+	policy := h.policy(ctx)
+
 	request := new(requests.CreateSecretRequest)
-	if err := c.Bind(request); err != nil {
-		return responses.ErrorResponseWithMessage(c, http.StatusBadRequest, "Error parsing data")
+	defer request.Close()
+	if err := request.Bind(c.Request(), maxBodySize(policy.MaxSecretSize), int64(policy.MaxFileSize)); err != nil {
+		return err
 	}
 
-	if err := request.Validate(); err != nil {
-		return responses.ErrorResponseWithMessage(c, http.StatusBadRequest, "Invalid input")
+	// The size of the secret and the file is checked here, before they are encrypted
+	if err := request.Validate(policy); err != nil {
+		return err
+	}
+
+	// The link is only handed out once, a client that can not read the response must not create the secret
+	if _, err := responses.Negotiate(c, false); err != nil {
+		return err
 	}
 
 	var res domain.Secret
 	if res, err = h.SecretManager.CreateSecretMessage(ctx, request.ToDomain()); err != nil {
-		return responses.ErrorResponseWithMessage(c, http.StatusMethodNotAllowed, "Error creating secret message, Try Again!!!")
+		var exceeded *domain.QuotaExceededError
+		if errors.As(err, &exceeded) {
+			setQuotaHeaders(c, exceeded.Status)
+			c.Response().Header().Set(echo.HeaderRetryAfter, strconv.FormatInt(seconds(exceeded.Status.Reset), 10))
+		}
+		return err
 	}
+	setQuotaHeaders(c, res.Quota)
+
+	secretResponse := response.NewSecretResponse(res)
+	secretResponse.URL = secretURL(c, h.PublicBaseURL, res.Hash, res.Key)
 
-	return responses.Response(c, http.StatusOK, response.NewSecretResponse(res))
+	return responses.Response(c, http.StatusOK, secretResponse)
 }
 
 // GetSecretByHash godoc
 //	@Summary		Find a secret by hash
-//	@Description	Returns a single secret
+//	@Description	Returns a single secret, client encrypted secrets are returned as the stored envelope.
+//	@Description	A file is returned as an attachment with the type it was uploaded with, whatever the Accept header asks for.
 //	@ID				getSecretByHash
 //	@Tags			Secret
-//	@Produce		application/json, application/xml
+//	@Produce		application/json, application/xml, application/yaml, text/plain
 //	@Param			hash	path		string					true	"Unique hash to identify the secret"
-//	@Success		200		{object}	response.SecretResponse	"successful operation"
-//	@Failure		400		{object}	responses.Error			"Bad request, hash missing"
-//	@Failure		404		{object}	responses.Error			"Secret not found"
+//	@Param			key					query		string					false	"Decryption key from the secret link, required unless the secret is client encrypted"
+//	@Param			X-Secret-Passphrase	header		string					false	"Passphrase of a passphrase protected secret"
+//	@Success		200					{object}	response.SecretResponse	"successful operation"
+//	@Header			200					{integer}	X-Remaining-Views		"Views left after this one, only sent with files"
+//	@Failure		400					{object}	responses.Problem			"Bad request, hash_required or key_required"
+//	@Failure		401					{object}	responses.Problem			"Passphrase missing or wrong or bearer token invalid, passphrase_required, wrong_passphrase or invalid_token"
+//	@Failure		404					{object}	responses.Problem			"Secret not found, secret_not_found or invalid_key"
+//	@Failure		406					{object}	responses.Problem			"None of the accepted media types is supported, not_acceptable"
+//	@Failure		410					{object}	responses.Problem			"Secret gone, secret_expired, views_exhausted or secret_burned"
+//	@Failure		503					{object}	responses.Problem			"Storage unavailable, service_unavailable"
 //	@Router			/api/v1/secret/{hash} [get]
 func (h *SecretManagerHandler) GetSecretByHash(c echo.Context) error {
 	ctx := c.Request().Context()
@@ -69,13 +151,197 @@ func (h *SecretManagerHandler) GetSecretByHash(c echo.Context) error {
 
 	hash := c.Param("hash")
 	if hash == "" {
-		return responses.ErrorResponseWithMessage(c, http.StatusBadRequest, "Hash is required")
+		return errHashRequired
+	}
+
+	// Client encrypted secrets are read without a key, their key stays in the fragment of the link
+	key := c.QueryParam("key")
+
+	// The passphrase is sent as a header so it does not end up in access logs with the link
+	passphrase := c.Request().Header.Get(PassphraseHeader)
+
+	// Reading consumes a view, a client that can not read the response must not use it up
+	if _, err := responses.Negotiate(c, true); err != nil {
+		return err
 	}
 
 	var res domain.Secret
-	if res, err = h.SecretManager.GetSecretMessage(ctx, hash); err != nil {
-		return responses.ErrorResponseWithMessage(c, http.StatusNotFound, "Error getting secret message")
+	if res, err = h.SecretManager.GetSecretMessage(ctx, hash, key, passphrase); err != nil {
+		return err
+	}
+
+	// Files are sent as they were uploaded, the metadata they would lose is only the number of views left
+	if res.File != nil {
+		defer res.File.Content.Close()
+		c.Response().Header().Set(RemainingViewsHeader, strconv.Itoa(res.RemainingViews))
+		return responses.FileResponse(c, http.StatusOK, res.File.Name, res.File.ContentType, res.File.Content)
+	}
+
+	// Clients asking for plain text only get the secret itself
+	return responses.TextResponse(c, http.StatusOK, response.NewSecretResponse(res), res.SecretText)
+}
+
+// DeleteSecret godoc
+//	@Summary		Delete a secret
+//	@Description	Deletes a secret immediately, only the creator holding the revocation token can delete it.
+//	@Description	An authenticated owner of the secret can delete it without the token.
+//	@ID				deleteSecret
+//	@Tags			Secret
+//	@Produce		application/json, application/xml
+//	@Security		APIKey
+//	@Security		BearerAuth
+//	@Param			hash				path		string			true	"Unique hash to identify the secret"
+//	@Param			X-Revocation-Token	header		string			false	"Revocation token returned when the secret was created, required unless the caller owns the secret"
+//	@Success		204					"secret deleted"
+//	@Failure		400					{object}	responses.Problem	"Bad request, hash_required or revocation_token_required"
+//	@Failure		401					{object}	responses.Problem	"API key or bearer token invalid, invalid_api_key or invalid_token"
+//	@Failure		403					{object}	responses.Problem	"Invalid revocation token or not the owner, invalid_revocation_token"
+//	@Failure		404					{object}	responses.Problem	"Secret not found, secret_not_found"
+//	@Failure		503					{object}	responses.Problem	"Storage unavailable, service_unavailable"
+//	@Router			/api/v1/secret/{hash} [delete]
+func (h *SecretManagerHandler) DeleteSecret(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	hash := c.Param("hash")
+	if hash == "" {
+		return errHashRequired
+	}
+
+	// Owners are authorized by the use case, anyone else has to present the token
+	token := c.Request().Header.Get(RevocationTokenHeader)
+	if _, ok := domain.PrincipalFromContext(ctx); !ok && token == "" {
+		return errRevocationTokenRequired
+	}
+
+	if err := h.SecretManager.RevokeSecret(ctx, hash, token); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// GetSecretStatus godoc
+//	@Summary		Get the status of a secret
+//	@Description	Returns the metadata of a secret to its creator without consuming a view, the secret itself is never returned.
+//	@Description	An authenticated owner of the secret can read it without the token.
+//	@ID				getSecretStatus
+//	@Tags			Secret
+//	@Produce		application/json, application/xml, application/yaml
+//	@Security		APIKey
+//	@Security		BearerAuth
+//	@Param			hash				path		string							true	"Unique hash to identify the secret"
+//	@Param			X-Revocation-Token	header		string							false	"Revocation token returned when the secret was created, required unless the caller owns the secret"
+//	@Success		200					{object}	response.SecretStatusResponse	"successful operation"
+//	@Failure		400					{object}	responses.Problem					"Bad request, hash_required or revocation_token_required"
+//	@Failure		401					{object}	responses.Problem					"API key or bearer token invalid, invalid_api_key or invalid_token"
+//	@Failure		403					{object}	responses.Problem					"Invalid revocation token or not the owner, invalid_revocation_token"
+//	@Failure		404					{object}	responses.Problem					"Secret not found, secret_not_found"
+//	@Failure		406					{object}	responses.Problem					"None of the accepted media types is supported, not_acceptable"
+//	@Failure		503					{object}	responses.Problem					"Storage unavailable, service_unavailable"
+//	@Router			/api/v1/secret/{hash}/status [get]
+func (h *SecretManagerHandler) GetSecretStatus(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	hash := c.Param("hash")
+	if hash == "" {
+		return errHashRequired
+	}
+
+	// Owners are authorized by the use case, anyone else has to present the token
+	token := c.Request().Header.Get(RevocationTokenHeader)
+	if _, ok := domain.PrincipalFromContext(ctx); !ok && token == "" {
+		return errRevocationTokenRequired
+	}
+
+	status, err := h.SecretManager.GetSecretStatus(ctx, hash, token)
+	if err != nil {
+		return err
 	}
 
-	return responses.Response(c, http.StatusOK, response.NewSecretResponse(res))
+	return responses.Response(c, http.StatusOK, response.NewSecretStatusResponse(status))
+}
+
+// ListSecrets godoc
+//	@Summary		List own secrets
+//	@Description	Lists the metadata of the secrets created by the caller, newest first. The secrets themselves are never listed.
+//	@Description	Pass the nextCursor of a page as cursor to get the next page, the last page has no nextCursor.
+//	@Tags			Secret
+//	@ID				listSecrets
+//	@Produce		application/json, application/xml, application/yaml
+//	@Security		APIKey
+//	@Security		BearerAuth
+//	@Param			cursor	query		string						false	"Cursor of the page, the nextCursor of the previous page"
+//	@Param			limit	query		int							false	"Number of secrets on the page, 20 by default"	minimum(1)	maximum(100)
+//	@Success		200		{object}	response.SecretListResponse	"successful operation"
+//	@Failure		400		{object}	responses.Problem				"Bad request, invalid_input or invalid_cursor"
+//	@Failure		401		{object}	responses.Problem				"Caller missing or invalid, api_key_required, invalid_api_key or invalid_token"
+//	@Failure		406		{object}	responses.Problem				"None of the accepted media types is supported, not_acceptable"
+//	@Failure		503		{object}	responses.Problem				"Storage unavailable, service_unavailable"
+//	@Router			/api/v1/secrets [get]
+func (h *SecretManagerHandler) ListSecrets(c echo.Context) error {
+	request := new(requests.ListSecretsRequest)
+	if err := request.Bind(c.QueryParams()); err != nil {
+		return err
+	}
+
+	if err := request.Validate(); err != nil {
+		return err
+	}
+
+	statuses, next, err := h.SecretManager.ListOwnSecrets(c.Request().Context(), request.Cursor, request.Limit)
+	if err != nil {
+		return err
+	}
+
+	return responses.Response(c, http.StatusOK, response.NewSecretListResponse(statuses, next))
+}
+
+// policy returns the policy of the tenant of the request
+func (h *SecretManagerHandler) policy(ctx context.Context) domain.TenantPolicy {
+	if policy, ok := h.Tenants[domain.TenantFromContext(ctx)]; ok {
+		return policy
+	}
+	return domain.TenantPolicy{MaxSecretSize: h.MaxSecretSize, MaxFileSize: h.MaxFileSize}
+}
+
+// setQuotaHeaders reports the status of the quota, nothing is reported when no quota applies
+func setQuotaHeaders(c echo.Context, status domain.QuotaStatus) {
+	if status.Limit == 0 {
+		return
+	}
+
+	header := c.Response().Header()
+	header.Set(RateLimitLimitHeader, strconv.FormatInt(status.Limit, 10))
+	header.Set(RateLimitRemainingHeader, strconv.FormatInt(status.Remaining, 10))
+	header.Set(RateLimitResetHeader, strconv.FormatInt(seconds(status.Reset), 10))
+}
+
+// seconds rounds the duration up to whole seconds, a client waiting that long finds the quota reset
+func seconds(d time.Duration) int64 {
+	if d <= 0 {
+		return 0
+	}
+	return int64((d + time.Second - 1) / time.Second)
+}
+
+// maxBodySize leaves room for the other fields and for escaping the secret in JSON, the size of the secret
+// itself is validated after binding
+func maxBodySize(maxSecretSize int) int64 {
+	return 2*int64(maxSecretSize) + 16*1024
+}
+
+// secretURL builds the link handed to the creator, the key only ever lives in this link.
+// Client encrypted secrets have no key here, the client adds it as the fragment of the link.
+// The link keeps the path the secret was created on, so the secret of a tenant is read through its routes.
+// It points to the public base URL, the Host header is set by the client and only trusted by local servers.
+func secretURL(c echo.Context, baseURL string, hash string, key string) string {
+	if baseURL == "" {
+		baseURL = fmt.Sprintf("%s://%s", c.Scheme(), c.Request().Host)
+	}
+
+	link := fmt.Sprintf("%s%s/%s", baseURL, c.Request().URL.EscapedPath(), url.PathEscape(hash))
+	if key == "" {
+		return link
+	}
+	return link + "?key=" + url.QueryEscape(key)
 }
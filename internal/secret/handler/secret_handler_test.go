@@ -2,28 +2,42 @@ package handler
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/labstack/echo/v4"
+	"github.com/nalawade41/secret-server/internal/common/security"
 	"github.com/nalawade41/secret-server/internal/domain"
 	"github.com/nalawade41/secret-server/internal/secret/response"
 	"github.com/nalawade41/secret-server/mocks"
 	"github.com/stretchr/testify/assert"
 )
 
+const (
+	// testMaxSecretSize is the largest secret accepted by the tests
+	testMaxSecretSize = 1024
+
+	// testMaxFileSize is the largest file accepted by the tests
+	testMaxFileSize = 4096
+)
+
 func TestAddSecret_Success(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
 	mockUseCase := mocks.NewMockSecretUseCase(ctrl)
 
-	handler := SecretManagerHandler{SecretManager: mockUseCase}
+	handler := SecretManagerHandler{SecretManager: mockUseCase, MaxSecretSize: testMaxSecretSize, MaxFileSize: testMaxFileSize}
 
 	e := echo.New()
 	req := httptest.NewRequest(http.MethodPost, "/api/v1/secret", bytes.NewBufferString(`{"secret":"This is a test secret","expireAfter":10,"expireAfterViews":5}`))
@@ -33,6 +47,7 @@ func TestAddSecret_Success(t *testing.T) {
 
 	expectedSecret := domain.Secret{
 		Hash:           "testhash",
+		Key:            "testkey",
 		SecretText:     "This is a test secret",
 		ExpiresAt:      time.Now().Add(10 * time.Minute),
 		RemainingViews: 5,
@@ -50,6 +65,274 @@ func TestAddSecret_Success(t *testing.T) {
 		assert.NoError(t, err)
 		assert.Equal(t, expectedSecret.Hash, secretResponse.Hash)
 		assert.Equal(t, expectedSecret.SecretText, secretResponse.SecretText)
+		assert.Equal(t, expectedSecret.Key, secretResponse.Key)
+		assert.Equal(t, "http://example.com/api/v1/secret/testhash?key=testkey", secretResponse.URL)
+	}
+}
+
+func TestAddSecret_PublicBaseURL(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUseCase := mocks.NewMockSecretUseCase(ctrl)
+
+	handler := SecretManagerHandler{
+		SecretManager: mockUseCase,
+		MaxSecretSize: testMaxSecretSize,
+		MaxFileSize:   testMaxFileSize,
+		PublicBaseURL: "https://secrets.example.com/prod",
+	}
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/secret", bytes.NewBufferString(`{"secret":"This is a test secret","expireAfter":10,"expireAfterViews":5}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	// A spoofed Host must not redirect the link and its key to another host
+	req.Host = "attacker.example.net"
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	mockUseCase.EXPECT().CreateSecretMessage(gomock.Any(), gomock.Any()).Return(domain.Secret{Hash: "testhash", Key: "testkey", RemainingViews: 5}, nil)
+
+	if assert.NoError(t, handler.AddSecret(c)) {
+		var secretResponse response.SecretResponse
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &secretResponse))
+		assert.Equal(t, "https://secrets.example.com/prod/api/v1/secret/testhash?key=testkey", secretResponse.URL)
+	}
+}
+
+func TestAddSecret_Tenant(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUseCase := mocks.NewMockSecretUseCase(ctrl)
+
+	handler := SecretManagerHandler{
+		SecretManager: mockUseCase,
+		MaxSecretSize: testMaxSecretSize,
+		MaxFileSize:   testMaxFileSize,
+		Tenants:       map[string]domain.TenantPolicy{"team-a": {MaxTTL: time.Hour, MaxViews: 1, MaxSecretSize: 64, MaxFileSize: 64}},
+	}
+
+	addSecret := func(body string) (*httptest.ResponseRecorder, error) {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/tenants/team-a/secret", bytes.NewBufferString(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req = req.WithContext(domain.ContextWithTenant(req.Context(), "team-a"))
+		rec := httptest.NewRecorder()
+		return rec, handler.AddSecret(echo.New().NewContext(req, rec))
+	}
+
+	// The policy of the tenant is stricter than the limits of the deployment
+	_, err := addSecret(`{"secret":"This is a test secret","expireAfter":120,"expireAfterViews":5}`)
+	assert.ErrorIs(t, err, domain.ErrValidation)
+
+	mockUseCase.EXPECT().CreateSecretMessage(gomock.Any(), gomock.Any()).Return(domain.Secret{Hash: "testhash", Key: "testkey"}, nil)
+
+	rec, err := addSecret(`{"secret":"This is a test secret","expireAfter":60,"expireAfterViews":1}`)
+	if assert.NoError(t, err) {
+		var secretResponse response.SecretResponse
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &secretResponse))
+		assert.Equal(t, "http://example.com/api/v1/tenants/team-a/secret/testhash?key=testkey", secretResponse.URL, "The link resolves through the routes of the tenant")
+	}
+}
+
+func TestAddSecret_Quota(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUseCase := mocks.NewMockSecretUseCase(ctrl)
+
+	handler := SecretManagerHandler{SecretManager: mockUseCase, MaxSecretSize: testMaxSecretSize, MaxFileSize: testMaxFileSize}
+
+	addSecret := func() (*httptest.ResponseRecorder, error) {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/secret", bytes.NewBufferString(`{"secret":"This is a test secret","expireAfterViews":1}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		return rec, handler.AddSecret(echo.New().NewContext(req, rec))
+	}
+
+	// The most restrictive quota is reported, the reset is rounded up to whole seconds
+	quota := domain.QuotaStatus{Limit: 100, Remaining: 42, Reset: 90*time.Second + time.Millisecond}
+	mockUseCase.EXPECT().CreateSecretMessage(gomock.Any(), gomock.Any()).Return(domain.Secret{Hash: "testhash", Key: "testkey", Quota: quota}, nil)
+
+	rec, err := addSecret()
+	if assert.NoError(t, err) {
+		assert.Equal(t, "100", rec.Header().Get(RateLimitLimitHeader))
+		assert.Equal(t, "42", rec.Header().Get(RateLimitRemainingHeader))
+		assert.Equal(t, "91", rec.Header().Get(RateLimitResetHeader))
+		assert.Empty(t, rec.Header().Get(echo.HeaderRetryAfter))
+	}
+
+	// Test case: The quota is exceeded, the client is told when to retry
+	exceeded := &domain.QuotaExceededError{Status: domain.QuotaStatus{Limit: 100, Reset: time.Hour}}
+	mockUseCase.EXPECT().CreateSecretMessage(gomock.Any(), gomock.Any()).Return(domain.Secret{}, fmt.Errorf("failed to reserve quota: %w", exceeded))
+
+	rec, err = addSecret()
+	assert.ErrorIs(t, err, domain.ErrQuotaExceeded)
+	assert.Equal(t, "0", rec.Header().Get(RateLimitRemainingHeader))
+	assert.Equal(t, "3600", rec.Header().Get(RateLimitResetHeader))
+	assert.Equal(t, "3600", rec.Header().Get(echo.HeaderRetryAfter))
+
+	// Test case: No quota applies, nothing is reported
+	mockUseCase.EXPECT().CreateSecretMessage(gomock.Any(), gomock.Any()).Return(domain.Secret{Hash: "testhash", Key: "testkey"}, nil)
+
+	rec, err = addSecret()
+	if assert.NoError(t, err) {
+		assert.Empty(t, rec.Header().Get(RateLimitLimitHeader))
+		assert.Empty(t, rec.Header().Get(RateLimitRemainingHeader))
+		assert.Empty(t, rec.Header().Get(RateLimitResetHeader))
+	}
+}
+
+func TestAddSecret_ClientEncrypted(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUseCase := mocks.NewMockSecretUseCase(ctrl)
+
+	handler := SecretManagerHandler{SecretManager: mockUseCase, MaxSecretSize: testMaxSecretSize, MaxFileSize: testMaxFileSize}
+
+	env, err := security.SealEnvelope(make([]byte, 32), "", []byte("This is a test secret"))
+	assert.NoError(t, err)
+
+	e := echo.New()
+	body := fmt.Sprintf(`{"secret":%q,"expireAfterViews":1,"clientEncrypted":true,"algorithm":"AES-256-GCM"}`, env.Encode())
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/secret", bytes.NewBufferString(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	mockUseCase.EXPECT().CreateSecretMessage(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, secret domain.Secret) (domain.Secret, error) {
+		assert.True(t, secret.ClientEncrypted)
+		assert.Equal(t, env.Encode(), secret.SecretText)
+		secret.Hash = "testhash"
+		return secret, nil
+	})
+
+	if assert.NoError(t, handler.AddSecret(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var secretResponse response.SecretResponse
+		err := json.Unmarshal(rec.Body.Bytes(), &secretResponse)
+		assert.NoError(t, err)
+		assert.Empty(t, secretResponse.Key)
+		assert.True(t, secretResponse.ClientEncrypted)
+		// The client adds the key as the fragment of the link
+		assert.Equal(t, "http://example.com/api/v1/secret/testhash", secretResponse.URL)
+	}
+}
+
+func TestAddSecret_Form(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUseCase := mocks.NewMockSecretUseCase(ctrl)
+
+	handler := SecretManagerHandler{SecretManager: mockUseCase, MaxSecretSize: testMaxSecretSize, MaxFileSize: testMaxFileSize}
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/secret", bytes.NewBufferString("secret=This+is+a+test+secret&expireAfterViews=5"))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	mockUseCase.EXPECT().CreateSecretMessage(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, secret domain.Secret) (domain.Secret, error) {
+		assert.Equal(t, "This is a test secret", secret.SecretText)
+		assert.Equal(t, 5, secret.RemainingViews)
+		secret.Hash = "testhash"
+		return secret, nil
+	})
+
+	if assert.NoError(t, handler.AddSecret(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+}
+
+func TestAddSecret_File(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUseCase := mocks.NewMockSecretUseCase(ctrl)
+
+	handler := SecretManagerHandler{SecretManager: mockUseCase, MaxSecretSize: testMaxSecretSize, MaxFileSize: testMaxFileSize}
+
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+	assert.NoError(t, writer.WriteField("expireAfterViews", "1"))
+	part, err := writer.CreateFormFile("file", "report.pdf")
+	assert.NoError(t, err)
+	_, err = part.Write([]byte("%PDF"))
+	assert.NoError(t, err)
+	assert.NoError(t, writer.Close())
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/secret", body)
+	req.Header.Set(echo.HeaderContentType, writer.FormDataContentType())
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	mockUseCase.EXPECT().CreateSecretMessage(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, secret domain.Secret) (domain.Secret, error) {
+		if assert.NotNil(t, secret.File) {
+			assert.Equal(t, "report.pdf", secret.File.Name)
+			assert.Equal(t, "application/pdf", secret.File.ContentType)
+			assert.Equal(t, int64(4), secret.File.Size)
+			content, err := io.ReadAll(secret.File.Content)
+			assert.NoError(t, err)
+			assert.Equal(t, "%PDF", string(content))
+		}
+		assert.Empty(t, secret.SecretText)
+		secret.Hash = "testhash"
+		return secret, nil
+	})
+
+	if assert.NoError(t, handler.AddSecret(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+}
+
+func TestAddSecret_SecretTooLarge(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUseCase := mocks.NewMockSecretUseCase(ctrl)
+
+	handler := SecretManagerHandler{SecretManager: mockUseCase, MaxSecretSize: testMaxSecretSize, MaxFileSize: testMaxFileSize}
+
+	e := echo.New()
+	body := fmt.Sprintf(`{"secret":%q,"expireAfterViews":1}`, strings.Repeat("a", testMaxSecretSize+1))
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/secret", bytes.NewBufferString(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	// The secret is rejected before it reaches the use case and gets encrypted
+	err := handler.AddSecret(c)
+
+	var validationErr *domain.Error
+	if assert.ErrorAs(t, err, &validationErr) {
+		assert.Equal(t, []domain.FieldError{{Field: "secret", Message: "secret is larger than 1024 bytes"}}, validationErr.Fields)
+	}
+}
+
+func TestAddSecret_BodyTooLarge(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUseCase := mocks.NewMockSecretUseCase(ctrl)
+
+	handler := SecretManagerHandler{SecretManager: mockUseCase, MaxSecretSize: testMaxSecretSize, MaxFileSize: testMaxFileSize}
+
+	e := echo.New()
+	body := fmt.Sprintf(`{"secret":%q}`, strings.Repeat("a", int(maxBodySize(testMaxSecretSize))))
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/secret", bytes.NewBufferString(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	err := handler.AddSecret(c)
+
+	var httpErr *echo.HTTPError
+	if assert.ErrorAs(t, err, &httpErr) {
+		assert.Equal(t, http.StatusRequestEntityTooLarge, httpErr.Code)
 	}
 }
 
@@ -59,7 +342,7 @@ func TestAddSecret_BindError(t *testing.T) {
 
 	mockUseCase := mocks.NewMockSecretUseCase(ctrl)
 
-	handler := SecretManagerHandler{SecretManager: mockUseCase}
+	handler := SecretManagerHandler{SecretManager: mockUseCase, MaxSecretSize: testMaxSecretSize, MaxFileSize: testMaxFileSize}
 
 	e := echo.New()
 	// Set content type to application/json to trigger a bind error with malformed JSON
@@ -70,11 +353,9 @@ func TestAddSecret_BindError(t *testing.T) {
 
 	err := handler.AddSecret(c)
 
-	// Check the response code and error message
-	if assert.NoError(t, err) {
-		assert.Equal(t, http.StatusBadRequest, rec.Code)
-		assert.Contains(t, rec.Body.String(), "Error parsing data")
-	}
+	// The error is written by the error handler of the router
+	assert.ErrorIs(t, err, domain.ErrValidation)
+	assert.Contains(t, err.Error(), "error parsing data")
 }
 
 func TestAddSecret_ValidationError(t *testing.T) {
@@ -83,7 +364,7 @@ func TestAddSecret_ValidationError(t *testing.T) {
 
 	mockUseCase := mocks.NewMockSecretUseCase(ctrl)
 
-	handler := SecretManagerHandler{SecretManager: mockUseCase}
+	handler := SecretManagerHandler{SecretManager: mockUseCase, MaxSecretSize: testMaxSecretSize, MaxFileSize: testMaxFileSize}
 
 	e := echo.New()
 	// Use application/json for JSON payload
@@ -94,11 +375,7 @@ func TestAddSecret_ValidationError(t *testing.T) {
 
 	err := handler.AddSecret(c)
 
-	// Echo does not return errors from the handler directly, it writes them to the response
-	if assert.NoError(t, err) {
-		assert.Equal(t, http.StatusBadRequest, rec.Code)
-		assert.Contains(t, rec.Body.String(), "Invalid input")
-	}
+	assert.ErrorIs(t, err, domain.ErrValidation)
 }
 
 func TestAddSecret_RepoError(t *testing.T) {
@@ -107,7 +384,7 @@ func TestAddSecret_RepoError(t *testing.T) {
 
 	mockUseCase := mocks.NewMockSecretUseCase(ctrl)
 
-	handler := SecretManagerHandler{SecretManager: mockUseCase}
+	handler := SecretManagerHandler{SecretManager: mockUseCase, MaxSecretSize: testMaxSecretSize, MaxFileSize: testMaxFileSize}
 
 	e := echo.New()
 	// Use application/json for JSON payload
@@ -117,15 +394,12 @@ func TestAddSecret_RepoError(t *testing.T) {
 	c := e.NewContext(req, rec)
 
 	// Set up the mock expectation for a repository error
-	mockUseCase.EXPECT().CreateSecretMessage(gomock.Any(), gomock.Any()).Return(domain.Secret{}, errors.New("repository error"))
+	mockUseCase.EXPECT().CreateSecretMessage(gomock.Any(), gomock.Any()).Return(domain.Secret{}, domain.Unavailable(errors.New("repository error")))
 
 	err := handler.AddSecret(c)
 
-	// Echo does not return errors from the handler directly, it writes them to the response
-	if assert.NoError(t, err) {
-		assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
-		assert.Contains(t, rec.Body.String(), "Error creating secret message")
-	}
+	// Storage errors are handed to the error handler of the router unchanged
+	assert.ErrorIs(t, err, domain.ErrUnavailable)
 }
 
 func TestGetSecretByHash_Success(t *testing.T) {
@@ -137,7 +411,7 @@ func TestGetSecretByHash_Success(t *testing.T) {
 	handler := SecretManagerHandler{SecretManager: mockUseCase}
 
 	e := echo.New()
-	req := httptest.NewRequest(http.MethodGet, "/api/v1/secret/testhash", nil)
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/secret/testhash?key=testkey", nil)
 	rec := httptest.NewRecorder()
 	c := e.NewContext(req, rec)
 	c.SetParamNames("hash")
@@ -151,7 +425,7 @@ func TestGetSecretByHash_Success(t *testing.T) {
 		CreatedAt:      time.Now().UTC(),
 	}
 
-	mockUseCase.EXPECT().GetSecretMessage(gomock.Any(), "testhash").Return(expectedSecret, nil)
+	mockUseCase.EXPECT().GetSecretMessage(gomock.Any(), "testhash", "testkey", "").Return(expectedSecret, nil)
 
 	if assert.NoError(t, handler.GetSecretByHash(c)) {
 		assert.Equal(t, http.StatusOK, rec.Code)
@@ -164,6 +438,99 @@ func TestGetSecretByHash_Success(t *testing.T) {
 	}
 }
 
+func TestGetSecretByHash_PlainText(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUseCase := mocks.NewMockSecretUseCase(ctrl)
+
+	handler := SecretManagerHandler{SecretManager: mockUseCase}
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/secret/testhash?key=testkey", nil)
+	req.Header.Set(echo.HeaderAccept, "text/plain")
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("hash")
+	c.SetParamValues("testhash")
+
+	mockUseCase.EXPECT().GetSecretMessage(gomock.Any(), "testhash", "testkey", "").Return(domain.Secret{Hash: "testhash", SecretText: "This is a test secret"}, nil)
+
+	// Plain text is the secret alone, without the metadata
+	if assert.NoError(t, handler.GetSecretByHash(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, echo.MIMETextPlainCharsetUTF8, rec.Header().Get(echo.HeaderContentType))
+		assert.Equal(t, "This is a test secret", rec.Body.String())
+	}
+}
+
+func TestGetSecretByHash_File(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUseCase := mocks.NewMockSecretUseCase(ctrl)
+
+	handler := SecretManagerHandler{SecretManager: mockUseCase}
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/secret/testhash?key=testkey", nil)
+	req.Header.Set(echo.HeaderAccept, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("hash")
+	c.SetParamValues("testhash")
+
+	content := &closeRecorder{Reader: strings.NewReader("%PDF")}
+	file := &domain.File{Name: "report.pdf", ContentType: "application/pdf", Size: 4, Content: content}
+	mockUseCase.EXPECT().GetSecretMessage(gomock.Any(), "testhash", "testkey", "").Return(domain.Secret{Hash: "testhash", RemainingViews: 2, File: file}, nil)
+
+	// Files are sent as uploaded even when JSON is asked for
+	if assert.NoError(t, handler.GetSecretByHash(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "application/pdf", rec.Header().Get(echo.HeaderContentType))
+		assert.Equal(t, "attachment; filename=report.pdf", rec.Header().Get(echo.HeaderContentDisposition))
+		assert.Equal(t, "2", rec.Header().Get(RemainingViewsHeader))
+		assert.Equal(t, "%PDF", rec.Body.String())
+	}
+	assert.True(t, content.closed, "the content should be closed once it was sent")
+}
+
+// closeRecorder records whether the content was closed
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+func (r *closeRecorder) Close() error {
+	r.closed = true
+	return nil
+}
+
+func TestGetSecretByHash_NotAcceptable(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUseCase := mocks.NewMockSecretUseCase(ctrl)
+
+	handler := SecretManagerHandler{SecretManager: mockUseCase}
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/secret/testhash?key=testkey", nil)
+	req.Header.Set(echo.HeaderAccept, "text/html")
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("hash")
+	c.SetParamValues("testhash")
+
+	// The use case is not called, the view is not consumed for a response the client can not read
+	err := handler.GetSecretByHash(c)
+
+	var httpErr *echo.HTTPError
+	if assert.ErrorAs(t, err, &httpErr) {
+		assert.Equal(t, http.StatusNotAcceptable, httpErr.Code)
+	}
+}
+
 func TestGetSecretByHash_NotFound(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -173,20 +540,57 @@ func TestGetSecretByHash_NotFound(t *testing.T) {
 	handler := SecretManagerHandler{SecretManager: mockUseCase}
 
 	e := echo.New()
-	req := httptest.NewRequest(http.MethodGet, "/api/v1/secret/nonexistenthash", nil)
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/secret/nonexistenthash?key=testkey", nil)
 	rec := httptest.NewRecorder()
 	c := e.NewContext(req, rec)
 	c.SetParamNames("hash")
 	c.SetParamValues("nonexistenthash")
 
 	// Set up the expectation for GetSecretMessage to return an error indicating the secret was not found
-	mockUseCase.EXPECT().GetSecretMessage(gomock.Any(), "nonexistenthash").Return(domain.Secret{}, errors.New("secret not found"))
+	mockUseCase.EXPECT().GetSecretMessage(gomock.Any(), "nonexistenthash", "testkey", "").Return(domain.Secret{}, domain.ErrSecretNotFound)
 
 	// Call the handler
-	if assert.NoError(t, handler.GetSecretByHash(c)) {
-		// Check that the status code and response body contain the expected error message
-		assert.Equal(t, http.StatusNotFound, rec.Code)
-		assert.Contains(t, rec.Body.String(), "Error getting secret message")
+	err := handler.GetSecretByHash(c)
+
+	assert.ErrorIs(t, err, domain.ErrSecretNotFound)
+	assert.Empty(t, rec.Body.String())
+}
+
+func TestGetSecretByHash_Passphrase(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected error
+	}{
+		{name: "required", err: domain.ErrPassphraseRequired, expected: domain.ErrPassphraseRequired},
+		{name: "wrong", err: fmt.Errorf("4 attempts left: %w", domain.ErrWrongPassphrase), expected: domain.ErrWrongPassphrase},
+		{name: "burned", err: domain.ErrSecretBurned, expected: domain.ErrSecretBurned},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockUseCase := mocks.NewMockSecretUseCase(ctrl)
+
+			handler := SecretManagerHandler{SecretManager: mockUseCase}
+
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/secret/testhash?key=testkey", nil)
+			req.Header.Set(PassphraseHeader, "guess")
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetParamNames("hash")
+			c.SetParamValues("testhash")
+
+			// The passphrase is taken from the header
+			mockUseCase.EXPECT().GetSecretMessage(gomock.Any(), "testhash", "testkey", "guess").Return(domain.Secret{}, tt.err)
+
+			err := handler.GetSecretByHash(c)
+
+			assert.ErrorIs(t, err, tt.expected)
+		})
 	}
 }
 
@@ -208,9 +612,182 @@ func TestGetSecretByHash_MissingHash(t *testing.T) {
 	c.SetParamValues("") // Simulating missing hash by setting an empty string
 
 	// Call the handler
-	if assert.NoError(t, handler.GetSecretByHash(c)) {
-		// Verify that the status code is 400 and the response contains the expected error message
-		assert.Equal(t, http.StatusBadRequest, rec.Code)
-		assert.Contains(t, rec.Body.String(), "Hash is required")
+	err := handler.GetSecretByHash(c)
+
+	assert.ErrorIs(t, err, errHashRequired)
+	assert.ErrorIs(t, err, domain.ErrValidation)
+}
+
+func TestGetSecretByHash_MissingKey(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUseCase := mocks.NewMockSecretUseCase(ctrl)
+
+	handler := SecretManagerHandler{SecretManager: mockUseCase}
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/secret/testhash", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("hash")
+	c.SetParamValues("testhash")
+
+	// Only client encrypted secrets can be read without a key
+	mockUseCase.EXPECT().GetSecretMessage(gomock.Any(), "testhash", "", "").Return(domain.Secret{}, domain.ErrKeyRequired)
+
+	// Without the key from the link the secret can not be decrypted
+	err := handler.GetSecretByHash(c)
+
+	assert.ErrorIs(t, err, domain.ErrKeyRequired)
+}
+
+func TestDeleteSecret(t *testing.T) {
+	tests := []struct {
+		name  string
+		token string
+		// owner is the subject of the principal of the request, owners need no token
+		owner    string
+		err      error
+		expected error
+	}{
+		{name: "deleted", token: "testtoken"},
+		{name: "deleted by owner", owner: "alice"},
+		{name: "missing token", expected: errRevocationTokenRequired},
+		{name: "invalid token", token: "testtoken", err: domain.ErrInvalidRevocationToken, expected: domain.ErrInvalidRevocationToken},
+		{name: "not found", token: "testtoken", err: fmt.Errorf("failed to retrieve secret: %w", domain.ErrSecretNotFound), expected: domain.ErrSecretNotFound},
+		{name: "repository error", token: "testtoken", err: domain.Unavailable(errors.New("delete error")), expected: domain.ErrUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockUseCase := mocks.NewMockSecretUseCase(ctrl)
+
+			handler := SecretManagerHandler{SecretManager: mockUseCase}
+
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodDelete, "/api/v1/secret/testhash", nil)
+			if tt.token != "" {
+				req.Header.Set(RevocationTokenHeader, tt.token)
+			}
+			if tt.owner != "" {
+				req = req.WithContext(domain.ContextWithPrincipal(req.Context(), domain.Principal{Subject: tt.owner}))
+			}
+			if tt.token != "" || tt.owner != "" {
+				mockUseCase.EXPECT().RevokeSecret(gomock.Any(), "testhash", tt.token).Return(tt.err)
+			}
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetParamNames("hash")
+			c.SetParamValues("testhash")
+
+			err := handler.DeleteSecret(c)
+			if tt.expected != nil {
+				assert.ErrorIs(t, err, tt.expected)
+				return
+			}
+
+			if assert.NoError(t, err) {
+				assert.Equal(t, http.StatusNoContent, rec.Code)
+			}
+		})
+	}
+}
+
+func TestGetSecretStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		token    string
+		err      error
+		expected error
+	}{
+		{name: "status", token: "testtoken"},
+		{name: "missing token", expected: errRevocationTokenRequired},
+		{name: "invalid token", token: "testtoken", err: domain.ErrInvalidRevocationToken, expected: domain.ErrInvalidRevocationToken},
+		{name: "not found", token: "testtoken", err: domain.ErrSecretNotFound, expected: domain.ErrSecretNotFound},
+		{name: "repository error", token: "testtoken", err: domain.Unavailable(errors.New("get error")), expected: domain.ErrUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockUseCase := mocks.NewMockSecretUseCase(ctrl)
+
+			handler := SecretManagerHandler{SecretManager: mockUseCase}
+
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/secret/testhash/status", nil)
+			if tt.token != "" {
+				req.Header.Set(RevocationTokenHeader, tt.token)
+				status := domain.SecretStatus{Hash: "testhash", Consumed: true, ReadAt: []time.Time{time.Now().UTC()}}
+				mockUseCase.EXPECT().GetSecretStatus(gomock.Any(), "testhash", tt.token).Return(status, tt.err)
+			}
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetParamNames("hash")
+			c.SetParamValues("testhash")
+
+			err := handler.GetSecretStatus(c)
+			if tt.expected != nil {
+				assert.ErrorIs(t, err, tt.expected)
+				return
+			}
+
+			if assert.NoError(t, err) {
+				assert.Equal(t, http.StatusOK, rec.Code)
+				assert.Contains(t, rec.Body.String(), `"consumed":true`)
+				assert.NotContains(t, rec.Body.String(), "secretText")
+			}
+		})
+	}
+}
+
+func TestListSecrets(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUseCase := mocks.NewMockSecretUseCase(ctrl)
+	handler := SecretManagerHandler{SecretManager: mockUseCase}
+
+	createdAt := time.Now().UTC().Truncate(time.Second)
+	mockUseCase.EXPECT().ListOwnSecrets(gomock.Any(), "cursor", 2).Return([]domain.SecretStatus{
+		{Hash: "newer", CreatedAt: createdAt, RemainingViews: 1},
+		{Hash: "older", CreatedAt: createdAt.Add(-time.Hour), Consumed: true},
+	}, "next", nil)
+
+	e := echo.New()
+	rec := httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest(http.MethodGet, "/api/v1/secrets?cursor=cursor&limit=2", nil), rec)
+
+	if assert.NoError(t, handler.ListSecrets(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var list response.SecretListResponse
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+		assert.Equal(t, "next", list.NextCursor)
+		if assert.Len(t, list.Secrets, 2) {
+			assert.Equal(t, "newer", list.Secrets[0].Hash)
+			assert.True(t, list.Secrets[1].Consumed)
+		}
+		assert.NotContains(t, rec.Body.String(), "secretText", "Secrets are never listed")
+	}
+}
+
+func TestListSecrets_InvalidRequest(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// The use case is never called for an invalid request
+	handler := SecretManagerHandler{SecretManager: mocks.NewMockSecretUseCase(ctrl)}
+
+	e := echo.New()
+	for _, query := range []string{"limit=0", "limit=101", "limit=ten", "owner=bob"} {
+		c := e.NewContext(httptest.NewRequest(http.MethodGet, "/api/v1/secrets?"+query, nil), httptest.NewRecorder())
+		assert.ErrorIs(t, handler.ListSecrets(c), domain.ErrValidation, query)
 	}
 }
@@ -1,6 +1,7 @@
 package secret
 
 import (
+	"github.com/nalawade41/secret-server/config"
 	"github.com/nalawade41/secret-server/db"
 	"github.com/nalawade41/secret-server/internal/common/security"
 	"sync"
@@ -10,6 +11,8 @@ import (
 	"github.com/nalawade41/secret-server/internal/domain"
 	"github.com/nalawade41/secret-server/internal/secret/handler"
 	"github.com/nalawade41/secret-server/internal/secret/repository/dynamo"
+	"github.com/nalawade41/secret-server/internal/secret/repository/memory"
+	"github.com/nalawade41/secret-server/internal/secret/repository/sqldb"
 	"github.com/nalawade41/secret-server/internal/secret/usecase"
 )
 
@@ -20,59 +23,123 @@ var (
 	secretUseCase *usecase.SecretManagerUseCase
 	ucOnce        sync.Once
 
-	repo     *dynamo.SecretManagerRepository
+	keyRotationUseCase *usecase.KeyRotationUseCase
+	keyRotationOnce    sync.Once
+
+	repo     domain.SecretRepository
 	repoOnce sync.Once
 
 	encryptor   *security.RealEncryptor
 	encryptOnce sync.Once
 
+	idGenerator *security.IDGenerator
+	idOnce      sync.Once
+
 	ManagerProviderSet wire.ProviderSet = wire.NewSet(
 		NewSecretManagerHandler,
 		NewSecretManagerUseCase,
+		NewKeyRotationUseCase,
 		NewSecretManagerRepository,
+		NewBlobStore,
 		NewEncryptor,
+		NewIDGenerator,
 
 		wire.Bind(new(domain.SecretUseCase), new(*usecase.SecretManagerUseCase)),
-		wire.Bind(new(domain.SecretRepository), new(*dynamo.SecretManagerRepository)),
+		wire.Bind(new(domain.KeyRotationUseCase), new(*usecase.KeyRotationUseCase)),
 		wire.Bind(new(domain.Encryptor), new(*security.RealEncryptor)),
+		wire.Bind(new(domain.IDGenerator), new(*security.IDGenerator)),
 	)
 )
 
-func NewEncryptor() *security.RealEncryptor {
+// NewEncryptor creates the encryptor that wraps the data keys with the key provider
+func NewEncryptor(keyProvider security.KeyProvider) *security.RealEncryptor {
 	encryptOnce.Do(func() {
-		encryptor = &security.RealEncryptor{}
+		encryptor = &security.RealEncryptor{
+			KeyProvider: keyProvider,
+		}
 	})
 	return encryptor
 }
 
-func NewSecretManagerUseCase(repo domain.SecretRepository, encryptor domain.Encryptor) *usecase.SecretManagerUseCase {
+// NewIDGenerator creates the generator for secret ids from the secret config
+func NewIDGenerator(cfg *config.SecretConfig) *security.IDGenerator {
+	idOnce.Do(func() {
+		idGenerator = &security.IDGenerator{
+			Length:   cfg.IDLength,
+			Encoding: cfg.IDEncoding,
+		}
+	})
+	return idGenerator
+}
+
+func NewSecretManagerUseCase(repo domain.SecretRepository, encryptor domain.Encryptor, idGenerator domain.IDGenerator, blobStore domain.BlobStore, quotas domain.QuotaUseCase, cfg *config.SecretConfig) *usecase.SecretManagerUseCase {
 	ucOnce.Do(func() {
 		secretUseCase = &usecase.SecretManagerUseCase{
+			SecretRepo:  repo,
+			Encryptor:   encryptor,
+			IDGenerator: idGenerator,
+			BlobStore:   blobStore,
+			Quotas:      quotas,
+
+			MaxPassphraseAttempts: cfg.MaxPassphraseAttempts,
+		}
+	})
+	return secretUseCase
+}
+
+// NewKeyRotationUseCase creates the use case that re-wraps data keys under the active master key
+func NewKeyRotationUseCase(repo domain.SecretRepository, encryptor domain.Encryptor) *usecase.KeyRotationUseCase {
+	keyRotationOnce.Do(func() {
+		keyRotationUseCase = &usecase.KeyRotationUseCase{
 			SecretRepo: repo,
 			Encryptor:  encryptor,
 		}
 	})
-	return secretUseCase
+	return keyRotationUseCase
 }
 
-func NewSecretManagerHandler(rs domain.SecretUseCase) *handler.SecretManagerHandler {
+func NewSecretManagerHandler(rs domain.SecretUseCase, cfg *config.SecretConfig) *handler.SecretManagerHandler {
 	hdlOnce.Do(func() {
 		secretHandler = &handler.SecretManagerHandler{
 			SecretManager: rs,
+			MaxSecretSize: cfg.MaxSecretSize,
+			MaxFileSize:   cfg.MaxFileSize,
+			Tenants:       cfg.Tenants,
+			PublicBaseURL: cfg.PublicBaseURL,
 		}
 	})
 	return secretHandler
 }
 
-// NewSecretManagerRepository creates new secret repository
-func NewSecretManagerRepository(db db.DynamoDBAPI, tableName string) *dynamo.SecretManagerRepository {
+// NewSecretManagerRepository creates the secret repository of the storage backend selected at startup
+func NewSecretManagerRepository(storage *db.Storage, tableName string) domain.SecretRepository {
 	repoOnce.Do(func() {
-		repo = &dynamo.SecretManagerRepository{
-			BaseRepository: repository.BaseRepository{
-				DBConnection: db,
-			},
-			TableName: tableName,
+		switch storage.Backend {
+		case config.StorageMemory:
+			repo = memory.NewSecretManagerRepository()
+		case config.StorageSQLite, config.StoragePostgres:
+			dialect := sqldb.SQLite
+			if storage.Backend == config.StoragePostgres {
+				dialect = sqldb.Postgres
+			}
+			repo = &sqldb.SecretManagerRepository{
+				DB:        storage.SQL,
+				Dialect:   dialect,
+				TableName: db.SQLTableName(tableName),
+			}
+		default:
+			repo = &dynamo.SecretManagerRepository{
+				BaseRepository: repository.BaseRepository{
+					DBConnection: storage.DynamoDB,
+				},
+				TableName: tableName,
+			}
 		}
 	})
 	return repo
 }
+
+// NewBlobStore returns the store of the file secret contents that was initialized with the storage
+func NewBlobStore(storage *db.Storage) domain.BlobStore {
+	return storage.Blobs
+}
@@ -3,13 +3,19 @@ package dynamo
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/nalawade41/secret-server/db"
+	"github.com/nalawade41/secret-server/internal/common/logger"
 	"github.com/nalawade41/secret-server/internal/common/repository"
 	"github.com/nalawade41/secret-server/internal/domain"
+	"github.com/nalawade41/secret-server/internal/secret/repository/pagination"
+	"github.com/nalawade41/secret-server/internal/secret/repository/tenancy"
 	"github.com/pkg/errors"
 )
 
@@ -18,81 +24,458 @@ type SecretManagerRepository struct {
 	TableName string
 }
 
+const (
+	// metadataProjection lists the attributes returned by GetMetadata, the ciphertext and the keys are left out
+	metadataProjection = "#hash, createdAt, expiresAt, remainingViews, readAt, revocationTokenHash, #owner"
+
+	// ownerIndexProjection lists the attributes returned by ListSecretsByOwner, all of them are in the owner index
+	ownerIndexProjection = "#hash, #owner, createdAt, expiresAt, remainingViews, readAt"
+)
+
+// itemKey returns the key of the item of the hash in the tenant of the context, a hash without a key is not found
+func itemKey(ctx context.Context, hash string) (map[string]types.AttributeValue, error) {
+	key, ok := tenancy.Key(ctx, hash)
+	if !ok {
+		return nil, domain.ErrSecretNotFound
+	}
+
+	return map[string]types.AttributeValue{
+		"hash": &types.AttributeValueMemberS{Value: key},
+	}, nil
+}
+
+// unmarshalSecret unmarshals an item, the tenant is split off the keys of its hash and owner
+func unmarshalSecret(item map[string]types.AttributeValue) (domain.Secret, error) {
+	var secret domain.Secret
+	if err := attributevalue.UnmarshalMap(item, &secret); err != nil {
+		return domain.Secret{}, errors.Wrap(err, fmt.Sprintf("failed to unmarshal item: %v", err))
+	}
+
+	secret.Tenant, secret.Hash = tenancy.Split(secret.Hash)
+	_, secret.Owner = tenancy.Split(secret.Owner)
+
+	return secret, nil
+}
+
+// unmarshalSecrets unmarshals the items of a scan or a query like unmarshalSecret
+func unmarshalSecrets(items []map[string]types.AttributeValue) ([]domain.Secret, error) {
+	secrets := make([]domain.Secret, 0, len(items))
+	for _, item := range items {
+		secret, err := unmarshalSecret(item)
+		if err != nil {
+			return nil, err
+		}
+		secrets = append(secrets, secret)
+	}
+
+	return secrets, nil
+}
+
+// hashAttributeName maps #hash to the key attribute, hash is a reserved word in DynamoDB expressions
+func hashAttributeName() map[string]string {
+	return map[string]string{"#hash": "hash"}
+}
+
+// hashAndOwnerAttributeNames adds #owner to hashAttributeName, owner is a reserved word as well
+func hashAndOwnerAttributeNames() map[string]string {
+	return map[string]string{"#hash": "hash", "#owner": "owner"}
+}
+
+// hashAndTTLAttributeNames adds #ttl for the expiry attribute to hashAttributeName, ttl is a reserved word as well
+func hashAndTTLAttributeNames() map[string]string {
+	return map[string]string{"#hash": "hash", "#ttl": "ttl"}
+}
+
 func (s SecretManagerRepository) DeleteSecret(ctx context.Context, hash string) error {
-	_, err := s.DBConnection.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+	// A hash without a key has no secret to delete
+	key, err := itemKey(ctx, hash)
+	if err != nil {
+		return nil
+	}
+
+	_, err = s.DBConnection.DeleteItem(ctx, &dynamodb.DeleteItemInput{
 		TableName: aws.String(s.TableName),
-		Key: map[string]types.AttributeValue{
-			"hash": &types.AttributeValueMemberS{Value: hash},
+		Key:       key,
+	})
+	if err != nil {
+		return errors.Wrap(domain.Unavailable(err), fmt.Sprintf("failed to delete secret for hash: %s", hash))
+	}
+
+	return nil
+}
+
+// ConsumeView atomically decrements the remaining views, appends the read time and returns the updated secret.
+// The condition makes DynamoDB reject the update once no views are left, so concurrent
+// readers can never take more views than the secret was created with. It also rejects secrets
+// whose TTL passed with ErrSecretExpired, DynamoDB can take up to a few days to delete them.
+func (s SecretManagerRepository) ConsumeView(ctx context.Context, hash string) (domain.Secret, error) {
+	key, err := itemKey(ctx, hash)
+	if err != nil {
+		return domain.Secret{}, err
+	}
+
+	now := time.Now().UTC()
+	readAt, err := attributevalue.Marshal([]time.Time{now})
+	if err != nil {
+		return domain.Secret{}, errors.Wrap(err, fmt.Sprintf("failed to marshal read time: %v", err))
+	}
+
+	result, err := s.DBConnection.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:        aws.String(s.TableName),
+		Key:              key,
+		UpdateExpression: aws.String("SET readAt = list_append(if_not_exists(readAt, :empty), :readAt) ADD remainingViews :decrement"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":decrement": &types.AttributeValueMemberN{Value: "-1"},
+			":zero":      &types.AttributeValueMemberN{Value: "0"},
+			":empty":     &types.AttributeValueMemberL{Value: []types.AttributeValue{}},
+			":readAt":    readAt,
+			":now":       &types.AttributeValueMemberN{Value: strconv.FormatInt(now.Unix(), 10)},
 		},
+		// Secrets stored before the TTL was introduced have no ttl attribute
+		ConditionExpression:                 aws.String("attribute_exists(#hash) AND remainingViews > :zero AND (attribute_not_exists(#ttl) OR #ttl > :now)"),
+		ExpressionAttributeNames:            hashAndTTLAttributeNames(),
+		ReturnValues:                        types.ReturnValueAllNew,
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
 	})
 	if err != nil {
-		return errors.Wrap(err, fmt.Sprintf("failed to delete secret for hash: %s", hash))
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			// Without an old item the secret never existed or was already deleted
+			if len(conditionErr.Item) == 0 {
+				return domain.Secret{}, domain.ErrSecretNotFound
+			}
+
+			// The secret expired and is only waiting to be deleted by DynamoDB
+			var old domain.Secret
+			if err := attributevalue.UnmarshalMap(conditionErr.Item, &old); err == nil && old.TTL != 0 && old.TTL <= now.Unix() {
+				return domain.Secret{}, domain.ErrSecretExpired
+			}
+			return domain.Secret{}, domain.ErrNoRemainingViews
+		}
+		return domain.Secret{}, errors.Wrap(domain.Unavailable(err), fmt.Sprintf("failed to consume view for hash: %s", hash))
+	}
+
+	secret, err := unmarshalSecret(result.Attributes)
+	if err != nil {
+		return domain.Secret{}, err
+	}
+
+	// The last view was taken, the secret must not be served again. The metadata stays for the status of the creator.
+	if secret.RemainingViews == 0 {
+		if err := s.removeCiphertext(ctx, hash); err != nil {
+			logger.Errorf("failed to remove ciphertext of fully viewed secret: %v", err)
+		}
+	}
+
+	return secret, nil
+}
+
+// removeCiphertext turns a fully viewed secret into a tombstone that only holds its metadata
+func (s SecretManagerRepository) removeCiphertext(ctx context.Context, hash string) error {
+	key, err := itemKey(ctx, hash)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.DBConnection.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:        aws.String(s.TableName),
+		Key:              key,
+		UpdateExpression: aws.String("REMOVE secretText, wrappedKey, keyId, passphraseKdf, fileName, quotaBytes"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":zero": &types.AttributeValueMemberN{Value: "0"},
+		},
+		ConditionExpression: aws.String("remainingViews = :zero"),
+	})
+	if err != nil {
+		return errors.Wrap(domain.Unavailable(err), fmt.Sprintf("failed to remove ciphertext for hash: %s", hash))
 	}
 
 	return nil
 }
 
-func (s SecretManagerRepository) UpdateSecretViews(ctx context.Context, hash string, remainingViews int) error {
-	_, err := s.DBConnection.UpdateItem(ctx, &dynamodb.UpdateItemInput{
-		TableName: aws.String(s.TableName),
-		Key: map[string]types.AttributeValue{
-			"hash": &types.AttributeValueMemberS{Value: hash},
+// UpdateEncryption replaces the stored ciphertext and wrapped data key, it is used to re-encrypt older secrets.
+// The condition keeps a re-encryption that lost the race against the last view from writing into the tombstone.
+func (s SecretManagerRepository) UpdateEncryption(ctx context.Context, secret domain.Secret) error {
+	key, err := itemKey(ctx, secret.Hash)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.DBConnection.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:        aws.String(s.TableName),
+		Key:              key,
+		UpdateExpression: aws.String("SET secretText = :secretText, wrappedKey = :wrappedKey, keyId = :keyId"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":secretText": &types.AttributeValueMemberS{Value: secret.SecretText},
+			":wrappedKey": &types.AttributeValueMemberS{Value: secret.WrappedKey},
+			":keyId":      &types.AttributeValueMemberS{Value: secret.KeyID},
+			":zero":       &types.AttributeValueMemberN{Value: "0"},
+		},
+		ConditionExpression:                 aws.String("attribute_exists(#hash) AND remainingViews > :zero"),
+		ExpressionAttributeNames:            hashAttributeName(),
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	})
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			// Without an old item the secret never existed or was already deleted
+			if len(conditionErr.Item) == 0 {
+				return errors.Wrap(domain.ErrSecretNotFound, fmt.Sprintf("failed to update secret encryption for hash: %s", secret.Hash))
+			}
+			return errors.Wrap(domain.ErrNoRemainingViews, fmt.Sprintf("failed to update secret encryption for hash: %s", secret.Hash))
+		}
+		return errors.Wrap(domain.Unavailable(err), fmt.Sprintf("failed to update secret encryption for hash: %s", secret.Hash))
+	}
+
+	return nil
+}
+
+// RecordFailedAttempt atomically increments the failed passphrase attempts and burns the secret
+// once the limit is reached, so concurrent guesses can not get past the limit
+func (s SecretManagerRepository) RecordFailedAttempt(ctx context.Context, hash string, maxAttempts int) (int, error) {
+	key, err := itemKey(ctx, hash)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := s.DBConnection.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:        aws.String(s.TableName),
+		Key:              key,
+		UpdateExpression: aws.String("ADD failedAttempts :one"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":one": &types.AttributeValueMemberN{Value: "1"},
 		},
-		UpdateExpression: aws.String("SET remainingViews = :remainingViews"),
+		ConditionExpression:      aws.String("attribute_exists(#hash)"),
+		ExpressionAttributeNames: hashAttributeName(),
+		ReturnValues:             types.ReturnValueUpdatedNew,
+	})
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return 0, domain.ErrSecretNotFound
+		}
+		return 0, errors.Wrap(domain.Unavailable(err), fmt.Sprintf("failed to record failed attempt for hash: %s", hash))
+	}
+
+	var secret domain.Secret
+	if err := attributevalue.UnmarshalMap(result.Attributes, &secret); err != nil {
+		return 0, errors.Wrap(err, fmt.Sprintf("failed to unmarshal item: %v", err))
+	}
+
+	if secret.FailedAttempts >= maxAttempts {
+		if err := s.DeleteSecret(ctx, hash); err != nil {
+			return secret.FailedAttempts, err
+		}
+	}
+
+	return secret.FailedAttempts, nil
+}
+
+// UpdateWrappedKey replaces the wrapped data key and its master key id. The condition on the old wrapped key
+// keeps a re-wrap from overwriting a secret that was re-encrypted or deleted in the meantime.
+func (s SecretManagerRepository) UpdateWrappedKey(ctx context.Context, hash string, oldWrappedKey string, wrappedKey string, keyID string) error {
+	key, err := itemKey(ctx, hash)
+	if err != nil {
+		return domain.ErrSecretChanged
+	}
+
+	_, err = s.DBConnection.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:        aws.String(s.TableName),
+		Key:              key,
+		UpdateExpression: aws.String("SET wrappedKey = :wrappedKey, keyId = :keyId"),
 		ExpressionAttributeValues: map[string]types.AttributeValue{
-			":remainingViews": &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", remainingViews)},
-			":zero":           &types.AttributeValueMemberN{Value: "0"},
+			":wrappedKey":    &types.AttributeValueMemberS{Value: wrappedKey},
+			":keyId":         &types.AttributeValueMemberS{Value: keyID},
+			":oldWrappedKey": &types.AttributeValueMemberS{Value: oldWrappedKey},
 		},
-		ConditionExpression: aws.String("remainingViews > :zero"),
+		ConditionExpression: aws.String("wrappedKey = :oldWrappedKey"),
 	})
 	if err != nil {
-		return errors.Wrap(err, fmt.Sprintf("failed to update remaining views for hash: %s", hash))
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return domain.ErrSecretChanged
+		}
+		return errors.Wrap(domain.Unavailable(err), fmt.Sprintf("failed to update wrapped key for hash: %s", hash))
 	}
 
 	return nil
 }
 
+// ListSecrets scans one page of the table over all tenants, the cursor is the key of the last item of the previous page
+func (s SecretManagerRepository) ListSecrets(ctx context.Context, cursor string, limit int) ([]domain.Secret, string, error) {
+	input := &dynamodb.ScanInput{
+		TableName: aws.String(s.TableName),
+		Limit:     aws.Int32(int32(limit)),
+	}
+	if cursor != "" {
+		input.ExclusiveStartKey = map[string]types.AttributeValue{
+			"hash": &types.AttributeValueMemberS{Value: cursor},
+		}
+	}
+
+	result, err := s.DBConnection.Scan(ctx, input)
+	if err != nil {
+		return nil, "", errors.Wrap(domain.Unavailable(err), fmt.Sprintf("failed to scan secrets after cursor: %s", cursor))
+	}
+
+	secrets, err := unmarshalSecrets(result.Items)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// DynamoDB only returns a last evaluated key when there are more items to scan
+	var next string
+	if lastKey, ok := result.LastEvaluatedKey["hash"].(*types.AttributeValueMemberS); ok {
+		next = lastKey.Value
+	}
+
+	return secrets, next, nil
+}
+
+// ListSecretsByOwner queries one page of the owner index, newest first. The cursor carries the creation time and
+// the hash of the last evaluated key, the owner is always the one asked for. The owner is keyed to the tenant
+// of the context like the hash, the index never returns the secrets of an owner of the same name in another tenant.
+func (s SecretManagerRepository) ListSecretsByOwner(ctx context.Context, owner string, cursor string, limit int) ([]domain.Secret, string, error) {
+	ownerKey := tenancy.OwnerKey(ctx, owner)
+	if ownerKey == "" {
+		return nil, "", nil
+	}
+
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(s.TableName),
+		IndexName:              aws.String(db.OwnerIndexName),
+		KeyConditionExpression: aws.String("#owner = :owner"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":owner": &types.AttributeValueMemberS{Value: ownerKey},
+		},
+		ExpressionAttributeNames: hashAndOwnerAttributeNames(),
+		ProjectionExpression:     aws.String(ownerIndexProjection),
+		ScanIndexForward:         aws.Bool(false),
+		Limit:                    aws.Int32(int32(limit)),
+	}
+	if cursor != "" {
+		start, err := pagination.DecodeOwnerCursor(cursor)
+		if err != nil {
+			return nil, "", err
+		}
+
+		createdAt, err := attributevalue.Marshal(start.CreatedAt)
+		if err != nil {
+			return nil, "", errors.Wrap(err, fmt.Sprintf("failed to marshal cursor: %v", err))
+		}
+
+		startKey, ok := tenancy.Key(ctx, start.Hash)
+		if !ok {
+			return nil, "", domain.ErrInvalidCursor
+		}
+
+		input.ExclusiveStartKey = map[string]types.AttributeValue{
+			"hash":      &types.AttributeValueMemberS{Value: startKey},
+			"owner":     &types.AttributeValueMemberS{Value: ownerKey},
+			"createdAt": createdAt,
+		}
+	}
+
+	result, err := s.DBConnection.Query(ctx, input)
+	if err != nil {
+		return nil, "", errors.Wrap(domain.Unavailable(err), fmt.Sprintf("failed to query secrets of owner: %s", owner))
+	}
+
+	secrets, err := unmarshalSecrets(result.Items)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// DynamoDB only returns a last evaluated key when there may be more items of the owner
+	var next string
+	if len(result.LastEvaluatedKey) > 0 {
+		last, err := unmarshalSecret(result.LastEvaluatedKey)
+		if err != nil {
+			return nil, "", err
+		}
+		next = pagination.OwnerCursor{CreatedAt: last.CreatedAt, Hash: last.Hash}.Encode()
+	}
+
+	return secrets, next, nil
+}
+
+// Save puts the secret under its key in the tenant of the context, the owner is keyed to the tenant as well
 func (s SecretManagerRepository) Save(ctx context.Context, secret domain.Secret) error {
+	key, ok := tenancy.Key(ctx, secret.Hash)
+	if !ok {
+		return errors.Errorf("invalid secret id %q", secret.Hash)
+	}
+	secret.Hash = key
+	secret.Owner = tenancy.OwnerKey(ctx, secret.Owner)
+
 	// Marshal the secret into a map of DynamoDB attribute values
 	item, err := attributevalue.MarshalMap(secret)
 	if err != nil {
-		return errors.Wrap(err, fmt.Sprintf("failed to marshal secret: %w", err))
+		return errors.Wrap(err, fmt.Sprintf("failed to marshal secret: %v", err))
 	}
 
-	// Put the item into the DynamoDB table
+	// Put the item into the DynamoDB table, unless the id is already taken
 	_, err = s.DBConnection.PutItem(ctx, &dynamodb.PutItemInput{
-		TableName: aws.String(s.TableName),
-		Item:      item,
+		TableName:                aws.String(s.TableName),
+		Item:                     item,
+		ConditionExpression:      aws.String("attribute_not_exists(#hash)"),
+		ExpressionAttributeNames: hashAttributeName(),
 	})
 	if err != nil {
-		return errors.Wrap(err, fmt.Sprintf("failed to put item: %w", err))
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return errors.Wrap(domain.ErrSecretAlreadyExists, fmt.Sprintf("failed to put item for hash: %s", secret.Hash))
+		}
+		return errors.Wrap(domain.Unavailable(err), fmt.Sprintf("failed to put item: %v", err))
 	}
 
 	return nil
 }
 
+// GetMetadata reads the secret without its ciphertext and keys, the projection keeps them from ever leaving the table
+func (s SecretManagerRepository) GetMetadata(ctx context.Context, hash string) (domain.Secret, error) {
+	key, err := itemKey(ctx, hash)
+	if err != nil {
+		return domain.Secret{}, err
+	}
+
+	result, err := s.DBConnection.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:                aws.String(s.TableName),
+		Key:                      key,
+		ProjectionExpression:     aws.String(metadataProjection),
+		ExpressionAttributeNames: hashAndOwnerAttributeNames(),
+	})
+	if err != nil {
+		return domain.Secret{}, errors.Wrap(domain.Unavailable(err), fmt.Sprintf("failed to retrieve metadata for hash: %s", hash))
+	}
+
+	if result.Item == nil {
+		return domain.Secret{}, domain.ErrSecretNotFound
+	}
+
+	return unmarshalSecret(result.Item)
+}
+
 func (s SecretManagerRepository) GetByHash(ctx context.Context, hash string) (domain.Secret, error) {
+	key, err := itemKey(ctx, hash)
+	if err != nil {
+		return domain.Secret{}, err
+	}
+
 	result, err := s.DBConnection.GetItem(ctx, &dynamodb.GetItemInput{
 		TableName: aws.String(s.TableName),
-		Key: map[string]types.AttributeValue{
-			"hash": &types.AttributeValueMemberS{Value: hash},
-		},
+		Key:       key,
 	})
 	if err != nil {
-		return domain.Secret{}, errors.Wrap(err, fmt.Sprintf("failed to get item for hash: %s", hash))
+		return domain.Secret{}, errors.Wrap(domain.Unavailable(err), fmt.Sprintf("failed to retrieve item for hash: %s", hash))
 	}
 
 	if result.Item == nil {
-		return domain.Secret{}, errors.New("secret not found")
+		return domain.Secret{}, domain.ErrSecretNotFound
 	}
 
 	// Unmarshal the result into a domain.Secret struct
-	var secret domain.Secret
-	if err := attributevalue.UnmarshalMap(result.Item, &secret); err != nil {
-		return domain.Secret{}, errors.Wrap(err, fmt.Sprintf("failed to unmarshal item: %w", err))
-	}
-
-	return secret, nil
+	return unmarshalSecret(result.Item)
 }
 
 var _ domain.SecretRepository = (*SecretManagerRepository)(nil)
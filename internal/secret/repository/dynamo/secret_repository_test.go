@@ -62,7 +62,52 @@ func TestDeleteSecret_Error(t *testing.T) {
 	assert.Contains(t, err.Error(), "failed to delete secret")
 }
 
-func TestUpdateSecretViews_Success(t *testing.T) {
+// consumeViewInput matches the update of ConsumeView for the hash, the read time only has to be a single timestamp
+// and the time the TTL is compared with has to be set
+type consumeViewInput string
+
+func (m consumeViewInput) Matches(x interface{}) bool {
+	input, ok := x.(*dynamodb.UpdateItemInput)
+	if !ok {
+		return false
+	}
+
+	readAt, ok := input.ExpressionAttributeValues[":readAt"].(*types.AttributeValueMemberL)
+	if !ok || len(readAt.Value) != 1 {
+		return false
+	}
+
+	now, ok := input.ExpressionAttributeValues[":now"].(*types.AttributeValueMemberN)
+	if !ok || now.Value == "" {
+		return false
+	}
+
+	expected := &dynamodb.UpdateItemInput{
+		TableName: aws.String("secrets"),
+		Key: map[string]types.AttributeValue{
+			"hash": &types.AttributeValueMemberS{Value: string(m)},
+		},
+		UpdateExpression: aws.String("SET readAt = list_append(if_not_exists(readAt, :empty), :readAt) ADD remainingViews :decrement"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":decrement": &types.AttributeValueMemberN{Value: "-1"},
+			":zero":      &types.AttributeValueMemberN{Value: "0"},
+			":empty":     &types.AttributeValueMemberL{Value: []types.AttributeValue{}},
+			":readAt":    readAt,
+			":now":       now,
+		},
+		ConditionExpression:                 aws.String("attribute_exists(#hash) AND remainingViews > :zero AND (attribute_not_exists(#ttl) OR #ttl > :now)"),
+		ExpressionAttributeNames:            map[string]string{"#hash": "hash", "#ttl": "ttl"},
+		ReturnValues:                        types.ReturnValueAllNew,
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	}
+	return gomock.Eq(expected).Matches(input)
+}
+
+func (m consumeViewInput) String() string {
+	return "consumes a view of " + string(m)
+}
+
+func TestConsumeView_Success(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
@@ -70,28 +115,197 @@ func TestUpdateSecretViews_Success(t *testing.T) {
 	repo := SecretManagerRepository{BaseRepository: repository.BaseRepository{DBConnection: mockDB}, TableName: "secrets"}
 
 	hash := "testhash"
-	remainingViews := 4
+	item, _ := attributevalue.MarshalMap(domain.Secret{Hash: hash, SecretText: "envelope", RemainingViews: 4})
 
 	// Set expectations for UpdateItem
+	mockDB.EXPECT().UpdateItem(gomock.Any(), consumeViewInput(hash)).Return(&dynamodb.UpdateItemOutput{Attributes: item}, nil)
+
+	result, err := repo.ConsumeView(context.Background(), hash)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 4, result.RemainingViews)
+	assert.Equal(t, "envelope", result.SecretText)
+}
+
+func TestConsumeView_LastViewLeavesTombstone(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDB := mocks.NewMockDynamoDBAPI(ctrl)
+	repo := SecretManagerRepository{BaseRepository: repository.BaseRepository{DBConnection: mockDB}, TableName: "secrets"}
+
+	hash := "testhash"
+	item, _ := attributevalue.MarshalMap(domain.Secret{Hash: hash, SecretText: "envelope", RemainingViews: 0})
+
+	// Taking the last view removes the ciphertext, the metadata stays for the status
+	mockDB.EXPECT().UpdateItem(gomock.Any(), consumeViewInput(hash)).Return(&dynamodb.UpdateItemOutput{Attributes: item}, nil)
 	mockDB.EXPECT().UpdateItem(gomock.Any(), &dynamodb.UpdateItemInput{
 		TableName: aws.String("secrets"),
 		Key: map[string]types.AttributeValue{
 			"hash": &types.AttributeValueMemberS{Value: hash},
 		},
-		UpdateExpression: aws.String("SET remainingViews = :remainingViews"),
+		UpdateExpression: aws.String("REMOVE secretText, wrappedKey, keyId, passphraseKdf, fileName, quotaBytes"),
 		ExpressionAttributeValues: map[string]types.AttributeValue{
-			":remainingViews": &types.AttributeValueMemberN{Value: "4"},
-			":zero":           &types.AttributeValueMemberN{Value: "0"},
+			":zero": &types.AttributeValueMemberN{Value: "0"},
 		},
-		ConditionExpression: aws.String("remainingViews > :zero"),
+		ConditionExpression: aws.String("remainingViews = :zero"),
 	}).Return(&dynamodb.UpdateItemOutput{}, nil)
 
-	err := repo.UpdateSecretViews(context.Background(), hash, remainingViews)
+	result, err := repo.ConsumeView(context.Background(), hash)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 0, result.RemainingViews)
+}
+
+func TestConsumeView_NoRemainingViews(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDB := mocks.NewMockDynamoDBAPI(ctrl)
+	repo := SecretManagerRepository{BaseRepository: repository.BaseRepository{DBConnection: mockDB}, TableName: "secrets"}
+
+	hash := "testhash"
+	item, _ := attributevalue.MarshalMap(domain.Secret{Hash: hash, RemainingViews: 0})
+
+	// The condition fails and returns the old item
+	mockDB.EXPECT().UpdateItem(gomock.Any(), consumeViewInput(hash)).Return(nil, &types.ConditionalCheckFailedException{Item: item})
+
+	_, err := repo.ConsumeView(context.Background(), hash)
+
+	assert.ErrorIs(t, err, domain.ErrNoRemainingViews)
+}
+
+func TestConsumeView_Expired(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDB := mocks.NewMockDynamoDBAPI(ctrl)
+	repo := SecretManagerRepository{BaseRepository: repository.BaseRepository{DBConnection: mockDB}, TableName: "secrets"}
+
+	hash := "testhash"
+	item, _ := attributevalue.MarshalMap(domain.Secret{Hash: hash, RemainingViews: 3, TTL: time.Now().Add(-time.Minute).Unix()})
+
+	// The secret still has views, but its TTL passed before DynamoDB deleted it
+	mockDB.EXPECT().UpdateItem(gomock.Any(), consumeViewInput(hash)).Return(nil, &types.ConditionalCheckFailedException{Item: item})
+
+	_, err := repo.ConsumeView(context.Background(), hash)
+
+	assert.ErrorIs(t, err, domain.ErrSecretExpired)
+}
+
+func TestConsumeView_NotFound(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDB := mocks.NewMockDynamoDBAPI(ctrl)
+	repo := SecretManagerRepository{BaseRepository: repository.BaseRepository{DBConnection: mockDB}, TableName: "secrets"}
+
+	hash := "testhash"
+
+	// The condition fails without an old item when the secret does not exist
+	mockDB.EXPECT().UpdateItem(gomock.Any(), consumeViewInput(hash)).Return(nil, &types.ConditionalCheckFailedException{})
+
+	_, err := repo.ConsumeView(context.Background(), hash)
+
+	assert.ErrorIs(t, err, domain.ErrSecretNotFound)
+}
+
+func TestConsumeView_Error(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDB := mocks.NewMockDynamoDBAPI(ctrl)
+	repo := SecretManagerRepository{BaseRepository: repository.BaseRepository{DBConnection: mockDB}, TableName: "secrets"}
+
+	hash := "testhash"
+
+	// Set expectations for UpdateItem to return an error
+	mockDB.EXPECT().UpdateItem(gomock.Any(), consumeViewInput(hash)).Return(nil, errors.New("update error"))
+
+	_, err := repo.ConsumeView(context.Background(), hash)
+
+	assert.ErrorIs(t, err, domain.ErrUnavailable)
+	assert.Contains(t, err.Error(), "failed to consume view")
+}
+
+func recordFailedAttemptInput(hash string) *dynamodb.UpdateItemInput {
+	return &dynamodb.UpdateItemInput{
+		TableName: aws.String("secrets"),
+		Key: map[string]types.AttributeValue{
+			"hash": &types.AttributeValueMemberS{Value: hash},
+		},
+		UpdateExpression: aws.String("ADD failedAttempts :one"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":one": &types.AttributeValueMemberN{Value: "1"},
+		},
+		ConditionExpression:      aws.String("attribute_exists(#hash)"),
+		ExpressionAttributeNames: map[string]string{"#hash": "hash"},
+		ReturnValues:             types.ReturnValueUpdatedNew,
+	}
+}
+
+func TestRecordFailedAttempt_Success(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDB := mocks.NewMockDynamoDBAPI(ctrl)
+	repo := SecretManagerRepository{BaseRepository: repository.BaseRepository{DBConnection: mockDB}, TableName: "secrets"}
+
+	hash := "testhash"
+	attributes := map[string]types.AttributeValue{"failedAttempts": &types.AttributeValueMemberN{Value: "2"}}
+
+	// Set expectations for UpdateItem
+	mockDB.EXPECT().UpdateItem(gomock.Any(), recordFailedAttemptInput(hash)).Return(&dynamodb.UpdateItemOutput{Attributes: attributes}, nil)
+
+	attempts, err := repo.RecordFailedAttempt(context.Background(), hash, 5)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, attempts)
+}
+
+func TestRecordFailedAttempt_LimitBurnsSecret(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDB := mocks.NewMockDynamoDBAPI(ctrl)
+	repo := SecretManagerRepository{BaseRepository: repository.BaseRepository{DBConnection: mockDB}, TableName: "secrets"}
+
+	hash := "testhash"
+	attributes := map[string]types.AttributeValue{"failedAttempts": &types.AttributeValueMemberN{Value: "5"}}
+
+	// Reaching the limit deletes the secret
+	mockDB.EXPECT().UpdateItem(gomock.Any(), recordFailedAttemptInput(hash)).Return(&dynamodb.UpdateItemOutput{Attributes: attributes}, nil)
+	mockDB.EXPECT().DeleteItem(gomock.Any(), &dynamodb.DeleteItemInput{
+		TableName: aws.String("secrets"),
+		Key: map[string]types.AttributeValue{
+			"hash": &types.AttributeValueMemberS{Value: hash},
+		},
+	}).Return(&dynamodb.DeleteItemOutput{}, nil)
+
+	attempts, err := repo.RecordFailedAttempt(context.Background(), hash, 5)
 
 	assert.NoError(t, err)
+	assert.Equal(t, 5, attempts)
+}
+
+func TestRecordFailedAttempt_NotFound(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDB := mocks.NewMockDynamoDBAPI(ctrl)
+	repo := SecretManagerRepository{BaseRepository: repository.BaseRepository{DBConnection: mockDB}, TableName: "secrets"}
+
+	hash := "testhash"
+
+	// The condition fails when the secret was already burned or deleted
+	mockDB.EXPECT().UpdateItem(gomock.Any(), recordFailedAttemptInput(hash)).Return(nil, &types.ConditionalCheckFailedException{})
+
+	_, err := repo.RecordFailedAttempt(context.Background(), hash, 5)
+
+	assert.ErrorIs(t, err, domain.ErrSecretNotFound)
 }
 
-func TestUpdateSecretViews_Error(t *testing.T) {
+func TestRecordFailedAttempt_Error(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
@@ -99,26 +313,216 @@ func TestUpdateSecretViews_Error(t *testing.T) {
 	repo := SecretManagerRepository{BaseRepository: repository.BaseRepository{DBConnection: mockDB}, TableName: "secrets"}
 
 	hash := "testhash"
-	remainingViews := 4
 
 	// Set expectations for UpdateItem to return an error
+	mockDB.EXPECT().UpdateItem(gomock.Any(), recordFailedAttemptInput(hash)).Return(nil, errors.New("update error"))
+
+	_, err := repo.RecordFailedAttempt(context.Background(), hash, 5)
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to record failed attempt")
+}
+
+func TestUpdateEncryption_Success(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDB := mocks.NewMockDynamoDBAPI(ctrl)
+	repo := SecretManagerRepository{BaseRepository: repository.BaseRepository{DBConnection: mockDB}, TableName: "secrets"}
+
+	hash := "testhash"
+
+	// Set expectations for UpdateItem
 	mockDB.EXPECT().UpdateItem(gomock.Any(), &dynamodb.UpdateItemInput{
 		TableName: aws.String("secrets"),
 		Key: map[string]types.AttributeValue{
 			"hash": &types.AttributeValueMemberS{Value: hash},
 		},
-		UpdateExpression: aws.String("SET remainingViews = :remainingViews"),
+		UpdateExpression: aws.String("SET secretText = :secretText, wrappedKey = :wrappedKey, keyId = :keyId"),
 		ExpressionAttributeValues: map[string]types.AttributeValue{
-			":remainingViews": &types.AttributeValueMemberN{Value: "4"},
-			":zero":           &types.AttributeValueMemberN{Value: "0"},
+			":secretText": &types.AttributeValueMemberS{Value: "envelope"},
+			":wrappedKey": &types.AttributeValueMemberS{Value: "wrapped"},
+			":keyId":      &types.AttributeValueMemberS{Value: "master"},
+			":zero":       &types.AttributeValueMemberN{Value: "0"},
 		},
-		ConditionExpression: aws.String("remainingViews > :zero"),
-	}).Return(nil, errors.New("update error"))
+		ConditionExpression:                 aws.String("attribute_exists(#hash) AND remainingViews > :zero"),
+		ExpressionAttributeNames:            map[string]string{"#hash": "hash"},
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	}).Return(&dynamodb.UpdateItemOutput{}, nil)
+
+	err := repo.UpdateEncryption(context.Background(), domain.Secret{Hash: hash, SecretText: "envelope", WrappedKey: "wrapped", KeyID: "master"})
+
+	assert.NoError(t, err)
+}
+
+func TestUpdateEncryption_NotFound(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDB := mocks.NewMockDynamoDBAPI(ctrl)
+	repo := SecretManagerRepository{BaseRepository: repository.BaseRepository{DBConnection: mockDB}, TableName: "secrets"}
+
+	// The condition fails when the secret does not exist
+	mockDB.EXPECT().UpdateItem(gomock.Any(), gomock.Any()).Return(nil, &types.ConditionalCheckFailedException{})
+
+	err := repo.UpdateEncryption(context.Background(), domain.Secret{Hash: "testhash", SecretText: "envelope"})
+
+	assert.ErrorIs(t, err, domain.ErrSecretNotFound)
+}
 
-	err := repo.UpdateSecretViews(context.Background(), hash, remainingViews)
+func TestUpdateEncryption_NoRemainingViews(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDB := mocks.NewMockDynamoDBAPI(ctrl)
+	repo := SecretManagerRepository{BaseRepository: repository.BaseRepository{DBConnection: mockDB}, TableName: "secrets"}
+
+	// The condition fails on the tombstone left by the last view
+	mockDB.EXPECT().UpdateItem(gomock.Any(), gomock.Any()).Return(nil, &types.ConditionalCheckFailedException{
+		Item: map[string]types.AttributeValue{
+			"hash":           &types.AttributeValueMemberS{Value: "testhash"},
+			"remainingViews": &types.AttributeValueMemberN{Value: "0"},
+		},
+	})
+
+	err := repo.UpdateEncryption(context.Background(), domain.Secret{Hash: "testhash", SecretText: "envelope"})
+
+	assert.ErrorIs(t, err, domain.ErrNoRemainingViews)
+}
+
+func TestUpdateEncryption_Error(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDB := mocks.NewMockDynamoDBAPI(ctrl)
+	repo := SecretManagerRepository{BaseRepository: repository.BaseRepository{DBConnection: mockDB}, TableName: "secrets"}
+
+	// Set expectations for UpdateItem to return an error
+	mockDB.EXPECT().UpdateItem(gomock.Any(), gomock.Any()).Return(nil, errors.New("update error"))
+
+	err := repo.UpdateEncryption(context.Background(), domain.Secret{Hash: "testhash", SecretText: "envelope"})
 
 	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "failed to update remaining views")
+	assert.Contains(t, err.Error(), "failed to update secret encryption")
+}
+
+func TestUpdateWrappedKey_Success(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDB := mocks.NewMockDynamoDBAPI(ctrl)
+	repo := SecretManagerRepository{BaseRepository: repository.BaseRepository{DBConnection: mockDB}, TableName: "secrets"}
+
+	hash := "testhash"
+
+	// The update only applies while the secret still holds the old wrapped key
+	mockDB.EXPECT().UpdateItem(gomock.Any(), &dynamodb.UpdateItemInput{
+		TableName: aws.String("secrets"),
+		Key: map[string]types.AttributeValue{
+			"hash": &types.AttributeValueMemberS{Value: hash},
+		},
+		UpdateExpression: aws.String("SET wrappedKey = :wrappedKey, keyId = :keyId"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":wrappedKey":    &types.AttributeValueMemberS{Value: "new wrapped"},
+			":keyId":         &types.AttributeValueMemberS{Value: "2024"},
+			":oldWrappedKey": &types.AttributeValueMemberS{Value: "old wrapped"},
+		},
+		ConditionExpression: aws.String("wrappedKey = :oldWrappedKey"),
+	}).Return(&dynamodb.UpdateItemOutput{}, nil)
+
+	err := repo.UpdateWrappedKey(context.Background(), hash, "old wrapped", "new wrapped", "2024")
+
+	assert.NoError(t, err)
+}
+
+func TestUpdateWrappedKey_SecretChanged(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDB := mocks.NewMockDynamoDBAPI(ctrl)
+	repo := SecretManagerRepository{BaseRepository: repository.BaseRepository{DBConnection: mockDB}, TableName: "secrets"}
+
+	// The secret was re-encrypted or deleted since it was read
+	mockDB.EXPECT().UpdateItem(gomock.Any(), gomock.Any()).Return(nil, &types.ConditionalCheckFailedException{})
+
+	err := repo.UpdateWrappedKey(context.Background(), "testhash", "old wrapped", "new wrapped", "2024")
+
+	assert.ErrorIs(t, err, domain.ErrSecretChanged)
+}
+
+func TestUpdateWrappedKey_Error(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDB := mocks.NewMockDynamoDBAPI(ctrl)
+	repo := SecretManagerRepository{BaseRepository: repository.BaseRepository{DBConnection: mockDB}, TableName: "secrets"}
+
+	mockDB.EXPECT().UpdateItem(gomock.Any(), gomock.Any()).Return(nil, errors.New("update error"))
+
+	err := repo.UpdateWrappedKey(context.Background(), "testhash", "old wrapped", "new wrapped", "2024")
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to update wrapped key")
+}
+
+func TestListSecrets_Pages(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDB := mocks.NewMockDynamoDBAPI(ctrl)
+	repo := SecretManagerRepository{BaseRepository: repository.BaseRepository{DBConnection: mockDB}, TableName: "secrets"}
+
+	first, _ := attributevalue.MarshalMap(domain.Secret{Hash: "first", RemainingViews: 1})
+	second, _ := attributevalue.MarshalMap(domain.Secret{Hash: "second", RemainingViews: 2})
+
+	// The first page ends with a last evaluated key, the second page resumes from it
+	gomock.InOrder(
+		mockDB.EXPECT().Scan(gomock.Any(), &dynamodb.ScanInput{
+			TableName: aws.String("secrets"),
+			Limit:     aws.Int32(1),
+		}).Return(&dynamodb.ScanOutput{
+			Items: []map[string]types.AttributeValue{first},
+			LastEvaluatedKey: map[string]types.AttributeValue{
+				"hash": &types.AttributeValueMemberS{Value: "first"},
+			},
+		}, nil),
+		mockDB.EXPECT().Scan(gomock.Any(), &dynamodb.ScanInput{
+			TableName: aws.String("secrets"),
+			Limit:     aws.Int32(1),
+			ExclusiveStartKey: map[string]types.AttributeValue{
+				"hash": &types.AttributeValueMemberS{Value: "first"},
+			},
+		}).Return(&dynamodb.ScanOutput{
+			Items: []map[string]types.AttributeValue{second},
+		}, nil),
+	)
+
+	secrets, cursor, err := repo.ListSecrets(context.Background(), "", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "first", cursor)
+	assert.Len(t, secrets, 1)
+	assert.Equal(t, "first", secrets[0].Hash)
+
+	secrets, cursor, err = repo.ListSecrets(context.Background(), cursor, 1)
+	assert.NoError(t, err)
+	assert.Empty(t, cursor, "The cursor should be empty after the last page")
+	assert.Len(t, secrets, 1)
+	assert.Equal(t, 2, secrets[0].RemainingViews)
+}
+
+func TestListSecrets_Error(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDB := mocks.NewMockDynamoDBAPI(ctrl)
+	repo := SecretManagerRepository{BaseRepository: repository.BaseRepository{DBConnection: mockDB}, TableName: "secrets"}
+
+	mockDB.EXPECT().Scan(gomock.Any(), gomock.Any()).Return(nil, errors.New("scan error"))
+
+	_, _, err := repo.ListSecrets(context.Background(), "cursor", 10)
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to scan secrets")
 }
 
 func TestSave_Success(t *testing.T) {
@@ -140,8 +544,10 @@ func TestSave_Success(t *testing.T) {
 
 	// Set expectations for PutItem
 	mockDB.EXPECT().PutItem(gomock.Any(), &dynamodb.PutItemInput{
-		TableName: aws.String("secrets"),
-		Item:      item,
+		TableName:                aws.String("secrets"),
+		Item:                     item,
+		ConditionExpression:      aws.String("attribute_not_exists(#hash)"),
+		ExpressionAttributeNames: map[string]string{"#hash": "hash"},
 	}).Return(&dynamodb.PutItemOutput{}, nil)
 
 	err := repo.Save(context.Background(), secret)
@@ -149,6 +555,36 @@ func TestSave_Success(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestSave_DoesNotPersistKey(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDB := mocks.NewMockDynamoDBAPI(ctrl)
+	repo := SecretManagerRepository{BaseRepository: repository.BaseRepository{DBConnection: mockDB}, TableName: "secrets"}
+
+	secret := domain.Secret{
+		Hash:           "testhash",
+		Key:            "testkey",
+		SecretText:     "This is a test secret",
+		ExpiresAt:      time.Now().Add(10 * time.Minute),
+		RemainingViews: 5,
+		CreatedAt:      time.Now().UTC(),
+	}
+
+	// The decryption key must never be written to the table
+	mockDB.EXPECT().PutItem(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, input *dynamodb.PutItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+			assert.NotContains(t, input.Item, "Key")
+			assert.NotContains(t, input.Item, "key")
+			assert.Len(t, input.Item, 5)
+			return &dynamodb.PutItemOutput{}, nil
+		})
+
+	err := repo.Save(context.Background(), secret)
+
+	assert.NoError(t, err)
+}
+
 func TestSave_Error(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -168,16 +604,171 @@ func TestSave_Error(t *testing.T) {
 
 	// Set expectations for PutItem to return an error
 	mockDB.EXPECT().PutItem(gomock.Any(), &dynamodb.PutItemInput{
-		TableName: aws.String("secrets"),
-		Item:      item,
+		TableName:                aws.String("secrets"),
+		Item:                     item,
+		ConditionExpression:      aws.String("attribute_not_exists(#hash)"),
+		ExpressionAttributeNames: map[string]string{"#hash": "hash"},
 	}).Return(nil, errors.New("put item error"))
 
 	err := repo.Save(context.Background(), secret)
 
-	assert.Error(t, err)
+	assert.ErrorIs(t, err, domain.ErrUnavailable)
 	assert.Contains(t, err.Error(), "failed to put item")
 }
 
+func TestSave_HashAlreadyExists(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDB := mocks.NewMockDynamoDBAPI(ctrl)
+	repo := SecretManagerRepository{BaseRepository: repository.BaseRepository{DBConnection: mockDB}, TableName: "secrets"}
+
+	secret := domain.Secret{
+		Hash:           "testhash",
+		SecretText:     "This is a test secret",
+		ExpiresAt:      time.Now().Add(10 * time.Minute),
+		RemainingViews: 5,
+		CreatedAt:      time.Now().UTC(),
+	}
+
+	// The conditional put fails when the hash is already taken
+	mockDB.EXPECT().PutItem(gomock.Any(), gomock.Any()).Return(nil, &types.ConditionalCheckFailedException{})
+
+	err := repo.Save(context.Background(), secret)
+
+	assert.Error(t, err)
+	assert.ErrorIs(t, err, domain.ErrSecretAlreadyExists)
+}
+
+func TestListSecretsByOwner_Pages(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDB := mocks.NewMockDynamoDBAPI(ctrl)
+	repo := SecretManagerRepository{BaseRepository: repository.BaseRepository{DBConnection: mockDB}, TableName: "secrets"}
+
+	createdAt := time.Date(2024, time.May, 1, 12, 0, 0, 0, time.UTC)
+	first, _ := attributevalue.MarshalMap(domain.Secret{Hash: "first", Owner: "alice", CreatedAt: createdAt, RemainingViews: 1})
+	second, _ := attributevalue.MarshalMap(domain.Secret{Hash: "second", Owner: "alice", CreatedAt: createdAt.Add(-time.Hour)})
+	lastKey, _ := attributevalue.MarshalMap(map[string]interface{}{"hash": "first", "owner": "alice", "createdAt": createdAt})
+
+	query := func(startKey map[string]types.AttributeValue) *dynamodb.QueryInput {
+		return &dynamodb.QueryInput{
+			TableName:              aws.String("secrets"),
+			IndexName:              aws.String("owner-createdAt"),
+			KeyConditionExpression: aws.String("#owner = :owner"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":owner": &types.AttributeValueMemberS{Value: "alice"},
+			},
+			ExpressionAttributeNames: map[string]string{"#hash": "hash", "#owner": "owner"},
+			ProjectionExpression:     aws.String("#hash, #owner, createdAt, expiresAt, remainingViews, readAt"),
+			ScanIndexForward:         aws.Bool(false),
+			Limit:                    aws.Int32(1),
+			ExclusiveStartKey:        startKey,
+		}
+	}
+
+	// The cursor of the first page resumes the query at the last evaluated key
+	gomock.InOrder(
+		mockDB.EXPECT().Query(gomock.Any(), query(nil)).Return(&dynamodb.QueryOutput{
+			Items:            []map[string]types.AttributeValue{first},
+			LastEvaluatedKey: lastKey,
+		}, nil),
+		mockDB.EXPECT().Query(gomock.Any(), query(lastKey)).Return(&dynamodb.QueryOutput{
+			Items: []map[string]types.AttributeValue{second},
+		}, nil),
+	)
+
+	secrets, cursor, err := repo.ListSecretsByOwner(context.Background(), "alice", "", 1)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, cursor)
+	if assert.Len(t, secrets, 1) {
+		assert.Equal(t, "first", secrets[0].Hash)
+	}
+
+	secrets, cursor, err = repo.ListSecretsByOwner(context.Background(), "alice", cursor, 1)
+	assert.NoError(t, err)
+	assert.Empty(t, cursor, "The cursor should be empty after the last page")
+	if assert.Len(t, secrets, 1) {
+		assert.Equal(t, "second", secrets[0].Hash)
+	}
+}
+
+func TestListSecretsByOwner_Error(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDB := mocks.NewMockDynamoDBAPI(ctrl)
+	repo := SecretManagerRepository{BaseRepository: repository.BaseRepository{DBConnection: mockDB}, TableName: "secrets"}
+
+	// An invalid cursor never reaches DynamoDB
+	_, _, err := repo.ListSecretsByOwner(context.Background(), "alice", "not a cursor", 10)
+	assert.ErrorIs(t, err, domain.ErrInvalidCursor)
+
+	mockDB.EXPECT().Query(gomock.Any(), gomock.Any()).Return(nil, errors.New("query error"))
+
+	_, _, err = repo.ListSecretsByOwner(context.Background(), "alice", "", 10)
+	assert.ErrorIs(t, err, domain.ErrUnavailable)
+}
+
+func TestGetMetadata_Success(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDB := mocks.NewMockDynamoDBAPI(ctrl)
+	repo := SecretManagerRepository{BaseRepository: repository.BaseRepository{DBConnection: mockDB}, TableName: "secrets"}
+
+	hash := "testhash"
+	readAt := time.Now().UTC().Truncate(time.Second)
+	item, _ := attributevalue.MarshalMap(domain.Secret{Hash: hash, RemainingViews: 0, ReadAt: []time.Time{readAt}, RevocationTokenHash: "tokenhash"})
+
+	// Only the metadata attributes are requested
+	mockDB.EXPECT().GetItem(gomock.Any(), &dynamodb.GetItemInput{
+		TableName: aws.String("secrets"),
+		Key: map[string]types.AttributeValue{
+			"hash": &types.AttributeValueMemberS{Value: hash},
+		},
+		ProjectionExpression:     aws.String("#hash, createdAt, expiresAt, remainingViews, readAt, revocationTokenHash, #owner"),
+		ExpressionAttributeNames: map[string]string{"#hash": "hash", "#owner": "owner"},
+	}).Return(&dynamodb.GetItemOutput{Item: item}, nil)
+
+	result, err := repo.GetMetadata(context.Background(), hash)
+
+	assert.NoError(t, err)
+	assert.Equal(t, hash, result.Hash)
+	assert.Equal(t, []time.Time{readAt}, result.ReadAt)
+	assert.Equal(t, "tokenhash", result.RevocationTokenHash)
+}
+
+func TestGetMetadata_NotFound(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDB := mocks.NewMockDynamoDBAPI(ctrl)
+	repo := SecretManagerRepository{BaseRepository: repository.BaseRepository{DBConnection: mockDB}, TableName: "secrets"}
+
+	mockDB.EXPECT().GetItem(gomock.Any(), gomock.Any()).Return(&dynamodb.GetItemOutput{}, nil)
+
+	_, err := repo.GetMetadata(context.Background(), "testhash")
+
+	assert.ErrorIs(t, err, domain.ErrSecretNotFound)
+}
+
+func TestGetMetadata_Error(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDB := mocks.NewMockDynamoDBAPI(ctrl)
+	repo := SecretManagerRepository{BaseRepository: repository.BaseRepository{DBConnection: mockDB}, TableName: "secrets"}
+
+	mockDB.EXPECT().GetItem(gomock.Any(), gomock.Any()).Return(nil, errors.New("get error"))
+
+	_, err := repo.GetMetadata(context.Background(), "testhash")
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to retrieve metadata")
+}
+
 func TestGetByHash_Success(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -210,6 +801,44 @@ func TestGetByHash_Success(t *testing.T) {
 	assert.Equal(t, secret, result)
 }
 
+func TestGetByHash_Tenant(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDB := mocks.NewMockDynamoDBAPI(ctrl)
+	repo := SecretManagerRepository{BaseRepository: repository.BaseRepository{DBConnection: mockDB}, TableName: "secrets"}
+
+	// The item of a secret of a tenant is keyed by the tenant and the hash, so is its owner
+	item, _ := attributevalue.MarshalMap(domain.Secret{Hash: "team-a#testhash", Owner: "team-a#apikey:key1", RemainingViews: 1})
+
+	mockDB.EXPECT().GetItem(gomock.Any(), &dynamodb.GetItemInput{
+		TableName: aws.String("secrets"),
+		Key: map[string]types.AttributeValue{
+			"hash": &types.AttributeValueMemberS{Value: "team-a#testhash"},
+		},
+	}).Return(&dynamodb.GetItemOutput{Item: item}, nil)
+
+	result, err := repo.GetByHash(domain.ContextWithTenant(context.Background(), "team-a"), "testhash")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "testhash", result.Hash)
+	assert.Equal(t, "apikey:key1", result.Owner)
+	assert.Equal(t, "team-a", result.Tenant)
+}
+
+func TestGetByHash_TenantInHash(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDB := mocks.NewMockDynamoDBAPI(ctrl)
+	repo := SecretManagerRepository{BaseRepository: repository.BaseRepository{DBConnection: mockDB}, TableName: "secrets"}
+
+	// A hash carrying a tenant is never looked up, the routes of the default tenant can not reach into a tenant
+	_, err := repo.GetByHash(context.Background(), "team-a#testhash")
+
+	assert.ErrorIs(t, err, domain.ErrSecretNotFound)
+}
+
 func TestGetByHash_NotFound(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -252,6 +881,6 @@ func TestGetByHash_Error(t *testing.T) {
 
 	_, err := repo.GetByHash(context.Background(), hash)
 
-	assert.Error(t, err)
+	assert.ErrorIs(t, err, domain.ErrUnavailable)
 	assert.Contains(t, err.Error(), "failed to retrieve item")
 }
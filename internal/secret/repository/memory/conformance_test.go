@@ -0,0 +1,14 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/nalawade41/secret-server/internal/domain"
+	"github.com/nalawade41/secret-server/internal/secret/repository/repositorytest"
+)
+
+func TestConformance(t *testing.T) {
+	repositorytest.Run(t, func(t *testing.T) domain.SecretRepository {
+		return NewSecretManagerRepository()
+	})
+}
@@ -1 +1,315 @@
 package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/nalawade41/secret-server/internal/domain"
+	"github.com/nalawade41/secret-server/internal/secret/repository/pagination"
+	"github.com/nalawade41/secret-server/internal/secret/repository/tenancy"
+	"github.com/pkg/errors"
+)
+
+// SecretManagerRepository keeps secrets in process memory, secrets are lost on restart.
+// Secrets are kept under their key in the tenant they were saved in, the same key the other repositories use.
+type SecretManagerRepository struct {
+	mu      sync.Mutex
+	secrets map[string]domain.Secret
+}
+
+// NewSecretManagerRepository creates an empty in memory secret repository
+func NewSecretManagerRepository() *SecretManagerRepository {
+	return &SecretManagerRepository{
+		secrets: make(map[string]domain.Secret),
+	}
+}
+
+func (s *SecretManagerRepository) Save(ctx context.Context, secret domain.Secret) error {
+	key, ok := tenancy.Key(ctx, secret.Hash)
+	if !ok {
+		return errors.Errorf("invalid secret id %q", secret.Hash)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.secrets[key]; ok {
+		return domain.ErrSecretAlreadyExists
+	}
+
+	secret.Tenant = domain.TenantFromContext(ctx)
+
+	// Owners without a key are not stored, like in the other repositories
+	if tenancy.OwnerKey(ctx, secret.Owner) == "" {
+		secret.Owner = ""
+	}
+
+	// The key, the passphrase, the plain revocation token and the plain file are never persisted,
+	// same as for the other repositories
+	secret.Key = ""
+	secret.Passphrase = ""
+	secret.RevocationToken = ""
+	secret.File = nil
+	secret.Quota = domain.QuotaStatus{}
+	s.secrets[key] = secret
+
+	return nil
+}
+
+func (s *SecretManagerRepository) GetByHash(ctx context.Context, hash string) (domain.Secret, error) {
+	key, ok := tenancy.Key(ctx, hash)
+	if !ok {
+		return domain.Secret{}, domain.ErrSecretNotFound
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	secret, ok := s.secrets[key]
+	if !ok {
+		return domain.Secret{}, domain.ErrSecretNotFound
+	}
+
+	return secret, nil
+}
+
+func (s *SecretManagerRepository) DeleteSecret(ctx context.Context, hash string) error {
+	key, ok := tenancy.Key(ctx, hash)
+	if !ok {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.secrets, key)
+
+	return nil
+}
+
+// GetMetadata returns a copy of the secret without its ciphertext and keys
+func (s *SecretManagerRepository) GetMetadata(ctx context.Context, hash string) (domain.Secret, error) {
+	key, ok := tenancy.Key(ctx, hash)
+	if !ok {
+		return domain.Secret{}, domain.ErrSecretNotFound
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	secret, ok := s.secrets[key]
+	if !ok {
+		return domain.Secret{}, domain.ErrSecretNotFound
+	}
+
+	return withoutCiphertext(secret), nil
+}
+
+// ConsumeView decrements the remaining views under the lock and keeps only a tombstone once the last view is taken
+func (s *SecretManagerRepository) ConsumeView(ctx context.Context, hash string) (domain.Secret, error) {
+	key, ok := tenancy.Key(ctx, hash)
+	if !ok {
+		return domain.Secret{}, domain.ErrSecretNotFound
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	secret, ok := s.secrets[key]
+	if !ok {
+		return domain.Secret{}, domain.ErrSecretNotFound
+	}
+
+	// An expired secret can not be viewed, same as in the repositories that expire secrets through the TTL
+	now := time.Now().UTC()
+	if secret.TTL != 0 && secret.TTL <= now.Unix() {
+		return domain.Secret{}, domain.ErrSecretExpired
+	}
+
+	if secret.RemainingViews <= 0 {
+		return domain.Secret{}, domain.ErrNoRemainingViews
+	}
+
+	secret.RemainingViews--
+	// Copy the read times, earlier copies of the secret must not see the new read
+	secret.ReadAt = append(append([]time.Time(nil), secret.ReadAt...), now)
+
+	if secret.RemainingViews == 0 {
+		// The charge of the secret is given back with its last view, the tombstone holds none
+		tombstone := withoutCiphertext(secret)
+		tombstone.QuotaBytes = 0
+		s.secrets[key] = tombstone
+	} else {
+		s.secrets[key] = secret
+	}
+
+	return secret, nil
+}
+
+// withoutCiphertext strips everything from the secret that could help to decrypt it
+func withoutCiphertext(secret domain.Secret) domain.Secret {
+	secret.SecretText = ""
+	secret.WrappedKey = ""
+	secret.KeyID = ""
+	secret.PassphraseKDF = ""
+	secret.FileName = ""
+	return secret
+}
+
+// UpdateEncryption replaces the ciphertext and wrapped data key under the lock, a tombstone is left as it is
+func (s *SecretManagerRepository) UpdateEncryption(ctx context.Context, update domain.Secret) error {
+	key, ok := tenancy.Key(ctx, update.Hash)
+	if !ok {
+		return domain.ErrSecretNotFound
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	secret, ok := s.secrets[key]
+	if !ok {
+		return domain.ErrSecretNotFound
+	}
+
+	if secret.RemainingViews <= 0 {
+		return domain.ErrNoRemainingViews
+	}
+
+	secret.SecretText = update.SecretText
+	secret.WrappedKey = update.WrappedKey
+	secret.KeyID = update.KeyID
+	s.secrets[key] = secret
+
+	return nil
+}
+
+// RecordFailedAttempt counts a failed passphrase attempt under the lock and deletes the secret at the limit
+func (s *SecretManagerRepository) RecordFailedAttempt(ctx context.Context, hash string, maxAttempts int) (int, error) {
+	key, ok := tenancy.Key(ctx, hash)
+	if !ok {
+		return 0, domain.ErrSecretNotFound
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	secret, ok := s.secrets[key]
+	if !ok {
+		return 0, domain.ErrSecretNotFound
+	}
+
+	secret.FailedAttempts++
+	if secret.FailedAttempts >= maxAttempts {
+		delete(s.secrets, key)
+	} else {
+		s.secrets[key] = secret
+	}
+
+	return secret.FailedAttempts, nil
+}
+
+func (s *SecretManagerRepository) UpdateWrappedKey(ctx context.Context, hash string, oldWrappedKey string, wrappedKey string, keyID string) error {
+	key, ok := tenancy.Key(ctx, hash)
+	if !ok {
+		return domain.ErrSecretChanged
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	secret, ok := s.secrets[key]
+	if !ok || secret.WrappedKey != oldWrappedKey {
+		return domain.ErrSecretChanged
+	}
+
+	secret.WrappedKey = wrappedKey
+	secret.KeyID = keyID
+	s.secrets[key] = secret
+
+	return nil
+}
+
+// ListSecrets returns the secrets of every tenant ordered by their key, the cursor is the key of the last secret of the previous page
+func (s *SecretManagerRepository) ListSecrets(_ context.Context, cursor string, limit int) ([]domain.Secret, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := make([]string, 0, len(s.secrets))
+	for key := range s.secrets {
+		if key > cursor {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var next string
+	if len(keys) > limit {
+		keys = keys[:limit]
+		next = keys[limit-1]
+	}
+
+	secrets := make([]domain.Secret, 0, len(keys))
+	for _, key := range keys {
+		secrets = append(secrets, s.secrets[key])
+	}
+
+	return secrets, next, nil
+}
+
+// ListSecretsByOwner returns the metadata of the secrets of the owner in the tenant of the context ordered by
+// creation time and hash, newest first. The cursor points after the last secret of the previous page.
+func (s *SecretManagerRepository) ListSecretsByOwner(ctx context.Context, owner string, cursor string, limit int) ([]domain.Secret, string, error) {
+	if tenancy.OwnerKey(ctx, owner) == "" {
+		return nil, "", nil
+	}
+
+	var after *pagination.OwnerCursor
+	if cursor != "" {
+		c, err := pagination.DecodeOwnerCursor(cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		if _, ok := tenancy.Key(ctx, c.Hash); !ok {
+			return nil, "", domain.ErrInvalidCursor
+		}
+		after = &c
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tenant := domain.TenantFromContext(ctx)
+	var secrets []domain.Secret
+	for _, secret := range s.secrets {
+		if secret.Tenant != tenant || secret.Owner != owner {
+			continue
+		}
+		if after != nil && !newerThan(*after, secret) {
+			continue
+		}
+		secrets = append(secrets, withoutCiphertext(secret))
+	}
+	sort.Slice(secrets, func(i, j int) bool {
+		return newerThan(pagination.OwnerCursor{CreatedAt: secrets[i].CreatedAt, Hash: secrets[i].Hash}, secrets[j])
+	})
+
+	var next string
+	if len(secrets) > limit {
+		secrets = secrets[:limit]
+		next = pagination.OwnerCursor{CreatedAt: secrets[limit-1].CreatedAt, Hash: secrets[limit-1].Hash}.Encode()
+	}
+
+	return secrets, next, nil
+}
+
+// newerThan reports whether the position comes before the secret in the listing of an owner
+func newerThan(position pagination.OwnerCursor, secret domain.Secret) bool {
+	if !position.CreatedAt.Equal(secret.CreatedAt) {
+		return position.CreatedAt.After(secret.CreatedAt)
+	}
+	return position.Hash > secret.Hash
+}
+
+var _ domain.SecretRepository = (*SecretManagerRepository)(nil)
@@ -0,0 +1,237 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nalawade41/secret-server/internal/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSaveAndGetByHash(t *testing.T) {
+	repo := NewSecretManagerRepository()
+
+	secret := domain.Secret{
+		Hash:            "testhash",
+		Key:             "testkey",
+		RevocationToken: "testtoken",
+		SecretText:      "This is a test secret",
+		ExpiresAt:       time.Now().Add(10 * time.Minute),
+		RemainingViews:  5,
+		CreatedAt:       time.Now().UTC(),
+	}
+
+	err := repo.Save(context.Background(), secret)
+	assert.NoError(t, err)
+
+	result, err := repo.GetByHash(context.Background(), "testhash")
+	assert.NoError(t, err)
+	assert.Equal(t, secret.SecretText, result.SecretText)
+	assert.Empty(t, result.Key, "The key must not be stored")
+	assert.Empty(t, result.RevocationToken, "The revocation token must only be stored hashed")
+
+	// Saving the same hash again must not overwrite the secret
+	err = repo.Save(context.Background(), secret)
+	assert.ErrorIs(t, err, domain.ErrSecretAlreadyExists)
+}
+
+func TestGetByHash_NotFound(t *testing.T) {
+	repo := NewSecretManagerRepository()
+
+	_, err := repo.GetByHash(context.Background(), "missing")
+	assert.ErrorIs(t, err, domain.ErrSecretNotFound)
+}
+
+func TestConsumeView(t *testing.T) {
+	repo := NewSecretManagerRepository()
+
+	err := repo.Save(context.Background(), domain.Secret{Hash: "testhash", SecretText: "envelope", WrappedKey: "wrapped", RemainingViews: 2})
+	assert.NoError(t, err)
+
+	result, err := repo.ConsumeView(context.Background(), "testhash")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.RemainingViews)
+	assert.Len(t, result.ReadAt, 1)
+
+	result, err = repo.ConsumeView(context.Background(), "testhash")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, result.RemainingViews)
+	assert.Equal(t, "envelope", result.SecretText)
+
+	// Only a tombstone with the metadata is left after the last view
+	tombstone, err := repo.GetByHash(context.Background(), "testhash")
+	assert.NoError(t, err)
+	assert.Empty(t, tombstone.SecretText)
+	assert.Empty(t, tombstone.WrappedKey)
+	assert.Len(t, tombstone.ReadAt, 2)
+
+	_, err = repo.ConsumeView(context.Background(), "testhash")
+	assert.ErrorIs(t, err, domain.ErrNoRemainingViews)
+}
+
+func TestGetMetadata(t *testing.T) {
+	repo := NewSecretManagerRepository()
+
+	_, err := repo.GetMetadata(context.Background(), "testhash")
+	assert.ErrorIs(t, err, domain.ErrSecretNotFound)
+
+	err = repo.Save(context.Background(), domain.Secret{Hash: "testhash", SecretText: "envelope", WrappedKey: "wrapped", KeyID: "master", RevocationTokenHash: "tokenhash", RemainingViews: 2})
+	assert.NoError(t, err)
+
+	result, err := repo.GetMetadata(context.Background(), "testhash")
+	assert.NoError(t, err)
+	assert.Empty(t, result.SecretText, "The ciphertext must never be part of the metadata")
+	assert.Empty(t, result.WrappedKey)
+	assert.Equal(t, "tokenhash", result.RevocationTokenHash)
+	assert.Equal(t, 2, result.RemainingViews, "Reading the metadata must not take a view")
+}
+
+func TestConsumeView_NoRemainingViews(t *testing.T) {
+	repo := NewSecretManagerRepository()
+
+	err := repo.Save(context.Background(), domain.Secret{Hash: "testhash", RemainingViews: 0})
+	assert.NoError(t, err)
+
+	_, err = repo.ConsumeView(context.Background(), "testhash")
+	assert.ErrorIs(t, err, domain.ErrNoRemainingViews)
+}
+
+func TestConsumeView_Expired(t *testing.T) {
+	repo := NewSecretManagerRepository()
+
+	// The TTL passed, but nothing deleted the secret yet
+	err := repo.Save(context.Background(), domain.Secret{Hash: "testhash", RemainingViews: 3, TTL: time.Now().Add(-time.Minute).Unix()})
+	assert.NoError(t, err)
+
+	_, err = repo.ConsumeView(context.Background(), "testhash")
+	assert.ErrorIs(t, err, domain.ErrSecretExpired)
+}
+
+func TestConsumeView_Concurrent(t *testing.T) {
+	repo := NewSecretManagerRepository()
+
+	const views = 3
+	err := repo.Save(context.Background(), domain.Secret{Hash: "testhash", RemainingViews: views})
+	assert.NoError(t, err)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	consumed := 0
+
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := repo.ConsumeView(context.Background(), "testhash"); err == nil {
+				mu.Lock()
+				consumed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, views, consumed, "Exactly the created number of views should be consumed")
+}
+
+func TestUpdateEncryptionAndDelete(t *testing.T) {
+	repo := NewSecretManagerRepository()
+	update := domain.Secret{Hash: "testhash", SecretText: "envelope", WrappedKey: "wrapped", KeyID: "master"}
+
+	err := repo.UpdateEncryption(context.Background(), update)
+	assert.ErrorIs(t, err, domain.ErrSecretNotFound)
+
+	err = repo.Save(context.Background(), domain.Secret{Hash: "testhash", SecretText: "legacy", RemainingViews: 1})
+	assert.NoError(t, err)
+
+	err = repo.UpdateEncryption(context.Background(), update)
+	assert.NoError(t, err)
+
+	result, err := repo.GetByHash(context.Background(), "testhash")
+	assert.NoError(t, err)
+	assert.Equal(t, "envelope", result.SecretText)
+	assert.Equal(t, "wrapped", result.WrappedKey)
+	assert.Equal(t, "master", result.KeyID)
+	assert.Equal(t, 1, result.RemainingViews)
+
+	err = repo.DeleteSecret(context.Background(), "testhash")
+	assert.NoError(t, err)
+
+	_, err = repo.GetByHash(context.Background(), "testhash")
+	assert.ErrorIs(t, err, domain.ErrSecretNotFound)
+}
+
+func TestRecordFailedAttempt(t *testing.T) {
+	repo := NewSecretManagerRepository()
+
+	_, err := repo.RecordFailedAttempt(context.Background(), "testhash", 3)
+	assert.ErrorIs(t, err, domain.ErrSecretNotFound)
+
+	err = repo.Save(context.Background(), domain.Secret{Hash: "testhash", RemainingViews: 1})
+	assert.NoError(t, err)
+
+	for expected := 1; expected <= 2; expected++ {
+		attempts, err := repo.RecordFailedAttempt(context.Background(), "testhash", 3)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, attempts)
+	}
+
+	// The attempt that reaches the limit burns the secret
+	attempts, err := repo.RecordFailedAttempt(context.Background(), "testhash", 3)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, attempts)
+
+	_, err = repo.GetByHash(context.Background(), "testhash")
+	assert.ErrorIs(t, err, domain.ErrSecretNotFound)
+}
+
+func TestUpdateWrappedKey(t *testing.T) {
+	repo := NewSecretManagerRepository()
+
+	err := repo.Save(context.Background(), domain.Secret{Hash: "testhash", WrappedKey: "old wrapped", KeyID: "2023", RemainingViews: 1})
+	assert.NoError(t, err)
+
+	// A stale wrapped key means the secret changed since it was read
+	err = repo.UpdateWrappedKey(context.Background(), "testhash", "stale wrapped", "new wrapped", "2024")
+	assert.ErrorIs(t, err, domain.ErrSecretChanged)
+
+	err = repo.UpdateWrappedKey(context.Background(), "testhash", "old wrapped", "new wrapped", "2024")
+	assert.NoError(t, err)
+
+	result, err := repo.GetByHash(context.Background(), "testhash")
+	assert.NoError(t, err)
+	assert.Equal(t, "new wrapped", result.WrappedKey)
+	assert.Equal(t, "2024", result.KeyID)
+
+	// A deleted secret can not be re-wrapped
+	err = repo.UpdateWrappedKey(context.Background(), "missing", "old wrapped", "new wrapped", "2024")
+	assert.ErrorIs(t, err, domain.ErrSecretChanged)
+}
+
+func TestListSecrets(t *testing.T) {
+	repo := NewSecretManagerRepository()
+
+	for _, hash := range []string{"c", "a", "e", "b", "d"} {
+		err := repo.Save(context.Background(), domain.Secret{Hash: hash, RemainingViews: 1})
+		assert.NoError(t, err)
+	}
+
+	// Page through all secrets two at a time
+	var hashes []string
+	cursor := ""
+	for {
+		secrets, next, err := repo.ListSecrets(context.Background(), cursor, 2)
+		assert.NoError(t, err)
+		for _, secret := range secrets {
+			hashes = append(hashes, secret.Hash)
+		}
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+
+	assert.Equal(t, []string{"a", "b", "c", "d", "e"}, hashes)
+}
@@ -0,0 +1,40 @@
+// Package pagination holds the cursors the secret repositories hand out for listings. Cursors are opaque
+// to clients, they are only ever decoded by the repository that encoded them.
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"time"
+
+	"github.com/nalawade41/secret-server/internal/domain"
+)
+
+// OwnerCursor points after the last secret of a page of the secrets of an owner, which are listed newest first.
+// The owner is not part of the cursor, a cursor only ever continues the listing of the caller.
+type OwnerCursor struct {
+	CreatedAt time.Time `json:"createdAt"`
+	Hash      string    `json:"hash"`
+}
+
+// Encode returns the cursor as URL safe text
+func (c OwnerCursor) Encode() string {
+	// Marshalling a struct of a time and a string can not fail
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeOwnerCursor parses a cursor returned by Encode, anything else is rejected with domain.ErrInvalidCursor
+func DecodeOwnerCursor(cursor string) (OwnerCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return OwnerCursor{}, domain.ErrInvalidCursor
+	}
+
+	var c OwnerCursor
+	if err := json.Unmarshal(b, &c); err != nil || c.Hash == "" || c.CreatedAt.IsZero() {
+		return OwnerCursor{}, domain.ErrInvalidCursor
+	}
+
+	return c, nil
+}
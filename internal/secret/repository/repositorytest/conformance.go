@@ -0,0 +1,703 @@
+// Package repositorytest holds the conformance suite every domain.SecretRepository has to pass.
+// The use cases rely on the semantics checked here, a new storage backend runs the suite from its tests:
+//
+//	func TestConformance(t *testing.T) {
+//		repositorytest.Run(t, func(t *testing.T) domain.SecretRepository { return newRepository(t) })
+//	}
+package repositorytest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nalawade41/secret-server/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// concurrentReaders is the number of goroutines racing for the views of a secret
+const concurrentReaders = 20
+
+// Factory returns the repository under test. It may return the same repository for every test,
+// the tests only work on secrets with their own random hashes.
+type Factory func(t *testing.T) domain.SecretRepository
+
+// Run runs the conformance suite against the repositories created by newRepository
+func Run(t *testing.T, newRepository Factory) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, repo domain.SecretRepository)
+	}{
+		{"SaveAndGetByHash", testSaveAndGetByHash},
+		{"SaveAlreadyExists", testSaveAlreadyExists},
+		{"NotFound", testNotFound},
+		{"DeleteSecret", testDeleteSecret},
+		{"GetMetadata", testGetMetadata},
+		{"ConsumeView", testConsumeView},
+		{"ConsumeViewRace", testConsumeViewRace},
+		{"ConsumeViewExpired", testConsumeViewExpired},
+		{"ConsumeViewWithoutTTL", testConsumeViewWithoutTTL},
+		{"UpdateEncryption", testUpdateEncryption},
+		{"UpdateEncryptionRace", testUpdateEncryptionRace},
+		{"RecordFailedAttempt", testRecordFailedAttempt},
+		{"RecordFailedAttemptRace", testRecordFailedAttemptRace},
+		{"UpdateWrappedKey", testUpdateWrappedKey},
+		{"ListSecrets", testListSecrets},
+		{"ListSecretsByOwner", testListSecretsByOwner},
+		{"ListSecretsByOwnerInvalidCursor", testListSecretsByOwnerInvalidCursor},
+		{"TenantIsolation", testTenantIsolation},
+		{"ListSecretsByOwnerOfTenant", testListSecretsByOwnerOfTenant},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newRepository(t))
+		})
+	}
+}
+
+// newHash returns a random hash, so tests sharing a repository never see each other's secrets
+func newHash(t *testing.T) string {
+	b := make([]byte, 12)
+	_, err := rand.Read(b)
+	require.NoError(t, err)
+	return hex.EncodeToString(b)
+}
+
+// newSecret returns a secret with every persisted field set, it expires in an hour
+func newSecret(t *testing.T, views int) domain.Secret {
+	// Backends store times with different precision, whole seconds survive all of them
+	now := time.Now().UTC().Truncate(time.Second)
+	return domain.Secret{
+		Hash:                newHash(t),
+		Key:                 "linkkey",
+		Passphrase:          "passphrase",
+		RevocationToken:     "revocationtoken",
+		RevocationTokenHash: "revocationtokenhash",
+		SecretText:          "envelope",
+		WrappedKey:          "wrappedkey",
+		KeyID:               "master",
+		PassphraseKDF:       "kdf",
+		Algorithm:           "AES-256-GCM",
+		CreatedAt:           now,
+		ExpiresAt:           now.Add(time.Hour),
+		TTL:                 now.Add(time.Hour).Unix(),
+		RemainingViews:      views,
+		BlobKey:             "blobkey",
+		FileName:            "encrypted file name",
+		FileContentType:     "application/x-pem-file",
+		Owner:               "apikey:" + newHash(t),
+		QuotaBytes:          42,
+		File:                &domain.File{Name: "server.pem", ContentType: "application/x-pem-file", Size: 10},
+	}
+}
+
+// save stores the secret and fails the test right away if that does not work
+func save(t *testing.T, repo domain.SecretRepository, secret domain.Secret) {
+	require.NoError(t, repo.Save(context.Background(), secret))
+}
+
+func testSaveAndGetByHash(t *testing.T, repo domain.SecretRepository) {
+	secret := newSecret(t, 3)
+	save(t, repo, secret)
+
+	stored, err := repo.GetByHash(context.Background(), secret.Hash)
+	require.NoError(t, err)
+
+	assert.Equal(t, secret.Hash, stored.Hash)
+	assert.Equal(t, secret.RevocationTokenHash, stored.RevocationTokenHash)
+	assert.Equal(t, secret.SecretText, stored.SecretText)
+	assert.Equal(t, secret.WrappedKey, stored.WrappedKey)
+	assert.Equal(t, secret.KeyID, stored.KeyID)
+	assert.Equal(t, secret.PassphraseKDF, stored.PassphraseKDF)
+	assert.Equal(t, secret.Algorithm, stored.Algorithm)
+	assert.True(t, secret.CreatedAt.Equal(stored.CreatedAt), "created at %v, stored %v", secret.CreatedAt, stored.CreatedAt)
+	assert.True(t, secret.ExpiresAt.Equal(stored.ExpiresAt), "expires at %v, stored %v", secret.ExpiresAt, stored.ExpiresAt)
+	assert.Equal(t, secret.TTL, stored.TTL)
+	assert.Equal(t, secret.RemainingViews, stored.RemainingViews)
+	assert.Equal(t, secret.BlobKey, stored.BlobKey)
+	assert.Equal(t, secret.FileName, stored.FileName)
+	assert.Equal(t, secret.FileContentType, stored.FileContentType)
+	assert.Equal(t, secret.Owner, stored.Owner)
+	assert.Equal(t, secret.QuotaBytes, stored.QuotaBytes)
+	assert.Zero(t, stored.FailedAttempts)
+	assert.Empty(t, stored.ReadAt)
+
+	// Whoever reads the storage must not be able to decrypt the secret or revoke it
+	assert.Empty(t, stored.Key, "the link key must never be persisted")
+	assert.Empty(t, stored.Passphrase, "the passphrase must never be persisted")
+	assert.Empty(t, stored.RevocationToken, "the revocation token must only be persisted hashed")
+	assert.Nil(t, stored.File, "the plain file must never be persisted")
+}
+
+func testSaveAlreadyExists(t *testing.T, repo domain.SecretRepository) {
+	secret := newSecret(t, 1)
+	save(t, repo, secret)
+
+	overwrite := secret
+	overwrite.SecretText = "another envelope"
+	err := repo.Save(context.Background(), overwrite)
+	assert.ErrorIs(t, err, domain.ErrSecretAlreadyExists)
+
+	// The first secret is untouched
+	stored, err := repo.GetByHash(context.Background(), secret.Hash)
+	require.NoError(t, err)
+	assert.Equal(t, secret.SecretText, stored.SecretText)
+}
+
+func testNotFound(t *testing.T, repo domain.SecretRepository) {
+	ctx := context.Background()
+	hash := newHash(t)
+
+	_, err := repo.GetByHash(ctx, hash)
+	assert.ErrorIs(t, err, domain.ErrSecretNotFound, "GetByHash")
+
+	_, err = repo.GetMetadata(ctx, hash)
+	assert.ErrorIs(t, err, domain.ErrSecretNotFound, "GetMetadata")
+
+	_, err = repo.ConsumeView(ctx, hash)
+	assert.ErrorIs(t, err, domain.ErrSecretNotFound, "ConsumeView")
+
+	err = repo.UpdateEncryption(ctx, domain.Secret{Hash: hash, SecretText: "envelope", WrappedKey: "wrappedkey", KeyID: "master"})
+	assert.ErrorIs(t, err, domain.ErrSecretNotFound, "UpdateEncryption")
+
+	_, err = repo.RecordFailedAttempt(ctx, hash, 5)
+	assert.ErrorIs(t, err, domain.ErrSecretNotFound, "RecordFailedAttempt")
+
+	// A re-wrap can not tell a deleted secret from a changed one, both must not be written
+	err = repo.UpdateWrappedKey(ctx, hash, "wrappedkey", "rewrapped", "new")
+	assert.ErrorIs(t, err, domain.ErrSecretChanged, "UpdateWrappedKey")
+
+	// None of the calls above may have created the secret
+	_, err = repo.GetByHash(ctx, hash)
+	assert.ErrorIs(t, err, domain.ErrSecretNotFound, "GetByHash after updates")
+
+	// Deleting is idempotent
+	assert.NoError(t, repo.DeleteSecret(ctx, hash), "DeleteSecret")
+}
+
+func testDeleteSecret(t *testing.T, repo domain.SecretRepository) {
+	secret := newSecret(t, 2)
+	save(t, repo, secret)
+
+	require.NoError(t, repo.DeleteSecret(context.Background(), secret.Hash))
+
+	_, err := repo.GetByHash(context.Background(), secret.Hash)
+	assert.ErrorIs(t, err, domain.ErrSecretNotFound)
+
+	_, err = repo.ConsumeView(context.Background(), secret.Hash)
+	assert.ErrorIs(t, err, domain.ErrSecretNotFound)
+}
+
+func testGetMetadata(t *testing.T, repo domain.SecretRepository) {
+	secret := newSecret(t, 2)
+	save(t, repo, secret)
+
+	_, err := repo.ConsumeView(context.Background(), secret.Hash)
+	require.NoError(t, err)
+
+	metadata, err := repo.GetMetadata(context.Background(), secret.Hash)
+	require.NoError(t, err)
+
+	assert.Equal(t, secret.Hash, metadata.Hash)
+	assert.Equal(t, secret.RevocationTokenHash, metadata.RevocationTokenHash)
+	assert.Equal(t, secret.Owner, metadata.Owner)
+	assert.True(t, secret.CreatedAt.Equal(metadata.CreatedAt))
+	assert.True(t, secret.ExpiresAt.Equal(metadata.ExpiresAt))
+	assert.Equal(t, 1, metadata.RemainingViews)
+	assert.Len(t, metadata.ReadAt, 1)
+
+	// The status read never carries anything that helps to decrypt the secret
+	assert.Empty(t, metadata.SecretText)
+	assert.Empty(t, metadata.WrappedKey)
+	assert.Empty(t, metadata.KeyID)
+	assert.Empty(t, metadata.PassphraseKDF)
+	assert.Empty(t, metadata.FileName)
+
+	// Reading the metadata does not take a view
+	stored, err := repo.GetByHash(context.Background(), secret.Hash)
+	require.NoError(t, err)
+	assert.Equal(t, 1, stored.RemainingViews)
+}
+
+func testConsumeView(t *testing.T, repo domain.SecretRepository) {
+	ctx := context.Background()
+	secret := newSecret(t, 2)
+	save(t, repo, secret)
+
+	before := time.Now().UTC().Add(-time.Second)
+
+	viewed, err := repo.ConsumeView(ctx, secret.Hash)
+	require.NoError(t, err)
+	assert.Equal(t, 1, viewed.RemainingViews)
+	assert.Equal(t, secret.SecretText, viewed.SecretText)
+	assert.Equal(t, secret.WrappedKey, viewed.WrappedKey)
+	require.Len(t, viewed.ReadAt, 1)
+	assert.WithinDuration(t, time.Now().UTC(), viewed.ReadAt[0], time.Minute)
+	assert.True(t, viewed.ReadAt[0].After(before))
+
+	// The last view still returns everything needed to decrypt the secret
+	viewed, err = repo.ConsumeView(ctx, secret.Hash)
+	require.NoError(t, err)
+	assert.Equal(t, 0, viewed.RemainingViews)
+	assert.Equal(t, secret.SecretText, viewed.SecretText)
+	assert.Equal(t, secret.WrappedKey, viewed.WrappedKey)
+	assert.Equal(t, secret.BlobKey, viewed.BlobKey)
+	assert.Equal(t, secret.FileName, viewed.FileName)
+	assert.Len(t, viewed.ReadAt, 2)
+
+	// Only a tombstone is left, it keeps the metadata for the status of the creator
+	tombstone, err := repo.GetByHash(ctx, secret.Hash)
+	require.NoError(t, err)
+	assert.Equal(t, 0, tombstone.RemainingViews)
+	assert.Empty(t, tombstone.SecretText)
+	assert.Empty(t, tombstone.WrappedKey)
+	assert.Empty(t, tombstone.KeyID)
+	assert.Empty(t, tombstone.PassphraseKDF)
+	assert.Empty(t, tombstone.FileName)
+	// The charge was given back with the last view, so the tombstone holds none
+	assert.Zero(t, tombstone.QuotaBytes)
+	assert.Equal(t, secret.RevocationTokenHash, tombstone.RevocationTokenHash)
+	assert.Len(t, tombstone.ReadAt, 2)
+
+	_, err = repo.ConsumeView(ctx, secret.Hash)
+	assert.ErrorIs(t, err, domain.ErrNoRemainingViews)
+}
+
+func testConsumeViewRace(t *testing.T, repo domain.SecretRepository) {
+	const views = 5
+	secret := newSecret(t, views)
+	save(t, repo, secret)
+
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		taken int
+		other []error
+	)
+	for i := 0; i < concurrentReaders; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			_, err := repo.ConsumeView(context.Background(), secret.Hash)
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				taken++
+			case !errors.Is(err, domain.ErrNoRemainingViews):
+				other = append(other, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Concurrent readers never take more views than the secret was created with, and every view is taken
+	assert.Equal(t, views, taken)
+	assert.Empty(t, other, "readers that lost the race must get ErrNoRemainingViews")
+
+	metadata, err := repo.GetMetadata(context.Background(), secret.Hash)
+	require.NoError(t, err)
+	assert.Equal(t, 0, metadata.RemainingViews)
+	assert.Len(t, metadata.ReadAt, views)
+}
+
+func testConsumeViewExpired(t *testing.T, repo domain.SecretRepository) {
+	// The secret expired, but the storage did not delete it yet
+	secret := newSecret(t, 3)
+	secret.ExpiresAt = time.Now().UTC().Add(-time.Minute).Truncate(time.Second)
+	secret.TTL = secret.ExpiresAt.Unix()
+	save(t, repo, secret)
+
+	_, err := repo.ConsumeView(context.Background(), secret.Hash)
+	assert.ErrorIs(t, err, domain.ErrSecretExpired)
+
+	// No view was taken
+	metadata, err := repo.GetMetadata(context.Background(), secret.Hash)
+	require.NoError(t, err)
+	assert.Equal(t, 3, metadata.RemainingViews)
+	assert.Empty(t, metadata.ReadAt)
+}
+
+func testConsumeViewWithoutTTL(t *testing.T, repo domain.SecretRepository) {
+	// Secrets stored before the TTL was introduced have none, they stay readable
+	secret := newSecret(t, 1)
+	secret.TTL = 0
+	save(t, repo, secret)
+
+	viewed, err := repo.ConsumeView(context.Background(), secret.Hash)
+	require.NoError(t, err)
+	assert.Equal(t, 0, viewed.RemainingViews)
+}
+
+func testUpdateEncryption(t *testing.T, repo domain.SecretRepository) {
+	secret := newSecret(t, 2)
+	save(t, repo, secret)
+
+	err := repo.UpdateEncryption(context.Background(), domain.Secret{Hash: secret.Hash, SecretText: "new envelope", WrappedKey: "newkey", KeyID: "new"})
+	require.NoError(t, err)
+
+	stored, err := repo.GetByHash(context.Background(), secret.Hash)
+	require.NoError(t, err)
+	assert.Equal(t, "new envelope", stored.SecretText)
+	assert.Equal(t, "newkey", stored.WrappedKey)
+	assert.Equal(t, "new", stored.KeyID)
+
+	// Everything else is left as it was
+	assert.Equal(t, secret.RemainingViews, stored.RemainingViews)
+	assert.Equal(t, secret.RevocationTokenHash, stored.RevocationTokenHash)
+	assert.Equal(t, secret.PassphraseKDF, stored.PassphraseKDF)
+
+	// Test case: The tombstone of a fully viewed secret gets no ciphertext back
+	_, err = repo.ConsumeView(context.Background(), secret.Hash)
+	require.NoError(t, err)
+	_, err = repo.ConsumeView(context.Background(), secret.Hash)
+	require.NoError(t, err)
+
+	err = repo.UpdateEncryption(context.Background(), domain.Secret{Hash: secret.Hash, SecretText: "late envelope", WrappedKey: "latekey", KeyID: "late"})
+	assert.ErrorIs(t, err, domain.ErrNoRemainingViews)
+
+	tombstone, err := repo.GetByHash(context.Background(), secret.Hash)
+	require.NoError(t, err)
+	assert.Empty(t, tombstone.SecretText)
+	assert.Empty(t, tombstone.WrappedKey)
+	assert.Empty(t, tombstone.KeyID)
+}
+
+func testUpdateEncryptionRace(t *testing.T, repo domain.SecretRepository) {
+	for i := 0; i < concurrentReaders; i++ {
+		secret := newSecret(t, 1)
+		save(t, repo, secret)
+
+		// The re-encryption of a read races the last view of another read
+		var (
+			wg         sync.WaitGroup
+			consumeErr error
+			updateErr  error
+		)
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_, consumeErr = repo.ConsumeView(context.Background(), secret.Hash)
+		}()
+		go func() {
+			defer wg.Done()
+			updateErr = repo.UpdateEncryption(context.Background(), domain.Secret{Hash: secret.Hash, SecretText: "new envelope", WrappedKey: "newkey", KeyID: "new"})
+		}()
+		wg.Wait()
+
+		require.NoError(t, consumeErr)
+		if updateErr != nil {
+			assert.ErrorIs(t, updateErr, domain.ErrNoRemainingViews)
+		}
+
+		// Whichever came first, the tombstone never holds a ciphertext
+		tombstone, err := repo.GetByHash(context.Background(), secret.Hash)
+		require.NoError(t, err)
+		assert.Equal(t, 0, tombstone.RemainingViews)
+		assert.Empty(t, tombstone.SecretText)
+		assert.Empty(t, tombstone.WrappedKey)
+		assert.Empty(t, tombstone.KeyID)
+	}
+}
+
+func testRecordFailedAttempt(t *testing.T, repo domain.SecretRepository) {
+	ctx := context.Background()
+	secret := newSecret(t, 1)
+	save(t, repo, secret)
+
+	attempts, err := repo.RecordFailedAttempt(ctx, secret.Hash, 3)
+	require.NoError(t, err)
+	assert.Equal(t, 1, attempts)
+
+	attempts, err = repo.RecordFailedAttempt(ctx, secret.Hash, 3)
+	require.NoError(t, err)
+	assert.Equal(t, 2, attempts)
+
+	// A failed attempt does not take a view
+	stored, err := repo.GetByHash(ctx, secret.Hash)
+	require.NoError(t, err)
+	assert.Equal(t, 2, stored.FailedAttempts)
+	assert.Equal(t, 1, stored.RemainingViews)
+
+	// The last attempt burns the secret
+	attempts, err = repo.RecordFailedAttempt(ctx, secret.Hash, 3)
+	require.NoError(t, err)
+	assert.Equal(t, 3, attempts)
+
+	_, err = repo.GetByHash(ctx, secret.Hash)
+	assert.ErrorIs(t, err, domain.ErrSecretNotFound)
+}
+
+func testRecordFailedAttemptRace(t *testing.T, repo domain.SecretRepository) {
+	const maxAttempts = 3
+	secret := newSecret(t, 1)
+	save(t, repo, secret)
+
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		other []error
+	)
+	for i := 0; i < concurrentReaders; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			_, err := repo.RecordFailedAttempt(context.Background(), secret.Hash, maxAttempts)
+			if err != nil && !errors.Is(err, domain.ErrSecretNotFound) {
+				mu.Lock()
+				other = append(other, err)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Empty(t, other, "guesses after the secret was burned must get ErrSecretNotFound")
+
+	// However the guesses interleave, the secret is burned and can not be read anymore
+	_, err := repo.GetByHash(context.Background(), secret.Hash)
+	assert.ErrorIs(t, err, domain.ErrSecretNotFound)
+
+	_, err = repo.ConsumeView(context.Background(), secret.Hash)
+	assert.ErrorIs(t, err, domain.ErrSecretNotFound)
+}
+
+func testUpdateWrappedKey(t *testing.T, repo domain.SecretRepository) {
+	ctx := context.Background()
+	secret := newSecret(t, 1)
+	save(t, repo, secret)
+
+	require.NoError(t, repo.UpdateWrappedKey(ctx, secret.Hash, secret.WrappedKey, "rewrapped", "new"))
+
+	// The wrapped key changed in the meantime, a second re-wrap from the old key is refused
+	err := repo.UpdateWrappedKey(ctx, secret.Hash, secret.WrappedKey, "rewrapped again", "newer")
+	assert.ErrorIs(t, err, domain.ErrSecretChanged)
+
+	stored, err := repo.GetByHash(ctx, secret.Hash)
+	require.NoError(t, err)
+	assert.Equal(t, "rewrapped", stored.WrappedKey)
+	assert.Equal(t, "new", stored.KeyID)
+	assert.Equal(t, secret.SecretText, stored.SecretText)
+}
+
+func testListSecrets(t *testing.T, repo domain.SecretRepository) {
+	ctx := context.Background()
+
+	saved := make(map[string]bool)
+	for i := 0; i < 5; i++ {
+		secret := newSecret(t, 1)
+		save(t, repo, secret)
+		saved[secret.Hash] = true
+	}
+
+	// The repository may hold secrets of other tests, the pages have to contain each secret exactly once
+	seen := make(map[string]int)
+	cursor := ""
+	for page := 0; ; page++ {
+		require.Less(t, page, 1000, "listing does not finish")
+
+		secrets, next, err := repo.ListSecrets(ctx, cursor, 2)
+		require.NoError(t, err)
+		assert.LessOrEqual(t, len(secrets), 2, "a page must not hold more secrets than the limit")
+
+		for _, secret := range secrets {
+			seen[secret.Hash]++
+		}
+
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+
+	for hash := range saved {
+		assert.Equal(t, 1, seen[hash], "secret %s listed %d times", hash, seen[hash])
+	}
+}
+
+func testListSecretsByOwner(t *testing.T, repo domain.SecretRepository) {
+	ctx := context.Background()
+
+	// Secrets of the owner, two of them created in the same second, and a secret of someone else
+	owner := "user:" + newHash(t)
+	var hashes []string
+	for i, age := range []time.Duration{3 * time.Hour, 2 * time.Hour, 2 * time.Hour, time.Hour, 0} {
+		secret := newSecret(t, 1)
+		secret.Owner = owner
+		secret.CreatedAt = secret.CreatedAt.Add(-age)
+		if i == 4 {
+			// The newest secret was already read, it is still listed
+			secret.RemainingViews = 0
+		}
+		save(t, repo, secret)
+		hashes = append(hashes, secret.Hash)
+	}
+	save(t, repo, newSecret(t, 1))
+
+	var listed []domain.Secret
+	cursor := ""
+	for page := 0; ; page++ {
+		require.Less(t, page, 10, "listing does not finish")
+
+		secrets, next, err := repo.ListSecretsByOwner(ctx, owner, cursor, 2)
+		require.NoError(t, err)
+		assert.LessOrEqual(t, len(secrets), 2, "a page must not hold more secrets than the limit")
+		listed = append(listed, secrets...)
+
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+
+	require.Len(t, listed, len(hashes), "every secret of the owner is listed once, no secret of anyone else")
+	for i, secret := range listed {
+		assert.Equal(t, owner, secret.Owner)
+		if i > 0 {
+			assert.False(t, secret.CreatedAt.After(listed[i-1].CreatedAt), "secrets are listed newest first")
+		}
+
+		// The listing never carries anything that helps to decrypt the secret
+		assert.Empty(t, secret.SecretText)
+		assert.Empty(t, secret.WrappedKey)
+		assert.Empty(t, secret.PassphraseKDF)
+		assert.Empty(t, secret.FileName)
+	}
+	assert.Equal(t, hashes[4], listed[0].Hash)
+	assert.Equal(t, 0, listed[0].RemainingViews)
+	assert.Equal(t, hashes[0], listed[4].Hash)
+	assert.ElementsMatch(t, hashes[1:3], []string{listed[2].Hash, listed[3].Hash})
+
+	// Someone without secrets gets an empty page
+	secrets, next, err := repo.ListSecretsByOwner(ctx, "user:"+newHash(t), "", 10)
+	require.NoError(t, err)
+	assert.Empty(t, secrets)
+	assert.Empty(t, next)
+}
+
+func testListSecretsByOwnerInvalidCursor(t *testing.T, repo domain.SecretRepository) {
+	_, _, err := repo.ListSecretsByOwner(context.Background(), "user:"+newHash(t), "not a cursor", 10)
+	assert.ErrorIs(t, err, domain.ErrInvalidCursor)
+}
+
+// newTenant returns the context of a random tenant, so tests sharing a repository never share a tenant
+func newTenant(t *testing.T) (string, context.Context) {
+	tenant := "tenant-" + newHash(t)
+	return tenant, domain.ContextWithTenant(context.Background(), tenant)
+}
+
+func testTenantIsolation(t *testing.T, repo domain.SecretRepository) {
+	tenant, ctx := newTenant(t)
+	_, otherCtx := newTenant(t)
+
+	secret := newSecret(t, 3)
+	require.NoError(t, repo.Save(ctx, secret))
+
+	stored, err := repo.GetByHash(ctx, secret.Hash)
+	require.NoError(t, err)
+	assert.Equal(t, secret.Hash, stored.Hash, "the hash is returned without its tenant")
+	assert.Equal(t, secret.Owner, stored.Owner, "the owner is returned without its tenant")
+	assert.Equal(t, tenant, stored.Tenant)
+
+	// Neither another tenant nor the default tenant find the secret, not even with the tenant in the hash
+	for name, lookup := range map[string]struct {
+		ctx  context.Context
+		hash string
+	}{
+		"other tenant":           {otherCtx, secret.Hash},
+		"default tenant":         {context.Background(), secret.Hash},
+		"tenant in default hash": {context.Background(), fmt.Sprintf("%s#%s", tenant, secret.Hash)},
+	} {
+		_, err := repo.GetByHash(lookup.ctx, lookup.hash)
+		assert.ErrorIs(t, err, domain.ErrSecretNotFound, name)
+
+		_, err = repo.GetMetadata(lookup.ctx, lookup.hash)
+		assert.ErrorIs(t, err, domain.ErrSecretNotFound, name)
+
+		_, err = repo.ConsumeView(lookup.ctx, lookup.hash)
+		assert.ErrorIs(t, err, domain.ErrSecretNotFound, name)
+
+		_, err = repo.RecordFailedAttempt(lookup.ctx, lookup.hash, 1)
+		assert.ErrorIs(t, err, domain.ErrSecretNotFound, name)
+
+		err = repo.UpdateEncryption(lookup.ctx, domain.Secret{Hash: lookup.hash, SecretText: "other"})
+		assert.ErrorIs(t, err, domain.ErrSecretNotFound, name)
+
+		err = repo.UpdateWrappedKey(lookup.ctx, lookup.hash, secret.WrappedKey, "other", "other")
+		assert.ErrorIs(t, err, domain.ErrSecretChanged, name)
+
+		assert.NoError(t, repo.DeleteSecret(lookup.ctx, lookup.hash), name)
+	}
+
+	// The same id is free in another tenant
+	require.NoError(t, repo.Save(otherCtx, secret))
+
+	stored, err = repo.GetByHash(ctx, secret.Hash)
+	require.NoError(t, err)
+	assert.Equal(t, secret.SecretText, stored.SecretText)
+	assert.Equal(t, secret.WrappedKey, stored.WrappedKey)
+	assert.Zero(t, stored.FailedAttempts)
+	assert.Equal(t, 3, stored.RemainingViews)
+
+	// The listing of all secrets tells the tenants apart
+	tenants := make(map[string]bool)
+	cursor := ""
+	for page := 0; ; page++ {
+		require.Less(t, page, 1000, "listing does not finish")
+
+		secrets, next, err := repo.ListSecrets(context.Background(), cursor, 50)
+		require.NoError(t, err)
+		for _, listed := range secrets {
+			if listed.Hash == secret.Hash {
+				tenants[listed.Tenant] = true
+			}
+		}
+
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+	assert.True(t, tenants[tenant], "the secret is listed with its tenant")
+	assert.Len(t, tenants, 2)
+}
+
+func testListSecretsByOwnerOfTenant(t *testing.T, repo domain.SecretRepository) {
+	_, ctx := newTenant(t)
+	_, otherCtx := newTenant(t)
+
+	// The owner has a secret in two tenants and in the default tenant
+	owner := "user:" + newHash(t)
+	var hashes []string
+	for _, tenantCtx := range []context.Context{ctx, otherCtx, context.Background()} {
+		secret := newSecret(t, 1)
+		secret.Owner = owner
+		require.NoError(t, repo.Save(tenantCtx, secret))
+		hashes = append(hashes, secret.Hash)
+	}
+
+	for i, tenantCtx := range []context.Context{ctx, otherCtx, context.Background()} {
+		secrets, next, err := repo.ListSecretsByOwner(tenantCtx, owner, "", 10)
+		require.NoError(t, err)
+		assert.Empty(t, next)
+		if assert.Len(t, secrets, 1, "only the secret of the tenant is listed") {
+			assert.Equal(t, hashes[i], secrets[0].Hash)
+			assert.Equal(t, owner, secrets[0].Owner)
+		}
+	}
+
+	// An owner containing the separator of the keys never reaches into another tenant
+	secrets, _, err := repo.ListSecretsByOwner(ctx, owner+"#other", "", 10)
+	require.NoError(t, err)
+	assert.Empty(t, secrets)
+}
@@ -0,0 +1,14 @@
+package sqldb
+
+import (
+	"testing"
+
+	"github.com/nalawade41/secret-server/internal/domain"
+	"github.com/nalawade41/secret-server/internal/secret/repository/repositorytest"
+)
+
+func TestConformance_SQLite(t *testing.T) {
+	repositorytest.Run(t, func(t *testing.T) domain.SecretRepository {
+		return newTestRepository(t)
+	})
+}
@@ -0,0 +1,472 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nalawade41/secret-server/internal/domain"
+	"github.com/nalawade41/secret-server/internal/secret/repository/pagination"
+	"github.com/nalawade41/secret-server/internal/secret/repository/tenancy"
+	"github.com/pkg/errors"
+)
+
+// Dialect is the SQL database the repository talks to, the dialects only differ in their placeholders
+type Dialect int
+
+const (
+	SQLite Dialect = iota
+	Postgres
+)
+
+// secretColumns are the columns of a full secret in the order scanSecret reads them
+const secretColumns = "hash, revocation_token_hash, secret_text, wrapped_key, key_id, passphrase_kdf, failed_attempts, " +
+	"client_encrypted, algorithm, created_at, expires_at, ttl, remaining_views, read_at, blob_key, file_name, file_content_type, owner, quota_bytes"
+
+// metadataColumns leaves out the ciphertext and the keys, in the order scanMetadata reads them
+const metadataColumns = "hash, created_at, expires_at, remaining_views, read_at, revocation_token_hash, owner"
+
+// Rebind replaces the ? placeholders of the query with the numbered placeholders PostgreSQL expects
+func (d Dialect) Rebind(query string) string {
+	if d != Postgres {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// SecretManagerRepository stores secrets in a table of an SQLite or PostgreSQL database
+type SecretManagerRepository struct {
+	DB        *sql.DB
+	Dialect   Dialect
+	TableName string
+}
+
+// querier is implemented by *sql.DB and *sql.Tx, reads work inside and outside a transaction
+type querier interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// scanner is implemented by *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// query fills the table name into the query and adapts its placeholders to the dialect
+func (s SecretManagerRepository) query(format string) string {
+	return s.Dialect.Rebind(fmt.Sprintf(format, s.TableName))
+}
+
+// Save inserts the secret under its key in the tenant of the context, the owner is keyed to the tenant as well
+func (s SecretManagerRepository) Save(ctx context.Context, secret domain.Secret) error {
+	key, ok := tenancy.Key(ctx, secret.Hash)
+	if !ok {
+		return errors.Errorf("invalid secret id %q", secret.Hash)
+	}
+
+	readAt, err := marshalReadAt(secret.ReadAt)
+	if err != nil {
+		return err
+	}
+
+	// The conflict clause turns a taken id into zero inserted rows instead of a driver specific error.
+	// The key, the passphrase and the plain revocation token have no column and are never persisted.
+	result, err := s.DB.ExecContext(ctx, s.query("INSERT INTO %s ("+secretColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT (hash) DO NOTHING"),
+		key, secret.RevocationTokenHash, secret.SecretText, secret.WrappedKey, secret.KeyID, secret.PassphraseKDF, secret.FailedAttempts,
+		secret.ClientEncrypted, secret.Algorithm, secret.CreatedAt.UTC(), secret.ExpiresAt.UTC(), secret.TTL, secret.RemainingViews, readAt,
+		secret.BlobKey, secret.FileName, secret.FileContentType, tenancy.OwnerKey(ctx, secret.Owner), secret.QuotaBytes)
+	if err != nil {
+		return errors.Wrap(domain.Unavailable(err), fmt.Sprintf("failed to insert secret: %v", err))
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return errors.Wrap(domain.Unavailable(err), fmt.Sprintf("failed to insert secret: %v", err))
+	}
+	if rows == 0 {
+		return errors.Wrap(domain.ErrSecretAlreadyExists, fmt.Sprintf("failed to insert secret for hash: %s", secret.Hash))
+	}
+
+	return nil
+}
+
+func (s SecretManagerRepository) GetByHash(ctx context.Context, hash string) (domain.Secret, error) {
+	key, ok := tenancy.Key(ctx, hash)
+	if !ok {
+		return domain.Secret{}, domain.ErrSecretNotFound
+	}
+
+	return s.getByKey(ctx, s.DB, key)
+}
+
+// getByKey reads the full secret with the querier, so it can be part of a transaction
+func (s SecretManagerRepository) getByKey(ctx context.Context, q querier, key string) (domain.Secret, error) {
+	secret, err := scanSecret(q.QueryRowContext(ctx, s.query("SELECT "+secretColumns+" FROM %s WHERE hash = ?"), key))
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.Secret{}, domain.ErrSecretNotFound
+	}
+	if err != nil {
+		return domain.Secret{}, errors.Wrap(domain.Unavailable(err), fmt.Sprintf("failed to retrieve secret for key: %s", key))
+	}
+
+	return secret, nil
+}
+
+// GetMetadata only selects the columns of the status, the ciphertext and the keys never leave the database
+func (s SecretManagerRepository) GetMetadata(ctx context.Context, hash string) (domain.Secret, error) {
+	key, ok := tenancy.Key(ctx, hash)
+	if !ok {
+		return domain.Secret{}, domain.ErrSecretNotFound
+	}
+
+	secret, err := scanMetadata(s.DB.QueryRowContext(ctx, s.query("SELECT "+metadataColumns+" FROM %s WHERE hash = ?"), key))
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.Secret{}, domain.ErrSecretNotFound
+	}
+	if err != nil {
+		return domain.Secret{}, errors.Wrap(domain.Unavailable(err), fmt.Sprintf("failed to retrieve metadata for hash: %s", hash))
+	}
+
+	return secret, nil
+}
+
+func (s SecretManagerRepository) DeleteSecret(ctx context.Context, hash string) error {
+	// A hash without a key has no secret to delete
+	key, ok := tenancy.Key(ctx, hash)
+	if !ok {
+		return nil
+	}
+
+	if _, err := s.DB.ExecContext(ctx, s.query("DELETE FROM %s WHERE hash = ?"), key); err != nil {
+		return errors.Wrap(domain.Unavailable(err), fmt.Sprintf("failed to delete secret for hash: %s", hash))
+	}
+
+	return nil
+}
+
+// ConsumeView decrements the remaining views in a transaction, the condition of the update keeps concurrent
+// readers from taking more views than the secret was created with. The read time is appended in the same
+// transaction and the ciphertext is removed with the last view, leaving a tombstone with the metadata.
+func (s SecretManagerRepository) ConsumeView(ctx context.Context, hash string) (domain.Secret, error) {
+	key, ok := tenancy.Key(ctx, hash)
+	if !ok {
+		return domain.Secret{}, domain.ErrSecretNotFound
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return domain.Secret{}, errors.Wrap(domain.Unavailable(err), fmt.Sprintf("failed to begin transaction: %v", err))
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	result, err := tx.ExecContext(ctx, s.query("UPDATE %s SET remaining_views = remaining_views - 1 WHERE hash = ? AND remaining_views > 0 AND (ttl = 0 OR ttl > ?)"),
+		key, now.Unix())
+	if err != nil {
+		return domain.Secret{}, errors.Wrap(domain.Unavailable(err), fmt.Sprintf("failed to consume view for hash: %s", hash))
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return domain.Secret{}, errors.Wrap(domain.Unavailable(err), fmt.Sprintf("failed to consume view for hash: %s", hash))
+	}
+
+	secret, err := s.getByKey(ctx, tx, key)
+	if err != nil {
+		return domain.Secret{}, err
+	}
+
+	// The condition did not match, the secret expired or has no views left
+	if rows == 0 {
+		if secret.TTL != 0 && secret.TTL <= now.Unix() {
+			return domain.Secret{}, domain.ErrSecretExpired
+		}
+		return domain.Secret{}, domain.ErrNoRemainingViews
+	}
+
+	secret.ReadAt = append(secret.ReadAt, now)
+	readAt, err := marshalReadAt(secret.ReadAt)
+	if err != nil {
+		return domain.Secret{}, err
+	}
+
+	update := "UPDATE %s SET read_at = ? WHERE hash = ?"
+	if secret.RemainingViews == 0 {
+		update = "UPDATE %s SET read_at = ?, secret_text = '', wrapped_key = '', key_id = '', passphrase_kdf = '', file_name = '', quota_bytes = 0 WHERE hash = ?"
+	}
+	if _, err := tx.ExecContext(ctx, s.query(update), readAt, key); err != nil {
+		return domain.Secret{}, errors.Wrap(domain.Unavailable(err), fmt.Sprintf("failed to record read for hash: %s", hash))
+	}
+
+	if err := tx.Commit(); err != nil {
+		return domain.Secret{}, errors.Wrap(domain.Unavailable(err), fmt.Sprintf("failed to consume view for hash: %s", hash))
+	}
+
+	return secret, nil
+}
+
+// UpdateEncryption replaces the stored ciphertext and wrapped data key, it is used to re-encrypt older secrets.
+// The condition keeps a re-encryption that lost the race against the last view from writing into the tombstone.
+func (s SecretManagerRepository) UpdateEncryption(ctx context.Context, secret domain.Secret) error {
+	key, ok := tenancy.Key(ctx, secret.Hash)
+	if !ok {
+		return domain.ErrSecretNotFound
+	}
+
+	result, err := s.DB.ExecContext(ctx, s.query("UPDATE %s SET secret_text = ?, wrapped_key = ?, key_id = ? WHERE hash = ? AND remaining_views > 0"),
+		secret.SecretText, secret.WrappedKey, secret.KeyID, key)
+	if err != nil {
+		return errors.Wrap(domain.Unavailable(err), fmt.Sprintf("failed to update secret encryption for hash: %s", secret.Hash))
+	}
+
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		// The condition did not match, the secret is gone or only its tombstone is left
+		if _, err := s.getByKey(ctx, s.DB, key); err != nil {
+			return errors.Wrap(err, fmt.Sprintf("failed to update secret encryption for hash: %s", secret.Hash))
+		}
+		return errors.Wrap(domain.ErrNoRemainingViews, fmt.Sprintf("failed to update secret encryption for hash: %s", secret.Hash))
+	}
+
+	return nil
+}
+
+// RecordFailedAttempt increments the failed passphrase attempts and burns the secret once the limit is reached,
+// the transaction keeps concurrent guesses from getting past the limit
+func (s SecretManagerRepository) RecordFailedAttempt(ctx context.Context, hash string, maxAttempts int) (int, error) {
+	key, ok := tenancy.Key(ctx, hash)
+	if !ok {
+		return 0, domain.ErrSecretNotFound
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, errors.Wrap(domain.Unavailable(err), fmt.Sprintf("failed to begin transaction: %v", err))
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, s.query("UPDATE %s SET failed_attempts = failed_attempts + 1 WHERE hash = ?"), key)
+	if err != nil {
+		return 0, errors.Wrap(domain.Unavailable(err), fmt.Sprintf("failed to record failed attempt for hash: %s", hash))
+	}
+
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return 0, domain.ErrSecretNotFound
+	}
+
+	var attempts int
+	if err := tx.QueryRowContext(ctx, s.query("SELECT failed_attempts FROM %s WHERE hash = ?"), key).Scan(&attempts); err != nil {
+		return 0, errors.Wrap(domain.Unavailable(err), fmt.Sprintf("failed to record failed attempt for hash: %s", hash))
+	}
+
+	if attempts >= maxAttempts {
+		if _, err := tx.ExecContext(ctx, s.query("DELETE FROM %s WHERE hash = ?"), key); err != nil {
+			return attempts, errors.Wrap(domain.Unavailable(err), fmt.Sprintf("failed to delete secret for hash: %s", hash))
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, errors.Wrap(domain.Unavailable(err), fmt.Sprintf("failed to record failed attempt for hash: %s", hash))
+	}
+
+	return attempts, nil
+}
+
+// UpdateWrappedKey replaces the wrapped data key and its master key id. The condition on the old wrapped key
+// keeps a re-wrap from overwriting a secret that was re-encrypted or deleted in the meantime.
+func (s SecretManagerRepository) UpdateWrappedKey(ctx context.Context, hash string, oldWrappedKey string, wrappedKey string, keyID string) error {
+	key, ok := tenancy.Key(ctx, hash)
+	if !ok {
+		return domain.ErrSecretChanged
+	}
+
+	result, err := s.DB.ExecContext(ctx, s.query("UPDATE %s SET wrapped_key = ?, key_id = ? WHERE hash = ? AND wrapped_key = ?"),
+		wrappedKey, keyID, key, oldWrappedKey)
+	if err != nil {
+		return errors.Wrap(domain.Unavailable(err), fmt.Sprintf("failed to update wrapped key for hash: %s", hash))
+	}
+
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return domain.ErrSecretChanged
+	}
+
+	return nil
+}
+
+// ListSecrets returns the secrets of every tenant ordered by their key, the cursor is the key of the last secret of the previous page
+func (s SecretManagerRepository) ListSecrets(ctx context.Context, cursor string, limit int) ([]domain.Secret, string, error) {
+	// One more row than asked for tells whether there is a next page
+	rows, err := s.DB.QueryContext(ctx, s.query("SELECT "+secretColumns+" FROM %s WHERE hash > ? ORDER BY hash LIMIT ?"), cursor, limit+1)
+	if err != nil {
+		return nil, "", errors.Wrap(domain.Unavailable(err), fmt.Sprintf("failed to list secrets after cursor: %s", cursor))
+	}
+	defer rows.Close()
+
+	var secrets []domain.Secret
+	for rows.Next() {
+		secret, err := scanSecret(rows)
+		if err != nil {
+			return nil, "", errors.Wrap(domain.Unavailable(err), fmt.Sprintf("failed to list secrets after cursor: %s", cursor))
+		}
+		secrets = append(secrets, secret)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", errors.Wrap(domain.Unavailable(err), fmt.Sprintf("failed to list secrets after cursor: %s", cursor))
+	}
+
+	var next string
+	if len(secrets) > limit {
+		secrets = secrets[:limit]
+		next = tenancy.Join(secrets[limit-1].Tenant, secrets[limit-1].Hash)
+	}
+
+	return secrets, next, nil
+}
+
+// ListSecretsByOwner returns the metadata of the secrets of the owner ordered by creation time and hash, newest
+// first. The cursor holds both of the last secret of the previous page, the owner index serves the query.
+// The owner is keyed to the tenant of the context like the hash, so only the secrets of the tenant are listed.
+func (s SecretManagerRepository) ListSecretsByOwner(ctx context.Context, owner string, cursor string, limit int) ([]domain.Secret, string, error) {
+	ownerKey := tenancy.OwnerKey(ctx, owner)
+	if ownerKey == "" {
+		return nil, "", nil
+	}
+
+	query := "SELECT " + metadataColumns + " FROM %s WHERE owner = ?"
+	args := []interface{}{ownerKey}
+	if cursor != "" {
+		after, err := pagination.DecodeOwnerCursor(cursor)
+		if err != nil {
+			return nil, "", err
+		}
+
+		afterKey, ok := tenancy.Key(ctx, after.Hash)
+		if !ok {
+			return nil, "", domain.ErrInvalidCursor
+		}
+
+		query += " AND (created_at < ? OR (created_at = ? AND hash < ?))"
+		args = append(args, after.CreatedAt.UTC(), after.CreatedAt.UTC(), afterKey)
+	}
+
+	// One more row than asked for tells whether there is a next page
+	query += " ORDER BY created_at DESC, hash DESC LIMIT ?"
+	args = append(args, limit+1)
+
+	rows, err := s.DB.QueryContext(ctx, s.query(query), args...)
+	if err != nil {
+		return nil, "", errors.Wrap(domain.Unavailable(err), fmt.Sprintf("failed to list secrets of owner: %s", owner))
+	}
+	defer rows.Close()
+
+	var secrets []domain.Secret
+	for rows.Next() {
+		secret, err := scanMetadata(rows)
+		if err != nil {
+			return nil, "", errors.Wrap(domain.Unavailable(err), fmt.Sprintf("failed to list secrets of owner: %s", owner))
+		}
+		secrets = append(secrets, secret)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", errors.Wrap(domain.Unavailable(err), fmt.Sprintf("failed to list secrets of owner: %s", owner))
+	}
+
+	var next string
+	if len(secrets) > limit {
+		secrets = secrets[:limit]
+		next = pagination.OwnerCursor{CreatedAt: secrets[limit-1].CreatedAt, Hash: secrets[limit-1].Hash}.Encode()
+	}
+
+	return secrets, next, nil
+}
+
+// scanMetadata reads the metadataColumns of a row
+func scanMetadata(row scanner) (domain.Secret, error) {
+	var (
+		secret domain.Secret
+		readAt string
+	)
+
+	err := row.Scan(&secret.Hash, &secret.CreatedAt, &secret.ExpiresAt, &secret.RemainingViews, &readAt, &secret.RevocationTokenHash, &secret.Owner)
+	if err != nil {
+		return domain.Secret{}, err
+	}
+
+	secret.Tenant, secret.Hash = tenancy.Split(secret.Hash)
+	_, secret.Owner = tenancy.Split(secret.Owner)
+	secret.CreatedAt = secret.CreatedAt.UTC()
+	secret.ExpiresAt = secret.ExpiresAt.UTC()
+	if secret.ReadAt, err = unmarshalReadAt(readAt); err != nil {
+		return domain.Secret{}, err
+	}
+
+	return secret, nil
+}
+
+// scanSecret reads the secretColumns of a row
+func scanSecret(row scanner) (domain.Secret, error) {
+	var (
+		secret domain.Secret
+		readAt string
+	)
+
+	err := row.Scan(&secret.Hash, &secret.RevocationTokenHash, &secret.SecretText, &secret.WrappedKey, &secret.KeyID, &secret.PassphraseKDF,
+		&secret.FailedAttempts, &secret.ClientEncrypted, &secret.Algorithm, &secret.CreatedAt, &secret.ExpiresAt, &secret.TTL,
+		&secret.RemainingViews, &readAt, &secret.BlobKey, &secret.FileName, &secret.FileContentType, &secret.Owner, &secret.QuotaBytes)
+	if err != nil {
+		return domain.Secret{}, err
+	}
+
+	// The drivers return the time in the zone of the connection, secrets are always handled in UTC
+	secret.Tenant, secret.Hash = tenancy.Split(secret.Hash)
+	_, secret.Owner = tenancy.Split(secret.Owner)
+	secret.CreatedAt = secret.CreatedAt.UTC()
+	secret.ExpiresAt = secret.ExpiresAt.UTC()
+	if secret.ReadAt, err = unmarshalReadAt(readAt); err != nil {
+		return domain.Secret{}, err
+	}
+
+	return secret, nil
+}
+
+// marshalReadAt encodes the read times as a JSON array for the read_at column
+func marshalReadAt(readAt []time.Time) (string, error) {
+	if len(readAt) == 0 {
+		return "[]", nil
+	}
+
+	content, err := json.Marshal(readAt)
+	if err != nil {
+		return "", errors.Wrap(err, fmt.Sprintf("failed to marshal read times: %v", err))
+	}
+
+	return string(content), nil
+}
+
+// unmarshalReadAt decodes the read_at column, a secret that was never read has no read times
+func unmarshalReadAt(content string) ([]time.Time, error) {
+	var readAt []time.Time
+	if err := json.Unmarshal([]byte(content), &readAt); err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("failed to unmarshal read times: %v", err))
+	}
+
+	if len(readAt) == 0 {
+		return nil, nil
+	}
+	return readAt, nil
+}
+
+var _ domain.SecretRepository = (*SecretManagerRepository)(nil)
@@ -0,0 +1,279 @@
+package sqldb
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nalawade41/secret-server/config"
+	"github.com/nalawade41/secret-server/db"
+	"github.com/nalawade41/secret-server/internal/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestRepository creates the secrets table in a fresh in memory SQLite database
+func newTestRepository(t *testing.T) SecretManagerRepository {
+	conn, err := db.OpenSQL(&config.StorageConfig{Backend: config.StorageSQLite, SQLitePath: ":memory:"})
+	assert.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	assert.NoError(t, db.CreateSQLTable(context.Background(), conn, config.StorageSQLite, "secrets"))
+
+	return SecretManagerRepository{DB: conn, Dialect: SQLite, TableName: "secrets"}
+}
+
+func newTestSecret(hash string, views int) domain.Secret {
+	now := time.Now().UTC().Truncate(time.Second)
+	return domain.Secret{
+		Hash:                hash,
+		Key:                 "linkkey",
+		Passphrase:          "passphrase",
+		RevocationToken:     "token",
+		RevocationTokenHash: "tokenhash",
+		SecretText:          "envelope",
+		WrappedKey:          "wrappedkey",
+		KeyID:               "master",
+		PassphraseKDF:       "kdf",
+		CreatedAt:           now,
+		ExpiresAt:           now.Add(time.Hour),
+		TTL:                 now.Add(time.Hour).Unix(),
+		RemainingViews:      views,
+	}
+}
+
+func TestDialect_Rebind(t *testing.T) {
+	query := "UPDATE secrets SET wrapped_key = ? WHERE hash = ? AND wrapped_key = ?"
+
+	assert.Equal(t, query, SQLite.Rebind(query))
+	assert.Equal(t, "UPDATE secrets SET wrapped_key = $1 WHERE hash = $2 AND wrapped_key = $3", Postgres.Rebind(query))
+}
+
+func TestSaveAndGetByHash(t *testing.T) {
+	repo := newTestRepository(t)
+	secret := newTestSecret("testhash", 2)
+
+	assert.NoError(t, repo.Save(context.Background(), secret))
+
+	stored, err := repo.GetByHash(context.Background(), "testhash")
+	assert.NoError(t, err)
+
+	// The key, the passphrase and the plain revocation token are never persisted
+	assert.Empty(t, stored.Key)
+	assert.Empty(t, stored.Passphrase)
+	assert.Empty(t, stored.RevocationToken)
+	assert.Equal(t, "tokenhash", stored.RevocationTokenHash)
+	assert.Equal(t, "envelope", stored.SecretText)
+	assert.Equal(t, "wrappedkey", stored.WrappedKey)
+	assert.Equal(t, "master", stored.KeyID)
+	assert.Equal(t, "kdf", stored.PassphraseKDF)
+	assert.True(t, secret.CreatedAt.Equal(stored.CreatedAt))
+	assert.True(t, secret.ExpiresAt.Equal(stored.ExpiresAt))
+	assert.Equal(t, secret.TTL, stored.TTL)
+	assert.Equal(t, 2, stored.RemainingViews)
+	assert.Nil(t, stored.ReadAt)
+}
+
+func TestSave_AlreadyExists(t *testing.T) {
+	repo := newTestRepository(t)
+
+	assert.NoError(t, repo.Save(context.Background(), newTestSecret("testhash", 1)))
+
+	err := repo.Save(context.Background(), newTestSecret("testhash", 1))
+	assert.ErrorIs(t, err, domain.ErrSecretAlreadyExists)
+}
+
+func TestGetByHash_NotFound(t *testing.T) {
+	repo := newTestRepository(t)
+
+	_, err := repo.GetByHash(context.Background(), "missing")
+	assert.ErrorIs(t, err, domain.ErrSecretNotFound)
+}
+
+func TestConsumeView(t *testing.T) {
+	repo := newTestRepository(t)
+	assert.NoError(t, repo.Save(context.Background(), newTestSecret("testhash", 2)))
+
+	secret, err := repo.ConsumeView(context.Background(), "testhash")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, secret.RemainingViews)
+	assert.Equal(t, "envelope", secret.SecretText)
+	assert.Len(t, secret.ReadAt, 1)
+
+	// The last view still returns the ciphertext, only a tombstone is left behind
+	secret, err = repo.ConsumeView(context.Background(), "testhash")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, secret.RemainingViews)
+	assert.Equal(t, "envelope", secret.SecretText)
+	assert.Len(t, secret.ReadAt, 2)
+
+	tombstone, err := repo.GetByHash(context.Background(), "testhash")
+	assert.NoError(t, err)
+	assert.Empty(t, tombstone.SecretText)
+	assert.Empty(t, tombstone.WrappedKey)
+	assert.Empty(t, tombstone.KeyID)
+	assert.Empty(t, tombstone.PassphraseKDF)
+	assert.Len(t, tombstone.ReadAt, 2)
+
+	_, err = repo.ConsumeView(context.Background(), "testhash")
+	assert.ErrorIs(t, err, domain.ErrNoRemainingViews)
+
+	_, err = repo.ConsumeView(context.Background(), "missing")
+	assert.ErrorIs(t, err, domain.ErrSecretNotFound)
+}
+
+func TestConsumeView_Expired(t *testing.T) {
+	repo := newTestRepository(t)
+
+	// The TTL passed, but nothing deleted the secret yet
+	secret := newTestSecret("testhash", 3)
+	secret.TTL = time.Now().Add(-time.Minute).Unix()
+	assert.NoError(t, repo.Save(context.Background(), secret))
+
+	_, err := repo.ConsumeView(context.Background(), "testhash")
+	assert.ErrorIs(t, err, domain.ErrSecretExpired)
+}
+
+func TestConsumeView_Concurrent(t *testing.T) {
+	repo := newTestRepository(t)
+	assert.NoError(t, repo.Save(context.Background(), newTestSecret("testhash", 5)))
+
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		taken int
+	)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := repo.ConsumeView(context.Background(), "testhash"); err == nil {
+				mu.Lock()
+				taken++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Concurrent readers never take more views than the secret was created with
+	assert.Equal(t, 5, taken)
+}
+
+func TestGetMetadata(t *testing.T) {
+	repo := newTestRepository(t)
+	assert.NoError(t, repo.Save(context.Background(), newTestSecret("testhash", 2)))
+	_, err := repo.ConsumeView(context.Background(), "testhash")
+	assert.NoError(t, err)
+
+	metadata, err := repo.GetMetadata(context.Background(), "testhash")
+	assert.NoError(t, err)
+	assert.Equal(t, "testhash", metadata.Hash)
+	assert.Equal(t, 1, metadata.RemainingViews)
+	assert.Equal(t, "tokenhash", metadata.RevocationTokenHash)
+	assert.Len(t, metadata.ReadAt, 1)
+	assert.Empty(t, metadata.SecretText)
+	assert.Empty(t, metadata.WrappedKey)
+
+	_, err = repo.GetMetadata(context.Background(), "missing")
+	assert.ErrorIs(t, err, domain.ErrSecretNotFound)
+}
+
+func TestDeleteSecret(t *testing.T) {
+	repo := newTestRepository(t)
+	assert.NoError(t, repo.Save(context.Background(), newTestSecret("testhash", 1)))
+
+	assert.NoError(t, repo.DeleteSecret(context.Background(), "testhash"))
+
+	_, err := repo.GetByHash(context.Background(), "testhash")
+	assert.ErrorIs(t, err, domain.ErrSecretNotFound)
+
+	// Deleting a missing secret is not an error
+	assert.NoError(t, repo.DeleteSecret(context.Background(), "testhash"))
+}
+
+func TestUpdateEncryption(t *testing.T) {
+	repo := newTestRepository(t)
+	assert.NoError(t, repo.Save(context.Background(), newTestSecret("testhash", 1)))
+
+	err := repo.UpdateEncryption(context.Background(), domain.Secret{Hash: "testhash", SecretText: "new envelope", WrappedKey: "newkey", KeyID: "new"})
+	assert.NoError(t, err)
+
+	stored, err := repo.GetByHash(context.Background(), "testhash")
+	assert.NoError(t, err)
+	assert.Equal(t, "new envelope", stored.SecretText)
+	assert.Equal(t, "newkey", stored.WrappedKey)
+	assert.Equal(t, "new", stored.KeyID)
+
+	err = repo.UpdateEncryption(context.Background(), domain.Secret{Hash: "missing"})
+	assert.ErrorIs(t, err, domain.ErrSecretNotFound)
+}
+
+func TestRecordFailedAttempt(t *testing.T) {
+	repo := newTestRepository(t)
+	assert.NoError(t, repo.Save(context.Background(), newTestSecret("testhash", 1)))
+
+	attempts, err := repo.RecordFailedAttempt(context.Background(), "testhash", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, attempts)
+
+	// The second failed attempt burns the secret
+	attempts, err = repo.RecordFailedAttempt(context.Background(), "testhash", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, attempts)
+
+	_, err = repo.GetByHash(context.Background(), "testhash")
+	assert.ErrorIs(t, err, domain.ErrSecretNotFound)
+
+	_, err = repo.RecordFailedAttempt(context.Background(), "testhash", 2)
+	assert.ErrorIs(t, err, domain.ErrSecretNotFound)
+}
+
+func TestUpdateWrappedKey(t *testing.T) {
+	repo := newTestRepository(t)
+	assert.NoError(t, repo.Save(context.Background(), newTestSecret("testhash", 1)))
+
+	assert.NoError(t, repo.UpdateWrappedKey(context.Background(), "testhash", "wrappedkey", "rewrapped", "new"))
+
+	// The wrapped key changed, a second re-wrap from the old key is refused
+	err := repo.UpdateWrappedKey(context.Background(), "testhash", "wrappedkey", "rewrapped again", "new")
+	assert.ErrorIs(t, err, domain.ErrSecretChanged)
+
+	stored, err := repo.GetByHash(context.Background(), "testhash")
+	assert.NoError(t, err)
+	assert.Equal(t, "rewrapped", stored.WrappedKey)
+	assert.Equal(t, "new", stored.KeyID)
+}
+
+func TestListSecrets(t *testing.T) {
+	repo := newTestRepository(t)
+	for _, hash := range []string{"c", "a", "b"} {
+		assert.NoError(t, repo.Save(context.Background(), newTestSecret(hash, 1)))
+	}
+
+	secrets, next, err := repo.ListSecrets(context.Background(), "", 2)
+	assert.NoError(t, err)
+	assert.Len(t, secrets, 2)
+	assert.Equal(t, "a", secrets[0].Hash)
+	assert.Equal(t, "b", secrets[1].Hash)
+	assert.Equal(t, "b", next)
+
+	secrets, next, err = repo.ListSecrets(context.Background(), next, 2)
+	assert.NoError(t, err)
+	assert.Len(t, secrets, 1)
+	assert.Equal(t, "c", secrets[0].Hash)
+	assert.Empty(t, next)
+}
+
+func TestStorageError(t *testing.T) {
+	repo := newTestRepository(t)
+	assert.NoError(t, repo.DB.Close())
+
+	// A broken database is an outage, not a missing secret
+	_, err := repo.GetByHash(context.Background(), "testhash")
+	assert.ErrorIs(t, err, domain.ErrUnavailable)
+	assert.NotErrorIs(t, err, domain.ErrNotFound)
+
+	_, err = repo.ConsumeView(context.Background(), "testhash")
+	assert.ErrorIs(t, err, domain.ErrUnavailable)
+}
@@ -0,0 +1,54 @@
+// Package tenancy builds the storage keys that keep the secrets of the tenants apart. The keys of secrets of
+// the default tenant are their ids as they were before tenants, the keys of other tenants start with the name
+// of their tenant. Tenant names and secret ids never contain the separator, so no key belongs to two tenants.
+package tenancy
+
+import (
+	"context"
+	"strings"
+
+	"github.com/nalawade41/secret-server/internal/domain"
+)
+
+// Separator separates the tenant from the id in the keys of the secrets of a tenant
+const Separator = "#"
+
+// Key returns the storage key of the id in the tenant of the context. An id containing the separator could
+// reach into another tenant, it has no key and false is returned.
+func Key(ctx context.Context, id string) (string, bool) {
+	if strings.Contains(id, Separator) {
+		return "", false
+	}
+	return Join(domain.TenantFromContext(ctx), id), true
+}
+
+// Join returns the storage key of the id in the tenant, the id must not contain the separator
+func Join(tenant string, id string) string {
+	if tenant == domain.DefaultTenant {
+		return id
+	}
+	return tenant + Separator + id
+}
+
+// Split returns the tenant and the id of a storage key
+func Split(key string) (string, string) {
+	tenant, id, ok := strings.Cut(key, Separator)
+	if !ok {
+		return domain.DefaultTenant, key
+	}
+	return tenant, id
+}
+
+// OwnerKey returns the storage key of the owner in the tenant of the context, empty for secrets without owner.
+// An owner containing the separator is not stored, its secrets can only be managed with their revocation token.
+func OwnerKey(ctx context.Context, owner string) string {
+	if owner == "" {
+		return ""
+	}
+
+	key, ok := Key(ctx, owner)
+	if !ok {
+		return ""
+	}
+	return key
+}
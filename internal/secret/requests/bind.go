@@ -0,0 +1,260 @@
+package requests
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nalawade41/secret-server/internal/domain"
+)
+
+const (
+	// fileField is the multipart field a file is uploaded in
+	fileField = "file"
+
+	// multipartMemory is how much of a multipart body is held in memory, larger files are buffered in
+	// temporary files until the request is closed
+	multipartMemory = 1 << 20
+)
+
+// fields returns pointers to the fields of the request by the name clients send them as
+func (c *CreateSecretRequest) fields() map[string]interface{} {
+	return map[string]interface{}{
+		"secret":           &c.SecretText,
+		"expireAfter":      &c.ExpiresAfter,
+		"expireAfterViews": &c.RemainingViews,
+		"passphrase":       &c.Passphrase,
+		"clientEncrypted":  &c.ClientEncrypted,
+		"algorithm":        &c.Algorithm,
+	}
+}
+
+// Bind reads the request from a JSON, URL encoded or multipart body. Unlike echo's binder it only reads the body,
+// rejects query parameters and unknown fields, and stops reading bodies larger than maxBodySize.
+// Only a multipart body can carry a file, it may be larger by maxFileSize. The file is left open to be
+// streamed, Close releases it.
+func (c *CreateSecretRequest) Bind(r *http.Request, maxBodySize int64, maxFileSize int64) error {
+	var fields []domain.FieldError
+
+	// The secret is only read from the body, query parameters end up in access logs
+	for _, name := range sortedKeys(r.URL.Query()) {
+		fields = append(fields, domain.FieldError{Field: name, Message: "query parameters are not accepted, send the field in the body"})
+	}
+
+	contentType, _, err := mime.ParseMediaType(r.Header.Get(echo.HeaderContentType))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnsupportedMediaType, "the content type must be application/json, application/x-www-form-urlencoded or multipart/form-data")
+	}
+
+	if contentType == echo.MIMEMultipartForm {
+		maxBodySize += maxFileSize
+	}
+	r.Body = http.MaxBytesReader(nil, r.Body, maxBodySize)
+
+	var bodyFields []domain.FieldError
+	switch contentType {
+	case echo.MIMEApplicationJSON:
+		bodyFields, err = c.bindJSON(r.Body)
+	case echo.MIMEApplicationForm:
+		if err = r.ParseForm(); err == nil {
+			bodyFields = c.bindForm(r.PostForm)
+		}
+	case echo.MIMEMultipartForm:
+		if err = r.ParseMultipartForm(multipartMemory); err == nil {
+			bodyFields = c.bindForm(r.MultipartForm.Value)
+
+			var fileFields []domain.FieldError
+			fileFields, err = c.bindFiles(r.MultipartForm.File)
+			bodyFields = append(bodyFields, fileFields...)
+
+			// The temporary files are kept while the file is streamed, Close removes them
+			if c.File != nil {
+				c.form = r.MultipartForm
+			} else {
+				_ = r.MultipartForm.RemoveAll()
+			}
+		}
+	default:
+		return echo.NewHTTPError(http.StatusUnsupportedMediaType, "the content type must be application/json, application/x-www-form-urlencoded or multipart/form-data")
+	}
+
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return echo.NewHTTPError(http.StatusRequestEntityTooLarge, fmt.Sprintf("the request body is larger than %d bytes", maxBodySize))
+	}
+	if err != nil {
+		return &domain.Error{Kind: domain.ErrValidation, Code: "malformed_request", Message: "error parsing data", Err: err}
+	}
+
+	fields = append(fields, bodyFields...)
+	if len(fields) > 0 {
+		return domain.NewValidationError(fields)
+	}
+
+	return nil
+}
+
+// bindJSON reads a single JSON object, every unknown field and every field of the wrong type is reported
+func (c *CreateSecretRequest) bindJSON(body io.Reader) ([]domain.FieldError, error) {
+	decoder := json.NewDecoder(body)
+
+	var object map[string]json.RawMessage
+	if err := decoder.Decode(&object); err != nil {
+		return nil, err
+	}
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		return nil, errors.New("unexpected data after the JSON object")
+	}
+
+	var invalid []domain.FieldError
+	targets := c.fields()
+	for _, name := range sortedKeys(object) {
+		target, ok := targets[name]
+		if !ok {
+			invalid = append(invalid, domain.FieldError{Field: name, Message: "unknown field"})
+			continue
+		}
+
+		if err := json.Unmarshal(object[name], target); err != nil {
+			invalid = append(invalid, domain.FieldError{Field: name, Message: "must be " + typeName(target)})
+		}
+	}
+
+	return invalid, nil
+}
+
+// bindForm sets the fields from form values
+func (c *CreateSecretRequest) bindForm(values map[string][]string) []domain.FieldError {
+	var invalid []domain.FieldError
+	targets := c.fields()
+	for _, name := range sortedKeys(values) {
+		target, ok := targets[name]
+		switch {
+		case name == fileField:
+			invalid = append(invalid, domain.FieldError{Field: name, Message: "must be a file upload"})
+		case !ok:
+			invalid = append(invalid, domain.FieldError{Field: name, Message: "unknown field"})
+		case len(values[name]) != 1:
+			invalid = append(invalid, domain.FieldError{Field: name, Message: "must be given once"})
+		case !setFormValue(target, values[name][0]):
+			invalid = append(invalid, domain.FieldError{Field: name, Message: "must be " + typeName(target)})
+		}
+	}
+
+	return invalid
+}
+
+// bindFiles reads the file of the file field, files in any other field are reported
+func (c *CreateSecretRequest) bindFiles(files map[string][]*multipart.FileHeader) ([]domain.FieldError, error) {
+	var invalid []domain.FieldError
+	for _, name := range sortedKeys(files) {
+		switch {
+		case name != fileField:
+			invalid = append(invalid, domain.FieldError{Field: name, Message: "files are only accepted in the file field"})
+		case len(files[name]) != 1:
+			invalid = append(invalid, domain.FieldError{Field: name, Message: "must be given once"})
+		default:
+			file, err := openFile(files[name][0])
+			if err != nil {
+				return nil, err
+			}
+			c.File = file
+		}
+	}
+
+	return invalid, nil
+}
+
+// openFile opens an uploaded file to stream its content, the multipart reader already stripped any directory
+// from its name
+func openFile(header *multipart.FileHeader) (*domain.File, error) {
+	file, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.File{Name: header.Filename, ContentType: fileContentType(header), Size: header.Size, Content: file}, nil
+}
+
+// Close closes the uploaded file and removes the temporary files of the multipart body
+func (c *CreateSecretRequest) Close() error {
+	var err error
+	if c.File != nil {
+		err = c.File.Content.Close()
+	}
+
+	if c.form != nil {
+		if removeErr := c.form.RemoveAll(); err == nil {
+			err = removeErr
+		}
+	}
+
+	return err
+}
+
+// fileContentType returns the content type of the uploaded file, it is guessed from the name
+// when the client did not send a valid one or only the generic octet stream most clients default to
+func fileContentType(header *multipart.FileHeader) string {
+	if mediaType, params, err := mime.ParseMediaType(header.Header.Get(echo.HeaderContentType)); err == nil && mediaType != echo.MIMEOctetStream {
+		if contentType := mime.FormatMediaType(mediaType, params); contentType != "" {
+			return contentType
+		}
+	}
+
+	if contentType := mime.TypeByExtension(filepath.Ext(header.Filename)); contentType != "" {
+		return contentType
+	}
+
+	return echo.MIMEOctetStream
+}
+
+// setFormValue parses the form value into the field, it returns false if the value does not fit the type of the field
+func setFormValue(target interface{}, value string) bool {
+	switch field := target.(type) {
+	case *string:
+		*field = value
+	case *int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return false
+		}
+		*field = n
+	case *bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return false
+		}
+		*field = b
+	}
+	return true
+}
+
+// typeName names the type of the field for the clients
+func typeName(target interface{}) string {
+	switch target.(type) {
+	case *int:
+		return "a number"
+	case *bool:
+		return "a boolean"
+	default:
+		return "a string"
+	}
+}
+
+// sortedKeys returns the keys of the map in order, so errors are reported in the same order every time
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
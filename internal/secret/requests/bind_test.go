@@ -0,0 +1,339 @@
+package requests
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nalawade41/secret-server/internal/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+// multipartBody encodes the fields and files as a multipart form
+func multipartBody(t *testing.T, fields map[string]string, files map[string]string) (string, *bytes.Buffer) {
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+	for name, value := range fields {
+		assert.NoError(t, writer.WriteField(name, value))
+	}
+	for name, content := range files {
+		part, err := writer.CreateFormFile(name, name+".txt")
+		assert.NoError(t, err)
+		_, err = part.Write([]byte(content))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, writer.Close())
+	return writer.FormDataContentType(), body
+}
+
+// TestBind tests that every supported content type binds the same request
+func TestBind(t *testing.T) {
+	contentType, body := multipartBody(t, map[string]string{"secret": "Test secret", "expireAfter": "10", "expireAfterViews": "2", "passphrase": "correct horse"}, nil)
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+	}{
+		{
+			name:        "json",
+			contentType: echo.MIMEApplicationJSON,
+			body:        `{"secret":"Test secret","expireAfter":10,"expireAfterViews":2,"passphrase":"correct horse"}`,
+		},
+		{
+			name:        "json with charset",
+			contentType: echo.MIMEApplicationJSONCharsetUTF8,
+			body:        `{"secret":"Test secret","expireAfter":10,"expireAfterViews":2,"passphrase":"correct horse"}`,
+		},
+		{
+			name:        "form",
+			contentType: echo.MIMEApplicationForm,
+			body:        "secret=Test+secret&expireAfter=10&expireAfterViews=2&passphrase=correct+horse",
+		},
+		{
+			name:        "multipart",
+			contentType: contentType,
+			body:        body.String(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/secret", strings.NewReader(tt.body))
+			req.Header.Set(echo.HeaderContentType, tt.contentType)
+
+			var request CreateSecretRequest
+			assert.NoError(t, request.Bind(req, 1024, 4096))
+			assert.Equal(t, CreateSecretRequest{SecretText: "Test secret", ExpiresAfter: 10, RemainingViews: 2, Passphrase: "correct horse"}, request)
+		})
+	}
+}
+
+// filePart is a file of a multipart body, an empty content type leaves out the header
+type filePart struct {
+	field       string
+	name        string
+	contentType string
+	content     string
+}
+
+// multipartFiles encodes the secret fields and the files as a multipart form
+func multipartFiles(t *testing.T, fields map[string]string, files ...filePart) (string, *bytes.Buffer) {
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+	for name, value := range fields {
+		assert.NoError(t, writer.WriteField(name, value))
+	}
+	for _, file := range files {
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Disposition", fmt.Sprintf(`form-data; name=%q; filename=%q`, file.field, file.name))
+		if file.contentType != "" {
+			header.Set(echo.HeaderContentType, file.contentType)
+		}
+		part, err := writer.CreatePart(header)
+		assert.NoError(t, err)
+		_, err = part.Write([]byte(file.content))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, writer.Close())
+	return writer.FormDataContentType(), body
+}
+
+// TestBind_File tests that a file is read with its name and content type
+func TestBind_File(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     filePart
+		expected domain.File
+	}{
+		{
+			name:     "content type of the part",
+			file:     filePart{field: "file", name: "server.pem", contentType: "application/x-pem-file", content: "-----BEGIN CERTIFICATE-----"},
+			expected: domain.File{Name: "server.pem", ContentType: "application/x-pem-file", Size: 27},
+		},
+		{
+			name:     "directory is stripped from the name",
+			file:     filePart{field: "file", name: "../../etc/keystore.jks", contentType: "application/x-java-keystore", content: "keystore"},
+			expected: domain.File{Name: "keystore.jks", ContentType: "application/x-java-keystore", Size: 8},
+		},
+		{
+			name:     "content type guessed from the name",
+			file:     filePart{field: "file", name: "config.json", content: "{}"},
+			expected: domain.File{Name: "config.json", ContentType: "application/json", Size: 2},
+		},
+		{
+			name:     "octet stream guessed from the name",
+			file:     filePart{field: "file", name: "config.json", contentType: "application/octet-stream", content: "{}"},
+			expected: domain.File{Name: "config.json", ContentType: "application/json", Size: 2},
+		},
+		{
+			name:     "unknown content type",
+			file:     filePart{field: "file", name: "kubeconfig", contentType: "not a media type", content: "apiVersion: v1"},
+			expected: domain.File{Name: "kubeconfig", ContentType: "application/octet-stream", Size: 14},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			contentType, body := multipartFiles(t, map[string]string{"expireAfterViews": "1"}, tt.file)
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/secret", body)
+			req.Header.Set(echo.HeaderContentType, contentType)
+
+			var request CreateSecretRequest
+			assert.NoError(t, request.Bind(req, 1024, 4096))
+			defer request.Close()
+			assert.Equal(t, 1, request.RemainingViews)
+			if assert.NotNil(t, request.File) {
+				assert.Equal(t, tt.expected, domain.File{Name: request.File.Name, ContentType: request.File.ContentType, Size: request.File.Size})
+				content, err := io.ReadAll(request.File.Content)
+				assert.NoError(t, err)
+				assert.Equal(t, tt.file.content, string(content))
+			}
+		})
+	}
+}
+
+// TestBind_LargeFile tests that a large file is buffered in a temporary file, which Close removes
+func TestBind_LargeFile(t *testing.T) {
+	content := strings.Repeat("a", 2*multipartMemory)
+	contentType, body := multipartFiles(t, nil, filePart{field: "file", name: "backup.tar", content: content})
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/secret", body)
+	req.Header.Set(echo.HeaderContentType, contentType)
+
+	var request CreateSecretRequest
+	assert.NoError(t, request.Bind(req, 1024, int64(len(content))))
+
+	file, ok := request.File.Content.(*os.File)
+	if assert.True(t, ok, "the file should not be held in memory") {
+		assert.Equal(t, int64(len(content)), request.File.Size)
+		assert.NoError(t, request.Close())
+
+		_, err := os.Stat(file.Name())
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	}
+}
+
+// TestBind_FileLimit tests that a multipart body may be larger than other bodies by the size of a file
+func TestBind_FileLimit(t *testing.T) {
+	file := filePart{field: "file", name: "server.pem", content: strings.Repeat("a", 2048)}
+
+	contentType, body := multipartFiles(t, nil, file)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/secret", body)
+	req.Header.Set(echo.HeaderContentType, contentType)
+
+	var request CreateSecretRequest
+	assert.NoError(t, request.Bind(req, 1024, 4096))
+
+	contentType, body = multipartFiles(t, nil, file, file)
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/secret", body)
+	req.Header.Set(echo.HeaderContentType, contentType)
+
+	var httpErr *echo.HTTPError
+	if assert.ErrorAs(t, new(CreateSecretRequest).Bind(req, 1024, 2048), &httpErr) {
+		assert.Equal(t, http.StatusRequestEntityTooLarge, httpErr.Code)
+	}
+}
+
+// TestBind_InvalidFields tests that every unknown or mistyped field is reported
+func TestBind_InvalidFields(t *testing.T) {
+	contentType, body := multipartBody(t, map[string]string{"secret": "Test secret"}, map[string]string{"attachment": "file content"})
+	repeatedType, repeatedBody := multipartFiles(t, nil, filePart{field: "file", name: "a.pem", content: "a"}, filePart{field: "file", name: "b.pem", content: "b"})
+
+	tests := []struct {
+		name        string
+		target      string
+		contentType string
+		body        string
+		expected    []domain.FieldError
+	}{
+		{
+			name:        "json unknown and mistyped fields",
+			target:      "/api/v1/secret",
+			contentType: echo.MIMEApplicationJSON,
+			body:        `{"secret":"Test secret","expireAfter":"10","clientEncrypted":1,"views":2}`,
+			expected: []domain.FieldError{
+				{Field: "clientEncrypted", Message: "must be a boolean"},
+				{Field: "expireAfter", Message: "must be a number"},
+				{Field: "views", Message: "unknown field"},
+			},
+		},
+		{
+			name:        "form unknown and repeated fields",
+			target:      "/api/v1/secret",
+			contentType: echo.MIMEApplicationForm,
+			body:        "secret=a&secret=b&expireAfterViews=two&ttl=10",
+			expected: []domain.FieldError{
+				{Field: "expireAfterViews", Message: "must be a number"},
+				{Field: "secret", Message: "must be given once"},
+				{Field: "ttl", Message: "unknown field"},
+			},
+		},
+		{
+			name:        "query parameters",
+			target:      "/api/v1/secret?secret=Test+secret",
+			contentType: echo.MIMEApplicationJSON,
+			body:        `{"expireAfterViews":2}`,
+			expected: []domain.FieldError{
+				{Field: "secret", Message: "query parameters are not accepted, send the field in the body"},
+			},
+		},
+		{
+			name:        "form file field",
+			target:      "/api/v1/secret",
+			contentType: echo.MIMEApplicationForm,
+			body:        "file=server.pem",
+			expected: []domain.FieldError{
+				{Field: "file", Message: "must be a file upload"},
+			},
+		},
+		{
+			name:        "multipart file",
+			target:      "/api/v1/secret",
+			contentType: contentType,
+			body:        body.String(),
+			expected: []domain.FieldError{
+				{Field: "attachment", Message: "files are only accepted in the file field"},
+			},
+		},
+		{
+			name:        "repeated file",
+			target:      "/api/v1/secret",
+			contentType: repeatedType,
+			body:        repeatedBody.String(),
+			expected: []domain.FieldError{
+				{Field: "file", Message: "must be given once"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.target, strings.NewReader(tt.body))
+			req.Header.Set(echo.HeaderContentType, tt.contentType)
+
+			var request CreateSecretRequest
+			err := request.Bind(req, 1024, 4096)
+
+			var validationErr *domain.Error
+			if assert.ErrorAs(t, err, &validationErr) {
+				assert.Equal(t, "invalid_input", validationErr.Code)
+				assert.Equal(t, tt.expected, validationErr.Fields)
+			}
+		})
+	}
+}
+
+// TestBind_Rejected tests the bodies that are rejected as a whole
+func TestBind_Rejected(t *testing.T) {
+	contentType, body := multipartBody(t, map[string]string{"secret": strings.Repeat("a", 64)}, nil)
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		status      int
+		code        string
+	}{
+		{name: "malformed json", contentType: echo.MIMEApplicationJSON, body: `{"secret":`, code: "malformed_request"},
+		{name: "trailing json", contentType: echo.MIMEApplicationJSON, body: `{"secret":"a"} {"secret":"b"}`, code: "malformed_request"},
+		{name: "json array", contentType: echo.MIMEApplicationJSON, body: `[{"secret":"a"}]`, code: "malformed_request"},
+		{name: "json too large", contentType: echo.MIMEApplicationJSON, body: `{"secret":"` + strings.Repeat("a", 64) + `"}`, status: http.StatusRequestEntityTooLarge},
+		{name: "form too large", contentType: echo.MIMEApplicationForm, body: "secret=" + strings.Repeat("a", 64), status: http.StatusRequestEntityTooLarge},
+		{name: "multipart too large", contentType: contentType, body: body.String(), status: http.StatusRequestEntityTooLarge},
+		{name: "unsupported content type", contentType: echo.MIMETextPlain, body: "Test secret", status: http.StatusUnsupportedMediaType},
+		{name: "missing content type", body: "Test secret", status: http.StatusUnsupportedMediaType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/secret", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set(echo.HeaderContentType, tt.contentType)
+			}
+
+			var request CreateSecretRequest
+			err := request.Bind(req, 32, 64)
+
+			if tt.status != 0 {
+				var httpErr *echo.HTTPError
+				if assert.ErrorAs(t, err, &httpErr) {
+					assert.Equal(t, tt.status, httpErr.Code)
+				}
+				return
+			}
+
+			var domainErr *domain.Error
+			if assert.ErrorAs(t, err, &domainErr) {
+				assert.Equal(t, tt.code, domainErr.Code)
+			}
+		})
+	}
+}
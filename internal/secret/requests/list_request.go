@@ -0,0 +1,85 @@
+package requests
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/nalawade41/secret-server/internal/domain"
+)
+
+const (
+	// DefaultListLimit is the number of secrets on a page unless the client asks for another limit
+	DefaultListLimit = 20
+
+	// MaxListLimit is the largest page a client can ask for
+	MaxListLimit = 100
+
+	// maxCursorLength is far longer than any cursor handed out, longer ones are not decoded
+	maxCursorLength = 1024
+)
+
+// ListSecretsRequest asks for a page of the secrets of the caller, Cursor is empty for the first page
+type ListSecretsRequest struct {
+	Cursor string `query:"cursor"`
+	Limit  int    `query:"limit"`
+}
+
+// Bind reads the request from the query parameters, unknown and repeated parameters are reported as field errors
+func (l *ListSecretsRequest) Bind(query url.Values) error {
+	var fields []domain.FieldError
+	invalid := func(field string, message string) {
+		fields = append(fields, domain.FieldError{Field: field, Message: message})
+	}
+
+	l.Limit = DefaultListLimit
+	for _, name := range sortedKeys(query) {
+		values := query[name]
+		if len(values) > 1 {
+			invalid(name, "parameter is repeated")
+			continue
+		}
+
+		switch name {
+		case "cursor":
+			l.Cursor = values[0]
+		case "limit":
+			limit, err := strconv.Atoi(values[0])
+			if err != nil {
+				invalid(name, "limit must be a number")
+				continue
+			}
+			l.Limit = limit
+		default:
+			invalid(name, "unknown parameter")
+		}
+	}
+
+	if len(fields) > 0 {
+		return domain.NewValidationError(fields)
+	}
+
+	return nil
+}
+
+// Validate checks the limit and the length of the cursor, whether the cursor is valid only the repository can tell
+func (l ListSecretsRequest) Validate() error {
+	var fields []domain.FieldError
+	invalid := func(field string, message string) {
+		fields = append(fields, domain.FieldError{Field: field, Message: message})
+	}
+
+	if l.Limit < 1 || l.Limit > MaxListLimit {
+		invalid("limit", fmt.Sprintf("limit must be between 1 and %d", MaxListLimit))
+	}
+
+	if len(l.Cursor) > maxCursorLength {
+		invalid("cursor", "cursor is too long")
+	}
+
+	if len(fields) > 0 {
+		return domain.NewValidationError(fields)
+	}
+
+	return nil
+}
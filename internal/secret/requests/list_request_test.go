@@ -0,0 +1,59 @@
+package requests
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/nalawade41/secret-server/internal/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListSecretsRequest_Bind(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		want       ListSecretsRequest
+		wantFields []string
+	}{
+		{name: "defaults", want: ListSecretsRequest{Limit: DefaultListLimit}},
+		{name: "cursor and limit", query: "cursor=abc&limit=5", want: ListSecretsRequest{Cursor: "abc", Limit: 5}},
+		{name: "limit not a number", query: "limit=ten", wantFields: []string{"limit"}},
+		{name: "repeated parameter", query: "cursor=a&cursor=b", wantFields: []string{"cursor"}},
+		{name: "unknown parameters", query: "owner=bob&limit=5&all=true", wantFields: []string{"all", "owner"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := url.ParseQuery(tt.query)
+			assert.NoError(t, err)
+
+			var request ListSecretsRequest
+			err = request.Bind(query)
+
+			if tt.wantFields == nil {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.want, request)
+				return
+			}
+
+			var domainErr *domain.Error
+			if assert.ErrorAs(t, err, &domainErr) {
+				var fields []string
+				for _, field := range domainErr.Fields {
+					fields = append(fields, field.Field)
+				}
+				assert.Equal(t, tt.wantFields, fields)
+			}
+		})
+	}
+}
+
+func TestListSecretsRequest_Validate(t *testing.T) {
+	assert.NoError(t, ListSecretsRequest{Limit: 1}.Validate())
+	assert.NoError(t, ListSecretsRequest{Cursor: "abc", Limit: MaxListLimit}.Validate())
+
+	assert.ErrorIs(t, ListSecretsRequest{Limit: 0}.Validate(), domain.ErrValidation)
+	assert.ErrorIs(t, ListSecretsRequest{Limit: MaxListLimit + 1}.Validate(), domain.ErrValidation)
+	assert.ErrorIs(t, ListSecretsRequest{Cursor: strings.Repeat("a", 2000), Limit: 10}.Validate(), domain.ErrValidation)
+}
@@ -1,16 +1,37 @@
 package requests
 
 import (
-	"errors"
+	"fmt"
+	"mime/multipart"
 	"time"
 
+	"github.com/nalawade41/secret-server/internal/common/security"
 	"github.com/nalawade41/secret-server/internal/domain"
 )
 
+const (
+	// maxPassphraseLength keeps the key derivation from hashing arbitrarily large inputs
+	maxPassphraseLength = 1024
+
+	// maxFileNameLength is the longest file name most file systems can store
+	maxFileNameLength = 255
+)
+
 type CreateSecretRequest struct {
 	SecretText     string `form:"secret" json:"secret"`
 	ExpiresAfter   int    `form:"expireAfter" json:"expireAfter"`
 	RemainingViews int    `form:"expireAfterViews" json:"expireAfterViews"`
+	// Passphrase is optional, when set it is required again to read the secret
+	Passphrase string `form:"passphrase" json:"passphrase"`
+	// ClientEncrypted marks the secret as an envelope encrypted by the client with Algorithm,
+	// the server stores it as it is and never sees the key
+	ClientEncrypted bool   `form:"clientEncrypted" json:"clientEncrypted"`
+	Algorithm       string `form:"algorithm" json:"algorithm"`
+	// File is shared instead of a secret text, it is only accepted as the file field of a multipart body
+	File *domain.File `form:"-" json:"-" swaggerignore:"true"`
+
+	// form is the parsed multipart body, its temporary files are removed by Close
+	form *multipart.Form
 }
 
 type GetSecretRequest struct {
@@ -28,30 +49,111 @@ func (c CreateSecretRequest) ToDomain() domain.Secret {
 
 	// Create expiresAt using current time and duration
 	return domain.Secret{
-		SecretText:     c.SecretText,
-		ExpiresAt:      expiresAtUtc,
-		RemainingViews: c.RemainingViews,
-		CreatedAt:      time.Now().UTC(),
+		SecretText:      c.SecretText,
+		Passphrase:      c.Passphrase,
+		ClientEncrypted: c.ClientEncrypted,
+		Algorithm:       c.Algorithm,
+		ExpiresAt:       expiresAtUtc,
+		RemainingViews:  c.RemainingViews,
+		CreatedAt:       time.Now().UTC(),
+		File:            c.File,
 	}
 }
 
-// Validate method to validate the request
-func (c CreateSecretRequest) Validate() error {
-	if c.SecretText == "" {
-		return errors.New("secret text is required")
+// Validate method to validate the request against the policy of its tenant, every invalid field is reported
+// and not only the first one. The secret size of the policy limits the envelope of client encrypted secrets.
+func (c CreateSecretRequest) Validate(policy domain.TenantPolicy) error {
+	var fields []domain.FieldError
+	invalid := func(field string, message string) {
+		fields = append(fields, domain.FieldError{Field: field, Message: message})
 	}
+	maxSecretSize, maxFileSize := policy.MaxSecretSize, policy.MaxFileSize
 
+	if c.File != nil {
+		c.validateFile(maxFileSize, invalid)
+	} else if c.SecretText == "" {
+		invalid("secret", "secret text is required")
+	} else if len(c.SecretText) > maxSecretSize {
+		invalid("secret", fmt.Sprintf("secret is larger than %d bytes", maxSecretSize))
+	}
+
+	// Without expiration a secret would outlive the TTL of the tenant, so it is required when the tenant has one
+	maxExpiresAfter := int(policy.MaxTTL / time.Minute)
 	if c.ExpiresAfter < 0 {
-		return errors.New("expires after should be greater than or equal to 0")
+		invalid("expireAfter", "expires after should be greater than or equal to 0")
+	} else if maxExpiresAfter > 0 && (c.ExpiresAfter == 0 || c.ExpiresAfter > maxExpiresAfter) {
+		invalid("expireAfter", fmt.Sprintf("expires after should be between 1 and %d minutes", maxExpiresAfter))
+	}
+
+	if c.RemainingViews < 1 {
+		invalid("expireAfterViews", "remaining views should be greater than 0")
+	} else if policy.MaxViews > 0 && c.RemainingViews > policy.MaxViews {
+		invalid("expireAfterViews", fmt.Sprintf("remaining views should be at most %d", policy.MaxViews))
 	}
 
-	if c.RemainingViews < 0 {
-		return errors.New("remaining views should be greater than 0")
+	if len(c.Passphrase) > maxPassphraseLength {
+		invalid("passphrase", "passphrase is too long")
+	}
+
+	if c.ClientEncrypted {
+		c.validateClientPayload(maxSecretSize, invalid)
+	} else if c.Algorithm != "" {
+		invalid("algorithm", "algorithm is only allowed for client encrypted secrets")
+	}
+
+	if len(fields) > 0 {
+		return domain.NewValidationError(fields)
 	}
 
 	return nil
 }
 
+// validateFile checks a file shared instead of a secret text
+func (c CreateSecretRequest) validateFile(maxFileSize int, invalid func(field string, message string)) {
+	if c.SecretText != "" {
+		invalid("secret", "secret text can not be sent with a file")
+	}
+
+	if c.File.Size == 0 {
+		invalid("file", "file is empty")
+	} else if c.File.Size > int64(maxFileSize) {
+		invalid("file", fmt.Sprintf("file is larger than %d bytes", maxFileSize))
+	}
+
+	if len(c.File.Name) > maxFileNameLength {
+		invalid("file", "file name is too long")
+	}
+
+	// The server can not tell a client encrypted file from any other file
+	if c.ClientEncrypted {
+		invalid("clientEncrypted", "files can not be client encrypted")
+	}
+}
+
+// validateClientPayload checks the structure and size of a client encrypted envelope, it can not be decrypted here
+func (c CreateSecretRequest) validateClientPayload(maxSecretSize int, invalid func(field string, message string)) {
+	if c.Passphrase != "" {
+		invalid("passphrase", "passphrase is not supported for client encrypted secrets")
+	}
+
+	algorithm, algorithmErr := security.ParseAlgorithmName(c.Algorithm)
+	if algorithmErr != nil {
+		invalid("algorithm", "unsupported algorithm")
+	}
+
+	// An empty or oversized secret is already reported, it is not decoded
+	if c.SecretText == "" || len(c.SecretText) > maxSecretSize {
+		return
+	}
+
+	env, err := security.DecodeEnvelope(c.SecretText)
+	if err != nil {
+		invalid("secret", "invalid encrypted payload")
+	} else if algorithmErr == nil && env.Algorithm != algorithm {
+		invalid("secret", "encrypted payload does not match the algorithm")
+	}
+}
+
 func getEndOfCenturyDate() time.Time {
 	// get end of century year
 	endOfCenturyYear := time.Now().UTC().Year() + 100
@@ -1,12 +1,24 @@
 package requests
 
 import (
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/nalawade41/secret-server/internal/common/security"
+	"github.com/nalawade41/secret-server/internal/domain"
 	"github.com/stretchr/testify/assert"
 )
 
+// testMaxSecretSize and testMaxFileSize are the largest secret and file accepted by the tests
+const (
+	testMaxSecretSize = 1024
+	testMaxFileSize   = 4096
+)
+
+// testPolicy is the policy of the default tenant with the sizes of the tests
+var testPolicy = domain.TenantPolicy{MaxSecretSize: testMaxSecretSize, MaxFileSize: testMaxFileSize}
+
 // TestToDomain_NoExpiration tests the ToDomain method without expiration
 func TestToDomain_NoExpiration(t *testing.T) {
 	request := CreateSecretRequest{
@@ -53,16 +65,51 @@ func TestValidate_ValidRequest(t *testing.T) {
 		RemainingViews: 5,
 	}
 
-	err := request.Validate()
+	err := request.Validate(testPolicy)
 
 	assert.NoError(t, err, "Validate should not return an error for a valid request")
 }
 
+// TestValidate_ClientEncrypted tests the Validate method for a client encrypted envelope
+func TestValidate_ClientEncrypted(t *testing.T) {
+	request := CreateSecretRequest{
+		SecretText:      clientEnvelope(t),
+		RemainingViews:  5,
+		ClientEncrypted: true,
+		Algorithm:       security.AlgorithmNameAES256GCM,
+	}
+
+	assert.NoError(t, request.Validate(testPolicy), "Validate should accept a well formed envelope")
+
+	secret := request.ToDomain()
+	assert.True(t, secret.ClientEncrypted)
+	assert.Equal(t, security.AlgorithmNameAES256GCM, secret.Algorithm)
+}
+
+// TestValidate_File tests the Validate method for a file shared instead of a secret text
+func TestValidate_File(t *testing.T) {
+	request := CreateSecretRequest{
+		RemainingViews: 1,
+		File:           &domain.File{Name: "server.pem", ContentType: "application/x-pem-file", Size: 27},
+	}
+
+	assert.NoError(t, request.Validate(testPolicy), "Validate should accept a file without secret text")
+	assert.Equal(t, request.File, request.ToDomain().File)
+}
+
+// clientEnvelope returns an envelope as a client would send it
+func clientEnvelope(t *testing.T) string {
+	env, err := security.SealEnvelope(make([]byte, 32), "", []byte("client secret"))
+	assert.NoError(t, err)
+	return env.Encode()
+}
+
 // TestValidate_InvalidRequests tests the Validate method for various invalid requests
 func TestValidate_InvalidRequests(t *testing.T) {
 	tests := []struct {
 		name     string
 		request  CreateSecretRequest
+		field    string
 		expected string
 	}{
 		{
@@ -72,6 +119,7 @@ func TestValidate_InvalidRequests(t *testing.T) {
 				ExpiresAfter:   10,
 				RemainingViews: 5,
 			},
+			field:    "secret",
 			expected: "secret text is required",
 		},
 		{
@@ -81,6 +129,7 @@ func TestValidate_InvalidRequests(t *testing.T) {
 				ExpiresAfter:   -5,
 				RemainingViews: 5,
 			},
+			field:    "expireAfter",
 			expected: "expires after should be greater than or equal to 0",
 		},
 		{
@@ -90,19 +139,197 @@ func TestValidate_InvalidRequests(t *testing.T) {
 				ExpiresAfter:   10,
 				RemainingViews: -5,
 			},
+			field:    "expireAfterViews",
+			expected: "remaining views should be greater than 0",
+		},
+		{
+			name: "Zero Remaining Views",
+			request: CreateSecretRequest{
+				SecretText:     "Zero views",
+				ExpiresAfter:   10,
+				RemainingViews: 0,
+			},
+			field:    "expireAfterViews",
 			expected: "remaining views should be greater than 0",
 		},
+		{
+			name: "Secret Too Large",
+			request: CreateSecretRequest{
+				SecretText:     strings.Repeat("a", testMaxSecretSize+1),
+				RemainingViews: 5,
+			},
+			field:    "secret",
+			expected: "secret is larger than 1024 bytes",
+		},
+		{
+			name: "Passphrase Too Long",
+			request: CreateSecretRequest{
+				SecretText:     "Long passphrase",
+				ExpiresAfter:   10,
+				RemainingViews: 5,
+				Passphrase:     strings.Repeat("a", maxPassphraseLength+1),
+			},
+			field:    "passphrase",
+			expected: "passphrase is too long",
+		},
+		{
+			name: "Algorithm Without Client Encryption",
+			request: CreateSecretRequest{
+				SecretText:     "Plain secret",
+				RemainingViews: 5,
+				Algorithm:      security.AlgorithmNameAES256GCM,
+			},
+			field:    "algorithm",
+			expected: "algorithm is only allowed for client encrypted secrets",
+		},
+		{
+			name: "Client Encrypted Unknown Algorithm",
+			request: CreateSecretRequest{
+				SecretText:      clientEnvelope(t),
+				RemainingViews:  5,
+				ClientEncrypted: true,
+				Algorithm:       "ROT13",
+			},
+			field:    "algorithm",
+			expected: "unsupported algorithm",
+		},
+		{
+			name: "Client Encrypted Invalid Payload",
+			request: CreateSecretRequest{
+				SecretText:      "not an envelope",
+				RemainingViews:  5,
+				ClientEncrypted: true,
+				Algorithm:       security.AlgorithmNameAES256GCM,
+			},
+			field:    "secret",
+			expected: "invalid encrypted payload",
+		},
+		{
+			name: "Client Encrypted Payload Too Large",
+			request: CreateSecretRequest{
+				SecretText:      strings.Repeat("A", testMaxSecretSize+1),
+				RemainingViews:  5,
+				ClientEncrypted: true,
+				Algorithm:       security.AlgorithmNameAES256GCM,
+			},
+			field:    "secret",
+			expected: "secret is larger than 1024 bytes",
+		},
+		{
+			name: "Client Encrypted With Passphrase",
+			request: CreateSecretRequest{
+				SecretText:      clientEnvelope(t),
+				RemainingViews:  5,
+				ClientEncrypted: true,
+				Algorithm:       security.AlgorithmNameAES256GCM,
+				Passphrase:      "correct horse",
+			},
+			field:    "passphrase",
+			expected: "passphrase is not supported for client encrypted secrets",
+		},
+		{
+			name: "File With Secret Text",
+			request: CreateSecretRequest{
+				SecretText:     "Valid secret",
+				RemainingViews: 1,
+				File:           &domain.File{Name: "server.pem", Size: 7},
+			},
+			field:    "secret",
+			expected: "secret text can not be sent with a file",
+		},
+		{
+			name: "Empty File",
+			request: CreateSecretRequest{
+				RemainingViews: 1,
+				File:           &domain.File{Name: "server.pem"},
+			},
+			field:    "file",
+			expected: "file is empty",
+		},
+		{
+			name: "File Too Large",
+			request: CreateSecretRequest{
+				RemainingViews: 1,
+				File:           &domain.File{Name: "server.pem", Size: testMaxFileSize + 1},
+			},
+			field:    "file",
+			expected: "file is larger than 4096 bytes",
+		},
+		{
+			name: "File Name Too Long",
+			request: CreateSecretRequest{
+				RemainingViews: 1,
+				File:           &domain.File{Name: strings.Repeat("a", 256), Size: 7},
+			},
+			field:    "file",
+			expected: "file name is too long",
+		},
+		{
+			name: "Client Encrypted File",
+			request: CreateSecretRequest{
+				RemainingViews:  1,
+				ClientEncrypted: true,
+				Algorithm:       security.AlgorithmNameAES256GCM,
+				File:            &domain.File{Name: "server.pem", Size: 7},
+			},
+			field:    "clientEncrypted",
+			expected: "files can not be client encrypted",
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := tt.request.Validate()
-			assert.Error(t, err)
-			assert.Equal(t, tt.expected, err.Error())
+			err := tt.request.Validate(testPolicy)
+			assert.ErrorIs(t, err, domain.ErrValidation)
+
+			var validationErr *domain.Error
+			if assert.ErrorAs(t, err, &validationErr) {
+				assert.Equal(t, []domain.FieldError{{Field: tt.field, Message: tt.expected}}, validationErr.Fields)
+			}
 		})
 	}
 }
 
+// TestValidate_AllFields tests that Validate reports every invalid field at once
+func TestValidate_AllFields(t *testing.T) {
+	request := CreateSecretRequest{
+		ExpiresAfter:   -1,
+		RemainingViews: -1,
+	}
+
+	var validationErr *domain.Error
+	if assert.ErrorAs(t, request.Validate(testPolicy), &validationErr) {
+		assert.Equal(t, "invalid_input", validationErr.Code)
+		assert.Equal(t, []domain.FieldError{
+			{Field: "secret", Message: "secret text is required"},
+			{Field: "expireAfter", Message: "expires after should be greater than or equal to 0"},
+			{Field: "expireAfterViews", Message: "remaining views should be greater than 0"},
+		}, validationErr.Fields)
+	}
+}
+
+// TestValidate_TenantPolicy tests that the TTL and views of a tenant are enforced
+func TestValidate_TenantPolicy(t *testing.T) {
+	policy := domain.TenantPolicy{MaxTTL: time.Hour, MaxViews: 3, MaxSecretSize: 16, MaxFileSize: testMaxFileSize}
+
+	assert.NoError(t, CreateSecretRequest{SecretText: "secret", ExpiresAfter: 60, RemainingViews: 3}.Validate(policy))
+
+	var validationErr *domain.Error
+	request := CreateSecretRequest{SecretText: strings.Repeat("a", 17), ExpiresAfter: 0, RemainingViews: 4}
+	if assert.ErrorAs(t, request.Validate(policy), &validationErr) {
+		assert.Equal(t, []domain.FieldError{
+			{Field: "secret", Message: "secret is larger than 16 bytes"},
+			{Field: "expireAfter", Message: "expires after should be between 1 and 60 minutes"},
+			{Field: "expireAfterViews", Message: "remaining views should be at most 3"},
+		}, validationErr.Fields)
+	}
+
+	request = CreateSecretRequest{SecretText: "secret", ExpiresAfter: 61, RemainingViews: 1}
+	if assert.ErrorAs(t, request.Validate(policy), &validationErr) {
+		assert.Equal(t, "expireAfter", validationErr.Fields[0].Field)
+	}
+}
+
 // TestGetEndOfCenturyDate tests the getEndOfCenturyDate function
 func TestGetEndOfCenturyDate(t *testing.T) {
 	endOfCentury := getEndOfCenturyDate()
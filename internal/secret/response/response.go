@@ -1,25 +1,83 @@
 package response
 
 import (
-	"github.com/nalawade41/secret-server/internal/domain"
+	"encoding/xml"
 	"time"
+
+	"github.com/nalawade41/secret-server/internal/domain"
 )
 
 type SecretResponse struct {
-	Hash           string    `xml:"hash" json:"hash"`
-	SecretText     string    `xml:"secretText" json:"secretText"`
-	CreatedAt      time.Time `xml:"createdAt" json:"createdAt"`
-	ExpiresAt      time.Time `xml:"expiresAt" json:"expiresAt"`
-	RemainingViews int       `xml:"remainingViews" json:"remainingViews"`
+	Hash string `xml:"hash" json:"hash" yaml:"hash"`
+	Key  string `xml:"key,omitempty" json:"key,omitempty" yaml:"key,omitempty"`
+	URL  string `xml:"url,omitempty" json:"url,omitempty" yaml:"url,omitempty"`
+	// RevocationToken is only returned to the creator, it is needed to delete the secret
+	RevocationToken string    `xml:"revocationToken,omitempty" json:"revocationToken,omitempty" yaml:"revocationToken,omitempty"`
+	SecretText      string    `xml:"secretText" json:"secretText" yaml:"secretText"`
+	CreatedAt       time.Time `xml:"createdAt" json:"createdAt" yaml:"createdAt"`
+	ExpiresAt       time.Time `xml:"expiresAt" json:"expiresAt" yaml:"expiresAt"`
+	RemainingViews  int       `xml:"remainingViews" json:"remainingViews" yaml:"remainingViews"`
+	// ClientEncrypted tells the reader that SecretText is an envelope to decrypt with the key from the link
+	ClientEncrypted bool   `xml:"clientEncrypted,omitempty" json:"clientEncrypted,omitempty" yaml:"clientEncrypted,omitempty"`
+	Algorithm       string `xml:"algorithm,omitempty" json:"algorithm,omitempty" yaml:"algorithm,omitempty"`
 }
 
 // NewSecretResponse converts data to SecretResponse
 func NewSecretResponse(data domain.Secret) SecretResponse {
 	return SecretResponse{
-		Hash:           data.Hash,
-		SecretText:     data.SecretText,
-		CreatedAt:      data.CreatedAt,
-		ExpiresAt:      data.ExpiresAt,
-		RemainingViews: data.RemainingViews,
+		Hash:            data.Hash,
+		Key:             data.Key,
+		RevocationToken: data.RevocationToken,
+		SecretText:      data.SecretText,
+		CreatedAt:       data.CreatedAt,
+		ExpiresAt:       data.ExpiresAt,
+		RemainingViews:  data.RemainingViews,
+		ClientEncrypted: data.ClientEncrypted,
+		Algorithm:       data.Algorithm,
+	}
+}
+
+// SecretStatusResponse is the metadata of a secret shown to its creator
+type SecretStatusResponse struct {
+	Hash           string      `xml:"hash" json:"hash" yaml:"hash"`
+	CreatedAt      time.Time   `xml:"createdAt" json:"createdAt" yaml:"createdAt"`
+	ExpiresAt      time.Time   `xml:"expiresAt" json:"expiresAt" yaml:"expiresAt"`
+	RemainingViews int         `xml:"remainingViews" json:"remainingViews" yaml:"remainingViews"`
+	Consumed       bool        `xml:"consumed" json:"consumed" yaml:"consumed"`
+	Expired        bool        `xml:"expired" json:"expired" yaml:"expired"`
+	ReadAt         []time.Time `xml:"readAt" json:"readAt" yaml:"readAt"`
+}
+
+// NewSecretStatusResponse converts the status to SecretStatusResponse
+func NewSecretStatusResponse(status domain.SecretStatus) SecretStatusResponse {
+	readAt := status.ReadAt
+	if readAt == nil {
+		readAt = []time.Time{}
+	}
+
+	return SecretStatusResponse{
+		Hash:           status.Hash,
+		CreatedAt:      status.CreatedAt,
+		ExpiresAt:      status.ExpiresAt,
+		RemainingViews: status.RemainingViews,
+		Consumed:       status.Consumed,
+		Expired:        status.Expired,
+		ReadAt:         readAt,
+	}
+}
+
+// SecretListResponse is a page of the secrets of the caller, NextCursor is empty on the last page
+type SecretListResponse struct {
+	XMLName    xml.Name               `xml:"secrets" json:"-" yaml:"-"`
+	Secrets    []SecretStatusResponse `xml:"secret" json:"secrets" yaml:"secrets"`
+	NextCursor string                 `xml:"nextCursor,omitempty" json:"nextCursor,omitempty" yaml:"nextCursor,omitempty"`
+}
+
+// NewSecretListResponse converts the statuses and the cursor of the next page to SecretListResponse
+func NewSecretListResponse(statuses []domain.SecretStatus, next string) SecretListResponse {
+	list := SecretListResponse{Secrets: make([]SecretStatusResponse, 0, len(statuses)), NextCursor: next}
+	for _, status := range statuses {
+		list.Secrets = append(list.Secrets, NewSecretStatusResponse(status))
 	}
+	return list
 }
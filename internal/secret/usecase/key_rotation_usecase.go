@@ -0,0 +1,93 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nalawade41/secret-server/internal/common/logger"
+	"github.com/nalawade41/secret-server/internal/domain"
+	"github.com/pkg/errors"
+)
+
+type KeyRotationUseCase struct {
+	SecretRepo domain.SecretRepository
+	Encryptor  domain.Encryptor
+}
+
+// RewrapSecrets re-wraps the data keys of all secrets that are not wrapped by the active master key.
+// It resumes after the cursor of the given progress and hands the progress to checkpoint after every
+// page, so an interrupted run can continue where it stopped.
+func (s KeyRotationUseCase) RewrapSecrets(ctx context.Context, progress domain.RewrapProgress, batchSize int, checkpoint func(domain.RewrapProgress) error) (domain.RewrapProgress, error) {
+	activeKeyID, err := s.Encryptor.ActiveKeyID(ctx)
+	if err != nil {
+		return progress, errors.Wrap(err, fmt.Sprintf("failed to resolve active master key: %v", err))
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return progress, err
+		}
+
+		secrets, next, err := s.SecretRepo.ListSecrets(ctx, progress.Cursor, batchSize)
+		if err != nil {
+			return progress, errors.Wrap(err, fmt.Sprintf("failed to list secrets: %v", err))
+		}
+
+		for _, secret := range secrets {
+			progress.Scanned++
+			s.rewrapSecret(ctx, secret, activeKeyID, &progress)
+		}
+
+		progress.Cursor = next
+		if err := checkpoint(progress); err != nil {
+			return progress, errors.Wrap(err, fmt.Sprintf("failed to store checkpoint: %v", err))
+		}
+
+		if next == "" {
+			return progress, nil
+		}
+	}
+}
+
+// rewrapSecret re-wraps the data key of one secret and counts the outcome, failures are logged
+// and left for the next run as the secret stays readable with the old master key
+func (s KeyRotationUseCase) rewrapSecret(ctx context.Context, secret domain.Secret, activeKeyID string, progress *domain.RewrapProgress) {
+	// Secrets written before data keys need their link key to be migrated, they are re-encrypted when read with
+	// views left and lose their ciphertext with the last view. Until then they are counted so they can be followed.
+	// Tombstones and client encrypted secrets have no data key either but nothing to migrate.
+	if secret.WrappedKey == "" && secret.SecretText != "" && !secret.ClientEncrypted && !secret.ExpiresAt.Before(time.Now().UTC()) {
+		progress.Legacy++
+		return
+	}
+
+	// Expired secrets can not be read anymore
+	if secret.WrappedKey == "" || secret.KeyID == activeKeyID || secret.ExpiresAt.Before(time.Now().UTC()) {
+		progress.Skipped++
+		return
+	}
+
+	wrappedKey, keyID, err := s.Encryptor.RewrapDataKey(ctx, secret.WrappedKey, secret.KeyID)
+	if err != nil {
+		logger.Errorf("failed to re-wrap data key of secret %s: %v", secret.Hash, err)
+		progress.Failed++
+		return
+	}
+
+	// The listing covers every tenant, the secret is updated in its own
+	err = s.SecretRepo.UpdateWrappedKey(domain.ContextWithTenant(ctx, secret.Tenant), secret.Hash, secret.WrappedKey, wrappedKey, keyID)
+	if errors.Is(err, domain.ErrSecretChanged) {
+		// The secret was read, re-encrypted or deleted since it was listed
+		progress.Skipped++
+		return
+	}
+	if err != nil {
+		logger.Errorf("failed to store re-wrapped data key of secret %s: %v", secret.Hash, err)
+		progress.Failed++
+		return
+	}
+
+	progress.Rewrapped++
+}
+
+var _ domain.KeyRotationUseCase = (*KeyRotationUseCase)(nil)
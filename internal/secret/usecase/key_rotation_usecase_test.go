@@ -0,0 +1,204 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/nalawade41/secret-server/internal/common/security"
+	"github.com/nalawade41/secret-server/internal/domain"
+	"github.com/nalawade41/secret-server/internal/secret/repository/memory"
+	"github.com/nalawade41/secret-server/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRewrapSecrets_Success(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockSecretRepository(ctrl)
+	mockEncryptor := mocks.NewMockEncryptor(ctrl)
+
+	useCase := KeyRotationUseCase{SecretRepo: mockRepo, Encryptor: mockEncryptor}
+
+	expiresAt := time.Now().Add(10 * time.Minute)
+	firstPage := []domain.Secret{
+		{Hash: "old", WrappedKey: "old wrapped", KeyID: "2023", ExpiresAt: expiresAt},
+		{Hash: "current", WrappedKey: "current wrapped", KeyID: "2024", ExpiresAt: expiresAt},
+	}
+	secondPage := []domain.Secret{
+		{Hash: "nokey", SecretText: "link key only", ExpiresAt: expiresAt},
+		{Hash: "client", SecretText: "client envelope", ClientEncrypted: true, ExpiresAt: expiresAt},
+		{Hash: "tombstone", ExpiresAt: expiresAt},
+		{Hash: "expired", WrappedKey: "expired wrapped", KeyID: "2023", ExpiresAt: time.Now().Add(-10 * time.Minute)},
+		{Hash: "changed", WrappedKey: "changed wrapped", KeyID: "2023", ExpiresAt: expiresAt},
+	}
+
+	mockEncryptor.EXPECT().ActiveKeyID(gomock.Any()).Return("2024", nil)
+	mockRepo.EXPECT().ListSecrets(gomock.Any(), "", 2).Return(firstPage, "current", nil)
+	mockRepo.EXPECT().ListSecrets(gomock.Any(), "current", 2).Return(secondPage, "", nil)
+
+	// Only the secrets wrapped by an older master key that can still be read are re-wrapped
+	mockEncryptor.EXPECT().RewrapDataKey(gomock.Any(), "old wrapped", "2023").Return("new wrapped", "2024", nil)
+	mockRepo.EXPECT().UpdateWrappedKey(gomock.Any(), "old", "old wrapped", "new wrapped", "2024").Return(nil)
+	mockEncryptor.EXPECT().RewrapDataKey(gomock.Any(), "changed wrapped", "2023").Return("new wrapped", "2024", nil)
+	mockRepo.EXPECT().UpdateWrappedKey(gomock.Any(), "changed", "changed wrapped", "new wrapped", "2024").Return(domain.ErrSecretChanged)
+
+	var checkpoints []domain.RewrapProgress
+	progress, err := useCase.RewrapSecrets(context.Background(), domain.RewrapProgress{}, 2, func(progress domain.RewrapProgress) error {
+		checkpoints = append(checkpoints, progress)
+		return nil
+	})
+
+	assert.NoError(t, err)
+	// The secret without a data key is left for its next read and counted apart
+	assert.Equal(t, domain.RewrapProgress{Scanned: 7, Rewrapped: 1, Skipped: 5, Legacy: 1}, progress)
+
+	// A checkpoint is stored after every page
+	assert.Len(t, checkpoints, 2)
+	assert.Equal(t, "current", checkpoints[0].Cursor)
+	assert.Equal(t, 2, checkpoints[0].Scanned)
+}
+
+func TestRewrapSecrets_ResumesFromCheckpoint(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockSecretRepository(ctrl)
+	mockEncryptor := mocks.NewMockEncryptor(ctrl)
+
+	useCase := KeyRotationUseCase{SecretRepo: mockRepo, Encryptor: mockEncryptor}
+
+	// The counts of the earlier run are carried on
+	mockEncryptor.EXPECT().ActiveKeyID(gomock.Any()).Return("2024", nil)
+	mockRepo.EXPECT().ListSecrets(gomock.Any(), "checkpoint", 100).Return(nil, "", nil)
+
+	progress, err := useCase.RewrapSecrets(context.Background(), domain.RewrapProgress{Cursor: "checkpoint", Scanned: 10, Rewrapped: 7}, 100, func(domain.RewrapProgress) error {
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, domain.RewrapProgress{Scanned: 10, Rewrapped: 7}, progress)
+}
+
+func TestRewrapSecrets_RewrapFailureIsCounted(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockSecretRepository(ctrl)
+	mockEncryptor := mocks.NewMockEncryptor(ctrl)
+
+	useCase := KeyRotationUseCase{SecretRepo: mockRepo, Encryptor: mockEncryptor}
+
+	secrets := []domain.Secret{
+		{Hash: "unknown", WrappedKey: "wrapped", KeyID: "2020", ExpiresAt: time.Now().Add(10 * time.Minute)},
+		{Hash: "unavailable", WrappedKey: "wrapped", KeyID: "2023", ExpiresAt: time.Now().Add(10 * time.Minute)},
+	}
+
+	mockEncryptor.EXPECT().ActiveKeyID(gomock.Any()).Return("2024", nil)
+	mockRepo.EXPECT().ListSecrets(gomock.Any(), "", 10).Return(secrets, "", nil)
+	mockEncryptor.EXPECT().RewrapDataKey(gomock.Any(), "wrapped", "2020").Return("", "", security.ErrUnknownKeyID)
+	mockEncryptor.EXPECT().RewrapDataKey(gomock.Any(), "wrapped", "2023").Return("new wrapped", "2024", nil)
+	mockRepo.EXPECT().UpdateWrappedKey(gomock.Any(), "unavailable", "wrapped", "new wrapped", "2024").Return(errors.New("throttled"))
+
+	progress, err := useCase.RewrapSecrets(context.Background(), domain.RewrapProgress{}, 10, func(domain.RewrapProgress) error {
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, progress.Failed)
+}
+
+func TestRewrapSecrets_ListError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockSecretRepository(ctrl)
+	mockEncryptor := mocks.NewMockEncryptor(ctrl)
+
+	useCase := KeyRotationUseCase{SecretRepo: mockRepo, Encryptor: mockEncryptor}
+
+	mockEncryptor.EXPECT().ActiveKeyID(gomock.Any()).Return("2024", nil)
+	mockRepo.EXPECT().ListSecrets(gomock.Any(), "checkpoint", 10).Return(nil, "", errors.New("scan error"))
+
+	progress, err := useCase.RewrapSecrets(context.Background(), domain.RewrapProgress{Cursor: "checkpoint"}, 10, func(domain.RewrapProgress) error {
+		return nil
+	})
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to list secrets")
+	assert.Equal(t, "checkpoint", progress.Cursor, "The cursor must stay at the last stored checkpoint")
+}
+
+func TestRewrapSecrets_CheckpointError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockSecretRepository(ctrl)
+	mockEncryptor := mocks.NewMockEncryptor(ctrl)
+
+	useCase := KeyRotationUseCase{SecretRepo: mockRepo, Encryptor: mockEncryptor}
+
+	mockEncryptor.EXPECT().ActiveKeyID(gomock.Any()).Return("2024", nil)
+	mockRepo.EXPECT().ListSecrets(gomock.Any(), "", 10).Return(nil, "next", nil)
+
+	_, err := useCase.RewrapSecrets(context.Background(), domain.RewrapProgress{}, 10, func(domain.RewrapProgress) error {
+		return errors.New("disk full")
+	})
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to store checkpoint")
+}
+
+func TestRewrapSecrets_RetireMasterKey(t *testing.T) {
+	oldKey := make([]byte, 32)
+	newKey := make([]byte, 32)
+	newKey[0] = 1
+
+	repo := memory.NewSecretManagerRepository()
+
+	// Secrets created while the old master key was active
+	before, err := security.NewKeyring("2023", map[string][]byte{"2023": oldKey})
+	assert.NoError(t, err)
+	secretUseCase := SecretManagerUseCase{
+		SecretRepo:  repo,
+		Encryptor:   security.RealEncryptor{KeyProvider: before},
+		IDGenerator: security.IDGenerator{Length: 22, Encoding: security.IDEncodingBase62},
+	}
+
+	var created []domain.Secret
+	for i := 0; i < 5; i++ {
+		secret, err := secretUseCase.CreateSecretMessage(context.Background(), domain.Secret{
+			SecretText:     "This is a test secret",
+			ExpiresAt:      time.Now().Add(10 * time.Minute),
+			RemainingViews: 1,
+			CreatedAt:      time.Now().UTC(),
+		})
+		assert.NoError(t, err)
+		created = append(created, secret)
+	}
+
+	// Rotate: the new key is active, the old one is decrypt only until the re-wrap is done
+	during, err := security.NewKeyring("2024", map[string][]byte{"2023": oldKey, "2024": newKey})
+	assert.NoError(t, err)
+	rotation := KeyRotationUseCase{SecretRepo: repo, Encryptor: security.RealEncryptor{KeyProvider: during}}
+
+	progress, err := rotation.RewrapSecrets(context.Background(), domain.RewrapProgress{}, 2, func(domain.RewrapProgress) error {
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 5, progress.Rewrapped)
+
+	// Once re-wrapped the old master key can be removed and every secret is still readable
+	after, err := security.NewKeyring("2024", map[string][]byte{"2024": newKey})
+	assert.NoError(t, err)
+	secretUseCase.Encryptor = security.RealEncryptor{KeyProvider: after}
+
+	for _, secret := range created {
+		result, err := secretUseCase.GetSecretMessage(context.Background(), secret.Hash, secret.Key, "")
+		assert.NoError(t, err)
+		assert.Equal(t, "This is a test secret", result.SecretText)
+	}
+}
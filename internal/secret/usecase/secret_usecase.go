@@ -2,7 +2,9 @@ package usecase
 
 import (
 	"context"
+	"crypto/subtle"
 	"fmt"
+	"io"
 	"time"
 
 	"github.com/nalawade41/secret-server/internal/common/logger"
@@ -10,73 +12,642 @@ import (
 	"github.com/pkg/errors"
 )
 
+// maxIDAttempts is how often a new id is generated when the previous one was already taken
+const maxIDAttempts = 3
+
 type SecretManagerUseCase struct {
-	SecretRepo domain.SecretRepository
-	Encryptor  domain.Encryptor
+	SecretRepo  domain.SecretRepository
+	Encryptor   domain.Encryptor
+	IDGenerator domain.IDGenerator
+	// BlobStore holds the encrypted content of file secrets
+	BlobStore domain.BlobStore
+	// Quotas charges the secrets to the quotas of their creator and their tenant, nil without quotas
+	Quotas domain.QuotaUseCase
+	// MaxPassphraseAttempts is the number of wrong passphrases after which a secret is burned
+	MaxPassphraseAttempts int
 }
 
-// CreateSecretMessage creates a secret message and stores it in the repository
+// CreateSecretMessage charges the secret to the quotas, then creates it and stores it in the repository.
+// A secret that is not stored gives its charge back.
 func (s SecretManagerUseCase) CreateSecretMessage(ctx context.Context, message domain.Secret) (domain.Secret, error) {
-	// Generate a unique hash for the secret
-	hash := s.Encryptor.GenerateSHA256Hash(message.SecretText, message.CreatedAt.String())
+	// Secrets of authenticated callers are listed to them and can be managed without the token
+	if principal, ok := domain.PrincipalFromContext(ctx); ok {
+		message.Owner = principal.Subject
+	}
+
+	// The repository keeps the secret in the tenant of the request, its link only resolves there
+	message.Tenant = domain.TenantFromContext(ctx)
+
+	// The quotas count the plain size, the encrypted secret is larger by a fixed overhead
+	message.QuotaBytes = int64(len(message.SecretText))
+	if message.File != nil {
+		message.QuotaBytes += message.File.Size
+	}
 
-	// Set the hash in the secret
-	message.Hash = hash
+	var quota domain.QuotaStatus
+	if s.Quotas != nil {
+		var err error
+		if quota, err = s.Quotas.Reserve(ctx, message); err != nil {
+			return domain.Secret{}, errors.Wrap(err, "failed to reserve quota")
+		}
+	}
+
+	stored, err := s.createSecret(ctx, message)
+	if err != nil {
+		s.releaseQuota(ctx, message)
+		return domain.Secret{}, err
+	}
+	stored.Quota = quota
+
+	return stored, nil
+}
+
+// createSecret encrypts the secret and stores it in the repository
+func (s SecretManagerUseCase) createSecret(ctx context.Context, message domain.Secret) (domain.Secret, error) {
+	// Client encrypted secrets are stored as they are, their key never reaches the server
+	if message.ClientEncrypted {
+		return s.storeSecret(ctx, message)
+	}
+
+	// Generate the encryption key, it is only handed back to the creator and never stored
+	key, err := s.Encryptor.GenerateKey()
+	if err != nil {
+		return domain.Secret{}, errors.Wrap(err, fmt.Sprintf("failed to generate secret key: %v", err))
+	}
+
+	// Generate the data key of the secret, only its wrapped form is stored
+	dataKey, err := s.Encryptor.GenerateDataKey(ctx, key)
+	if err != nil {
+		return domain.Secret{}, errors.Wrap(err, fmt.Sprintf("failed to generate data key: %v", err))
+	}
+
+	keys := layerKeys{contentKey: dataKey.ContentKey}
+
+	// A passphrase adds an inner layer, the passphrase itself is never stored
+	if message.Passphrase != "" {
+		keys.passphraseKey, message.PassphraseKDF, err = s.newPassphraseKey(dataKey.ContentKey, message.Passphrase)
+		if err != nil {
+			return domain.Secret{}, err
+		}
+		message.Passphrase = ""
+	}
 
 	// Encrypt the message
-	encryptedText, err := s.Encryptor.EncryptMessage(message.SecretText, hash)
+	encryptedText, err := s.seal(message.SecretText, keys)
 	if err != nil {
-		return domain.Secret{}, errors.Wrap(err, fmt.Sprintf("failed to encrypt secret: %v", err))
+		return domain.Secret{}, err
 	}
 
 	message.SecretText = encryptedText
+	message.WrappedKey = dataKey.WrappedKey
+	message.KeyID = dataKey.KeyID
+
+	// The file is encrypted in the same layers as the secret, the secret only points to its blob
+	if message.File != nil {
+		if err := s.storeFile(ctx, &message, keys); err != nil {
+			return domain.Secret{}, err
+		}
+	}
+
+	stored, err := s.storeSecret(ctx, message)
+	if err != nil {
+		s.deleteBlob(ctx, message.BlobKey)
+		return domain.Secret{}, err
+	}
+	message = stored
+
+	message.Key = key
+
+	return message, nil
+}
+
+// storeFile encrypts the name and the content of the file, the content is put into the blob store under a random key.
+// The content is encrypted while the blob store reads it, so only a chunk of it is held in memory.
+func (s SecretManagerUseCase) storeFile(ctx context.Context, message *domain.Secret, keys layerKeys) error {
+	name, err := s.seal(message.File.Name, keys)
+	if err != nil {
+		return err
+	}
+
+	blobKey, err := s.IDGenerator.GenerateID()
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("failed to generate blob key: %v", err))
+	}
+
+	r, w := io.Pipe()
+	sealed := make(chan error, 1)
+	go func() {
+		err := s.sealStream(w, message.File.Content, keys)
+		w.CloseWithError(err)
+		sealed <- err
+	}()
+
+	putErr := s.BlobStore.Put(ctx, blobKey, r, s.streamSize(message.File.Size, keys))
+
+	// A store that gave up early stops the encryption on its next write
+	r.Close()
+	if err := <-sealed; err != nil && !errors.Is(err, io.ErrClosedPipe) {
+		return errors.Wrap(err, fmt.Sprintf("failed to encrypt file: %v", err))
+	}
+	if putErr != nil {
+		return errors.Wrap(putErr, fmt.Sprintf("failed to store file: %v", putErr))
+	}
+
+	message.BlobKey = blobKey
+	message.FileName = name
+	message.FileContentType = message.File.ContentType
+
+	return nil
+}
+
+// storeSecret stores the secret under a random id, the repository refuses to overwrite an existing id.
+// It also issues the revocation token of the creator, only its hash is stored.
+func (s SecretManagerUseCase) storeSecret(ctx context.Context, message domain.Secret) (domain.Secret, error) {
+	token, err := s.Encryptor.GenerateKey()
+	if err != nil {
+		return domain.Secret{}, errors.Wrap(err, fmt.Sprintf("failed to generate revocation token: %v", err))
+	}
+	message.RevocationTokenHash = s.Encryptor.GenerateSHA256Hash(token)
+
+	// The secret is removed by the storage once it expired, even when nobody reads it again
+	message.TTL = message.ExpiresAt.Unix()
+
+	for attempt := 1; ; attempt++ {
+		hash, err := s.IDGenerator.GenerateID()
+		if err != nil {
+			return domain.Secret{}, errors.Wrap(err, fmt.Sprintf("failed to generate secret id: %v", err))
+		}
+
+		// Set the hash in the secret
+		message.Hash = hash
+
+		err = s.SecretRepo.Save(ctx, message)
+		if err == nil {
+			break
+		}
+
+		if !errors.Is(err, domain.ErrSecretAlreadyExists) || attempt == maxIDAttempts {
+			return domain.Secret{}, errors.Wrap(err, fmt.Sprintf("failed to store secret: %v", err))
+		}
 
-	// Store the secret in the repository
-	if err := s.SecretRepo.Save(ctx, message); err != nil {
-		return domain.Secret{}, errors.Wrap(err, fmt.Sprintf("failed to store secret: %v", err))
+		logger.Warnf("secret id collision on attempt %d, generating a new id", attempt)
 	}
 
+	message.RevocationToken = token
+
 	return message, nil
 }
 
-// GetSecretMessage retrieves a secret from the repository and decrements the remaining views
-func (s SecretManagerUseCase) GetSecretMessage(ctx context.Context, hash string) (domain.Secret, error) {
+// RevokeSecret deletes the secret when the token matches the revocation token issued at creation.
+// Without a token the principal of the context has to own the secret.
+func (s SecretManagerUseCase) RevokeSecret(ctx context.Context, hash string, token string) error {
+	secret, err := s.SecretRepo.GetByHash(ctx, hash)
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("failed to retrieve secret: %v", err))
+	}
+
+	if err := s.authorizeCreator(ctx, secret, token); err != nil {
+		return err
+	}
+
+	if err := s.SecretRepo.DeleteSecret(ctx, hash); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("failed to delete secret: %v", err))
+	}
+
+	s.deleteBlob(ctx, secret.BlobKey)
+	s.releaseQuota(ctx, secret)
+
+	return nil
+}
+
+// GetSecretStatus returns the metadata of the secret when the token matches its revocation token, or without
+// a token to the owner of the secret. It uses a read that never returns the ciphertext and does not take a view.
+func (s SecretManagerUseCase) GetSecretStatus(ctx context.Context, hash string, token string) (domain.SecretStatus, error) {
+	secret, err := s.SecretRepo.GetMetadata(ctx, hash)
+	if err != nil {
+		return domain.SecretStatus{}, errors.Wrap(err, fmt.Sprintf("failed to retrieve secret metadata: %v", err))
+	}
+
+	if err := s.authorizeCreator(ctx, secret, token); err != nil {
+		return domain.SecretStatus{}, err
+	}
+
+	return newSecretStatus(secret, time.Now().UTC()), nil
+}
+
+// ListOwnSecrets returns the status of the secrets of the principal of the context, newest first. Anonymous
+// secrets have no owner and are never listed.
+func (s SecretManagerUseCase) ListOwnSecrets(ctx context.Context, cursor string, limit int) ([]domain.SecretStatus, string, error) {
+	principal, ok := domain.PrincipalFromContext(ctx)
+	if !ok {
+		return nil, "", domain.ErrAPIKeyRequired
+	}
+
+	secrets, next, err := s.SecretRepo.ListSecretsByOwner(ctx, principal.Subject, cursor, limit)
+	if err != nil {
+		return nil, "", errors.Wrap(err, fmt.Sprintf("failed to list secrets of %s: %v", principal.Subject, err))
+	}
+
+	now := time.Now().UTC()
+	statuses := make([]domain.SecretStatus, 0, len(secrets))
+	for _, secret := range secrets {
+		statuses = append(statuses, newSecretStatus(secret, now))
+	}
+
+	return statuses, next, nil
+}
+
+// newSecretStatus takes the status from the metadata of the secret
+func newSecretStatus(secret domain.Secret, now time.Time) domain.SecretStatus {
+	return domain.SecretStatus{
+		Hash:           secret.Hash,
+		CreatedAt:      secret.CreatedAt,
+		ExpiresAt:      secret.ExpiresAt,
+		RemainingViews: secret.RemainingViews,
+		Consumed:       secret.RemainingViews <= 0,
+		Expired:        secret.ExpiresAt.Before(now),
+		ReadAt:         secret.ReadAt,
+	}
+}
+
+// authorizeCreator lets the holder of the revocation token manage the secret. Without a token the principal
+// of the context has to own the secret, anyone else is told the token is invalid.
+func (s SecretManagerUseCase) authorizeCreator(ctx context.Context, secret domain.Secret, token string) error {
+	if token != "" {
+		return s.checkRevocationToken(secret, token)
+	}
+
+	principal, ok := domain.PrincipalFromContext(ctx)
+	if !ok || secret.Owner == "" || subtle.ConstantTimeCompare([]byte(principal.Subject), []byte(secret.Owner)) != 1 {
+		return domain.ErrInvalidRevocationToken
+	}
+
+	return nil
+}
+
+// checkRevocationToken compares the hash of the token with the stored hash in constant time.
+// Secrets created before revocation tokens have no token hash and can not be managed.
+func (s SecretManagerUseCase) checkRevocationToken(secret domain.Secret, token string) error {
+	tokenHash := s.Encryptor.GenerateSHA256Hash(token)
+	if secret.RevocationTokenHash == "" || subtle.ConstantTimeCompare([]byte(tokenHash), []byte(secret.RevocationTokenHash)) != 1 {
+		return domain.ErrInvalidRevocationToken
+	}
+
+	return nil
+}
+
+// GetSecretMessage retrieves a secret from the repository, decrypts it and consumes one view
+func (s SecretManagerUseCase) GetSecretMessage(ctx context.Context, hash string, key string, passphrase string) (domain.Secret, error) {
 	// Retrieve the secret from the repository
 	secret, err := s.SecretRepo.GetByHash(ctx, hash)
 	if err != nil {
 		return domain.Secret{}, errors.Wrap(err, fmt.Sprintf("failed to retrieve secret: %v", err))
 	}
 
-	// Check if the secret has expired or if there are no remaining views
-	if secret.ExpiresAt.Before(time.Now().UTC()) || secret.RemainingViews <= 0 {
+	// Check if the secret has expired
+	if secret.ExpiresAt.Before(time.Now().UTC()) {
 		// TODO:This part we can do asynchronously using queue services like SQS, RabbitMQ, etc.
-		// Delete the secret from the repository
+		// Delete the secret from the repository, the reader is told it expired even when the delete fails
 		if err := s.SecretRepo.DeleteSecret(ctx, hash); err != nil {
-			return domain.Secret{}, errors.Wrap(err, fmt.Sprintf("failed to delete expired or fully viewed secret: %v", err))
+			logger.Errorf("failed to delete expired secret: %v", err)
 		}
-		return domain.Secret{}, errors.New("secret expired or no remaining views")
+		s.deleteBlob(ctx, secret.BlobKey)
+		s.releaseQuota(ctx, secret)
+		return domain.Secret{}, domain.ErrSecretExpired
 	}
 
-	// Decrement the remaining views
-	secret.RemainingViews -= 1
+	// A fully viewed secret is only a tombstone kept for the status of its creator
+	if secret.RemainingViews <= 0 {
+		return domain.Secret{}, domain.ErrNoRemainingViews
+	}
 
-	// If the views reach 0 after decrementing, delete the secret
-	if secret.RemainingViews == 0 {
-		// TODO:This part we can do asynchronously using queue services like SQS, RabbitMQ, etc.
-		if err := s.SecretRepo.DeleteSecret(ctx, hash); err != nil {
-			logger.Error("failed to delete secret: %v", err)
+	// Client encrypted secrets are handed out as they are stored, the reader decrypts them.
+	// Everything else is decrypted before touching the views, so a wrong key does not consume a view.
+	ciphertext := secret.SecretText
+	plaintext := ciphertext
+	if !secret.ClientEncrypted {
+		var keys layerKeys
+		plaintext, keys, err = s.decryptSecret(ctx, secret, key, passphrase)
+		if err != nil {
+			return domain.Secret{}, err
+		}
+
+		// The file is opened before the view is taken as well, a file that can not be read does not use up a view
+		if secret.BlobKey != "" {
+			if secret.File, err = s.openFile(ctx, secret, keys); err != nil {
+				return domain.Secret{}, err
+			}
 		}
-		return secret, nil
 	}
 
-	// TODO:This part we can do asynchronously using queue services like SQS, RabbitMQ, etc.
-	// Update the remaining views in the repository
-	err = s.SecretRepo.UpdateSecretViews(ctx, hash, secret.RemainingViews)
+	// Take the view atomically, only the readers that win a view get to see the secret.
+	// The repository deletes the secret once its last view is taken.
+	consumed, err := s.SecretRepo.ConsumeView(ctx, hash)
 	if err != nil {
-		logger.Errorf("failed to update remaining views: %w", err)
+		if secret.File != nil {
+			secret.File.Content.Close()
+		}
+		return domain.Secret{}, errors.Wrap(err, "failed to consume secret view")
+	}
+
+	// Only the tombstone is kept after the last view, the file is not needed anymore once it was sent
+	if consumed.RemainingViews == 0 {
+		if secret.File != nil {
+			secret.File.Content = blobDeleter{ReadCloser: secret.File.Content, delete: func() { s.deleteBlob(ctx, secret.BlobKey) }}
+		} else {
+			s.deleteBlob(ctx, secret.BlobKey)
+		}
+		s.releaseQuota(ctx, secret)
 	}
 
+	// Secrets written before authenticated or envelope encryption can only be upgraded while the key is at hand.
+	// The last view already removed the ciphertext, there is nothing left to upgrade.
+	// Passphrase protected and client encrypted secrets are always written in the current format.
+	if consumed.RemainingViews > 0 && !secret.ClientEncrypted && secret.PassphraseKDF == "" && (secret.WrappedKey == "" || s.Encryptor.IsLegacy(ciphertext)) {
+		s.reencryptSecret(ctx, hash, plaintext, key)
+	}
+
+	secret.RemainingViews = consumed.RemainingViews
+	secret.SecretText = plaintext
+
 	return secret, nil
 }
 
+// layerKeys are the keys of the layers a secret is encrypted in, passphraseKey is empty without a passphrase
+type layerKeys struct {
+	contentKey    string
+	passphraseKey string
+}
+
+// seal encrypts the plaintext in the passphrase layer, if there is one, and then with the content key
+func (s SecretManagerUseCase) seal(plaintext string, keys layerKeys) (string, error) {
+	var err error
+	if keys.passphraseKey != "" {
+		plaintext, err = s.Encryptor.EncryptMessage(plaintext, keys.passphraseKey)
+		if err != nil {
+			return "", errors.Wrap(err, fmt.Sprintf("failed to encrypt secret with passphrase: %v", err))
+		}
+	}
+
+	ciphertext, err := s.Encryptor.EncryptMessage(plaintext, keys.contentKey)
+	if err != nil {
+		return "", errors.Wrap(err, fmt.Sprintf("failed to encrypt secret: %v", err))
+	}
+
+	return ciphertext, nil
+}
+
+// open decrypts a ciphertext sealed with the keys
+func (s SecretManagerUseCase) open(ciphertext string, keys layerKeys) (string, error) {
+	plaintext, err := s.Encryptor.DecryptMessage(ciphertext, keys.contentKey)
+	if err != nil {
+		return "", err
+	}
+
+	if keys.passphraseKey != "" {
+		return s.Encryptor.DecryptMessage(plaintext, keys.passphraseKey)
+	}
+
+	return plaintext, nil
+}
+
+// sealStream encrypts the plaintext into dst like seal, chunk by chunk as it is read from the plaintext
+func (s SecretManagerUseCase) sealStream(dst io.Writer, plaintext io.Reader, keys layerKeys) error {
+	outer, err := s.Encryptor.EncryptStream(dst, keys.contentKey)
+	if err != nil {
+		return err
+	}
+
+	w := outer
+	if keys.passphraseKey != "" {
+		if w, err = s.Encryptor.EncryptStream(outer, keys.passphraseKey); err != nil {
+			return err
+		}
+	}
+
+	if _, err := io.Copy(w, plaintext); err != nil {
+		return err
+	}
+
+	// The passphrase layer has to write its final chunk before the outer layer can
+	if w != outer {
+		if err := w.Close(); err != nil {
+			return err
+		}
+	}
+
+	return outer.Close()
+}
+
+// openStream returns a reader that decrypts a ciphertext sealed with sealStream chunk by chunk
+func (s SecretManagerUseCase) openStream(ciphertext io.Reader, keys layerKeys) (io.Reader, error) {
+	r, err := s.Encryptor.DecryptStream(ciphertext, keys.contentKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if keys.passphraseKey != "" {
+		if r, err = s.Encryptor.DecryptStream(r, keys.passphraseKey); err != nil {
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+// streamSize returns the length of the ciphertext sealStream writes for a plaintext of the size
+func (s SecretManagerUseCase) streamSize(size int64, keys layerKeys) int64 {
+	if keys.passphraseKey != "" {
+		size = s.Encryptor.StreamSize(size)
+	}
+
+	return s.Encryptor.StreamSize(size)
+}
+
+// decryptSecret decrypts a secret encrypted by the server with the key from the link and the passphrase,
+// it also returns the keys of the secret to decrypt its file
+func (s SecretManagerUseCase) decryptSecret(ctx context.Context, secret domain.Secret, key string, passphrase string) (string, layerKeys, error) {
+	if key == "" {
+		return "", layerKeys{}, domain.ErrKeyRequired
+	}
+
+	// Secrets created before envelope encryption are encrypted with the link key alone
+	keys := layerKeys{contentKey: key}
+	if secret.WrappedKey != "" {
+		var err error
+		keys.contentKey, err = s.Encryptor.UnwrapDataKey(ctx, key, secret.WrappedKey, secret.KeyID)
+		if err != nil {
+			return "", layerKeys{}, errors.Wrap(err, fmt.Sprintf("failed to unwrap data key: %v", err))
+		}
+	}
+
+	// The key is part of the link, a key that does not decrypt the secret is a link that does not exist
+	plaintext, err := s.Encryptor.DecryptMessage(secret.SecretText, keys.contentKey)
+	if err != nil {
+		return "", layerKeys{}, errors.Wrap(domain.ErrInvalidKey, fmt.Sprintf("failed to decrypt secret: %v", err))
+	}
+
+	// The inner layer needs the passphrase, wrong passphrases count against the attempt limit
+	if secret.PassphraseKDF != "" {
+		plaintext, keys.passphraseKey, err = s.openPassphrase(ctx, secret, plaintext, keys.contentKey, passphrase)
+		if err != nil {
+			return "", layerKeys{}, err
+		}
+	}
+
+	return plaintext, keys, nil
+}
+
+// openFile decrypts the name of a file secret and opens its content in the blob store, the content is decrypted
+// while it is read. Opening reads the header of the ciphertext, so a blob that can not be read fails here.
+func (s SecretManagerUseCase) openFile(ctx context.Context, secret domain.Secret, keys layerKeys) (*domain.File, error) {
+	name, err := s.open(secret.FileName, keys)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("failed to decrypt file name: %v", err))
+	}
+
+	blob, err := s.BlobStore.Get(ctx, secret.BlobKey)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("failed to read file: %v", err))
+	}
+
+	content, err := s.openStream(blobReader{blob}, keys)
+	if err != nil {
+		blob.Close()
+		return nil, errors.Wrap(err, fmt.Sprintf("failed to decrypt file: %v", err))
+	}
+
+	return &domain.File{Name: name, ContentType: secret.FileContentType, Content: fileContent{Reader: content, Closer: blob}}, nil
+}
+
+// blobReader reports the failures to read a blob as the blob store being unavailable
+type blobReader struct {
+	io.Reader
+}
+
+func (r blobReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if err != nil && err != io.EOF {
+		err = domain.Unavailable(err)
+	}
+	return n, err
+}
+
+// fileContent is the decrypted content of a file, closing it closes its blob
+type fileContent struct {
+	io.Reader
+	io.Closer
+}
+
+// blobDeleter deletes the blob of a file once it was sent for the last view
+type blobDeleter struct {
+	io.ReadCloser
+	delete func()
+}
+
+func (d blobDeleter) Close() error {
+	err := d.ReadCloser.Close()
+	d.delete()
+	return err
+}
+
+// deleteBlob removes the file of a file secret, failures are only logged as the secret is already gone
+func (s SecretManagerUseCase) deleteBlob(ctx context.Context, blobKey string) {
+	if blobKey == "" {
+		return
+	}
+
+	if err := s.BlobStore.Delete(ctx, blobKey); err != nil {
+		logger.Errorf("failed to delete file of secret: %v", err)
+	}
+}
+
+// releaseQuota gives back the charge of a secret removed before it expired, failures are only logged as the
+// charge frees up once the secret would have expired. The repositories clear the charge of the tombstone
+// left by the last view, which gave it back already, so it is never released twice.
+func (s SecretManagerUseCase) releaseQuota(ctx context.Context, secret domain.Secret) {
+	if s.Quotas == nil || secret.QuotaBytes == 0 {
+		return
+	}
+
+	if err := s.Quotas.Release(ctx, secret); err != nil {
+		logger.Errorf("failed to release quota of secret: %v", err)
+	}
+}
+
+// newPassphraseKey derives the key of the passphrase layer from the passphrase and the content key.
+// Keeping it as a separate layer tells a wrong link key apart from a wrong passphrase, so only
+// readers holding the link can use up the passphrase attempts.
+func (s SecretManagerUseCase) newPassphraseKey(contentKey string, passphrase string) (string, string, error) {
+	kdf, err := s.Encryptor.GeneratePassphraseKDF()
+	if err != nil {
+		return "", "", errors.Wrap(err, fmt.Sprintf("failed to generate passphrase parameters: %v", err))
+	}
+
+	passphraseKey, err := s.Encryptor.DerivePassphraseKey(contentKey, passphrase, kdf)
+	if err != nil {
+		return "", "", errors.Wrap(err, fmt.Sprintf("failed to derive passphrase key: %v", err))
+	}
+
+	return passphraseKey, kdf, nil
+}
+
+// openPassphrase decrypts the passphrase layer and returns the passphrase key with the plaintext.
+// A wrong passphrase is recorded and burns the secret at the limit.
+func (s SecretManagerUseCase) openPassphrase(ctx context.Context, secret domain.Secret, ciphertext string, contentKey string, passphrase string) (string, string, error) {
+	if passphrase == "" {
+		return "", "", domain.ErrPassphraseRequired
+	}
+
+	passphraseKey, err := s.Encryptor.DerivePassphraseKey(contentKey, passphrase, secret.PassphraseKDF)
+	if err != nil {
+		return "", "", errors.Wrap(err, fmt.Sprintf("failed to derive passphrase key: %v", err))
+	}
+
+	plaintext, err := s.Encryptor.DecryptMessage(ciphertext, passphraseKey)
+	if err == nil {
+		return plaintext, passphraseKey, nil
+	}
+
+	attempts, err := s.SecretRepo.RecordFailedAttempt(ctx, secret.Hash, s.MaxPassphraseAttempts)
+	if err != nil {
+		return "", "", errors.Wrap(err, fmt.Sprintf("failed to record failed passphrase attempt: %v", err))
+	}
+
+	if attempts >= s.MaxPassphraseAttempts {
+		logger.Warnf("secret burned after %d failed passphrase attempts", attempts)
+		s.deleteBlob(ctx, secret.BlobKey)
+		s.releaseQuota(ctx, secret)
+		return "", "", domain.ErrSecretBurned
+	}
+
+	return "", "", errors.Wrap(domain.ErrWrongPassphrase, fmt.Sprintf("%d attempts left", s.MaxPassphraseAttempts-attempts))
+}
+
+// reencryptSecret stores the secret again under a new data key in the current envelope format,
+// failures are only logged as the old ciphertext stays readable
+func (s SecretManagerUseCase) reencryptSecret(ctx context.Context, hash string, plaintext string, key string) {
+	dataKey, err := s.Encryptor.GenerateDataKey(ctx, key)
+	if err != nil {
+		logger.Errorf("failed to generate data key for re-encryption: %v", err)
+		return
+	}
+
+	encryptedText, err := s.Encryptor.EncryptMessage(plaintext, dataKey.ContentKey)
+	if err != nil {
+		logger.Errorf("failed to re-encrypt secret: %v", err)
+		return
+	}
+
+	update := domain.Secret{
+		Hash:       hash,
+		SecretText: encryptedText,
+		WrappedKey: dataKey.WrappedKey,
+		KeyID:      dataKey.KeyID,
+	}
+	if err := s.SecretRepo.UpdateEncryption(ctx, update); err != nil {
+		logger.Errorf("failed to store re-encrypted secret: %v", err)
+	}
+}
+
 var _ domain.SecretUseCase = (*SecretManagerUseCase)(nil)
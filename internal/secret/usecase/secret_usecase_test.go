@@ -3,11 +3,20 @@ package usecase
 import (
 	"context"
 	"errors"
+	"io"
+	"strings"
+	"sync"
 	"testing"
+	"testing/iotest"
 	"time"
 
 	"github.com/golang/mock/gomock"
+	"github.com/nalawade41/secret-server/internal/common/security"
 	"github.com/nalawade41/secret-server/internal/domain"
+	quotamemory "github.com/nalawade41/secret-server/internal/quota/repository/memory"
+	quotausecase "github.com/nalawade41/secret-server/internal/quota/usecase"
+	"github.com/nalawade41/secret-server/internal/secret/blobstore"
+	"github.com/nalawade41/secret-server/internal/secret/repository/memory"
 	"github.com/nalawade41/secret-server/mocks"
 	"github.com/stretchr/testify/assert"
 )
@@ -18,8 +27,9 @@ func TestCreateSecretMessage_Success(t *testing.T) {
 
 	mockRepo := mocks.NewMockSecretRepository(ctrl)
 	mockEncryptor := mocks.NewMockEncryptor(ctrl)
+	mockIDGenerator := mocks.NewMockIDGenerator(ctrl)
 
-	useCase := SecretManagerUseCase{SecretRepo: mockRepo, Encryptor: mockEncryptor}
+	useCase := SecretManagerUseCase{SecretRepo: mockRepo, Encryptor: mockEncryptor, IDGenerator: mockIDGenerator}
 
 	message := domain.Secret{
 		SecretText:     "This is a test secret",
@@ -28,33 +38,139 @@ func TestCreateSecretMessage_Success(t *testing.T) {
 		CreatedAt:      time.Now().UTC(),
 	}
 
-	// Mocked hash and encrypted text
+	// Mocked hash, key and encrypted text
 	expectedHash := "mockedhash"
+	expectedKey := "mockedkey"
 	expectedEncryptedText := "encryptedText"
 
 	// Set expectations for mock methods
-	mockEncryptor.EXPECT().GenerateSHA256Hash(message.SecretText, message.CreatedAt.String()).Return(expectedHash)
-	mockEncryptor.EXPECT().EncryptMessage(message.SecretText, expectedHash).Return(expectedEncryptedText, nil)
+	mockEncryptor.EXPECT().GenerateKey().Return(expectedKey, nil)
+	mockEncryptor.EXPECT().GenerateDataKey(gomock.Any(), expectedKey).Return(domain.DataKey{ContentKey: "contentkey", WrappedKey: "wrappedkey", KeyID: "master"}, nil)
+	mockEncryptor.EXPECT().EncryptMessage(message.SecretText, "contentkey").Return(expectedEncryptedText, nil)
+	mockEncryptor.EXPECT().GenerateKey().Return("revocationtoken", nil)
+	mockEncryptor.EXPECT().GenerateSHA256Hash("revocationtoken").Return("revocationtokenhash")
+	mockIDGenerator.EXPECT().GenerateID().Return(expectedHash, nil)
 
-	// Set expectations for mock repository
-	mockRepo.EXPECT().Save(gomock.Any(), gomock.Any()).Return(nil)
+	// Set expectations for mock repository, only the wrapped data key and never the link key reach the repository
+	mockRepo.EXPECT().Save(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, secret domain.Secret) error {
+		assert.Equal(t, expectedHash, secret.Hash)
+		assert.Empty(t, secret.Key)
+		assert.Equal(t, "wrappedkey", secret.WrappedKey)
+		assert.Equal(t, "master", secret.KeyID)
+		assert.Empty(t, secret.RevocationToken)
+		assert.Equal(t, "revocationtokenhash", secret.RevocationTokenHash)
+		assert.Equal(t, message.ExpiresAt.Unix(), secret.TTL)
+		return nil
+	})
 
 	result, err := useCase.CreateSecretMessage(context.Background(), message)
 
 	assert.NoError(t, err)
 	assert.Equal(t, expectedHash, result.Hash)
+	assert.Equal(t, expectedKey, result.Key)
+	assert.Equal(t, "revocationtoken", result.RevocationToken)
 	assert.Equal(t, expectedEncryptedText, result.SecretText)
 	assert.Equal(t, message.RemainingViews, result.RemainingViews)
 }
 
+func TestCreateSecretMessage_ClientEncrypted(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockSecretRepository(ctrl)
+	mockEncryptor := mocks.NewMockEncryptor(ctrl)
+	mockIDGenerator := mocks.NewMockIDGenerator(ctrl)
+
+	useCase := SecretManagerUseCase{SecretRepo: mockRepo, Encryptor: mockEncryptor, IDGenerator: mockIDGenerator}
+
+	message := domain.Secret{
+		SecretText:      "client envelope",
+		ClientEncrypted: true,
+		Algorithm:       "AES-256-GCM",
+		ExpiresAt:       time.Now().Add(10 * time.Minute),
+		RemainingViews:  5,
+		CreatedAt:       time.Now().UTC(),
+	}
+
+	// The encryptor is never used, the envelope is stored as it is
+	mockEncryptor.EXPECT().GenerateKey().Return("revocationtoken", nil)
+	mockEncryptor.EXPECT().GenerateSHA256Hash("revocationtoken").Return("revocationtokenhash")
+	mockIDGenerator.EXPECT().GenerateID().Return("mockedhash", nil)
+	mockRepo.EXPECT().Save(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, secret domain.Secret) error {
+		assert.Equal(t, "client envelope", secret.SecretText)
+		assert.Empty(t, secret.WrappedKey)
+		return nil
+	})
+
+	result, err := useCase.CreateSecretMessage(context.Background(), message)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "mockedhash", result.Hash)
+	assert.Empty(t, result.Key)
+	assert.Equal(t, "client envelope", result.SecretText)
+}
+
+func TestCreateSecretMessage_KeyGenerationError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockSecretRepository(ctrl)
+	mockEncryptor := mocks.NewMockEncryptor(ctrl)
+	mockIDGenerator := mocks.NewMockIDGenerator(ctrl)
+
+	useCase := SecretManagerUseCase{SecretRepo: mockRepo, Encryptor: mockEncryptor, IDGenerator: mockIDGenerator}
+
+	message := domain.Secret{
+		SecretText:     "This is a test secret",
+		ExpiresAt:      time.Now().Add(10 * time.Minute),
+		RemainingViews: 5,
+		CreatedAt:      time.Now().UTC(),
+	}
+
+	mockEncryptor.EXPECT().GenerateKey().Return("", errors.New("entropy exhausted"))
+
+	_, err := useCase.CreateSecretMessage(context.Background(), message)
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to generate secret key")
+}
+
+func TestCreateSecretMessage_DataKeyError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockSecretRepository(ctrl)
+	mockEncryptor := mocks.NewMockEncryptor(ctrl)
+	mockIDGenerator := mocks.NewMockIDGenerator(ctrl)
+
+	useCase := SecretManagerUseCase{SecretRepo: mockRepo, Encryptor: mockEncryptor, IDGenerator: mockIDGenerator}
+
+	message := domain.Secret{
+		SecretText:     "This is a test secret",
+		ExpiresAt:      time.Now().Add(10 * time.Minute),
+		RemainingViews: 5,
+		CreatedAt:      time.Now().UTC(),
+	}
+
+	// The key provider is unavailable, nothing may be stored
+	mockEncryptor.EXPECT().GenerateKey().Return("mockedkey", nil)
+	mockEncryptor.EXPECT().GenerateDataKey(gomock.Any(), "mockedkey").Return(domain.DataKey{}, errors.New("kms unavailable"))
+
+	_, err := useCase.CreateSecretMessage(context.Background(), message)
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to generate data key")
+}
+
 func TestCreateSecretMessage_EncryptionError(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
 	mockRepo := mocks.NewMockSecretRepository(ctrl)
 	mockEncryptor := mocks.NewMockEncryptor(ctrl)
+	mockIDGenerator := mocks.NewMockIDGenerator(ctrl)
 
-	useCase := SecretManagerUseCase{SecretRepo: mockRepo, Encryptor: mockEncryptor}
+	useCase := SecretManagerUseCase{SecretRepo: mockRepo, Encryptor: mockEncryptor, IDGenerator: mockIDGenerator}
 
 	message := domain.Secret{
 		SecretText:     "This is a test secret",
@@ -63,13 +179,14 @@ func TestCreateSecretMessage_EncryptionError(t *testing.T) {
 		CreatedAt:      time.Now().UTC(),
 	}
 
-	expectedHash := "mockedhash"
+	expectedKey := "mockedkey"
 
-	// Mock the hash generation
-	mockEncryptor.EXPECT().GenerateSHA256Hash(message.SecretText, message.CreatedAt.String()).Return(expectedHash)
+	// Mock the key generation
+	mockEncryptor.EXPECT().GenerateKey().Return(expectedKey, nil)
 
 	// Simulate encryption error
-	mockEncryptor.EXPECT().EncryptMessage(message.SecretText, expectedHash).Return("", errors.New("encryption failed"))
+	mockEncryptor.EXPECT().GenerateDataKey(gomock.Any(), expectedKey).Return(domain.DataKey{ContentKey: "contentkey", WrappedKey: "wrappedkey", KeyID: "master"}, nil)
+	mockEncryptor.EXPECT().EncryptMessage(message.SecretText, "contentkey").Return("", errors.New("encryption failed"))
 
 	_, err := useCase.CreateSecretMessage(context.Background(), message)
 
@@ -83,8 +200,9 @@ func TestCreateSecretMessage_RepoError(t *testing.T) {
 
 	mockRepo := mocks.NewMockSecretRepository(ctrl)
 	mockEncryptor := mocks.NewMockEncryptor(ctrl)
+	mockIDGenerator := mocks.NewMockIDGenerator(ctrl)
 
-	useCase := SecretManagerUseCase{SecretRepo: mockRepo, Encryptor: mockEncryptor}
+	useCase := SecretManagerUseCase{SecretRepo: mockRepo, Encryptor: mockEncryptor, IDGenerator: mockIDGenerator}
 
 	message := domain.Secret{
 		SecretText:     "This is a test secret",
@@ -93,14 +211,18 @@ func TestCreateSecretMessage_RepoError(t *testing.T) {
 		CreatedAt:      time.Now().UTC(),
 	}
 
-	expectedHash := "mockedhash"
+	expectedKey := "mockedkey"
 	expectedEncryptedText := "encryptedText"
 
-	// Mock the hash generation
-	mockEncryptor.EXPECT().GenerateSHA256Hash(message.SecretText, message.CreatedAt.String()).Return(expectedHash)
+	// Mock the key and id generation
+	mockEncryptor.EXPECT().GenerateKey().Return(expectedKey, nil)
+	mockEncryptor.EXPECT().GenerateKey().Return("revocationtoken", nil)
+	mockEncryptor.EXPECT().GenerateSHA256Hash("revocationtoken").Return("revocationtokenhash")
+	mockIDGenerator.EXPECT().GenerateID().Return("mockedhash", nil)
 
 	// Set expectations for mock encryptor
-	mockEncryptor.EXPECT().EncryptMessage(message.SecretText, expectedHash).Return(expectedEncryptedText, nil)
+	mockEncryptor.EXPECT().GenerateDataKey(gomock.Any(), expectedKey).Return(domain.DataKey{ContentKey: "contentkey", WrappedKey: "wrappedkey", KeyID: "master"}, nil)
+	mockEncryptor.EXPECT().EncryptMessage(message.SecretText, "contentkey").Return(expectedEncryptedText, nil)
 
 	// Set expectations for mock repository to return an error
 	mockRepo.EXPECT().Save(gomock.Any(), gomock.Any()).Return(errors.New("repository save error"))
@@ -111,6 +233,75 @@ func TestCreateSecretMessage_RepoError(t *testing.T) {
 	assert.Contains(t, err.Error(), "failed to store secret")
 }
 
+func TestCreateSecretMessage_RetriesOnIDCollision(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockSecretRepository(ctrl)
+	mockEncryptor := mocks.NewMockEncryptor(ctrl)
+	mockIDGenerator := mocks.NewMockIDGenerator(ctrl)
+
+	useCase := SecretManagerUseCase{SecretRepo: mockRepo, Encryptor: mockEncryptor, IDGenerator: mockIDGenerator}
+
+	message := domain.Secret{
+		SecretText:     "This is a test secret",
+		ExpiresAt:      time.Now().Add(10 * time.Minute),
+		RemainingViews: 5,
+		CreatedAt:      time.Now().UTC(),
+	}
+
+	mockEncryptor.EXPECT().GenerateKey().Return("mockedkey", nil)
+	mockEncryptor.EXPECT().GenerateDataKey(gomock.Any(), "mockedkey").Return(domain.DataKey{ContentKey: "contentkey", WrappedKey: "wrappedkey", KeyID: "master"}, nil)
+	mockEncryptor.EXPECT().EncryptMessage(message.SecretText, "contentkey").Return("encryptedText", nil)
+
+	mockEncryptor.EXPECT().GenerateKey().Return("revocationtoken", nil)
+	mockEncryptor.EXPECT().GenerateSHA256Hash("revocationtoken").Return("revocationtokenhash")
+
+	// The first id is already taken, the second one is free
+	gomock.InOrder(
+		mockIDGenerator.EXPECT().GenerateID().Return("takenhash", nil),
+		mockRepo.EXPECT().Save(gomock.Any(), gomock.Any()).Return(domain.ErrSecretAlreadyExists),
+		mockIDGenerator.EXPECT().GenerateID().Return("freehash", nil),
+		mockRepo.EXPECT().Save(gomock.Any(), gomock.Any()).Return(nil),
+	)
+
+	result, err := useCase.CreateSecretMessage(context.Background(), message)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "freehash", result.Hash)
+}
+
+func TestCreateSecretMessage_GivesUpAfterRepeatedCollisions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockSecretRepository(ctrl)
+	mockEncryptor := mocks.NewMockEncryptor(ctrl)
+	mockIDGenerator := mocks.NewMockIDGenerator(ctrl)
+
+	useCase := SecretManagerUseCase{SecretRepo: mockRepo, Encryptor: mockEncryptor, IDGenerator: mockIDGenerator}
+
+	message := domain.Secret{
+		SecretText:     "This is a test secret",
+		ExpiresAt:      time.Now().Add(10 * time.Minute),
+		RemainingViews: 5,
+		CreatedAt:      time.Now().UTC(),
+	}
+
+	mockEncryptor.EXPECT().GenerateKey().Return("mockedkey", nil)
+	mockEncryptor.EXPECT().GenerateDataKey(gomock.Any(), "mockedkey").Return(domain.DataKey{ContentKey: "contentkey", WrappedKey: "wrappedkey", KeyID: "master"}, nil)
+	mockEncryptor.EXPECT().EncryptMessage(message.SecretText, "contentkey").Return("encryptedText", nil)
+	mockEncryptor.EXPECT().GenerateKey().Return("revocationtoken", nil)
+	mockEncryptor.EXPECT().GenerateSHA256Hash("revocationtoken").Return("revocationtokenhash")
+	mockIDGenerator.EXPECT().GenerateID().Return("takenhash", nil).Times(maxIDAttempts)
+	mockRepo.EXPECT().Save(gomock.Any(), gomock.Any()).Return(domain.ErrSecretAlreadyExists).Times(maxIDAttempts)
+
+	_, err := useCase.CreateSecretMessage(context.Background(), message)
+
+	assert.Error(t, err)
+	assert.ErrorIs(t, err, domain.ErrSecretAlreadyExists)
+}
+
 func TestGetSecretMessage_Success(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -124,6 +315,8 @@ func TestGetSecretMessage_Success(t *testing.T) {
 	secret := domain.Secret{
 		Hash:           hash,
 		SecretText:     "Encrypted text",
+		WrappedKey:     "wrappedkey",
+		KeyID:          "master",
 		ExpiresAt:      time.Now().Add(10 * time.Minute),
 		RemainingViews: 5,
 		CreatedAt:      time.Now().UTC(),
@@ -131,15 +324,51 @@ func TestGetSecretMessage_Success(t *testing.T) {
 
 	// Set expectations for mock repository
 	mockRepo.EXPECT().GetByHash(gomock.Any(), hash).Return(secret, nil)
-	mockRepo.EXPECT().UpdateSecretViews(gomock.Any(), hash, 4).Return(nil)
+	mockEncryptor.EXPECT().UnwrapDataKey(gomock.Any(), "testkey", "wrappedkey", "master").Return("contentkey", nil)
+	mockEncryptor.EXPECT().DecryptMessage("Encrypted text", "contentkey").Return("Decrypted text", nil)
+	mockRepo.EXPECT().ConsumeView(gomock.Any(), hash).Return(domain.Secret{Hash: hash, RemainingViews: 4}, nil)
+	mockEncryptor.EXPECT().IsLegacy("Encrypted text").Return(false)
 
-	result, err := useCase.GetSecretMessage(context.Background(), hash)
+	result, err := useCase.GetSecretMessage(context.Background(), hash, "testkey", "")
 
 	assert.NoError(t, err)
 	assert.Equal(t, 4, result.RemainingViews)
+	assert.Equal(t, "Decrypted text", result.SecretText)
 }
 
-func TestGetSecretMessage_SecretExpired(t *testing.T) {
+func TestGetSecretMessage_ClientEncrypted(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockSecretRepository(ctrl)
+	mockEncryptor := mocks.NewMockEncryptor(ctrl)
+
+	useCase := SecretManagerUseCase{SecretRepo: mockRepo, Encryptor: mockEncryptor}
+
+	hash := "testhash"
+	secret := domain.Secret{
+		Hash:            hash,
+		SecretText:      "client envelope",
+		ClientEncrypted: true,
+		Algorithm:       "AES-256-GCM",
+		ExpiresAt:       time.Now().Add(10 * time.Minute),
+		RemainingViews:  5,
+		CreatedAt:       time.Now().UTC(),
+	}
+
+	// The envelope is handed out without a key and never decrypted or re-encrypted
+	mockRepo.EXPECT().GetByHash(gomock.Any(), hash).Return(secret, nil)
+	mockRepo.EXPECT().ConsumeView(gomock.Any(), hash).Return(domain.Secret{Hash: hash, RemainingViews: 4}, nil)
+
+	result, err := useCase.GetSecretMessage(context.Background(), hash, "", "")
+
+	assert.NoError(t, err)
+	assert.Equal(t, 4, result.RemainingViews)
+	assert.Equal(t, "client envelope", result.SecretText)
+	assert.True(t, result.ClientEncrypted)
+}
+
+func TestGetSecretMessage_KeyRequired(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
@@ -152,22 +381,115 @@ func TestGetSecretMessage_SecretExpired(t *testing.T) {
 	secret := domain.Secret{
 		Hash:           hash,
 		SecretText:     "Encrypted text",
-		ExpiresAt:      time.Now().Add(-10 * time.Minute), // Already expired
+		WrappedKey:     "wrappedkey",
+		KeyID:          "master",
+		ExpiresAt:      time.Now().Add(10 * time.Minute),
 		RemainingViews: 5,
 		CreatedAt:      time.Now().UTC(),
 	}
 
-	// Set expectations for mock repository
+	// Secrets encrypted by the server can not be read without the key
 	mockRepo.EXPECT().GetByHash(gomock.Any(), hash).Return(secret, nil)
-	mockRepo.EXPECT().DeleteSecret(gomock.Any(), hash).Return(nil)
 
-	_, err := useCase.GetSecretMessage(context.Background(), hash)
+	_, err := useCase.GetSecretMessage(context.Background(), hash, "", "")
+
+	assert.ErrorIs(t, err, domain.ErrKeyRequired)
+}
+
+func TestGetSecretMessage_ReencryptsLegacySecret(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockSecretRepository(ctrl)
+	mockEncryptor := mocks.NewMockEncryptor(ctrl)
+
+	useCase := SecretManagerUseCase{SecretRepo: mockRepo, Encryptor: mockEncryptor}
+
+	hash := "testhash"
+	secret := domain.Secret{
+		Hash:           hash,
+		SecretText:     "legacy ciphertext",
+		ExpiresAt:      time.Now().Add(10 * time.Minute),
+		RemainingViews: 5,
+		CreatedAt:      time.Now().UTC(),
+	}
+
+	// A legacy secret with views left is stored again in the authenticated format under a wrapped data key
+	mockRepo.EXPECT().GetByHash(gomock.Any(), hash).Return(secret, nil)
+	mockEncryptor.EXPECT().DecryptMessage("legacy ciphertext", "testkey").Return("Decrypted text", nil)
+	mockRepo.EXPECT().ConsumeView(gomock.Any(), hash).Return(domain.Secret{Hash: hash, RemainingViews: 4}, nil)
+	mockEncryptor.EXPECT().GenerateDataKey(gomock.Any(), "testkey").Return(domain.DataKey{ContentKey: "contentkey", WrappedKey: "wrappedkey", KeyID: "master"}, nil)
+	mockEncryptor.EXPECT().EncryptMessage("Decrypted text", "contentkey").Return("envelope", nil)
+	mockRepo.EXPECT().UpdateEncryption(gomock.Any(), domain.Secret{Hash: hash, SecretText: "envelope", WrappedKey: "wrappedkey", KeyID: "master"}).Return(nil)
+
+	result, err := useCase.GetSecretMessage(context.Background(), hash, "testkey", "")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Decrypted text", result.SecretText)
+}
+
+func TestGetSecretMessage_LegacyReencryptionFailureIsNotFatal(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockSecretRepository(ctrl)
+	mockEncryptor := mocks.NewMockEncryptor(ctrl)
+
+	useCase := SecretManagerUseCase{SecretRepo: mockRepo, Encryptor: mockEncryptor}
+
+	hash := "testhash"
+	secret := domain.Secret{
+		Hash:           hash,
+		SecretText:     "legacy ciphertext",
+		ExpiresAt:      time.Now().Add(10 * time.Minute),
+		RemainingViews: 5,
+		CreatedAt:      time.Now().UTC(),
+	}
+
+	mockRepo.EXPECT().GetByHash(gomock.Any(), hash).Return(secret, nil)
+	mockEncryptor.EXPECT().DecryptMessage("legacy ciphertext", "testkey").Return("Decrypted text", nil)
+	mockRepo.EXPECT().ConsumeView(gomock.Any(), hash).Return(domain.Secret{Hash: hash, RemainingViews: 4}, nil)
+	mockEncryptor.EXPECT().GenerateDataKey(gomock.Any(), "testkey").Return(domain.DataKey{ContentKey: "contentkey", WrappedKey: "wrappedkey", KeyID: "master"}, nil)
+	mockEncryptor.EXPECT().EncryptMessage("Decrypted text", "contentkey").Return("envelope", nil)
+	mockRepo.EXPECT().UpdateEncryption(gomock.Any(), domain.Secret{Hash: hash, SecretText: "envelope", WrappedKey: "wrappedkey", KeyID: "master"}).Return(errors.New("update error"))
+
+	result, err := useCase.GetSecretMessage(context.Background(), hash, "testkey", "")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Decrypted text", result.SecretText)
+}
+
+func TestGetSecretMessage_UnwrapError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockSecretRepository(ctrl)
+	mockEncryptor := mocks.NewMockEncryptor(ctrl)
+
+	useCase := SecretManagerUseCase{SecretRepo: mockRepo, Encryptor: mockEncryptor}
+
+	hash := "testhash"
+	secret := domain.Secret{
+		Hash:           hash,
+		SecretText:     "Encrypted text",
+		WrappedKey:     "wrappedkey",
+		KeyID:          "retired",
+		ExpiresAt:      time.Now().Add(10 * time.Minute),
+		RemainingViews: 5,
+		CreatedAt:      time.Now().UTC(),
+	}
+
+	// A data key that can not be unwrapped must not consume a view
+	mockRepo.EXPECT().GetByHash(gomock.Any(), hash).Return(secret, nil)
+	mockEncryptor.EXPECT().UnwrapDataKey(gomock.Any(), "testkey", "wrappedkey", "retired").Return("", security.ErrUnknownKeyID)
+
+	_, err := useCase.GetSecretMessage(context.Background(), hash, "testkey", "")
 
 	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "secret expired or no remaining views")
+	assert.Contains(t, err.Error(), "failed to unwrap data key")
 }
 
-func TestGetSecretMessage_NoRemainingViews(t *testing.T) {
+func TestGetSecretMessage_DecryptionError(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
@@ -181,7 +503,35 @@ func TestGetSecretMessage_NoRemainingViews(t *testing.T) {
 		Hash:           hash,
 		SecretText:     "Encrypted text",
 		ExpiresAt:      time.Now().Add(10 * time.Minute),
-		RemainingViews: 0, // No remaining views
+		RemainingViews: 5,
+		CreatedAt:      time.Now().UTC(),
+	}
+
+	// A failed decryption must not consume a view
+	mockRepo.EXPECT().GetByHash(gomock.Any(), hash).Return(secret, nil)
+	mockEncryptor.EXPECT().DecryptMessage("Encrypted text", "testkey").Return("", errors.New("invalid padding"))
+
+	_, err := useCase.GetSecretMessage(context.Background(), hash, "testkey", "")
+
+	assert.ErrorIs(t, err, domain.ErrInvalidKey)
+	assert.Contains(t, err.Error(), "failed to decrypt secret")
+}
+
+func TestGetSecretMessage_SecretExpired(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockSecretRepository(ctrl)
+	mockEncryptor := mocks.NewMockEncryptor(ctrl)
+
+	useCase := SecretManagerUseCase{SecretRepo: mockRepo, Encryptor: mockEncryptor}
+
+	hash := "testhash"
+	secret := domain.Secret{
+		Hash:           hash,
+		SecretText:     "Encrypted text",
+		ExpiresAt:      time.Now().Add(-10 * time.Minute), // Already expired
+		RemainingViews: 5,
 		CreatedAt:      time.Now().UTC(),
 	}
 
@@ -189,13 +539,39 @@ func TestGetSecretMessage_NoRemainingViews(t *testing.T) {
 	mockRepo.EXPECT().GetByHash(gomock.Any(), hash).Return(secret, nil)
 	mockRepo.EXPECT().DeleteSecret(gomock.Any(), hash).Return(nil)
 
-	_, err := useCase.GetSecretMessage(context.Background(), hash)
+	_, err := useCase.GetSecretMessage(context.Background(), hash, "testkey", "")
 
-	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "secret expired or no remaining views")
+	assert.ErrorIs(t, err, domain.ErrSecretExpired)
 }
 
-func TestGetSecretMessage_RepoError(t *testing.T) {
+func TestGetSecretMessage_SecretExpiredDeleteFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockSecretRepository(ctrl)
+	mockEncryptor := mocks.NewMockEncryptor(ctrl)
+
+	useCase := SecretManagerUseCase{SecretRepo: mockRepo, Encryptor: mockEncryptor}
+
+	hash := "testhash"
+	secret := domain.Secret{
+		Hash:           hash,
+		SecretText:     "Encrypted text",
+		ExpiresAt:      time.Now().Add(-10 * time.Minute),
+		RemainingViews: 5,
+		CreatedAt:      time.Now().UTC(),
+	}
+
+	// The reader is told the secret expired, the storage removes it through the TTL anyway
+	mockRepo.EXPECT().GetByHash(gomock.Any(), hash).Return(secret, nil)
+	mockRepo.EXPECT().DeleteSecret(gomock.Any(), hash).Return(domain.Unavailable(errors.New("connection refused")))
+
+	_, err := useCase.GetSecretMessage(context.Background(), hash, "testkey", "")
+
+	assert.ErrorIs(t, err, domain.ErrSecretExpired)
+}
+
+func TestGetSecretMessage_NoRemainingViews(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
@@ -205,12 +581,879 @@ func TestGetSecretMessage_RepoError(t *testing.T) {
 	useCase := SecretManagerUseCase{SecretRepo: mockRepo, Encryptor: mockEncryptor}
 
 	hash := "testhash"
+	secret := domain.Secret{
+		Hash:           hash,
+		SecretText:     "Encrypted text",
+		ExpiresAt:      time.Now().Add(10 * time.Minute),
+		RemainingViews: 0, // No remaining views
+		CreatedAt:      time.Now().UTC(),
+	}
 
-	// Set expectations for mock repository to return an error
-	mockRepo.EXPECT().GetByHash(gomock.Any(), hash).Return(domain.Secret{}, errors.New("repository error"))
+	// The fully viewed secret is a tombstone, it is kept for the status of the creator
+	mockRepo.EXPECT().GetByHash(gomock.Any(), hash).Return(secret, nil)
 
-	_, err := useCase.GetSecretMessage(context.Background(), hash)
+	_, err := useCase.GetSecretMessage(context.Background(), hash, "testkey", "")
 
-	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "failed to retrieve secret")
+	assert.ErrorIs(t, err, domain.ErrNoRemainingViews)
+}
+
+func TestGetSecretMessage_RepoError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockSecretRepository(ctrl)
+	mockEncryptor := mocks.NewMockEncryptor(ctrl)
+
+	useCase := SecretManagerUseCase{SecretRepo: mockRepo, Encryptor: mockEncryptor}
+
+	hash := "testhash"
+
+	// Set expectations for mock repository to return an error
+	mockRepo.EXPECT().GetByHash(gomock.Any(), hash).Return(domain.Secret{}, domain.Unavailable(errors.New("repository error")))
+
+	_, err := useCase.GetSecretMessage(context.Background(), hash, "testkey", "")
+
+	// A storage outage is not reported as a missing secret
+	assert.ErrorIs(t, err, domain.ErrUnavailable)
+	assert.NotErrorIs(t, err, domain.ErrNotFound)
+	assert.Contains(t, err.Error(), "failed to retrieve secret")
+}
+
+func TestGetSecretMessage_LastView(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockSecretRepository(ctrl)
+	mockEncryptor := mocks.NewMockEncryptor(ctrl)
+
+	useCase := SecretManagerUseCase{SecretRepo: mockRepo, Encryptor: mockEncryptor}
+
+	hash := "testhash"
+	secret := domain.Secret{
+		Hash:           hash,
+		SecretText:     "Encrypted text",
+		ExpiresAt:      time.Now().Add(10 * time.Minute),
+		RemainingViews: 1,
+		CreatedAt:      time.Now().UTC(),
+	}
+
+	// The repository deletes the secret when the last view is consumed, nothing is re-encrypted
+	mockRepo.EXPECT().GetByHash(gomock.Any(), hash).Return(secret, nil)
+	mockEncryptor.EXPECT().DecryptMessage("Encrypted text", "testkey").Return("Decrypted text", nil)
+	mockRepo.EXPECT().ConsumeView(gomock.Any(), hash).Return(domain.Secret{Hash: hash, RemainingViews: 0}, nil)
+
+	result, err := useCase.GetSecretMessage(context.Background(), hash, "testkey", "")
+
+	assert.NoError(t, err)
+	assert.Equal(t, 0, result.RemainingViews)
+	assert.Equal(t, "Decrypted text", result.SecretText)
+}
+
+func TestGetSecretMessage_ViewTakenConcurrently(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockSecretRepository(ctrl)
+	mockEncryptor := mocks.NewMockEncryptor(ctrl)
+
+	useCase := SecretManagerUseCase{SecretRepo: mockRepo, Encryptor: mockEncryptor}
+
+	hash := "testhash"
+	secret := domain.Secret{
+		Hash:           hash,
+		SecretText:     "Encrypted text",
+		ExpiresAt:      time.Now().Add(10 * time.Minute),
+		RemainingViews: 1,
+		CreatedAt:      time.Now().UTC(),
+	}
+
+	// Another reader took the last view between the read and the consume
+	mockRepo.EXPECT().GetByHash(gomock.Any(), hash).Return(secret, nil)
+	mockEncryptor.EXPECT().DecryptMessage("Encrypted text", "testkey").Return("Decrypted text", nil)
+	mockRepo.EXPECT().ConsumeView(gomock.Any(), hash).Return(domain.Secret{}, domain.ErrSecretNotFound)
+
+	result, err := useCase.GetSecretMessage(context.Background(), hash, "testkey", "")
+
+	assert.ErrorIs(t, err, domain.ErrSecretNotFound)
+	assert.Empty(t, result.SecretText, "The secret must not be returned without a consumed view")
+}
+
+func TestGetSecretMessage_ConcurrentReaders(t *testing.T) {
+	keyProvider, err := security.NewLocalKeyProvider("test", make([]byte, 32))
+	assert.NoError(t, err)
+
+	for _, views := range []int{1, 5} {
+		useCase := SecretManagerUseCase{
+			SecretRepo:  memory.NewSecretManagerRepository(),
+			Encryptor:   security.RealEncryptor{KeyProvider: keyProvider},
+			IDGenerator: security.IDGenerator{Length: 22, Encoding: security.IDEncodingBase62},
+		}
+
+		created, err := useCase.CreateSecretMessage(context.Background(), domain.Secret{
+			SecretText:     "This is a test secret",
+			ExpiresAt:      time.Now().Add(10 * time.Minute),
+			RemainingViews: views,
+			CreatedAt:      time.Now().UTC(),
+		})
+		assert.NoError(t, err)
+
+		// Hammer the same secret from many goroutines at once
+		const readers = 100
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		start := make(chan struct{})
+		reads := 0
+
+		for i := 0; i < readers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				<-start
+
+				result, err := useCase.GetSecretMessage(context.Background(), created.Hash, created.Key, "")
+				if err == nil {
+					assert.Equal(t, "This is a test secret", result.SecretText)
+					mu.Lock()
+					reads++
+					mu.Unlock()
+				}
+			}()
+		}
+		close(start)
+		wg.Wait()
+
+		assert.Equal(t, views, reads, "The secret must be read exactly as often as it has views")
+	}
+}
+
+// TestGetSecretMessage_Passphrase tests reading a passphrase protected secret end to end
+func TestGetSecretMessage_Passphrase(t *testing.T) {
+	keyProvider, err := security.NewLocalKeyProvider("test", make([]byte, 32))
+	assert.NoError(t, err)
+
+	repo := memory.NewSecretManagerRepository()
+	useCase := SecretManagerUseCase{
+		SecretRepo:            repo,
+		Encryptor:             security.RealEncryptor{KeyProvider: keyProvider},
+		IDGenerator:           security.IDGenerator{Length: 22, Encoding: security.IDEncodingBase62},
+		MaxPassphraseAttempts: 3,
+	}
+
+	create := func() domain.Secret {
+		created, err := useCase.CreateSecretMessage(context.Background(), domain.Secret{
+			SecretText:     "This is a test secret",
+			Passphrase:     "correct horse",
+			ExpiresAt:      time.Now().Add(10 * time.Minute),
+			RemainingViews: 2,
+			CreatedAt:      time.Now().UTC(),
+		})
+		assert.NoError(t, err)
+		assert.Empty(t, created.Passphrase)
+		assert.NotEmpty(t, created.PassphraseKDF)
+		return created
+	}
+
+	t.Run("passphrase is never stored", func(t *testing.T) {
+		created := create()
+
+		stored, err := repo.GetByHash(context.Background(), created.Hash)
+		assert.NoError(t, err)
+		assert.Empty(t, stored.Passphrase)
+		assert.NotContains(t, stored.SecretText, "correct horse")
+	})
+
+	t.Run("missing passphrase does not count as an attempt", func(t *testing.T) {
+		created := create()
+
+		_, err := useCase.GetSecretMessage(context.Background(), created.Hash, created.Key, "")
+		assert.ErrorIs(t, err, domain.ErrPassphraseRequired)
+
+		stored, err := repo.GetByHash(context.Background(), created.Hash)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, stored.FailedAttempts)
+		assert.Equal(t, 2, stored.RemainingViews)
+	})
+
+	t.Run("wrong link key does not count as an attempt", func(t *testing.T) {
+		created := create()
+		otherKey, err := useCase.Encryptor.GenerateKey()
+		assert.NoError(t, err)
+
+		_, err = useCase.GetSecretMessage(context.Background(), created.Hash, otherKey, "wrong")
+		assert.Error(t, err)
+		assert.NotErrorIs(t, err, domain.ErrWrongPassphrase)
+
+		stored, err := repo.GetByHash(context.Background(), created.Hash)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, stored.FailedAttempts)
+	})
+
+	t.Run("correct passphrase reads the secret", func(t *testing.T) {
+		created := create()
+
+		_, err := useCase.GetSecretMessage(context.Background(), created.Hash, created.Key, "wrong")
+		assert.ErrorIs(t, err, domain.ErrWrongPassphrase)
+
+		result, err := useCase.GetSecretMessage(context.Background(), created.Hash, created.Key, "correct horse")
+		assert.NoError(t, err)
+		assert.Equal(t, "This is a test secret", result.SecretText)
+		assert.Equal(t, 1, result.RemainingViews)
+	})
+
+	t.Run("secret is burned after too many wrong passphrases", func(t *testing.T) {
+		created := create()
+
+		for attempt := 1; attempt < 3; attempt++ {
+			_, err := useCase.GetSecretMessage(context.Background(), created.Hash, created.Key, "wrong")
+			assert.ErrorIs(t, err, domain.ErrWrongPassphrase)
+		}
+
+		_, err := useCase.GetSecretMessage(context.Background(), created.Hash, created.Key, "wrong")
+		assert.ErrorIs(t, err, domain.ErrSecretBurned)
+
+		_, err = useCase.GetSecretMessage(context.Background(), created.Hash, created.Key, "correct horse")
+		assert.ErrorIs(t, err, domain.ErrSecretNotFound)
+	})
+}
+
+// TestFileSecret tests creating and reading a file secret end to end
+func TestFileSecret(t *testing.T) {
+	keyProvider, err := security.NewLocalKeyProvider("test", make([]byte, 32))
+	assert.NoError(t, err)
+
+	repo := memory.NewSecretManagerRepository()
+	blobs := blobstore.NewMemoryStore()
+	useCase := SecretManagerUseCase{
+		SecretRepo:            repo,
+		Encryptor:             security.RealEncryptor{KeyProvider: keyProvider},
+		IDGenerator:           security.IDGenerator{Length: 22, Encoding: security.IDEncodingBase62},
+		BlobStore:             blobs,
+		MaxPassphraseAttempts: 1,
+	}
+
+	createContent := func(views int, passphrase string, content string) domain.Secret {
+		created, err := useCase.CreateSecretMessage(context.Background(), domain.Secret{
+			Passphrase:     passphrase,
+			ExpiresAt:      time.Now().Add(10 * time.Minute),
+			RemainingViews: views,
+			CreatedAt:      time.Now().UTC(),
+			File:           &domain.File{Name: "kubeconfig.yaml", ContentType: "application/yaml", Size: int64(len(content)), Content: io.NopCloser(strings.NewReader(content))},
+		})
+		assert.NoError(t, err)
+		assert.NotEmpty(t, created.BlobKey)
+		return created
+	}
+	create := func(views int, passphrase string) domain.Secret {
+		return createContent(views, passphrase, "apiVersion: v1")
+	}
+
+	// readFile reads and closes the content of the file
+	readFile := func(file *domain.File) string {
+		content, err := io.ReadAll(file.Content)
+		assert.NoError(t, err)
+		assert.NoError(t, file.Content.Close())
+		return string(content)
+	}
+
+	blobExists := func(blobKey string) bool {
+		_, err := blobs.Get(context.Background(), blobKey)
+		return err == nil
+	}
+
+	t.Run("only the encrypted file is stored", func(t *testing.T) {
+		created := create(1, "")
+
+		stored, err := repo.GetByHash(context.Background(), created.Hash)
+		assert.NoError(t, err)
+		assert.Equal(t, created.BlobKey, stored.BlobKey)
+		assert.Equal(t, "application/yaml", stored.FileContentType)
+		assert.NotContains(t, stored.FileName, "kubeconfig")
+
+		blob, err := blobs.Get(context.Background(), created.BlobKey)
+		assert.NoError(t, err)
+		content, err := io.ReadAll(blob)
+		assert.NoError(t, err)
+		assert.NotContains(t, string(content), "apiVersion")
+	})
+
+	t.Run("file is read with its name and content type", func(t *testing.T) {
+		created := create(2, "")
+
+		result, err := useCase.GetSecretMessage(context.Background(), created.Hash, created.Key, "")
+		assert.NoError(t, err)
+		assert.Equal(t, "kubeconfig.yaml", result.File.Name)
+		assert.Equal(t, "application/yaml", result.File.ContentType)
+		assert.Equal(t, "apiVersion: v1", readFile(result.File))
+		assert.True(t, blobExists(created.BlobKey))
+
+		// The blob goes with the last view, once the file was sent
+		result, err = useCase.GetSecretMessage(context.Background(), created.Hash, created.Key, "")
+		assert.NoError(t, err)
+		assert.True(t, blobExists(created.BlobKey))
+		assert.Equal(t, "apiVersion: v1", readFile(result.File))
+		assert.False(t, blobExists(created.BlobKey))
+
+		_, err = useCase.GetSecretMessage(context.Background(), created.Hash, created.Key, "")
+		assert.ErrorIs(t, err, domain.ErrNoRemainingViews)
+	})
+
+	t.Run("passphrase protects the file", func(t *testing.T) {
+		created := create(1, "correct horse")
+
+		result, err := useCase.GetSecretMessage(context.Background(), created.Hash, created.Key, "correct horse")
+		assert.NoError(t, err)
+		assert.Equal(t, "kubeconfig.yaml", result.File.Name)
+		assert.Equal(t, "apiVersion: v1", readFile(result.File))
+
+		// A burned secret takes its blob along
+		created = create(1, "correct horse")
+		_, err = useCase.GetSecretMessage(context.Background(), created.Hash, created.Key, "wrong")
+		assert.ErrorIs(t, err, domain.ErrSecretBurned)
+		assert.False(t, blobExists(created.BlobKey))
+	})
+
+	t.Run("large file is streamed in chunks", func(t *testing.T) {
+		content := strings.Repeat("0123456789abcdef", 3*security.StreamChunkSize/16+5)
+
+		for _, passphrase := range []string{"", "correct horse"} {
+			created := createContent(1, passphrase, content)
+
+			result, err := useCase.GetSecretMessage(context.Background(), created.Hash, created.Key, passphrase)
+			assert.NoError(t, err)
+			assert.Equal(t, content, readFile(result.File))
+		}
+	})
+
+	t.Run("missing blob does not use up a view", func(t *testing.T) {
+		created := create(1, "")
+		assert.NoError(t, blobs.Delete(context.Background(), created.BlobKey))
+
+		_, err := useCase.GetSecretMessage(context.Background(), created.Hash, created.Key, "")
+		assert.ErrorIs(t, err, domain.ErrBlobNotFound)
+
+		stored, err := repo.GetByHash(context.Background(), created.Hash)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, stored.RemainingViews)
+	})
+
+	t.Run("revoking deletes the blob", func(t *testing.T) {
+		created := create(1, "")
+
+		assert.NoError(t, useCase.RevokeSecret(context.Background(), created.Hash, created.RevocationToken))
+		assert.False(t, blobExists(created.BlobKey))
+	})
+}
+
+// unreliableBlobStore is a memory blob store whose blobs fail to read after readable bytes
+// and whose uploads fail after writable bytes, a negative limit never fails
+type unreliableBlobStore struct {
+	*blobstore.MemoryStore
+	readable int64
+	writable int64
+}
+
+func (s *unreliableBlobStore) Put(ctx context.Context, key string, content io.Reader, size int64) error {
+	if s.writable >= 0 {
+		_, _ = io.CopyN(io.Discard, content, s.writable)
+		return domain.Unavailable(errors.New("connection reset"))
+	}
+	return s.MemoryStore.Put(ctx, key, content, size)
+}
+
+func (s *unreliableBlobStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	blob, err := s.MemoryStore.Get(ctx, key)
+	if err != nil || s.readable < 0 {
+		return blob, err
+	}
+
+	content := io.MultiReader(io.LimitReader(blob, s.readable), iotest.ErrReader(errors.New("connection reset")))
+	return struct {
+		io.Reader
+		io.Closer
+	}{content, blob}, nil
+}
+
+func TestFileSecret_BlobStoreUnavailable(t *testing.T) {
+	keyProvider, err := security.NewLocalKeyProvider("test", make([]byte, 32))
+	assert.NoError(t, err)
+
+	repo := memory.NewSecretManagerRepository()
+	blobs := &unreliableBlobStore{MemoryStore: blobstore.NewMemoryStore(), readable: -1, writable: -1}
+	useCase := SecretManagerUseCase{
+		SecretRepo:  repo,
+		Encryptor:   security.RealEncryptor{KeyProvider: keyProvider},
+		IDGenerator: security.IDGenerator{Length: 22, Encoding: security.IDEncodingBase62},
+		BlobStore:   blobs,
+	}
+
+	content := strings.Repeat("a", 2*security.StreamChunkSize)
+	create := func() (domain.Secret, error) {
+		return useCase.CreateSecretMessage(context.Background(), domain.Secret{
+			ExpiresAt:      time.Now().Add(10 * time.Minute),
+			RemainingViews: 1,
+			CreatedAt:      time.Now().UTC(),
+			File:           &domain.File{Name: "backup.tar", Size: int64(len(content)), Content: io.NopCloser(strings.NewReader(content))},
+		})
+	}
+
+	// Test case: A store failing during the upload stops the encryption
+	blobs.writable = 100
+	_, err = create()
+	assert.ErrorIs(t, err, domain.ErrUnavailable)
+
+	blobs.writable = -1
+	created, err := create()
+	assert.NoError(t, err)
+
+	// Test case: A blob that can not be read at all does not use up a view
+	blobs.readable = 0
+	_, err = useCase.GetSecretMessage(context.Background(), created.Hash, created.Key, "")
+	assert.ErrorIs(t, err, domain.ErrUnavailable)
+
+	stored, err := repo.GetByHash(context.Background(), created.Hash)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, stored.RemainingViews)
+
+	// Test case: A blob failing midway fails the read of the file
+	blobs.readable = security.StreamChunkSize
+	result, err := useCase.GetSecretMessage(context.Background(), created.Hash, created.Key, "")
+	assert.NoError(t, err)
+	_, err = io.ReadAll(result.File.Content)
+	assert.ErrorIs(t, err, domain.ErrUnavailable)
+	assert.NoError(t, result.File.Content.Close())
+}
+
+// nopWriteCloser passes the content of a stream through unencrypted
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+func TestCreateSecretMessage_FileRepoError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockSecretRepository(ctrl)
+	mockEncryptor := mocks.NewMockEncryptor(ctrl)
+	mockIDGenerator := mocks.NewMockIDGenerator(ctrl)
+	mockBlobStore := mocks.NewMockBlobStore(ctrl)
+
+	useCase := SecretManagerUseCase{SecretRepo: mockRepo, Encryptor: mockEncryptor, IDGenerator: mockIDGenerator, BlobStore: mockBlobStore}
+
+	mockEncryptor.EXPECT().GenerateKey().Return("mockedkey", nil)
+	mockEncryptor.EXPECT().GenerateDataKey(gomock.Any(), "mockedkey").Return(domain.DataKey{ContentKey: "contentkey", WrappedKey: "wrappedkey", KeyID: "master"}, nil)
+	mockEncryptor.EXPECT().EncryptMessage(gomock.Any(), "contentkey").Return("ciphertext", nil).Times(2)
+	mockEncryptor.EXPECT().EncryptStream(gomock.Any(), "contentkey").DoAndReturn(func(dst io.Writer, _ string) (io.WriteCloser, error) {
+		return nopWriteCloser{dst}, nil
+	})
+	mockEncryptor.EXPECT().GenerateKey().Return("revocationtoken", nil)
+	mockEncryptor.EXPECT().GenerateSHA256Hash("revocationtoken").Return("revocationtokenhash")
+	mockEncryptor.EXPECT().StreamSize(int64(27)).Return(int64(27))
+	mockIDGenerator.EXPECT().GenerateID().Return("blobkey", nil)
+	mockIDGenerator.EXPECT().GenerateID().Return("mockedhash", nil)
+	mockBlobStore.EXPECT().Put(gomock.Any(), "blobkey", gomock.Any(), int64(27)).DoAndReturn(func(_ context.Context, _ string, content io.Reader, _ int64) error {
+		_, err := io.Copy(io.Discard, content)
+		return err
+	})
+	mockRepo.EXPECT().Save(gomock.Any(), gomock.Any()).Return(domain.Unavailable(errors.New("connection refused")))
+
+	// The blob of a secret that was never stored is removed again
+	mockBlobStore.EXPECT().Delete(gomock.Any(), "blobkey").Return(nil)
+
+	_, err := useCase.CreateSecretMessage(context.Background(), domain.Secret{
+		ExpiresAt:      time.Now().Add(10 * time.Minute),
+		RemainingViews: 1,
+		File:           &domain.File{Name: "server.pem", ContentType: "application/x-pem-file", Size: 27, Content: io.NopCloser(strings.NewReader("-----BEGIN CERTIFICATE-----"))},
+	})
+	assert.ErrorIs(t, err, domain.ErrUnavailable)
+}
+
+func TestRevokeSecret(t *testing.T) {
+	tests := []struct {
+		name      string
+		tokenHash string
+		getErr    error
+		deleteErr error
+		expected  error
+	}{
+		{name: "matching token", tokenHash: "tokenhash"},
+		{name: "wrong token", tokenHash: "othertokenhash", expected: domain.ErrInvalidRevocationToken},
+		{name: "secret without token", tokenHash: "", expected: domain.ErrInvalidRevocationToken},
+		{name: "secret not found", getErr: domain.ErrSecretNotFound, expected: domain.ErrSecretNotFound},
+		{name: "delete error", tokenHash: "tokenhash", deleteErr: errors.New("delete error")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockRepo := mocks.NewMockSecretRepository(ctrl)
+			mockEncryptor := mocks.NewMockEncryptor(ctrl)
+
+			useCase := SecretManagerUseCase{SecretRepo: mockRepo, Encryptor: mockEncryptor}
+
+			hash := "testhash"
+			mockRepo.EXPECT().GetByHash(gomock.Any(), hash).Return(domain.Secret{Hash: hash, RevocationTokenHash: tt.tokenHash}, tt.getErr)
+			if tt.getErr == nil {
+				mockEncryptor.EXPECT().GenerateSHA256Hash("token").Return("tokenhash")
+			}
+
+			// Only a matching token deletes the secret
+			if tt.tokenHash == "tokenhash" {
+				mockRepo.EXPECT().DeleteSecret(gomock.Any(), hash).Return(tt.deleteErr)
+			}
+
+			err := useCase.RevokeSecret(context.Background(), hash, "token")
+
+			switch {
+			case tt.expected != nil:
+				assert.ErrorIs(t, err, tt.expected)
+			case tt.deleteErr != nil:
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), "failed to delete secret")
+			default:
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestGetSecretStatus(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockSecretRepository(ctrl)
+	mockEncryptor := mocks.NewMockEncryptor(ctrl)
+
+	useCase := SecretManagerUseCase{SecretRepo: mockRepo, Encryptor: mockEncryptor}
+
+	hash := "testhash"
+	readAt := []time.Time{time.Now().Add(-time.Minute).UTC(), time.Now().UTC()}
+	metadata := domain.Secret{
+		Hash:                hash,
+		RevocationTokenHash: "tokenhash",
+		ExpiresAt:           time.Now().Add(10 * time.Minute),
+		RemainingViews:      0,
+		CreatedAt:           time.Now().Add(-time.Hour).UTC(),
+		ReadAt:              readAt,
+	}
+
+	// The status only reads the metadata and never takes a view
+	mockRepo.EXPECT().GetMetadata(gomock.Any(), hash).Return(metadata, nil).Times(2)
+	mockEncryptor.EXPECT().GenerateSHA256Hash("token").Return("tokenhash")
+	mockEncryptor.EXPECT().GenerateSHA256Hash("wrong").Return("wronghash")
+
+	status, err := useCase.GetSecretStatus(context.Background(), hash, "token")
+
+	assert.NoError(t, err)
+	assert.Equal(t, hash, status.Hash)
+	assert.True(t, status.Consumed)
+	assert.False(t, status.Expired)
+	assert.Equal(t, readAt, status.ReadAt)
+	assert.Equal(t, metadata.CreatedAt, status.CreatedAt)
+
+	_, err = useCase.GetSecretStatus(context.Background(), hash, "wrong")
+	assert.ErrorIs(t, err, domain.ErrInvalidRevocationToken)
+}
+
+// TestGetSecretStatus_AfterReads tests the status end to end, reads are recorded and the last one leaves a tombstone
+func TestGetSecretStatus_AfterReads(t *testing.T) {
+	keyProvider, err := security.NewLocalKeyProvider("test", make([]byte, 32))
+	assert.NoError(t, err)
+
+	useCase := SecretManagerUseCase{
+		SecretRepo:  memory.NewSecretManagerRepository(),
+		Encryptor:   security.RealEncryptor{KeyProvider: keyProvider},
+		IDGenerator: security.IDGenerator{Length: 22, Encoding: security.IDEncodingBase62},
+	}
+
+	created, err := useCase.CreateSecretMessage(context.Background(), domain.Secret{
+		SecretText:     "This is a test secret",
+		ExpiresAt:      time.Now().Add(10 * time.Minute),
+		RemainingViews: 2,
+		CreatedAt:      time.Now().UTC(),
+	})
+	assert.NoError(t, err)
+
+	status, err := useCase.GetSecretStatus(context.Background(), created.Hash, created.RevocationToken)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, status.RemainingViews)
+	assert.False(t, status.Consumed)
+	assert.Empty(t, status.ReadAt)
+
+	for i := 0; i < 2; i++ {
+		_, err = useCase.GetSecretMessage(context.Background(), created.Hash, created.Key, "")
+		assert.NoError(t, err)
+	}
+
+	status, err = useCase.GetSecretStatus(context.Background(), created.Hash, created.RevocationToken)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, status.RemainingViews)
+	assert.True(t, status.Consumed)
+	assert.Len(t, status.ReadAt, 2)
+
+	// The tombstone can not be read again
+	_, err = useCase.GetSecretMessage(context.Background(), created.Hash, created.Key, "")
+	assert.ErrorIs(t, err, domain.ErrNoRemainingViews)
+}
+
+// TestOwnSecrets tests the secrets of a principal end to end, they are listed to the owner and managed without the token
+func TestOwnSecrets(t *testing.T) {
+	keyProvider, err := security.NewLocalKeyProvider("test", make([]byte, 32))
+	assert.NoError(t, err)
+
+	useCase := SecretManagerUseCase{
+		SecretRepo:  memory.NewSecretManagerRepository(),
+		Encryptor:   security.RealEncryptor{KeyProvider: keyProvider},
+		IDGenerator: security.IDGenerator{Length: 22, Encoding: security.IDEncodingBase62},
+	}
+
+	alice := domain.ContextWithPrincipal(context.Background(), domain.Principal{Subject: "alice"})
+	bob := domain.ContextWithPrincipal(context.Background(), domain.Principal{Subject: "bob"})
+
+	create := func(ctx context.Context, createdAt time.Time) domain.Secret {
+		created, err := useCase.CreateSecretMessage(ctx, domain.Secret{
+			SecretText:     "This is a test secret",
+			ExpiresAt:      time.Now().Add(10 * time.Minute),
+			RemainingViews: 1,
+			CreatedAt:      createdAt,
+		})
+		assert.NoError(t, err)
+		return created
+	}
+
+	now := time.Now().UTC()
+	older := create(alice, now.Add(-time.Minute))
+	newer := create(alice, now)
+	anonymous := create(context.Background(), now)
+	assert.Equal(t, "alice", newer.Owner)
+	assert.Empty(t, anonymous.Owner)
+
+	statuses, next, err := useCase.ListOwnSecrets(alice, "", 1)
+	assert.NoError(t, err)
+	if assert.Len(t, statuses, 1) {
+		assert.Equal(t, newer.Hash, statuses[0].Hash)
+		assert.Equal(t, 1, statuses[0].RemainingViews)
+	}
+
+	statuses, next, err = useCase.ListOwnSecrets(alice, next, 1)
+	assert.NoError(t, err)
+	assert.Empty(t, next)
+	if assert.Len(t, statuses, 1) {
+		assert.Equal(t, older.Hash, statuses[0].Hash)
+	}
+
+	statuses, _, err = useCase.ListOwnSecrets(bob, "", 10)
+	assert.NoError(t, err)
+	assert.Empty(t, statuses, "Nobody sees the secrets of someone else")
+
+	_, _, err = useCase.ListOwnSecrets(context.Background(), "", 10)
+	assert.ErrorIs(t, err, domain.ErrAPIKeyRequired)
+
+	// Only the owner manages a secret without its token, anonymous secrets always need the token
+	_, err = useCase.GetSecretStatus(bob, older.Hash, "")
+	assert.ErrorIs(t, err, domain.ErrInvalidRevocationToken)
+	assert.ErrorIs(t, useCase.RevokeSecret(bob, older.Hash, ""), domain.ErrInvalidRevocationToken)
+	assert.ErrorIs(t, useCase.RevokeSecret(alice, anonymous.Hash, ""), domain.ErrInvalidRevocationToken)
+
+	status, err := useCase.GetSecretStatus(alice, older.Hash, "")
+	assert.NoError(t, err)
+	assert.Equal(t, older.Hash, status.Hash)
+	assert.NoError(t, useCase.RevokeSecret(alice, older.Hash, ""))
+
+	// The token of the creator still works for everyone
+	assert.NoError(t, useCase.RevokeSecret(bob, newer.Hash, newer.RevocationToken))
+
+	statuses, _, err = useCase.ListOwnSecrets(alice, "", 10)
+	assert.NoError(t, err)
+	assert.Empty(t, statuses)
+}
+
+func TestCreateSecretMessage_Quota(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockSecretRepository(ctrl)
+	mockEncryptor := mocks.NewMockEncryptor(ctrl)
+	mockIDGenerator := mocks.NewMockIDGenerator(ctrl)
+	mockQuotas := mocks.NewMockQuotaUseCase(ctrl)
+
+	useCase := SecretManagerUseCase{SecretRepo: mockRepo, Encryptor: mockEncryptor, IDGenerator: mockIDGenerator, Quotas: mockQuotas}
+	ctx := domain.ContextWithPrincipal(context.Background(), domain.Principal{Subject: "alice"})
+
+	message := domain.Secret{
+		SecretText:      "This is a test secret",
+		ExpiresAt:       time.Now().Add(10 * time.Minute),
+		RemainingViews:  1,
+		ClientEncrypted: true,
+	}
+
+	// The quota is charged to the owner with the plain size before anything is stored
+	status := domain.QuotaStatus{Limit: 10, Remaining: 9, Reset: time.Hour}
+	mockQuotas.EXPECT().Reserve(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, secret domain.Secret) (domain.QuotaStatus, error) {
+		assert.Equal(t, "alice", secret.Owner)
+		assert.Equal(t, int64(len(message.SecretText)), secret.QuotaBytes)
+		return status, nil
+	})
+	mockEncryptor.EXPECT().GenerateKey().Return("revocationtoken", nil)
+	mockEncryptor.EXPECT().GenerateSHA256Hash("revocationtoken").Return("revocationtokenhash")
+	mockIDGenerator.EXPECT().GenerateID().Return("mockedhash", nil)
+	mockRepo.EXPECT().Save(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, secret domain.Secret) error {
+		assert.Equal(t, int64(len(message.SecretText)), secret.QuotaBytes)
+		return nil
+	})
+
+	result, err := useCase.CreateSecretMessage(ctx, message)
+	assert.NoError(t, err)
+	assert.Equal(t, status, result.Quota)
+
+	// Test case: A secret that is not stored gives its charge back
+	mockQuotas.EXPECT().Reserve(gomock.Any(), gomock.Any()).Return(status, nil)
+	mockEncryptor.EXPECT().GenerateKey().Return("revocationtoken", nil)
+	mockEncryptor.EXPECT().GenerateSHA256Hash("revocationtoken").Return("revocationtokenhash")
+	mockIDGenerator.EXPECT().GenerateID().Return("mockedhash", nil)
+	mockRepo.EXPECT().Save(gomock.Any(), gomock.Any()).Return(domain.Unavailable(errors.New("connection refused")))
+	mockQuotas.EXPECT().Release(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, secret domain.Secret) error {
+		assert.Equal(t, "alice", secret.Owner)
+		return nil
+	})
+
+	_, err = useCase.CreateSecretMessage(ctx, message)
+	assert.ErrorIs(t, err, domain.ErrUnavailable)
+
+	// Test case: The quota is exceeded, nothing is created
+	mockQuotas.EXPECT().Reserve(gomock.Any(), gomock.Any()).Return(domain.QuotaStatus{}, &domain.QuotaExceededError{Status: domain.QuotaStatus{Limit: 10, Reset: time.Hour}})
+
+	_, err = useCase.CreateSecretMessage(ctx, message)
+	var exceeded *domain.QuotaExceededError
+	assert.ErrorAs(t, err, &exceeded)
+	assert.ErrorIs(t, err, domain.ErrQuotaExceeded)
+}
+
+func TestQuotas_ReleasedOnRemoval(t *testing.T) {
+	keyProvider, err := security.NewLocalKeyProvider("test", make([]byte, 32))
+	assert.NoError(t, err)
+
+	useCase := SecretManagerUseCase{
+		SecretRepo:  memory.NewSecretManagerRepository(),
+		Encryptor:   security.RealEncryptor{KeyProvider: keyProvider},
+		IDGenerator: security.IDGenerator{Length: 22, Encoding: security.IDEncodingBase62},
+		Quotas: quotausecase.QuotaUseCase{
+			QuotaRepo: quotamemory.NewQuotaRepository(),
+			PerCaller: domain.QuotaPolicy{MaxLiveSecrets: 1},
+		},
+	}
+	alice := domain.ContextWithPrincipal(context.Background(), domain.Principal{Subject: "alice"})
+
+	create := func() (domain.Secret, error) {
+		return useCase.CreateSecretMessage(alice, domain.Secret{
+			SecretText:     "This is a test secret",
+			ExpiresAt:      time.Now().Add(10 * time.Minute),
+			RemainingViews: 1,
+			CreatedAt:      time.Now().UTC(),
+		})
+	}
+
+	created, err := create()
+	assert.NoError(t, err)
+	assert.Equal(t, domain.QuotaStatus{Limit: 1, Remaining: 0}, domain.QuotaStatus{Limit: created.Quota.Limit, Remaining: created.Quota.Remaining})
+
+	_, err = create()
+	assert.ErrorIs(t, err, domain.ErrQuotaExceeded)
+
+	// Test case: Revoking a secret frees its place
+	assert.NoError(t, useCase.RevokeSecret(alice, created.Hash, ""))
+	created, err = create()
+	assert.NoError(t, err)
+
+	// Test case: Taking the last view frees its place
+	_, err = useCase.GetSecretMessage(alice, created.Hash, created.Key, "")
+	assert.NoError(t, err)
+	_, err = create()
+	assert.NoError(t, err)
+}
+
+func TestQuotas_TombstoneReleasedOnce(t *testing.T) {
+	keyProvider, err := security.NewLocalKeyProvider("test", make([]byte, 32))
+	assert.NoError(t, err)
+
+	// liveSecrets returns the live secrets charged to alice
+	liveSecrets := func(repo domain.QuotaRepository) int {
+		usage, err := repo.GetUsage(context.Background(), "caller::alice")
+		assert.NoError(t, err)
+
+		var secrets int
+		for _, bucket := range usage.Buckets {
+			secrets += bucket.Secrets
+		}
+		return secrets
+	}
+	alice := domain.ContextWithPrincipal(context.Background(), domain.Principal{Subject: "alice"})
+
+	// Test case: Revoking a consumed secret does not free the place of another secret in its bucket
+	quotaRepo := quotamemory.NewQuotaRepository()
+	useCase := SecretManagerUseCase{
+		SecretRepo:  memory.NewSecretManagerRepository(),
+		Encryptor:   security.RealEncryptor{KeyProvider: keyProvider},
+		IDGenerator: security.IDGenerator{Length: 22, Encoding: security.IDEncodingBase62},
+		Quotas: quotausecase.QuotaUseCase{
+			QuotaRepo: quotaRepo,
+			PerCaller: domain.QuotaPolicy{MaxLiveSecrets: 2},
+		},
+	}
+
+	expiresAt := time.Now().Add(10 * time.Minute)
+	create := func() domain.Secret {
+		created, err := useCase.CreateSecretMessage(alice, domain.Secret{
+			SecretText:     "This is a test secret",
+			ExpiresAt:      expiresAt,
+			RemainingViews: 1,
+			CreatedAt:      time.Now().UTC(),
+		})
+		assert.NoError(t, err)
+		return created
+	}
+
+	consumed := create()
+	create()
+	assert.Equal(t, 2, liveSecrets(quotaRepo))
+
+	_, err = useCase.GetSecretMessage(alice, consumed.Hash, consumed.Key, "")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, liveSecrets(quotaRepo))
+
+	assert.NoError(t, useCase.RevokeSecret(alice, consumed.Hash, ""))
+	assert.Equal(t, 1, liveSecrets(quotaRepo))
+
+	// Test case: Reading an expired tombstone does not free the place of another secret in its bucket
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockSecretRepository(ctrl)
+	now := time.Now().UTC()
+	quotaRepo = quotamemory.NewQuotaRepository()
+	quotas := quotausecase.QuotaUseCase{
+		QuotaRepo: quotaRepo,
+		PerCaller: domain.QuotaPolicy{MaxLiveSecrets: 2},
+		// The bucket of the tombstone has not ended yet for the quotas
+		Now: func() time.Time { return now.Add(-2 * time.Hour) },
+	}
+	useCase = SecretManagerUseCase{SecretRepo: mockRepo, Quotas: quotas}
+
+	// The tombstone left by the last view holds no charge, the charge of the secret was given back with that view
+	tombstone := domain.Secret{Hash: "hash", Owner: "alice", ExpiresAt: now.Add(-time.Minute)}
+	charged := tombstone
+	charged.QuotaBytes = 21
+	_, err = quotas.Reserve(alice, charged)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, liveSecrets(quotaRepo))
+
+	mockRepo.EXPECT().GetByHash(gomock.Any(), "hash").Return(tombstone, nil)
+	mockRepo.EXPECT().DeleteSecret(gomock.Any(), "hash").Return(nil)
+
+	_, err = useCase.GetSecretMessage(alice, "hash", "key", "")
+	assert.ErrorIs(t, err, domain.ErrSecretExpired)
+	assert.Equal(t, 1, liveSecrets(quotaRepo))
 }
@@ -4,11 +4,29 @@ package wire
 
 import (
 	"github.com/google/wire"
+	"github.com/nalawade41/secret-server/config"
 	"github.com/nalawade41/secret-server/db"
+	"github.com/nalawade41/secret-server/internal/apikey"
+	apikeyhandler "github.com/nalawade41/secret-server/internal/apikey/handler"
+	"github.com/nalawade41/secret-server/internal/common/security"
+	"github.com/nalawade41/secret-server/internal/domain"
+	"github.com/nalawade41/secret-server/internal/quota"
 	"github.com/nalawade41/secret-server/internal/secret"
 	"github.com/nalawade41/secret-server/internal/secret/handler"
 )
 
-func InitializeRouteProvider(dbConnection db.DynamoDBAPI, tableName string) *handler.SecretManagerHandler {
+func InitializeRouteProvider(storage *db.Storage, tableName string, secretConfig *config.SecretConfig, quotaConfig *config.QuotaConfig, keyProvider security.KeyProvider) *handler.SecretManagerHandler {
+	panic(wire.Build(secret.ManagerProviderSet, quota.ProviderSet))
+}
+
+func InitializeKeyRotation(storage *db.Storage, tableName string, keyProvider security.KeyProvider) domain.KeyRotationUseCase {
 	panic(wire.Build(secret.ManagerProviderSet))
 }
+
+func InitializeAPIKeyProvider(storage *db.Storage, tableName string, secretConfig *config.SecretConfig, keyProvider security.KeyProvider) *apikeyhandler.APIKeyHandler {
+	panic(wire.Build(apikey.ProviderSet))
+}
+
+func InitializeAPIKeys(storage *db.Storage, tableName string, secretConfig *config.SecretConfig, keyProvider security.KeyProvider) domain.APIKeyUseCase {
+	panic(wire.Build(apikey.ProviderSet))
+}
@@ -7,17 +7,51 @@
 package wire
 
 import (
+	"github.com/nalawade41/secret-server/config"
 	"github.com/nalawade41/secret-server/db"
+	"github.com/nalawade41/secret-server/internal/apikey"
+	apikeyhandler "github.com/nalawade41/secret-server/internal/apikey/handler"
+	"github.com/nalawade41/secret-server/internal/common/security"
+	"github.com/nalawade41/secret-server/internal/domain"
+	"github.com/nalawade41/secret-server/internal/quota"
 	"github.com/nalawade41/secret-server/internal/secret"
 	"github.com/nalawade41/secret-server/internal/secret/handler"
 )
 
 // Injectors from wire.go:
 
-func InitializeRouteProvider(dbConnection db.DynamoDBAPI, tableName string) *handler.SecretManagerHandler {
-	secretManagerRepository := secret.NewSecretManagerRepository(dbConnection, tableName)
-	realEncryptor := secret.NewEncryptor()
-	secretManagerUseCase := secret.NewSecretManagerUseCase(secretManagerRepository, realEncryptor)
-	secretManagerHandler := secret.NewSecretManagerHandler(secretManagerUseCase)
+func InitializeRouteProvider(storage *db.Storage, tableName string, secretConfig *config.SecretConfig, quotaConfig *config.QuotaConfig, keyProvider security.KeyProvider) *handler.SecretManagerHandler {
+	secretRepository := secret.NewSecretManagerRepository(storage, tableName)
+	realEncryptor := secret.NewEncryptor(keyProvider)
+	idGenerator := secret.NewIDGenerator(secretConfig)
+	blobStore := secret.NewBlobStore(storage)
+	quotaRepository := quota.NewQuotaRepository(storage, quotaConfig)
+	quotaUseCase := quota.NewQuotaUseCase(quotaRepository, quotaConfig, secretConfig)
+	secretManagerUseCase := secret.NewSecretManagerUseCase(secretRepository, realEncryptor, idGenerator, blobStore, quotaUseCase, secretConfig)
+	secretManagerHandler := secret.NewSecretManagerHandler(secretManagerUseCase, secretConfig)
 	return secretManagerHandler
 }
+
+func InitializeKeyRotation(storage *db.Storage, tableName string, keyProvider security.KeyProvider) domain.KeyRotationUseCase {
+	secretRepository := secret.NewSecretManagerRepository(storage, tableName)
+	realEncryptor := secret.NewEncryptor(keyProvider)
+	keyRotationUseCase := secret.NewKeyRotationUseCase(secretRepository, realEncryptor)
+	return keyRotationUseCase
+}
+
+func InitializeAPIKeyProvider(storage *db.Storage, tableName string, secretConfig *config.SecretConfig, keyProvider security.KeyProvider) *apikeyhandler.APIKeyHandler {
+	apiKeyRepository := apikey.NewAPIKeyRepository(storage, tableName)
+	realEncryptor := secret.NewEncryptor(keyProvider)
+	idGenerator := secret.NewIDGenerator(secretConfig)
+	apiKeyUseCase := apikey.NewAPIKeyUseCase(apiKeyRepository, realEncryptor, idGenerator)
+	apiKeyHandler := apikey.NewAPIKeyHandler(apiKeyUseCase, secretConfig)
+	return apiKeyHandler
+}
+
+func InitializeAPIKeys(storage *db.Storage, tableName string, secretConfig *config.SecretConfig, keyProvider security.KeyProvider) domain.APIKeyUseCase {
+	apiKeyRepository := apikey.NewAPIKeyRepository(storage, tableName)
+	realEncryptor := secret.NewEncryptor(keyProvider)
+	idGenerator := secret.NewIDGenerator(secretConfig)
+	apiKeyUseCase := apikey.NewAPIKeyUseCase(apiKeyRepository, realEncryptor, idGenerator)
+	return apiKeyUseCase
+}
@@ -0,0 +1,125 @@
+package keys
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	lConfig "github.com/nalawade41/secret-server/config"
+	"github.com/nalawade41/secret-server/internal/common/logger"
+	"github.com/nalawade41/secret-server/internal/common/security"
+	"github.com/pkg/errors"
+)
+
+var (
+	keyProvider     security.KeyProvider
+	keyProviderOnce = new(sync.Once)
+)
+
+// InitKeyProvider initializes the provider of the master key configured for the environment
+func InitKeyProvider(cfg *lConfig.Config) (security.KeyProvider, error) {
+	var initErr error
+
+	keyProviderOnce.Do(func() {
+		switch cfg.Key.Provider {
+		case lConfig.KeyProviderKMS:
+			keyProvider, initErr = newKMSKeyProvider(cfg)
+		default:
+			keyProvider, initErr = newLocalKeyProvider(cfg.Key)
+		}
+
+		if initErr == nil {
+			logger.Infof("Using %s key provider", cfg.Key.Provider)
+		}
+	})
+
+	return keyProvider, initErr
+}
+
+// newLocalKeyProvider creates the keyring of the active master key and the decrypt only master keys
+// from the environment or the key files
+func newLocalKeyProvider(cfg *lConfig.KeyConfig) (*security.Keyring, error) {
+	value, err := readKeyValue(cfg.MasterKey, cfg.MasterKeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	if value == "" {
+		return nil, errors.New("MASTER_KEY or MASTER_KEY_FILE must be set for the local key provider")
+	}
+
+	masterKey, err := security.ParseMasterKey(value)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("invalid master key: %v", err))
+	}
+
+	value, err = readKeyValue(cfg.DecryptMasterKeys, cfg.DecryptMasterKeysFile)
+	if err != nil {
+		return nil, err
+	}
+
+	masterKeys, err := security.ParseMasterKeys(value)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("invalid decrypt only master keys: %v", err))
+	}
+
+	if _, ok := masterKeys[cfg.MasterKeyID]; ok {
+		return nil, errors.Errorf("master key %q is configured as active and as decrypt only", cfg.MasterKeyID)
+	}
+	masterKeys[cfg.MasterKeyID] = masterKey
+
+	return security.NewKeyring(cfg.MasterKeyID, masterKeys)
+}
+
+// readKeyValue returns the content of the key file if one is configured, the value otherwise
+func readKeyValue(value string, file string) (string, error) {
+	if file == "" {
+		return value, nil
+	}
+
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return "", errors.Wrap(err, fmt.Sprintf("failed to read master key file %s: %v", file, err))
+	}
+
+	return string(content), nil
+}
+
+// newKMSKeyProvider creates the key provider for the configured KMS key
+func newKMSKeyProvider(cfg *lConfig.Config) (*security.KMSKeyProvider, error) {
+	if cfg.Key.KMSKeyID == "" {
+		return nil, errors.New("KMS_KEY_ID must be set for the kms key provider")
+	}
+
+	options := []func(*config.LoadOptions) error{
+		config.WithRegion(cfg.AWS.Region),
+	}
+
+	// A local KMS stand-in accepts any credentials
+	if cfg.Environment == lConfig.EnvLocal {
+		options = append(options, config.WithCredentialsProvider(credentials.StaticCredentialsProvider{
+			Value: aws.Credentials{
+				AccessKeyID: "a1b2c3", SecretAccessKey: "a1b2c3", SessionToken: "a1b2c3",
+				Source: "Mock credentials used above for local instance",
+			},
+		}))
+	}
+
+	awsConfig, err := config.LoadDefaultConfig(context.TODO(), options...)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("failed to load AWS config: %v", err))
+	}
+
+	client := kms.NewFromConfig(awsConfig, func(o *kms.Options) {
+		if cfg.Key.KMSEndpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.Key.KMSEndpoint)
+		}
+	})
+
+	return &security.KMSKeyProvider{Client: client, KeyID: cfg.Key.KMSKeyID}, nil
+}
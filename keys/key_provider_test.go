@@ -0,0 +1,111 @@
+package keys
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	lConfig "github.com/nalawade41/secret-server/config"
+	"github.com/stretchr/testify/assert"
+)
+
+const testMasterKey = "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"
+
+func TestNewLocalKeyProvider_FromEnvironment(t *testing.T) {
+	provider, err := newLocalKeyProvider(&lConfig.KeyConfig{MasterKey: testMasterKey, MasterKeyID: "master"})
+	assert.NoError(t, err)
+
+	// The provider must be able to unwrap what it wrapped
+	wrapped, keyID, err := provider.WrapKey(context.Background(), []byte(strings.Repeat("k", 32)))
+	assert.NoError(t, err)
+	assert.Equal(t, "master", keyID)
+
+	dataKey, err := provider.UnwrapKey(context.Background(), wrapped, keyID)
+	assert.NoError(t, err)
+	assert.Equal(t, strings.Repeat("k", 32), string(dataKey))
+}
+
+func TestNewLocalKeyProvider_FromFile(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "master.key")
+	err := os.WriteFile(keyFile, []byte(testMasterKey+"\n"), 0600)
+	assert.NoError(t, err)
+
+	// The file takes precedence over the environment
+	provider, err := newLocalKeyProvider(&lConfig.KeyConfig{MasterKey: "ignored", MasterKeyFile: keyFile, MasterKeyID: "master"})
+	assert.NoError(t, err)
+	assert.NotNil(t, provider)
+}
+
+func TestNewLocalKeyProvider_DecryptOnlyKeys(t *testing.T) {
+	retiredKey := strings.Repeat("ab", 32)
+
+	// Data key wrapped while the retired key was active
+	retired, err := newLocalKeyProvider(&lConfig.KeyConfig{MasterKey: retiredKey, MasterKeyID: "2023"})
+	assert.NoError(t, err)
+	wrapped, keyID, err := retired.WrapKey(context.Background(), []byte(strings.Repeat("k", 32)))
+	assert.NoError(t, err)
+
+	keysFile := filepath.Join(t.TempDir(), "retired.keys")
+	err = os.WriteFile(keysFile, []byte("2023:"+retiredKey+"\n"), 0600)
+	assert.NoError(t, err)
+
+	provider, err := newLocalKeyProvider(&lConfig.KeyConfig{MasterKey: testMasterKey, MasterKeyID: "2024", DecryptMasterKeysFile: keysFile})
+	assert.NoError(t, err)
+
+	activeKeyID, err := provider.ActiveKeyID(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "2024", activeKeyID)
+
+	dataKey, err := provider.UnwrapKey(context.Background(), wrapped, keyID)
+	assert.NoError(t, err, "The retired key should still unwrap its data keys")
+	assert.Equal(t, strings.Repeat("k", 32), string(dataKey))
+}
+
+func TestNewLocalKeyProvider_Errors(t *testing.T) {
+	// Test case: No master key configured
+	_, err := newLocalKeyProvider(&lConfig.KeyConfig{MasterKeyID: "master"})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "MASTER_KEY or MASTER_KEY_FILE must be set")
+
+	// Test case: Master key file does not exist
+	_, err = newLocalKeyProvider(&lConfig.KeyConfig{MasterKeyFile: filepath.Join(t.TempDir(), "missing"), MasterKeyID: "master"})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to read master key file")
+
+	// Test case: Active key is also configured as decrypt only
+	_, err = newLocalKeyProvider(&lConfig.KeyConfig{MasterKey: testMasterKey, MasterKeyID: "master", DecryptMasterKeys: "master:" + testMasterKey})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "configured as active and as decrypt only")
+
+	// Test case: Invalid decrypt only keys
+	_, err = newLocalKeyProvider(&lConfig.KeyConfig{MasterKey: testMasterKey, MasterKeyID: "master", DecryptMasterKeys: "2023"})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid decrypt only master keys")
+
+	// Test case: Master key is too short
+	_, err = newLocalKeyProvider(&lConfig.KeyConfig{MasterKey: "0011", MasterKeyID: "master"})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid master key")
+}
+
+func TestNewKMSKeyProvider(t *testing.T) {
+	cfg := &lConfig.Config{
+		Environment: lConfig.EnvLocal,
+		AWS:         &lConfig.AWSConfig{Region: "us-west-2"},
+		Key:         &lConfig.KeyConfig{Provider: lConfig.KeyProviderKMS, KMSEndpoint: "http://localhost:4566"},
+	}
+
+	// Test case: No KMS key configured
+	_, err := newKMSKeyProvider(cfg)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "KMS_KEY_ID must be set")
+
+	// Test case: KMS key configured
+	cfg.Key.KMSKeyID = "alias/secret-server"
+	provider, err := newKMSKeyProvider(cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, "alias/secret-server", provider.KeyID)
+	assert.NotNil(t, provider.Client)
+}
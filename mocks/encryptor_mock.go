@@ -5,9 +5,12 @@
 package mocks
 
 import (
+	context "context"
+	io "io"
 	reflect "reflect"
 
 	gomock "github.com/golang/mock/gomock"
+	domain "github.com/nalawade41/secret-server/internal/domain"
 )
 
 // MockEncryptor is a mock of Encryptor interface.
@@ -33,6 +36,66 @@ func (m *MockEncryptor) EXPECT() *MockEncryptorMockRecorder {
 	return m.recorder
 }
 
+// ActiveKeyID mocks base method.
+func (m *MockEncryptor) ActiveKeyID(arg0 context.Context) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ActiveKeyID", arg0)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ActiveKeyID indicates an expected call of ActiveKeyID.
+func (mr *MockEncryptorMockRecorder) ActiveKeyID(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ActiveKeyID", reflect.TypeOf((*MockEncryptor)(nil).ActiveKeyID), arg0)
+}
+
+// DecryptMessage mocks base method.
+func (m *MockEncryptor) DecryptMessage(arg0, arg1 string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DecryptMessage", arg0, arg1)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DecryptMessage indicates an expected call of DecryptMessage.
+func (mr *MockEncryptorMockRecorder) DecryptMessage(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DecryptMessage", reflect.TypeOf((*MockEncryptor)(nil).DecryptMessage), arg0, arg1)
+}
+
+// DecryptStream mocks base method.
+func (m *MockEncryptor) DecryptStream(arg0 io.Reader, arg1 string) (io.Reader, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DecryptStream", arg0, arg1)
+	ret0, _ := ret[0].(io.Reader)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DecryptStream indicates an expected call of DecryptStream.
+func (mr *MockEncryptorMockRecorder) DecryptStream(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DecryptStream", reflect.TypeOf((*MockEncryptor)(nil).DecryptStream), arg0, arg1)
+}
+
+// DerivePassphraseKey mocks base method.
+func (m *MockEncryptor) DerivePassphraseKey(arg0, arg1, arg2 string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DerivePassphraseKey", arg0, arg1, arg2)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DerivePassphraseKey indicates an expected call of DerivePassphraseKey.
+func (mr *MockEncryptorMockRecorder) DerivePassphraseKey(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DerivePassphraseKey", reflect.TypeOf((*MockEncryptor)(nil).DerivePassphraseKey), arg0, arg1, arg2)
+}
+
 // EncryptMessage mocks base method.
 func (m *MockEncryptor) EncryptMessage(arg0, arg1 string) (string, error) {
 	m.ctrl.T.Helper()
@@ -48,6 +111,66 @@ func (mr *MockEncryptorMockRecorder) EncryptMessage(arg0, arg1 interface{}) *gom
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EncryptMessage", reflect.TypeOf((*MockEncryptor)(nil).EncryptMessage), arg0, arg1)
 }
 
+// EncryptStream mocks base method.
+func (m *MockEncryptor) EncryptStream(arg0 io.Writer, arg1 string) (io.WriteCloser, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EncryptStream", arg0, arg1)
+	ret0, _ := ret[0].(io.WriteCloser)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EncryptStream indicates an expected call of EncryptStream.
+func (mr *MockEncryptorMockRecorder) EncryptStream(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EncryptStream", reflect.TypeOf((*MockEncryptor)(nil).EncryptStream), arg0, arg1)
+}
+
+// GenerateDataKey mocks base method.
+func (m *MockEncryptor) GenerateDataKey(arg0 context.Context, arg1 string) (domain.DataKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GenerateDataKey", arg0, arg1)
+	ret0, _ := ret[0].(domain.DataKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GenerateDataKey indicates an expected call of GenerateDataKey.
+func (mr *MockEncryptorMockRecorder) GenerateDataKey(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GenerateDataKey", reflect.TypeOf((*MockEncryptor)(nil).GenerateDataKey), arg0, arg1)
+}
+
+// GenerateKey mocks base method.
+func (m *MockEncryptor) GenerateKey() (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GenerateKey")
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GenerateKey indicates an expected call of GenerateKey.
+func (mr *MockEncryptorMockRecorder) GenerateKey() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GenerateKey", reflect.TypeOf((*MockEncryptor)(nil).GenerateKey))
+}
+
+// GeneratePassphraseKDF mocks base method.
+func (m *MockEncryptor) GeneratePassphraseKDF() (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GeneratePassphraseKDF")
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GeneratePassphraseKDF indicates an expected call of GeneratePassphraseKDF.
+func (mr *MockEncryptorMockRecorder) GeneratePassphraseKDF() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GeneratePassphraseKDF", reflect.TypeOf((*MockEncryptor)(nil).GeneratePassphraseKDF))
+}
+
 // GenerateSHA256Hash mocks base method.
 func (m *MockEncryptor) GenerateSHA256Hash(arg0 ...string) string {
 	m.ctrl.T.Helper()
@@ -65,3 +188,62 @@ func (mr *MockEncryptorMockRecorder) GenerateSHA256Hash(arg0 ...interface{}) *go
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GenerateSHA256Hash", reflect.TypeOf((*MockEncryptor)(nil).GenerateSHA256Hash), arg0...)
 }
+
+// IsLegacy mocks base method.
+func (m *MockEncryptor) IsLegacy(arg0 string) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IsLegacy", arg0)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// IsLegacy indicates an expected call of IsLegacy.
+func (mr *MockEncryptorMockRecorder) IsLegacy(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsLegacy", reflect.TypeOf((*MockEncryptor)(nil).IsLegacy), arg0)
+}
+
+// RewrapDataKey mocks base method.
+func (m *MockEncryptor) RewrapDataKey(arg0 context.Context, arg1, arg2 string) (string, string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RewrapDataKey", arg0, arg1, arg2)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// RewrapDataKey indicates an expected call of RewrapDataKey.
+func (mr *MockEncryptorMockRecorder) RewrapDataKey(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RewrapDataKey", reflect.TypeOf((*MockEncryptor)(nil).RewrapDataKey), arg0, arg1, arg2)
+}
+
+// StreamSize mocks base method.
+func (m *MockEncryptor) StreamSize(arg0 int64) int64 {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StreamSize", arg0)
+	ret0, _ := ret[0].(int64)
+	return ret0
+}
+
+// StreamSize indicates an expected call of StreamSize.
+func (mr *MockEncryptorMockRecorder) StreamSize(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StreamSize", reflect.TypeOf((*MockEncryptor)(nil).StreamSize), arg0)
+}
+
+// UnwrapDataKey mocks base method.
+func (m *MockEncryptor) UnwrapDataKey(arg0 context.Context, arg1, arg2, arg3 string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UnwrapDataKey", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UnwrapDataKey indicates an expected call of UnwrapDataKey.
+func (mr *MockEncryptorMockRecorder) UnwrapDataKey(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UnwrapDataKey", reflect.TypeOf((*MockEncryptor)(nil).UnwrapDataKey), arg0, arg1, arg2, arg3)
+}
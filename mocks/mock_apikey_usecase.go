@@ -0,0 +1,95 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/nalawade41/secret-server/internal/domain (interfaces: APIKeyUseCase)
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+	domain "github.com/nalawade41/secret-server/internal/domain"
+)
+
+// MockAPIKeyUseCase is a mock of APIKeyUseCase interface.
+type MockAPIKeyUseCase struct {
+	ctrl     *gomock.Controller
+	recorder *MockAPIKeyUseCaseMockRecorder
+}
+
+// MockAPIKeyUseCaseMockRecorder is the mock recorder for MockAPIKeyUseCase.
+type MockAPIKeyUseCaseMockRecorder struct {
+	mock *MockAPIKeyUseCase
+}
+
+// NewMockAPIKeyUseCase creates a new mock instance.
+func NewMockAPIKeyUseCase(ctrl *gomock.Controller) *MockAPIKeyUseCase {
+	mock := &MockAPIKeyUseCase{ctrl: ctrl}
+	mock.recorder = &MockAPIKeyUseCaseMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAPIKeyUseCase) EXPECT() *MockAPIKeyUseCaseMockRecorder {
+	return m.recorder
+}
+
+// Authenticate mocks base method.
+func (m *MockAPIKeyUseCase) Authenticate(arg0 context.Context, arg1 string) (domain.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Authenticate", arg0, arg1)
+	ret0, _ := ret[0].(domain.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Authenticate indicates an expected call of Authenticate.
+func (mr *MockAPIKeyUseCaseMockRecorder) Authenticate(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Authenticate", reflect.TypeOf((*MockAPIKeyUseCase)(nil).Authenticate), arg0, arg1)
+}
+
+// IssueKey mocks base method.
+func (m *MockAPIKeyUseCase) IssueKey(arg0 context.Context, arg1, arg2 string, arg3 []string) (domain.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IssueKey", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(domain.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IssueKey indicates an expected call of IssueKey.
+func (mr *MockAPIKeyUseCaseMockRecorder) IssueKey(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IssueKey", reflect.TypeOf((*MockAPIKeyUseCase)(nil).IssueKey), arg0, arg1, arg2, arg3)
+}
+
+// ListKeys mocks base method.
+func (m *MockAPIKeyUseCase) ListKeys(arg0 context.Context) ([]domain.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListKeys", arg0)
+	ret0, _ := ret[0].([]domain.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListKeys indicates an expected call of ListKeys.
+func (mr *MockAPIKeyUseCaseMockRecorder) ListKeys(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListKeys", reflect.TypeOf((*MockAPIKeyUseCase)(nil).ListKeys), arg0)
+}
+
+// RevokeKey mocks base method.
+func (m *MockAPIKeyUseCase) RevokeKey(arg0 context.Context, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeKey", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeKey indicates an expected call of RevokeKey.
+func (mr *MockAPIKeyUseCaseMockRecorder) RevokeKey(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeKey", reflect.TypeOf((*MockAPIKeyUseCase)(nil).RevokeKey), arg0, arg1)
+}
@@ -0,0 +1,65 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/nalawade41/secret-server/internal/domain (interfaces: QuotaUseCase)
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+	domain "github.com/nalawade41/secret-server/internal/domain"
+)
+
+// MockQuotaUseCase is a mock of QuotaUseCase interface.
+type MockQuotaUseCase struct {
+	ctrl     *gomock.Controller
+	recorder *MockQuotaUseCaseMockRecorder
+}
+
+// MockQuotaUseCaseMockRecorder is the mock recorder for MockQuotaUseCase.
+type MockQuotaUseCaseMockRecorder struct {
+	mock *MockQuotaUseCase
+}
+
+// NewMockQuotaUseCase creates a new mock instance.
+func NewMockQuotaUseCase(ctrl *gomock.Controller) *MockQuotaUseCase {
+	mock := &MockQuotaUseCase{ctrl: ctrl}
+	mock.recorder = &MockQuotaUseCaseMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockQuotaUseCase) EXPECT() *MockQuotaUseCaseMockRecorder {
+	return m.recorder
+}
+
+// Release mocks base method.
+func (m *MockQuotaUseCase) Release(arg0 context.Context, arg1 domain.Secret) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Release", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Release indicates an expected call of Release.
+func (mr *MockQuotaUseCaseMockRecorder) Release(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Release", reflect.TypeOf((*MockQuotaUseCase)(nil).Release), arg0, arg1)
+}
+
+// Reserve mocks base method.
+func (m *MockQuotaUseCase) Reserve(arg0 context.Context, arg1 domain.Secret) (domain.QuotaStatus, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Reserve", arg0, arg1)
+	ret0, _ := ret[0].(domain.QuotaStatus)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Reserve indicates an expected call of Reserve.
+func (mr *MockQuotaUseCaseMockRecorder) Reserve(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Reserve", reflect.TypeOf((*MockQuotaUseCase)(nil).Reserve), arg0, arg1)
+}
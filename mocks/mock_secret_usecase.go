@@ -51,16 +51,61 @@ func (mr *MockSecretUseCaseMockRecorder) CreateSecretMessage(arg0, arg1 interfac
 }
 
 // GetSecretMessage mocks base method.
-func (m *MockSecretUseCase) GetSecretMessage(arg0 context.Context, arg1 string) (domain.Secret, error) {
+func (m *MockSecretUseCase) GetSecretMessage(arg0 context.Context, arg1, arg2, arg3 string) (domain.Secret, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetSecretMessage", arg0, arg1)
+	ret := m.ctrl.Call(m, "GetSecretMessage", arg0, arg1, arg2, arg3)
 	ret0, _ := ret[0].(domain.Secret)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetSecretMessage indicates an expected call of GetSecretMessage.
-func (mr *MockSecretUseCaseMockRecorder) GetSecretMessage(arg0, arg1 interface{}) *gomock.Call {
+func (mr *MockSecretUseCaseMockRecorder) GetSecretMessage(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSecretMessage", reflect.TypeOf((*MockSecretUseCase)(nil).GetSecretMessage), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSecretMessage", reflect.TypeOf((*MockSecretUseCase)(nil).GetSecretMessage), arg0, arg1, arg2, arg3)
+}
+
+// GetSecretStatus mocks base method.
+func (m *MockSecretUseCase) GetSecretStatus(arg0 context.Context, arg1, arg2 string) (domain.SecretStatus, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSecretStatus", arg0, arg1, arg2)
+	ret0, _ := ret[0].(domain.SecretStatus)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSecretStatus indicates an expected call of GetSecretStatus.
+func (mr *MockSecretUseCaseMockRecorder) GetSecretStatus(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSecretStatus", reflect.TypeOf((*MockSecretUseCase)(nil).GetSecretStatus), arg0, arg1, arg2)
+}
+
+// ListOwnSecrets mocks base method.
+func (m *MockSecretUseCase) ListOwnSecrets(arg0 context.Context, arg1 string, arg2 int) ([]domain.SecretStatus, string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListOwnSecrets", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]domain.SecretStatus)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// ListOwnSecrets indicates an expected call of ListOwnSecrets.
+func (mr *MockSecretUseCaseMockRecorder) ListOwnSecrets(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListOwnSecrets", reflect.TypeOf((*MockSecretUseCase)(nil).ListOwnSecrets), arg0, arg1, arg2)
+}
+
+// RevokeSecret mocks base method.
+func (m *MockSecretUseCase) RevokeSecret(arg0 context.Context, arg1, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeSecret", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeSecret indicates an expected call of RevokeSecret.
+func (mr *MockSecretUseCaseMockRecorder) RevokeSecret(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeSecret", reflect.TypeOf((*MockSecretUseCase)(nil).RevokeSecret), arg0, arg1, arg2)
 }
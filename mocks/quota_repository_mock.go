@@ -0,0 +1,65 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/nalawade41/secret-server/internal/domain (interfaces: QuotaRepository)
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+	domain "github.com/nalawade41/secret-server/internal/domain"
+)
+
+// MockQuotaRepository is a mock of QuotaRepository interface.
+type MockQuotaRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockQuotaRepositoryMockRecorder
+}
+
+// MockQuotaRepositoryMockRecorder is the mock recorder for MockQuotaRepository.
+type MockQuotaRepositoryMockRecorder struct {
+	mock *MockQuotaRepository
+}
+
+// NewMockQuotaRepository creates a new mock instance.
+func NewMockQuotaRepository(ctrl *gomock.Controller) *MockQuotaRepository {
+	mock := &MockQuotaRepository{ctrl: ctrl}
+	mock.recorder = &MockQuotaRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockQuotaRepository) EXPECT() *MockQuotaRepositoryMockRecorder {
+	return m.recorder
+}
+
+// GetUsage mocks base method.
+func (m *MockQuotaRepository) GetUsage(arg0 context.Context, arg1 string) (domain.QuotaUsage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUsage", arg0, arg1)
+	ret0, _ := ret[0].(domain.QuotaUsage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUsage indicates an expected call of GetUsage.
+func (mr *MockQuotaRepositoryMockRecorder) GetUsage(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUsage", reflect.TypeOf((*MockQuotaRepository)(nil).GetUsage), arg0, arg1)
+}
+
+// SaveUsage mocks base method.
+func (m *MockQuotaRepository) SaveUsage(arg0 context.Context, arg1 domain.QuotaUsage) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveUsage", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveUsage indicates an expected call of SaveUsage.
+func (mr *MockQuotaRepositoryMockRecorder) SaveUsage(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveUsage", reflect.TypeOf((*MockQuotaRepository)(nil).SaveUsage), arg0, arg1)
+}
@@ -35,6 +35,21 @@ func (m *MockSecretRepository) EXPECT() *MockSecretRepositoryMockRecorder {
 	return m.recorder
 }
 
+// ConsumeView mocks base method.
+func (m *MockSecretRepository) ConsumeView(arg0 context.Context, arg1 string) (domain.Secret, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConsumeView", arg0, arg1)
+	ret0, _ := ret[0].(domain.Secret)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ConsumeView indicates an expected call of ConsumeView.
+func (mr *MockSecretRepositoryMockRecorder) ConsumeView(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConsumeView", reflect.TypeOf((*MockSecretRepository)(nil).ConsumeView), arg0, arg1)
+}
+
 // DeleteSecret mocks base method.
 func (m *MockSecretRepository) DeleteSecret(arg0 context.Context, arg1 string) error {
 	m.ctrl.T.Helper()
@@ -64,6 +79,68 @@ func (mr *MockSecretRepositoryMockRecorder) GetByHash(arg0, arg1 interface{}) *g
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByHash", reflect.TypeOf((*MockSecretRepository)(nil).GetByHash), arg0, arg1)
 }
 
+// GetMetadata mocks base method.
+func (m *MockSecretRepository) GetMetadata(arg0 context.Context, arg1 string) (domain.Secret, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMetadata", arg0, arg1)
+	ret0, _ := ret[0].(domain.Secret)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMetadata indicates an expected call of GetMetadata.
+func (mr *MockSecretRepositoryMockRecorder) GetMetadata(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMetadata", reflect.TypeOf((*MockSecretRepository)(nil).GetMetadata), arg0, arg1)
+}
+
+// ListSecrets mocks base method.
+func (m *MockSecretRepository) ListSecrets(arg0 context.Context, arg1 string, arg2 int) ([]domain.Secret, string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListSecrets", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]domain.Secret)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// ListSecrets indicates an expected call of ListSecrets.
+func (mr *MockSecretRepositoryMockRecorder) ListSecrets(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListSecrets", reflect.TypeOf((*MockSecretRepository)(nil).ListSecrets), arg0, arg1, arg2)
+}
+
+// ListSecretsByOwner mocks base method.
+func (m *MockSecretRepository) ListSecretsByOwner(arg0 context.Context, arg1, arg2 string, arg3 int) ([]domain.Secret, string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListSecretsByOwner", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].([]domain.Secret)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// ListSecretsByOwner indicates an expected call of ListSecretsByOwner.
+func (mr *MockSecretRepositoryMockRecorder) ListSecretsByOwner(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListSecretsByOwner", reflect.TypeOf((*MockSecretRepository)(nil).ListSecretsByOwner), arg0, arg1, arg2, arg3)
+}
+
+// RecordFailedAttempt mocks base method.
+func (m *MockSecretRepository) RecordFailedAttempt(arg0 context.Context, arg1 string, arg2 int) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordFailedAttempt", arg0, arg1, arg2)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RecordFailedAttempt indicates an expected call of RecordFailedAttempt.
+func (mr *MockSecretRepositoryMockRecorder) RecordFailedAttempt(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordFailedAttempt", reflect.TypeOf((*MockSecretRepository)(nil).RecordFailedAttempt), arg0, arg1, arg2)
+}
+
 // Save mocks base method.
 func (m *MockSecretRepository) Save(arg0 context.Context, arg1 domain.Secret) error {
 	m.ctrl.T.Helper()
@@ -78,16 +155,30 @@ func (mr *MockSecretRepositoryMockRecorder) Save(arg0, arg1 interface{}) *gomock
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Save", reflect.TypeOf((*MockSecretRepository)(nil).Save), arg0, arg1)
 }
 
-// UpdateSecretViews mocks base method.
-func (m *MockSecretRepository) UpdateSecretViews(arg0 context.Context, arg1 string, arg2 int) error {
+// UpdateEncryption mocks base method.
+func (m *MockSecretRepository) UpdateEncryption(arg0 context.Context, arg1 domain.Secret) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateEncryption", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateEncryption indicates an expected call of UpdateEncryption.
+func (mr *MockSecretRepositoryMockRecorder) UpdateEncryption(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateEncryption", reflect.TypeOf((*MockSecretRepository)(nil).UpdateEncryption), arg0, arg1)
+}
+
+// UpdateWrappedKey mocks base method.
+func (m *MockSecretRepository) UpdateWrappedKey(arg0 context.Context, arg1, arg2, arg3, arg4 string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateSecretViews", arg0, arg1, arg2)
+	ret := m.ctrl.Call(m, "UpdateWrappedKey", arg0, arg1, arg2, arg3, arg4)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// UpdateSecretViews indicates an expected call of UpdateSecretViews.
-func (mr *MockSecretRepositoryMockRecorder) UpdateSecretViews(arg0, arg1, arg2 interface{}) *gomock.Call {
+// UpdateWrappedKey indicates an expected call of UpdateWrappedKey.
+func (mr *MockSecretRepositoryMockRecorder) UpdateWrappedKey(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateSecretViews", reflect.TypeOf((*MockSecretRepository)(nil).UpdateSecretViews), arg0, arg1, arg2)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateWrappedKey", reflect.TypeOf((*MockSecretRepository)(nil).UpdateWrappedKey), arg0, arg1, arg2, arg3, arg4)
 }
@@ -0,0 +1,110 @@
+package router
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nalawade41/secret-server/internal/common/logger"
+	"github.com/nalawade41/secret-server/internal/common/responses"
+	"github.com/nalawade41/secret-server/internal/domain"
+)
+
+// kindStatus maps the kinds of domain errors to the status of the response
+var kindStatus = []struct {
+	kind   error
+	status int
+}{
+	{kind: domain.ErrNotFound, status: http.StatusNotFound},
+	{kind: domain.ErrExpired, status: http.StatusGone},
+	{kind: domain.ErrViewsExhausted, status: http.StatusGone},
+	{kind: domain.ErrValidation, status: http.StatusBadRequest},
+	{kind: domain.ErrUnauthorized, status: http.StatusUnauthorized},
+	{kind: domain.ErrForbidden, status: http.StatusForbidden},
+	{kind: domain.ErrConflict, status: http.StatusConflict},
+	{kind: domain.ErrTooManyRequests, status: http.StatusTooManyRequests},
+	{kind: domain.ErrUnavailable, status: http.StatusServiceUnavailable},
+}
+
+// ErrorHandler writes every error returned by a handler or a middleware as problem details.
+// Domain errors are mapped by their kind and keep their code, errors of echo keep their status and
+// anything else is an internal error whose details are only logged.
+func ErrorHandler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+
+	problem := newProblem(err)
+	// Only the path identifies the occurrence, the query carries the key of the secret
+	problem.Instance = c.Request().URL.Path
+	if problem.Status >= http.StatusInternalServerError {
+		logger.Errorf("request %s %s failed: %v", c.Request().Method, c.Request().URL.Path, err)
+	}
+
+	if c.Request().Method == http.MethodHead {
+		err = c.NoContent(problem.Status)
+	} else {
+		err = responses.ProblemResponse(c, problem)
+	}
+	if err != nil {
+		logger.Errorf("failed to write error response: %v", err)
+	}
+}
+
+// newProblem returns the problem details of the response for err
+func newProblem(err error) responses.Problem {
+	var domainErr *domain.Error
+	if errors.As(err, &domainErr) {
+		for _, ks := range kindStatus {
+			if errors.Is(domainErr.Kind, ks.kind) {
+				problem := responses.Problem{
+					Type:      responses.ProblemType(domainErr.Code),
+					Title:     domainErr.Message,
+					Status:    ks.status,
+					ErrorCode: domainErr.Code,
+					Errors:    domainErr.Fields,
+				}
+				if len(domainErr.Fields) > 0 {
+					problem.Detail = fmt.Sprintf("%d fields of the request are invalid", len(domainErr.Fields))
+					if len(domainErr.Fields) == 1 {
+						problem.Detail = domainErr.Fields[0].Message
+					}
+				}
+				return problem
+			}
+		}
+	}
+
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		problem := responses.Problem{
+			Type:      responses.ProblemTypeBlank,
+			Title:     http.StatusText(httpErr.Code),
+			Status:    httpErr.Code,
+			ErrorCode: httpErrorCode(httpErr.Code),
+		}
+		// The messages of echo and its middlewares are meant for clients
+		if message, ok := httpErr.Message.(string); ok && !strings.EqualFold(message, problem.Title) {
+			problem.Detail = message
+		}
+		return problem
+	}
+
+	return responses.Problem{
+		Type:      responses.ProblemTypeBlank,
+		Title:     http.StatusText(http.StatusInternalServerError),
+		Status:    http.StatusInternalServerError,
+		ErrorCode: "internal_error",
+	}
+}
+
+// httpErrorCode derives the code of errors raised by echo and its middlewares from their status
+func httpErrorCode(status int) string {
+	text := http.StatusText(status)
+	if text == "" {
+		return "http_error"
+	}
+	return strings.ReplaceAll(strings.ToLower(text), " ", "_")
+}
@@ -0,0 +1,149 @@
+package router
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nalawade41/secret-server/internal/common/responses"
+	"github.com/nalawade41/secret-server/internal/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		statusCode int
+		errorCode  string
+		message    string
+	}{
+		{name: "not found", err: fmt.Errorf("failed to retrieve secret: %w", domain.ErrSecretNotFound), statusCode: http.StatusNotFound, errorCode: "secret_not_found", message: "secret not found"},
+		{name: "invalid key", err: domain.ErrInvalidKey, statusCode: http.StatusNotFound, errorCode: "invalid_key", message: "key does not decrypt the secret"},
+		{name: "expired", err: domain.ErrSecretExpired, statusCode: http.StatusGone, errorCode: "secret_expired", message: "secret expired"},
+		{name: "views exhausted", err: domain.ErrNoRemainingViews, statusCode: http.StatusGone, errorCode: "views_exhausted", message: "secret has no remaining views"},
+		{name: "burned", err: domain.ErrSecretBurned, statusCode: http.StatusGone, errorCode: "secret_burned"},
+		{name: "validation", err: domain.ErrKeyRequired, statusCode: http.StatusBadRequest, errorCode: "key_required", message: "key required"},
+		{name: "unauthorized", err: fmt.Errorf("4 attempts left: %w", domain.ErrWrongPassphrase), statusCode: http.StatusUnauthorized, errorCode: "wrong_passphrase", message: "wrong passphrase"},
+		{name: "forbidden", err: domain.ErrInvalidRevocationToken, statusCode: http.StatusForbidden, errorCode: "invalid_revocation_token", message: "invalid revocation token"},
+		{name: "conflict", err: domain.ErrSecretAlreadyExists, statusCode: http.StatusConflict, errorCode: "secret_already_exists", message: "secret already exists"},
+		{name: "too many requests", err: fmt.Errorf("failed to reserve quota: %w", &domain.QuotaExceededError{}), statusCode: http.StatusTooManyRequests, errorCode: "quota_exceeded", message: "quota exceeded"},
+		{name: "unavailable", err: fmt.Errorf("failed to put item: %w", domain.Unavailable(errors.New("connection refused"))), statusCode: http.StatusServiceUnavailable, errorCode: "service_unavailable", message: "service unavailable"},
+		{name: "echo error", err: echo.ErrMethodNotAllowed, statusCode: http.StatusMethodNotAllowed, errorCode: "method_not_allowed", message: "Method Not Allowed"},
+		{name: "rate limited", err: echo.ErrTooManyRequests, statusCode: http.StatusTooManyRequests, errorCode: "too_many_requests", message: "Too Many Requests"},
+		{name: "unknown", err: errors.New("something broke"), statusCode: http.StatusInternalServerError, errorCode: "internal_error", message: "Internal Server Error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/secret/testhash", nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			ErrorHandler(tt.err, c)
+
+			assert.Equal(t, tt.statusCode, rec.Code)
+			assert.Equal(t, responses.MIMEApplicationProblemJSON, rec.Header().Get(echo.HeaderContentType))
+
+			var body responses.Problem
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+			assert.Equal(t, tt.statusCode, body.Status)
+			assert.Equal(t, tt.errorCode, body.ErrorCode)
+			assert.Equal(t, "/api/v1/secret/testhash", body.Instance)
+			if tt.message != "" {
+				assert.Equal(t, tt.message, body.Title)
+			}
+		})
+	}
+}
+
+func TestErrorHandler_Validation(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/secret?key=testkey", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	ErrorHandler(domain.NewValidationError([]domain.FieldError{
+		{Field: "secret", Message: "secret text is required"},
+		{Field: "expireAfterViews", Message: "remaining views should be greater than 0"},
+	}), c)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	// The query carries the key of a secret, it is left out of the instance
+	assert.JSONEq(t, `{
+		"type": "urn:secret-server:problem:invalid_input",
+		"title": "invalid input",
+		"status": 400,
+		"detail": "2 fields of the request are invalid",
+		"instance": "/api/v1/secret",
+		"errorCode": "invalid_input",
+		"errors": [
+			{"field": "secret", "message": "secret text is required"},
+			{"field": "expireAfterViews", "message": "remaining views should be greater than 0"}
+		]
+	}`, rec.Body.String())
+}
+
+func TestErrorHandler_XML(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/secret/testhash", nil)
+	req.Header.Set(echo.HeaderAccept, echo.MIMEApplicationXML)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	ErrorHandler(domain.ErrSecretExpired, c)
+
+	assert.Equal(t, http.StatusGone, rec.Code)
+	assert.Equal(t, responses.MIMEApplicationProblemXML, rec.Header().Get(echo.HeaderContentType))
+
+	var body responses.Problem
+	assert.NoError(t, xml.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, "urn:secret-server:problem:secret_expired", body.Type)
+	assert.Equal(t, "secret_expired", body.ErrorCode)
+}
+
+func TestErrorHandler_HidesCause(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/secret/testhash", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	// The cause of an outage stays in the logs, it may name hosts and tables
+	ErrorHandler(domain.Unavailable(errors.New("dial tcp 10.0.0.1:8000: connection refused")), c)
+
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "10.0.0.1")
+}
+
+func TestErrorHandler_Committed(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	assert.NoError(t, c.String(http.StatusOK, "partial"))
+
+	// A response that was already sent is left alone
+	ErrorHandler(domain.ErrSecretNotFound, c)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "partial", rec.Body.String())
+}
+
+func TestErrorHandler_Head(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodHead, "/api/v1/secret/testhash", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	ErrorHandler(domain.ErrSecretNotFound, c)
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Empty(t, rec.Body.String())
+}
@@ -0,0 +1,78 @@
+package router
+
+import (
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nalawade41/secret-server/config"
+	"github.com/nalawade41/secret-server/internal/common/logger"
+	"github.com/nalawade41/secret-server/internal/common/security"
+	"github.com/nalawade41/secret-server/internal/domain"
+)
+
+// bearerScheme starts the Authorization header of a bearer token, the scheme is case insensitive
+const bearerScheme = "bearer "
+
+// JWTAuth returns a middleware that verifies the bearer token of a request and puts the principal of its
+// subject into the request context. Requests without a bearer token pass through anonymously, the routes
+// that need a caller check for the principal.
+//
+// Users of the identity provider may create secrets, members of the admin groups may manage API keys as well.
+// Users with a tenant claim are restricted to the routes of their tenant, like keys of a tenant they are never admins.
+func JWTAuth(verifier *security.JWTVerifier, cfg *config.AuthConfig) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			header := c.Request().Header.Get(echo.HeaderAuthorization)
+			if len(header) < len(bearerScheme) || !strings.EqualFold(header[:len(bearerScheme)], bearerScheme) {
+				return next(c)
+			}
+
+			ctx := c.Request().Context()
+			claims, err := verifier.Verify(ctx, strings.TrimSpace(header[len(bearerScheme):]))
+			if err != nil {
+				logger.Warnf("rejected bearer token: %v", err)
+				c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Bearer error="invalid_token"`)
+				return domain.ErrInvalidToken
+			}
+
+			groups, err := claims.StringList(cfg.JWTGroupsClaim)
+			if err != nil {
+				logger.Warnf("rejected bearer token of %s: invalid %s claim", claims.Subject, cfg.JWTGroupsClaim)
+				c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Bearer error="invalid_token"`)
+				return domain.ErrInvalidToken
+			}
+
+			tenant, err := claims.String(cfg.JWTTenantClaim)
+			if err != nil {
+				logger.Warnf("rejected bearer token of %s: invalid %s claim", claims.Subject, cfg.JWTTenantClaim)
+				c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Bearer error="invalid_token"`)
+				return domain.ErrInvalidToken
+			}
+
+			principal := domain.Principal{
+				Subject: domain.SubjectPrefixJWT + claims.Subject,
+				Groups:  groups,
+				Scopes:  []string{domain.ScopeSecretsCreate},
+				Tenant:  tenant,
+			}
+			if tenant == domain.DefaultTenant && anyOf(groups, cfg.JWTAdminGroups) {
+				principal.Scopes = append(principal.Scopes, domain.ScopeAdmin)
+			}
+
+			c.SetRequest(c.Request().WithContext(domain.ContextWithPrincipal(ctx, principal)))
+			return next(c)
+		}
+	}
+}
+
+// anyOf reports whether any of the values is in the list
+func anyOf(values []string, list []string) bool {
+	for _, value := range values {
+		for _, entry := range list {
+			if value == entry {
+				return true
+			}
+		}
+	}
+	return false
+}
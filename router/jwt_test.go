@@ -0,0 +1,125 @@
+package router
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nalawade41/secret-server/config"
+	"github.com/nalawade41/secret-server/internal/common/security"
+	"github.com/nalawade41/secret-server/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestJWTServer serves the principal of the request behind JWTAuth, tokens are signed by the returned function
+func newTestJWTServer(t *testing.T) (*echo.Echo, func(claims map[string]interface{}) string) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	encode := base64.RawURLEncoding.EncodeToString
+	keys, err := security.ParseJWKS([]byte(fmt.Sprintf(`{"keys":[{"kty":"OKP","crv":"Ed25519","kid":"test","x":"%s"}]}`, encode(publicKey))))
+	require.NoError(t, err)
+
+	verifier := &security.JWTVerifier{
+		Issuer:     "https://sso.example.com",
+		Audience:   "secret-server",
+		Algorithms: security.JWTAlgorithms,
+		ClockSkew:  time.Minute,
+		Keys:       security.StaticKeySet(keys),
+	}
+	cfg := &config.AuthConfig{JWTGroupsClaim: "groups", JWTAdminGroups: []string{"secret-admins"}, JWTTenantClaim: "tenant"}
+
+	e := echo.New()
+	e.HTTPErrorHandler = ErrorHandler
+	e.Group("/api/v1", JWTAuth(verifier, cfg)).GET("/whoami", func(c echo.Context) error {
+		principal, ok := domain.PrincipalFromContext(c.Request().Context())
+		if !ok {
+			return c.NoContent(http.StatusNoContent)
+		}
+		return c.JSON(http.StatusOK, principal)
+	})
+
+	sign := func(claims map[string]interface{}) string {
+		claimsJSON, err := json.Marshal(claims)
+		require.NoError(t, err)
+		input := encode([]byte(`{"alg":"EdDSA","kid":"test"}`)) + "." + encode(claimsJSON)
+		return input + "." + encode(ed25519.Sign(privateKey, []byte(input)))
+	}
+
+	return e, sign
+}
+
+func TestJWTAuth(t *testing.T) {
+	e, sign := newTestJWTServer(t)
+
+	claims := func(groups ...string) map[string]interface{} {
+		return map[string]interface{}{
+			"iss":    "https://sso.example.com",
+			"aud":    "secret-server",
+			"sub":    "alice",
+			"exp":    time.Now().Add(time.Hour).Unix(),
+			"groups": groups,
+		}
+	}
+	expired := claims()
+	expired["exp"] = time.Now().Add(-time.Hour).Unix()
+	tenantAdmin := claims("secret-admins")
+	tenantAdmin["tenant"] = "team-a"
+	invalidTenant := claims()
+	invalidTenant["tenant"] = []string{"team-a", "team-b"}
+	apiKeySubject := claims()
+	apiKeySubject["sub"] = domain.SubjectPrefixAPIKey + "key-1"
+
+	tests := []struct {
+		name          string
+		authorization string
+		wantStatus    int
+		wantSubject   string
+		wantScopes    []string
+		wantTenant    string
+	}{
+		{name: "anonymous", wantStatus: http.StatusNoContent},
+		{name: "other scheme", authorization: "Basic YWxpY2U6c2VjcmV0", wantStatus: http.StatusNoContent},
+		{name: "user", authorization: "Bearer " + sign(claims("engineering")), wantStatus: http.StatusOK, wantSubject: "jwt:alice", wantScopes: []string{domain.ScopeSecretsCreate}},
+		{name: "admin", authorization: "bearer " + sign(claims("engineering", "secret-admins")), wantStatus: http.StatusOK, wantSubject: "jwt:alice", wantScopes: []string{domain.ScopeSecretsCreate, domain.ScopeAdmin}},
+		{name: "tenant user", authorization: "Bearer " + sign(tenantAdmin), wantStatus: http.StatusOK, wantSubject: "jwt:alice", wantScopes: []string{domain.ScopeSecretsCreate}, wantTenant: "team-a"},
+		{name: "subject of an API key", authorization: "Bearer " + sign(apiKeySubject), wantStatus: http.StatusOK, wantSubject: "jwt:apikey:key-1", wantScopes: []string{domain.ScopeSecretsCreate}},
+		{name: "invalid tenant claim", authorization: "Bearer " + sign(invalidTenant), wantStatus: http.StatusUnauthorized},
+		{name: "expired token", authorization: "Bearer " + sign(expired), wantStatus: http.StatusUnauthorized},
+		{name: "malformed token", authorization: "Bearer not-a-token", wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/whoami", nil)
+			if tt.authorization != "" {
+				req.Header.Set(echo.HeaderAuthorization, tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+
+			switch tt.wantStatus {
+			case http.StatusOK:
+				var principal domain.Principal
+				require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &principal))
+				// The subject of a user never collides with the subject of an API key
+				assert.Equal(t, tt.wantSubject, principal.Subject)
+				assert.Equal(t, tt.wantScopes, principal.Scopes)
+				assert.Equal(t, tt.wantTenant, principal.Tenant)
+			case http.StatusUnauthorized:
+				assert.Contains(t, rec.Body.String(), "invalid_token")
+				assert.Equal(t, `Bearer error="invalid_token"`, rec.Header().Get(echo.HeaderWWWAuthenticate))
+			}
+		})
+	}
+}
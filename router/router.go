@@ -1,6 +1,7 @@
 package router
 
 import (
+	"io"
 	"net/http"
 	"os"
 
@@ -9,28 +10,31 @@ import (
 	"github.com/nalawade41/secret-server/config"
 	"github.com/nalawade41/secret-server/db"
 	_ "github.com/nalawade41/secret-server/docs"
+	"github.com/nalawade41/secret-server/internal/common/security"
+	"github.com/nalawade41/secret-server/internal/domain"
 	"github.com/nalawade41/secret-server/internal/wire"
 	echoSwagger "github.com/swaggo/echo-swagger"
 )
 
 type Handler struct {
-	config    *config.Config
-	dbConnect db.DynamoDBAPI
+	config      *config.Config
+	storage     *db.Storage
+	keyProvider security.KeyProvider
+	// verifier checks bearer tokens, nil when the identity provider is not configured
+	verifier *security.JWTVerifier
 }
 
-func NewHandler(cfg *config.Config, db db.DynamoDBAPI) *Handler {
-	return &Handler{config: cfg, dbConnect: db}
+func NewHandler(cfg *config.Config, storage *db.Storage, keyProvider security.KeyProvider, verifier *security.JWTVerifier) *Handler {
+	return &Handler{config: cfg, storage: storage, keyProvider: keyProvider, verifier: verifier}
 }
 
 func (h *Handler) Init() *echo.Echo {
 	// Init echo router
 	e := echo.New()
+	e.HTTPErrorHandler = ErrorHandler
 	e.Use(
 		middleware.Recover(),
-		middleware.LoggerWithConfig(middleware.LoggerConfig{
-			Output: os.Stdout,
-		}),
-		middleware.RateLimiter(middleware.NewRateLimiterMemoryStore(200)),
+		accessLog(os.Stdout),
 		middleware.CORS(),
 	)
 
@@ -49,11 +53,47 @@ func (h *Handler) Init() *echo.Echo {
 }
 
 func (h *Handler) initAPI(e *echo.Echo) {
-	secretManager := wire.InitializeRouteProvider(h.dbConnect, h.config.Database.TableName)
-	api := e.Group("/api/v1")
+	secretManager := wire.InitializeRouteProvider(h.storage, h.config.Database.TableName, h.config.Secret, h.config.Quota, h.keyProvider)
+	apiKeys := wire.InitializeAPIKeyProvider(h.storage, h.config.Database.APIKeyTableName, h.config.Secret, h.keyProvider)
+
+	// Creating secrets can be left open for deployments behind their own authentication
+	var createMiddleware []echo.MiddlewareFunc
+	if h.config.Auth.APIKeysRequired {
+		createMiddleware = append(createMiddleware, apiKeys.RequireScope(domain.ScopeSecretsCreate))
+	}
+
+	// Bearer tokens and API keys are checked for every route, the principal is then known to all of them
+	var apiMiddleware []echo.MiddlewareFunc
+	if h.verifier != nil {
+		apiMiddleware = append(apiMiddleware, JWTAuth(h.verifier, h.config.Auth))
+	}
+	apiMiddleware = append(apiMiddleware, apiKeys.Authenticate(), TenantScope(h.config.Secret.Tenants))
+
+	api := e.Group("/api/v1", apiMiddleware...)
 	{
-		secretManager.InitRoutes(api)
+		secretManager.InitRoutes(api, createMiddleware...)
+		apiKeys.InitRoutes(api)
 	}
+
+	// Every tenant has the secret routes of its own, a link of a tenant only resolves through them
+	if len(h.config.Secret.Tenants) > 0 {
+		secretManager.InitRoutes(api.Group("/tenants/:"+tenantParam), createMiddleware...)
+	}
+}
+
+// accessLogFormat is the default format of the echo logger with the path in place of the uri. The query holds
+// the key of a secret link, it must never be written to the access logs.
+const accessLogFormat = `{"time":"${time_rfc3339_nano}","id":"${id}","remote_ip":"${remote_ip}",` +
+	`"host":"${host}","method":"${method}","path":"${path}","user_agent":"${user_agent}",` +
+	`"status":${status},"error":"${error}","latency":${latency},"latency_human":"${latency_human}"` +
+	`,"bytes_in":${bytes_in},"bytes_out":${bytes_out}}` + "\n"
+
+// accessLog returns the middleware that logs every request to out
+func accessLog(out io.Writer) echo.MiddlewareFunc {
+	return middleware.LoggerWithConfig(middleware.LoggerConfig{
+		Format: accessLogFormat,
+		Output: out,
+	})
 }
 
 // HealthCheck godoc
@@ -1,13 +1,18 @@
 package router
 
 import (
+	"bytes"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
 	"github.com/nalawade41/secret-server/config"
+	"github.com/nalawade41/secret-server/db"
+	"github.com/nalawade41/secret-server/internal/common/security"
+	"github.com/nalawade41/secret-server/internal/domain"
 	"github.com/nalawade41/secret-server/mocks"
 	"github.com/stretchr/testify/assert"
 )
@@ -16,13 +21,15 @@ func TestNewHandler(t *testing.T) {
 	cfg := &config.Config{
 		Environment: config.EnvLocal,
 	}
-	db := &dynamodb.Client{}
+	storage := &db.Storage{Backend: config.StorageDynamoDB, DynamoDB: &dynamodb.Client{}}
+	keyProvider, _ := security.NewLocalKeyProvider("test", make([]byte, 32))
 
-	handler := NewHandler(cfg, db)
+	handler := NewHandler(cfg, storage, keyProvider, nil)
 
 	assert.NotNil(t, handler)
 	assert.Equal(t, cfg, handler.config)
-	assert.Equal(t, db, handler.dbConnect)
+	assert.Equal(t, storage, handler.storage)
+	assert.Equal(t, keyProvider, handler.keyProvider, nil)
 }
 
 func TestHandler_Init(t *testing.T) {
@@ -35,13 +42,22 @@ func TestHandler_Init(t *testing.T) {
 		Environment: config.EnvLocal,
 		HTTP:        nil,
 		Database: &config.DynamoConfig{
-			Host:      "localhost",
-			Port:      "8000",
-			TableName: "secrets",
+			Host:            "localhost",
+			Port:            "8000",
+			TableName:       "secrets",
+			APIKeyTableName: "api_keys",
 		},
 		AWS: nil,
+		Secret: &config.SecretConfig{
+			IDLength:   22,
+			IDEncoding: security.IDEncodingBase62,
+			Tenants:    map[string]domain.TenantPolicy{"team-a": {}},
+		},
+		Auth:  &config.AuthConfig{APIKeysRequired: true},
+		Quota: &config.QuotaConfig{TableName: "quotas"},
 	}
-	handler := NewHandler(cfg, mockDynamoClient)
+	keyProvider, _ := security.NewLocalKeyProvider("test", make([]byte, 32))
+	handler := NewHandler(cfg, &db.Storage{Backend: config.StorageDynamoDB, DynamoDB: mockDynamoClient}, keyProvider, nil)
 
 	// Initialize the Echo instance
 	e := handler.Init()
@@ -62,6 +78,32 @@ func TestHandler_Init(t *testing.T) {
 	e.ServeHTTP(rec, req)
 
 	assert.Equal(t, http.StatusOK, rec.Code)
+
+	// Creating secrets and the admin API are rejected before the storage is touched without an API key
+	for _, path := range []string{"/api/v1/secret", "/api/v1/tenants/team-a/secret", "/api/v1/admin/keys"} {
+		req = httptest.NewRequest(http.MethodPost, path, nil)
+		rec = httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code, path)
+		assert.Contains(t, rec.Body.String(), "api_key_required", path)
+	}
+
+	// Only authenticated callers have secrets of their own
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/secrets", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Contains(t, rec.Body.String(), "api_key_required")
+
+	// The routes of tenants that are not configured do not exist
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/tenants/team-b/secret/abc?key=def", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Contains(t, rec.Body.String(), "tenant_not_found")
 }
 
 func TestHandler_Init_Production(t *testing.T) {
@@ -74,13 +116,20 @@ func TestHandler_Init_Production(t *testing.T) {
 		Environment: config.Prod,
 		HTTP:        nil,
 		Database: &config.DynamoConfig{
-			Host:      "localhost",
-			Port:      "8000",
-			TableName: "secrets",
+			Host:            "localhost",
+			Port:            "8000",
+			TableName:       "secrets",
+			APIKeyTableName: "api_keys",
 		},
 		AWS: nil,
+		Secret: &config.SecretConfig{
+			IDLength:   22,
+			IDEncoding: security.IDEncodingBase62,
+		},
+		Auth: &config.AuthConfig{APIKeysRequired: true},
 	}
-	handler := NewHandler(cfg, mockDynamoClient)
+	keyProvider, _ := security.NewLocalKeyProvider("test", make([]byte, 32))
+	handler := NewHandler(cfg, &db.Storage{Backend: config.StorageDynamoDB, DynamoDB: mockDynamoClient}, keyProvider, nil)
 
 	// Initialize the Echo instance
 	e := handler.Init()
@@ -92,3 +141,20 @@ func TestHandler_Init_Production(t *testing.T) {
 
 	assert.Equal(t, http.StatusNotFound, rec.Code)
 }
+
+func TestAccessLog(t *testing.T) {
+	var out bytes.Buffer
+
+	e := echo.New()
+	e.Use(accessLog(&out))
+	e.GET("/api/v1/secret/:hash", func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/secret/abc?key=linkkey", nil)
+	e.ServeHTTP(httptest.NewRecorder(), req)
+
+	// The request is logged without the key of the link
+	assert.Contains(t, out.String(), `"path":"/api/v1/secret/abc"`)
+	assert.NotContains(t, out.String(), "linkkey")
+}
@@ -0,0 +1,37 @@
+package router
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/nalawade41/secret-server/internal/domain"
+)
+
+// tenantParam is the path parameter of the routes of a tenant
+const tenantParam = "tenant"
+
+// TenantScope returns a middleware that puts the tenant of the route into the request context, the secrets are
+// then only looked up in that tenant. Routes without a tenant are the routes of the default tenant, the routes of
+// a tenant that is not configured are not found.
+//
+// An authenticated caller may only use the routes of its own tenant, a key or token of a tenant can neither
+// create secrets in another tenant nor manage them. Reading a secret only needs its link, which only resolves
+// through the routes of the tenant the secret was created in.
+func TenantScope(tenants map[string]domain.TenantPolicy) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			tenant := c.Param(tenantParam)
+			if tenant != domain.DefaultTenant {
+				if _, ok := tenants[tenant]; !ok {
+					return domain.ErrTenantNotFound
+				}
+			}
+
+			ctx := c.Request().Context()
+			if principal, ok := domain.PrincipalFromContext(ctx); ok && principal.Tenant != tenant {
+				return domain.ErrTenantMismatch
+			}
+
+			c.SetRequest(c.Request().WithContext(domain.ContextWithTenant(ctx, tenant)))
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nalawade41/secret-server/internal/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTenantScope(t *testing.T) {
+	tenants := map[string]domain.TenantPolicy{"team-a": {}, "team-b": {}}
+
+	e := echo.New()
+	e.HTTPErrorHandler = ErrorHandler
+	whoami := func(c echo.Context) error {
+		return c.String(http.StatusOK, "tenant="+domain.TenantFromContext(c.Request().Context()))
+	}
+	withPrincipal := func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if tenant, ok := c.Request().Header["X-Test-Tenant"]; ok {
+				principal := domain.Principal{Subject: "alice", Tenant: tenant[0]}
+				c.SetRequest(c.Request().WithContext(domain.ContextWithPrincipal(c.Request().Context(), principal)))
+			}
+			return next(c)
+		}
+	}
+	api := e.Group("/api/v1", withPrincipal, TenantScope(tenants))
+	api.GET("/secret", whoami)
+	api.GET("/tenants/:tenant/secret", whoami)
+
+	tests := []struct {
+		name       string
+		path       string
+		principal  *string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "default routes", path: "/api/v1/secret", wantStatus: http.StatusOK, wantBody: "tenant="},
+		{name: "tenant routes", path: "/api/v1/tenants/team-a/secret", wantStatus: http.StatusOK, wantBody: "tenant=team-a"},
+		{name: "unknown tenant", path: "/api/v1/tenants/team-c/secret", wantStatus: http.StatusNotFound, wantBody: "tenant_not_found"},
+		{name: "caller of the tenant", path: "/api/v1/tenants/team-a/secret", principal: stringPtr("team-a"), wantStatus: http.StatusOK, wantBody: "tenant=team-a"},
+		{name: "caller of another tenant", path: "/api/v1/tenants/team-b/secret", principal: stringPtr("team-a"), wantStatus: http.StatusForbidden, wantBody: "tenant_mismatch"},
+		{name: "caller of a tenant on the default routes", path: "/api/v1/secret", principal: stringPtr("team-a"), wantStatus: http.StatusForbidden, wantBody: "tenant_mismatch"},
+		{name: "caller of the default tenant on tenant routes", path: "/api/v1/tenants/team-a/secret", principal: stringPtr(""), wantStatus: http.StatusForbidden, wantBody: "tenant_mismatch"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.principal != nil {
+				req.Header["X-Test-Tenant"] = []string{*tt.principal}
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.wantBody)
+		})
+	}
+}
+
+func stringPtr(value string) *string {
+	return &value
+}
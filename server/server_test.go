@@ -70,11 +70,10 @@ func TestServer_Stop(t *testing.T) {
 		},
 	}
 
+	runErr := make(chan error, 1)
 	go func() {
 		// Start the server in a goroutine
-		if err := server.Run(); err != nil && err != http.ErrServerClosed {
-			t.Fatalf("Failed to start server: %v", err)
-		}
+		runErr <- server.Run()
 	}()
 
 	// Allow some time for the server to start
@@ -83,4 +82,8 @@ func TestServer_Stop(t *testing.T) {
 	// Stop the server and check for errors
 	err := server.Stop(context.Background())
 	assert.NoError(t, err)
+
+	if err := <-runErr; err != nil && err != http.ErrServerClosed {
+		t.Fatalf("Failed to start server: %v", err)
+	}
 }